@@ -0,0 +1,80 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Applier serializes the applies to each target, so that goroutines changing
+// the same record (by type and ID field, even through different copies of it)
+// can't interleave their decoding and metadata stamping. Applies to different
+// records run concurrently, though records sharing one of the Applier's lock
+// stripes wait for each other. Targets without an ID, or whose ID is still the
+// zero value, are locked by address.
+type Applier struct {
+	opts    []Option
+	stripes []sync.Mutex
+}
+
+// NewApplier returns an Applier with the given number of lock stripes (more
+// stripes means fewer unrelated records waiting on each other), applying opts
+// before those given to each call
+func NewApplier(stripes int, opts ...Option) *Applier {
+	if stripes < 1 {
+		stripes = 1
+	}
+
+	return &Applier{opts: opts, stripes: make([]sync.Mutex, stripes)}
+}
+
+// ApplyChangesWrapper is ApplyChangesWrapper holding the target's lock
+func (a *Applier) ApplyChangesWrapper(changes map[string]interface{}, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	unlock := a.Lock(to)
+	defer unlock()
+
+	return ApplyChangesWrapper(changes, modifier, to, a.options(opts)...)
+}
+
+// ApplyChangesAs is ApplyChangesAs holding the target's lock
+func (a *Applier) ApplyChangesAs(changes map[string]interface{}, principal Principal, to interface{}, opts ...Option) (ApplyResult, error) {
+	unlock := a.Lock(to)
+	defer unlock()
+
+	return ApplyChangesAs(changes, principal, to, a.options(opts)...)
+}
+
+// ApplyChangesCtx is ApplyChangesCtx holding the target's lock
+func (a *Applier) ApplyChangesCtx(ctx context.Context, changes map[string]interface{}, to interface{}, opts ...Option) (ApplyResult, error) {
+	unlock := a.Lock(to)
+	defer unlock()
+
+	return ApplyChangesCtx(ctx, changes, to, a.options(opts)...)
+}
+
+// Lock takes the target's lock, returning the function releasing it, for
+// callers that need to hold it across more than an apply (e.g. a fetch, apply
+// and save). The lock isn't reentrant: while holding it, apply with the
+// package's functions rather than the Applier's, and don't lock another
+// target, which may share its stripe, or the goroutine deadlocks.
+func (a *Applier) Lock(to interface{}) func() {
+	stripe := &a.stripes[a.stripe(to)]
+	stripe.Lock()
+	return stripe.Unlock
+}
+
+func (a *Applier) stripe(to interface{}) int {
+	hash := fnv.New32a()
+	if key, ok := historyKeyOf(to); ok {
+		fmt.Fprintf(hash, "%s\x00%s", key.targetType, key.id)
+	} else {
+		fmt.Fprintf(hash, "%p", to)
+	}
+
+	return int(hash.Sum32() % uint32(len(a.stripes)))
+}
+
+func (a *Applier) options(opts []Option) []Option {
+	return append(append([]Option(nil), a.opts...), opts...)
+}
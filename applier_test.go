@@ -0,0 +1,178 @@
+package applychanges
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// concurrencyProbe records how many applies are in BeforeApply at once
+type concurrencyProbe struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+type serializedRecord struct {
+	BaseStruct
+	Count int `json:"count"`
+
+	probe *concurrencyProbe
+}
+
+func (r *serializedRecord) BeforeApply(map[string]interface{}) error {
+	if r.probe == nil {
+		return nil
+	}
+
+	r.probe.mu.Lock()
+	r.probe.active++
+	if r.probe.active > r.probe.maxActive {
+		r.probe.maxActive = r.probe.active
+	}
+	r.probe.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	r.probe.mu.Lock()
+	r.probe.active--
+	r.probe.mu.Unlock()
+	return nil
+}
+
+func TestApplierSerializes(t *testing.T) {
+	probe := &concurrencyProbe{}
+	record := serializedRecord{probe: probe}
+	record.ID = uuid.New()
+	applier := NewApplier(16)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(count int) {
+			defer wg.Done()
+			if _, err := applier.ApplyChangesWrapper(map[string]interface{}{"count": count}, "EUA1", &record); err != nil {
+				t.Errorf("ApplyChangesWrapper() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if probe.maxActive != 1 {
+		t.Errorf("%d applies ran at once, want them serialized", probe.maxActive)
+	}
+}
+
+func TestApplierLock(t *testing.T) {
+	applier := NewApplier(64)
+
+	saved := func(id uuid.UUID) *serializedRecord {
+		record := &serializedRecord{}
+		record.ID = id
+		return record
+	}
+	first := saved(uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+	firstCopy := *first
+
+	// other records are picked off the first one's stripe
+	other := saved(uuid.New())
+	for applier.stripe(other) == applier.stripe(first) {
+		other = saved(uuid.New())
+	}
+	unsaved, otherUnsaved := &serializedRecord{}, &serializedRecord{}
+	for applier.stripe(otherUnsaved) == applier.stripe(unsaved) {
+		otherUnsaved = &serializedRecord{}
+	}
+
+	tests := []struct {
+		name        string
+		locked      interface{}
+		applied     interface{}
+		wantBlocked bool
+	}{
+		{name: "same record", locked: first, applied: first, wantBlocked: true},
+		{name: "copy of the same record", locked: first, applied: &firstCopy, wantBlocked: true},
+		{name: "another record", locked: first, applied: other},
+		{name: "same unsaved record", locked: unsaved, applied: unsaved, wantBlocked: true},
+		{name: "unsaved records by address", locked: unsaved, applied: otherUnsaved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unlock := applier.Lock(tt.locked)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if _, err := applier.ApplyChangesWrapper(map[string]interface{}{"count": 1}, "EUA1", tt.applied); err != nil {
+					t.Errorf("ApplyChangesWrapper() error = %v", err)
+				}
+			}()
+
+			select {
+			case <-done:
+				if tt.wantBlocked {
+					t.Error("ApplyChangesWrapper() ran while the target was locked")
+				}
+			case <-time.After(20 * time.Millisecond):
+				if !tt.wantBlocked {
+					t.Error("ApplyChangesWrapper() waited for another target's lock")
+				}
+			}
+
+			unlock()
+			<-done
+		})
+	}
+}
+
+func TestApplierOptions(t *testing.T) {
+	applierTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	callTime := applierTime.Add(time.Hour)
+	applier := NewApplier(0, WithIgnoreUnknownFields(), WithClock(fixedClock(applierTime)))
+
+	tests := []struct {
+		name  string
+		apply func(to *serializedRecord) error
+		want  time.Time
+	}{
+		{
+			name: "wrapper",
+			apply: func(to *serializedRecord) error {
+				_, err := applier.ApplyChangesWrapper(map[string]interface{}{"count": 1, "zip": "33602"}, "EUA1", to)
+				return err
+			},
+			want: applierTime,
+		},
+		{
+			name: "as a principal",
+			apply: func(to *serializedRecord) error {
+				_, err := applier.ApplyChangesAs(map[string]interface{}{"count": 1, "zip": "33602"}, Principal{ID: "EUA1"}, to)
+				return err
+			},
+			want: applierTime,
+		},
+		{
+			name: "call options after the applier's",
+			apply: func(to *serializedRecord) error {
+				_, err := applier.ApplyChangesWrapper(map[string]interface{}{"count": 1, "zip": "33602"}, "EUA1", to, WithClock(fixedClock(callTime)))
+				return err
+			},
+			want: callTime,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := serializedRecord{}
+			if err := tt.apply(&record); err != nil {
+				t.Fatalf("apply error = %v", err)
+			}
+			if record.Count != 1 || record.ModifiedDts == nil || !record.ModifiedDts.Equal(tt.want) {
+				t.Errorf("record = %+v, want count 1 stamped at %v", record, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,638 @@
+// Package applychanges applies gqlgen-style changesets (a map of json keys to
+// new values) onto Go structs, stamping modification metadata along the way.
+//
+// The approach is taken from https://github.com/CMSgov/easi-app/pull/1760 and
+// https://gqlgen.com/reference/changesets/.
+package applychanges
+
+import (
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mitchellh/mapstructure"
+)
+
+// ApplyChanges decodes the changes onto the struct pointed to by to, matching
+// keys against json tags (see the Options for changing this). The changes are
+// sanitized in place first (see Sanitize).
+//
+// A nested map is merged into a struct (or pointer to struct) field, so
+// {"address": {"city": "Tampa"}} only changes the address's city. Map fields
+// are replaced by the nested map instead, unless WithZeroFields(false) is
+// given.
+//
+// An apply goes through these stages, any of which can fail it:
+//   - the changes' keys are checked and normalized against the target's
+//     fields, and they are sanitized
+//   - the target's state is checked: WithRejectDeleted, WithExpectedETag and
+//     any Conditions
+//   - the changed fields are authorized, by their paths, and a BeforeApplier
+//     may normalize the changes before the metadata is stamped
+//   - the changes are decoded onto the target (or a copy of it, see below)
+//   - the ValueAuthorizers check the typed values
+//   - an AfterApplier derives fields from the applied changes
+//   - the Validator checks the target
+//   - the apply is published and then audited
+//
+// Nothing is changed by a failure before the decode. An apply with
+// ValueAuthorizers, an AfterApplier or a Validator is decoded onto a copy of
+// the target, assigned to it once the ValueAuthorizers pass, and the whole
+// target is put back if a later stage fails; any other failed apply puts back
+// just the fields its changes touched.
+func ApplyChanges(changes map[string]interface{}, to interface{}, opts ...Option) (ApplyResult, error) {
+	return applyChanges(changes, to, configFor(reflect.TypeOf(to), opts))
+}
+
+func applyChanges(changes map[string]interface{}, to interface{}, cfg *config) (result ApplyResult, err error) {
+	cfg.now = cfg.clock.Now().UTC()
+	timer := cfg.stageTimer()
+
+	if cfg.messageCatalog != nil {
+		defer func() {
+			if err != nil {
+				err = &catalogError{err: err, catalog: cfg.messageCatalog}
+			}
+		}()
+	}
+
+	if err := cfg.ctx.Err(); err != nil {
+		return ApplyResult{}, err
+	}
+
+	if cfg.impersonation != nil {
+		if err := cfg.impersonate(); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	idempotencyKey, idempotent, err := idempotencyKey(changes, to, cfg)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	if idempotent {
+		stored, ok, err := cfg.idempotencyStore.Load(cfg.ctx, idempotencyKey)
+		if err != nil {
+			return ApplyResult{}, err
+		}
+		if ok {
+			stored.Duplicate = true
+			return stored, nil
+		}
+	}
+
+	if cfg.rateLimiter != nil && !cfg.freeNoOps && !cfg.dryRun {
+		if err := checkRateLimit(to, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if cfg.maxDepth > 0 {
+		if err := checkApplyDepth(changes, reflect.TypeOf(to), cfg.tagName, cfg.maxDepth); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if err := cfg.checkValues(changes); err != nil {
+		return ApplyResult{}, err
+	}
+
+	if target, ok := targetStruct(to); ok {
+		if !cfg.allowConflicts {
+			if err := checkConflictingKeys(changes, target.Type(), cfg, ""); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+
+		if len(cfg.fallbackTagNames) > 0 {
+			if err := applyFallbackTags(changes, target.Type(), cfg); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+
+		if cfg.snakeCaseKeys {
+			if err := normalizeKeyCase(changes, target.Type(), cfg); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+
+		if cfg.caseSensitiveKeys {
+			mismatched, err := checkKeyCase(changes, target.Type(), cfg, "")
+			if err != nil {
+				return ApplyResult{}, err
+			}
+			result.UnknownFields = mismatched
+		}
+
+		if len(cfg.dualWrites) > 0 {
+			if err := renameDualWrites(changes, target.Type(), cfg); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+
+		if cfg.graphQLCoercion {
+			if err := coerceGraphQL(changes, target.Type(), cfg.tagName); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+
+		if err := prepareOptionals(changes, target.Type(), cfg); err != nil {
+			return ApplyResult{}, err
+		}
+
+		if len(cfg.floatTolerances) > 0 {
+			if err := cfg.applyFloatTolerance(changes, target); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+	}
+
+	Sanitize(changes)
+
+	if cfg.rejectDeleted && isDeleted(to) {
+		return ApplyResult{}, ErrDeleted
+	}
+
+	if cfg.expectedETag != nil {
+		if err := checkETag(to, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if len(cfg.conditions) > 0 {
+		if err := checkConditions(to, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if cfg.history != nil && !cfg.dryRun {
+		cfg.history.begin(to)
+	}
+
+	// dry runs never touch the target; staged applies change a copy of it,
+	// committed once they pass (see stagedApply), and everything else changes
+	// it in place, undoing the fields it touched when it fails (see undoLog)
+	original := to
+	var staged *stagedApply
+	if cfg.dryRun {
+		to = copyTarget(to)
+	} else if cfg.stagesApply(to) {
+		staged = stageApply(to)
+		to = staged.copied
+		defer func() {
+			if err != nil && cfg.rollback {
+				staged.restore()
+			}
+		}()
+	}
+
+	changes, err = sanitizeForTarget(changes, to)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	timer.lap(StageSanitize)
+
+	if target, ok := targetStruct(to); ok && cfg.mayDecodeSkip() {
+		result.SkippedFields = skipFields(changes, target.Type(), cfg.decodeHook(), cfg.tagName, "")
+		sort.Slice(result.SkippedFields, func(i, j int) bool {
+			return result.SkippedFields[i].Path < result.SkippedFields[j].Path
+		})
+	}
+
+	var versionKey string
+	var nextVersion int64
+	stampVersion := false
+	if cfg.modifier != nil {
+		if versionKey, nextVersion, stampVersion, err = checkLockVersion(changes, to, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if result.DroppedFields, err = filterFields(changes, to, cfg); err != nil {
+		return ApplyResult{}, err
+	}
+
+	target, isStruct := targetStruct(to)
+	if isStruct {
+		skipped, err := rejectImmutableFields(changes, target, cfg)
+		if err != nil {
+			return ApplyResult{}, err
+		}
+		result.DroppedFields = append(result.DroppedFields, skipped...)
+
+		if cfg.fieldLocks != nil || cfg.fieldLocker != nil {
+			if err := checkFieldLocks(changes, target, original, cfg); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+	}
+
+	if cfg.policy != nil {
+		if err := authorizePolicy(changes, original, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if err := authorizeFields(changes, to, cfg); err != nil {
+		return ApplyResult{}, err
+	}
+
+	if cfg.requireChanges && len(changes) == 0 {
+		return ApplyResult{}, ErrNoChanges
+	}
+	timer.lap(StageAuthorize)
+
+	if !cfg.linting {
+		if err := beforeApply(cfg.ctx, changes, to); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if len(cfg.dualWrites) > 0 {
+		mirrorDualWrites(changes, cfg)
+	}
+
+	if cfg.modifier != nil {
+		if err := stampModifier(changes, to, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if stampVersion {
+		changes[versionKey] = nextVersion
+	}
+
+	var before map[string]interface{}
+	var undo *undoLog
+	if isStruct {
+		flat := isFlatStruct(target.Type())
+		if !cfg.restoring && !flat {
+			if err := encryptFields(changes, target.Type(), cfg, ""); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+
+		// from here on the target itself is changed, which a failure must undo
+		// (a staged copy is just thrown away)
+		undo = newUndoLog(changes, target, cfg.tagName)
+		if staged == nil && cfg.rollback {
+			defer func() {
+				if err != nil {
+					undo.restore()
+				}
+			}()
+		}
+
+		before = undo.snapshot()
+		cleared := prepareNestedChanges(changes, target, cfg.tagName)
+
+		if !flat {
+			if err := parseTaggedDates(changes, target.Type(), cfg.tagName); err != nil {
+				return ApplyResult{}, err
+			}
+
+			if err := validateEnums(changes, target.Type(), cfg.tagName, ""); err != nil {
+				return ApplyResult{}, err
+			}
+		}
+
+		if !cfg.restoring && !flat {
+			skipped, err := mergeCollections(changes, target, cfg, "")
+			if err != nil {
+				return ApplyResult{}, err
+			}
+			if len(skipped) > 0 {
+				result.SkippedFields = append(result.SkippedFields, skipped...)
+				sort.Slice(result.SkippedFields, func(i, j int) bool {
+					return result.SkippedFields[i].Path < result.SkippedFields[j].Path
+				})
+			}
+		}
+
+		result.AppliedFields = appliedFields(changes, target.Type(), cfg.tagName, cleared)
+	}
+
+	// the last chance to give up without having touched the target
+	if err := cfg.ctx.Err(); err != nil {
+		return ApplyResult{}, err
+	}
+
+	var unknown []string
+	if !cfg.decodeFast(changes, to) {
+		unknown, err = cfg.decodeUnused(changes, to)
+	}
+	if err != nil {
+		err = decodeFieldErrors(err)
+		if isStruct {
+			err = redactFieldErrors(err, target.Type(), cfg.tagName)
+		}
+		return ApplyResult{}, err
+	}
+	if len(unknown) > 0 {
+		result.UnknownFields = append(result.UnknownFields, unknown...)
+		sort.Strings(result.UnknownFields)
+	}
+
+	if isStruct {
+		result.Changes = diffFields(before, target, cfg, result.AppliedFields)
+		if len(cfg.dualWrites) > 0 {
+			hideDualWrites(&result, cfg)
+		}
+	}
+	timer.lap(StageDecode)
+
+	if cfg.rateLimiter != nil && cfg.freeNoOps && !cfg.dryRun && changesFields(result.Changes) {
+		if err := checkRateLimit(original, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if len(cfg.valueAuthorizers) > 0 {
+		if err := authorizeValues(result.Changes, cfg); err != nil {
+			return ApplyResult{}, err
+		}
+		timer.lap(StageAuthorize)
+	}
+
+	if staged != nil {
+		to = staged.commit()
+	}
+
+	if !cfg.linting {
+		if err := afterApply(cfg.ctx, result.Changes, to); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if cfg.validator != nil {
+		if err := validate(cfg.ctx, cfg.validator, to); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+	timer.lap(StageValidate)
+
+	// only what the apply reports is truncated, once nothing else needs the
+	// whole values
+	if isStruct && cfg.maxDiffValueBytes > 0 {
+		result.Changes = truncateChanges(result.Changes, cfg.maxDiffValueBytes)
+	}
+
+	// published before it's audited, so an apply that fails to publish has no
+	// audit entry for changes that were undone
+	if err := publishEvent(to, result, cfg); err != nil {
+		return ApplyResult{}, err
+	}
+	timer.lap(StageEmit)
+
+	if err := recordAudit(to, result, cfg); err != nil {
+		return ApplyResult{}, err
+	}
+	timer.lap(StageAudit)
+
+	if idempotent && !cfg.dryRun {
+		if err := cfg.idempotencyStore.Save(cfg.ctx, idempotencyKey, result); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if cfg.history != nil && !cfg.dryRun {
+		cfg.history.record(to, result, cfg)
+	}
+	timer.lap(StageFinalize)
+	timer.finish(&result, to, cfg)
+
+	return result, nil
+}
+
+// decode decodes input onto the result
+func (cfg *config) decode(input interface{}, result interface{}) error {
+	_, err := cfg.decodeUnused(input, result)
+	return err
+}
+
+// decodeUnused decodes input onto the result, returning the (sorted) keys that
+// didn't match a field when they aren't an error
+func (cfg *config) decodeUnused(input interface{}, result interface{}) ([]string, error) {
+	var metadata mapstructure.Metadata
+
+	// Set up the decoder. This is almost exactly ripped from https://gqlgen.com/reference/changesets/
+	decoderConfig := &mapstructure.DecoderConfig{
+		ErrorUnused: cfg.errorUnused,
+		TagName:     cfg.tagName,
+		Result:      result,
+		ZeroFields:  cfg.zeroFields,
+		Squash:      true,
+		DecodeHook:  cfg.decodeHook(),
+		Metadata:    &metadata,
+	}
+	if cfg.caseSensitiveKeys {
+		decoderConfig.MatchName = exactMatch
+	}
+
+	dec, err := mapstructure.NewDecoder(decoderConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dec.Decode(input); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(metadata.Unused)
+	return metadata.Unused, nil
+}
+
+// copyTarget returns a pointer to a deep copy of the target, for a dry run to
+// apply to instead
+func copyTarget(to interface{}) interface{} {
+	target, ok := targetStruct(to)
+	if !ok {
+		return to
+	}
+
+	copied := reflect.New(target.Type())
+	copied.Elem().Set(deepCopy(target))
+	return copied.Interface()
+}
+
+// registeredDecodeHooks holds the decodeHooks added by RegisterDecodeHook,
+// replaced as a whole by every registration
+var registeredDecodeHooks atomic.Value // decodeHooks
+
+// decodeHooks are the registered decode hooks, and composed is them in front of
+// the built-in one, so that applies without hooks of their own don't compose
+// the chain every time
+type decodeHooks struct {
+	hooks    []mapstructure.DecodeHookFunc
+	composed mapstructure.DecodeHookFunc
+}
+
+func loadDecodeHooks() decodeHooks {
+	registered, ok := registeredDecodeHooks.Load().(decodeHooks)
+	if !ok {
+		return decodeHooks{composed: builtinDecodeHook}
+	}
+	return registered
+}
+
+// RegisterDecodeHook adds a decode hook to every apply in the process, run
+// before the hooks added with WithDecodeHook and the built-in conversions
+// (time.Time, time.Duration, uuid.UUID, decimal.Decimal and
+// graphql.Unmarshaler). Hooks are meant to be registered during
+// initialization, but it is safe to do so concurrently with applies, until
+// Freeze.
+func RegisterDecodeHook(hook mapstructure.DecodeHookFunc) {
+	register("RegisterDecodeHook", func() {
+		hooks := append(append([]mapstructure.DecodeHookFunc(nil), loadDecodeHooks().hooks...), hook)
+		registeredDecodeHooks.Store(decodeHooks{
+			hooks:    hooks,
+			composed: mapstructure.ComposeDecodeHookFunc(append(append([]mapstructure.DecodeHookFunc(nil), hooks...), builtinDecodeHook)...),
+		})
+	})
+}
+
+// decodeHook chains the registered and configured decode hooks, and the time
+// parsing configured with WithTimeLayouts, WithEpochTimes and
+// WithDurationUnit, in front of the built-in one
+func (cfg *config) decodeHook() mapstructure.DecodeHookFunc {
+	registered := loadDecodeHooks()
+	if len(cfg.decodeHooks) == 0 && !cfg.parsesTimes() {
+		return registered.composed
+	}
+
+	hooks := append(append([]mapstructure.DecodeHookFunc(nil), registered.hooks...), cfg.decodeHooks...)
+	if cfg.parsesTimes() {
+		hooks = append(hooks, cfg.timeHook)
+	}
+	return mapstructure.ComposeDecodeHookFunc(append(hooks, builtinDecodeHook)...)
+}
+
+var (
+	stringType = reflect.TypeOf("")
+	uuidType   = reflect.TypeOf(uuid.UUID{})
+)
+
+// This is needed to get mapstructure to call the gqlgen unmarshaler func for custom scalars (eg Date)
+// and to parse the strings GraphQL carries times, UUIDs and decimals as
+func builtinDecodeHook(a reflect.Type, b reflect.Type, v interface{}) (interface{}, error) {
+	// If the destination is a registered scalar that the value isn't already
+	if parse, ok := scalarParser(b); ok && a != b {
+		return parse(v)
+	}
+
+	// If the destination is a time.Time and we need to parse it from a string
+	if b == timeType && a == stringType {
+		t, err := time.Parse(time.RFC3339Nano, v.(string))
+		return t, err
+	}
+
+	// If the destination is a time.Duration written like "1h30m"; numbers are
+	// nanoseconds unless WithDurationUnit says otherwise
+	if b == durationType && a == stringType {
+		return time.ParseDuration(v.(string))
+	}
+
+	// If the destination is a uuid.UUID and we need to parse it from a string
+	if b == uuidType && a == stringType {
+		return uuid.Parse(v.(string))
+	}
+
+	// If the destination is a decimal.Decimal, which mustn't go through float64
+	if b == decimalType && a != decimalType {
+		return parseDecimal(v)
+	}
+
+	// If the desination implements graphql.Unmarshaler
+	if reflect.PtrTo(b).Implements(unmarshalerType) {
+		resultType := reflect.New(b)
+		result := resultType.MethodByName("UnmarshalGQL").Call([]reflect.Value{reflect.ValueOf(v)})
+		err, _ := result[0].Interface().(error)
+		return resultType.Elem().Interface(), err
+	}
+
+	return v, nil
+}
+
+// ApplyChangesWrapper applies the changes like ApplyChanges, additionally
+// stamping the modifier as modifiedBy and the current time as modifiedDts (when
+// the target has a ModifiedDts field, see WithModifiedDts and
+// WithModifiedDtsPolicy). Targets with a LockVersion field get optimistic
+// concurrency: see WithExpectedVersion. Note that the stamped values are
+// written into the changes map itself, unless the target is a
+// ChangeSanitizer, in which case they go into the copy it was given.
+func ApplyChangesWrapper(changes map[string]interface{}, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+	cfg.modifier = &modifier
+
+	return applyChanges(changes, to, cfg)
+}
+
+// stampModifier writes the modifier into the changes, after checking the
+// changes don't try to set the stamped fields themselves
+func stampModifier(changes map[string]interface{}, to interface{}, cfg *config) error {
+	if err := rejectRecentModifiersWrite(changes, to, cfg); err != nil {
+		return err
+	}
+
+	if err := rejectPrincipalWrite(changes, to, cfg); err != nil {
+		return err
+	}
+
+	dtsKey, stampDts := modifiedDtsKey(to, cfg)
+	var dts time.Time
+	if stampDts {
+		var err error
+		if dts, err = stampedModifiedDts(changes, dtsKey, cfg); err != nil {
+			return err
+		}
+	}
+
+	modifier := *cfg.modifier
+	if cfg.backfilling {
+		if modifier != "" {
+			changes[metadataKey(to, cfg, "ModifiedBy", "modifiedBy")] = modifier
+		}
+		changes[dtsKey] = dts
+		return nil
+	}
+
+	changes[metadataKey(to, cfg, "ModifiedBy", "modifiedBy")] = modifier
+	if key, recent, ok := nextRecentModifiers(to, modifier, cfg); ok {
+		changes[key] = recent
+	}
+	if key, ok := modifiedByPrincipalKey(to, cfg); ok {
+		changes[key] = principalChange(cfg)
+	}
+	if stampDts {
+		changes[dtsKey] = dts
+	}
+	if cfg.deleting {
+		stampDeleted(changes, to, cfg)
+	}
+
+	return nil
+}
+
+// modifiedDtsKey returns the changes key of the target's ModifiedDts field, or
+// false when it shouldn't be stamped
+func modifiedDtsKey(to interface{}, cfg *config) (string, bool) {
+	if !cfg.modifiedDts {
+		return "", false
+	}
+
+	target, ok := targetStruct(to)
+	if !ok {
+		return "", false
+	}
+
+	field, ok := structFieldByName(target.Type(), "ModifiedDts")
+	if !ok || (field.Type != timeType && field.Type != reflect.PtrTo(timeType)) {
+		return "", false
+	}
+
+	key := fieldKey(field, cfg.tagName)
+	return key, key != ""
+}
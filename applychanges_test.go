@@ -0,0 +1,151 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWithDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+		wantErr bool
+	}{
+		{
+			name:    "applies to a copy",
+			changes: map[string]interface{}{"city": "Tampa", "details": map[string]interface{}{"level": 3}},
+		},
+		{
+			name:    "fails like the apply",
+			changes: map[string]interface{}{"city": 7},
+			wantErr: true,
+		},
+		{
+			name:    "validates",
+			changes: map[string]interface{}{"city": "Tampa"},
+			opts: []Option{WithValidator(ValidatorFunc(func(interface{}) error {
+				return errors.New("closed")
+			}))},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := resultRecord{City: "Miami", Details: &nestedDetails{Source: "survey", Level: 2}}
+			before := deepCopy(reflect.ValueOf(record)).Interface()
+			sink := &recordingSink{}
+
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, append(tt.opts, WithDryRun(), WithAuditSink(sink))...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChangesWrapper() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(record, before) {
+				t.Errorf("record = %+v, want it untouched", record)
+			}
+			if len(sink.entries) != 0 {
+				t.Errorf("audited %d entries, want none for a dry run", len(sink.entries))
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(result.AppliedFields, []string{"city", "details", "modifiedBy", "modifiedDts"}) {
+				t.Errorf("AppliedFields = %v, want what the apply would have changed", result.AppliedFields)
+			}
+			if result.Changes[0].Old != "Miami" || result.Changes[0].New != "Tampa" {
+				t.Errorf("Changes = %+v, want what the apply would have changed", result.Changes)
+			}
+		})
+	}
+}
+
+// suffixHook appends the suffix to the strings decoded into string fields
+func suffixHook(suffix string) func(reflect.Type, reflect.Type, interface{}) (interface{}, error) {
+	return func(from reflect.Type, to reflect.Type, v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok && to.Kind() == reflect.String {
+			return s + suffix, nil
+		}
+		return v, nil
+	}
+}
+
+func TestRegisterDecodeHook(t *testing.T) {
+	tests := []struct {
+		name     string
+		register []string
+		opts     []Option
+		want     string
+	}{
+		{name: "none", want: "Tampa"},
+		{name: "registered", register: []string{"-a"}, want: "Tampa-a"},
+		{name: "in registration order", register: []string{"-a", "-b"}, want: "Tampa-a-b"},
+		{name: "before WithDecodeHook", register: []string{"-a"}, opts: []Option{WithDecodeHook(suffixHook("-o"))}, want: "Tampa-a-o"},
+		{name: "before the time parsing", register: []string{"-a"}, opts: []Option{WithTimeLayouts("2006-01-02")}, want: "Tampa-a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hooks := loadDecodeHooks()
+			t.Cleanup(func() { registeredDecodeHooks.Store(hooks) })
+			for _, suffix := range tt.register {
+				RegisterDecodeHook(suffixHook(suffix))
+			}
+
+			for i := 0; i < 2; i++ {
+				record := nestedRecord{}
+				if _, err := ApplyChanges(map[string]interface{}{"name": "Tampa"}, &record, tt.opts...); err != nil {
+					t.Fatalf("ApplyChanges() error = %v", err)
+				}
+				if record.Name != tt.want {
+					t.Errorf("apply %d: Name = %q, want %q", i, record.Name, tt.want)
+				}
+			}
+		})
+	}
+}
+
+type uuidRecord struct {
+	Network uuid.UUID   `json:"network"`
+	Backup  *uuid.UUID  `json:"backup"`
+	Peers   []uuid.UUID `json:"peers"`
+}
+
+func TestDecodeUUID(t *testing.T) {
+	id := uuid.MustParse("8a7c3b4e-2f1d-4e5a-9b6c-7d8e9f0a1b2c")
+	other := uuid.MustParse("0b6d9a1e-8c4f-4d3b-a2e1-5f7c9d0e1a2b")
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    uuidRecord
+		wantErr bool
+	}{
+		{name: "string", changes: map[string]interface{}{"network": id.String()}, want: uuidRecord{Network: id}},
+		{name: "URN", changes: map[string]interface{}{"network": "urn:uuid:" + id.String()}, want: uuidRecord{Network: id}},
+		{name: "uuid.UUID", changes: map[string]interface{}{"network": id}, want: uuidRecord{Network: id}},
+		{name: "pointer", changes: map[string]interface{}{"backup": other.String()}, want: uuidRecord{Backup: &other}},
+		{name: "empty string", changes: map[string]interface{}{"network": ""}, want: uuidRecord{}},
+		{name: "slice", changes: map[string]interface{}{"peers": []interface{}{id.String(), other.String()}}, want: uuidRecord{Peers: []uuid.UUID{id, other}}},
+		{name: "invalid", changes: map[string]interface{}{"network": "not-a-uuid"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := uuidRecord{}
+			_, err := ApplyChanges(tt.changes, &record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(record, tt.want) {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+		})
+	}
+}
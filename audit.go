@@ -0,0 +1,97 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// AuditSink receives an AuditEntry for every successful ApplyChangesWrapper
+// (and ApplyChangesAs, ApplyDelete, ...) call, see WithAuditSink. A failure to
+// record is returned from the apply, so callers can refuse to persist unaudited
+// changes.
+type AuditSink interface {
+	Record(ctx context.Context, entry AuditEntry) error
+}
+
+// AuditEntry describes one apply for an audit log
+type AuditEntry struct {
+	// TargetType is the Go type name of the target, e.g. "WeatherReport"
+	TargetType string `json:"targetType"`
+
+	// TargetID is the target's ID field formatted as a string, or empty when it
+	// doesn't have one
+	TargetID string `json:"targetId"`
+
+	Modifier string `json:"modifier"`
+
+	// Principal is the full modifier when applied with ApplyChangesAs
+	Principal *Principal `json:"principal,omitempty"`
+
+	// ActualActor and Reason say who applied the changes on the Modifier's
+	// behalf, and why, see WithImpersonation
+	ActualActor string `json:"actualActor,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+
+	// Timestamp is when the changes were applied, from the configured Clock
+	Timestamp time.Time `json:"timestamp"`
+
+	// Changes are the applied fields with their old and new values, Redacted
+	// for sensitive fields
+	Changes []FieldChange `json:"changes"`
+
+	// BehaviorVersion is the BehaviorVersion the changes were applied under
+	BehaviorVersion string `json:"behaviorVersion"`
+}
+
+// recordAudit hands the entry for a finished apply to the configured sink
+func recordAudit(to interface{}, result ApplyResult, cfg *config) error {
+	if cfg.auditSink == nil || cfg.modifier == nil || cfg.dryRun {
+		return nil
+	}
+
+	entry := newAuditEntry(to, result, cfg)
+	if err := cfg.emit(cfg.ctx, func() error { return cfg.auditSink.Record(cfg.ctx, entry) }); err != nil {
+		return fmt.Errorf("recording audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// newAuditEntry describes a finished apply
+func newAuditEntry(to interface{}, result ApplyResult, cfg *config) AuditEntry {
+	entry := AuditEntry{
+		Principal:       cfg.principal,
+		Timestamp:       cfg.now,
+		Changes:         redactChanges(result.Changes),
+		BehaviorVersion: BehaviorVersion,
+	}
+
+	entry.TargetType = targetTypeName(to)
+	if target, ok := targetStruct(to); ok {
+		if field, ok := structFieldByName(target.Type(), "ID"); ok {
+			entry.TargetID = fmt.Sprint(target.FieldByIndex(field.Index).Interface())
+		}
+	}
+
+	if cfg.modifier != nil {
+		entry.Modifier = *cfg.modifier
+	}
+	if cfg.impersonation != nil {
+		entry.ActualActor = cfg.impersonation.actualActor
+		entry.Reason = cfg.impersonation.reason
+	}
+
+	return entry
+}
+
+// targetTypeName is the Go type name of the target, e.g. "WeatherReport", or
+// the full type of targets that aren't structs
+func targetTypeName(to interface{}) string {
+	if target, ok := targetStruct(to); ok {
+		return target.Type().Name()
+	}
+
+	return reflect.TypeOf(to).String()
+}
@@ -0,0 +1,98 @@
+package applychanges
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithAuditSink(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	id := uuid.MustParse("8a7c3b4e-2f1d-4e5a-9b6c-7d8e9f0a1b2c")
+
+	tests := []struct {
+		name      string
+		apply     func(to *nestedRecord, opts ...Option) (ApplyResult, error)
+		wantPaths []string
+		wantErr   bool
+	}{
+		{
+			name: "wrapper",
+			apply: func(to *nestedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyChangesWrapper(map[string]interface{}{"name": "Tampa", "count": 2}, "EUA1", to, opts...)
+			},
+			wantPaths: []string{"count", "name"},
+		},
+		{
+			name: "delete",
+			apply: func(to *nestedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyDelete("EUA1", to, opts...)
+			},
+			wantPaths: []string{"deletedBy", "deletedDts"},
+		},
+		{
+			name: "no modifier",
+			apply: func(to *nestedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyChanges(map[string]interface{}{"name": "Tampa"}, to, opts...)
+			},
+		},
+		{
+			name: "failed apply",
+			apply: func(to *nestedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyChangesWrapper(map[string]interface{}{"count": "many"}, "EUA1", to, opts...)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			record := nestedRecord{BaseStruct: BaseStruct{ID: id}}
+			if _, err := tt.apply(&record, WithClock(fixedClock(now)), WithAuditSink(sink)); (err != nil) != tt.wantErr {
+				t.Fatalf("apply error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantPaths == nil {
+				if len(sink.entries) != 0 {
+					t.Errorf("audit entries = %+v, want none", sink.entries)
+				}
+				return
+			}
+			if len(sink.entries) != 1 {
+				t.Fatalf("audit entries = %+v, want one", sink.entries)
+			}
+
+			entry := sink.entries[0]
+			if entry.TargetType != "nestedRecord" || entry.TargetID != id.String() || entry.Modifier != "EUA1" ||
+				!entry.Timestamp.Equal(now) || entry.BehaviorVersion != BehaviorVersion {
+				t.Errorf("audit entry = %+v", entry)
+			}
+
+			var paths []string
+			for _, change := range entry.Changes {
+				paths = append(paths, change.Path)
+			}
+			for _, path := range tt.wantPaths {
+				if !containsPath(paths, path) {
+					t.Errorf("audit entry changes = %v, want %s", paths, path)
+				}
+			}
+		})
+	}
+}
+
+func TestWithAuditSinkError(t *testing.T) {
+	failure := errors.New("audit log unavailable")
+	sink := &recordingSink{err: failure}
+
+	record := nestedRecord{Name: "Miami"}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record, WithAuditSink(sink)); !errors.Is(err, failure) {
+		t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, failure)
+	}
+	if record.Name != "Miami" {
+		t.Errorf("Name = %q, want the unaudited change rolled back", record.Name)
+	}
+}
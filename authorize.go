@@ -0,0 +1,156 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldAuthorizer decides whether the principal may change the field at
+// fieldPath (its tag name, e.g. "status" or "details.source" for a nested
+// struct's field), returning an error describing why not, see
+// WithFieldAuthorizer
+type FieldAuthorizer func(ctx context.Context, fieldPath string, principal Principal) error
+
+// ValueAuthorizer decides whether the principal may make a change, given the
+// field's typed value before and after it (after decode hooks and scalars have
+// converted it, so a time.Time field's New is a time.Time rather than the
+// string the changes carried), see WithValueAuthorizerCtx
+type ValueAuthorizer func(ctx context.Context, change FieldChange, principal Principal) error
+
+// ForbiddenFieldsError is returned when the principal isn't permitted to change
+// one or more fields, by WithFieldPermissions, an `apply:"roles=..."` tag or a
+// FieldAuthorizer; it is the equivalent of an HTTP 403 Forbidden
+type ForbiddenFieldsError struct {
+	// Fields are the paths the authorizer rejected, sorted
+	Fields []string
+
+	// Reasons holds why each of the Fields was rejected, e.g. the
+	// FieldAuthorizer's error
+	Reasons map[string]error
+}
+
+func (e *ForbiddenFieldsError) Error() string {
+	return fmt.Sprintf("not authorized to change %s", quoteFields(e.Fields))
+}
+
+// authorizeFields checks every key of the changes against the role
+// permissions and then the FieldAuthorizer, failing with all the fields either
+// rejects. Changes applied without a principal are authorized as one with just
+// the modifier's ID (or no ID at all), see authorizedPrincipal.
+func authorizeFields(changes map[string]interface{}, to interface{}, cfg *config) error {
+	principal := authorizedPrincipal(cfg)
+
+	var structType reflect.Type
+	if target, ok := targetStruct(to); ok {
+		structType = target.Type()
+	}
+
+	// flat structs have no roles tags, so there's nothing to check them against
+	if cfg.fieldAuthorizer == nil && len(cfg.permissions) == 0 && structType != nil && isFlatStruct(structType) {
+		return nil
+	}
+
+	reasons := map[string]error{}
+	authorizePaths(changes, structType, cfg.tagName, "", func(path string, field *reflect.StructField, descending bool) {
+		if err := permitted(path, field, descending, principal.Role, cfg); err != nil {
+			reasons[path] = err
+			return
+		}
+
+		if cfg.fieldAuthorizer != nil {
+			if err := cfg.fieldAuthorizer(cfg.ctx, path, principal); err != nil {
+				reasons[path] = err
+			}
+		}
+	})
+
+	return forbiddenFields(reasons)
+}
+
+// authorizeValues checks every applied change, apart from the stamped
+// metadata, with the ValueAuthorizers, failing with all the fields they reject
+func authorizeValues(changes []FieldChange, cfg *config) error {
+	principal := authorizedPrincipal(cfg)
+
+	reasons := map[string]error{}
+	for _, change := range changes {
+		if isBookkeeping(change.Path) {
+			continue
+		}
+
+		for _, authorizer := range cfg.valueAuthorizers {
+			if err := authorizer(cfg.ctx, change, principal); err != nil {
+				reasons[change.Path] = err
+				break
+			}
+		}
+	}
+
+	return forbiddenFields(reasons)
+}
+
+// authorizedPrincipal is who the apply is authorized as: its principal, or
+// else one with just the modifier's ID (or no ID at all), which has no role
+func authorizedPrincipal(cfg *config) Principal {
+	var principal Principal
+	if cfg.principal != nil {
+		principal = *cfg.principal
+	} else if cfg.modifier != nil {
+		principal.ID = *cfg.modifier
+	}
+
+	return principal
+}
+
+// forbiddenFields returns a ForbiddenFieldsError for the rejected fields, if
+// there are any
+func forbiddenFields(reasons map[string]error) error {
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(reasons))
+	for path := range reasons {
+		fields = append(fields, path)
+	}
+	sort.Strings(fields)
+
+	return &ForbiddenFieldsError{Fields: fields, Reasons: reasons}
+}
+
+// authorizePaths calls authorize with the path of every key and the field it
+// resolves to (nil when it doesn't resolve to one), descending into nested maps
+// bound for struct fields. Keys are named by the tag name of their field, or
+// by themselves when they have none; descending is set for the keys whose
+// nested keys are authorized separately.
+func authorizePaths(changes map[string]interface{}, structType reflect.Type, tagName string, prefix string, authorize func(path string, field *reflect.StructField, descending bool)) {
+	for key, value := range changes {
+		name := key
+		var field *reflect.StructField
+		if structType != nil {
+			if resolved, ok := structFieldByTag(structType, tagName, key); ok {
+				name = fieldKey(resolved, tagName)
+				field = &resolved
+			}
+		}
+
+		nested, ok := value.(map[string]interface{})
+		var nestedType reflect.Type
+		if ok && field != nil {
+			nestedType = field.Type
+			if nestedType.Kind() == reflect.Ptr {
+				nestedType = nestedType.Elem()
+			}
+			if nestedType.Kind() != reflect.Struct || isScalar(nestedType) {
+				nestedType = nil
+			}
+		}
+
+		authorize(prefix+name, field, nestedType != nil)
+		if nestedType != nil {
+			authorizePaths(nested, nestedType, tagName, prefix+name+".", authorize)
+		}
+	}
+}
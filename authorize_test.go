@@ -0,0 +1,309 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type authorizedRecord struct {
+	BaseStruct
+	Status  string         `json:"status"`
+	DueDts  time.Time      `json:"dueDts"`
+	Notes   string         `json:"notes" apply:"roles=admin"`
+	Details *nestedDetails `json:"details"`
+}
+
+type authorizeKey struct{}
+
+func TestWithValueAuthorizer(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	farOut := func(_ context.Context, change FieldChange, principal Principal) error {
+		due, ok := change.New.(time.Time)
+		if change.Path == "dueDts" && (!ok || due.Sub(now) > 30*24*time.Hour) && principal.Role != AdminRole {
+			return errors.New("only admins may set a due date more than 30 days out")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name      string
+		changes   map[string]interface{}
+		principal Principal
+		wantErr   bool
+		forbidden []string
+	}{
+		{
+			name:      "typed value within policy",
+			changes:   map[string]interface{}{"dueDts": "2024-06-10T00:00:00Z", "status": "open"},
+			principal: Principal{ID: "EUA1"},
+		},
+		{
+			name:      "typed value outside policy",
+			changes:   map[string]interface{}{"dueDts": "2024-09-01T00:00:00Z", "status": "open"},
+			principal: Principal{ID: "EUA1"},
+			wantErr:   true,
+			forbidden: []string{"dueDts"},
+		},
+		{
+			name:      "admin outside policy",
+			changes:   map[string]interface{}{"dueDts": "2024-09-01T00:00:00Z"},
+			principal: Principal{ID: "EUA1", Role: AdminRole},
+		},
+		{
+			name:      "conversion errors come first",
+			changes:   map[string]interface{}{"dueDts": "next tuesday"},
+			principal: Principal{ID: "EUA1"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := authorizedRecord{Status: "draft"}
+			var seen []FieldChange
+			recording := func(ctx context.Context, change FieldChange, principal Principal) error {
+				seen = append(seen, change)
+				return farOut(ctx, change, principal)
+			}
+
+			_, err := ApplyChangesAs(tt.changes, tt.principal, &record, WithValueAuthorizerCtx(recording))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChangesAs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var forbidden *ForbiddenFieldsError
+			if errors.As(err, &forbidden) != (tt.forbidden != nil) {
+				t.Fatalf("ApplyChangesAs() error = %v, want ForbiddenFieldsError for %v", err, tt.forbidden)
+			}
+			if forbidden != nil && !reflect.DeepEqual(forbidden.Fields, tt.forbidden) {
+				t.Errorf("forbidden fields = %v, want %v", forbidden.Fields, tt.forbidden)
+			}
+
+			if err != nil {
+				if !reflect.DeepEqual(record, authorizedRecord{Status: "draft"}) {
+					t.Errorf("record = %+v, want it untouched", record)
+				}
+				if forbidden == nil && len(seen) > 0 {
+					t.Errorf("authorizer saw %+v before the conversion error", seen)
+				}
+				return
+			}
+
+			for _, change := range seen {
+				if isBookkeeping(change.Path) {
+					t.Errorf("authorizer saw stamped field %q", change.Path)
+				}
+				if change.Path == "dueDts" {
+					if _, ok := change.New.(time.Time); !ok {
+						t.Errorf("dueDts New = %T, want time.Time", change.New)
+					}
+					if _, ok := change.Old.(time.Time); !ok {
+						t.Errorf("dueDts Old = %T, want time.Time", change.Old)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWithValueAuthorizerContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), authorizeKey{}, "policy")
+
+	var got interface{}
+	authorizer := func(ctx context.Context, _ FieldChange, _ Principal) error {
+		got = ctx.Value(authorizeKey{})
+		return nil
+	}
+
+	record := authorizedRecord{}
+	if _, err := ApplyChangesWrapperCtx(ctx, map[string]interface{}{"status": "open"}, "EUA1", &record, WithValueAuthorizerCtx(authorizer)); err != nil {
+		t.Fatalf("ApplyChangesWrapperCtx() error = %v", err)
+	}
+	if got != "policy" {
+		t.Errorf("authorizer context value = %v, want the apply's context", got)
+	}
+}
+
+func TestWithValueAuthorizerNested(t *testing.T) {
+	record := authorizedRecord{Details: &nestedDetails{Source: "radar", Level: 1}}
+	authorizer := func(change FieldChange, _ Principal) error {
+		if details, ok := change.New.(nestedDetails); ok && details.Level > 3 {
+			return errors.New("level too high")
+		}
+		return nil
+	}
+
+	_, err := ApplyChanges(map[string]interface{}{"details": map[string]interface{}{"level": 5}}, &record, WithValueAuthorizer(authorizer))
+
+	var forbidden *ForbiddenFieldsError
+	if !errors.As(err, &forbidden) || !reflect.DeepEqual(forbidden.Fields, []string{"details"}) {
+		t.Fatalf("ApplyChanges() error = %v, want details forbidden", err)
+	}
+	if *record.Details != (nestedDetails{Source: "radar", Level: 1}) {
+		t.Errorf("Details = %+v, want it restored", record.Details)
+	}
+}
+
+func TestWithValueAuthorizerBeforeAssignment(t *testing.T) {
+	record := authorizedRecord{Status: "draft", Details: &nestedDetails{Source: "radar"}}
+	details := record.Details
+
+	var status string
+	authorizer := func(change FieldChange, _ Principal) error {
+		status = record.Status
+		return nil
+	}
+
+	changes := map[string]interface{}{"status": "open", "details": map[string]interface{}{"level": 2}}
+	if _, err := ApplyChanges(changes, &record, WithValueAuthorizer(authorizer)); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	if status != "draft" {
+		t.Errorf("authorizer saw Status = %q on the target, want it not yet assigned", status)
+	}
+	if record.Status != "open" || record.Details != details || *details != (nestedDetails{Source: "radar", Level: 2}) {
+		t.Errorf("record = %+v, want the changes assigned to the same Details", record)
+	}
+}
+
+func TestFieldAuthorizer(t *testing.T) {
+	tests := []struct {
+		name      string
+		changes   map[string]interface{}
+		principal Principal
+		forbidden []string
+	}{
+		{
+			name:      "roles tag",
+			changes:   map[string]interface{}{"notes": "x", "status": "open"},
+			principal: Principal{ID: "EUA1", Role: "editor"},
+			forbidden: []string{"notes"},
+		},
+		{
+			name:      "roles tag satisfied",
+			changes:   map[string]interface{}{"notes": "x"},
+			principal: Principal{ID: "EUA1", Role: "admin"},
+		},
+		{
+			name:      "authorizer rejects nested path",
+			changes:   map[string]interface{}{"details": map[string]interface{}{"source": "x"}},
+			principal: Principal{ID: "EUA1", Role: "admin"},
+			forbidden: []string{"details.source"},
+		},
+	}
+
+	authorizer := func(_ context.Context, path string, _ Principal) error {
+		if path == "details.source" {
+			return errors.New("read only")
+		}
+		return nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := authorizedRecord{}
+			_, err := ApplyChangesAs(tt.changes, tt.principal, &record, WithFieldAuthorizer(authorizer))
+
+			var forbidden *ForbiddenFieldsError
+			if errors.As(err, &forbidden) {
+				if !reflect.DeepEqual(forbidden.Fields, tt.forbidden) {
+					t.Errorf("forbidden fields = %v, want %v", forbidden.Fields, tt.forbidden)
+				}
+			} else if err != nil || tt.forbidden != nil {
+				t.Errorf("ApplyChangesAs() error = %v, want %v forbidden", err, tt.forbidden)
+			}
+		})
+	}
+}
+
+func TestWithFieldAuthorizer(t *testing.T) {
+	ctx := context.WithValue(context.Background(), authorizeKey{}, "request")
+
+	tests := []struct {
+		name      string
+		apply     func(changes map[string]interface{}, to *authorizedRecord, opts ...Option) (ApplyResult, error)
+		changes   map[string]interface{}
+		wantSeen  []string
+		forbidden []string
+	}{
+		{
+			name: "every key and nested key by tag name, as the principal",
+			apply: func(changes map[string]interface{}, to *authorizedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyChangesAsCtx(ctx, changes, Principal{ID: "EUA1", Role: "admin"}, to, append(opts, WithCaseInsensitiveKeys())...)
+			},
+			changes:  map[string]interface{}{"Status": "open", "details": map[string]interface{}{"level": 2}},
+			wantSeen: []string{"EUA1 admin details", "EUA1 admin details.level", "EUA1 admin status"},
+		},
+		{
+			name: "all rejected fields, sorted",
+			apply: func(changes map[string]interface{}, to *authorizedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyChangesAsCtx(ctx, changes, Principal{ID: "EUA1", Role: "viewer"}, to, opts...)
+			},
+			changes:   map[string]interface{}{"status": "open", "dueDts": "2024-06-01T00:00:00Z"},
+			wantSeen:  []string{"EUA1 viewer dueDts", "EUA1 viewer status"},
+			forbidden: []string{"dueDts", "status"},
+		},
+		{
+			name: "wrapper authorizes the modifier",
+			apply: func(changes map[string]interface{}, to *authorizedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyChangesWrapperCtx(ctx, changes, "EUA2", to, opts...)
+			},
+			changes:  map[string]interface{}{"status": "open"},
+			wantSeen: []string{"EUA2  status"},
+		},
+		{
+			name: "no modifier",
+			apply: func(changes map[string]interface{}, to *authorizedRecord, opts ...Option) (ApplyResult, error) {
+				return ApplyChanges(changes, to, append(opts, WithContext(ctx))...)
+			},
+			changes:  map[string]interface{}{"status": "open"},
+			wantSeen: []string{"  status"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []string
+			authorizer := func(ctx context.Context, path string, principal Principal) error {
+				if ctx.Value(authorizeKey{}) != "request" {
+					t.Errorf("authorizer context = %v, want the apply's", ctx)
+				}
+				seen = append(seen, principal.ID+" "+principal.Role+" "+path)
+				if principal.Role == "viewer" {
+					return errors.New("read only")
+				}
+				return nil
+			}
+
+			record := authorizedRecord{}
+			_, err := tt.apply(tt.changes, &record, WithFieldAuthorizer(authorizer))
+
+			var forbidden *ForbiddenFieldsError
+			if tt.forbidden != nil {
+				if !errors.As(err, &forbidden) || !reflect.DeepEqual(forbidden.Fields, tt.forbidden) {
+					t.Fatalf("apply error = %v, want %v forbidden", err, tt.forbidden)
+				}
+				for _, path := range tt.forbidden {
+					if forbidden.Reasons[path] == nil || forbidden.Reasons[path].Error() != "read only" {
+						t.Errorf("Reasons[%s] = %v, want the authorizer's error", path, forbidden.Reasons[path])
+					}
+				}
+				if record.Status != "" {
+					t.Errorf("record = %+v, want it untouched", record)
+				}
+			} else if err != nil {
+				t.Fatalf("apply error = %v", err)
+			}
+
+			sort.Strings(seen)
+			if !reflect.DeepEqual(seen, tt.wantSeen) {
+				t.Errorf("authorizer saw %q, want %q", seen, tt.wantSeen)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Entity is a target of BackfillMetadata: a pointer to a struct with a
+// ModifiedDts field (a time.Time or *time.Time), and usually a ModifiedBy
+// field, like BaseStruct's
+type Entity = interface{}
+
+// BackfillReport counts what BackfillMetadata did with the entities
+type BackfillReport struct {
+	// Updated entities had their missing metadata filled in (or would have,
+	// for a dry run)
+	Updated int
+
+	// Skipped entities weren't missing anything
+	Skipped int
+
+	// Errored entities are those in Errors
+	Errored int
+
+	// Errors are why the errored entities weren't filled in, by their index
+	Errors map[int]error
+
+	// DryRun is set when the entities were left alone, WithDryRun
+	DryRun bool
+}
+
+// BackfillMetadata fills in the ModifiedDts of every entity that doesn't have
+// one (it's nil or zero) with the time the fallback returns for it, e.g. its
+// CreatedDts, and WithBackfillModifiedBy its missing ModifiedBy with the
+// fallback's modifier. It goes through the same path (with the options) as
+// ApplyChangesWrapper, so validators, audit sinks and the like see the
+// backfill as an apply by the entity's ModifiedBy, and WithDryRun previews it;
+// but only the missing metadata is stamped, the rest of the entity (its
+// RecentModifiers included) is left alone. An entity failing (its fallback or
+// its apply) doesn't stop the others; the failures are in the report and
+// returned together as a *BatchError.
+func BackfillMetadata(entities []Entity, fallback func(e Entity) (time.Time, string, error), opts ...Option) (BackfillReport, error) {
+	cfg := newConfig(opts)
+
+	report := BackfillReport{Errors: map[int]error{}, DryRun: cfg.dryRun}
+	for i, entity := range entities {
+		updated, err := backfillEntity(entity, fallback, opts)
+		switch {
+		case err != nil:
+			report.Errored++
+			report.Errors[i] = err
+		case updated:
+			report.Updated++
+		default:
+			report.Skipped++
+		}
+	}
+
+	if report.Errored > 0 {
+		return report, &BatchError{Errors: report.Errors}
+	}
+
+	return report, nil
+}
+
+// backfillEntity fills in the entity's missing metadata, reporting whether it
+// was missing any
+func backfillEntity(entity Entity, fallback func(e Entity) (time.Time, string, error), opts []Option) (bool, error) {
+	cfg := configFor(reflect.TypeOf(entity), opts)
+
+	target, ok := targetStruct(entity)
+	if !ok || !target.CanSet() {
+		return false, fmt.Errorf("%T is not a pointer to a struct", entity)
+	}
+
+	dtsKey, ok := modifiedDtsKey(entity, cfg)
+	if !ok {
+		return false, fmt.Errorf("%T has no ModifiedDts to backfill", entity)
+	}
+	dtsField, _ := structFieldByName(target.Type(), "ModifiedDts")
+	dts := target.FieldByIndex(dtsField.Index)
+
+	modifier, missingBy := "", false
+	if byField, ok := structFieldByName(target.Type(), "ModifiedBy"); ok {
+		by := reflect.Indirect(target.FieldByIndex(byField.Index))
+		if by.Kind() == reflect.String {
+			modifier = by.String()
+		}
+		missingBy = modifier == "" && cfg.backfillModifiedBy
+	}
+
+	if !dts.IsZero() && !missingBy {
+		return false, nil
+	}
+
+	fallbackDts, fallbackModifier, err := fallback(entity)
+	if err != nil {
+		return false, fmt.Errorf("fallback: %w", err)
+	}
+
+	// the ModifiedDts is stamped either way, so one it has is supplied as it is
+	changes := map[string]interface{}{}
+	if dts.IsZero() {
+		if fallbackDts.IsZero() {
+			return false, errors.New("fallback: no ModifiedDts")
+		}
+		changes[dtsKey] = fallbackDts
+	} else {
+		changes[dtsKey] = reflect.Indirect(dts).Interface()
+	}
+	if missingBy {
+		if fallbackModifier == "" {
+			return false, errors.New("fallback: no ModifiedBy")
+		}
+		modifier = fallbackModifier
+	}
+
+	cfg.modifier = &modifier
+	cfg.backfilling = true
+	cfg.modifiedDtsPolicy = HonorModifiedDts
+	if _, err := applyChanges(changes, entity, cfg); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
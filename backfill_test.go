@@ -0,0 +1,163 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type backfilledRecord struct {
+	BaseStruct
+	Title string `json:"title"`
+}
+
+func newBackfilledRecord(title string, modifiedBy *string, modifiedDts *time.Time) *backfilledRecord {
+	record := &backfilledRecord{BaseStruct: NewBaseStruct("EUA0"), Title: title}
+	record.CreatedDts = time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)
+	record.ModifiedBy = modifiedBy
+	record.ModifiedDts = modifiedDts
+	return record
+}
+
+// createdFallback backfills from the record's creation, and fails for the
+// record titled "broken"
+func createdFallback(e Entity) (time.Time, string, error) {
+	record := e.(*backfilledRecord)
+	if record.Title == "broken" {
+		return time.Time{}, "", errors.New("no creation data")
+	}
+	return record.CreatedDts, record.CreatedBy, nil
+}
+
+func TestBackfillMetadata(t *testing.T) {
+	modified := time.Date(2021, 7, 4, 12, 0, 0, 0, time.UTC)
+	created := time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantReport  BackfillReport
+		wantBy      []*string
+		wantDts     []*time.Time
+		wantErrs    []int
+		wantAudited int
+	}{
+		{
+			name:        "mixed batch",
+			wantReport:  BackfillReport{Updated: 2, Skipped: 2, Errored: 1},
+			wantBy:      []*string{stringPtr("EUA1"), stringPtr("EUA2"), nil, stringPtr(""), nil},
+			wantDts:     []*time.Time{&modified, &created, &created, &modified, nil},
+			wantErrs:    []int{4},
+			wantAudited: 2,
+		},
+		{
+			name:        "modifiers too",
+			opts:        []Option{WithBackfillModifiedBy()},
+			wantReport:  BackfillReport{Updated: 3, Skipped: 1, Errored: 1},
+			wantBy:      []*string{stringPtr("EUA1"), stringPtr("EUA2"), stringPtr("EUA0"), stringPtr("EUA0"), nil},
+			wantDts:     []*time.Time{&modified, &created, &created, &modified, nil},
+			wantErrs:    []int{4},
+			wantAudited: 3,
+		},
+		{
+			name:        "dry run",
+			opts:        []Option{WithDryRun(), WithBackfillModifiedBy()},
+			wantReport:  BackfillReport{Updated: 3, Skipped: 1, Errored: 1, DryRun: true},
+			wantBy:      []*string{stringPtr("EUA1"), stringPtr("EUA2"), nil, stringPtr(""), nil},
+			wantDts:     []*time.Time{&modified, nil, nil, &modified, nil},
+			wantErrs:    []int{4},
+			wantAudited: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := []*backfilledRecord{
+				newBackfilledRecord("complete", stringPtr("EUA1"), &modified),
+				newBackfilledRecord("no time", stringPtr("EUA2"), nil),
+				newBackfilledRecord("nothing", nil, nil),
+				newBackfilledRecord("no modifier", stringPtr(""), &modified),
+				newBackfilledRecord("broken", nil, nil),
+			}
+			entities := make([]Entity, len(records))
+			for i, record := range records {
+				entities[i] = record
+			}
+
+			sink := &recordingSink{}
+			report, err := BackfillMetadata(entities, createdFallback, append(tt.opts, WithAuditSink(sink))...)
+
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("BackfillMetadata() error = %v, want a *BatchError", err)
+			}
+			var failed []int
+			for i := range batchErr.Errors {
+				failed = append(failed, i)
+			}
+			if !reflect.DeepEqual(failed, tt.wantErrs) {
+				t.Errorf("failed entities = %v, want %v", failed, tt.wantErrs)
+			}
+
+			errs := report.Errors
+			report.Errors = nil
+			if !reflect.DeepEqual(report, tt.wantReport) || len(errs) != report.Errored {
+				t.Errorf("BackfillMetadata() = %+v with %d errors, want %+v", report, len(errs), tt.wantReport)
+			}
+
+			for i, record := range records {
+				if !reflect.DeepEqual(record.ModifiedBy, tt.wantBy[i]) {
+					t.Errorf("%s: ModifiedBy = %v, want %v", record.Title, record.ModifiedBy, tt.wantBy[i])
+				}
+				if !reflect.DeepEqual(record.ModifiedDts, tt.wantDts[i]) {
+					t.Errorf("%s: ModifiedDts = %v, want %v", record.Title, record.ModifiedDts, tt.wantDts[i])
+				}
+				if record.CreatedDts != created {
+					t.Errorf("%s: CreatedDts = %v, want it untouched", record.Title, record.CreatedDts)
+				}
+			}
+
+			if len(sink.entries) != tt.wantAudited {
+				t.Errorf("audited %d entities, want %d", len(sink.entries), tt.wantAudited)
+			}
+		})
+	}
+}
+
+func TestBackfillMetadataValidates(t *testing.T) {
+	record := newBackfilledRecord("old", stringPtr("EUA1"), nil)
+	validator := ValidatorFunc(func(target interface{}) error {
+		if target.(*backfilledRecord).ModifiedDts.Year() < 2020 {
+			return errors.New("too old")
+		}
+		return nil
+	})
+
+	report, err := BackfillMetadata([]Entity{record}, createdFallback, WithValidator(validator))
+	if err == nil || report.Errored != 1 {
+		t.Fatalf("BackfillMetadata() = %+v, %v, want the validator's error", report, err)
+	}
+	if record.ModifiedDts != nil {
+		t.Errorf("ModifiedDts = %v, want the failed backfill undone", record.ModifiedDts)
+	}
+}
+
+func TestBackfillMetadataRejectsTargets(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity Entity
+	}{
+		{name: "not a pointer", entity: backfilledRecord{}},
+		{name: "no ModifiedDts", entity: &struct{ Title string }{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := BackfillMetadata([]Entity{tt.entity}, createdFallback)
+			if err == nil || report.Errored != 1 {
+				t.Errorf("BackfillMetadata() = %+v, %v, want the entity rejected", report, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package applychanges
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BaseStruct holds the metadata shared by every entity; embed it in a struct
+// to have ApplyChangesWrapper keep it up to date. The creation fields are
+// immutable: no changes can set them, not even those creating the entity, so
+// they're filled in when it's constructed (see NewBaseStruct). The deletion
+// fields are only set by ApplyDelete.
+//
+// taken from https://github.com/CMSgov/easi-app/pull/1760
+type BaseStruct struct {
+	ID          uuid.UUID  `json:"id" db:"id" apply:"immutable"`
+	CreatedBy   string     `json:"createdBy" db:"created_by" apply:"immutable"`
+	CreatedDts  time.Time  `json:"createdDts" db:"created_dts" apply:"immutable"`
+	ModifiedBy  *string    `json:"modifiedBy" db:"modified_by"`
+	ModifiedDts *time.Time `json:"modifiedDts" db:"modified_dts"`
+	DeletedBy   *string    `json:"deletedBy" db:"deleted_by" apply:"immutable"`
+	DeletedDts  *time.Time `json:"deletedDts" db:"deleted_dts" apply:"immutable"`
+}
+
+// NewBaseStruct returns the metadata of a new entity created by createdBy,
+// with a fresh random ID and the current time as its CreatedDts
+func NewBaseStruct(createdBy string) BaseStruct {
+	return NewBaseStructWithClock(createdBy, systemClock{})
+}
+
+// NewBaseStructWithClock is NewBaseStruct stamping CreatedDts from the clock,
+// e.g. the one given to WithClock
+func NewBaseStructWithClock(createdBy string, clock Clock) BaseStruct {
+	return BaseStruct{
+		ID:         uuid.New(),
+		CreatedBy:  createdBy,
+		CreatedDts: clock.Now().UTC(),
+	}
+}
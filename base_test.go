@@ -0,0 +1,35 @@
+package applychanges
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewBaseStruct(t *testing.T) {
+	before := time.Now().UTC()
+	base := NewBaseStruct("EUA1")
+
+	if base.CreatedBy != "EUA1" {
+		t.Errorf("CreatedBy = %q, want EUA1", base.CreatedBy)
+	}
+	if base.ID == uuid.Nil || base.ID == NewBaseStruct("EUA1").ID {
+		t.Errorf("ID = %s, want a fresh random ID", base.ID)
+	}
+	if base.CreatedDts.Before(before) || base.CreatedDts.Location() != time.UTC {
+		t.Errorf("CreatedDts = %v, want the current UTC time", base.CreatedDts)
+	}
+	if base.ModifiedBy != nil || base.ModifiedDts != nil || base.DeletedBy != nil || base.DeletedDts != nil {
+		t.Errorf("base = %+v, want no modification or deletion metadata", base)
+	}
+}
+
+func TestNewBaseStructWithClock(t *testing.T) {
+	now := time.Date(2022, 9, 1, 8, 0, 0, 0, time.FixedZone("EDT", -4*60*60))
+	base := NewBaseStructWithClock("EUA1", fixedClock(now))
+
+	if !base.CreatedDts.Equal(now) || base.CreatedDts.Location() != time.UTC {
+		t.Errorf("CreatedDts = %v, want %v in UTC", base.CreatedDts, now)
+	}
+}
@@ -0,0 +1,90 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BatchResult is the outcome of applying changes to one target of a batch
+type BatchResult struct {
+	Result ApplyResult
+
+	// Err is why the target's apply failed, or nil
+	Err error
+}
+
+// BatchError lists the targets of a batch whose apply failed, by their index
+// in the batch
+type BatchError struct {
+	Errors map[int]error
+}
+
+func (e *BatchError) Error() string {
+	indexes := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	messages := make([]string, len(indexes))
+	for i, index := range indexes {
+		messages[i] = fmt.Sprintf("%d: %v", index, e.Errors[index])
+	}
+
+	return fmt.Sprintf("%d of the batch failed to apply: %s", len(indexes), strings.Join(messages, "; "))
+}
+
+// ApplyToAll applies the same changes to every target with ApplyChangesWrapper,
+// each from its own copy of the changes; see ApplyBatch.
+func ApplyToAll(changes map[string]interface{}, modifier string, targets []interface{}, opts ...Option) ([]BatchResult, error) {
+	items := make([]BatchItem, len(targets))
+	for i, target := range targets {
+		items[i] = BatchItem{Target: target, Changes: copyChanges(changes), Modifier: modifier}
+	}
+
+	return ApplyBatch(items, opts...)
+}
+
+// BatchItem is one target of ApplyBatch with its own changes and modifier
+type BatchItem struct {
+	Target   interface{}
+	Changes  map[string]interface{}
+	Modifier string
+}
+
+// ErrBatchStopped is the error of the items a batch didn't get to, because an
+// earlier one failed with WithStopOnError
+var ErrBatchStopped = errors.New("not applied: an earlier item of the batch failed")
+
+// ApplyBatch applies each item's changes to its target with ApplyChangesWrapper
+// and returns every item's result in order. By default one item failing doesn't
+// stop the others; with WithStopOnError the remaining items are left alone
+// (their Err is ErrBatchStopped). The failures are also returned together as a
+// *BatchError.
+func ApplyBatch(items []BatchItem, opts ...Option) ([]BatchResult, error) {
+	cfg := newConfig(opts)
+
+	results := make([]BatchResult, len(items))
+	failures := map[int]error{}
+
+	for i, item := range items {
+		if cfg.stopOnError && len(failures) > 0 {
+			results[i] = BatchResult{Err: ErrBatchStopped}
+			continue
+		}
+
+		result, err := ApplyChangesWrapper(item.Changes, item.Modifier, item.Target, opts...)
+		results[i] = BatchResult{Result: result, Err: err}
+		if err != nil {
+			failures[i] = err
+		}
+	}
+
+	if len(failures) > 0 {
+		return results, &BatchError{Errors: failures}
+	}
+
+	return results, nil
+}
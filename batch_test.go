@@ -0,0 +1,161 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyToAll(t *testing.T) {
+	tests := []struct {
+		name       string
+		changes    map[string]interface{}
+		targets    []interface{}
+		wantNames  []string
+		wantFailed []int
+	}{
+		{
+			name:      "every target",
+			changes:   map[string]interface{}{"name": "Tampa", "details": map[string]interface{}{"level": 2}},
+			targets:   []interface{}{&nestedRecord{Name: "Miami"}, &nestedRecord{Name: "Orlando"}},
+			wantNames: []string{"Tampa", "Tampa"},
+		},
+		{
+			name:       "a failing target doesn't stop the others",
+			changes:    map[string]interface{}{"name": "Tampa"},
+			targets:    []interface{}{&nestedRecord{Name: "Miami"}, nestedRecord{Name: "Orlando"}, &nestedRecord{Name: "Naples"}},
+			wantNames:  []string{"Tampa", "Orlando", "Tampa"},
+			wantFailed: []int{1},
+		},
+		{
+			name:    "no targets",
+			changes: map[string]interface{}{"name": "Tampa"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := copyChanges(tt.changes)
+			results, err := ApplyToAll(tt.changes, "EUA1", tt.targets)
+
+			if len(results) != len(tt.targets) {
+				t.Fatalf("ApplyToAll() = %d results, want %d", len(results), len(tt.targets))
+			}
+			if !reflect.DeepEqual(tt.changes, original) {
+				t.Errorf("changes = %v, want them left as they were", tt.changes)
+			}
+
+			var failed []int
+			for i, target := range tt.targets {
+				name := reflect.Indirect(reflect.ValueOf(target)).FieldByName("Name").String()
+				if name != tt.wantNames[i] {
+					t.Errorf("target %d Name = %q, want %q", i, name, tt.wantNames[i])
+				}
+				if results[i].Err != nil {
+					failed = append(failed, i)
+				}
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("failed items = %v, want %v", failed, tt.wantFailed)
+			}
+
+			var batchErr *BatchError
+			if tt.wantFailed == nil {
+				if err != nil {
+					t.Errorf("ApplyToAll() error = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &batchErr) || len(batchErr.Errors) != len(tt.wantFailed) {
+				t.Errorf("ApplyToAll() error = %v, want a BatchError for %v", err, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestApplyToAllDetailsNotShared(t *testing.T) {
+	first, second := &nestedRecord{}, &nestedRecord{}
+	changes := map[string]interface{}{"details": map[string]interface{}{"source": "radar"}}
+	if _, err := ApplyToAll(changes, "EUA1", []interface{}{first, second}); err != nil {
+		t.Fatalf("ApplyToAll() error = %v", err)
+	}
+
+	if first.Details == nil || first.Details == second.Details {
+		t.Errorf("Details = %p and %p, want one each", first.Details, second.Details)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	err := &BatchError{Errors: map[int]error{3: errors.New("c"), 0: errors.New("a")}}
+	if want := "2 of the batch failed to apply: 0: a; 3: c"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	newItems := func() []BatchItem {
+		return []BatchItem{
+			{Target: &nestedRecord{}, Changes: map[string]interface{}{"name": "Tampa"}, Modifier: "EUA1"},
+			{Target: &nestedRecord{}, Changes: map[string]interface{}{"count": "many"}, Modifier: "EUA2"},
+			{Target: &nestedRecord{}, Changes: map[string]interface{}{"count": 3}, Modifier: "EUA3"},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		wantApplied  []bool
+		wantStopped  []int
+		wantFailures []int
+	}{
+		{
+			name:         "keeps going",
+			wantApplied:  []bool{true, false, true},
+			wantFailures: []int{1},
+		},
+		{
+			name:         "stops on error",
+			opts:         []Option{WithStopOnError()},
+			wantApplied:  []bool{true, false, false},
+			wantStopped:  []int{2},
+			wantFailures: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := newItems()
+			results, err := ApplyBatch(items, tt.opts...)
+
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("ApplyBatch() error = %v, want a BatchError", err)
+			}
+			var failures []int
+			for index := range batchErr.Errors {
+				failures = append(failures, index)
+			}
+			if !reflect.DeepEqual(failures, tt.wantFailures) {
+				t.Errorf("BatchError indexes = %v, want %v", failures, tt.wantFailures)
+			}
+
+			var stopped []int
+			for i, item := range items {
+				record := item.Target.(*nestedRecord)
+				applied := record.ModifiedBy != nil
+				if applied != tt.wantApplied[i] {
+					t.Errorf("item %d applied = %v, want %v", i, applied, tt.wantApplied[i])
+				}
+				if applied && *record.ModifiedBy != item.Modifier {
+					t.Errorf("item %d ModifiedBy = %q, want its own modifier %q", i, *record.ModifiedBy, item.Modifier)
+				}
+				if errors.Is(results[i].Err, ErrBatchStopped) {
+					stopped = append(stopped, i)
+				}
+			}
+			if !reflect.DeepEqual(stopped, tt.wantStopped) {
+				t.Errorf("stopped items = %v, want %v", stopped, tt.wantStopped)
+			}
+		})
+	}
+}
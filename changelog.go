@@ -0,0 +1,159 @@
+package applychanges
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChangeLogRow is one changed field of one apply, as stored in a generic
+// changes_log table:
+//
+//	CREATE TABLE changes_log (
+//		target_type  TEXT NOT NULL,
+//		target_id    TEXT NOT NULL,
+//		field        TEXT NOT NULL,
+//		old          TEXT NOT NULL,
+//		new          TEXT NOT NULL,
+//		modified_by  TEXT NOT NULL,
+//		modified_dts TIMESTAMPTZ NOT NULL
+//	);
+type ChangeLogRow struct {
+	TargetType string `json:"targetType" db:"target_type"`
+	TargetID   string `json:"targetId" db:"target_id"`
+	Field      string `json:"field" db:"field"`
+
+	// Old and New are the field's values encoded as JSON (Redacted for
+	// sensitive fields)
+	Old string `json:"old" db:"old"`
+	New string `json:"new" db:"new"`
+
+	ModifiedBy  string    `json:"modifiedBy" db:"modified_by"`
+	ModifiedDts time.Time `json:"modifiedDts" db:"modified_dts"`
+}
+
+// ChangeLogWriter stores the rows of one apply, e.g. in a single transaction
+type ChangeLogWriter interface {
+	WriteChanges(ctx context.Context, rows []ChangeLogRow) error
+}
+
+// ChangeRecorder is an AuditSink turning every apply into ChangeLogRows for its
+// writer:
+//
+//	applychanges.WithAuditSink(applychanges.NewChangeRecorder(
+//		applychanges.NewSQLChangeLogWriter(db, "changes_log"),
+//	))
+type ChangeRecorder struct {
+	writer ChangeLogWriter
+}
+
+// NewChangeRecorder returns a ChangeRecorder writing to the writer
+func NewChangeRecorder(writer ChangeLogWriter) *ChangeRecorder {
+	return &ChangeRecorder{writer: writer}
+}
+
+// Record writes the entry's rows, if it changed anything
+func (r *ChangeRecorder) Record(ctx context.Context, entry AuditEntry) error {
+	rows, err := ChangeRows(entry)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	return r.writer.WriteChanges(ctx, rows)
+}
+
+// bookkeepingFields are the metadata fields (by Go name) stamped by every apply,
+// which the change log records as columns rather than rows
+var bookkeepingFields = []string{"ModifiedBy", "ModifiedDts", recentModifiersField, modifiedByPrincipalField, lockVersionField}
+
+// ChangeRows converts an audit entry into a row per field whose value actually
+// changed (floats compared bit for bit), leaving out the metadata stamped by
+// every apply. Sensitive fields always get a row, since their redacted values
+// can't tell.
+func ChangeRows(entry AuditEntry) ([]ChangeLogRow, error) {
+	var rows []ChangeLogRow
+	for _, change := range entry.Changes {
+		if isBookkeeping(change.Path) || (!change.Sensitive && sameValue(change.Old, change.New)) {
+			continue
+		}
+
+		change = change.rendered()
+		oldValue, err := json.Marshal(change.Old)
+		if err != nil {
+			return nil, fmt.Errorf("encoding '%s': %w", change.Path, err)
+		}
+		newValue, err := json.Marshal(change.New)
+		if err != nil {
+			return nil, fmt.Errorf("encoding '%s': %w", change.Path, err)
+		}
+
+		rows = append(rows, ChangeLogRow{
+			TargetType:  entry.TargetType,
+			TargetID:    entry.TargetID,
+			Field:       change.Path,
+			Old:         string(oldValue),
+			New:         string(newValue),
+			ModifiedBy:  entry.Modifier,
+			ModifiedDts: entry.Timestamp,
+		})
+	}
+
+	return rows, nil
+}
+
+func isBookkeeping(path string) bool {
+	for _, name := range bookkeepingFields {
+		if sameWords(path, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Execer is the part of *sql.DB (or *sql.Tx) SQLChangeLogWriter needs
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// SQLChangeLogWriter inserts the rows into a changes_log table (see
+// ChangeLogRow) with a single statement using $n placeholders
+type SQLChangeLogWriter struct {
+	db        Execer
+	tableName string
+}
+
+// NewSQLChangeLogWriter returns a SQLChangeLogWriter inserting into tableName
+func NewSQLChangeLogWriter(db Execer, tableName string) *SQLChangeLogWriter {
+	return &SQLChangeLogWriter{db: db, tableName: tableName}
+}
+
+func (w *SQLChangeLogWriter) WriteChanges(ctx context.Context, rows []ChangeLogRow) error {
+	const columns = 7
+
+	values := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*columns)
+	for i, row := range rows {
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = fmt.Sprintf("$%d", i*columns+j+1)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+
+		args = append(args, row.TargetType, row.TargetID, row.Field, row.Old, row.New, row.ModifiedBy, row.ModifiedDts)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (target_type, target_id, field, old, new, modified_by, modified_dts) VALUES %s",
+		w.tableName, strings.Join(values, ", "),
+	)
+
+	if _, err := w.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("writing change log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package applychanges
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingExecer collects the statements it is handed
+type recordingExecer struct {
+	query string
+	args  []interface{}
+	err   error
+}
+
+func (e *recordingExecer) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.query, e.args = query, args
+	return nil, e.err
+}
+
+// recordingWriter collects the rows it is handed
+type recordingWriter struct {
+	writes [][]ChangeLogRow
+}
+
+func (w *recordingWriter) WriteChanges(_ context.Context, rows []ChangeLogRow) error {
+	w.writes = append(w.writes, rows)
+	return nil
+}
+
+func TestChangeRows(t *testing.T) {
+	timestamp := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	row := func(field, old, new string) ChangeLogRow {
+		return ChangeLogRow{TargetType: "WeatherReport", TargetID: "42", Field: field, Old: old, New: new, ModifiedBy: "EUA1", ModifiedDts: timestamp}
+	}
+
+	tests := []struct {
+		name    string
+		changes []FieldChange
+		want    []ChangeLogRow
+		wantErr bool
+	}{
+		{
+			name:    "changed fields as JSON",
+			changes: []FieldChange{{Path: "name", Old: "Miami", New: "Tampa"}, {Path: "level", Old: nil, New: 2}},
+			want:    []ChangeLogRow{row("name", `"Miami"`, `"Tampa"`), row("level", "null", "2")},
+		},
+		{
+			name: "bookkeeping left out",
+			changes: []FieldChange{
+				{Path: "modifiedBy", Old: "EUA0", New: "EUA1"},
+				{Path: "ModifiedDts", Old: nil, New: timestamp},
+				{Path: "lock_version", Old: 1, New: 2},
+				{Path: "name", Old: "Miami", New: "Tampa"},
+			},
+			want: []ChangeLogRow{row("name", `"Miami"`, `"Tampa"`)},
+		},
+		{
+			name:    "unchanged fields left out",
+			changes: []FieldChange{{Path: "name", Old: "Miami", New: "Miami"}, {Path: "speed", Old: math.NaN(), New: math.NaN()}},
+		},
+		{
+			name:    "sensitive fields always recorded",
+			changes: []FieldChange{{Path: "ssn", Old: "same", New: "same", Sensitive: true}},
+			want:    []ChangeLogRow{row("ssn", `"[REDACTED]"`, `"[REDACTED]"`)},
+		},
+		{
+			name:    "dates",
+			changes: []FieldChange{{Path: "dueDts", Old: nil, New: timestamp, DateOnly: true}},
+			want:    []ChangeLogRow{row("dueDts", "null", `"2024-06-01"`)},
+		},
+		{
+			name:    "unencodable value",
+			changes: []FieldChange{{Path: "events", Old: nil, New: make(chan int)}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := AuditEntry{TargetType: "WeatherReport", TargetID: "42", Modifier: "EUA1", Timestamp: timestamp, Changes: tt.changes}
+			got, err := ChangeRows(entry)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ChangeRows() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChangeRows() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChangeRows() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeRecorder(t *testing.T) {
+	writer := &recordingWriter{}
+	record := nestedRecord{Name: "Miami"}
+	opts := []Option{WithAuditSink(NewChangeRecorder(writer))}
+
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record, opts...); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record, opts...); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	if len(writer.writes) != 1 {
+		t.Fatalf("wrote %d times, want once for the apply that changed something", len(writer.writes))
+	}
+	rows := writer.writes[0]
+	if len(rows) != 1 || rows[0].Field != "name" || rows[0].Old != `"Miami"` || rows[0].New != `"Tampa"` || rows[0].ModifiedBy != "EUA1" {
+		t.Errorf("rows = %+v, want the name change by EUA1", rows)
+	}
+}
+
+func TestSQLChangeLogWriter(t *testing.T) {
+	timestamp := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rows := []ChangeLogRow{
+		{TargetType: "WeatherReport", TargetID: "42", Field: "name", Old: `"Miami"`, New: `"Tampa"`, ModifiedBy: "EUA1", ModifiedDts: timestamp},
+		{TargetType: "WeatherReport", TargetID: "42", Field: "level", Old: "1", New: "2", ModifiedBy: "EUA1", ModifiedDts: timestamp},
+	}
+
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "inserts every row"},
+		{name: "exec error", err: errors.New("connection reset"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &recordingExecer{err: tt.err}
+			err := NewSQLChangeLogWriter(db, "changes_log").WriteChanges(context.Background(), rows)
+
+			if tt.wantErr {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("WriteChanges() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WriteChanges() error = %v", err)
+			}
+
+			wantQuery := "INSERT INTO changes_log (target_type, target_id, field, old, new, modified_by, modified_dts) VALUES " +
+				"($1, $2, $3, $4, $5, $6, $7), ($8, $9, $10, $11, $12, $13, $14)"
+			if db.query != wantQuery {
+				t.Errorf("query = %q, want %q", db.query, wantQuery)
+			}
+			wantArgs := []interface{}{
+				"WeatherReport", "42", "name", `"Miami"`, `"Tampa"`, "EUA1", timestamp,
+				"WeatherReport", "42", "level", "1", "2", "EUA1", timestamp,
+			}
+			if !reflect.DeepEqual(db.args, wantArgs) {
+				t.Errorf("args = %v, want %v", db.args, wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package changeslint lints changeset files against a target type with
+// applychanges.LintChanges, for checking fixtures, queued changes or API
+// examples in CI. It backs the changeslint command, which generates a small
+// program calling Run with the type to lint against:
+//
+//	go run github.com/DylanSpOddball/apply-changes-wrapper/cmd/changeslint -type WeatherReport changes/*.json
+//
+// Programs that already import the target type can call Run themselves.
+package changeslint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+// Run lints each file, a JSON object of changes, against the target type and
+// writes one line per issue to out:
+//
+//	changes/rename.json: weatherr: error: WeatherReport has no field 'weatherr' (did you mean 'weather'?)
+//
+// It returns the exit code for the command: 1 when any file has an error (or
+// can't be read), 0 when the files only have warnings or none at all.
+func Run(targetType reflect.Type, files []string, out io.Writer, opts ...applychanges.Option) int {
+	code := 0
+	for _, file := range files {
+		changes, err := readChanges(file)
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", file, err)
+			code = 1
+			continue
+		}
+
+		for _, issue := range applychanges.LintChanges(targetType, changes, opts...) {
+			fmt.Fprintf(out, "%s: %s: %s: %s\n", file, issue.Field, issue.Severity, issue.Message)
+			if issue.Severity == applychanges.LintError {
+				code = 1
+			}
+		}
+	}
+
+	return code
+}
+
+// readChanges reads the JSON object of changes in the file
+func readChanges(file string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes map[string]interface{}
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("not a JSON object of changes: %w", err)
+	}
+
+	return changes, nil
+}
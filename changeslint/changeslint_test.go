@@ -0,0 +1,82 @@
+package changeslint
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+type station struct {
+	Name     string `json:"name"`
+	CallSign string `json:"callSign" apply:"deprecated"`
+}
+
+type weatherReport struct {
+	Weather     string  `json:"weather"`
+	Temperature float64 `json:"temperature"`
+	LegacyCode  string  `json:"legacyCode" apply:"deprecated"`
+	Station     station `json:"station"`
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		opts     []applychanges.Option
+		wantCode int
+		wantOut  string
+	}{
+		{
+			name:  "clean",
+			files: []string{"testdata/clean.json"},
+		},
+		{
+			name:     "errors and warnings",
+			files:    []string{"testdata/mixed.json"},
+			wantCode: 1,
+			wantOut: "testdata/mixed.json: Weather: error: 'Weather': no such field (did you mean 'weather'?)\n" +
+				"testdata/mixed.json: legacyCode: warning: 'legacyCode' is deprecated\n" +
+				"testdata/mixed.json: station.callSign: warning: 'station.callSign' is deprecated\n" +
+				"testdata/mixed.json: temperature: error: 'temperature': expected type 'float64', got unconvertible type 'string', value: 'hot'\n" +
+				"testdata/mixed.json: weatherr: error: weatherReport has no field 'weatherr' (did you mean 'weather'?)\n",
+		},
+		{
+			name:     "case-insensitive keys",
+			files:    []string{"testdata/mixed.json"},
+			opts:     []applychanges.Option{applychanges.WithCaseInsensitiveKeys()},
+			wantCode: 1,
+			wantOut: "testdata/mixed.json: Weather: warning: 'Weather' only matches 'weather' case-insensitively\n" +
+				"testdata/mixed.json: legacyCode: warning: 'legacyCode' is deprecated\n" +
+				"testdata/mixed.json: station.callSign: warning: 'station.callSign' is deprecated\n" +
+				"testdata/mixed.json: temperature: error: 'temperature': expected type 'float64', got unconvertible type 'string', value: 'hot'\n" +
+				"testdata/mixed.json: weatherr: error: weatherReport has no field 'weatherr' (did you mean 'weather'?)\n",
+		},
+		{
+			name:     "not an object",
+			files:    []string{"testdata/array.json", "testdata/clean.json"},
+			wantCode: 1,
+			wantOut:  "testdata/array.json: not a JSON object of changes: json: cannot unmarshal array into Go value of type map[string]interface {}\n",
+		},
+		{
+			name:     "missing file",
+			files:    []string{"testdata/missing.json"},
+			wantCode: 1,
+			wantOut:  "testdata/missing.json: open testdata/missing.json: no such file or directory\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			code := Run(reflect.TypeOf(weatherReport{}), tt.files, &out, tt.opts...)
+			if code != tt.wantCode {
+				t.Errorf("Run() = %d, want %d", code, tt.wantCode)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("Run() output:\n%s\nwant:\n%s", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
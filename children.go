@@ -0,0 +1,145 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// childIDField returns the ID field of the elements of a slice type when they
+// are entities, structs (or pointers to them) with a uuid.UUID ID like
+// BaseStruct's, whose changes can be keyed by ID, see mergeChildrenByID
+func childIDField(sliceType reflect.Type) (reflect.StructField, bool) {
+	elemType := sliceType.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+
+	field, ok := structFieldByName(elemType, "ID")
+	return field, ok && field.Type == uuidType
+}
+
+// mergeChildrenByID applies the changesets of a child collection keyed by
+// child ID, e.g. `{"notes": {"<id>": {"text": "..."}}}`, each to a copy of the
+// element with that ID like a nested struct's changes, stamping the child's own
+// ModifiedBy and ModifiedDts. It returns the slice's complete new value.
+//
+// Children that fail (an invalid or unknown ID, an immutable field, a value
+// that won't decode) fail the apply with FieldErrors listing all of them, or
+// WithPartialChildren are left as they were and returned as skipped.
+func mergeChildrenByID(current reflect.Value, incoming map[string]interface{}, idField reflect.StructField, cfg *config, path string) ([]interface{}, []SkippedField, error) {
+	merged := make([]interface{}, current.Len())
+	indexes := map[uuid.UUID]int{}
+	for i := 0; i < current.Len(); i++ {
+		element := deepCopy(current.Index(i))
+		merged[i] = element.Interface()
+
+		if element.Kind() == reflect.Ptr {
+			if element.IsNil() {
+				continue
+			}
+			element = element.Elem()
+		}
+		indexes[element.FieldByIndex(idField.Index).Interface().(uuid.UUID)] = i
+	}
+
+	keys := make([]string, 0, len(incoming))
+	for key := range incoming {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var failures FieldErrors
+	var skipped []SkippedField
+	for _, key := range keys {
+		childPath := fmt.Sprintf("%s[%s]", path, key)
+
+		i, err := childIndex(key, indexes)
+		if err == nil {
+			var childSkipped []SkippedField
+			merged[i], childSkipped, err = applyChild(incoming[key], merged[i], current.Type().Elem(), cfg, childPath)
+			skipped = append(skipped, childSkipped...)
+		}
+		if err == nil {
+			continue
+		}
+
+		if cfg.partialChildren {
+			skipped = append(skipped, SkippedField{Path: childPath, Reason: err.Error()})
+			continue
+		}
+		failures = append(failures, &FieldError{Path: childPath, Err: err})
+	}
+
+	if len(failures) > 0 {
+		return nil, nil, failures
+	}
+
+	return merged, skipped, nil
+}
+
+// childIndex finds the element with the ID the key gives
+func childIndex(key string, indexes map[uuid.UUID]int) (int, error) {
+	id, err := uuid.Parse(key)
+	if err != nil {
+		return 0, fmt.Errorf("not a child ID: %v", err)
+	}
+
+	i, ok := indexes[id]
+	if !ok {
+		return 0, fmt.Errorf("no child with this ID")
+	}
+
+	return i, nil
+}
+
+// applyChild applies a child's changes to the copy of its element, returning
+// the changed copy
+func applyChild(value interface{}, element interface{}, elemType reflect.Type, cfg *config, path string) (interface{}, []SkippedField, error) {
+	changes, ok := value.(map[string]interface{})
+	if !ok {
+		return element, nil, fmt.Errorf("expected the child's changes, got %T", value)
+	}
+	Sanitize(changes)
+
+	// pointer elements are changed in the copy they point to
+	target := reflect.ValueOf(element)
+	if elemType.Kind() != reflect.Ptr {
+		target = reflect.New(elemType)
+		target.Elem().Set(reflect.ValueOf(element))
+	}
+
+	if immutable := immutableFields(changes, target.Elem().Type(), cfg, "", ""); len(immutable) > 0 {
+		sort.Strings(immutable)
+		return element, nil, &ImmutableFieldError{Fields: immutable}
+	}
+
+	if cfg.modifier != nil {
+		if field, ok := structFieldByName(target.Elem().Type(), "ModifiedBy"); ok {
+			changes[fieldKey(field, cfg.tagName)] = *cfg.modifier
+		}
+		if key, ok := modifiedDtsKey(target.Interface(), cfg); ok {
+			changes[key] = cfg.now
+		}
+	}
+
+	prepareNestedChanges(changes, target.Elem(), cfg.tagName)
+	skipped, err := mergeCollections(changes, target.Elem(), cfg, path+".")
+	if err != nil {
+		return element, nil, err
+	}
+
+	if err := cfg.decode(changes, target.Interface()); err != nil {
+		return element, nil, decodeFieldErrors(err)
+	}
+
+	if elemType.Kind() == reflect.Ptr {
+		return target.Interface(), skipped, nil
+	}
+	return target.Elem().Interface(), skipped, nil
+}
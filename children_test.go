@@ -0,0 +1,167 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type childNote struct {
+	BaseStruct
+	Text     string `json:"text"`
+	Archived bool   `json:"archived"`
+}
+
+type noteParent struct {
+	BaseStruct
+	Title    string       `json:"title"`
+	Notes    []childNote  `json:"notes"`
+	Pinned   []*childNote `json:"pinned"`
+	Keywords []string     `json:"keywords"`
+}
+
+var (
+	noteID1 = uuid.MustParse("2f1c5b0e-0000-4000-8000-000000000001")
+	noteID2 = uuid.MustParse("2f1c5b0e-0000-4000-8000-000000000002")
+	noteID3 = uuid.MustParse("2f1c5b0e-0000-4000-8000-000000000003")
+)
+
+func newNoteParent() noteParent {
+	return noteParent{
+		Title: "Intake",
+		Notes: []childNote{
+			{BaseStruct: BaseStruct{ID: noteID1}, Text: "first"},
+			{BaseStruct: BaseStruct{ID: noteID2}, Text: "second"},
+			{BaseStruct: BaseStruct{ID: noteID3}, Text: "third"},
+		},
+		Pinned: []*childNote{{BaseStruct: BaseStruct{ID: noteID1}, Text: "pinned"}},
+	}
+}
+
+func TestChildrenByID(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	stamped := BaseStruct{ModifiedBy: stringPtr("EUA1"), ModifiedDts: &now}
+
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		opts        []Option
+		want        func(parent *noteParent)
+		wantSkipped []SkippedField
+		wantErr     string
+	}{
+		{
+			name: "two children updated",
+			changes: map[string]interface{}{"notes": map[string]interface{}{
+				noteID1.String(): map[string]interface{}{"text": "changed"},
+				noteID3.String(): map[string]interface{}{"archived": true},
+			}},
+			want: func(parent *noteParent) {
+				parent.Notes[0].Text, parent.Notes[0].ModifiedBy, parent.Notes[0].ModifiedDts = "changed", stamped.ModifiedBy, stamped.ModifiedDts
+				parent.Notes[2].Archived, parent.Notes[2].ModifiedBy, parent.Notes[2].ModifiedDts = true, stamped.ModifiedBy, stamped.ModifiedDts
+			},
+		},
+		{
+			name: "pointer children",
+			changes: map[string]interface{}{"pinned": map[string]interface{}{
+				noteID1.String(): map[string]interface{}{"text": "changed"},
+			}},
+			want: func(parent *noteParent) {
+				parent.Pinned = []*childNote{{BaseStruct: BaseStruct{ID: noteID1, ModifiedBy: stamped.ModifiedBy, ModifiedDts: stamped.ModifiedDts}, Text: "changed"}}
+			},
+		},
+		{
+			name: "unknown and invalid IDs",
+			changes: map[string]interface{}{"notes": map[string]interface{}{
+				noteID1.String():  map[string]interface{}{"text": "changed"},
+				uuid.Nil.String(): map[string]interface{}{"text": "lost"},
+				"42":              map[string]interface{}{"text": "lost"},
+			}},
+			wantErr: "2 errors applying changes: " +
+				"'notes[00000000-0000-0000-0000-000000000000]': no child with this ID; " +
+				"'notes[42]': not a child ID: invalid UUID length: 2",
+		},
+		{
+			name: "unknown ID WithPartialChildren",
+			changes: map[string]interface{}{"title": "Renamed", "notes": map[string]interface{}{
+				noteID2.String():  map[string]interface{}{"text": "changed"},
+				uuid.Nil.String(): map[string]interface{}{"text": "lost"},
+			}},
+			opts: []Option{WithPartialChildren()},
+			want: func(parent *noteParent) {
+				parent.Title = "Renamed"
+				parent.Notes[1].Text, parent.Notes[1].ModifiedBy, parent.Notes[1].ModifiedDts = "changed", stamped.ModifiedBy, stamped.ModifiedDts
+			},
+			wantSkipped: []SkippedField{{Path: "notes[00000000-0000-0000-0000-000000000000]", Reason: "no child with this ID"}},
+		},
+		{
+			name: "immutable child field",
+			changes: map[string]interface{}{"notes": map[string]interface{}{
+				noteID1.String(): map[string]interface{}{"id": noteID2.String(), "createdBy": "EUA2"},
+			}},
+			wantErr: "1 error applying changes: 'notes[2f1c5b0e-0000-4000-8000-000000000001]': 'createdBy' and 'id' cannot be changed",
+		},
+		{
+			name: "child value that won't decode WithPartialChildren",
+			changes: map[string]interface{}{"notes": map[string]interface{}{
+				noteID1.String(): map[string]interface{}{"archived": "maybe"},
+				noteID2.String(): "archived",
+			}},
+			opts: []Option{WithPartialChildren()},
+			wantSkipped: []SkippedField{
+				{Path: "notes[2f1c5b0e-0000-4000-8000-000000000001]", Reason: "1 error applying changes: 'archived': expected type 'bool', got unconvertible type 'string', value: 'maybe'"},
+				{Path: "notes[2f1c5b0e-0000-4000-8000-000000000002]", Reason: "expected the child's changes, got string"},
+			},
+			want: func(parent *noteParent) {},
+		},
+		{
+			name:    "list of children replaces them",
+			changes: map[string]interface{}{"notes": []interface{}{map[string]interface{}{"text": "only"}}},
+			want: func(parent *noteParent) {
+				parent.Notes = []childNote{{Text: "only"}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := newNoteParent()
+			opts := append([]Option{WithClock(fixedClock(now))}, tt.opts...)
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &parent, opts...)
+			if tt.wantErr != "" {
+				var fieldErrors FieldErrors
+				if err == nil || err.Error() != tt.wantErr || !errors.As(err, &fieldErrors) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %s", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(parent, newNoteParent()) {
+					t.Errorf("a failed apply changed the target to %+v", parent)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			want := newNoteParent()
+			tt.want(&want)
+			want.BaseStruct = stamped
+			if !reflect.DeepEqual(parent, want) {
+				t.Errorf("target = %+v, want %+v", parent, want)
+			}
+			if !reflect.DeepEqual(result.SkippedFields, tt.wantSkipped) {
+				t.Errorf("SkippedFields = %v, want %v", result.SkippedFields, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestChildrenByIDWithoutEntities(t *testing.T) {
+	parent := newNoteParent()
+	_, err := ApplyChangesWrapper(map[string]interface{}{"keywords": map[string]interface{}{"0": "flood"}}, "EUA1", &parent)
+	if err == nil {
+		t.Error("ApplyChangesWrapper() error = nil, want changes keyed by ID rejected for a slice of strings")
+	}
+}
@@ -0,0 +1,171 @@
+package applychanges
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// FieldCipher encrypts the values of fields tagged `apply:"encrypt"` as they
+// are applied, and decrypts them for Decrypted, see WithFieldCipher
+type FieldCipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// KeyProvider returns the key an AESGCMCipher encrypts with, e.g. from a secrets
+// manager; it's called for every value, so it can cache or rotate as it sees fit
+type KeyProvider func() ([]byte, error)
+
+// AESGCMCipher is a FieldCipher using AES-GCM with a random nonce per value,
+// which it prefixes to the ciphertext. Keys must be 16, 24 or 32 bytes long.
+type AESGCMCipher struct {
+	keys KeyProvider
+}
+
+// NewAESGCMCipher returns an AESGCMCipher with keys from the provider
+func NewAESGCMCipher(keys KeyProvider) *AESGCMCipher {
+	return &AESGCMCipher{keys: keys}
+}
+
+func (c *AESGCMCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	aead, err := c.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *AESGCMCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	aead, err := c.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+func (c *AESGCMCipher) aead() (cipher.AEAD, error) {
+	key, err := c.keys()
+	if err != nil {
+		return nil, fmt.Errorf("getting encryption key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Decrypted returns the plaintext of the entity's encrypted field (by tag name)
+// using the FieldCipher given with WithFieldCipher; an unset field is empty
+func Decrypted(entity interface{}, field string, opts ...Option) (string, error) {
+	cfg := configFor(reflect.TypeOf(entity), opts)
+	if cfg.cipher == nil {
+		return "", errors.New("no FieldCipher to decrypt with, see WithFieldCipher")
+	}
+
+	target, ok := targetStruct(entity)
+	if !ok {
+		return "", fmt.Errorf("fields can only be decrypted from structs, not %T", entity)
+	}
+
+	structField, ok := structFieldByTag(target.Type(), cfg.tagName, field)
+	if !ok || !hasApplyOption(structField, "encrypt") {
+		return "", fmt.Errorf("%s has no encrypted field '%s'", target.Type().Name(), field)
+	}
+
+	value := target.FieldByIndex(structField.Index)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return "", nil
+		}
+		value = value.Elem()
+	}
+	if value.String() == "" {
+		return "", nil
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value.String())
+	if err != nil {
+		return "", fmt.Errorf("decrypting '%s': %w", field, err)
+	}
+
+	plaintext, err := cfg.cipher.Decrypt(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decrypting '%s': %w", field, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// encryptFields replaces the values of the changes to `apply:"encrypt"` fields
+// (string or *string) with their base64-encoded ciphertext, including the
+// fields of nested structs. null is left alone, clearing the field.
+func encryptFields(changes map[string]interface{}, structType reflect.Type, cfg *config, prefix string) error {
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, cfg.tagName, key)
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if !hasApplyOption(field, "encrypt") {
+			if nested, ok := value.(map[string]interface{}); ok && fieldType.Kind() == reflect.Struct && !isScalar(fieldType) {
+				if err := encryptFields(nested, fieldType, cfg, prefix+key+"."); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if value == nil {
+			continue
+		}
+
+		plaintext, ok := value.(string)
+		if !ok || fieldType != stringType {
+			return fmt.Errorf("'%s' must be a string to be encrypted", prefix+key)
+		}
+
+		// linting only checks the value could be encrypted
+		if cfg.linting {
+			continue
+		}
+
+		if cfg.cipher == nil {
+			return fmt.Errorf("'%s' is encrypted but there's no FieldCipher, see WithFieldCipher", prefix+key)
+		}
+
+		ciphertext, err := cfg.cipher.Encrypt([]byte(plaintext))
+		if err != nil {
+			return fmt.Errorf("encrypting '%s': %w", prefix+key, err)
+		}
+
+		changes[key] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package applychanges
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type encryptedDetails struct {
+	Account string `json:"account" apply:"encrypt"`
+}
+
+type encryptedRecord struct {
+	Name    string            `json:"name"`
+	SSN     string            `json:"ssn" apply:"encrypt"`
+	Phone   *string           `json:"phone" apply:"encrypt"`
+	Count   int               `json:"count" apply:"encrypt"`
+	Details *encryptedDetails `json:"details"`
+}
+
+func testCipher() *AESGCMCipher {
+	return NewAESGCMCipher(func() ([]byte, error) { return bytes.Repeat([]byte{7}, 32), nil })
+}
+
+func TestAESGCMCipher(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     []byte
+		keyErr  error
+		wantErr string
+	}{
+		{name: "AES-128", key: bytes.Repeat([]byte{1}, 16)},
+		{name: "AES-256", key: bytes.Repeat([]byte{1}, 32)},
+		{name: "bad key length", key: []byte("short"), wantErr: "invalid key size"},
+		{name: "key provider error", keyErr: errors.New("vault down"), wantErr: "getting encryption key: vault down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewAESGCMCipher(func() ([]byte, error) { return tt.key, tt.keyErr })
+
+			first, err := c.Encrypt([]byte("123-45-6789"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Encrypt() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+
+			second, err := c.Encrypt([]byte("123-45-6789"))
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+			if bytes.Equal(first, second) {
+				t.Errorf("Encrypt() gave the same ciphertext twice, want a random nonce")
+			}
+
+			plaintext, err := c.Decrypt(first)
+			if err != nil || string(plaintext) != "123-45-6789" {
+				t.Errorf("Decrypt() = %q, %v, want the plaintext", plaintext, err)
+			}
+
+			first[len(first)-1] ^= 1
+			if _, err := c.Decrypt(first); err == nil {
+				t.Errorf("Decrypt() of tampered ciphertext succeeded")
+			}
+			if _, err := c.Decrypt(first[:4]); err == nil {
+				t.Errorf("Decrypt() of short ciphertext succeeded")
+			}
+		})
+	}
+}
+
+func TestWithFieldCipher(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		changes map[string]interface{}
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "string and pointer fields",
+			opts:    []Option{WithFieldCipher(testCipher())},
+			changes: map[string]interface{}{"name": "Kim", "ssn": "123-45-6789", "phone": "555-0100"},
+			want:    map[string]string{"name": "Kim", "ssn": "123-45-6789", "phone": "555-0100"},
+		},
+		{
+			name:    "nested field",
+			opts:    []Option{WithFieldCipher(testCipher())},
+			changes: map[string]interface{}{"details": map[string]interface{}{"account": "GB29NWBK"}},
+			want:    map[string]string{"details.account": "GB29NWBK"},
+		},
+		{
+			name:    "null clears the field",
+			opts:    []Option{WithFieldCipher(testCipher())},
+			changes: map[string]interface{}{"phone": nil},
+			want:    map[string]string{"phone": ""},
+		},
+		{
+			name:    "non-string value",
+			opts:    []Option{WithFieldCipher(testCipher())},
+			changes: map[string]interface{}{"ssn": 123},
+			wantErr: "'ssn' must be a string to be encrypted",
+		},
+		{
+			name:    "non-string field",
+			opts:    []Option{WithFieldCipher(testCipher())},
+			changes: map[string]interface{}{"count": "3"},
+			wantErr: "'count' must be a string to be encrypted",
+		},
+		{
+			name:    "no cipher",
+			changes: map[string]interface{}{"details": map[string]interface{}{"account": "GB29NWBK"}},
+			wantErr: "'details.account' is encrypted but there's no FieldCipher",
+		},
+		{
+			name:    "cipher error",
+			opts:    []Option{WithFieldCipher(NewAESGCMCipher(func() ([]byte, error) { return nil, errors.New("vault down") }))},
+			changes: map[string]interface{}{"ssn": "123-45-6789"},
+			wantErr: "encrypting 'ssn': getting encryption key: vault down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phone := "555-0199"
+			record := encryptedRecord{Phone: &phone, Details: &encryptedDetails{}}
+			_, err := ApplyChanges(tt.changes, &record, tt.opts...)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChanges() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if want, ok := tt.want["ssn"]; ok && record.SSN == want {
+				t.Errorf("SSN = %q, want it encrypted", record.SSN)
+			}
+			for path, want := range tt.want {
+				var got string
+				switch path {
+				case "name":
+					got = record.Name
+				case "details.account":
+					got, err = Decrypted(record.Details, "account", tt.opts...)
+				default:
+					got, err = Decrypted(&record, path, tt.opts...)
+				}
+				if err != nil || got != want {
+					t.Errorf("Decrypted(%s) = %q, %v, want %q", path, got, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecrypted(t *testing.T) {
+	tests := []struct {
+		name    string
+		entity  interface{}
+		field   string
+		opts    []Option
+		want    string
+		wantErr string
+	}{
+		{name: "unset field", entity: &encryptedRecord{}, field: "ssn", opts: []Option{WithFieldCipher(testCipher())}},
+		{name: "nil pointer field", entity: &encryptedRecord{}, field: "phone", opts: []Option{WithFieldCipher(testCipher())}},
+		{name: "no cipher", entity: &encryptedRecord{SSN: "x"}, field: "ssn", wantErr: "no FieldCipher to decrypt with"},
+		{name: "not encrypted", entity: &encryptedRecord{}, field: "name", opts: []Option{WithFieldCipher(testCipher())}, wantErr: "encryptedRecord has no encrypted field 'name'"},
+		{name: "not a struct", entity: "ssn", field: "ssn", opts: []Option{WithFieldCipher(testCipher())}, wantErr: "fields can only be decrypted from structs, not string"},
+		{name: "not base64", entity: &encryptedRecord{SSN: "not base64!"}, field: "ssn", opts: []Option{WithFieldCipher(testCipher())}, wantErr: "decrypting 'ssn'"},
+		{name: "not ciphertext", entity: &encryptedRecord{SSN: "c2hvcnQ="}, field: "ssn", opts: []Option{WithFieldCipher(testCipher())}, wantErr: "decrypting 'ssn': ciphertext too short"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decrypted(tt.entity, tt.field, tt.opts...)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Decrypted() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Decrypted() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// applyGroupTagName is the struct tag grouping fields that are managed
+// together, e.g. `applygroup:"contactInfo"`; a field can list several groups
+// separated by commas
+const applyGroupTagName = "applygroup"
+
+// ClearFields clears every given field of the target through the normal apply
+// path, as if the changes had carried an explicit null for each of them:
+// pointers become nil and values become their zero value, even
+// WithZeroFields(false).
+func ClearFields(fields []string, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	changes := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		changes[field] = nil
+	}
+
+	return ApplyChangesWrapper(changes, modifier, to, append(opts[:len(opts):len(opts)], zeroingFields)...)
+}
+
+// zeroingFields zeroes the fields ClearFields clears, since without
+// WithZeroFields a null leaves a field as it was
+func zeroingFields(cfg *config) {
+	cfg.zeroFields = true
+}
+
+// ClearGroup clears every field of the target tagged with the given applygroup,
+// see ClearFields.
+func ClearGroup(group string, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	fields, err := groupFields(reflect.TypeOf(to), group, configFor(reflect.TypeOf(to), opts))
+	if err != nil {
+		return ApplyResult{}, err
+	}
+
+	return ClearFields(fields, modifier, to, opts...)
+}
+
+// groupFields lists the changes keys of the fields in the given applygroup
+func groupFields(t reflect.Type, group string, cfg *config) ([]string, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("changes can only be applied to structs, not %v", t)
+	}
+
+	var fields []string
+	for _, field := range squashedFields(t) {
+		if name := fieldKey(field, cfg.tagName); name != "" && hasGroup(field, group) {
+			fields = append(fields, name)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s has no fields in group '%s'", t.Name(), group)
+	}
+
+	return fields, nil
+}
+
+func hasGroup(field reflect.StructField, group string) bool {
+	for _, candidate := range strings.Split(field.Tag.Get(applyGroupTagName), ",") {
+		if strings.TrimSpace(candidate) == group {
+			return true
+		}
+	}
+
+	return false
+}
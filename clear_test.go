@@ -0,0 +1,148 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type contactRecord struct {
+	BaseStruct
+	Name      string         `json:"name"`
+	Email     *string        `json:"email" applygroup:"contactInfo"`
+	Phone     string         `json:"phone" applygroup:"contactInfo, phoneInfo"`
+	Extension int            `json:"extension" applygroup:"phoneInfo"`
+	Address   *nestedDetails `json:"address" applygroup:"contactInfo"`
+	Tags      []string       `json:"tags" applygroup:"contactInfo"`
+	Code      string         `json:"code" apply:"immutable" applygroup:"identity"`
+	Source    string         `json:"source" applygroup:"identity"`
+}
+
+func newContactRecord() contactRecord {
+	return contactRecord{
+		Name:      "Tampa office",
+		Email:     stringPtr("tampa@example.com"),
+		Phone:     "555-0100",
+		Extension: 12,
+		Address:   &nestedDetails{Source: "survey", Level: 2},
+		Tags:      []string{"east"},
+		Code:      "TPA",
+		Source:    "import",
+	}
+}
+
+func TestClearFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		clear       func(to *contactRecord, opts ...Option) (ApplyResult, error)
+		opts        []Option
+		want        func(record *contactRecord)
+		wantApplied []string
+		wantErr     error
+	}{
+		{
+			name: "pointer and value fields",
+			clear: func(to *contactRecord, opts ...Option) (ApplyResult, error) {
+				return ClearFields([]string{"email", "extension"}, "EUA1", to, opts...)
+			},
+			want: func(record *contactRecord) {
+				record.Email, record.Extension = nil, 0
+			},
+			wantApplied: []string{"email", "extension", "modifiedBy"},
+		},
+		{
+			name: "value fields WithZeroFields(false)",
+			clear: func(to *contactRecord, opts ...Option) (ApplyResult, error) {
+				return ClearFields([]string{"email", "phone", "tags"}, "EUA1", to, opts...)
+			},
+			opts: []Option{WithZeroFields(false)},
+			want: func(record *contactRecord) {
+				record.Email, record.Phone, record.Tags = nil, "", nil
+			},
+			wantApplied: []string{"email", "modifiedBy", "phone", "tags"},
+		},
+		{
+			name: "group",
+			clear: func(to *contactRecord, opts ...Option) (ApplyResult, error) {
+				return ClearGroup("contactInfo", "EUA1", to, opts...)
+			},
+			want: func(record *contactRecord) {
+				record.Email, record.Phone, record.Address, record.Tags = nil, "", nil, nil
+			},
+			wantApplied: []string{"address", "email", "modifiedBy", "phone", "tags"},
+		},
+		{
+			name: "group listed among several",
+			clear: func(to *contactRecord, opts ...Option) (ApplyResult, error) {
+				return ClearGroup("phoneInfo", "EUA1", to, opts...)
+			},
+			want: func(record *contactRecord) {
+				record.Phone, record.Extension = "", 0
+			},
+			wantApplied: []string{"extension", "modifiedBy", "phone"},
+		},
+		{
+			name: "group with an immutable field",
+			clear: func(to *contactRecord, opts ...Option) (ApplyResult, error) {
+				return ClearGroup("identity", "EUA1", to, opts...)
+			},
+			wantErr: &ImmutableFieldError{Fields: []string{"code"}},
+		},
+		{
+			name: "protected fields reported together",
+			clear: func(to *contactRecord, opts ...Option) (ApplyResult, error) {
+				return ClearFields([]string{"code", "createdBy", "name"}, "EUA1", to, opts...)
+			},
+			wantErr: &ImmutableFieldError{Fields: []string{"code", "createdBy"}},
+		},
+		{
+			name: "denied field",
+			clear: func(to *contactRecord, opts ...Option) (ApplyResult, error) {
+				return ClearGroup("contactInfo", "EUA1", to, opts...)
+			},
+			opts:    []Option{WithDeniedFields("email")},
+			wantErr: &DisallowedFieldsError{Fields: []string{"email"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newContactRecord()
+			opts := append([]Option{WithModifiedDts(false)}, tt.opts...)
+			result, err := tt.clear(&record, opts...)
+
+			want := newContactRecord()
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() || reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(record, want) {
+					t.Errorf("a failed clear changed the target to %+v", record)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			tt.want(&want)
+			want.ModifiedBy = stringPtr("EUA1")
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("target = %+v, want %+v", record, want)
+			}
+			if !reflect.DeepEqual(result.AppliedFields, tt.wantApplied) {
+				t.Errorf("AppliedFields = %v, want %v", result.AppliedFields, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestClearGroupUnknown(t *testing.T) {
+	record := newContactRecord()
+	_, err := ClearGroup("billing", "EUA1", &record)
+	if err == nil || err.Error() != "contactRecord has no fields in group 'billing'" {
+		t.Errorf("ClearGroup() error = %v, want the empty group reported", err)
+	}
+	if !reflect.DeepEqual(record, newContactRecord()) {
+		t.Errorf("a failed clear changed the target to %+v", record)
+	}
+}
@@ -0,0 +1,16 @@
+package applychanges
+
+import "time"
+
+// Clock is the source of the times stamped by ApplyChangesWrapper, so tests can
+// use a fixed time and services a database-synchronized one (see WithClock)
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock, reading the local system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
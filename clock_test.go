@@ -0,0 +1,65 @@
+package applychanges
+
+import (
+	"testing"
+	"time"
+)
+
+// countingClock is a fixedClock counting the times it's read
+type countingClock struct {
+	now   time.Time
+	reads int
+}
+
+func (c *countingClock) Now() time.Time {
+	c.reads++
+	return c.now
+}
+
+func TestWithClock(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*3600)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{name: "UTC", now: now, want: now},
+		{name: "converted to UTC", now: time.Date(2024, 6, 1, 14, 0, 0, 0, berlin), want: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &countingClock{now: tt.now}
+			sink := &recordingSink{}
+			record := nestedRecord{}
+			if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record, WithClock(clock), WithAuditSink(sink)); err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if record.ModifiedDts == nil || !record.ModifiedDts.Equal(tt.want) || record.ModifiedDts.Location() != time.UTC {
+				t.Errorf("ModifiedDts = %v, want %v in UTC", record.ModifiedDts, tt.want)
+			}
+			if len(sink.entries) != 1 || !sink.entries[0].Timestamp.Equal(*record.ModifiedDts) {
+				t.Errorf("audit entries = %+v, want one stamped at ModifiedDts", sink.entries)
+			}
+			if clock.reads != 1 {
+				t.Errorf("clock read %d times, want once per apply", clock.reads)
+			}
+		})
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	before := time.Now()
+	record := nestedRecord{}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	after := time.Now()
+
+	if record.ModifiedDts == nil || record.ModifiedDts.Before(before.Truncate(time.Microsecond)) || record.ModifiedDts.After(after) {
+		t.Errorf("ModifiedDts = %v, want between %v and %v", record.ModifiedDts, before, after)
+	}
+}
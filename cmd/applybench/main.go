@@ -0,0 +1,126 @@
+// Command applybench measures the time and allocations of applying changes to
+// a few typical targets, so changes to the apply path can be compared:
+//
+//	go run github.com/DylanSpOddball/apply-changes-wrapper/cmd/applybench
+//
+// The flat scenario is a struct of scalar fields only, which takes the fast
+// path that skips mapstructure; entity embeds BaseStruct and is applied with
+// ApplyChangesWrapper, which changes nothing but scalar fields too, so it skips
+// mapstructure as well; nested decodes a nested struct and a slice.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+type flatReport struct {
+	City        string     `json:"city"`
+	Weather     string     `json:"weather"`
+	Temperature float64    `json:"temperature"`
+	Humidity    int        `json:"humidity"`
+	ReportedAt  *time.Time `json:"reportedAt"`
+}
+
+type entityReport struct {
+	applychanges.BaseStruct
+	City    string `json:"city"`
+	Weather string `json:"weather"`
+}
+
+type station struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type nestedReport struct {
+	City    string   `json:"city"`
+	Station station  `json:"station"`
+	Tags    []string `json:"tags"`
+}
+
+type scenario struct {
+	name  string
+	apply func() error
+}
+
+var scenarios = []scenario{
+	{"flat", func() error {
+		var report flatReport
+		_, err := applychanges.ApplyChanges(map[string]interface{}{
+			"city":        "Clearwater",
+			"weather":     "Thunderstorms",
+			"temperature": 31.5,
+			"humidity":    80,
+		}, &report)
+		return err
+	}},
+	{"entity", func() error {
+		report := entityReport{BaseStruct: applychanges.NewBaseStruct("Dylan")}
+		_, err := applychanges.ApplyChangesWrapper(map[string]interface{}{
+			"city":    "Clearwater",
+			"weather": "Thunderstorms",
+		}, "Mr. Weatherdude", &report)
+		return err
+	}},
+	{"nested", func() error {
+		var report nestedReport
+		_, err := applychanges.ApplyChanges(map[string]interface{}{
+			"city": "Clearwater",
+			"station": map[string]interface{}{
+				"name":      "KPIE",
+				"latitude":  27.91,
+				"longitude": -82.69,
+			},
+			"tags": []interface{}{"storm", "warning"},
+		}, &report)
+		return err
+	}},
+}
+
+func main() {
+	run := flag.String("run", "", "comma-separated names of the scenarios to run (default all)")
+	flag.Parse()
+
+	selected := map[string]bool{}
+	for _, name := range strings.Split(*run, ",") {
+		if name != "" {
+			selected[name] = true
+		}
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "scenario\tns/op\tB/op\tallocs/op\t")
+
+	for _, s := range scenarios {
+		if len(selected) > 0 && !selected[s.name] {
+			continue
+		}
+
+		if err := s.apply(); err != nil {
+			log.Fatalf("%s: %v", s.name, err)
+		}
+
+		result := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := s.apply(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\t\n", s.name, result.NsPerOp(), result.AllocedBytesPerOp(), result.AllocsPerOp())
+	}
+
+	out.Flush()
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fullField is a field the generated applier (see writeApplier) handles
+type fullField struct {
+	key       string
+	selector  string
+	typ       types.Type
+	immutable bool
+	sensitive bool
+}
+
+// elem is the type a change to the field is converted to: what a pointer
+// field points to, or the field's own type
+func (f fullField) elem() types.Type {
+	if pointer, ok := f.typ.(*types.Pointer); ok {
+		return pointer.Elem()
+	}
+
+	return f.typ
+}
+
+// fullType is a struct the generated applier handles, with the keys it stamps
+type fullType struct {
+	name           string
+	fields         []fullField
+	modifiedByKey  string
+	modifiedDtsKey string
+}
+
+// stampedOptions are the apply tag options the generated applier carries out
+// (or, for immutable fields, hands to the reflection path)
+var stampedOptions = map[string]bool{"immutable": true, "sensitive": true}
+
+// unsupportedMethods are the methods through which a type takes part in its
+// applies, which the generated applier doesn't call
+var unsupportedMethods = []string{"BeforeApply", "BeforeApplyContext", "AfterApply", "AfterApplyContext", "SanitizeChanges"}
+
+// unsupportedFields are the fields, by Go name, that applies maintain in ways
+// the generated applier doesn't
+var unsupportedFields = map[string]bool{"LockVersion": true, "RecentModifiers": true, "ModifiedByPrincipal": true}
+
+// loadFullType lists the fields of a struct for its generated applier, failing
+// with every reason the applier couldn't behave like the reflection path for
+// it: fields of types it doesn't convert, unexported fields with a key, keys
+// shared case-insensitively, and the tags and methods it ignores
+func loadFullType(object types.Object, structType *types.Struct, tagName string) (fullType, error) {
+	name := object.Name()
+	full := fullType{name: name}
+	var problems []string
+
+	methods := types.NewMethodSet(types.NewPointer(object.Type()))
+	for _, method := range unsupportedMethods {
+		if methods.Lookup(object.Pkg(), method) != nil {
+			problems = append(problems, fmt.Sprintf("it has a %s method", method))
+		}
+	}
+
+	type embedded struct {
+		structType *types.Struct
+		selector   string
+	}
+
+	keys := map[string]string{}
+	structs := []embedded{{structType: structType}}
+	for len(structs) > 0 {
+		current := structs[0]
+		structs = structs[1:]
+
+		for i := 0; i < current.structType.NumFields(); i++ {
+			v := current.structType.Field(i)
+			tag := reflect.StructTag(current.structType.Tag(i))
+			selector := current.selector + "." + v.Name()
+
+			if v.Embedded() {
+				nested, ok := v.Type().Underlying().(*types.Struct)
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s is embedded but not a struct", v.Name()))
+					continue
+				}
+				structs = append(structs, embedded{structType: nested, selector: selector})
+				continue
+			}
+
+			key := fieldKey(v.Name(), tag, tagName)
+			if key == "" {
+				continue
+			}
+
+			switch {
+			case !v.Exported():
+				problems = append(problems, fmt.Sprintf("%s is unexported", v.Name()))
+				continue
+			case unsupportedFields[v.Name()]:
+				problems = append(problems, fmt.Sprintf("%s is maintained by the apply", v.Name()))
+				continue
+			case !isASCII(key):
+				problems = append(problems, fmt.Sprintf("%s has the non-ASCII key %q", v.Name(), key))
+				continue
+			case !fullySupported(v.Type()):
+				problems = append(problems, fmt.Sprintf("%s has the unsupported type %s", v.Name(), typeName(v.Type())))
+				continue
+			}
+
+			if other, ok := keys[strings.ToLower(key)]; ok {
+				problems = append(problems, fmt.Sprintf("%s and %s share the key %q", other, v.Name(), key))
+				continue
+			}
+			keys[strings.ToLower(key)] = v.Name()
+
+			if _, ok := tag.Lookup("applyas"); ok {
+				problems = append(problems, fmt.Sprintf("%s has an applyas tag", v.Name()))
+			}
+
+			f := fullField{key: key, selector: selector, typ: v.Type()}
+			for _, option := range strings.Split(tag.Get("apply"), ",") {
+				option = strings.TrimSpace(option)
+				switch {
+				case option == "":
+				case !stampedOptions[option]:
+					problems = append(problems, fmt.Sprintf("%s has the apply option %q", v.Name(), option))
+				case option == "immutable":
+					f.immutable = true
+				case option == "sensitive":
+					f.sensitive = true
+				}
+			}
+
+			switch v.Name() {
+			case "ModifiedBy":
+				if typeName(f.elem()) == "string" {
+					full.modifiedByKey = key
+				}
+			case "ModifiedDts":
+				if typeName(f.elem()) == "time.Time" {
+					full.modifiedDtsKey = key
+				}
+			}
+
+			full.fields = append(full.fields, f)
+		}
+	}
+
+	if full.modifiedByKey == "" {
+		problems = append(problems, "it has no string ModifiedBy field to stamp")
+	}
+
+	if len(problems) > 0 {
+		return fullType{}, fmt.Errorf("can't generate an applier for %s: %s", name, strings.Join(problems, "; "))
+	}
+
+	sort.Slice(full.fields, func(i, j int) bool {
+		return full.fields[i].key < full.fields[j].key
+	})
+
+	return full, nil
+}
+
+// fullySupported reports whether the generated applier converts changes for
+// fields of the type: bool, string, the signed integer and float types,
+// time.Time and uuid.UUID, and pointers to them
+func fullySupported(t types.Type) bool {
+	if pointer, ok := t.(*types.Pointer); ok {
+		t = pointer.Elem()
+	}
+
+	if basic, ok := t.(*types.Basic); ok {
+		return basic.Info()&(types.IsBoolean|types.IsString|types.IsFloat) != 0 ||
+			basic.Info()&(types.IsInteger|types.IsUnsigned) == types.IsInteger
+	}
+
+	switch typeName(t) {
+	case "time.Time", "uuid.UUID":
+		return true
+	}
+
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// converter is the genapply function converting a change for the type
+func converter(t types.Type) string {
+	switch name := typeName(t); name {
+	case "string":
+		return "genapply.String(%q, value)"
+	case "bool":
+		return "genapply.Bool(%q, value)"
+	case "time.Time":
+		return "genapply.Time(%q, value)"
+	case "uuid.UUID":
+		return "genapply.UUID(%q, value)"
+	case "float32", "float64":
+		return "genapply.Float(%q, \"" + name + "\", value)"
+	}
+
+	return "genapply.Int(%q, \"" + typeName(t) + "\", value)"
+}
+
+// converted is the converted value v as the type, for the numeric types
+// genapply converts to int64 or float64
+func converted(t types.Type) string {
+	switch name := typeName(t); name {
+	case "int64", "float64", "string", "bool", "time.Time", "uuid.UUID":
+		return "v"
+	default:
+		return name + "(v)"
+	}
+}
+
+// writeApplier writes the exported Apply<T>Changes function, applying the
+// changes like ApplyChangesWrapper without reflection: it hands the changes to
+// ApplyChangesWrapper whenever they hold something the reflection path would
+// reject before decoding (or that it doesn't convert), and otherwise sanitizes
+// and stamps them, converts every change onto a copy of the target, and only
+// then assigns the copy
+func writeApplier(out *bytes.Buffer, full fullType) {
+	var plain, handed []string
+	lowered := make([]string, 0, len(full.fields))
+	for _, f := range full.fields {
+		lowered = append(lowered, strings.ToLower(f.key))
+		if f.immutable || f.key == full.modifiedDtsKey {
+			handed = append(handed, fmt.Sprintf("%q", f.key))
+		} else {
+			plain = append(plain, fmt.Sprintf("%q", f.key))
+		}
+	}
+	sort.Strings(lowered)
+
+	lowerName := strings.ToLower(full.name[:1]) + full.name[1:]
+
+	fmt.Fprintf(out, `
+// Apply%[1]sChanges applies the changes to a %[1]s like
+// applychanges.ApplyChangesWrapper without options, but without reflection.
+// Changes it can't handle itself are handed to ApplyChangesWrapper.
+func Apply%[1]sChanges(changes map[string]interface{}, modifier string, to *%[1]s) (applychanges.ApplyResult, error) {
+	if to == nil || !genapply.Plain(changes) {
+		return applychanges.ApplyChangesWrapper(changes, modifier, to)
+	}
+
+	for key := range changes {
+		switch key {
+`, full.name)
+
+	if len(plain) > 0 {
+		fmt.Fprintf(out, "\t\tcase %s:\n", strings.Join(plain, ", "))
+	}
+	if len(handed) > 0 {
+		fmt.Fprintf(out, "\t\tcase %s:\n\t\t\treturn applychanges.ApplyChangesWrapper(changes, modifier, to)\n", strings.Join(handed, ", "))
+	}
+	fmt.Fprintf(out, `		default:
+			if genapply.Folded(key, %sKeys) {
+				return applychanges.ApplyChangesWrapper(changes, modifier, to)
+			}
+		}
+	}
+
+	genapply.Sanitize(changes)
+	changes[%q] = modifier
+`, lowerName, full.modifiedByKey)
+	if full.modifiedDtsKey != "" {
+		fmt.Fprintf(out, "\tnow := time.Now().UTC()\n\tchanges[%q] = now\n", full.modifiedDtsKey)
+	}
+
+	fmt.Fprintf(out, `
+	next := *to
+	var errs []*applychanges.FieldError
+	for key, value := range changes {
+		switch key {
+`)
+
+	var sensitive []string
+	for _, f := range full.fields {
+		if f.immutable {
+			continue
+		}
+		if f.sensitive {
+			sensitive = append(sensitive, fmt.Sprintf(", %q", f.key))
+		}
+
+		fmt.Fprintf(out, "\t\tcase %q:\n", f.key)
+		_, pointer := f.typ.(*types.Pointer)
+		if f.key == full.modifiedDtsKey {
+			if pointer {
+				fmt.Fprintf(out, "\t\t\tstamped := now\n\t\t\tnext%s = &stamped\n", f.selector)
+			} else {
+				fmt.Fprintf(out, "\t\t\tnext%s = now\n", f.selector)
+			}
+			continue
+		}
+
+		if pointer {
+			fmt.Fprintf(out, "\t\t\tif value == nil {\n\t\t\t\tnext%s = nil\n\t\t\t\tcontinue\n\t\t\t}\n", f.selector)
+		}
+		fmt.Fprintf(out, "\t\t\tv, err := "+converter(f.elem())+"\n", f.key)
+		fmt.Fprintf(out, "\t\t\tif err != nil {\n\t\t\t\terrs = append(errs, err)\n\t\t\t\tcontinue\n\t\t\t}\n")
+		switch {
+		case pointer && converted(f.elem()) == "v":
+			fmt.Fprintf(out, "\t\t\tnext%s = &v\n", f.selector)
+		case pointer:
+			fmt.Fprintf(out, "\t\t\tconverted := %s\n\t\t\tnext%s = &converted\n", converted(f.elem()), f.selector)
+		default:
+			fmt.Fprintf(out, "\t\t\tnext%s = %s\n", f.selector, converted(f.elem()))
+		}
+	}
+
+	fmt.Fprintf(out, `		default:
+			errs = append(errs, genapply.NoSuchField(key))
+		}
+	}
+	if len(errs) > 0 {
+		return applychanges.ApplyResult{}, genapply.FieldErrors(errs%s)
+	}
+
+	result := applychanges.ApplyResult{AppliedFields: make([]string, 0, len(changes))}
+	for key := range changes {
+		result.AppliedFields = append(result.AppliedFields, key)
+	}
+	sort.Strings(result.AppliedFields)
+
+	result.Changes = make([]applychanges.FieldChange, 0, len(result.AppliedFields))
+	for _, key := range result.AppliedFields {
+		result.Changes = append(result.Changes, %sChange(key, to, &next))
+	}
+
+	*to = next
+	return result, nil
+}
+`, strings.Join(sensitive, ""), lowerName)
+
+	fmt.Fprintf(out, "\n// %sKeys are the keys of a %s, lowercased\nvar %sKeys = map[string]bool{\n", lowerName, full.name, lowerName)
+	for _, key := range lowered {
+		fmt.Fprintf(out, "\t%q: true,\n", key)
+	}
+	fmt.Fprintf(out, "}\n")
+
+	fmt.Fprintf(out, `
+// %[1]sChange reports the change to the field of a %[2]s with the key
+func %[1]sChange(key string, before, after *%[2]s) applychanges.FieldChange {
+	switch key {
+`, lowerName, full.name)
+
+	for _, f := range full.fields {
+		if f.immutable {
+			continue
+		}
+
+		oldValue, newValue := "before"+f.selector, "after"+f.selector
+		if _, pointer := f.typ.(*types.Pointer); pointer {
+			oldValue, newValue = "genapply.Value("+oldValue+")", "genapply.Value("+newValue+")"
+		}
+
+		fmt.Fprintf(out, "\tcase %q:\n\t\treturn applychanges.FieldChange{Path: key, Old: %s, New: %s", f.key, oldValue, newValue)
+		if f.sensitive {
+			fmt.Fprintf(out, ", Sensitive: true")
+		}
+		fmt.Fprintf(out, "}\n")
+	}
+
+	fmt.Fprintf(out, "\t}\n\n\treturn applychanges.FieldChange{Path: key}\n}\n")
+}
@@ -0,0 +1,302 @@
+// Command applygen generates reflection-free decoders for structs that changes
+// are applied to, registered with applychanges.RegisterFastDecoder. Run it
+// with go:generate in the package declaring the structs:
+//
+//	//go:generate go run github.com/DylanSpOddball/apply-changes-wrapper/cmd/applygen -type WeatherReport
+//
+// For each type it writes an applyChangesWeatherReport function assigning the
+// changes to the fields directly. Fields of the basic types, time.Time,
+// pointers to them and slices of basic types are assigned when a change holds
+// exactly the field's type (or null, for pointers and slices); any other key or
+// value makes the generated function leave the changes to the reflection path.
+//
+// With -full it also writes an ApplyWeatherReportChanges function doing the
+// whole of ApplyChangesWrapper without reflection, registered with
+// applychanges.RegisterApplier so applychanges.Apply dispatches to it. It
+// converts changes to fields of the basic types (other than unsigned
+// integers), time.Time and uuid.UUID, and pointers to them, reporting the same
+// errors as the reflection path, and hands it the changes it can't handle.
+// Types it couldn't apply changes to the same way (other field types, apply
+// hooks, lock versions, ...) are refused.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated names of the struct types to generate decoders for")
+	tagName := flag.String("tag", "json", "struct tag the changes are keyed by")
+	output := flag.String("output", "", "output file (default <first type>_apply_gen.go, lowercased)")
+	full := flag.Bool("full", false, "also generate reflection-free appliers, registered with applychanges.RegisterApplier")
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	pkg, err := loadPackage(".")
+	if err != nil {
+		log.Fatalf("applygen: %v", err)
+	}
+
+	names := strings.Split(*typeNames, ",")
+	source, err := generate(pkg, names, *tagName, *full)
+	if err != nil {
+		log.Fatalf("applygen: %v", err)
+	}
+
+	filename := *output
+	if filename == "" {
+		filename = strings.ToLower(names[0]) + "_apply_gen.go"
+	}
+
+	if err := os.WriteFile(filename, source, 0o644); err != nil {
+		log.Fatalf("applygen: %v", err)
+	}
+}
+
+// loadPackage parses and type-checks the non-test Go files of the package in
+// dir
+func loadPackage(dir string) (*types.Package, error) {
+	fset := token.NewFileSet()
+	parsed, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && !strings.HasSuffix(info.Name(), "_apply_gen.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parsed) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(parsed))
+	}
+
+	var files []*ast.File
+	for _, p := range parsed {
+		for _, file := range p.Files {
+			files = append(files, file)
+		}
+	}
+
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return config.Check(files[0].Name.Name, fset, files, nil)
+}
+
+// field is a field the generated decoder assigns
+type field struct {
+	key      string
+	selector string
+	typ      types.Type
+}
+
+func generate(pkg *types.Package, names []string, tagName string, full bool) ([]byte, error) {
+	if full && tagName != "json" {
+		return nil, fmt.Errorf("-full needs -tag json, the tag ApplyChangesWrapper keys changes by without options")
+	}
+
+	var body bytes.Buffer
+	usesTime := false
+
+	for _, name := range names {
+		object := pkg.Scope().Lookup(name)
+		if object == nil {
+			return nil, fmt.Errorf("no type %s in package %s", name, pkg.Name())
+		}
+
+		structType, ok := object.Type().Underlying().(*types.Struct)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a struct", name)
+		}
+
+		fields := squashedFields(structType, tagName)
+		for _, f := range fields {
+			if strings.Contains(typeName(f.typ), "time.") {
+				usesTime = true
+			}
+		}
+
+		writeDecoder(&body, name, fields)
+
+		if full {
+			applied, err := loadFullType(object, structType, tagName)
+			if err != nil {
+				return nil, err
+			}
+			usesTime = usesTime || applied.modifiedDtsKey != ""
+
+			writeApplier(&body, applied)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by applygen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg.Name())
+	if full {
+		fmt.Fprintf(&out, "\t\"sort\"\n")
+	}
+	if usesTime {
+		fmt.Fprintf(&out, "\t\"time\"\n")
+	}
+	fmt.Fprintf(&out, "\n\tapplychanges \"github.com/DylanSpOddball/apply-changes-wrapper\"\n")
+	if full {
+		fmt.Fprintf(&out, "\t\"github.com/DylanSpOddball/apply-changes-wrapper/genapply\"\n")
+	}
+	fmt.Fprintf(&out, ")\n\nfunc init() {\n")
+	for _, name := range names {
+		fmt.Fprintf(&out, "\tapplychanges.RegisterFastDecoder(%q, applyChanges%s)\n", tagName, name)
+		if full {
+			fmt.Fprintf(&out, "\tapplychanges.RegisterApplier(Apply%sChanges)\n", name)
+		}
+	}
+	fmt.Fprintf(&out, "}\n")
+	out.Write(body.Bytes())
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+
+	return source, nil
+}
+
+// squashedFields lists the supported fields of a struct the way the reflection
+// path sees them: the fields of embedded structs after the outer struct's own,
+// keeping the first field for each key
+func squashedFields(structType *types.Struct, tagName string) []field {
+	type embedded struct {
+		structType *types.Struct
+		selector   string
+	}
+
+	seen := map[string]bool{}
+	var fields []field
+
+	structs := []embedded{{structType: structType}}
+	for len(structs) > 0 {
+		current := structs[0]
+		structs = structs[1:]
+
+		for i := 0; i < current.structType.NumFields(); i++ {
+			v := current.structType.Field(i)
+			selector := current.selector + "." + v.Name()
+
+			if nested, ok := v.Type().Underlying().(*types.Struct); ok && v.Embedded() {
+				structs = append(structs, embedded{structType: nested, selector: selector})
+				continue
+			}
+
+			key := fieldKey(v.Name(), reflect.StructTag(current.structType.Tag(i)), tagName)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if v.Exported() && supported(v.Type()) {
+				fields = append(fields, field{key: key, selector: selector, typ: v.Type()})
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].key < fields[j].key
+	})
+
+	return fields
+}
+
+// fieldKey mirrors the key applychanges decodes a field from
+func fieldKey(name string, tag reflect.StructTag, tagName string) string {
+	key := strings.SplitN(tag.Get(tagName), ",", 2)[0]
+	if key == "-" {
+		return ""
+	}
+	if key == "" {
+		return name
+	}
+
+	return key
+}
+
+// supported reports whether the generated code assigns fields of the type:
+// basic types and time.Time, pointers to them and slices of basic types
+func supported(t types.Type) bool {
+	switch typed := t.(type) {
+	case *types.Pointer:
+		return isValue(typed.Elem())
+	case *types.Slice:
+		_, ok := typed.Elem().(*types.Basic)
+		return ok
+	}
+
+	return isValue(t)
+}
+
+// isValue reports whether t is an unnamed basic type or time.Time
+func isValue(t types.Type) bool {
+	if basic, ok := t.(*types.Basic); ok {
+		return basic.Info()&(types.IsBoolean|types.IsNumeric|types.IsString) != 0 && basic.Kind() != types.UntypedNil
+	}
+
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Time"
+}
+
+func typeName(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+}
+
+// writeDecoder writes the function checking every change can be assigned and
+// then assigning them
+func writeDecoder(out *bytes.Buffer, name string, fields []field) {
+	fmt.Fprintf(out, `
+// applyChanges%[1]s assigns the changes to a %[1]s without
+// reflection, or reports false (leaving it untouched) when it can't handle
+// them
+func applyChanges%[1]s(changes map[string]interface{}, to *%[1]s) bool {
+	for key, value := range changes {
+		switch key {
+`, name)
+
+	for _, f := range fields {
+		fmt.Fprintf(out, "\t\tcase %q:\n", f.key)
+		switch typed := f.typ.(type) {
+		case *types.Pointer:
+			fmt.Fprintf(out, "\t\t\tswitch value.(type) {\n\t\t\tcase nil, %s:\n\t\t\tdefault:\n\t\t\t\treturn false\n\t\t\t}\n", typeName(typed.Elem()))
+		case *types.Slice:
+			fmt.Fprintf(out, "\t\t\tswitch value.(type) {\n\t\t\tcase nil, %s:\n\t\t\tdefault:\n\t\t\t\treturn false\n\t\t\t}\n", typeName(typed))
+		default:
+			fmt.Fprintf(out, "\t\t\tif _, ok := value.(%s); !ok {\n\t\t\t\treturn false\n\t\t\t}\n", typeName(typed))
+		}
+	}
+
+	fmt.Fprintf(out, "\t\tdefault:\n\t\t\treturn false\n\t\t}\n\t}\n\n\tfor key, value := range changes {\n\t\tswitch key {\n")
+
+	for _, f := range fields {
+		fmt.Fprintf(out, "\t\tcase %q:\n", f.key)
+		switch typed := f.typ.(type) {
+		case *types.Pointer:
+			fmt.Fprintf(out, "\t\t\tif value == nil {\n\t\t\t\tto%s = nil\n\t\t\t} else {\n\t\t\t\tv := value.(%s)\n\t\t\t\tto%s = &v\n\t\t\t}\n", f.selector, typeName(typed.Elem()), f.selector)
+		case *types.Slice:
+			fmt.Fprintf(out, "\t\t\tif value == nil {\n\t\t\t\tto%s = nil\n\t\t\t} else {\n\t\t\t\tv := value.(%s)\n\t\t\t\tto%s = append(make(%s, 0, len(v)), v...)\n\t\t\t}\n", f.selector, typeName(typed), f.selector, typeName(typed))
+		default:
+			fmt.Fprintf(out, "\t\t\tto%s = value.(%s)\n", f.selector, typeName(typed))
+		}
+	}
+
+	fmt.Fprintf(out, "\t\t}\n\t}\n\n\treturn true\n}\n")
+}
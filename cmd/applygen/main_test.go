@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedAppliersAreUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "applygentest")
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		t.Fatalf("loadPackage() error = %v", err)
+	}
+
+	source, err := generate(pkg, []string{"Station", "Reading"}, "json", true)
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	committed, err := os.ReadFile(filepath.Join(dir, "station_apply_gen.go"))
+	if err != nil {
+		t.Fatalf("reading the generated file: %v", err)
+	}
+	if !bytes.Equal(source, committed) {
+		t.Errorf("station_apply_gen.go is out of date, run go generate in %s", dir)
+	}
+}
+
+func TestGeneratedDecodersAreUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "demo")
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		t.Fatalf("loadPackage() error = %v", err)
+	}
+
+	source, err := generate(pkg, []string{"WeatherReport"}, "json", false)
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	committed, err := os.ReadFile(filepath.Join(dir, "weatherreport_apply_gen.go"))
+	if err != nil {
+		t.Fatalf("reading the generated file: %v", err)
+	}
+	if !bytes.Equal(source, committed) {
+		t.Errorf("weatherreport_apply_gen.go is out of date, run go generate in %s", dir)
+	}
+}
+
+func TestGenerateDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		tagName  string
+		want     []string
+		wantNot  []string
+		wantErr  string
+		typeName string
+	}{
+		{
+			name:    "basic types and pointers",
+			source:  "type Record struct {\n\tName string `json:\"name\"`\n\tCount *int `json:\"count\"`\n}",
+			tagName: "json",
+			want:    []string{`applychanges.RegisterFastDecoder("json", applyChangesRecord)`, `case "name":`, `to.Name = value.(string)`, `case nil, int:`, `to.Count = &v`},
+		},
+		{
+			name:    "slices of basic types and times",
+			source:  "import \"time\"\n\ntype Record struct {\n\tTags []string `json:\"tags\"`\n\tAt time.Time `json:\"at\"`\n}",
+			tagName: "json",
+			want:    []string{`"time"`, `case nil, []string:`, `append(make([]string, 0, len(v)), v...)`, `to.At = value.(time.Time)`},
+		},
+		{
+			name:    "embedded structs after their outer fields",
+			source:  "type Base struct {\n\tName string `json:\"name\"`\n\tID string `json:\"id\"`\n}\n\ntype Record struct {\n\tBase\n\tName string `json:\"name\"`\n}",
+			tagName: "json",
+			want:    []string{`to.Name = value.(string)`, `to.Base.ID = value.(string)`},
+			wantNot: []string{`to.Base.Name`},
+		},
+		{
+			name:    "unsupported and skipped fields",
+			source:  "type Record struct {\n\tName string `json:\"name\"`\n\tScores map[string]int `json:\"scores\"`\n\tSkipped string `json:\"-\"`\n\tsecret string\n}",
+			tagName: "json",
+			want:    []string{`case "name":`},
+			wantNot: []string{`"scores"`, `Skipped`, `secret`},
+		},
+		{
+			name:    "another tag",
+			source:  "type Record struct {\n\tName string `db:\"full_name\"`\n\tPlain string\n}",
+			tagName: "db",
+			want:    []string{`applychanges.RegisterFastDecoder("db", applyChangesRecord)`, `case "full_name":`, `case "Plain":`},
+		},
+		{
+			name:     "unknown type",
+			source:   "type Record struct{}",
+			tagName:  "json",
+			typeName: "Missing",
+			wantErr:  "no type Missing in package record",
+		},
+		{
+			name:    "not a struct",
+			source:  "type Record string",
+			tagName: "json",
+			wantErr: "Record is not a struct",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "record.go"), []byte("package record\n\n"+tt.source+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			pkg, err := loadPackage(dir)
+			if err != nil {
+				t.Fatalf("loadPackage() error = %v", err)
+			}
+
+			typeName := tt.typeName
+			if typeName == "" {
+				typeName = "Record"
+			}
+			source, err := generate(pkg, []string{typeName}, tt.tagName, false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("generate() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("generate() error = %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !bytes.Contains(source, []byte(want)) {
+					t.Errorf("generated code doesn't contain %s:\n%s", want, source)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if bytes.Contains(source, []byte(unwanted)) {
+					t.Errorf("generated code contains %s:\n%s", unwanted, source)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateFullRefusesUnsupportedTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		tagName string
+		want    string
+	}{
+		{
+			name:    "supported",
+			source:  "type Record struct {\n\tModifiedBy string `json:\"modifiedBy\"`\n\tCount int `json:\"count\"`\n}",
+			tagName: "json",
+		},
+		{
+			name:    "another tag",
+			source:  "type Record struct {\n\tModifiedBy string `db:\"modified_by\"`\n}",
+			tagName: "db",
+			want:    "-full needs -tag json",
+		},
+		{
+			name:    "unsupported fields",
+			source:  "type Record struct {\n\tModifiedBy string\n\tCount uint\n\tTags []string\n\tLockVersion int\n\tsecret string\n}",
+			tagName: "json",
+			want:    "Count has the unsupported type uint; Tags has the unsupported type []string; LockVersion is maintained by the apply; secret is unexported",
+		},
+		{
+			name:    "shared key",
+			source:  "type Record struct {\n\tModifiedBy string\n\tName string `json:\"name\"`\n\tOtherName string `json:\"Name\"`\n}",
+			tagName: "json",
+			want:    "Name and OtherName share the key \"Name\"",
+		},
+		{
+			name:    "tags",
+			source:  "type Record struct {\n\tModifiedBy string\n\tTags string `apply:\"append\"`\n\tDay string `applyas:\"date\"`\n}",
+			tagName: "json",
+			want:    "Tags has the apply option \"append\"; Day has an applyas tag",
+		},
+		{
+			name:    "hooks",
+			source:  "type Record struct {\n\tModifiedBy string\n}\n\nfunc (r *Record) BeforeApply(map[string]interface{}) error { return nil }",
+			tagName: "json",
+			want:    "it has a BeforeApply method",
+		},
+		{
+			name:    "no modifier",
+			source:  "type Record struct {\n\tName string\n}",
+			tagName: "json",
+			want:    "it has no string ModifiedBy field to stamp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "record.go"), []byte("package record\n\n"+tt.source+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			pkg, err := loadPackage(dir)
+			if err != nil {
+				t.Fatalf("loadPackage() error = %v", err)
+			}
+
+			_, err = generate(pkg, []string{"Record"}, tt.tagName, true)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("generate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("generate() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Command changeslint lints changeset files, JSON objects of changes, against
+// a struct type the way applychanges.LintChanges does, printing one line per
+// issue and exiting with 1 when any changeset would fail to apply:
+//
+//	go run github.com/DylanSpOddball/apply-changes-wrapper/cmd/changeslint -type WeatherReport changes/*.json
+//
+// The type is looked up in the package in the current directory, or the one
+// given with -pkg. Since linting needs the type itself rather than its source,
+// the command generates a program importing the package next to it, runs it
+// with go run and removes it again, so it must be run inside the module the
+// package belongs to.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	pkgPath := flag.String("pkg", "", "import path of the package declaring the type (default the package in the current directory)")
+	typeName := flag.String("type", "", "name of the struct type the changes are applied to")
+	tagName := flag.String("tag", "json", "struct tag the changes are keyed by")
+	flag.Parse()
+
+	if *typeName == "" || flag.NArg() == 0 {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: changeslint [-pkg path] [-tag name] -type Name file.json...\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if *pkgPath == "" {
+		listed, err := exec.Command("go", "list", "-f", "{{.ImportPath}}", ".").Output()
+		if err != nil {
+			log.Fatalf("changeslint: finding the package in the current directory: %v", err)
+		}
+		*pkgPath = strings.TrimSpace(string(listed))
+	}
+
+	source, err := generate(*pkgPath, *typeName, *tagName)
+	if err != nil {
+		log.Fatalf("changeslint: %v", err)
+	}
+
+	// Directories starting with a dot are left out of ./... but are still in
+	// the module, so the program can import its packages
+	dir, err := os.MkdirTemp(".", ".changeslint")
+	if err != nil {
+		log.Fatalf("changeslint: %v", err)
+	}
+	program := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(program, source, 0o644); err != nil {
+		os.RemoveAll(dir)
+		log.Fatalf("changeslint: %v", err)
+	}
+
+	cmd := exec.Command("go", append([]string{"run", program}, flag.Args()...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	err = cmd.Run()
+	os.RemoveAll(dir)
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		log.Fatalf("changeslint: %v", err)
+	}
+}
+
+// generate writes the program linting the files it's given against the type
+func generate(pkgPath, typeName, tagName string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by changeslint; DO NOT EDIT.\n\npackage main\n\n")
+	fmt.Fprintf(&out, "import (\n\t\"os\"\n\t\"reflect\"\n\n")
+	fmt.Fprintf(&out, "\tapplychanges \"github.com/DylanSpOddball/apply-changes-wrapper\"\n")
+	fmt.Fprintf(&out, "\t\"github.com/DylanSpOddball/apply-changes-wrapper/changeslint\"\n")
+	fmt.Fprintf(&out, "\ttarget %q\n)\n\n", pkgPath)
+	fmt.Fprintf(&out, "func main() {\n")
+	fmt.Fprintf(&out, "\ttargetType := reflect.TypeOf((*target.%s)(nil)).Elem()\n", typeName)
+	fmt.Fprintf(&out, "\tos.Exit(changeslint.Run(targetType, os.Args[1:], os.Stdout, applychanges.WithTagName(%q)))\n}\n", tagName)
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+
+	return source, nil
+}
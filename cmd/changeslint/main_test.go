@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestGenerate(t *testing.T) {
+	const want = `// Code generated by changeslint; DO NOT EDIT.
+
+package main
+
+import (
+	"os"
+	"reflect"
+
+	target "example.com/weather/reports"
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+	"github.com/DylanSpOddball/apply-changes-wrapper/changeslint"
+)
+
+func main() {
+	targetType := reflect.TypeOf((*target.WeatherReport)(nil)).Elem()
+	os.Exit(changeslint.Run(targetType, os.Args[1:], os.Stdout, applychanges.WithTagName("db")))
+}
+`
+
+	source, err := generate("example.com/weather/reports", "WeatherReport", "db")
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	if string(source) != want {
+		t.Errorf("generate() =\n%s\nwant\n%s", source, want)
+	}
+
+	if _, err := generate("example.com/weather/reports", "Weather Report", "db"); err == nil {
+		t.Error("generate() error = nil for an invalid type name")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+//go:generate go run ../applygen -type WeatherReport
+
+// example struct with BaseStruct metadata
+type WeatherReport struct {
+	applychanges.BaseStruct
+	City    string `json:"city"`
+	Weather string `json:"weather"`
+}
+
+func NewWeatherReport(createdBy string, city string, weather string) WeatherReport {
+	return WeatherReport{
+		BaseStruct: applychanges.NewBaseStruct(createdBy),
+		City:       city,
+		Weather:    weather,
+	}
+}
+
+func (report WeatherReport) Print() {
+	fmt.Println("City: " + report.City)
+	fmt.Println("Weather: " + report.Weather)
+
+	if report.ModifiedBy == nil {
+		fmt.Println("Last reported by: " + report.CreatedBy)
+	} else {
+		fmt.Println("Last reported by: " + *report.ModifiedBy)
+	}
+}
+
+func main() {
+	report := NewWeatherReport("Dylan", "Clearwater", "Hot and sunny")
+	report.Print()
+	// should print:
+	// City: Clearwater
+	// Weather: Hot and sunny
+	// Last reported by: Dylan
+
+	fmt.Println()
+	fmt.Println("Making changes...")
+	fmt.Println()
+
+	changes := map[string]interface{}{
+		"weather": "Thunderstorms",
+	}
+	modifier := "Mr. Weatherdude"
+	applychanges.ApplyChangesWrapper(changes, modifier, &report)
+	report.Print()
+	// should print:
+	// City: Clearwater
+	// Weather: Thunderstorms
+	// Last reported by: Mr. Weatherdude
+}
@@ -0,0 +1,108 @@
+// Code generated by applygen; DO NOT EDIT.
+
+package main
+
+import (
+	"time"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+func init() {
+	applychanges.RegisterFastDecoder("json", applyChangesWeatherReport)
+}
+
+// applyChangesWeatherReport assigns the changes to a WeatherReport without
+// reflection, or reports false (leaving it untouched) when it can't handle
+// them
+func applyChangesWeatherReport(changes map[string]interface{}, to *WeatherReport) bool {
+	for key, value := range changes {
+		switch key {
+		case "city":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "createdBy":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "createdDts":
+			if _, ok := value.(time.Time); !ok {
+				return false
+			}
+		case "deletedBy":
+			switch value.(type) {
+			case nil, string:
+			default:
+				return false
+			}
+		case "deletedDts":
+			switch value.(type) {
+			case nil, time.Time:
+			default:
+				return false
+			}
+		case "modifiedBy":
+			switch value.(type) {
+			case nil, string:
+			default:
+				return false
+			}
+		case "modifiedDts":
+			switch value.(type) {
+			case nil, time.Time:
+			default:
+				return false
+			}
+		case "weather":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	for key, value := range changes {
+		switch key {
+		case "city":
+			to.City = value.(string)
+		case "createdBy":
+			to.BaseStruct.CreatedBy = value.(string)
+		case "createdDts":
+			to.BaseStruct.CreatedDts = value.(time.Time)
+		case "deletedBy":
+			if value == nil {
+				to.BaseStruct.DeletedBy = nil
+			} else {
+				v := value.(string)
+				to.BaseStruct.DeletedBy = &v
+			}
+		case "deletedDts":
+			if value == nil {
+				to.BaseStruct.DeletedDts = nil
+			} else {
+				v := value.(time.Time)
+				to.BaseStruct.DeletedDts = &v
+			}
+		case "modifiedBy":
+			if value == nil {
+				to.BaseStruct.ModifiedBy = nil
+			} else {
+				v := value.(string)
+				to.BaseStruct.ModifiedBy = &v
+			}
+		case "modifiedDts":
+			if value == nil {
+				to.BaseStruct.ModifiedDts = nil
+			} else {
+				v := value.(time.Time)
+				to.BaseStruct.ModifiedDts = &v
+			}
+		case "weather":
+			to.Weather = value.(string)
+		}
+	}
+
+	return true
+}
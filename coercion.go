@@ -0,0 +1,260 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// coerceGraphQL checks and normalizes the changes (including those of nested
+// structs and the elements of lists) by the input coercion rules of the
+// GraphQL spec, for the built-in types the fields are bound to: Int fields
+// take integers and integral floats, Float fields any number, String and
+// Boolean fields only strings and booleans, enum fields (see RegisterEnum) only
+// their values, and only nullable fields (pointers, slices, maps and
+// Optionals) take null. A list field given a single value gets a list of it
+// (a map for a list is left alone, being the changes of its children).
+// Custom scalars (times, UUIDs, decimals, RegisterScalar types and
+// graphql.Unmarshalers) are left for decoding. Every violation is reported.
+func coerceGraphQL(changes map[string]interface{}, structType reflect.Type, tagName string) error {
+	var errs FieldErrors
+	coerceInputObject(changes, structType, tagName, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Path < errs[j].Path
+	})
+	return redactFieldErrors(errs, structType, tagName)
+}
+
+func coerceInputObject(changes map[string]interface{}, structType reflect.Type, tagName string, prefix string, errs *FieldErrors) {
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, tagName, key)
+		if !ok {
+			continue
+		}
+
+		fieldType, nullable := field.Type, false
+		if reflect.PtrTo(fieldType).Implements(optionalFieldType) {
+			fieldType, nullable = reflect.New(fieldType).Interface().(optionalField).valueType(), true
+		}
+
+		changes[key] = coerceInput(prefix+key, value, fieldType, nullable, tagName, errs)
+	}
+}
+
+// coerceInput coerces a value for a field of type t, adding its violations to
+// errs
+func coerceInput(path string, value interface{}, t reflect.Type, nullable bool, tagName string, errs *FieldErrors) interface{} {
+	if t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Interface:
+		nullable = true
+	}
+
+	fail := func(format string, args ...interface{}) interface{} {
+		*errs = append(*errs, &FieldError{Path: path, Err: fmt.Errorf(format, args...)})
+		return value
+	}
+
+	if value == nil {
+		if !nullable {
+			return fail("Expected non-nullable type \"%s!\" not to be null.", graphQLTypeName(t))
+		}
+		return nil
+	}
+
+	if isCustomScalar(t) {
+		return value
+	}
+
+	input := reflect.ValueOf(value)
+	switch t.Kind() {
+	case reflect.Bool:
+		if input.Kind() != reflect.Bool {
+			return fail("Boolean cannot represent a non boolean value: %s", graphQLValue(value))
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return coerceInt(path, value, t, errs)
+
+	case reflect.Float32, reflect.Float64:
+		if !isNumber(input) {
+			return fail("Float cannot represent non numeric value: %s", graphQLValue(value))
+		}
+
+	case reflect.String:
+		if !isEnumType(t) {
+			if input.Kind() != reflect.String || input.Type() == jsonNumberType {
+				return fail("String cannot represent a non string value: %s", graphQLValue(value))
+			}
+			break
+		}
+
+		if input.Kind() != reflect.String || input.Type() == jsonNumberType {
+			return fail("Enum \"%s\" cannot represent non-string value: %s.", t.Name(), graphQLValue(value))
+		}
+		if err := checkEnum(path, t, value); err != nil {
+			return fail("Value %s does not exist in \"%s\" enum.", strconv.Quote(input.String()), t.Name())
+		}
+
+	case reflect.Struct:
+		nested, ok := value.(map[string]interface{})
+		if !ok && input.Type() == t {
+			break
+		}
+		if !ok {
+			return fail("Expected type \"%s\" to be an object.", graphQLTypeName(t))
+		}
+		coerceInputObject(nested, t, tagName, path+".", errs)
+
+	case reflect.Slice, reflect.Array:
+		elemType := t.Elem()
+		if elemType.Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return value
+		}
+
+		list, ok := value.([]interface{})
+		if _, isMap := value.(map[string]interface{}); !ok && (isMap || input.Kind() == reflect.Slice || input.Kind() == reflect.Array) {
+			break
+		}
+		if !ok {
+			return []interface{}{coerceInput(path+"[0]", value, elemType, false, tagName, errs)}
+		}
+		for i, element := range list {
+			list[i] = coerceInput(fmt.Sprintf("%s[%d]", path, i), element, elemType, false, tagName, errs)
+		}
+	}
+
+	return value
+}
+
+// coerceInt accepts integers, and floats (or json.Numbers) without a
+// fractional part, which become int64s, that fit the field's type
+func coerceInt(path string, value interface{}, t reflect.Type, errs *FieldErrors) interface{} {
+	fail := func(format string, args ...interface{}) interface{} {
+		*errs = append(*errs, &FieldError{Path: path, Err: fmt.Errorf(format, args...)})
+		return value
+	}
+
+	input := reflect.ValueOf(value)
+	var f float64
+	switch {
+	case input.Type() == jsonNumberType:
+		if _, err := input.Interface().(json.Number).Int64(); err == nil {
+			return value
+		}
+		parsed, err := strconv.ParseFloat(input.String(), 64)
+		if err != nil {
+			return fail("Int cannot represent non-integer value: %s", input.String())
+		}
+		f = parsed
+	case input.Kind() >= reflect.Int && input.Kind() <= reflect.Int64:
+		if !fitsInt(t, input.Int()) {
+			return fail("Int cannot represent value out of the range of %s: %v", t.Kind(), value)
+		}
+		return value
+	case input.Kind() >= reflect.Uint && input.Kind() <= reflect.Uintptr:
+		if input.Uint() > math.MaxInt64 || !fitsInt(t, int64(input.Uint())) {
+			return fail("Int cannot represent value out of the range of %s: %v", t.Kind(), value)
+		}
+		return value
+	case input.Kind() == reflect.Float32 || input.Kind() == reflect.Float64:
+		f = input.Float()
+	default:
+		return fail("Int cannot represent non-integer value: %s", graphQLValue(value))
+	}
+
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return fail("Int cannot represent non-integer value: %v", value)
+	}
+	if f < math.MinInt64 || f >= math.MaxInt64 || !fitsInt(t, int64(f)) {
+		return fail("Int cannot represent value out of the range of %s: %v", t.Kind(), value)
+	}
+
+	return int64(f)
+}
+
+// fitsInt reports whether n can be held by the integer type t
+func fitsInt(t reflect.Type, n int64) bool {
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return n >= 0 && !reflect.New(t).Elem().OverflowUint(uint64(n))
+	}
+
+	return !reflect.New(t).Elem().OverflowInt(n)
+}
+
+func isNumber(input reflect.Value) bool {
+	if input.Type() == jsonNumberType {
+		_, err := strconv.ParseFloat(input.String(), 64)
+		return err == nil
+	}
+
+	switch input.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// isEnumType reports whether t is an enum, registered or with a Valid method
+func isEnumType(t reflect.Type) bool {
+	if _, ok := registeredEnum(t); ok {
+		return true
+	}
+
+	return t.Implements(validEnumType) || reflect.PtrTo(t).Implements(validEnumType)
+}
+
+// isCustomScalar reports whether t is decoded by a conversion of the package
+// (or a registered one) rather than as one of GraphQL's built-in types
+func isCustomScalar(t reflect.Type) bool {
+	switch t {
+	case timeType, durationType, uuidType, decimalType:
+		return true
+	}
+
+	return isScalar(t)
+}
+
+// graphQLTypeName names the GraphQL type a field of type t is bound to
+func graphQLTypeName(t reflect.Type) string {
+	if isCustomScalar(t) || isEnumType(t) {
+		return t.Name()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "Boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "Int"
+	case reflect.Float32, reflect.Float64:
+		return "Float"
+	case reflect.String:
+		return "String"
+	}
+
+	return t.Name()
+}
+
+// graphQLValue prints a value the way GraphQL's errors do, quoting strings
+func graphQLValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
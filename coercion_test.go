@@ -0,0 +1,213 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type coercedPriority string
+
+type coercedLevel struct {
+	Depth    int     `json:"depth"`
+	Priority *string `json:"priority"`
+}
+
+type coercedTicket struct {
+	BaseStruct
+	Count    int               `json:"count"`
+	Small    int8              `json:"small"`
+	Ratio    float64           `json:"ratio"`
+	Open     bool              `json:"open"`
+	Title    string            `json:"title"`
+	Note     *string           `json:"note"`
+	Priority coercedPriority   `json:"priority"`
+	Level    coercedLevel      `json:"level"`
+	Tags     []string          `json:"tags"`
+	Scores   []int             `json:"scores"`
+	Due      time.Time         `json:"due"`
+	Pin      int               `json:"pin" apply:"sensitive"`
+	Hint     Optional[int]     `json:"hint"`
+	Labels   map[string]string `json:"labels"`
+}
+
+func TestWithGraphQLCoercion(t *testing.T) {
+	RegisterEnum[coercedPriority]("LOW", "HIGH")
+	defer enums.remove(reflect.TypeOf(coercedPriority("")))
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		check   func(t *testing.T, ticket coercedTicket)
+		wantErr string
+	}{
+		{
+			name:    "integral float",
+			changes: map[string]interface{}{"count": 3.0, "small": float32(-4), "scores": []interface{}{1.0, 2}},
+			check: func(t *testing.T, ticket coercedTicket) {
+				if ticket.Count != 3 || ticket.Small != -4 || !reflect.DeepEqual(ticket.Scores, []int{1, 2}) {
+					t.Errorf("ticket = %+v, want the integral floats applied", ticket)
+				}
+			},
+		},
+		{
+			name:    "integral json.Number",
+			changes: map[string]interface{}{"count": json.Number("7"), "small": json.Number("1e1")},
+			check: func(t *testing.T, ticket coercedTicket) {
+				if ticket.Count != 7 || ticket.Small != 10 {
+					t.Errorf("ticket = %+v, want the numbers applied", ticket)
+				}
+			},
+		},
+		{
+			name:    "fractional float",
+			changes: map[string]interface{}{"count": 1.5},
+			wantErr: "1 error applying changes: 'count': Int cannot represent non-integer value: 1.5",
+		},
+		{
+			name:    "fractional json.Number",
+			changes: map[string]interface{}{"count": json.Number("2.25")},
+			wantErr: "1 error applying changes: 'count': Int cannot represent non-integer value: 2.25",
+		},
+		{
+			name:    "out of range",
+			changes: map[string]interface{}{"small": 300.0},
+			wantErr: "1 error applying changes: 'small': Int cannot represent value out of the range of int8: 300",
+		},
+		{
+			name:    "string int",
+			changes: map[string]interface{}{"count": "3"},
+			wantErr: `1 error applying changes: 'count': Int cannot represent non-integer value: "3"`,
+		},
+		{
+			name:    "float takes ints",
+			changes: map[string]interface{}{"ratio": 2},
+			check: func(t *testing.T, ticket coercedTicket) {
+				if ticket.Ratio != 2 {
+					t.Errorf("ratio = %v, want 2", ticket.Ratio)
+				}
+			},
+		},
+		{
+			name:    "string float",
+			changes: map[string]interface{}{"ratio": "0.5"},
+			wantErr: `1 error applying changes: 'ratio': Float cannot represent non numeric value: "0.5"`,
+		},
+		{
+			name:    "string boolean",
+			changes: map[string]interface{}{"open": "true"},
+			wantErr: `1 error applying changes: 'open': Boolean cannot represent a non boolean value: "true"`,
+		},
+		{
+			name:    "number string",
+			changes: map[string]interface{}{"title": 5},
+			wantErr: "1 error applying changes: 'title': String cannot represent a non string value: 5",
+		},
+		{
+			name:    "enum value",
+			changes: map[string]interface{}{"priority": "HIGH"},
+			check: func(t *testing.T, ticket coercedTicket) {
+				if ticket.Priority != "HIGH" {
+					t.Errorf("priority = %q, want HIGH", ticket.Priority)
+				}
+			},
+		},
+		{
+			name:    "enum mismatch",
+			changes: map[string]interface{}{"priority": "high"},
+			wantErr: `1 error applying changes: 'priority': Value "high" does not exist in "coercedPriority" enum.`,
+		},
+		{
+			name:    "non-string enum",
+			changes: map[string]interface{}{"priority": 1},
+			wantErr: `1 error applying changes: 'priority': Enum "coercedPriority" cannot represent non-string value: 1.`,
+		},
+		{
+			name:    "null on non-nullable field",
+			changes: map[string]interface{}{"count": nil, "level": map[string]interface{}{"depth": nil}},
+			wantErr: `2 errors applying changes: 'count': Expected non-nullable type "Int!" not to be null.; 'level.depth': Expected non-nullable type "Int!" not to be null.`,
+		},
+		{
+			name:    "null on non-nullable scalar",
+			changes: map[string]interface{}{"due": nil},
+			wantErr: `1 error applying changes: 'due': Expected non-nullable type "Time!" not to be null.`,
+		},
+		{
+			name:    "null on nullable fields",
+			changes: map[string]interface{}{"note": nil, "tags": nil, "labels": nil, "hint": nil, "level": map[string]interface{}{"priority": nil}},
+			check: func(t *testing.T, ticket coercedTicket) {
+				if ticket.Note != nil || ticket.Tags != nil || !ticket.Hint.IsNull() {
+					t.Errorf("ticket = %+v, want the nullable fields cleared", ticket)
+				}
+			},
+		},
+		{
+			name:    "single value for a list",
+			changes: map[string]interface{}{"tags": "urgent"},
+			check: func(t *testing.T, ticket coercedTicket) {
+				if !reflect.DeepEqual(ticket.Tags, []string{"urgent"}) {
+					t.Errorf("tags = %v, want [urgent]", ticket.Tags)
+				}
+			},
+		},
+		{
+			name:    "list elements",
+			changes: map[string]interface{}{"scores": []interface{}{1, 2.5, nil}},
+			wantErr: `2 errors applying changes: 'scores[1]': Int cannot represent non-integer value: 2.5; 'scores[2]': Expected non-nullable type "Int!" not to be null.`,
+		},
+		{
+			name:    "input object",
+			changes: map[string]interface{}{"level": "deep"},
+			wantErr: `1 error applying changes: 'level': Expected type "coercedLevel" to be an object.`,
+		},
+		{
+			name:    "sensitive field",
+			changes: map[string]interface{}{"pin": 12.5},
+			wantErr: "1 error applying changes: 'pin': invalid value [REDACTED]",
+		},
+		{
+			name:    "custom scalars are left to decoding",
+			changes: map[string]interface{}{"due": "2024-06-01T12:00:00Z"},
+			check: func(t *testing.T, ticket coercedTicket) {
+				if !ticket.Due.Equal(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)) {
+					t.Errorf("due = %v, want it parsed", ticket.Due)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := coercedTicket{Count: 1, Title: "Leak", Priority: "LOW"}
+			before := ticket
+
+			_, err := ApplyChangesWrapper(tt.changes, "EUA1", &ticket, WithGraphQLCoercion())
+			if tt.wantErr != "" {
+				var fieldErrors FieldErrors
+				if err == nil || !errors.As(err, &fieldErrors) || err.Error() != tt.wantErr {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %s", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(ticket, before) {
+					t.Errorf("ticket = %+v, want nothing applied", ticket)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+			tt.check(t, ticket)
+		})
+	}
+}
+
+func TestWithoutGraphQLCoercion(t *testing.T) {
+	ticket := coercedTicket{}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"count": 1.5}, "EUA1", &ticket); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if ticket.Count != 1 {
+		t.Errorf("count = %d, want the float truncated as before", ticket.Count)
+	}
+}
@@ -0,0 +1,277 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SliceStrategy is how a change to a slice field is combined with the field's
+// current elements, see WithSliceStrategy. Slice fields can also choose their
+// strategy with the apply tag: `apply:"append"` or `apply:"mergekey=id"`.
+type SliceStrategy struct {
+	kind sliceStrategyKind
+	key  string
+}
+
+type sliceStrategyKind int
+
+const (
+	sliceReplace sliceStrategyKind = iota
+	sliceAppend
+	sliceMergeByKey
+)
+
+var (
+	// ReplaceSlice replaces the whole slice with the change (the default)
+	ReplaceSlice = SliceStrategy{kind: sliceReplace}
+
+	// AppendSlice appends the elements of the change to the current ones
+	AppendSlice = SliceStrategy{kind: sliceAppend}
+)
+
+// MergeSliceByKey merges each element of the change into the current element
+// with the same value for key (a tag name of the element struct), leaving the
+// other current elements alone; elements with a new key are appended.
+func MergeSliceByKey(key string) SliceStrategy {
+	return SliceStrategy{kind: sliceMergeByKey, key: key}
+}
+
+func (s SliceStrategy) String() string {
+	switch s.kind {
+	case sliceAppend:
+		return "append"
+	case sliceMergeByKey:
+		return "mergekey=" + s.key
+	}
+
+	return "replace"
+}
+
+// sliceStrategy returns the strategy for the field at path: the configured
+// one, or else the one from its apply tag
+func sliceStrategy(field reflect.StructField, path string, cfg *config) SliceStrategy {
+	if strategy, ok := cfg.sliceStrategies[path]; ok {
+		return strategy
+	}
+
+	for _, option := range strings.Split(field.Tag.Get(applyTagName), ",") {
+		option = strings.TrimSpace(option)
+		switch {
+		case option == "append":
+			return AppendSlice
+		case strings.HasPrefix(option, "mergekey="):
+			return MergeSliceByKey(strings.TrimPrefix(option, "mergekey="))
+		}
+	}
+
+	return ReplaceSlice
+}
+
+// mergeCollections rewrites the changes to slice fields that don't use
+// ReplaceSlice, to child collections keyed by ID (see mergeChildrenByID) and to
+// map fields that are merged (see WithMergeMaps), into the field's complete new
+// value, including the fields of nested structs. It returns the children
+// skipped WithPartialChildren.
+func mergeCollections(changes map[string]interface{}, dest reflect.Value, cfg *config, prefix string) ([]SkippedField, error) {
+	var skipped []SkippedField
+	for key, value := range changes {
+		field, ok := structFieldByTag(dest.Type(), cfg.tagName, key)
+		if !ok {
+			continue
+		}
+
+		current := dest.FieldByIndex(field.Index)
+		path := prefix + fieldKey(field, cfg.tagName)
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			if current.Kind() == reflect.Ptr && !current.IsNil() {
+				current = current.Elem()
+			}
+
+			switch {
+			case current.Kind() == reflect.Struct:
+				nestedSkipped, err := mergeCollections(nested, current, cfg, path+".")
+				if err != nil {
+					return nil, err
+				}
+				skipped = append(skipped, nestedSkipped...)
+			case current.Kind() == reflect.Slice:
+				idField, ok := childIDField(current.Type())
+				if !ok {
+					continue
+				}
+
+				merged, childSkipped, err := mergeChildrenByID(current, nested, idField, cfg, prefix+key)
+				if err != nil {
+					return nil, err
+				}
+				changes[key] = merged
+				skipped = append(skipped, childSkipped...)
+			case current.Kind() == reflect.Map && (cfg.mergeMaps || hasApplyOption(field, "merge")):
+				merged, err := mergeMap(current, nested, cfg)
+				if err != nil {
+					return nil, fmt.Errorf("error merging '%s': %w", path, err)
+				}
+				changes[key] = merged
+			}
+			continue
+		}
+
+		incoming, ok := value.([]interface{})
+		if !ok || current.Kind() != reflect.Slice {
+			continue
+		}
+
+		strategy := sliceStrategy(field, path, cfg)
+		switch strategy.kind {
+		case sliceAppend:
+			merged := make([]interface{}, 0, current.Len()+len(incoming))
+			for i := 0; i < current.Len(); i++ {
+				merged = append(merged, current.Index(i).Interface())
+			}
+			changes[key] = append(merged, incoming...)
+		case sliceMergeByKey:
+			merged, err := mergeSliceByKey(current, incoming, strategy.key, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("error merging '%s': %w", path, err)
+			}
+			changes[key] = merged
+		}
+	}
+
+	return skipped, nil
+}
+
+// mergeSliceByKey decodes each incoming element onto a copy of the current
+// element it matches by key
+func mergeSliceByKey(current reflect.Value, incoming []interface{}, key string, cfg *config) ([]interface{}, error) {
+	elemType := current.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("elements can only be merged by key into structs, not %s", elemType)
+	}
+
+	keyField, ok := structFieldByTag(structType, cfg.tagName, key)
+	if !ok {
+		return nil, fmt.Errorf("%s has no '%s' field to merge by", structType.Name(), key)
+	}
+
+	merged := make([]interface{}, current.Len())
+	indexes := map[string]int{}
+	for i := 0; i < current.Len(); i++ {
+		element := deepCopy(current.Index(i))
+		merged[i] = element.Interface()
+
+		if element.Kind() == reflect.Ptr {
+			if element.IsNil() {
+				continue
+			}
+			element = element.Elem()
+		}
+		indexes[fmt.Sprint(element.FieldByIndex(keyField.Index).Interface())] = i
+	}
+
+	for _, value := range incoming {
+		changes, ok := value.(map[string]interface{})
+		if !ok {
+			merged = append(merged, value)
+			continue
+		}
+
+		elementKey, hasKey := lookupKey(changes, keyField, cfg.tagName)
+		i, exists := indexes[fmt.Sprint(elementKey)]
+		if !hasKey || !exists {
+			merged = append(merged, value)
+			continue
+		}
+
+		// pointer elements are decoded into the copy they point to, as the
+		// decoder would replace the pointer itself
+		if elemType.Kind() == reflect.Ptr {
+			if err := cfg.decode(changes, merged[i]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		element := reflect.New(elemType)
+		element.Elem().Set(reflect.ValueOf(merged[i]))
+		if err := cfg.decode(changes, element.Interface()); err != nil {
+			return nil, err
+		}
+		merged[i] = element.Elem().Interface()
+	}
+
+	return merged, nil
+}
+
+// lookupKey returns the value the changes give for the field
+func lookupKey(changes map[string]interface{}, field reflect.StructField, tagName string) (interface{}, bool) {
+	name := fieldKey(field, tagName)
+	if value, ok := changes[name]; ok {
+		return value, true
+	}
+
+	for key, value := range changes {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
+
+// mergeMap merges the incoming entries into a copy of the current map the way
+// a JSON merge patch does: null removes an entry, struct values are merged into
+// the current value for their key, and anything else replaces it
+func mergeMap(current reflect.Value, incoming map[string]interface{}, cfg *config) (map[string]interface{}, error) {
+	if current.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("only maps with string keys can be merged, not %s", current.Type())
+	}
+
+	merged := make(map[string]interface{}, current.Len()+len(incoming))
+	iter := current.MapRange()
+	for iter.Next() {
+		merged[iter.Key().String()] = deepCopy(iter.Value()).Interface()
+	}
+
+	elemType := current.Type().Elem()
+	for key, value := range incoming {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		existing, exists := merged[key]
+		changes, isMap := value.(map[string]interface{})
+		structType := elemType
+		if structType.Kind() == reflect.Ptr {
+			structType = structType.Elem()
+		}
+		if !exists || !isMap || structType.Kind() != reflect.Struct || reflect.ValueOf(existing).IsZero() {
+			merged[key] = value
+			continue
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			if err := cfg.decode(changes, existing); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		element := reflect.New(elemType)
+		element.Elem().Set(reflect.ValueOf(existing))
+		if err := cfg.decode(changes, element.Interface()); err != nil {
+			return nil, err
+		}
+		merged[key] = element.Elem().Interface()
+	}
+
+	return merged, nil
+}
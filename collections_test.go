@@ -0,0 +1,253 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type collectedReading struct {
+	Sensor string `json:"sensor"`
+	Value  int    `json:"value"`
+	Unit   string `json:"unit"`
+}
+
+type collectedRecord struct {
+	Tags     []string            `json:"tags"`
+	Log      []string            `json:"log" apply:"append"`
+	Readings []collectedReading  `json:"readings" apply:"mergekey=sensor"`
+	Backups  []*collectedReading `json:"backups"`
+}
+
+func newCollectedRecord() collectedRecord {
+	return collectedRecord{
+		Tags:     []string{"a", "b"},
+		Log:      []string{"created"},
+		Readings: []collectedReading{{Sensor: "t1", Value: 20, Unit: "C"}, {Sensor: "t2", Value: 30, Unit: "C"}},
+		Backups:  []*collectedReading{{Sensor: "b1", Value: 1, Unit: "V"}},
+	}
+}
+
+func TestSliceStrategies(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+		want    func(record *collectedRecord)
+		wantErr bool
+	}{
+		{
+			name:    "replaced by default",
+			changes: map[string]interface{}{"tags": []interface{}{"c"}},
+			want:    func(record *collectedRecord) { record.Tags = []string{"c"} },
+		},
+		{
+			name:    "appended by tag",
+			changes: map[string]interface{}{"log": []interface{}{"edited"}},
+			want:    func(record *collectedRecord) { record.Log = []string{"created", "edited"} },
+		},
+		{
+			name:    "appended by option",
+			changes: map[string]interface{}{"tags": []interface{}{"c"}},
+			opts:    []Option{WithSliceStrategy("tags", AppendSlice)},
+			want:    func(record *collectedRecord) { record.Tags = []string{"a", "b", "c"} },
+		},
+		{
+			name:    "option replaces the tag's strategy",
+			changes: map[string]interface{}{"log": []interface{}{"reset"}},
+			opts:    []Option{WithSliceStrategy("log", ReplaceSlice)},
+			want:    func(record *collectedRecord) { record.Log = []string{"reset"} },
+		},
+		{
+			name: "merged by key",
+			changes: map[string]interface{}{"readings": []interface{}{
+				map[string]interface{}{"sensor": "t2", "value": 31},
+				map[string]interface{}{"sensor": "t3", "value": 5, "unit": "F"},
+			}},
+			want: func(record *collectedRecord) {
+				record.Readings = []collectedReading{{Sensor: "t1", Value: 20, Unit: "C"}, {Sensor: "t2", Value: 31, Unit: "C"}, {Sensor: "t3", Value: 5, Unit: "F"}}
+			},
+		},
+		{
+			name:    "pointer elements merged by key",
+			changes: map[string]interface{}{"backups": []interface{}{map[string]interface{}{"sensor": "b1", "value": 2}}},
+			opts:    []Option{WithSliceStrategy("backups", MergeSliceByKey("sensor"))},
+			want: func(record *collectedRecord) {
+				record.Backups = []*collectedReading{{Sensor: "b1", Value: 2, Unit: "V"}}
+			},
+		},
+		{
+			name:    "merged by a missing key",
+			changes: map[string]interface{}{"readings": []interface{}{map[string]interface{}{"value": 1}}},
+			opts:    []Option{WithSliceStrategy("readings", MergeSliceByKey("serial"))},
+			wantErr: true,
+		},
+		{
+			name:    "merged by key into strings",
+			changes: map[string]interface{}{"tags": []interface{}{"c"}},
+			opts:    []Option{WithSliceStrategy("tags", MergeSliceByKey("id"))},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newCollectedRecord()
+			_, err := ApplyChanges(tt.changes, &record, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := newCollectedRecord()
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("record = %+v, want %+v", record, want)
+			}
+		})
+	}
+}
+
+func TestMergeSliceByKeyLeavesCurrentElements(t *testing.T) {
+	record := newCollectedRecord()
+	backup := record.Backups[0]
+	changes := map[string]interface{}{"backups": []interface{}{map[string]interface{}{"sensor": "b1", "value": 2}}}
+	if _, err := ApplyChanges(changes, &record, WithSliceStrategy("backups", MergeSliceByKey("sensor"))); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	if backup.Value != 1 {
+		t.Errorf("original element Value = %d, want it not written through", backup.Value)
+	}
+}
+
+func TestSliceStrategyString(t *testing.T) {
+	tests := []struct {
+		strategy SliceStrategy
+		want     string
+	}{
+		{strategy: ReplaceSlice, want: "replace"},
+		{strategy: AppendSlice, want: "append"},
+		{strategy: MergeSliceByKey("id"), want: "mergekey=id"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.strategy.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+type mappedRecord struct {
+	Labels   map[string]string            `json:"labels"`
+	Sensors  map[string]collectedReading  `json:"sensors"`
+	Pointers map[string]*collectedReading `json:"pointers"`
+	Settings map[string]interface{}       `json:"settings" apply:"merge"`
+	ByIndex  map[int]string               `json:"byIndex"`
+}
+
+func newMappedRecord() mappedRecord {
+	return mappedRecord{
+		Labels:   map[string]string{"owner": "noaa", "region": "south"},
+		Sensors:  map[string]collectedReading{"t1": {Sensor: "t1", Value: 20, Unit: "C"}},
+		Pointers: map[string]*collectedReading{"p1": {Sensor: "p1", Value: 1, Unit: "V"}},
+		Settings: map[string]interface{}{"interval": 60, "enabled": true},
+		ByIndex:  map[int]string{1: "one"},
+	}
+}
+
+func TestWithMergeMaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+		want    func(record *mappedRecord)
+		wantErr bool
+	}{
+		{
+			name:    "replaced by default",
+			changes: map[string]interface{}{"labels": map[string]interface{}{"owner": "nws"}},
+			want:    func(record *mappedRecord) { record.Labels = map[string]string{"owner": "nws"} },
+		},
+		{
+			name:    "merged",
+			changes: map[string]interface{}{"labels": map[string]interface{}{"owner": "nws", "site": "tbw"}},
+			opts:    []Option{WithMergeMaps()},
+			want: func(record *mappedRecord) {
+				record.Labels = map[string]string{"owner": "nws", "region": "south", "site": "tbw"}
+			},
+		},
+		{
+			name:    "null removes an entry",
+			changes: map[string]interface{}{"labels": map[string]interface{}{"region": nil}},
+			opts:    []Option{WithMergeMaps()},
+			want:    func(record *mappedRecord) { record.Labels = map[string]string{"owner": "noaa"} },
+		},
+		{
+			name:    "merged by tag",
+			changes: map[string]interface{}{"settings": map[string]interface{}{"interval": 30}},
+			want:    func(record *mappedRecord) { record.Settings = map[string]interface{}{"interval": 30, "enabled": true} },
+		},
+		{
+			name:    "struct values merged",
+			changes: map[string]interface{}{"sensors": map[string]interface{}{"t1": map[string]interface{}{"value": 21}}},
+			opts:    []Option{WithMergeMaps()},
+			want: func(record *mappedRecord) {
+				record.Sensors = map[string]collectedReading{"t1": {Sensor: "t1", Value: 21, Unit: "C"}}
+			},
+		},
+		{
+			name:    "pointer struct values merged",
+			changes: map[string]interface{}{"pointers": map[string]interface{}{"p1": map[string]interface{}{"value": 2}}},
+			opts:    []Option{WithMergeMaps()},
+			want: func(record *mappedRecord) {
+				record.Pointers = map[string]*collectedReading{"p1": {Sensor: "p1", Value: 2, Unit: "V"}}
+			},
+		},
+		{
+			name:    "new struct value",
+			changes: map[string]interface{}{"sensors": map[string]interface{}{"t2": map[string]interface{}{"sensor": "t2", "value": 5}}},
+			opts:    []Option{WithMergeMaps()},
+			want: func(record *mappedRecord) {
+				record.Sensors["t2"] = collectedReading{Sensor: "t2", Value: 5}
+			},
+		},
+		{
+			name:    "non-string keys",
+			changes: map[string]interface{}{"byIndex": map[string]interface{}{"2": "two"}},
+			opts:    []Option{WithMergeMaps()},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newMappedRecord()
+			_, err := ApplyChanges(tt.changes, &record, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := newMappedRecord()
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("record = %+v, want %+v", record, want)
+			}
+		})
+	}
+}
+
+func TestWithMergeMapsLeavesCurrentValues(t *testing.T) {
+	record := newMappedRecord()
+	pointer := record.Pointers["p1"]
+	changes := map[string]interface{}{"pointers": map[string]interface{}{"p1": map[string]interface{}{"value": 2}}}
+	if _, err := ApplyChanges(changes, &record, WithMergeMaps()); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	if pointer.Value != 1 || record.Pointers["p1"] == pointer {
+		t.Errorf("original value = %+v, want a merged copy", pointer)
+	}
+}
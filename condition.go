@@ -0,0 +1,231 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrConditionNotMet is returned (wrapped in a ConditionError) when the target
+// doesn't meet the condition given with WithCondition
+var ErrConditionNotMet = errors.New("condition not met")
+
+// Condition is a rule the target must meet for changes to be applied to it,
+// see WithCondition. It is built with FieldEquals, FieldIn, Not, And and Or,
+// and marshals to JSON (and back) so it can be queued along with the changes:
+//
+//	applychanges.Or(
+//		applychanges.FieldEquals("status", "DRAFT"),
+//		applychanges.FieldIn("status", "SUBMITTED", "IN_REVIEW"),
+//	)
+type Condition struct {
+	// Op is one of "equals", "in", "not", "and" and "or"
+	Op string `json:"op"`
+
+	// Field is the path of the field "equals" and "in" read, by tag name
+	// (e.g. "status" or "details.source")
+	Field string `json:"field,omitempty"`
+
+	// Value is what "equals" compares the field with, and Values what "in"
+	// does. They are decoded into the field's type the way changes are, so
+	// e.g. a time.Time field can be compared with an RFC3339 string.
+	Value  interface{}   `json:"value,omitempty"`
+	Values []interface{} `json:"values,omitempty"`
+
+	// Conditions are what "not", "and" and "or" combine
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+const (
+	conditionEquals = "equals"
+	conditionIn     = "in"
+	conditionNot    = "not"
+	conditionAnd    = "and"
+	conditionOr     = "or"
+)
+
+// FieldEquals is met when the field at path equals value
+func FieldEquals(path string, value interface{}) Condition {
+	return Condition{Op: conditionEquals, Field: path, Value: value}
+}
+
+// FieldIn is met when the field at path equals any of the values
+func FieldIn(path string, values ...interface{}) Condition {
+	return Condition{Op: conditionIn, Field: path, Values: values}
+}
+
+// Not is met when the condition isn't
+func Not(condition Condition) Condition {
+	return Condition{Op: conditionNot, Conditions: []Condition{condition}}
+}
+
+// And is met when all of the conditions are
+func And(conditions ...Condition) Condition {
+	return Condition{Op: conditionAnd, Conditions: conditions}
+}
+
+// Or is met when any of the conditions is
+func Or(conditions ...Condition) Condition {
+	return Condition{Op: conditionOr, Conditions: conditions}
+}
+
+func (c Condition) String() string {
+	switch c.Op {
+	case conditionEquals:
+		return fmt.Sprintf("%s = %v", c.Field, c.Value)
+	case conditionIn:
+		return fmt.Sprintf("%s in %v", c.Field, c.Values)
+	case conditionNot:
+		if len(c.Conditions) == 1 {
+			return fmt.Sprintf("not (%s)", c.Conditions[0])
+		}
+	case conditionAnd, conditionOr:
+		clauses := make([]string, len(c.Conditions))
+		for i, condition := range c.Conditions {
+			clauses[i] = condition.String()
+		}
+		return "(" + strings.Join(clauses, " "+c.Op+" ") + ")"
+	}
+
+	return fmt.Sprintf("invalid %q condition", c.Op)
+}
+
+// ConditionError is returned when the target doesn't meet a Condition; it
+// unwraps to ErrConditionNotMet
+type ConditionError struct {
+	// Clause is the part of the condition that failed: the first failing
+	// condition of an And, or else the whole Not or Or that did
+	Clause Condition
+}
+
+func (e *ConditionError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrConditionNotMet, e.Clause)
+}
+
+func (e *ConditionError) Unwrap() error {
+	return ErrConditionNotMet
+}
+
+// checkConditions evaluates every condition against the target's current
+// field values, failing with a ConditionError for the first one it doesn't
+// meet
+func checkConditions(to interface{}, cfg *config) error {
+	target, ok := targetStruct(to)
+	if !ok {
+		return fmt.Errorf("conditions can only be checked against structs, not %T", to)
+	}
+
+	for _, condition := range cfg.conditions {
+		failed, err := condition.failingClause(target, cfg)
+		if err != nil {
+			return err
+		}
+		if failed != nil {
+			return &ConditionError{Clause: *failed}
+		}
+	}
+
+	return nil
+}
+
+// failingClause returns the clause of the condition the target fails, or nil
+// when it meets the condition
+func (c Condition) failingClause(target reflect.Value, cfg *config) (*Condition, error) {
+	switch c.Op {
+	case conditionEquals, conditionIn:
+		values := c.Values
+		if c.Op == conditionEquals {
+			values = []interface{}{c.Value}
+		}
+
+		met, err := fieldIn(target, c.Field, values, cfg)
+		if err != nil || met {
+			return nil, err
+		}
+		return &c, nil
+	case conditionNot:
+		if len(c.Conditions) != 1 {
+			return nil, fmt.Errorf("a not condition takes one condition, not %d", len(c.Conditions))
+		}
+
+		failed, err := c.Conditions[0].failingClause(target, cfg)
+		if err != nil || failed != nil {
+			return nil, err
+		}
+		return &c, nil
+	case conditionAnd:
+		for _, condition := range c.Conditions {
+			if failed, err := condition.failingClause(target, cfg); err != nil || failed != nil {
+				return failed, err
+			}
+		}
+		return nil, nil
+	case conditionOr:
+		for _, condition := range c.Conditions {
+			failed, err := condition.failingClause(target, cfg)
+			if err != nil {
+				return nil, err
+			}
+			if failed == nil {
+				return nil, nil
+			}
+		}
+		return &c, nil
+	}
+
+	return nil, fmt.Errorf("unknown condition %q", c.Op)
+}
+
+// fieldIn reports whether the field at path equals any of the values, decoded
+// into its type
+func fieldIn(target reflect.Value, path string, values []interface{}, cfg *config) (bool, error) {
+	current, err := fieldAtPath(target, path, cfg.tagName)
+	if err != nil {
+		return false, err
+	}
+
+	for _, value := range values {
+		if value == nil || current == nil {
+			if value == nil && current == nil {
+				return true, nil
+			}
+			continue
+		}
+
+		decoded := reflect.New(reflect.TypeOf(current))
+		if err := cfg.decode(value, decoded.Interface()); err != nil {
+			return false, fmt.Errorf("condition value %v for '%s': %w", value, path, err)
+		}
+		if reflect.DeepEqual(decoded.Elem().Interface(), current) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// fieldAtPath reads the field at a path of tag names, dereferencing pointers;
+// a nil pointer along the way reads as nil
+func fieldAtPath(target reflect.Value, path string, tagName string) (interface{}, error) {
+	value := target
+	for _, key := range strings.Split(path, ".") {
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return nil, nil
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("condition field '%s' is not a field", path)
+		}
+
+		field, ok := structFieldByTag(value.Type(), tagName, key)
+		if !ok {
+			return nil, fmt.Errorf("condition field '%s' is not a field", path)
+		}
+		value = value.FieldByIndex(field.Index)
+	}
+
+	return fieldChangeValue(value), nil
+}
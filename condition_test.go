@@ -0,0 +1,133 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type conditionRecord struct {
+	Status   string         `json:"status"`
+	Priority int            `json:"priority"`
+	DueDts   *time.Time     `json:"dueDts"`
+	Details  *nestedDetails `json:"details"`
+}
+
+func TestWithCondition(t *testing.T) {
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	record := conditionRecord{Status: "DRAFT", Priority: 2, DueDts: &due, Details: &nestedDetails{Source: "radar"}}
+
+	equalsSubmitted := FieldEquals("status", "SUBMITTED")
+	tests := []struct {
+		name      string
+		condition Condition
+		failing   *Condition
+	}{
+		{name: "equals", condition: FieldEquals("status", "DRAFT")},
+		{name: "equals fails", condition: equalsSubmitted, failing: &equalsSubmitted},
+		{name: "equals converted number", condition: FieldEquals("priority", 2.0)},
+		{name: "equals converted time", condition: FieldEquals("dueDts", "2024-06-01T00:00:00Z")},
+		{name: "equals nested", condition: FieldEquals("details.source", "radar")},
+		{name: "in", condition: FieldIn("status", "DRAFT", "SUBMITTED")},
+		{name: "in fails", condition: FieldIn("status", "SUBMITTED", "CLOSED"), failing: &Condition{Op: "in", Field: "status", Values: []interface{}{"SUBMITTED", "CLOSED"}}},
+		{name: "not", condition: Not(equalsSubmitted)},
+		{name: "not fails", condition: Not(FieldEquals("status", "DRAFT")), failing: &Condition{Op: "not", Conditions: []Condition{FieldEquals("status", "DRAFT")}}},
+		{name: "and", condition: And(FieldEquals("status", "DRAFT"), FieldIn("priority", 1, 2))},
+		{name: "and fails on the failing clause", condition: And(FieldEquals("status", "DRAFT"), equalsSubmitted), failing: &equalsSubmitted},
+		{name: "or", condition: Or(equalsSubmitted, FieldEquals("status", "DRAFT"))},
+		{name: "or fails as a whole", condition: Or(equalsSubmitted, FieldEquals("priority", 3)), failing: &Condition{Op: "or", Conditions: []Condition{equalsSubmitted, FieldEquals("priority", 3)}}},
+		{name: "zero nested value", condition: FieldEquals("details.level", 0)},
+		{name: "null", condition: Not(FieldEquals("dueDts", nil))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := record
+			_, err := ApplyChanges(map[string]interface{}{"priority": 5}, &target, WithCondition(tt.condition))
+
+			if tt.failing == nil {
+				if err != nil {
+					t.Fatalf("ApplyChanges() error = %v", err)
+				}
+				if target.Priority != 5 {
+					t.Errorf("Priority = %d, want the changes applied", target.Priority)
+				}
+				return
+			}
+
+			var conditionErr *ConditionError
+			if !errors.Is(err, ErrConditionNotMet) || !errors.As(err, &conditionErr) {
+				t.Fatalf("ApplyChanges() error = %v, want ErrConditionNotMet", err)
+			}
+			if !reflect.DeepEqual(conditionErr.Clause, *tt.failing) {
+				t.Errorf("Clause = %s, want %s", conditionErr.Clause, *tt.failing)
+			}
+			if target.Priority != 2 {
+				t.Errorf("Priority = %d, want the target untouched", target.Priority)
+			}
+		})
+	}
+}
+
+func TestConditionRoundTrip(t *testing.T) {
+	condition := And(
+		Or(FieldEquals("status", "DRAFT"), FieldIn("status", "SUBMITTED", "IN_REVIEW")),
+		Not(FieldEquals("priority", 1)),
+	)
+
+	encoded, err := json.Marshal(condition)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	const want = `{"op":"and","conditions":[` +
+		`{"op":"or","conditions":[{"op":"equals","field":"status","value":"DRAFT"},{"op":"in","field":"status","values":["SUBMITTED","IN_REVIEW"]}]},` +
+		`{"op":"not","conditions":[{"op":"equals","field":"priority","value":1}]}]}`
+	if string(encoded) != want {
+		t.Errorf("json.Marshal() = %s, want %s", encoded, want)
+	}
+
+	var decoded Condition
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		record conditionRecord
+		met    bool
+	}{
+		{conditionRecord{Status: "IN_REVIEW", Priority: 2}, true},
+		{conditionRecord{Status: "IN_REVIEW", Priority: 1}, false},
+		{conditionRecord{Status: "CLOSED", Priority: 2}, false},
+	} {
+		record := tt.record
+		_, err := ApplyChanges(map[string]interface{}{"priority": 3}, &record, WithCondition(decoded))
+		if met := err == nil; met != tt.met {
+			t.Errorf("%+v: met = %v (%v), want %v", tt.record, met, err, tt.met)
+		}
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+	}{
+		{name: "unknown field", condition: FieldEquals("bogus", 1)},
+		{name: "unknown op", condition: Condition{Op: "xor"}},
+		{name: "value of the wrong type", condition: FieldEquals("priority", "high")},
+		{name: "not without a condition", condition: Condition{Op: "not"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := conditionRecord{}
+			_, err := ApplyChanges(map[string]interface{}{"priority": 3}, &record, WithCondition(tt.condition))
+			if err == nil || errors.Is(err, ErrConditionNotMet) {
+				t.Errorf("ApplyChanges() error = %v, want an invalid condition error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// ErrNoPrincipal is returned by ApplyChangesCtx when no principal can be found
+// for its context
+var ErrNoPrincipal = errors.New("no principal in context to apply the changes as")
+
+// PrincipalExtractor finds the authenticated principal of a request from its
+// context, reporting false when the request isn't authenticated
+type PrincipalExtractor func(ctx context.Context) (Principal, bool)
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the principal, for
+// ApplyChangesCtx to apply changes as
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by ContextWithPrincipal (or
+// PrincipalMiddleware)
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+// PrincipalMiddleware is gqlgen field middleware storing the principal found by
+// extract in the context of every resolver (see ContextWithPrincipal), so
+// resolvers can call ApplyChangesCtx without threading a modifier through.
+// Requests extract can't find a principal for resolve as usual.
+//
+//	srv.AroundFields(applychanges.PrincipalMiddleware(auth.Principal))
+func PrincipalMiddleware(extract PrincipalExtractor) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		if _, ok := PrincipalFromContext(ctx); !ok {
+			if principal, ok := extract(ctx); ok {
+				ctx = ContextWithPrincipal(ctx, principal)
+			}
+		}
+
+		return next(ctx)
+	}
+}
+
+// ApplyChangesCtx applies the changes like ApplyChangesAs, as the principal
+// carried by ctx: the one found by WithPrincipalExtractor if given, otherwise
+// the one stored by ContextWithPrincipal or PrincipalMiddleware. It fails with
+// ErrNoPrincipal when there is none. ctx is otherwise handled as by
+// ApplyChangesWrapperCtx.
+func ApplyChangesCtx(ctx context.Context, changes map[string]interface{}, to interface{}, opts ...Option) (ApplyResult, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+	cfg.ctx = ctx
+
+	extract := PrincipalFromContext
+	if cfg.principalExtractor != nil {
+		extract = cfg.principalExtractor
+	}
+
+	principal, ok := extract(ctx)
+	if !ok {
+		return ApplyResult{}, ErrNoPrincipal
+	}
+
+	cfg.modifier = &principal.ID
+	cfg.principal = &principal
+
+	return applyChanges(changes, to, cfg)
+}
+
+// ApplyChangesWrapperCtx is ApplyChangesWrapper under ctx: the apply gives up
+// with ctx's error if it is cancelled (or past its deadline) before the target
+// is changed, and ctx is passed on to the AuditSink, to targets implementing
+// BeforeApplierContext or AfterApplierContext and to a ContextValidator. It
+// takes precedence over WithContext.
+func ApplyChangesWrapperCtx(ctx context.Context, changes map[string]interface{}, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	return ApplyChangesWrapper(changes, modifier, to, append(opts, WithContext(ctx))...)
+}
+
+// ApplyChangesAsCtx is ApplyChangesAs under ctx, see ApplyChangesWrapperCtx
+func ApplyChangesAsCtx(ctx context.Context, changes map[string]interface{}, principal Principal, to interface{}, opts ...Option) (ApplyResult, error) {
+	return ApplyChangesAs(changes, principal, to, append(opts, WithContext(ctx))...)
+}
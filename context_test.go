@@ -0,0 +1,195 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestApplyChangesCtx(t *testing.T) {
+	ada := Principal{ID: "EUA1", Name: "Ada", Role: "admin"}
+	bob := Principal{ID: "EUA2", Name: "Bob"}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		opts    []Option
+		want    *Principal
+		wantErr error
+	}{
+		{
+			name: "stored principal",
+			ctx:  ContextWithPrincipal(context.Background(), ada),
+			want: &ada,
+		},
+		{
+			name: "extractor",
+			ctx:  ContextWithPrincipal(context.Background(), ada),
+			opts: []Option{WithPrincipalExtractor(func(context.Context) (Principal, bool) { return bob, true })},
+			want: &bob,
+		},
+		{
+			name:    "extractor finds none",
+			ctx:     ContextWithPrincipal(context.Background(), ada),
+			opts:    []Option{WithPrincipalExtractor(func(context.Context) (Principal, bool) { return Principal{}, false })},
+			wantErr: ErrNoPrincipal,
+		},
+		{
+			name:    "no principal",
+			ctx:     context.Background(),
+			wantErr: ErrNoPrincipal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := nestedRecord{}
+			_, err := ApplyChangesCtx(tt.ctx, map[string]interface{}{"name": "Tampa"}, &record, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyChangesCtx() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if record.Name != "" {
+					t.Errorf("record = %+v, want it untouched", record)
+				}
+				return
+			}
+
+			if record.ModifiedBy == nil || *record.ModifiedBy != tt.want.ID || record.ModifiedByPrincipal == nil || *record.ModifiedByPrincipal != *tt.want {
+				t.Errorf("modified by %v (%+v), want %+v", record.ModifiedBy, record.ModifiedByPrincipal, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrincipalMiddleware(t *testing.T) {
+	ada := Principal{ID: "EUA1", Name: "Ada"}
+	bob := Principal{ID: "EUA2", Name: "Bob"}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		extract PrincipalExtractor
+		want    *Principal
+	}{
+		{
+			name:    "extracted",
+			ctx:     context.Background(),
+			extract: func(context.Context) (Principal, bool) { return ada, true },
+			want:    &ada,
+		},
+		{
+			name:    "already stored",
+			ctx:     ContextWithPrincipal(context.Background(), bob),
+			extract: func(context.Context) (Principal, bool) { return ada, true },
+			want:    &bob,
+		},
+		{
+			name:    "unauthenticated",
+			ctx:     context.Background(),
+			extract: func(context.Context) (Principal, bool) { return Principal{}, false },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resolved context.Context
+			result, err := PrincipalMiddleware(tt.extract)(tt.ctx, func(ctx context.Context) (interface{}, error) {
+				resolved = ctx
+				return "resolved", nil
+			})
+			if err != nil || result != "resolved" {
+				t.Fatalf("middleware = %v, %v, want the resolver's result", result, err)
+			}
+
+			principal, ok := PrincipalFromContext(resolved)
+			if ok != (tt.want != nil) || (ok && principal != *tt.want) {
+				t.Errorf("PrincipalFromContext() = %+v, %v, want %+v", principal, ok, tt.want)
+			}
+		})
+	}
+}
+
+// cancellingRecord cancels the apply's context from its BeforeApply hook
+type cancellingRecord struct {
+	Name string `json:"name"`
+
+	cancel context.CancelFunc
+}
+
+func (r *cancellingRecord) BeforeApplyContext(context.Context, map[string]interface{}) error {
+	r.cancel()
+	return nil
+}
+
+// contextSink records the context value of every entry's apply
+type contextSink struct {
+	values []interface{}
+}
+
+func (s *contextSink) Record(ctx context.Context, _ AuditEntry) error {
+	s.values = append(s.values, ctx.Value(validationKey{}))
+	return nil
+}
+
+func TestApplyChangesWrapperCtx(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), 0)
+	defer cancelExpired()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantName string
+		wantErr  error
+	}{
+		{name: "live", ctx: context.Background(), wantName: "Tampa"},
+		{name: "cancelled", ctx: cancelled, wantName: "Miami", wantErr: context.Canceled},
+		{name: "past its deadline", ctx: expired, wantName: "Miami", wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := nestedRecord{Name: "Miami"}
+			_, err := ApplyChangesWrapperCtx(tt.ctx, map[string]interface{}{"name": "Tampa"}, "EUA1", &record)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyChangesWrapperCtx() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if record.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", record.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestApplyCancelledMidway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	record := cancellingRecord{Name: "Miami", cancel: cancel}
+	if _, err := ApplyChangesWrapperCtx(ctx, map[string]interface{}{"name": "Tampa"}, "EUA1", &record); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ApplyChangesWrapperCtx() error = %v, want context.Canceled", err)
+	}
+	if record.Name != "Miami" {
+		t.Errorf("Name = %q, want the target left alone", record.Name)
+	}
+}
+
+func TestApplyCtxPassedOn(t *testing.T) {
+	ctx := context.WithValue(context.Background(), validationKey{}, "request")
+	sink := &contextSink{}
+
+	record := nestedRecord{}
+	if _, err := ApplyChangesWrapperCtx(ctx, map[string]interface{}{"name": "Tampa"}, "EUA1", &record, WithAuditSink(sink), WithContext(context.Background())); err != nil {
+		t.Fatalf("ApplyChangesWrapperCtx() error = %v", err)
+	}
+	if _, err := ApplyChangesAsCtx(ctx, map[string]interface{}{"name": "Tampa"}, Principal{ID: "EUA1"}, &record, WithAuditSink(sink)); err != nil {
+		t.Fatalf("ApplyChangesAsCtx() error = %v", err)
+	}
+
+	if len(sink.values) != 2 || sink.values[0] != "request" || sink.values[1] != "request" {
+		t.Errorf("sink saw %v, want the apply's context taking precedence over WithContext", sink.values)
+	}
+}
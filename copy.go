@@ -0,0 +1,62 @@
+package applychanges
+
+import "reflect"
+
+// deepCopy returns a copy of value that shares no pointers, slices or maps with
+// it, so mutating one leaves the other alone. Strings are immutable, so a copy
+// shares their bytes however large they are. Unexported struct fields are
+// copied shallowly, since reflection can't set them individually.
+func deepCopy(value reflect.Value) reflect.Value {
+	if !value.IsValid() {
+		return value
+	}
+
+	copied := reflect.New(value.Type()).Elem()
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return copied
+		}
+		pointee := reflect.New(value.Type().Elem())
+		pointee.Elem().Set(deepCopy(value.Elem()))
+		copied.Set(pointee)
+	case reflect.Interface:
+		if value.IsNil() {
+			return copied
+		}
+		copied.Set(deepCopy(value.Elem()))
+	case reflect.Struct:
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if field := copied.Field(i); field.CanSet() {
+				field.Set(deepCopy(value.Field(i)))
+			}
+		}
+	case reflect.Slice:
+		if value.IsNil() {
+			return copied
+		}
+		copied.Set(reflect.MakeSlice(value.Type(), value.Len(), value.Len()))
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopy(value.Index(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopy(value.Index(i)))
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			return copied
+		}
+		copied.Set(reflect.MakeMapWithSize(value.Type(), value.Len()))
+		iter := value.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+	default:
+		copied.Set(value)
+	}
+
+	return copied
+}
@@ -0,0 +1,64 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type copiedRecord struct {
+	Name     string
+	Details  *nestedDetails
+	Tags     []string
+	Labels   map[string]string
+	Any      interface{}
+	Sizes    [2]int
+	internal *int
+}
+
+func TestDeepCopy(t *testing.T) {
+	internal := 7
+	original := copiedRecord{
+		Name:     "Tampa",
+		Details:  &nestedDetails{Source: "survey", Level: 2},
+		Tags:     []string{"east"},
+		Labels:   map[string]string{"a": "1"},
+		Any:      []int{1},
+		Sizes:    [2]int{1, 2},
+		internal: &internal,
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(copied *copiedRecord)
+	}{
+		{name: "pointer", mutate: func(copied *copiedRecord) { copied.Details.Level = 9 }},
+		{name: "slice", mutate: func(copied *copiedRecord) { copied.Tags[0] = "west" }},
+		{name: "map", mutate: func(copied *copiedRecord) { copied.Labels["a"] = "2" }},
+		{name: "interface", mutate: func(copied *copiedRecord) { copied.Any.([]int)[0] = 2 }},
+		{name: "array", mutate: func(copied *copiedRecord) { copied.Sizes[0] = 5 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copied := deepCopy(reflect.ValueOf(original)).Interface().(copiedRecord)
+			if !reflect.DeepEqual(copied, original) {
+				t.Fatalf("deepCopy() = %+v, want %+v", copied, original)
+			}
+
+			tt.mutate(&copied)
+			if original.Details.Level != 2 || original.Tags[0] != "east" || original.Labels["a"] != "1" || original.Any.([]int)[0] != 1 || original.Sizes[0] != 1 {
+				t.Errorf("mutating the copy changed the original to %+v", original)
+			}
+		})
+	}
+
+	copied := deepCopy(reflect.ValueOf(original)).Interface().(copiedRecord)
+	if copied.internal != original.internal {
+		t.Error("deepCopy() copied an unexported field deeply, want it shallow")
+	}
+
+	var nilRecord *copiedRecord
+	if got := deepCopy(reflect.ValueOf(nilRecord)); !got.IsNil() {
+		t.Errorf("deepCopy(nil) = %v, want nil", got)
+	}
+}
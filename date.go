@@ -0,0 +1,212 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DateLayout is the layout civil dates are read and written in
+const DateLayout = "2006-01-02"
+
+// Date is a civil date (a birthday, a report date) rather than an instant, so
+// it never shifts across time zones. It is encoded as "2006-01-02" in JSON and
+// GraphQL, and implements graphql.Unmarshaler so changes can set it from a
+// date-only string. The zero Date, no date at all, is encoded as null (or an
+// empty string as text), and decoded from either.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ParseDate parses a "2006-01-02" string into a Date
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+
+	return DateOf(t), nil
+}
+
+// DateOf returns the date t falls on in its own location
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{Year: year, Month: month, Day: day}
+}
+
+// In returns midnight at the start of the date in loc
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	if d.IsZero() {
+		return []byte{}, nil
+	}
+
+	return []byte(d.String()), nil
+}
+
+func (d *Date) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Date{}
+		return nil
+	}
+
+	parsed, err := ParseDate(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Date{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return d.UnmarshalText([]byte(s))
+}
+
+// MarshalGQL implements graphql.Marshaler
+func (d Date) MarshalGQL(w io.Writer) {
+	if d.IsZero() {
+		_, _ = io.WriteString(w, "null")
+		return
+	}
+
+	_, _ = io.WriteString(w, strconv.Quote(d.String()))
+}
+
+// UnmarshalGQL implements graphql.Unmarshaler
+func (d *Date) UnmarshalGQL(v interface{}) error {
+	switch typed := v.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case string:
+		return d.UnmarshalText([]byte(typed))
+	case Date:
+		*d = typed
+		return nil
+	case time.Time:
+		*d = DateOf(typed)
+		return nil
+	}
+
+	return fmt.Errorf("expected a %s date string, got %T", DateLayout, v)
+}
+
+// applyAsTagName is the struct tag that changes how a field's values are
+// interpreted; `applyas:"date"` on a time.Time or *time.Time field means it
+// holds a civil date, parsed date-only at UTC midnight, and `applyas:"time"`
+// that it holds a time of day, parsed time-only on January 1st of year 0 at
+// UTC (as time.Parse does)
+const applyAsTagName = "applyas"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isDateOnly reports whether the field is tagged `applyas:"date"`
+func isDateOnly(field reflect.StructField) bool {
+	return field.Tag.Get(applyAsTagName) == "date"
+}
+
+// dateOnlyValue returns the Date a time.Time value of an `applyas:"date"`
+// field stands for, so it renders date-only, and other values as they are
+func dateOnlyValue(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return DateOf(t)
+	}
+
+	return value
+}
+
+// parseTaggedDates replaces the string values of `applyas:"date"` and
+// `applyas:"time"` fields (at any nesting depth) with the time.Time they
+// stand for, before the generic RFC3339 hook gets a chance to read them as
+// instants. time.Time values are truncated the same way, so an instant never
+// carries its zone into a date.
+func parseTaggedDates(changes map[string]interface{}, structType reflect.Type, tagName string) error {
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, tagName, key)
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if typed, ok := value.(map[string]interface{}); ok {
+			if fieldType.Kind() != reflect.Struct {
+				continue
+			}
+
+			if err := parseTaggedDates(typed, fieldType, tagName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if fieldType != timeType {
+			continue
+		}
+
+		switch field.Tag.Get(applyAsTagName) {
+		case "date":
+			switch typed := value.(type) {
+			case string:
+				date, err := ParseDate(typed)
+				if err != nil {
+					return fmt.Errorf("error decoding '%s': expected a %s date: %s", key, DateLayout, err)
+				}
+				changes[key] = date.In(time.UTC)
+			case time.Time:
+				changes[key] = DateOf(typed).In(time.UTC)
+			}
+		case "time":
+			switch typed := value.(type) {
+			case string:
+				timeOfDay, err := ParseTimeOfDay(typed)
+				if err != nil {
+					return fmt.Errorf("error decoding '%s': expected a %s time: %s", key, TimeOfDayLayout, err)
+				}
+				changes[key] = timeOfDay.On(Date{Month: time.January, Day: 1}, time.UTC)
+			case time.Time:
+				changes[key] = TimeOfDayOf(typed).On(Date{Month: time.January, Day: 1}, time.UTC)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package applychanges
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type datedReport struct {
+	BaseStruct
+	ReportDate Date       `json:"reportDate"`
+	DueDate    *Date      `json:"dueDate"`
+	BirthDate  time.Time  `json:"birthDate" applyas:"date"`
+	ReviewDate *time.Time `json:"reviewDate" applyas:"date"`
+}
+
+func TestDateJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		date Date
+		json string
+	}{
+		{name: "date", date: Date{Year: 2024, Month: time.June, Day: 1}, json: `"2024-06-01"`},
+		{name: "early year", date: Date{Year: 12, Month: time.January, Day: 31}, json: `"0012-01-31"`},
+		{name: "zero", date: Date{}, json: `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := json.Marshal(tt.date)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(encoded) != tt.json {
+				t.Errorf("json.Marshal() = %s, want %s", encoded, tt.json)
+			}
+
+			decoded := Date{Year: 1999, Month: time.December, Day: 31}
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if decoded != tt.date {
+				t.Errorf("json.Unmarshal() = %v, want %v", decoded, tt.date)
+			}
+
+			var gql bytes.Buffer
+			tt.date.MarshalGQL(&gql)
+			if gql.String() != tt.json {
+				t.Errorf("MarshalGQL() = %s, want %s", gql.String(), tt.json)
+			}
+
+			text, _ := tt.date.MarshalText()
+			var fromText Date
+			if err := fromText.UnmarshalText(text); err != nil || fromText != tt.date {
+				t.Errorf("text round trip of %q = %v (%v), want %v", text, fromText, err, tt.date)
+			}
+		})
+	}
+}
+
+func TestApplyDates(t *testing.T) {
+	june1 := Date{Year: 2024, Month: time.June, Day: 1}
+	june1UTC := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	tokyo := time.FixedZone("Tokyo", 9*60*60)
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		check   func(t *testing.T, report datedReport)
+		wantErr string
+	}{
+		{
+			name:    "Date from a string",
+			changes: map[string]interface{}{"reportDate": "2024-06-01", "dueDate": "2024-06-01"},
+			check: func(t *testing.T, report datedReport) {
+				if report.ReportDate != june1 || report.DueDate == nil || *report.DueDate != june1 {
+					t.Errorf("dates = %v, %v, want %v", report.ReportDate, report.DueDate, june1)
+				}
+			},
+		},
+		{
+			name:    "Date cleared",
+			changes: map[string]interface{}{"reportDate": nil, "dueDate": nil},
+			check: func(t *testing.T, report datedReport) {
+				if !report.ReportDate.IsZero() || report.DueDate != nil {
+					t.Errorf("dates = %v, %v, want them cleared", report.ReportDate, report.DueDate)
+				}
+			},
+		},
+		{
+			name:    "tagged time.Time from a string",
+			changes: map[string]interface{}{"birthDate": "2024-06-01", "reviewDate": "2024-06-01"},
+			check: func(t *testing.T, report datedReport) {
+				if !report.BirthDate.Equal(june1UTC) || report.BirthDate.Location() != time.UTC || report.ReviewDate == nil || !report.ReviewDate.Equal(june1UTC) {
+					t.Errorf("dates = %v, %v, want UTC midnight of %v", report.BirthDate, report.ReviewDate, june1)
+				}
+			},
+		},
+		{
+			// midnight in Tokyo is still May 31st in UTC, which an instant
+			// would shift the date to
+			name:    "tagged time.Time from an instant in another zone",
+			changes: map[string]interface{}{"birthDate": time.Date(2024, time.June, 1, 0, 30, 0, 0, tokyo)},
+			check: func(t *testing.T, report datedReport) {
+				if !report.BirthDate.Equal(june1UTC) {
+					t.Errorf("birthDate = %v, want %v", report.BirthDate, june1UTC)
+				}
+			},
+		},
+		{
+			name:    "Date from an instant in another zone",
+			changes: map[string]interface{}{"reportDate": time.Date(2024, time.June, 1, 0, 30, 0, 0, tokyo)},
+			check: func(t *testing.T, report datedReport) {
+				if report.ReportDate != june1 {
+					t.Errorf("reportDate = %v, want %v", report.ReportDate, june1)
+				}
+			},
+		},
+		{
+			name:    "RFC3339 string for a tagged field",
+			changes: map[string]interface{}{"birthDate": "2024-06-01T00:00:00+09:00"},
+			wantErr: "error decoding 'birthDate': expected a 2006-01-02 date",
+		},
+		{
+			name:    "invalid Date",
+			changes: map[string]interface{}{"reportDate": "2024-02-30"},
+			wantErr: "reportDate",
+		},
+		{
+			name:    "invalid tagged date",
+			changes: map[string]interface{}{"birthDate": "June 1st"},
+			wantErr: "error decoding 'birthDate': expected a 2006-01-02 date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due := Date{Year: 2020, Month: time.March, Day: 3}
+			report := datedReport{ReportDate: due, DueDate: &due}
+
+			_, err := ApplyChanges(tt.changes, &report)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChanges() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			tt.check(t, report)
+		})
+	}
+}
+
+func TestDateOnlyChanges(t *testing.T) {
+	before := time.Date(2020, time.March, 3, 0, 0, 0, 0, time.UTC)
+	report := datedReport{BirthDate: before}
+
+	result, err := ApplyChangesWrapper(map[string]interface{}{"birthDate": "2024-06-01", "reportDate": "2024-06-02"}, "EUA1", &report)
+	if err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	var birthDate, reportDate FieldChange
+	for _, change := range result.Changes {
+		switch change.Path {
+		case "birthDate":
+			birthDate = change
+		case "reportDate":
+			reportDate = change
+		}
+	}
+
+	if _, ok := birthDate.New.(time.Time); !ok || !birthDate.DateOnly {
+		t.Fatalf("birthDate change = %#v, want the typed time.Time, DateOnly", birthDate)
+	}
+
+	encoded, err := json.Marshal(birthDate)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if want := `{"path":"birthDate","old":"2020-03-03","new":"2024-06-01","dateOnly":true}`; string(encoded) != want {
+		t.Errorf("json.Marshal() = %s, want %s", encoded, want)
+	}
+	if want := "birthDate: 2020-03-03 -> 2024-06-01"; birthDate.String() != want {
+		t.Errorf("String() = %q, want %q", birthDate.String(), want)
+	}
+
+	encoded, err = json.Marshal(reportDate)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if want := `{"path":"reportDate","old":null,"new":"2024-06-02"}`; string(encoded) != want {
+		t.Errorf("json.Marshal() = %s, want %s", encoded, want)
+	}
+
+	rows, err := ChangeRows(AuditEntry{TargetType: "datedReport", Changes: result.Changes})
+	if err != nil {
+		t.Fatalf("ChangeRows() error = %v", err)
+	}
+	var rendered []string
+	for _, row := range rows {
+		rendered = append(rendered, row.Field+": "+row.Old+" -> "+row.New)
+	}
+	if want := `birthDate: "2020-03-03" -> "2024-06-01", reportDate: null -> "2024-06-02"`; strings.Join(rendered, ", ") != want {
+		t.Errorf("ChangeRows() = %s, want %s", strings.Join(rendered, ", "), want)
+	}
+}
+
+func TestReplayDateOnlyFromJSON(t *testing.T) {
+	sink := &recordingSink{}
+	report := datedReport{}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"birthDate": "2024-06-01"}, "EUA1", &report, WithAuditSink(sink)); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"birthDate": "2024-06-02"}, "EUA1", &report, WithAuditSink(sink)); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	encoded, err := json.Marshal(sink.entries)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var entries []AuditEntry
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&entries); err != nil {
+		t.Fatalf("decoding the entries: %v", err)
+	}
+
+	replayed, err := Replay(datedReport{}, entries, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if want := time.Date(2024, time.June, 2, 0, 0, 0, 0, time.UTC); !replayed.BirthDate.Equal(want) {
+		t.Errorf("replayed birthDate = %v, want %v", replayed.BirthDate, want)
+	}
+}
@@ -0,0 +1,36 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/shopspring/decimal"
+)
+
+var decimalType = reflect.TypeOf(decimal.Decimal{})
+
+// parseDecimal converts a change into a decimal.Decimal. Strings and
+// json.Numbers (see ApplyMergePatch) are parsed exactly; floats are converted
+// through their shortest representation, so 19.99 stays 19.99 rather than
+// 19.989999999999998436805981327779591083526611328125.
+func parseDecimal(value interface{}) (decimal.Decimal, error) {
+	switch typed := value.(type) {
+	case string:
+		return decimal.NewFromString(typed)
+	case json.Number:
+		return decimal.NewFromString(typed.String())
+	case float64:
+		return decimal.NewFromFloat(typed), nil
+	case float32:
+		return decimal.NewFromFloat32(typed), nil
+	case int:
+		return decimal.NewFromInt(int64(typed)), nil
+	case int32:
+		return decimal.NewFromInt32(typed), nil
+	case int64:
+		return decimal.NewFromInt(typed), nil
+	}
+
+	return decimal.Decimal{}, fmt.Errorf("cannot decode %T into a decimal", value)
+}
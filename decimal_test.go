@@ -0,0 +1,81 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+type pricedRecord struct {
+	Price    decimal.Decimal  `json:"price"`
+	Discount *decimal.Decimal `json:"discount"`
+}
+
+func TestDecodeDecimal(t *testing.T) {
+	tests := []struct {
+		name         string
+		changes      map[string]interface{}
+		want         string
+		wantDiscount string
+		wantErr      bool
+	}{
+		{name: "string", changes: map[string]interface{}{"price": "19.99"}, want: "19.99"},
+		{name: "precise string", changes: map[string]interface{}{"price": "0.1000000000000000000001"}, want: "0.1000000000000000000001"},
+		{name: "json.Number", changes: map[string]interface{}{"price": json.Number("12345678901234567890.5")}, want: "12345678901234567890.5"},
+		{name: "float64 shortest", changes: map[string]interface{}{"price": 19.99}, want: "19.99"},
+		{name: "float32 shortest", changes: map[string]interface{}{"price": float32(0.1)}, want: "0.1"},
+		{name: "int", changes: map[string]interface{}{"price": 20}, want: "20"},
+		{name: "int32", changes: map[string]interface{}{"price": int32(-7)}, want: "-7"},
+		{name: "int64", changes: map[string]interface{}{"price": int64(1) << 40}, want: "1099511627776"},
+		{name: "decimal.Decimal", changes: map[string]interface{}{"price": decimal.RequireFromString("3.50")}, want: "3.5"},
+		{name: "pointer", changes: map[string]interface{}{"discount": "0.15"}, want: "0", wantDiscount: "0.15"},
+		{name: "invalid string", changes: map[string]interface{}{"price": "cheap"}, wantErr: true},
+		{name: "unsupported type", changes: map[string]interface{}{"price": true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := pricedRecord{}
+			_, err := ApplyChanges(tt.changes, &record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := record.Price.String(); got != tt.want {
+				t.Errorf("Price = %s, want %s", got, tt.want)
+			}
+			if tt.wantDiscount != "" && (record.Discount == nil || record.Discount.String() != tt.wantDiscount) {
+				t.Errorf("Discount = %v, want %s", record.Discount, tt.wantDiscount)
+			}
+		})
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "float64 not binary expansion", value: 0.1, want: "0.1"},
+		{name: "negative exponent", value: "1e-3", want: "0.001"},
+		{name: "nil", value: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDecimal(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDecimal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("parseDecimal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
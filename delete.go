@@ -0,0 +1,67 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrDeleted is returned when changes are applied to a target that has already
+// been soft-deleted (see ApplyDelete and WithRejectDeleted)
+var ErrDeleted = errors.New("the record has been deleted")
+
+// deletionFields are the metadata fields (by Go name) stamped by ApplyDelete.
+// Unlike the bookkeeping fields they are recorded and undone like any other
+// change, but they aren't part of an entity's ETag either.
+var deletionFields = []string{"DeletedBy", "DeletedDts"}
+
+// ApplyDelete soft-deletes the target by stamping the modifier as deletedBy and
+// the current time as deletedDts, through the same path (and with the same
+// modifiedBy stamping) as ApplyChangesWrapper. The target must have DeletedBy
+// and DeletedDts fields, as BaseStruct does; deleting it again fails with
+// ErrDeleted.
+func ApplyDelete(modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+	cfg.modifier = &modifier
+	cfg.deleting = true
+	cfg.rejectDeleted = true
+
+	target, ok := targetStruct(to)
+	if !ok {
+		return ApplyResult{}, fmt.Errorf("changes can only be applied to structs, not %T", to)
+	}
+
+	for _, name := range deletionFields {
+		if _, ok := structFieldByName(target.Type(), name); !ok {
+			return ApplyResult{}, fmt.Errorf("%s has no %s field", target.Type().Name(), name)
+		}
+	}
+
+	return applyChanges(map[string]interface{}{}, to, cfg)
+}
+
+// isDeleted reports whether the target has a DeletedDts field that is set
+func isDeleted(to interface{}) bool {
+	target, ok := targetStruct(to)
+	if !ok {
+		return false
+	}
+
+	field, ok := structFieldByName(target.Type(), "DeletedDts")
+	if !ok {
+		return false
+	}
+
+	value := target.FieldByIndex(field.Index)
+	if value.Kind() == reflect.Ptr {
+		return !value.IsNil()
+	}
+
+	return !value.IsZero()
+}
+
+// stampDeleted writes the deletion metadata into the changes
+func stampDeleted(changes map[string]interface{}, to interface{}, cfg *config) {
+	changes[metadataKey(to, cfg, "DeletedBy", "deletedBy")] = *cfg.modifier
+	changes[metadataKey(to, cfg, "DeletedDts", "deletedDts")] = cfg.now
+}
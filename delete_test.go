@@ -0,0 +1,118 @@
+package applychanges
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApplyDelete(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		record  nestedRecord
+		wantErr error
+	}{
+		{
+			name:   "live record",
+			record: nestedRecord{Name: "Tampa"},
+		},
+		{
+			name:    "already deleted",
+			record:  nestedRecord{BaseStruct: BaseStruct{DeletedBy: stringPtr("EUA9"), DeletedDts: &earlier}},
+			wantErr: ErrDeleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			result, err := ApplyDelete("EUA1", &record, WithClock(fixedClock(now)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyDelete() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if *record.DeletedBy != "EUA9" || !record.DeletedDts.Equal(earlier) || record.ModifiedBy != nil {
+					t.Errorf("record = %+v, want it untouched", record)
+				}
+				return
+			}
+
+			if record.DeletedBy == nil || *record.DeletedBy != "EUA1" || record.DeletedDts == nil || !record.DeletedDts.Equal(now) {
+				t.Errorf("deleted = %v at %v, want EUA1 at %v", record.DeletedBy, record.DeletedDts, now)
+			}
+			if record.ModifiedBy == nil || *record.ModifiedBy != "EUA1" || !record.ModifiedDts.Equal(now) {
+				t.Errorf("modified = %v at %v, want EUA1 at %v", record.ModifiedBy, record.ModifiedDts, now)
+			}
+			if !isDeleted(&record) {
+				t.Error("isDeleted() = false after ApplyDelete")
+			}
+
+			var paths []string
+			for _, change := range result.Changes {
+				paths = append(paths, change.Path)
+			}
+			if !containsPath(paths, "deletedBy") || !containsPath(paths, "deletedDts") {
+				t.Errorf("Changes = %v, want the deletion fields recorded", paths)
+			}
+		})
+	}
+}
+
+func TestApplyDeleteWithoutDeletionFields(t *testing.T) {
+	if _, err := ApplyDelete("EUA1", &principalRecord{}); err == nil {
+		t.Error("ApplyDelete() error = nil, want missing DeletedBy")
+	}
+	if _, err := ApplyDelete("EUA1", principalRecord{}); err == nil {
+		t.Error("ApplyDelete() error = nil, want a struct pointer")
+	}
+}
+
+func TestWithRejectDeleted(t *testing.T) {
+	deleted := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		changes   map[string]interface{}
+		wantErr   bool
+		immutable bool
+	}{
+		{name: "allowed by default", changes: map[string]interface{}{"name": "Tampa"}},
+		{name: "rejected", opts: []Option{WithRejectDeleted()}, changes: map[string]interface{}{"name": "Tampa"}, wantErr: true},
+		{name: "undeleting", changes: map[string]interface{}{"deletedDts": nil}, wantErr: true, immutable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := nestedRecord{BaseStruct: BaseStruct{DeletedBy: stringPtr("EUA9"), DeletedDts: &deleted}}
+			_, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, tt.opts...)
+
+			var immutable *ImmutableFieldError
+			switch {
+			case !tt.wantErr && err != nil:
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			case tt.wantErr && tt.immutable && !errors.As(err, &immutable):
+				t.Fatalf("ApplyChangesWrapper() error = %v, want ImmutableFieldError", err)
+			case tt.wantErr && !tt.immutable && !errors.Is(err, ErrDeleted):
+				t.Fatalf("ApplyChangesWrapper() error = %v, want ErrDeleted", err)
+			}
+
+			if record.DeletedDts == nil || !record.DeletedDts.Equal(deleted) {
+				t.Errorf("DeletedDts = %v, want it kept", record.DeletedDts)
+			}
+		})
+	}
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+
+	return false
+}
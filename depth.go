@@ -0,0 +1,130 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// DepthExceededError is returned when the changes address a field nested more
+// deeply than WithMaxApplyDepth allows
+type DepthExceededError struct {
+	// Path is the first offending path (in key order), e.g. "details.inner.a"
+	// or "contacts[0].name"
+	Path string
+
+	// Max is the configured maximum depth
+	Max int
+}
+
+func (e *DepthExceededError) Error() string {
+	return fmt.Sprintf("'%s' is nested more than %d levels deep", e.Path, e.Max)
+}
+
+// checkApplyDepth fails with a DepthExceededError for the first key (in sorted
+// order) of the changes that is more than max levels deep. Top-level keys are
+// one level deep, and only merging into a nested struct goes a level deeper:
+// the keys of a nested map for a struct field are one more, and elements of a
+// slice of structs count as a level of their own. The keys of a map-valued
+// field, or of anything without a field behind it, don't count.
+func checkApplyDepth(changes map[string]interface{}, targetType reflect.Type, tagName string, max int) error {
+	if path, ok := exceedsDepth(changes, targetType, tagName, "", 0, max); ok {
+		return &DepthExceededError{Path: path, Max: max}
+	}
+
+	return nil
+}
+
+// exceedsDepth returns the first path below value (itself depth levels deep at
+// path, and decoded onto valueType) that is more than max levels deep
+func exceedsDepth(value interface{}, valueType reflect.Type, tagName string, path string, depth int, max int) (string, bool) {
+	for valueType != nil && valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	if nested, ok := value.(map[string]interface{}); ok {
+		if valueType == nil || valueType.Kind() != reflect.Struct {
+			if depth > 0 {
+				return "", false
+			}
+			valueType = nil
+		}
+
+		keys := make([]string, 0, len(nested))
+		for key := range nested {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			if depth+1 > max {
+				return keyPath, true
+			}
+			if valueType == nil {
+				continue
+			}
+
+			field, ok := structFieldByTag(valueType, tagName, key)
+			if !ok {
+				continue
+			}
+			if offending, ok := exceedsDepth(nested[key], field.Type, tagName, keyPath, depth+1, max); ok {
+				return offending, true
+			}
+		}
+
+		return "", false
+	}
+
+	slice := reflect.ValueOf(value)
+	if slice.Kind() != reflect.Slice || valueType == nil {
+		return "", false
+	}
+	if kind := valueType.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return "", false
+	}
+	elemType := valueType.Elem()
+
+	for i := 0; i < slice.Len(); i++ {
+		elem := slice.Index(i).Interface()
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		// a nested slice is only a way to reach the structs inside it
+		if reflect.ValueOf(elem).Kind() == reflect.Slice {
+			if offending, ok := exceedsDepth(elem, elemType, tagName, elemPath, depth, max); ok {
+				return offending, true
+			}
+			continue
+		}
+
+		if !mergesIntoStruct(elem, elemType) {
+			continue
+		}
+		if depth+1 > max {
+			return elemPath, true
+		}
+		if offending, ok := exceedsDepth(elem, elemType, tagName, elemPath, depth+1, max); ok {
+			return offending, true
+		}
+	}
+
+	return "", false
+}
+
+// mergesIntoStruct reports whether value is a nested map decoded onto a struct
+// (or pointer to struct) type
+func mergesIntoStruct(value interface{}, valueType reflect.Type) bool {
+	if _, ok := value.(map[string]interface{}); !ok {
+		return false
+	}
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	return valueType.Kind() == reflect.Struct
+}
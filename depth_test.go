@@ -0,0 +1,129 @@
+package applychanges
+
+import (
+	"errors"
+	"testing"
+)
+
+type depthContact struct {
+	Name  string   `json:"name"`
+	Phone []string `json:"phone"`
+}
+
+type depthRecord struct {
+	Name     string                  `json:"name"`
+	Tags     []string                `json:"tags"`
+	Details  *nestedDetails          `json:"details"`
+	Outer    *depthOuter             `json:"outer"`
+	Contacts []depthContact          `json:"contacts"`
+	Matrix   [][]int                 `json:"matrix"`
+	Labels   map[string]string       `json:"labels"`
+	Extra    map[string]interface{}  `json:"extra"`
+	ByName   map[string]depthContact `json:"byName"`
+	Grid     [][]depthContact        `json:"grid"`
+}
+
+type depthOuter struct {
+	Inner nestedDetails `json:"inner"`
+}
+
+func TestWithMaxApplyDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		changes  map[string]interface{}
+		wantPath string
+	}{
+		{
+			name:    "top-level keys at depth one",
+			max:     1,
+			changes: map[string]interface{}{"name": "x", "tags": []interface{}{"a", "b"}},
+		},
+		{
+			name:     "nested map one level too deep",
+			max:      1,
+			changes:  map[string]interface{}{"details": map[string]interface{}{"source": "radar"}},
+			wantPath: "details.source",
+		},
+		{
+			name:    "nested map at the limit",
+			max:     2,
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": "radar"}, "labels": map[string]interface{}{"a": "b"}},
+		},
+		{
+			name: "first offending path in key order",
+			max:  2,
+			changes: map[string]interface{}{
+				"outer": map[string]interface{}{
+					"inner": map[string]interface{}{"source": "radar", "level": 1},
+				},
+			},
+			wantPath: "outer.inner.level",
+		},
+		{
+			name:    "three levels at the limit",
+			max:     3,
+			changes: map[string]interface{}{"outer": map[string]interface{}{"inner": map[string]interface{}{"source": "radar"}}},
+		},
+		{
+			name:     "slice elements count as a level",
+			max:      2,
+			changes:  map[string]interface{}{"contacts": []interface{}{map[string]interface{}{"name": "Ada"}}},
+			wantPath: "contacts[0].name",
+		},
+		{
+			name:    "slice of maps at the limit",
+			max:     3,
+			changes: map[string]interface{}{"contacts": []interface{}{map[string]interface{}{"name": "Ada", "phone": []interface{}{"555"}}}},
+		},
+		{
+			name:    "nested slices of scalars",
+			max:     1,
+			changes: map[string]interface{}{"matrix": []interface{}{[]interface{}{1}, []interface{}{2}}},
+		},
+		{
+			name:     "nested slices of structs",
+			max:      1,
+			changes:  map[string]interface{}{"grid": []interface{}{[]interface{}{map[string]interface{}{"name": "Ada"}}}},
+			wantPath: "grid[0][0]",
+		},
+		{
+			name:    "map-valued field",
+			max:     1,
+			changes: map[string]interface{}{"labels": map[string]interface{}{"a": "b"}, "extra": map[string]interface{}{"a": map[string]interface{}{"b": "c"}}},
+		},
+		{
+			name:    "struct inside a map-valued field",
+			max:     1,
+			changes: map[string]interface{}{"byName": map[string]interface{}{"ada": map[string]interface{}{"name": "Ada"}}},
+		},
+		{
+			name:    "no limit",
+			changes: map[string]interface{}{"outer": map[string]interface{}{"inner": map[string]interface{}{"source": "radar"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := depthRecord{}
+			_, err := ApplyChanges(tt.changes, &record, WithMaxApplyDepth(tt.max))
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("ApplyChanges() error = %v", err)
+				}
+				return
+			}
+
+			var depthErr *DepthExceededError
+			if !errors.As(err, &depthErr) {
+				t.Fatalf("ApplyChanges() error = %v, want a DepthExceededError", err)
+			}
+			if depthErr.Path != tt.wantPath || depthErr.Max != tt.max {
+				t.Errorf("DepthExceededError = %+v, want path %q", depthErr, tt.wantPath)
+			}
+			if record.Outer != nil || record.Details != nil || record.Contacts != nil || record.Matrix != nil {
+				t.Errorf("record = %+v, want it untouched", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// DeprecationHandler is told about every deprecated key an apply is given,
+// e.g. to log which clients still send it, see WithDeprecationHandler
+type DeprecationHandler func(key string, message string)
+
+// DualWriteCutover ends the transition of the WithDualWrite renames it's given
+// to: once RejectOld(true) is called changes keyed by the old name are rejected
+// with a RenamedFieldError, while changes to the new one are still written to
+// both fields. It's safe to flip concurrently with applies, e.g. from a
+// feature flag, so the options at the call sites don't change.
+type DualWriteCutover struct {
+	rejectOld int32
+}
+
+// RejectOld switches between accepting the old keys (false, the zero value)
+// and rejecting them (true)
+func (c *DualWriteCutover) RejectOld(reject bool) {
+	var value int32
+	if reject {
+		value = 1
+	}
+	atomic.StoreInt32(&c.rejectOld, value)
+}
+
+// RejectingOld reports whether the old keys are rejected
+func (c *DualWriteCutover) RejectingOld() bool {
+	return c != nil && atomic.LoadInt32(&c.rejectOld) == 1
+}
+
+// RenamedFieldError is returned for a change keyed by the old name of a field
+// renamed with WithDualWrite, once its DualWriteCutover rejects old keys
+type RenamedFieldError struct {
+	OldField string
+	NewField string
+}
+
+func (e *RenamedFieldError) Error() string {
+	return fmt.Sprintf("'%s' has been renamed to '%s'", e.OldField, e.NewField)
+}
+
+type dualWrite struct {
+	oldField string
+	newField string
+	cutover  *DualWriteCutover
+}
+
+// renameDualWrites rewrites the changes to the old key of each WithDualWrite
+// rename to the new one, so the rest of the apply (filtering, authorizing,
+// reporting) only sees the new field
+func renameDualWrites(changes map[string]interface{}, structType reflect.Type, cfg *config) error {
+	for _, rename := range cfg.dualWrites {
+		for _, name := range []string{rename.oldField, rename.newField} {
+			if _, ok := structFieldByTag(structType, cfg.tagName, name); !ok {
+				return fmt.Errorf("WithDualWrite: %s has no field '%s'", structType.Name(), name)
+			}
+		}
+
+		for key, value := range changes {
+			field, ok := structFieldByTag(structType, cfg.tagName, key)
+			if !ok || fieldKey(field, cfg.tagName) != rename.oldField {
+				continue
+			}
+
+			if rename.cutover.RejectingOld() {
+				return &RenamedFieldError{OldField: key, NewField: rename.newField}
+			}
+			if _, exists := changes[rename.newField]; exists {
+				return fmt.Errorf("'%s' and '%s' both change '%s'", key, rename.newField, rename.newField)
+			}
+
+			delete(changes, key)
+			changes[rename.newField] = value
+			if cfg.deprecationHandler != nil {
+				cfg.deprecationHandler(key, fmt.Sprintf("'%s' is deprecated, use '%s'", key, rename.newField))
+			}
+		}
+	}
+
+	return nil
+}
+
+// mirrorDualWrites copies the changes to the new field of each WithDualWrite
+// rename to the old one, once the changes have been checked
+func mirrorDualWrites(changes map[string]interface{}, cfg *config) {
+	for _, rename := range cfg.dualWrites {
+		if value, ok := changes[rename.newField]; ok {
+			changes[rename.oldField] = copyChangeValue(value)
+		}
+	}
+}
+
+// hideDualWrites leaves the old fields mirrored by mirrorDualWrites out of the
+// result, so it reports (and audits) the new field alone
+func hideDualWrites(result *ApplyResult, cfg *config) {
+	hidden := map[string]bool{}
+	for _, rename := range cfg.dualWrites {
+		hidden[rename.oldField] = true
+	}
+
+	applied := result.AppliedFields[:0]
+	for _, key := range result.AppliedFields {
+		if !hidden[key] {
+			applied = append(applied, key)
+		}
+	}
+	result.AppliedFields = applied
+
+	changes := result.Changes[:0]
+	for _, change := range result.Changes {
+		if !hidden[change.Path] {
+			changes = append(changes, change)
+		}
+	}
+	result.Changes = changes
+}
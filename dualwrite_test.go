@@ -0,0 +1,145 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type dualWriteReport struct {
+	BaseStruct
+	City       string `json:"city"`
+	Weather    string `json:"weather"`
+	Conditions string `json:"conditions"`
+}
+
+func TestWithDualWrite(t *testing.T) {
+	tests := []struct {
+		name           string
+		changes        map[string]interface{}
+		rejectOld      bool
+		opts           []Option
+		want           dualWriteReport
+		wantApplied    []string
+		wantDeprecated []string
+		wantErr        error
+	}{
+		{
+			name:           "old key",
+			changes:        map[string]interface{}{"weather": "sunny"},
+			want:           dualWriteReport{City: "Tampa", Weather: "sunny", Conditions: "sunny"},
+			wantApplied:    []string{"conditions", "modifiedBy"},
+			wantDeprecated: []string{"weather: 'weather' is deprecated, use 'conditions'"},
+		},
+		{
+			name:        "new key",
+			changes:     map[string]interface{}{"conditions": "sunny", "city": "Miami"},
+			want:        dualWriteReport{City: "Miami", Weather: "sunny", Conditions: "sunny"},
+			wantApplied: []string{"city", "conditions", "modifiedBy"},
+		},
+		{
+			name:           "old key matched case-insensitively",
+			changes:        map[string]interface{}{"Weather": "sunny"},
+			opts:           []Option{WithCaseInsensitiveKeys()},
+			want:           dualWriteReport{City: "Tampa", Weather: "sunny", Conditions: "sunny"},
+			wantApplied:    []string{"conditions", "modifiedBy"},
+			wantDeprecated: []string{"Weather: 'Weather' is deprecated, use 'conditions'"},
+		},
+		{
+			name:    "both keys",
+			changes: map[string]interface{}{"weather": "sunny", "conditions": "rainy"},
+			want:    dualWriteReport{City: "Tampa", Weather: "cloudy", Conditions: "cloudy"},
+			wantErr: errors.New("'weather' and 'conditions' both change 'conditions'"),
+		},
+		{
+			name:      "old key after the cutover",
+			changes:   map[string]interface{}{"weather": "sunny"},
+			rejectOld: true,
+			want:      dualWriteReport{City: "Tampa", Weather: "cloudy", Conditions: "cloudy"},
+			wantErr:   &RenamedFieldError{OldField: "weather", NewField: "conditions"},
+		},
+		{
+			name:        "new key after the cutover",
+			changes:     map[string]interface{}{"conditions": "sunny"},
+			rejectOld:   true,
+			want:        dualWriteReport{City: "Tampa", Weather: "sunny", Conditions: "sunny"},
+			wantApplied: []string{"conditions", "modifiedBy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := dualWriteReport{City: "Tampa", Weather: "cloudy", Conditions: "cloudy"}
+			cutover := &DualWriteCutover{}
+			cutover.RejectOld(tt.rejectOld)
+
+			var deprecated []string
+			sink := &recordingSink{}
+			opts := append([]Option{
+				WithDualWrite("weather", "conditions", cutover),
+				WithDeprecationHandler(func(key, message string) {
+					deprecated = append(deprecated, key+": "+message)
+				}),
+				WithModifiedDts(false),
+				WithAuditSink(sink),
+			}, tt.opts...)
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &report, opts...)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			report.ModifiedBy = nil
+			if report != tt.want {
+				t.Errorf("target = %+v, want %+v", report, tt.want)
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(result.AppliedFields, tt.wantApplied) {
+				t.Errorf("AppliedFields = %v, want %v", result.AppliedFields, tt.wantApplied)
+			}
+			if !reflect.DeepEqual(deprecated, tt.wantDeprecated) {
+				t.Errorf("deprecations = %v, want %v", deprecated, tt.wantDeprecated)
+			}
+
+			if len(sink.entries) != 1 {
+				t.Fatalf("audited %d entries, want 1", len(sink.entries))
+			}
+			var audited []string
+			for _, change := range sink.entries[0].Changes {
+				audited = append(audited, change.Path)
+			}
+			if !reflect.DeepEqual(audited, tt.wantApplied) {
+				t.Errorf("audited changes to %v, want %v", audited, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestWithDualWriteMissingField(t *testing.T) {
+	report := dualWriteReport{}
+	_, err := ApplyChanges(map[string]interface{}{"city": "Tampa"}, &report, WithDualWrite("weather", "forecast", nil))
+	if err == nil || err.Error() != "WithDualWrite: dualWriteReport has no field 'forecast'" {
+		t.Errorf("ApplyChanges() error = %v, want the missing field reported", err)
+	}
+}
+
+func TestWithDualWriteUndo(t *testing.T) {
+	report := dualWriteReport{Weather: "cloudy", Conditions: "cloudy"}
+	result, err := ApplyChangesWrapper(map[string]interface{}{"weather": "sunny"}, "EUA1", &report, WithDualWrite("weather", "conditions", nil))
+	if err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	if _, err := Undo(result, "EUA1", &report, WithDualWrite("weather", "conditions", nil)); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if report.Weather != "cloudy" || report.Conditions != "cloudy" {
+		t.Errorf("undone target = %+v, want both fields cloudy again", report)
+	}
+}
@@ -0,0 +1,149 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The ElementChange ops
+const (
+	ElementAdded    = "added"
+	ElementRemoved  = "removed"
+	ElementModified = "modified"
+)
+
+// ElementChange is a change to one element of a slice field, see
+// FieldChange.Elements
+type ElementChange struct {
+	// Key identifies the element: its merge key (see MergeSliceByKey) or, in
+	// a slice of scalars, the element itself
+	Key interface{} `json:"key"`
+
+	// Op is ElementAdded, ElementRemoved or ElementModified
+	Op string `json:"op"`
+
+	// Old is unset for added elements, New for removed ones
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// String renders the change for logs, e.g. "added urgent: urgent" or
+// "modified 2: {2 Ada} -> {2 Grace}"
+func (c ElementChange) String() string {
+	switch c.Op {
+	case ElementAdded:
+		return fmt.Sprintf("%s %v: %v", c.Op, c.Key, c.New)
+	case ElementRemoved:
+		return fmt.Sprintf("%s %v: %v", c.Op, c.Key, c.Old)
+	}
+
+	return fmt.Sprintf("%s %v: %v -> %v", c.Op, c.Key, c.Old, c.New)
+}
+
+// sliceElement is an element of a slice field's value along with its key
+type sliceElement struct {
+	key   interface{}
+	index string
+	value interface{}
+}
+
+// elementChanges breaks a change to a slice field down by element: scalar
+// elements are keyed by themselves (each occurrence counted separately), and
+// struct elements by their merge key when merged by key. Other slices aren't
+// broken down.
+func elementChanges(old interface{}, new interface{}, field reflect.StructField, path string, cfg *config) []ElementChange {
+	elemType := field.Type.Elem()
+	keyOf := reflect.Value.Interface
+
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	switch {
+	case elemType.Kind() == reflect.Struct:
+		strategy := sliceStrategy(field, path, cfg)
+		if strategy.kind != sliceMergeByKey {
+			return nil
+		}
+
+		keyField, ok := structFieldByTag(elemType, cfg.tagName, strategy.key)
+		if !ok {
+			return nil
+		}
+		keyOf = func(element reflect.Value) interface{} {
+			return element.FieldByIndex(keyField.Index).Interface()
+		}
+	case !isScalarKind(elemType.Kind()) || elemType.Kind() == reflect.Uint8:
+		// byte slices are blobs rather than lists
+		return nil
+	}
+
+	before := sliceElements(old, keyOf)
+	after := sliceElements(new, keyOf)
+
+	unmatched := map[string][]int{}
+	for i, element := range before {
+		unmatched[element.index] = append(unmatched[element.index], i)
+	}
+
+	var changes []ElementChange
+	matched := make([]bool, len(before))
+	for _, element := range after {
+		candidates := unmatched[element.index]
+		if len(candidates) == 0 {
+			changes = append(changes, ElementChange{Key: element.key, Op: ElementAdded, New: element.value})
+			continue
+		}
+
+		i := candidates[0]
+		unmatched[element.index] = candidates[1:]
+		matched[i] = true
+		if !sameValue(before[i].value, element.value) {
+			changes = append(changes, ElementChange{Key: element.key, Op: ElementModified, Old: before[i].value, New: element.value})
+		}
+	}
+
+	for i, element := range before {
+		if !matched[i] {
+			changes = append(changes, ElementChange{Key: element.key, Op: ElementRemoved, Old: element.value})
+		}
+	}
+
+	return changes
+}
+
+// sliceElements lists the non-nil elements of a slice field's value (as
+// reported in a FieldChange) with their keys
+func sliceElements(value interface{}, keyOf func(reflect.Value) interface{}) []sliceElement {
+	slice := reflect.ValueOf(value)
+	if slice.Kind() != reflect.Slice {
+		return nil
+	}
+
+	elements := make([]sliceElement, 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		element := slice.Index(i)
+		if element.Kind() == reflect.Ptr {
+			if element.IsNil() {
+				continue
+			}
+			element = element.Elem()
+		}
+
+		key := keyOf(element)
+		elements = append(elements, sliceElement{key: key, index: fmt.Sprint(key), value: element.Interface()})
+	}
+
+	return elements
+}
+
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type elementContact struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type elementRecord struct {
+	Tags     []string         `json:"tags"`
+	Counts   []int            `json:"counts" apply:"append"`
+	Contacts []elementContact `json:"contacts" apply:"mergekey=id"`
+	Others   []elementContact `json:"others"`
+	Secrets  []string         `json:"secrets" apply:"sensitive"`
+	Blob     []byte           `json:"blob"`
+}
+
+func TestElementChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  elementRecord
+		changes map[string]interface{}
+		path    string
+		opts    []Option
+		want    []ElementChange
+	}{
+		{
+			name:    "scalar add and remove",
+			record:  elementRecord{Tags: []string{"a", "b", "b"}},
+			changes: map[string]interface{}{"tags": []interface{}{"b", "c"}},
+			path:    "tags",
+			want: []ElementChange{
+				{Key: "c", Op: ElementAdded, New: "c"},
+				{Key: "a", Op: ElementRemoved, Old: "a"},
+				{Key: "b", Op: ElementRemoved, Old: "b"},
+			},
+		},
+		{
+			name:    "appended scalars",
+			record:  elementRecord{Counts: []int{1}},
+			changes: map[string]interface{}{"counts": []interface{}{2, 1}},
+			path:    "counts",
+			want: []ElementChange{
+				{Key: 2, Op: ElementAdded, New: 2},
+				{Key: 1, Op: ElementAdded, New: 1},
+			},
+		},
+		{
+			name:   "merged by key",
+			record: elementRecord{Contacts: []elementContact{{ID: 1, Name: "Ada"}, {ID: 2, Name: "Alan"}}},
+			changes: map[string]interface{}{"contacts": []interface{}{
+				map[string]interface{}{"id": 2, "name": "Grace"},
+				map[string]interface{}{"id": 3, "name": "Edsger"},
+			}},
+			path: "contacts",
+			want: []ElementChange{
+				{Key: 2, Op: ElementModified, Old: elementContact{ID: 2, Name: "Alan"}, New: elementContact{ID: 2, Name: "Grace"}},
+				{Key: 3, Op: ElementAdded, New: elementContact{ID: 3, Name: "Edsger"}},
+			},
+		},
+		{
+			name:   "replaced by key",
+			record: elementRecord{Others: []elementContact{{ID: 1, Name: "Ada"}, {ID: 2, Name: "Alan"}}},
+			changes: map[string]interface{}{"others": []interface{}{
+				map[string]interface{}{"id": 2, "name": "Alan"},
+				map[string]interface{}{"id": 3, "name": "Edsger"},
+			}},
+			path: "others",
+			opts: []Option{WithSliceStrategy("others", MergeSliceByKey("id"))},
+			want: []ElementChange{
+				{Key: 3, Op: ElementAdded, New: elementContact{ID: 3, Name: "Edsger"}},
+			},
+		},
+		{
+			name:    "structs without a merge key",
+			changes: map[string]interface{}{"others": []interface{}{map[string]interface{}{"id": 1}}},
+			path:    "others",
+		},
+		{
+			name:    "sensitive",
+			changes: map[string]interface{}{"secrets": []interface{}{"s"}},
+			path:    "secrets",
+		},
+		{
+			name:    "bytes",
+			changes: map[string]interface{}{"blob": []byte("ab")},
+			path:    "blob",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			result, err := ApplyChanges(tt.changes, &record, tt.opts...)
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if len(result.Changes) != 1 || result.Changes[0].Path != tt.path {
+				t.Fatalf("Changes = %v, want one for %s", result.Changes, tt.path)
+			}
+
+			got := result.Changes[0].redacted().Elements
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Elements = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestElementChangesRendering(t *testing.T) {
+	change := FieldChange{
+		Path: "contacts",
+		Old:  []elementContact{{ID: 1, Name: "Ada"}, {ID: 2, Name: "Alan"}},
+		New:  []elementContact{{ID: 2, Name: "Grace"}, {ID: 3, Name: "Edsger"}},
+		Elements: []ElementChange{
+			{Key: 2, Op: ElementModified, Old: elementContact{ID: 2, Name: "Alan"}, New: elementContact{ID: 2, Name: "Grace"}},
+			{Key: 3, Op: ElementAdded, New: elementContact{ID: 3, Name: "Edsger"}},
+			{Key: 1, Op: ElementRemoved, Old: elementContact{ID: 1, Name: "Ada"}},
+		},
+	}
+
+	wantString := "contacts: [{1 Ada} {2 Alan}] -> [{2 Grace} {3 Edsger}] " +
+		"(modified 2: {2 Alan} -> {2 Grace}; added 3: {3 Edsger}; removed 1: {1 Ada})"
+	if got := change.String(); got != wantString {
+		t.Errorf("String() = %q, want %q", got, wantString)
+	}
+
+	encoded, err := json.Marshal(change)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	wantJSON := `"elements":[` +
+		`{"key":2,"op":"modified","old":{"id":2,"name":"Alan"},"new":{"id":2,"name":"Grace"}},` +
+		`{"key":3,"op":"added","new":{"id":3,"name":"Edsger"}},` +
+		`{"key":1,"op":"removed","old":{"id":1,"name":"Ada"}}]`
+	if !strings.Contains(string(encoded), wantJSON) {
+		t.Errorf("json.Marshal() = %s, want it to contain %s", encoded, wantJSON)
+	}
+
+	change.Sensitive = true
+	if encoded, _ := json.Marshal(change); strings.Contains(string(encoded), "elements") {
+		t.Errorf("json.Marshal() = %s, want no elements for a sensitive field", encoded)
+	}
+}
@@ -0,0 +1,44 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// InvalidEncodingError is returned when a string in the changes (at any depth)
+// isn't valid UTF-8
+type InvalidEncodingError struct {
+	// Field is the path to the offending value, e.g. `details.notes[2]`
+	Field string
+}
+
+func (e *InvalidEncodingError) Error() string {
+	return fmt.Sprintf("'%s' is not valid UTF-8", e.Field)
+}
+
+// checkUTF8 fails on a string that isn't valid UTF-8, or with repair replaces
+// its invalid sequences with U+FFFD instead, see walkChanges
+func checkUTF8(path string, value reflect.Value, repair bool) (reflect.Value, error) {
+	if value.Kind() != reflect.String || utf8.ValidString(value.String()) {
+		return reflect.Value{}, nil
+	}
+
+	if !repair {
+		return reflect.Value{}, &InvalidEncodingError{Field: path}
+	}
+
+	repaired := strings.ToValidUTF8(value.String(), string(utf8.RuneError))
+	return reflect.ValueOf(repaired).Convert(value.Type()), nil
+}
+
+// RepairUTF8 replaces invalid UTF-8 sequences in every string of the changes
+// (at any depth, wherever an apply checks them) with U+FFFD, in place. Callers
+// that would rather store a mangled value than reject the change can run it
+// before applying, or apply with WithUTF8Repair.
+func RepairUTF8(changes map[string]interface{}) {
+	_ = walkChanges(changes, func(path string, value reflect.Value) (reflect.Value, error) {
+		return checkUTF8(path, value, true)
+	})
+}
@@ -0,0 +1,142 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type encodedRecord struct {
+	Name    string            `json:"name"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Details *nestedDetails    `json:"details"`
+	Notes   [2]string         `json:"notes"`
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	const invalid = "caf\xc3"
+
+	tests := []struct {
+		name      string
+		changes   map[string]interface{}
+		wantField string
+		repaired  encodedRecord
+	}{
+		{
+			name:      "string",
+			changes:   map[string]interface{}{"name": invalid},
+			wantField: "name",
+			repaired:  encodedRecord{Name: "caf�"},
+		},
+		{
+			name:      "slice element",
+			changes:   map[string]interface{}{"tags": []interface{}{"ok", invalid}},
+			wantField: "tags[1]",
+			repaired:  encodedRecord{Tags: []string{"ok", "caf�"}},
+		},
+		{
+			name:      "typed slice",
+			changes:   map[string]interface{}{"tags": []string{invalid}},
+			wantField: "tags[0]",
+			repaired:  encodedRecord{Tags: []string{"caf�"}},
+		},
+		{
+			name:      "nested map",
+			changes:   map[string]interface{}{"details": map[string]interface{}{"source": invalid}},
+			wantField: "details.source",
+			repaired:  encodedRecord{Details: &nestedDetails{Source: "caf�"}},
+		},
+		{
+			name:      "typed map",
+			changes:   map[string]interface{}{"labels": map[string]string{"city": invalid}},
+			wantField: "labels.city",
+			repaired:  encodedRecord{Labels: map[string]string{"city": "caf�"}},
+		},
+		{
+			name:      "struct value",
+			changes:   map[string]interface{}{"details": nestedDetails{Source: invalid}},
+			wantField: "details.Source",
+			repaired:  encodedRecord{Details: &nestedDetails{Source: "caf�"}},
+		},
+		{
+			name:      "pointer",
+			changes:   map[string]interface{}{"name": stringPtr(invalid)},
+			wantField: "name",
+			repaired:  encodedRecord{Name: "caf�"},
+		},
+		{
+			name:      "array",
+			changes:   map[string]interface{}{"notes": [2]string{"ok", invalid}},
+			wantField: "notes[1]",
+			repaired:  encodedRecord{Notes: [2]string{"ok", "caf�"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := encodedRecord{}
+			_, err := ApplyChanges(copyChanges(tt.changes), &record)
+			var encodingErr *InvalidEncodingError
+			if !errors.As(err, &encodingErr) || encodingErr.Field != tt.wantField {
+				t.Fatalf("ApplyChanges() error = %v, want an InvalidEncodingError for %s", err, tt.wantField)
+			}
+
+			record = encodedRecord{}
+			if _, err := ApplyChanges(copyChanges(tt.changes), &record, WithUTF8Repair()); err != nil {
+				t.Fatalf("ApplyChanges(WithUTF8Repair) error = %v", err)
+			}
+			if !reflect.DeepEqual(record, tt.repaired) {
+				t.Errorf("record = %+v, want %+v", record, tt.repaired)
+			}
+
+			changes := copyChanges(tt.changes)
+			RepairUTF8(changes)
+			if err := (&config{}).checkValues(changes); err != nil {
+				t.Errorf("checkValues() error = %v after RepairUTF8", err)
+			}
+		})
+	}
+}
+
+func TestWithAllowInvalidUTF8(t *testing.T) {
+	const invalid = "caf\xc3"
+
+	record := encodedRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"name": invalid}, &record, WithAllowInvalidUTF8()); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if record.Name != invalid {
+		t.Errorf("Name = %q, want %q stored as it is", record.Name, invalid)
+	}
+
+	record = encodedRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"name": invalid}, &record, WithAllowInvalidUTF8(), WithUTF8Repair()); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if record.Name != "caf�" {
+		t.Errorf("Name = %q, want it repaired", record.Name)
+	}
+}
+
+func TestValidUTF8Untouched(t *testing.T) {
+	changes := map[string]interface{}{
+		"name":   "Zürich 東京 🌦",
+		"tags":   []string{"naïve", "日本"},
+		"labels": map[string]string{"emoji": "☔"},
+	}
+
+	record := encodedRecord{}
+	if _, err := ApplyChanges(changes, &record, WithUTF8Repair()); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	want := encodedRecord{Name: "Zürich 東京 🌦", Tags: []string{"naïve", "日本"}, Labels: map[string]string{"emoji": "☔"}}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("record = %+v, want %+v", record, want)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
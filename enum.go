@@ -0,0 +1,139 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// validEnum is implemented by enum types that can tell their valid values apart
+type validEnum interface {
+	Valid() bool
+}
+
+var validEnumType = reflect.TypeOf((*validEnum)(nil)).Elem()
+
+// enums are the allowed values added by RegisterEnum, by enum type
+var enums registry[[]string]
+
+// InvalidEnumError is returned when a change for a string-based enum field
+// isn't one of the enum's values
+type InvalidEnumError struct {
+	// Field is the path of the change, e.g. "status" or "details.levels[2]"
+	Field string
+	Type  string
+	Value string
+
+	// Allowed are the enum's values when they were registered with
+	// RegisterEnum (an enum validated by its Valid method doesn't list them)
+	Allowed []string
+}
+
+func (e *InvalidEnumError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("'%s': '%s' is not a valid %s", e.Field, e.Value, e.Type)
+	}
+
+	return fmt.Sprintf("'%s': '%s' is not a valid %s (expected %s)", e.Field, e.Value, e.Type, quoteFields(e.Allowed))
+}
+
+// RegisterEnum registers the values a string-based enum type (e.g.
+// `type Status string`) allows, for every apply in the process; changes to
+// fields of that type with any other value fail with an InvalidEnumError.
+// Enum types can instead implement `Valid() bool` themselves. Registering a
+// type again replaces its values.
+func RegisterEnum[T ~string](values ...T) {
+	allowed := make([]string, len(values))
+	for i, value := range values {
+		allowed[i] = string(value)
+	}
+
+	register("RegisterEnum", func() {
+		enums.set(reflect.TypeOf((*T)(nil)).Elem(), allowed)
+	})
+}
+
+// validateEnums checks every string change for an enum field (or a slice of
+// them), including the fields of nested structs, against the enum's values
+func validateEnums(changes map[string]interface{}, structType reflect.Type, tagName string, prefix string) error {
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, tagName, key)
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		path := prefix + key
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			if fieldType.Kind() == reflect.Struct {
+				if err := validateEnums(typed, fieldType, tagName, path+"."); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			if fieldType.Kind() != reflect.Slice && fieldType.Kind() != reflect.Array {
+				continue
+			}
+
+			elemType := fieldType.Elem()
+			if elemType.Kind() == reflect.Ptr {
+				elemType = elemType.Elem()
+			}
+			for i, element := range typed {
+				if err := checkEnum(fmt.Sprintf("%s[%d]", path, i), elemType, element); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := checkEnum(path, fieldType, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkEnum fails if t is an enum type (registered, or with a Valid method)
+// and value is a string that isn't one of its values
+func checkEnum(path string, t reflect.Type, value interface{}) error {
+	if t.Kind() != reflect.String {
+		return nil
+	}
+
+	input := reflect.ValueOf(value)
+	if !input.IsValid() || input.Kind() != reflect.String {
+		return nil
+	}
+
+	if allowed, ok := registeredEnum(t); ok {
+		for _, candidate := range allowed {
+			if candidate == input.String() {
+				return nil
+			}
+		}
+
+		return &InvalidEnumError{Field: path, Type: t.Name(), Value: input.String(), Allowed: allowed}
+	}
+
+	if !t.Implements(validEnumType) && !reflect.PtrTo(t).Implements(validEnumType) {
+		return nil
+	}
+
+	enum := reflect.New(t)
+	enum.Elem().SetString(input.String())
+	if enum.Interface().(validEnum).Valid() {
+		return nil
+	}
+
+	return &InvalidEnumError{Field: path, Type: t.Name(), Value: input.String()}
+}
+
+// registeredEnum returns the values registered for t with RegisterEnum
+func registeredEnum(t reflect.Type) ([]string, bool) {
+	return enums.get(t)
+}
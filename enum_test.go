@@ -0,0 +1,88 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// ticketStatus is a registered enum
+type ticketStatus string
+
+// ticketSeverity validates itself
+type ticketSeverity string
+
+func (s ticketSeverity) Valid() bool {
+	return s == "minor" || s == "major"
+}
+
+type enumDetails struct {
+	Status ticketStatus `json:"status"`
+}
+
+type enumRecord struct {
+	Status   ticketStatus   `json:"status"`
+	Previous *ticketStatus  `json:"previous"`
+	Severity ticketSeverity `json:"severity"`
+	History  []ticketStatus `json:"history"`
+	Details  *enumDetails   `json:"details"`
+	Label    string         `json:"label"`
+}
+
+func TestValidateEnums(t *testing.T) {
+	t.Cleanup(func() { enums.remove(reflect.TypeOf(ticketStatus(""))) })
+	RegisterEnum[ticketStatus]("open", "closed")
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		wantErr *InvalidEnumError
+	}{
+		{name: "registered value", changes: map[string]interface{}{"status": "closed"}},
+		{name: "pointer field", changes: map[string]interface{}{"previous": "open"}},
+		{name: "nil", changes: map[string]interface{}{"previous": nil}},
+		{name: "Valid method", changes: map[string]interface{}{"severity": "major"}},
+		{name: "not an enum", changes: map[string]interface{}{"label": "anything"}},
+		{
+			name:    "unregistered value",
+			changes: map[string]interface{}{"status": "pending"},
+			wantErr: &InvalidEnumError{Field: "status", Type: "ticketStatus", Value: "pending", Allowed: []string{"open", "closed"}},
+		},
+		{
+			name:    "invalid by its Valid method",
+			changes: map[string]interface{}{"severity": "critical"},
+			wantErr: &InvalidEnumError{Field: "severity", Type: "ticketSeverity", Value: "critical"},
+		},
+		{
+			name:    "slice element",
+			changes: map[string]interface{}{"history": []interface{}{"open", "reopened"}},
+			wantErr: &InvalidEnumError{Field: "history[1]", Type: "ticketStatus", Value: "reopened", Allowed: []string{"open", "closed"}},
+		},
+		{
+			name:    "nested field",
+			changes: map[string]interface{}{"details": map[string]interface{}{"status": "gone"}},
+			wantErr: &InvalidEnumError{Field: "details.status", Type: "ticketStatus", Value: "gone", Allowed: []string{"open", "closed"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := enumRecord{Status: "open"}
+			_, err := ApplyChanges(tt.changes, &record)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("ApplyChanges() error = %v", err)
+				}
+				return
+			}
+
+			var invalid *InvalidEnumError
+			if !errors.As(err, &invalid) || !reflect.DeepEqual(invalid, tt.wantErr) {
+				t.Fatalf("ApplyChanges() error = %v, want %v", err, tt.wantErr)
+			}
+			if record.Status != "open" || record.Severity != "" || record.History != nil || record.Details != nil {
+				t.Errorf("record = %+v, want it untouched", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package applychanges
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Envelope carries a changeset between services along with the schema of the
+// type it was built against, so the receiving service can tell when its own
+// definition of the type has drifted from the sender's, see ValidateEnvelope
+type Envelope struct {
+	// BehaviorVersion is the sender's, see CompatibleWith
+	BehaviorVersion string `json:"behaviorVersion"`
+
+	// TargetType is the name of the sender's type
+	TargetType string `json:"targetType"`
+
+	// Fingerprint is the SHA-256 of the Fields, hex-encoded
+	Fingerprint string `json:"fingerprint"`
+
+	// Fields are the sender's fields, sorted by path
+	Fields []SchemaField `json:"fields"`
+
+	Changes map[string]interface{} `json:"changes"`
+}
+
+// SchemaField is a field of a type's schema: its path of json tag names (e.g.
+// `station.name`) and the kind of value it holds, e.g. "string", "*int",
+// "[]string", "map[string]float64", or a type name like "time.Time" for
+// values decoded as a whole. Nested structs are listed by their fields, and
+// structs in slices and maps are just "struct".
+type SchemaField struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+// SchemaMismatchError is returned by ValidateEnvelope when the sender's schema
+// differs from the target type's, with the differing paths, each sorted
+type SchemaMismatchError struct {
+	TargetType string
+
+	// Added are the fields of the target type the sender's doesn't have, and
+	// Removed the sender's fields the target type doesn't have
+	Added   []string
+	Removed []string
+
+	// Retyped are the fields whose kind differs
+	Retyped []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	var differences []string
+	if len(e.Added) > 0 {
+		differences = append(differences, "added "+quoteFields(e.Added))
+	}
+	if len(e.Removed) > 0 {
+		differences = append(differences, "removed "+quoteFields(e.Removed))
+	}
+	if len(e.Retyped) > 0 {
+		differences = append(differences, "retyped "+quoteFields(e.Retyped))
+	}
+	if len(differences) == 0 {
+		differences = append(differences, "the fingerprint doesn't match the fields")
+	}
+
+	return fmt.Sprintf("the changes were built against another schema of %s: %s", e.TargetType, strings.Join(differences, ", "))
+}
+
+// NewEnvelope wraps the changes in an Envelope for the target type, marshalled
+// to JSON
+func NewEnvelope(changes map[string]interface{}, targetType reflect.Type) ([]byte, error) {
+	targetType, err := envelopeType(targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := schemaFields(targetType)
+	return json.Marshal(Envelope{
+		BehaviorVersion: BehaviorVersion,
+		TargetType:      targetType.Name(),
+		Fingerprint:     schemaFingerprint(fields),
+		Fields:          fields,
+		Changes:         changes,
+	})
+}
+
+// ValidateEnvelope checks that an envelope made by NewEnvelope was built
+// against the same schema as the target type has, i.e. that no field has been
+// added, removed or retyped since, returning a SchemaMismatchError otherwise
+func ValidateEnvelope(envelope []byte, targetType reflect.Type) error {
+	_, err := openEnvelope(envelope, targetType)
+	return err
+}
+
+// ApplyEnvelope applies the changes of an envelope made by NewEnvelope like
+// ApplyChangesWrapper, after validating it like ValidateEnvelope. A mismatched
+// envelope is only applied WithSchemaMismatchOverride. Numbers are decoded as
+// json.Numbers, so large integers keep their precision.
+func ApplyEnvelope(envelope []byte, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+	cfg.modifier = &modifier
+
+	opened, err := openEnvelope(envelope, reflect.TypeOf(to))
+	if _, mismatched := err.(*SchemaMismatchError); err != nil && !(mismatched && cfg.schemaMismatchOverride) {
+		if cfg.messageCatalog != nil {
+			err = &catalogError{err: err, catalog: cfg.messageCatalog}
+		}
+		return ApplyResult{}, err
+	}
+
+	return applyChanges(opened.Changes, to, cfg)
+}
+
+// openEnvelope unmarshals the envelope, returning it along with any
+// SchemaMismatchError
+func openEnvelope(envelope []byte, targetType reflect.Type) (Envelope, error) {
+	targetType, err := envelopeType(targetType)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	var opened Envelope
+	decoder := json.NewDecoder(bytes.NewReader(envelope))
+	decoder.UseNumber()
+	if err := decoder.Decode(&opened); err != nil {
+		return Envelope{}, fmt.Errorf("not a changeset envelope: %w", err)
+	}
+
+	fields := schemaFields(targetType)
+	if opened.Fingerprint == schemaFingerprint(fields) && opened.Fingerprint == schemaFingerprint(opened.Fields) {
+		return opened, nil
+	}
+
+	return opened, schemaMismatch(targetType, opened.Fields, fields)
+}
+
+func envelopeType(targetType reflect.Type) (reflect.Type, error) {
+	for targetType != nil && targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+	if targetType == nil || targetType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("changes can only be applied to structs, not %v", targetType)
+	}
+
+	return targetType, nil
+}
+
+// schemaMismatch compares the sender's fields with ours
+func schemaMismatch(targetType reflect.Type, theirs, ours []SchemaField) *SchemaMismatchError {
+	theirKinds := make(map[string]string, len(theirs))
+	for _, field := range theirs {
+		theirKinds[field.Path] = field.Kind
+	}
+
+	mismatch := &SchemaMismatchError{TargetType: targetType.Name()}
+	for _, field := range ours {
+		kind, ok := theirKinds[field.Path]
+		switch {
+		case !ok:
+			mismatch.Added = append(mismatch.Added, field.Path)
+		case kind != field.Kind:
+			mismatch.Retyped = append(mismatch.Retyped, field.Path)
+		}
+		delete(theirKinds, field.Path)
+	}
+	for path := range theirKinds {
+		mismatch.Removed = append(mismatch.Removed, path)
+	}
+	sort.Strings(mismatch.Removed)
+
+	return mismatch
+}
+
+// schemaFingerprint hashes the fields, one "path kind" line each
+func schemaFingerprint(fields []SchemaField) string {
+	hash := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(hash, "%s %s\n", field.Path, field.Kind)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// schemaFields lists the fields of a struct type by json tag, sorted by path
+func schemaFields(structType reflect.Type) []SchemaField {
+	fields := appendSchemaFields(nil, structType, "", map[reflect.Type]bool{})
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Path < fields[j].Path
+	})
+
+	return fields
+}
+
+func appendSchemaFields(fields []SchemaField, structType reflect.Type, prefix string, seen map[reflect.Type]bool) []SchemaField {
+	seen[structType] = true
+	defer delete(seen, structType)
+
+	for _, field := range squashedFields(structType) {
+		name := fieldKey(field, "json")
+		if name == "" || field.PkgPath != "" {
+			continue
+		}
+
+		nested := field.Type
+		if nested.Kind() == reflect.Ptr {
+			nested = nested.Elem()
+		}
+		if isFieldByFieldStruct(nested) && !seen[nested] {
+			fields = appendSchemaFields(fields, nested, prefix+name+".", seen)
+			continue
+		}
+
+		fields = append(fields, SchemaField{Path: prefix + name, Kind: schemaKind(field.Type)})
+	}
+
+	return fields
+}
+
+// schemaKind describes the kind of value a type holds, see SchemaField
+func schemaKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + schemaKind(t.Elem())
+	case reflect.Slice:
+		return "[]" + schemaKind(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), schemaKind(t.Elem()))
+	case reflect.Map:
+		return "map[" + schemaKind(t.Key()) + "]" + schemaKind(t.Elem())
+	case reflect.Struct:
+		if isFieldByFieldStruct(t) {
+			return "struct"
+		}
+		return t.String()
+	}
+
+	return t.Kind().String()
+}
+
+// isFieldByFieldStruct reports whether t is a struct decoded field by field,
+// rather than as a whole like time.Time, scalars or structs without exported
+// fields (decimal.Decimal)
+func isFieldByFieldStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !isScalar(t) && hasExportedFields(t)
+}
@@ -0,0 +1,182 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type envelopeStation struct {
+	Name      string  `json:"name"`
+	Elevation float64 `json:"elevation"`
+}
+
+// sentReport is the sender's definition of the type
+type sentReport struct {
+	ModifiedBy *string          `json:"modifiedBy"`
+	City       string           `json:"city"`
+	Weather    string           `json:"weather"`
+	Readings   []int            `json:"readings"`
+	Station    envelopeStation  `json:"station"`
+	Backup     *envelopeStation `json:"backup"`
+	Amount     decimal.Decimal  `json:"amount"`
+	ObservedAt time.Time        `json:"observedAt"`
+}
+
+type sameReport sentReport
+
+type driftedStation struct {
+	Name      string `json:"name"`
+	Elevation int    `json:"elevation"`
+}
+
+type driftedReport struct {
+	ModifiedBy *string         `json:"modifiedBy"`
+	City       string          `json:"city"`
+	Readings   []float64       `json:"readings"`
+	Station    driftedStation  `json:"station"`
+	Backup     *driftedStation `json:"backup"`
+	Amount     decimal.Decimal `json:"amount"`
+	ObservedAt time.Time       `json:"observedAt"`
+	Country    string          `json:"country"`
+}
+
+func TestSchemaFields(t *testing.T) {
+	want := []SchemaField{
+		{Path: "amount", Kind: "decimal.Decimal"},
+		{Path: "backup.elevation", Kind: "float64"},
+		{Path: "backup.name", Kind: "string"},
+		{Path: "city", Kind: "string"},
+		{Path: "modifiedBy", Kind: "*string"},
+		{Path: "observedAt", Kind: "time.Time"},
+		{Path: "readings", Kind: "[]int"},
+		{Path: "station.elevation", Kind: "float64"},
+		{Path: "station.name", Kind: "string"},
+		{Path: "weather", Kind: "string"},
+	}
+
+	if got := schemaFields(reflect.TypeOf(sentReport{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("schemaFields() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateEnvelope(t *testing.T) {
+	changes := map[string]interface{}{"city": "Tampa", "station": map[string]interface{}{"elevation": 3}}
+	envelope, err := NewEnvelope(changes, reflect.TypeOf(&sentReport{}))
+	if err != nil {
+		t.Fatalf("NewEnvelope() error = %v", err)
+	}
+
+	tampered := strings.Replace(string(envelope), `"kind":"[]int"`, `"kind":"[]string"`, 1)
+
+	tests := []struct {
+		name     string
+		envelope []byte
+		target   reflect.Type
+		wantErr  error
+	}{
+		{name: "matching", envelope: envelope, target: reflect.TypeOf(sameReport{})},
+		{
+			name:     "drifted",
+			envelope: envelope,
+			target:   reflect.TypeOf(driftedReport{}),
+			wantErr: &SchemaMismatchError{
+				TargetType: "driftedReport",
+				Added:      []string{"country"},
+				Removed:    []string{"weather"},
+				Retyped:    []string{"backup.elevation", "readings", "station.elevation"},
+			},
+		},
+		{
+			name:     "fields not matching the fingerprint",
+			envelope: []byte(tampered),
+			target:   reflect.TypeOf(sameReport{}),
+			wantErr:  &SchemaMismatchError{TargetType: "sameReport", Retyped: []string{"readings"}},
+		},
+		{
+			name:     "not an envelope",
+			envelope: []byte(`["city"]`),
+			target:   reflect.TypeOf(sameReport{}),
+			wantErr:  errors.New("not a changeset envelope: json: cannot unmarshal array into Go value of type applychanges.Envelope"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEnvelope(tt.envelope, tt.target)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("ValidateEnvelope() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tt.wantErr.Error() {
+				t.Fatalf("ValidateEnvelope() error = %v, want %v", err, tt.wantErr)
+			}
+			if want, ok := tt.wantErr.(*SchemaMismatchError); ok && !reflect.DeepEqual(err, want) {
+				t.Errorf("ValidateEnvelope() error = %+v, want %+v", err, want)
+			}
+		})
+	}
+}
+
+func TestApplyEnvelope(t *testing.T) {
+	envelope, err := NewEnvelope(map[string]interface{}{"city": "Tampa", "station": map[string]interface{}{"elevation": 3}}, reflect.TypeOf(sentReport{}))
+	if err != nil {
+		t.Fatalf("NewEnvelope() error = %v", err)
+	}
+
+	var opened Envelope
+	if err := json.Unmarshal(envelope, &opened); err != nil {
+		t.Fatal(err)
+	}
+	if opened.BehaviorVersion != BehaviorVersion || opened.TargetType != "sentReport" {
+		t.Errorf("envelope = %+v, want it stamped with the BehaviorVersion and type", opened)
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		var report sameReport
+		if _, err := ApplyEnvelope(envelope, "EUA1", &report); err != nil {
+			t.Fatalf("ApplyEnvelope() error = %v", err)
+		}
+		if report.City != "Tampa" || report.Station.Elevation != 3 || *report.ModifiedBy != "EUA1" {
+			t.Errorf("applied %+v", report)
+		}
+	})
+
+	t.Run("mismatched", func(t *testing.T) {
+		var report driftedReport
+		_, err := ApplyEnvelope(envelope, "EUA1", &report)
+
+		var mismatch *SchemaMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("ApplyEnvelope() error = %v, want a SchemaMismatchError", err)
+		}
+		if report.City != "" {
+			t.Errorf("a mismatched envelope was applied: %+v", report)
+		}
+	})
+
+	t.Run("mismatched with the override", func(t *testing.T) {
+		var report driftedReport
+		if _, err := ApplyEnvelope(envelope, "EUA1", &report, WithSchemaMismatchOverride()); err != nil {
+			t.Fatalf("ApplyEnvelope() error = %v", err)
+		}
+		if report.City != "Tampa" || report.Station.Elevation != 3 {
+			t.Errorf("applied %+v", report)
+		}
+	})
+
+	t.Run("override doesn't cover a broken envelope", func(t *testing.T) {
+		var report driftedReport
+		if _, err := ApplyEnvelope([]byte("{"), "EUA1", &report, WithSchemaMismatchOverride()); err == nil {
+			t.Fatal("ApplyEnvelope() error = nil for a truncated envelope")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package applychanges
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ErrETagMismatch is returned when the target's current ETag isn't the one
+// given with WithExpectedETag, i.e. someone else changed the entity in the
+// meantime (the equivalent of an HTTP 412 Precondition Failed)
+type ErrETagMismatch struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ErrETagMismatch) Error() string {
+	return fmt.Sprintf("conflicting change: expected ETag %q, but the current ETag is %q", e.Expected, e.Actual)
+}
+
+// checkETag compares the target's current ETag with the expected one, which
+// may be given quoted (and weak) as in an If-Match header
+func checkETag(to interface{}, cfg *config) error {
+	actual, err := ComputeETag(to)
+	if err != nil {
+		return fmt.Errorf("computing ETag: %w", err)
+	}
+
+	expected := strings.Trim(strings.TrimPrefix(*cfg.expectedETag, "W/"), `"`)
+	if expected != actual {
+		return &ErrETagMismatch{Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// etagExcludedKeys returns the JSON keys of the entity's metadata fields (see
+// bookkeepingFields and deletionFields), which are left out of ComputeETag so
+// stamping an entity doesn't by itself change its ETag
+func etagExcludedKeys(entity interface{}) []string {
+	names := append(append([]string(nil), bookkeepingFields...), deletionFields...)
+
+	target, ok := targetStruct(entity)
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		if field, ok := structFieldByName(target.Type(), name); ok {
+			if key := fieldKey(field, "json"); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
+
+// ComputeETag returns a stable hash of an entity's business fields, suitable for
+// use as an HTTP ETag (callers add the surrounding quotes). The metadata
+// stamped by applies and deletes (modifiedBy, recentModifiers, lockVersion,
+// deletedDts, ...) is left out.
+//
+// The entity is hashed through its JSON form, re-encoded with sorted keys, so
+// the result doesn't depend on map iteration order, and time.Time values only
+// contribute their wall clock reading (never the monotonic one). NaN and ±Inf
+// are hashed as the strings "NaN", "+Inf" and "-Inf".
+func ComputeETag(entity interface{}) (string, error) {
+	encoded, err := json.Marshal(finiteValue(entity))
+	if err != nil {
+		return "", err
+	}
+
+	var fields interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return "", err
+	}
+
+	if asMap, ok := fields.(map[string]interface{}); ok {
+		for _, key := range etagExcludedKeys(entity) {
+			delete(asMap, key)
+		}
+	}
+
+	// encoding/json always writes map keys in sorted order
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
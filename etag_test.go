@@ -0,0 +1,124 @@
+package applychanges
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type etagRecord struct {
+	BaseStruct
+	Name            string            `json:"name"`
+	Labels          map[string]string `json:"labels"`
+	RecentModifiers []string          `json:"recentModifiers"`
+	LockVersion     int64             `json:"lockVersion"`
+}
+
+func newETagRecord() etagRecord {
+	base := NewBaseStruct("EUA1")
+	base.ID = uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	base.CreatedDts = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	return etagRecord{
+		BaseStruct: base,
+		Name:       "report",
+		Labels:     map[string]string{"b": "2", "a": "1", "c": "3"},
+	}
+}
+
+func TestComputeETag(t *testing.T) {
+	// sha256 of {"createdBy":"EUA1","createdDts":"2024-06-01T12:00:00Z",
+	// "id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","labels":{"a":"1","b":"2","c":"3"},"name":"report"}
+	const golden = "cb2f71c335a2f05c96d876ac306e4d743c3a841ccf7476225de583e6e3246561"
+
+	record := newETagRecord()
+	etag, err := ComputeETag(&record)
+	if err != nil {
+		t.Fatalf("ComputeETag() error = %v", err)
+	}
+	if etag != golden {
+		t.Errorf("ComputeETag() = %s, want %s", etag, golden)
+	}
+
+	record.Labels = map[string]string{"c": "3", "a": "1", "b": "2"}
+	if again, _ := ComputeETag(record); again != etag {
+		t.Errorf("ComputeETag() = %s after rebuilding the map, want %s", again, etag)
+	}
+
+	now := time.Now()
+	record.CreatedDts = now
+	monotonic, _ := ComputeETag(&record)
+	record.CreatedDts = now.Round(0)
+	if wall, _ := ComputeETag(&record); wall != monotonic {
+		t.Errorf("ComputeETag() = %s without the monotonic reading, want %s", wall, monotonic)
+	}
+}
+
+func TestETagAfterApply(t *testing.T) {
+	record := newETagRecord()
+	before, _ := ComputeETag(&record)
+
+	if _, err := ApplyChangesWrapper(map[string]interface{}{}, "EUA2", &record); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if record.ModifiedBy == nil || len(record.RecentModifiers) != 1 || record.LockVersion != 1 {
+		t.Fatalf("record = %+v, want the metadata stamped", record)
+	}
+	if touched, _ := ComputeETag(&record); touched != before {
+		t.Errorf("ComputeETag() = %s after a metadata-only apply, want %s", touched, before)
+	}
+
+	if _, err := ApplyDelete("EUA2", &record); err != nil {
+		t.Fatalf("ApplyDelete() error = %v", err)
+	}
+	if deleted, _ := ComputeETag(&record); deleted != before {
+		t.Errorf("ComputeETag() = %s after a delete, want %s", deleted, before)
+	}
+
+	record = newETagRecord()
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "renamed"}, "EUA2", &record); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if changed, _ := ComputeETag(&record); changed == before {
+		t.Error("ComputeETag() unchanged after a business-field apply")
+	}
+}
+
+func TestWithExpectedETag(t *testing.T) {
+	record := newETagRecord()
+	current, _ := ComputeETag(&record)
+
+	tests := []struct {
+		name     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "bare", expected: current},
+		{name: "quoted", expected: `"` + current + `"`},
+		{name: "weak", expected: `W/"` + current + `"`},
+		{name: "stale", expected: "0123", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newETagRecord()
+			_, err := ApplyChangesWrapper(map[string]interface{}{"name": "renamed"}, "EUA2", &target, WithExpectedETag(tt.expected))
+			if !tt.wantErr {
+				if err != nil || target.Name != "renamed" {
+					t.Fatalf("ApplyChangesWrapper() error = %v, name %q", err, target.Name)
+				}
+				return
+			}
+
+			var mismatch *ErrETagMismatch
+			if !errors.As(err, &mismatch) || mismatch.Expected != "0123" || mismatch.Actual != current {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want an ErrETagMismatch", err)
+			}
+			if target.Name != "report" || target.ModifiedBy != nil {
+				t.Errorf("target = %+v, want it untouched", target)
+			}
+		})
+	}
+}
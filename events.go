@@ -0,0 +1,196 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ChangeApplied is the event published after every apply, see
+// WithEventPublisher
+type ChangeApplied struct {
+	// TargetType and TargetID identify the target as in AuditEntry
+	TargetType string `json:"targetType"`
+	TargetID   string `json:"targetId"`
+
+	// Diff is the apply's result Changes, Redacted for sensitive fields
+	Diff []FieldChange `json:"diff"`
+
+	// Modifier is empty for applies without one (e.g. ApplyChanges)
+	Modifier string `json:"modifier"`
+
+	// At is when the changes were applied, from the configured Clock
+	At time.Time `json:"at"`
+
+	// BehaviorVersion is the BehaviorVersion the changes were applied under
+	BehaviorVersion string `json:"behaviorVersion"`
+}
+
+// EventPublisher receives a ChangeApplied event for every apply that succeeds
+// (dry runs aren't published). It's published before any AuditSink records
+// the apply, so an error publishing fails the apply with nothing audited,
+// undoing its changes to the target like any other failure; wrap slow
+// publishers in an AsyncPublisher to keep them off the apply's path.
+type EventPublisher interface {
+	Publish(ctx context.Context, event ChangeApplied) error
+}
+
+// publishEvent hands the event for a finished apply to the configured
+// publisher
+func publishEvent(to interface{}, result ApplyResult, cfg *config) error {
+	if cfg.eventPublisher == nil || cfg.dryRun {
+		return nil
+	}
+
+	entry := newAuditEntry(to, result, cfg)
+	event := ChangeApplied{
+		TargetType:      entry.TargetType,
+		TargetID:        entry.TargetID,
+		Diff:            entry.Changes,
+		Modifier:        entry.Modifier,
+		At:              entry.Timestamp,
+		BehaviorVersion: entry.BehaviorVersion,
+	}
+
+	if err := cfg.emit(cfg.ctx, func() error { return cfg.eventPublisher.Publish(cfg.ctx, event) }); err != nil {
+		return fmt.Errorf("publishing change event: %w", err)
+	}
+
+	return nil
+}
+
+// ErrEventBufferFull is returned by AsyncPublisher.Publish when its buffer is
+// full and its Backpressure is FailWhenFull
+var ErrEventBufferFull = errors.New("event buffer full")
+
+// ErrPublisherClosed is returned by AsyncPublisher.Publish once it has been
+// closed
+var ErrPublisherClosed = errors.New("event publisher closed")
+
+// Backpressure is what an AsyncPublisher does with an event when its buffer is
+// full
+type Backpressure int
+
+const (
+	// BlockWhenFull waits for room in the buffer, or for the apply's context
+	// to be done
+	BlockWhenFull Backpressure = iota
+
+	// DropWhenFull discards the event, reporting it to OnError
+	DropWhenFull
+
+	// FailWhenFull fails the apply with ErrEventBufferFull
+	FailWhenFull
+)
+
+// AsyncPublisherConfig configures NewAsyncPublisher
+type AsyncPublisherConfig struct {
+	// BufferSize is how many events can wait to be delivered (100 by default)
+	BufferSize int
+
+	// Backpressure is what to do when the buffer is full (BlockWhenFull by
+	// default)
+	Backpressure Backpressure
+
+	// OnError is called with events that couldn't be delivered (or were
+	// dropped) and why; such events are otherwise lost
+	OnError func(event ChangeApplied, err error)
+}
+
+// AsyncPublisher is an EventPublisher buffering events for delivery to another
+// publisher by a background goroutine, in order. Delivery runs detached from
+// the apply, under context.Background. Close it to deliver what's buffered and
+// stop.
+type AsyncPublisher struct {
+	next   EventPublisher
+	config AsyncPublisherConfig
+	events chan ChangeApplied
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncPublisher starts delivering events published to it to next
+func NewAsyncPublisher(next EventPublisher, config AsyncPublisherConfig) *AsyncPublisher {
+	if config.BufferSize <= 0 {
+		config.BufferSize = 100
+	}
+
+	p := &AsyncPublisher{
+		next:   next,
+		config: config,
+		events: make(chan ChangeApplied, config.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go p.deliver()
+
+	return p
+}
+
+// Publish buffers the event, handling a full buffer per the Backpressure
+func (p *AsyncPublisher) Publish(ctx context.Context, event ChangeApplied) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPublisherClosed
+	}
+
+	select {
+	case p.events <- event:
+		return nil
+	default:
+	}
+
+	switch p.config.Backpressure {
+	case DropWhenFull:
+		p.reportError(event, ErrEventBufferFull)
+		return nil
+	case FailWhenFull:
+		return ErrEventBufferFull
+	}
+
+	select {
+	case p.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits for the buffered ones to be
+// delivered, or for ctx to be done
+func (p *AsyncPublisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *AsyncPublisher) deliver() {
+	defer close(p.done)
+
+	for event := range p.events {
+		if err := p.next.Publish(context.Background(), event); err != nil {
+			p.reportError(event, err)
+		}
+	}
+}
+
+func (p *AsyncPublisher) reportError(event ChangeApplied, err error) {
+	if p.config.OnError != nil {
+		p.config.OnError(event, err)
+	}
+}
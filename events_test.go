@@ -0,0 +1,234 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// gatedPublisher holds every event until its gate is opened
+type gatedPublisher struct {
+	gate chan struct{}
+	err  error
+
+	mu     sync.Mutex
+	events []ChangeApplied
+}
+
+func (p *gatedPublisher) Publish(_ context.Context, event ChangeApplied) error {
+	<-p.gate
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return p.err
+}
+
+func TestWithEventPublisher(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		err       error
+		wantCount int
+	}{
+		{name: "published", wantCount: 1},
+		{name: "dry runs aren't published", opts: []Option{WithDryRun()}},
+		{name: "publish error fails the apply", err: errors.New("broker down"), wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &recordingPublisher{err: tt.err}
+			record := nestedRecord{Name: "Miami"}
+			record.ID = uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+			opts := append([]Option{WithEventPublisher(publisher), WithClock(fixedClock(now))}, tt.opts...)
+			_, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record, opts...)
+
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.err)
+				}
+				if record.Name != "Miami" {
+					t.Errorf("Name = %q, want the failed apply undone", record.Name)
+				}
+			} else if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if len(publisher.events) != tt.wantCount {
+				t.Fatalf("published %d events, want %d", len(publisher.events), tt.wantCount)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+
+			event := publisher.events[0]
+			if event.TargetType != "nestedRecord" || event.TargetID != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" || event.Modifier != "EUA1" || !event.At.Equal(now) || event.BehaviorVersion != BehaviorVersion {
+				t.Errorf("event = %+v, want the apply's target, modifier and time", event)
+			}
+			var diff []FieldChange
+			for _, change := range event.Diff {
+				if change.Path == "name" {
+					diff = append(diff, change)
+				}
+			}
+			if !reflect.DeepEqual(diff, []FieldChange{{Path: "name", Old: "Miami", New: "Tampa"}}) {
+				t.Errorf("Diff = %+v, want the name change", event.Diff)
+			}
+		})
+	}
+}
+
+func TestPublishBeforeAudit(t *testing.T) {
+	publisher := &recordingPublisher{err: errors.New("broker down")}
+	sink := &recordingSink{}
+	record := nestedRecord{Name: "Miami"}
+
+	result, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record, WithEventPublisher(publisher), WithAuditSink(sink))
+	if !errors.Is(err, publisher.err) {
+		t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, publisher.err)
+	}
+	if !reflect.DeepEqual(result, ApplyResult{}) {
+		t.Errorf("result = %+v, want it empty for a failed apply", result)
+	}
+	if record.Name != "Miami" {
+		t.Errorf("Name = %q, want the failed apply undone", record.Name)
+	}
+	if len(publisher.events) != 1 || len(sink.entries) != 0 {
+		t.Errorf("published %d events and audited %d entries, want the publish tried and nothing audited", len(publisher.events), len(sink.entries))
+	}
+}
+
+func TestAsyncPublisherBackpressure(t *testing.T) {
+	tests := []struct {
+		name         string
+		backpressure Backpressure
+		wantErr      error
+		wantDropped  int
+	}{
+		{name: "block until the context is done", backpressure: BlockWhenFull, wantErr: context.DeadlineExceeded},
+		{name: "drop", backpressure: DropWhenFull, wantDropped: 1},
+		{name: "fail", backpressure: FailWhenFull, wantErr: ErrEventBufferFull},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &gatedPublisher{gate: make(chan struct{})}
+			var mu sync.Mutex
+			var dropped []error
+			publisher := NewAsyncPublisher(next, AsyncPublisherConfig{
+				BufferSize:   1,
+				Backpressure: tt.backpressure,
+				OnError: func(_ ChangeApplied, err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					dropped = append(dropped, err)
+				},
+			})
+
+			// the first event is held by next and the second fills the buffer
+			for i := 0; i < 2; i++ {
+				if err := publisher.Publish(context.Background(), ChangeApplied{TargetID: "first"}); err != nil {
+					t.Fatalf("Publish() error = %v", err)
+				}
+				if i == 0 {
+					waitFor(t, func() bool { return len(publisher.events) == 0 })
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if err := publisher.Publish(ctx, ChangeApplied{TargetID: "overflow"}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Publish() error = %v, want %v", err, tt.wantErr)
+			}
+
+			close(next.gate)
+			if err := publisher.Close(context.Background()); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(dropped) != tt.wantDropped {
+				t.Errorf("OnError got %v, want %d dropped", dropped, tt.wantDropped)
+			}
+			if len(next.events) != 2 {
+				t.Errorf("delivered %d events, want the 2 buffered", len(next.events))
+			}
+		})
+	}
+}
+
+func TestAsyncPublisherDelivery(t *testing.T) {
+	next := &gatedPublisher{gate: make(chan struct{}), err: errors.New("broker down")}
+	close(next.gate)
+
+	var failed []string
+	publisher := NewAsyncPublisher(next, AsyncPublisherConfig{
+		OnError: func(event ChangeApplied, err error) {
+			failed = append(failed, event.TargetID+": "+err.Error())
+		},
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := publisher.Publish(context.Background(), ChangeApplied{TargetID: id}); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+
+	var delivered []string
+	for _, event := range next.events {
+		delivered = append(delivered, event.TargetID)
+	}
+	if !reflect.DeepEqual(delivered, []string{"1", "2", "3"}) {
+		t.Errorf("delivered %q, want every event in order", delivered)
+	}
+	if want := []string{"1: broker down", "2: broker down", "3: broker down"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("OnError got %q, want %q", failed, want)
+	}
+	if err := publisher.Publish(context.Background(), ChangeApplied{}); !errors.Is(err, ErrPublisherClosed) {
+		t.Errorf("Publish() after Close error = %v, want ErrPublisherClosed", err)
+	}
+}
+
+func TestAsyncPublisherCloseTimeout(t *testing.T) {
+	next := &gatedPublisher{gate: make(chan struct{})}
+	defer close(next.gate)
+
+	publisher := NewAsyncPublisher(next, AsyncPublisherConfig{})
+	if err := publisher.Publish(context.Background(), ChangeApplied{}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := publisher.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want the context's", err)
+	}
+}
+
+// waitFor polls until the condition holds, failing the test after a second
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
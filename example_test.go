@@ -0,0 +1,51 @@
+package applychanges_test
+
+import (
+	"fmt"
+	"time"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+type WeatherReport struct {
+	applychanges.BaseStruct
+	City    string `json:"city"`
+	Weather string `json:"weather"`
+}
+
+type fixedClock struct{}
+
+func (fixedClock) Now() time.Time {
+	return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func ExampleApplyChangesWrapper() {
+	report := WeatherReport{
+		BaseStruct: applychanges.NewBaseStruct("Dylan"),
+		City:       "Clearwater",
+		Weather:    "Hot and sunny",
+	}
+
+	changes := map[string]interface{}{"weather": "Thunderstorms"}
+	result, err := applychanges.ApplyChangesWrapper(changes, "Mr. Weatherdude", &report, applychanges.WithClock(fixedClock{}))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(report.City, "-", report.Weather)
+	fmt.Println(*report.ModifiedBy, report.ModifiedDts.Format(time.RFC3339))
+	fmt.Println(result.AppliedFields)
+	// Output:
+	// Clearwater - Thunderstorms
+	// Mr. Weatherdude 2024-06-01T12:00:00Z
+	// [modifiedBy modifiedDts weather]
+}
+
+func ExampleSanitize() {
+	changes := map[string]interface{}{"city": "", "weather": "Fog"}
+	applychanges.Sanitize(changes)
+
+	fmt.Println(changes["city"] == nil, changes["weather"])
+	// Output: true Fog
+}
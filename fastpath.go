@@ -0,0 +1,243 @@
+package applychanges
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fastDecoders are the decoders registered with RegisterFastDecoder, by struct
+// type
+var fastDecoders registry[fastDecoder]
+
+type fastDecoder struct {
+	tagName string
+	decode  func(changes map[string]interface{}, to interface{}) bool
+}
+
+// RegisterFastDecoder registers a reflection-free decoder for T, as generated
+// by cmd/applygen, replacing mapstructure for the final decode of the changes
+// onto a *T. The rest of the apply (sanitizing, stamping, diffing, ...) runs as
+// usual.
+//
+// decode must assign the changes to to and report true, or report false
+// without touching to when it can't handle them (e.g. an unexpected key or
+// value type), in which case mapstructure decodes them after all. It is only
+// used for applies matching keys by tagName, with zeroed fields (see
+// WithZeroFields) and without decode hooks, registered or configured, whose
+// conversions it would skip.
+func RegisterFastDecoder[T any](tagName string, decode func(changes map[string]interface{}, to *T) bool) {
+	register("RegisterFastDecoder", func() {
+		fastDecoders.set(reflect.TypeOf((*T)(nil)).Elem(), fastDecoder{
+			tagName: tagName,
+			decode: func(changes map[string]interface{}, to interface{}) bool {
+				return decode(changes, to.(*T))
+			},
+		})
+	})
+}
+
+// generatedAppliers are the appliers registered with RegisterApplier, by
+// struct type, each a func(map[string]interface{}, string, *T) (ApplyResult,
+// error)
+var generatedAppliers registry[interface{}]
+
+// RegisterApplier registers a reflection-free applier for T, as generated by
+// cmd/applygen -full, which Apply (and Applied) call in place of
+// ApplyChangesWrapper for applies to a *T without options, when no type config,
+// decode hook, scalar or enum is registered that it would skip. The applier
+// must behave exactly like ApplyChangesWrapper, handing it whatever changes it
+// can't handle itself.
+func RegisterApplier[T any](apply func(changes map[string]interface{}, modifier string, to *T) (ApplyResult, error)) {
+	register("RegisterApplier", func() {
+		generatedAppliers.set(reflect.TypeOf((*T)(nil)).Elem(), apply)
+	})
+}
+
+// generatedApplier returns the applier registered for T, unless the registries
+// change what an apply to a T does
+func generatedApplier[T any]() (func(map[string]interface{}, string, *T) (ApplyResult, error), bool) {
+	if len(generatedAppliers.load()) == 0 {
+		return nil, false
+	}
+
+	targetType := reflect.TypeOf((*T)(nil)).Elem()
+	registered, ok := generatedAppliers.get(targetType)
+	if !ok {
+		return nil, false
+	}
+	if _, configured := typeConfigs.get(targetType); configured {
+		return nil, false
+	}
+	if len(loadDecodeHooks().hooks) > 0 || len(scalars.load()) > 0 || len(enums.load()) > 0 {
+		return nil, false
+	}
+
+	return registered.(func(map[string]interface{}, string, *T) (ApplyResult, error)), true
+}
+
+// decodeFast decodes the changes onto the target with its registered fast
+// decoder, or by assigning them directly when every one of them is a plain
+// value for a scalar field (see decodeDirect), reporting false when neither is
+// usable
+func (cfg *config) decodeFast(changes map[string]interface{}, to interface{}) bool {
+	value := reflect.ValueOf(to)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct || !cfg.zeroFields || len(cfg.decodeHooks) > 0 {
+		return false
+	}
+
+	registered, ok := fastDecoders.get(value.Type().Elem())
+	if ok && registered.tagName != cfg.tagName {
+		return false
+	}
+	if len(loadDecodeHooks().hooks) > 0 {
+		return false
+	}
+
+	if ok {
+		return registered.decode(changes, to)
+	}
+	return decodeDirect(changes, value.Elem(), cfg.tagName)
+}
+
+// flatStructs caches isFlatStruct by struct type
+var flatStructs sync.Map // reflect.Type -> bool
+
+// isFlatStruct reports whether a type is a struct whose fields are all (or
+// point to) unnamed basic types or time.Time, without embedded structs or apply
+// tags. Nothing but a plain assignment happens to the changes of such a type,
+// so most of the apply's preparation passes are skipped for it, and values of
+// exactly the fields' types are assigned without going through mapstructure.
+func isFlatStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	if flat, ok := flatStructs.Load(t); ok {
+		return flat.(bool)
+	}
+
+	flat := true
+	for i := 0; i < t.NumField() && flat; i++ {
+		field := t.Field(i)
+		_, tagged := field.Tag.Lookup(applyTagName)
+		_, taggedAs := field.Tag.Lookup(applyAsTagName)
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		flat = !field.Anonymous && !tagged && !taggedAs && (fieldType == timeType || isBasicType(fieldType))
+	}
+
+	flatStructs.Store(t, flat)
+	return flat
+}
+
+// isBasicType reports whether t is a predeclared boolean, numeric or string type
+func isBasicType(t reflect.Type) bool {
+	if t.PkgPath() != "" {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+
+	return false
+}
+
+// directFieldsCache caches directFields by struct type and tag
+var directFieldsCache sync.Map // tagIndexKey -> map[string][]int
+
+// directFields returns the index of every field of a struct that decodeDirect
+// can assign, by tag name: the exported fields of (or pointing to) an unnamed
+// basic type or time.Time, whose ASCII name no other field shares, even
+// case-insensitively, since mapstructure would decode the key into both
+func directFields(structType reflect.Type, tagName string) map[string][]int {
+	cacheKey := tagIndexKey{structType: structType, tagName: tagName}
+	if fields, ok := directFieldsCache.Load(cacheKey); ok {
+		return fields.(map[string][]int)
+	}
+
+	squashed := squashedFields(structType)
+	names := map[string]int{}
+	for _, field := range squashed {
+		if name := fieldKey(field, tagName); name != "" {
+			names[strings.ToLower(name)]++
+		}
+	}
+
+	fields := map[string][]int{}
+	for _, field := range squashed {
+		name := fieldKey(field, tagName)
+		if name == "" || field.PkgPath != "" || !isASCII(name) || names[strings.ToLower(name)] > 1 {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType == timeType || isBasicType(fieldType) {
+			fields[name] = field.Index
+		}
+	}
+
+	cached, _ := directFieldsCache.LoadOrStore(cacheKey, fields)
+	return cached.(map[string][]int)
+}
+
+// decodeDirect assigns the changes to the fields they exactly match, for the
+// common changes of a few scalar fields (a status flip alongside the stamped
+// metadata, say), the way mapstructure would: every key must match one of the
+// directFields exactly and hold nil or a value of the field's type (or the
+// type it points to), with no RegisterScalar parser for it, otherwise false is
+// reported without touching the target
+func decodeDirect(changes map[string]interface{}, target reflect.Value, tagName string) bool {
+	fields := directFields(target.Type(), tagName)
+
+	for key, value := range changes {
+		index, ok := fields[key]
+		if !ok {
+			return false
+		}
+		if value == nil {
+			continue
+		}
+
+		// pointers are never shared with the changes, mapstructure copies what
+		// they point to too
+		fieldType := target.Type().FieldByIndex(index).Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if reflect.TypeOf(value) != fieldType {
+			return false
+		}
+		if _, parsed := scalarParser(fieldType); parsed {
+			return false
+		}
+	}
+
+	for key, value := range changes {
+		field := target.FieldByIndex(fields[key])
+		switch {
+		case value == nil:
+			field.Set(reflect.Zero(field.Type()))
+		case field.Kind() == reflect.Ptr:
+			pointer := reflect.New(field.Type().Elem())
+			pointer.Elem().Set(reflect.ValueOf(value))
+			field.Set(pointer)
+		default:
+			field.Set(reflect.ValueOf(value))
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,397 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type directStatus string
+
+type directReport struct {
+	BaseStruct
+	Status      string            `json:"status"`
+	Note        *string           `json:"note"`
+	Count       int               `json:"count"`
+	Total       int64             `json:"total"`
+	Ratio       float64           `json:"ratio"`
+	Active      bool              `json:"active"`
+	ObservedAt  time.Time         `json:"observedAt"`
+	ReviewedAt  *time.Time        `json:"reviewedAt"`
+	Phase       directStatus      `json:"phase"`
+	Secret      string            `json:"secret" apply:"sensitive"`
+	Tags        []string          `json:"tags"`
+	Details     nestedDetails     `json:"details"`
+	Scores      map[string]int    `json:"scores"`
+	Labels      map[string]string `json:"labels"`
+	Level       int               `json:"level"`
+	LevelFolded int               `json:"Level"`
+}
+
+// noopHook forces the decode through mapstructure, without changing anything
+func noopHook(_ reflect.Type, _ reflect.Type, v interface{}) (interface{}, error) {
+	return v, nil
+}
+
+func newDirectReport() directReport {
+	observed := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	note := "calm"
+	return directReport{
+		BaseStruct: BaseStruct{CreatedBy: "EUA0"},
+		Status:     "open",
+		Note:       &note,
+		Count:      1,
+		Total:      2,
+		Ratio:      0.5,
+		ObservedAt: observed,
+		ReviewedAt: &observed,
+		Tags:       []string{"storm"},
+		Scores:     map[string]int{"wind": 3},
+	}
+}
+
+// TestDecodeDirect runs every change of the corpus through the direct
+// assignment and through mapstructure, which must agree on the target, the
+// result and the error
+func TestDecodeDirect(t *testing.T) {
+	reviewed := time.Date(2022, 9, 2, 8, 30, 0, 0, time.UTC)
+
+	corpus := []struct {
+		name    string
+		changes map[string]interface{}
+		direct  bool
+	}{
+		{name: "string", changes: map[string]interface{}{"status": "closed"}, direct: true},
+		{name: "empty string", changes: map[string]interface{}{"status": ""}, direct: true},
+		{name: "pointer to string", changes: map[string]interface{}{"note": "windy"}, direct: true},
+		{name: "null pointer", changes: map[string]interface{}{"note": nil}, direct: true},
+		{name: "null value", changes: map[string]interface{}{"count": nil}, direct: true},
+		{name: "int", changes: map[string]interface{}{"count": 7}, direct: true},
+		{name: "int into int64", changes: map[string]interface{}{"total": 7}},
+		{name: "float into int", changes: map[string]interface{}{"count": 7.0}},
+		{name: "fractional float into int", changes: map[string]interface{}{"count": 7.5}},
+		{name: "float", changes: map[string]interface{}{"ratio": 0.75}, direct: true},
+		{name: "int into float", changes: map[string]interface{}{"ratio": 1}},
+		{name: "bool", changes: map[string]interface{}{"active": true}, direct: true},
+		{name: "string into bool", changes: map[string]interface{}{"active": "yes"}},
+		{name: "time", changes: map[string]interface{}{"observedAt": reviewed}, direct: true},
+		{name: "time string", changes: map[string]interface{}{"observedAt": "2022-09-02T08:30:00Z"}},
+		{name: "pointer to time", changes: map[string]interface{}{"reviewedAt": reviewed}, direct: true},
+		{name: "bad time string", changes: map[string]interface{}{"reviewedAt": "yesterday"}},
+		{name: "named type", changes: map[string]interface{}{"phase": "draft"}},
+		{name: "sensitive", changes: map[string]interface{}{"secret": "hunter2"}, direct: true},
+		{name: "slice", changes: map[string]interface{}{"tags": []interface{}{"calm"}}},
+		{name: "nested struct", changes: map[string]interface{}{"details": map[string]interface{}{"level": 2}}},
+		{name: "map", changes: map[string]interface{}{"scores": map[string]interface{}{"rain": 1}}},
+		{name: "nil map", changes: map[string]interface{}{"labels": nil}},
+		{name: "embedded field", changes: map[string]interface{}{"modifiedBy": "EUA3"}, direct: true},
+		{name: "immutable embedded field", changes: map[string]interface{}{"createdBy": "EUA3"}, direct: true},
+		{name: "case-insensitive key", changes: map[string]interface{}{"Status": "closed"}},
+		{name: "names shared case-insensitively", changes: map[string]interface{}{"level": 3}},
+		{name: "unknown key", changes: map[string]interface{}{"weather": "sunny"}},
+		{name: "several scalars", changes: map[string]interface{}{"status": "closed", "count": 2, "active": true}, direct: true},
+	}
+
+	applies := []struct {
+		name  string
+		apply func(changes map[string]interface{}, to *directReport, opts ...Option) (ApplyResult, error)
+	}{
+		{name: "ApplyChanges", apply: func(changes map[string]interface{}, to *directReport, opts ...Option) (ApplyResult, error) {
+			return ApplyChanges(changes, to, opts...)
+		}},
+		{name: "ApplyChangesWrapper", apply: func(changes map[string]interface{}, to *directReport, opts ...Option) (ApplyResult, error) {
+			return ApplyChangesWrapper(changes, "EUA1", to, opts...)
+		}},
+	}
+
+	clock := WithClock(fixedClock(time.Date(2022, 9, 3, 0, 0, 0, 0, time.UTC)))
+	for _, apply := range applies {
+		for _, tt := range corpus {
+			t.Run(apply.name+"/"+tt.name, func(t *testing.T) {
+				fastTarget, slowTarget := newDirectReport(), newDirectReport()
+
+				fastResult, fastErr := apply.apply(copyChanges(tt.changes), &fastTarget, clock)
+				slowResult, slowErr := apply.apply(copyChanges(tt.changes), &slowTarget, clock, WithDecodeHook(noopHook))
+
+				if (fastErr == nil) != (slowErr == nil) || (fastErr != nil && fastErr.Error() != slowErr.Error()) {
+					t.Fatalf("errors differ: direct %v, mapstructure %v", fastErr, slowErr)
+				}
+				if !reflect.DeepEqual(fastTarget, slowTarget) {
+					t.Errorf("targets differ:\ndirect       %+v\nmapstructure %+v", fastTarget, slowTarget)
+				}
+				if !reflect.DeepEqual(fastResult, slowResult) {
+					t.Errorf("results differ:\ndirect       %+v\nmapstructure %+v", fastResult, slowResult)
+				}
+
+				changes := copyChanges(tt.changes)
+				Sanitize(changes)
+				if apply.name == "ApplyChangesWrapper" {
+					changes["modifiedBy"] = "EUA1"
+					changes["modifiedDts"] = time.Date(2022, 9, 3, 0, 0, 0, 0, time.UTC)
+				}
+				target := newDirectReport()
+				if got := decodeDirect(changes, reflect.ValueOf(&target).Elem(), "json"); got != tt.direct {
+					t.Errorf("decodeDirect() = %v, want %v", got, tt.direct)
+				}
+			})
+		}
+	}
+}
+
+func TestRegisterFastDecoder(t *testing.T) {
+	reportType := reflect.TypeOf(directReport{})
+	defer fastDecoders.remove(reportType)
+
+	calls, handle := 0, true
+	RegisterFastDecoder("json", func(changes map[string]interface{}, to *directReport) bool {
+		calls++
+		if !handle {
+			return false
+		}
+		for key, value := range changes {
+			switch key {
+			case "status":
+				to.Status = value.(string)
+			case "modifiedBy":
+				to.ModifiedBy = stringPtr(value.(string))
+			case "modifiedDts":
+				modified := value.(time.Time)
+				to.ModifiedDts = &modified
+			}
+		}
+		return true
+	})
+
+	tests := []struct {
+		name      string
+		declines  bool
+		opts      []Option
+		wantCalls int
+	}{
+		{name: "registered", wantCalls: 1},
+		{name: "declined, decoded by mapstructure", declines: true, wantCalls: 1},
+		{name: "another tag name", opts: []Option{WithTagName("db"), WithCaseInsensitiveKeys()}},
+		{name: "with a decode hook", opts: []Option{WithDecodeHook(noopHook)}},
+		{name: "without zeroed fields", opts: []Option{WithZeroFields(false)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, handle = 0, !tt.declines
+			report := newDirectReport()
+			if _, err := ApplyChangesWrapper(map[string]interface{}{"status": "closed"}, "EUA1", &report, tt.opts...); err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("fast decoder called %d times, want %d", calls, tt.wantCalls)
+			}
+			if report.Status != "closed" || report.ModifiedBy == nil || *report.ModifiedBy != "EUA1" {
+				t.Errorf("report = %+v, want it closed by EUA1", report)
+			}
+		})
+	}
+}
+
+func TestRegisterApplier(t *testing.T) {
+	reportType := reflect.TypeOf(directReport{})
+	defer generatedAppliers.remove(reportType)
+
+	calls := 0
+	RegisterApplier(func(changes map[string]interface{}, modifier string, to *directReport) (ApplyResult, error) {
+		calls++
+		return ApplyChangesWrapper(changes, modifier, to)
+	})
+
+	tests := []struct {
+		name      string
+		register  func(t *testing.T)
+		opts      []Option
+		wantCalls int
+	}{
+		{name: "registered", wantCalls: 1},
+		{name: "with options", opts: []Option{WithModifiedDts(false)}},
+		{name: "with a type config", register: func(t *testing.T) {
+			registerTestTypeConfig[directReport](t, WithModifiedDts(false))
+		}},
+		{name: "with a decode hook", register: func(t *testing.T) {
+			hooks := loadDecodeHooks()
+			t.Cleanup(func() { registeredDecodeHooks.Store(hooks) })
+			RegisterDecodeHook(noopHook)
+		}},
+		{name: "with a scalar", register: func(t *testing.T) {
+			t.Cleanup(func() { scalars.remove(reflect.TypeOf(directStatus(""))) })
+			RegisterScalar(func(value interface{}) (directStatus, error) { return directStatus("open"), nil })
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.register != nil {
+				tt.register(t)
+			}
+
+			calls = 0
+			report := newDirectReport()
+			if _, err := Apply(map[string]interface{}{"status": "closed"}, "EUA1", &report, tt.opts...); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("registered applier called %d times, want %d", calls, tt.wantCalls)
+			}
+			if report.Status != "closed" {
+				t.Errorf("Status = %q, want closed", report.Status)
+			}
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	paths := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "direct"},
+		{name: "mapstructure", opts: []Option{WithDecodeHook(noopHook)}},
+	}
+
+	for _, path := range paths {
+		b.Run(path.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				report := directReport{Status: "open"}
+				if _, err := ApplyChangesWrapper(map[string]interface{}{"status": "closed"}, "EUA1", &report, path.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+type flatReading struct {
+	Station    string     `json:"station"`
+	Celsius    float64    `json:"celsius"`
+	Count      int        `json:"count"`
+	Samples    *int64     `json:"samples"`
+	Valid      bool       `json:"valid"`
+	Note       *string    `json:"note"`
+	ObservedAt time.Time  `json:"observedAt"`
+	ReviewedAt *time.Time `json:"reviewedAt"`
+}
+
+// taggedReading has flatReading's fields, but its apply tag takes it off the
+// flat struct fast path
+type taggedReading struct {
+	Station    string     `json:"station"`
+	Celsius    float64    `json:"celsius"`
+	Count      int        `json:"count"`
+	Samples    *int64     `json:"samples"`
+	Valid      bool       `json:"valid"`
+	Note       *string    `json:"note"`
+	ObservedAt time.Time  `json:"observedAt"`
+	ReviewedAt *time.Time `json:"reviewedAt"`
+	Internal   string     `json:"internal" apply:"immutable"`
+}
+
+func TestIsFlatStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		target interface{}
+		want   bool
+	}{
+		{name: "scalars, times and pointers to them", target: flatReading{}, want: true},
+		{name: "apply tag", target: taggedReading{}},
+		{name: "embedded struct", target: directReport{}},
+		{name: "nested struct", target: nestedRecord{}},
+		{name: "named scalar", target: struct{ Phase directStatus }{}},
+		{name: "slice", target: struct{ Tags []string }{}},
+		{name: "applyas tag", target: struct {
+			Day time.Time `applyas:"date"`
+		}{}},
+		{name: "not a struct", target: "flat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFlatStruct(reflect.TypeOf(tt.target)); got != tt.want {
+				t.Errorf("isFlatStruct(%T) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFlatStructMatchesMapstructure applies every change of the corpus to a
+// flat struct, taking the fast path, and to its tagged twin through
+// mapstructure, which must agree on the fields, the changes and the error
+func TestFlatStructMatchesMapstructure(t *testing.T) {
+	observed := time.Date(2022, 9, 2, 8, 30, 0, 0, time.UTC)
+
+	corpus := []struct {
+		name    string
+		changes map[string]interface{}
+	}{
+		{name: "string", changes: map[string]interface{}{"station": "KTPA"}},
+		{name: "float", changes: map[string]interface{}{"celsius": 21.5}},
+		{name: "int into float", changes: map[string]interface{}{"celsius": 21}},
+		{name: "float into int", changes: map[string]interface{}{"count": 3.0}},
+		{name: "fractional float into int", changes: map[string]interface{}{"count": 3.5}},
+		{name: "int into pointer", changes: map[string]interface{}{"samples": 12}},
+		{name: "null pointer", changes: map[string]interface{}{"note": nil}},
+		{name: "bool", changes: map[string]interface{}{"valid": true}},
+		{name: "string into bool", changes: map[string]interface{}{"valid": "yes"}},
+		{name: "time", changes: map[string]interface{}{"observedAt": observed}},
+		{name: "time string", changes: map[string]interface{}{"reviewedAt": "2022-09-02T08:30:00Z"}},
+		{name: "bad time string", changes: map[string]interface{}{"observedAt": "yesterday"}},
+		{name: "case-insensitive key", changes: map[string]interface{}{"Station": "KMIA"}},
+		{name: "unknown key", changes: map[string]interface{}{"weather": "sunny"}},
+		{name: "padded string", changes: map[string]interface{}{"station": "  KTPA  "}},
+		{name: "several", changes: map[string]interface{}{"station": "KTPA", "count": 4, "note": "gusty", "valid": false}},
+	}
+
+	newTargets := func() (flatReading, taggedReading) {
+		note, samples := "calm", int64(5)
+		return flatReading{Station: "KMIA", Count: 1, Note: &note, Samples: &samples},
+			taggedReading{Station: "KMIA", Count: 1, Note: &note, Samples: &samples}
+	}
+
+	for _, tt := range corpus {
+		t.Run(tt.name, func(t *testing.T) {
+			fast, slow := newTargets()
+			fastResult, fastErr := ApplyChanges(copyChanges(tt.changes), &fast)
+			slowResult, slowErr := ApplyChanges(copyChanges(tt.changes), &slow, WithDecodeHook(noopHook))
+
+			if (fastErr == nil) != (slowErr == nil) || (fastErr != nil && fastErr.Error() != slowErr.Error()) {
+				t.Fatalf("errors differ: fast path %v, mapstructure %v", fastErr, slowErr)
+			}
+			if twin := (flatReading{slow.Station, slow.Celsius, slow.Count, slow.Samples, slow.Valid, slow.Note, slow.ObservedAt, slow.ReviewedAt}); !reflect.DeepEqual(fast, twin) {
+				t.Errorf("targets differ:\nfast path    %+v\nmapstructure %+v", fast, twin)
+			}
+			if !reflect.DeepEqual(fastResult, slowResult) {
+				t.Errorf("results differ:\nfast path    %+v\nmapstructure %+v", fastResult, slowResult)
+			}
+		})
+	}
+}
+
+func BenchmarkFlatStruct(b *testing.B) {
+	changes := map[string]interface{}{"station": "KTPA", "celsius": 21.5, "count": 4, "valid": true}
+
+	b.Run("fast path", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var reading flatReading
+			if _, err := ApplyChanges(copyChanges(changes), &reading); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("mapstructure", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var reading taggedReading
+			if _, err := ApplyChanges(copyChanges(changes), &reading, WithDecodeHook(noopHook)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// FieldError is a failure to apply the change at one field path, e.g.
+// "addresses[2].zip"
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("'%s': %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors holds every field that failed to decode, so they can all be
+// reported at once rather than just the first.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	noun := "errors"
+	if len(e) == 1 {
+		noun = "error"
+	}
+
+	return fmt.Sprintf("%d %s applying changes: %s", len(e), noun, strings.Join(messages, "; "))
+}
+
+// Unwrap returns the individual FieldErrors, for errors.Is and errors.As on Go
+// 1.20 and later
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+var (
+	// the ways mapstructure names the field at fault, see its decode funcs
+	decodingErrorPattern   = regexp.MustCompile(`^error decoding '([^']*)': (.*)$`)
+	invalidKeysPattern     = regexp.MustCompile(`^'([^']*)' has invalid keys: (.*)$`)
+	quotedFieldPattern     = regexp.MustCompile(`^'([^']*)':? (.*)$`)
+	parseFieldErrorPattern = regexp.MustCompile(`^cannot parse '([^']*)'`)
+)
+
+// decodeFieldErrors splits mapstructure's error summary into a FieldError per
+// failing field, sorted by path. Other errors are returned as they are.
+func decodeFieldErrors(err error) error {
+	var decodeErr *mapstructure.Error
+	if !errors.As(err, &decodeErr) {
+		return err
+	}
+
+	var fieldErrors FieldErrors
+	for _, message := range decodeErr.Errors {
+		fieldErrors = append(fieldErrors, parseDecodeError(message)...)
+	}
+
+	sort.SliceStable(fieldErrors, func(i, j int) bool {
+		return fieldErrors[i].Path < fieldErrors[j].Path
+	})
+
+	return fieldErrors
+}
+
+func parseDecodeError(message string) []*FieldError {
+	if match := decodingErrorPattern.FindStringSubmatch(message); match != nil {
+		return []*FieldError{{Path: match[1], Err: errors.New(match[2])}}
+	}
+
+	if match := invalidKeysPattern.FindStringSubmatch(message); match != nil {
+		var fieldErrors []*FieldError
+		for _, key := range strings.Split(match[2], ", ") {
+			path := key
+			if match[1] != "" {
+				path = match[1] + "." + key
+			}
+			fieldErrors = append(fieldErrors, &FieldError{Path: path, Err: errors.New("no such field")})
+		}
+		return fieldErrors
+	}
+
+	if match := parseFieldErrorPattern.FindStringSubmatch(message); match != nil {
+		return []*FieldError{{Path: match[1], Err: errors.New(strings.Replace(message, " '"+match[1]+"'", "", 1))}}
+	}
+
+	if match := quotedFieldPattern.FindStringSubmatch(message); match != nil {
+		return []*FieldError{{Path: match[1], Err: errors.New(match[2])}}
+	}
+
+	return []*FieldError{{Err: errors.New(message)}}
+}
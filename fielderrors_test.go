@@ -0,0 +1,156 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fieldErrorItem struct {
+	Zip int `json:"zip"`
+}
+
+type fieldErrorRecord struct {
+	Name    string           `json:"name"`
+	Count   int              `json:"count"`
+	Active  bool             `json:"active"`
+	Details nestedDetails    `json:"details"`
+	Items   []fieldErrorItem `json:"items"`
+}
+
+func TestFieldErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    []string
+	}{
+		{
+			name:    "one field",
+			changes: map[string]interface{}{"count": "many", "name": "Tampa"},
+			want:    []string{"'count': expected type 'int', got unconvertible type 'string', value: 'many'"},
+		},
+		{
+			name:    "every field, sorted",
+			changes: map[string]interface{}{"count": "many", "active": "yes", "bogus": 1},
+			want: []string{
+				"'active': expected type 'bool', got unconvertible type 'string', value: 'yes'",
+				"'bogus': no such field",
+				"'count': expected type 'int', got unconvertible type 'string', value: 'many'",
+			},
+		},
+		{
+			name:    "nested fields",
+			changes: map[string]interface{}{"details": map[string]interface{}{"level": "high", "extra": 1}},
+			want: []string{
+				"'details.extra': no such field",
+				"'details.level': expected type 'int', got unconvertible type 'string', value: 'high'",
+			},
+		},
+		{
+			name:    "slice elements",
+			changes: map[string]interface{}{"items": []interface{}{map[string]interface{}{"zip": 33601}, map[string]interface{}{"zip": "x"}}},
+			want:    []string{"'items[1].zip': expected type 'int', got unconvertible type 'string', value: 'x'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := fieldErrorRecord{}
+			_, err := ApplyChanges(tt.changes, &record)
+
+			var fieldErrors FieldErrors
+			if !errors.As(err, &fieldErrors) {
+				t.Fatalf("ApplyChanges() error = %v, want FieldErrors", err)
+			}
+
+			var got []string
+			for _, fieldError := range fieldErrors {
+				got = append(got, fieldError.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FieldErrors = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(record, fieldErrorRecord{}) {
+				t.Errorf("record = %+v, want it untouched", record)
+			}
+		})
+	}
+}
+
+func TestFieldErrorsError(t *testing.T) {
+	cause := errors.New("too long")
+
+	tests := []struct {
+		name string
+		err  FieldErrors
+		want string
+	}{
+		{
+			name: "one",
+			err:  FieldErrors{{Path: "name", Err: cause}},
+			want: "1 error applying changes: 'name': too long",
+		},
+		{
+			name: "several",
+			err:  FieldErrors{{Path: "name", Err: cause}, {Path: "zip", Err: errors.New("no such field")}},
+			want: "2 errors applying changes: 'name': too long; 'zip': no such field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+			if unwrapped := tt.err.Unwrap(); len(unwrapped) != len(tt.err) || !errors.Is(unwrapped[0], cause) {
+				t.Errorf("Unwrap() = %v, want the FieldErrors", unwrapped)
+			}
+		})
+	}
+}
+
+func TestParseDecodeError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    []FieldError
+	}{
+		{
+			message: "error decoding 'count': invalid",
+			want:    []FieldError{{Path: "count", Err: errors.New("invalid")}},
+		},
+		{
+			message: "'details' has invalid keys: a, b",
+			want:    []FieldError{{Path: "details.a", Err: errors.New("no such field")}, {Path: "details.b", Err: errors.New("no such field")}},
+		},
+		{
+			message: "'' has invalid keys: bogus",
+			want:    []FieldError{{Path: "bogus", Err: errors.New("no such field")}},
+		},
+		{
+			message: "cannot parse 'count' as int: strconv.ParseInt: invalid syntax",
+			want:    []FieldError{{Path: "count", Err: errors.New("cannot parse as int: strconv.ParseInt: invalid syntax")}},
+		},
+		{
+			message: "'active' expected type 'bool'",
+			want:    []FieldError{{Path: "active", Err: errors.New("expected type 'bool'")}},
+		},
+		{
+			message: "something else",
+			want:    []FieldError{{Err: errors.New("something else")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			got := parseDecodeError(tt.message)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseDecodeError() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Path != tt.want[i].Path || got[i].Err.Error() != tt.want[i].Err.Error() {
+					t.Errorf("parseDecodeError()[%d] = %v, want %v", i, got[i], &tt.want[i])
+				}
+			}
+		})
+	}
+}
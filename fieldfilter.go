@@ -0,0 +1,93 @@
+package applychanges
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AdminRole is the Principal.Role allowed to change WithAdminOnlyFields fields
+const AdminRole = "admin"
+
+// DisallowedFieldsError is returned when the changes address fields excluded by
+// WithAllowedFields, WithDeniedFields or WithAdminOnlyFields
+type DisallowedFieldsError struct {
+	// Fields are the offending keys as they appeared in the changes, sorted
+	Fields []string
+}
+
+func (e *DisallowedFieldsError) Error() string {
+	return fmt.Sprintf("changes to %s are not allowed", quoteFields(e.Fields))
+}
+
+// filterFields enforces the allowed and denied fields, dropping the keys that
+// aren't allowed (and returning them) or failing on them
+func filterFields(changes map[string]interface{}, to interface{}, cfg *config) ([]string, error) {
+	if cfg.allowedFields == nil && cfg.deniedFields == nil && cfg.adminOnlyFields == nil {
+		return nil, nil
+	}
+
+	var disallowed []string
+	for key := range changes {
+		if !fieldAllowed(key, to, cfg) {
+			disallowed = append(disallowed, key)
+		}
+	}
+
+	if len(disallowed) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(disallowed)
+	if !cfg.dropDisallowed {
+		return nil, &DisallowedFieldsError{Fields: disallowed}
+	}
+
+	for _, key := range disallowed {
+		delete(changes, key)
+	}
+
+	return disallowed, nil
+}
+
+// fieldAllowed checks a key against the allowed, denied and admin-only fields,
+// by the tag name of the field it resolves to (or the key itself for unknown
+// keys)
+func fieldAllowed(key string, to interface{}, cfg *config) bool {
+	name := key
+	if target, ok := targetStruct(to); ok {
+		if field, ok := structFieldByTag(target.Type(), cfg.tagName, key); ok {
+			name = fieldKey(field, cfg.tagName)
+		}
+	}
+
+	return nameAllowed(name, cfg)
+}
+
+// nameAllowed checks a field's tag name against the allowed, denied and
+// admin-only fields
+func nameAllowed(name string, cfg *config) bool {
+	if cfg.deniedFields[name] {
+		return false
+	}
+
+	if cfg.adminOnlyFields[name] && (cfg.principal == nil || cfg.principal.Role != AdminRole) {
+		return false
+	}
+
+	return cfg.allowedFields == nil || cfg.allowedFields[name]
+}
+
+// quoteFields renders field names for error messages: 'a', 'b' and 'c'
+func quoteFields(fields []string) string {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = "'" + field + "'"
+	}
+
+	if len(quoted) <= 1 {
+		return strings.Join(quoted, "")
+	}
+
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " and " + quoted[len(quoted)-1]
+}
@@ -0,0 +1,103 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFieldFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		opts        []Option
+		principal   *Principal
+		want        resultRecord
+		wantDropped []string
+		wantErr     []string
+	}{
+		{
+			name:    "allowed",
+			changes: map[string]interface{}{"city": "Tampa"},
+			opts:    []Option{WithAllowedFields("city")},
+			want:    resultRecord{City: "Tampa"},
+		},
+		{
+			name:    "not allowed",
+			changes: map[string]interface{}{"city": "Tampa", "weather": "Fog", "zip": "33602"},
+			opts:    []Option{WithAllowedFields("city")},
+			wantErr: []string{"weather", "zip"},
+		},
+		{
+			name:    "denied",
+			changes: map[string]interface{}{"city": "Tampa", "weather": "Fog"},
+			opts:    []Option{WithDeniedFields("weather")},
+			wantErr: []string{"weather"},
+		},
+		{
+			name:    "denied wins over allowed",
+			changes: map[string]interface{}{"city": "Tampa"},
+			opts:    []Option{WithAllowedFields("city"), WithDeniedFields("city")},
+			wantErr: []string{"city"},
+		},
+		{
+			name:    "by tag name whatever the key's case",
+			changes: map[string]interface{}{"Weather": "Fog"},
+			opts:    []Option{WithDeniedFields("weather"), WithCaseInsensitiveKeys()},
+			wantErr: []string{"Weather"},
+		},
+		{
+			name:        "dropped",
+			changes:     map[string]interface{}{"city": "Tampa", "weather": "Fog"},
+			opts:        []Option{WithDeniedFields("weather"), WithDropDisallowedFields()},
+			want:        resultRecord{City: "Tampa"},
+			wantDropped: []string{"weather"},
+		},
+		{
+			name:    "admin only",
+			changes: map[string]interface{}{"city": "Tampa"},
+			opts:    []Option{WithAdminOnlyFields("city")},
+			wantErr: []string{"city"},
+		},
+		{
+			name:      "admin only, as an admin",
+			changes:   map[string]interface{}{"city": "Tampa"},
+			opts:      []Option{WithAdminOnlyFields("city")},
+			principal: &Principal{ID: "EUA1", Role: AdminRole},
+			want:      resultRecord{City: "Tampa"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := resultRecord{City: "Miami"}
+			var result ApplyResult
+			var err error
+			if tt.principal != nil {
+				result, err = ApplyChangesAs(tt.changes, *tt.principal, &record, tt.opts...)
+			} else {
+				result, err = ApplyChangesWrapper(tt.changes, "EUA1", &record, tt.opts...)
+			}
+			if tt.wantErr != nil {
+				var disallowed *DisallowedFieldsError
+				if !errors.As(err, &disallowed) || !reflect.DeepEqual(disallowed.Fields, tt.wantErr) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %v disallowed", err, tt.wantErr)
+				}
+				if record.City != "Miami" {
+					t.Errorf("city = %q, want nothing applied", record.City)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if record.City != tt.want.City || !reflect.DeepEqual(record.Weather, tt.want.Weather) {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+			if !reflect.DeepEqual(result.DroppedFields, tt.wantDropped) {
+				t.Errorf("DroppedFields = %v, want %v", result.DroppedFields, tt.wantDropped)
+			}
+		})
+	}
+}
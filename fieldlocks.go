@@ -0,0 +1,130 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldLocker returns the locks held on the fields of an entity, by field path
+// (of tag names, e.g. "address.city") to the ID of the principal holding them,
+// e.g. from a collaborative editor's lock table; see WithFieldLocker
+type FieldLocker interface {
+	FieldLocks(ctx context.Context, entity interface{}) (map[string]string, error)
+}
+
+// FieldLockerFunc adapts a function to a FieldLocker
+type FieldLockerFunc func(ctx context.Context, entity interface{}) (map[string]string, error)
+
+func (f FieldLockerFunc) FieldLocks(ctx context.Context, entity interface{}) (map[string]string, error) {
+	return f(ctx, entity)
+}
+
+// FieldLockedError is a change to a field locked by another principal, see
+// WithFieldLocks
+type FieldLockedError struct {
+	// Field is the path of the change, e.g. "address.city"
+	Field string
+
+	// Holder is the ID of the principal holding the lock
+	Holder string
+}
+
+func (e *FieldLockedError) Error() string {
+	return fmt.Sprintf("'%s' is being edited by %s", e.Field, e.Holder)
+}
+
+// FieldLockedErrors are all the changes to fields locked by other principals,
+// sorted by field
+type FieldLockedErrors []*FieldLockedError
+
+func (e FieldLockedErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return "fields are locked: " + strings.Join(messages, "; ")
+}
+
+// Unwrap returns the individual FieldLockedErrors, for errors.Is and errors.As
+// on Go 1.20 and later
+func (e FieldLockedErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// checkFieldLocks fails with every change to a field (or a struct containing
+// it) locked by someone other than the apply's principal, see
+// authorizedPrincipal
+func checkFieldLocks(changes map[string]interface{}, target reflect.Value, entity interface{}, cfg *config) error {
+	locks := cfg.fieldLocks
+	if cfg.fieldLocker != nil {
+		fetched, err := cfg.fieldLocker.FieldLocks(cfg.ctx, entity)
+		if err != nil {
+			return fmt.Errorf("fetching the field locks: %w", err)
+		}
+
+		locks = make(map[string]string, len(cfg.fieldLocks)+len(fetched))
+		for path, holder := range cfg.fieldLocks {
+			locks[path] = holder
+		}
+		for path, holder := range fetched {
+			locks[path] = holder
+		}
+	}
+	if len(locks) == 0 {
+		return nil
+	}
+
+	locked := lockedFields(changes, target.Type(), locks, authorizedPrincipal(cfg).ID, cfg.tagName, "", "")
+	if len(locked) == 0 {
+		return nil
+	}
+
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].Field < locked[j].Field
+	})
+	return locked
+}
+
+// lockedFields lists the changes to fields locked by anyone but the holder.
+// Locks name fields by tag name, so the path of tag names leading to the
+// changes is tracked alongside the path of their keys, as for
+// immutableFields.
+func lockedFields(changes map[string]interface{}, structType reflect.Type, locks map[string]string, holder string, tagName string, prefix string, tagPrefix string) FieldLockedErrors {
+	var locked FieldLockedErrors
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, tagName, key)
+		if !ok {
+			continue
+		}
+
+		tagPath := tagPrefix + fieldKey(field, tagName)
+		if lockedBy, ok := locks[tagPath]; ok && lockedBy != holder {
+			locked = append(locked, &FieldLockedError{Field: prefix + key, Holder: lockedBy})
+			continue
+		}
+
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			locked = append(locked, lockedFields(nested, fieldType, locks, holder, tagName, prefix+key+".", tagPath+".")...)
+		}
+	}
+
+	return locked
+}
@@ -0,0 +1,134 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type lockedRecord struct {
+	BaseStruct
+	Name    string         `json:"name"`
+	Notes   string         `json:"notes"`
+	Details *nestedDetails `json:"details"`
+}
+
+func TestWithFieldLocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		locks   map[string]string
+		wantErr error
+	}{
+		{
+			name:    "own lock",
+			changes: map[string]interface{}{"name": "Tampa"},
+			locks:   map[string]string{"name": "EUA1"},
+		},
+		{
+			name:    "unlocked field",
+			changes: map[string]interface{}{"notes": "windy"},
+			locks:   map[string]string{"name": "EUA2"},
+		},
+		{
+			name:    "foreign lock",
+			changes: map[string]interface{}{"name": "Tampa", "notes": "windy"},
+			locks:   map[string]string{"name": "EUA2"},
+			wantErr: FieldLockedErrors{{Field: "name", Holder: "EUA2"}},
+		},
+		{
+			name:    "violations listed together",
+			changes: map[string]interface{}{"name": "Tampa", "notes": "windy", "details": map[string]interface{}{"source": "buoy", "level": 3}},
+			locks:   map[string]string{"name": "EUA2", "notes": "EUA1", "details.level": "EUA3"},
+			wantErr: FieldLockedErrors{{Field: "details.level", Holder: "EUA3"}, {Field: "name", Holder: "EUA2"}},
+		},
+		{
+			name:    "locked struct",
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": "buoy"}},
+			locks:   map[string]string{"details": "EUA2"},
+			wantErr: FieldLockedErrors{{Field: "details", Holder: "EUA2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := lockedRecord{Name: "Miami", Notes: "calm", Details: &nestedDetails{Source: "survey", Level: 1}}
+			_, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, WithFieldLocks(tt.locks))
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && (record.Name != "Miami" || record.Notes != "calm" || *record.Details != (nestedDetails{Source: "survey", Level: 1})) {
+				t.Errorf("a locked apply changed the target to %+v", record)
+			}
+		})
+	}
+}
+
+func TestWithFieldLocker(t *testing.T) {
+	errLocks := errors.New("lock table unavailable")
+
+	tests := []struct {
+		name    string
+		locker  FieldLockerFunc
+		locks   map[string]string
+		wantErr string
+	}{
+		{
+			name: "locks for the entity",
+			locker: func(ctx context.Context, entity interface{}) (map[string]string, error) {
+				if entity.(*lockedRecord).Name != "Miami" {
+					t.Errorf("locker given %+v, want the target before the apply", entity)
+				}
+				return map[string]string{"notes": "EUA2"}, nil
+			},
+			wantErr: "fields are locked: 'notes' is being edited by EUA2",
+		},
+		{
+			name: "on top of WithFieldLocks",
+			locker: func(ctx context.Context, entity interface{}) (map[string]string, error) {
+				return map[string]string{"notes": "EUA1"}, nil
+			},
+			locks:   map[string]string{"name": "EUA3"},
+			wantErr: "fields are locked: 'name' is being edited by EUA3",
+		},
+		{
+			name: "no locks",
+			locker: func(ctx context.Context, entity interface{}) (map[string]string, error) {
+				return nil, nil
+			},
+		},
+		{
+			name: "failing locker",
+			locker: func(ctx context.Context, entity interface{}) (map[string]string, error) {
+				return nil, errLocks
+			},
+			wantErr: "fetching the field locks: lock table unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := lockedRecord{Name: "Miami"}
+			_, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa", "notes": "windy"}, "EUA1", &record, WithFieldLocker(tt.locker), WithFieldLocks(tt.locks))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ApplyChangesWrapper() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithFieldLocksWithoutModifier(t *testing.T) {
+	record := lockedRecord{}
+	_, err := ApplyChanges(map[string]interface{}{"name": "Tampa"}, &record, WithFieldLocks(map[string]string{"name": "EUA2"}))
+	var locked FieldLockedErrors
+	if !errors.As(err, &locked) || len(locked) != 1 {
+		t.Errorf("ApplyChanges() error = %v, want the lock to hold against an apply by nobody", err)
+	}
+}
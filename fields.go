@@ -0,0 +1,182 @@
+package applychanges
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// squashedFieldsCache holds the squashedFields of every struct type seen, and
+// tagIndexCache the fields' positions by key for every struct type and tag
+// name; both only depend on the type, so they are computed once per process
+var (
+	squashedFieldsCache sync.Map // reflect.Type -> []reflect.StructField
+	tagIndexCache       sync.Map // tagIndexKey -> *fieldIndex
+)
+
+type tagIndexKey struct {
+	structType reflect.Type
+	tagName    string
+}
+
+// fieldIndex holds the positions of a struct type's squashedFields by key, and
+// by lowercased key for case-insensitive lookups. Those are only equivalent to
+// strings.EqualFold for ASCII, so ascii records whether every key is.
+type fieldIndex struct {
+	exact  map[string]int
+	folded map[string]int
+	ascii  bool
+}
+
+// squashedFields lists the fields of a struct type the way the decoder sees
+// them: the fields of anonymous embedded structs are listed in place of the
+// embedded struct itself, after the outer struct's own fields. Each returned
+// field's Index is the full path from structType. The slice is shared, so it
+// must not be modified.
+func squashedFields(structType reflect.Type) []reflect.StructField {
+	if fields, ok := squashedFieldsCache.Load(structType); ok {
+		return fields.([]reflect.StructField)
+	}
+
+	fields, _ := squashedFieldsCache.LoadOrStore(structType, listSquashedFields(structType))
+	return fields.([]reflect.StructField)
+}
+
+func listSquashedFields(structType reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+
+	type embedded struct {
+		structType reflect.Type
+		index      []int
+	}
+
+	structs := []embedded{{structType: structType}}
+	for len(structs) > 0 {
+		current := structs[0]
+		structs = structs[1:]
+
+		for i := 0; i < current.structType.NumField(); i++ {
+			field := current.structType.Field(i)
+			field.Index = append(append([]int(nil), current.index...), i)
+
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				structs = append(structs, embedded{structType: field.Type, index: field.Index})
+				continue
+			}
+
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// fieldKey returns the changes key a field is decoded from: its tag name, or
+// its Go name when untagged. Fields tagged "-" have no key.
+func fieldKey(field reflect.StructField, tagName string) string {
+	name := strings.SplitN(field.Tag.Get(tagName), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// structFieldByTag finds the field of a struct type that mapstructure would
+// decode key into: an exact match of the key is preferred, and a
+// case-insensitive match is used as a fallback just like mapstructure's
+// default MatchName.
+func structFieldByTag(structType reflect.Type, tagName string, key string) (reflect.StructField, bool) {
+	fields := squashedFields(structType)
+	index := tagIndex(structType, tagName)
+	if i, ok := index.exact[key]; ok {
+		return fields[i], true
+	}
+
+	if index.ascii && isASCII(key) {
+		if i, ok := index.folded[strings.ToLower(key)]; ok {
+			return fields[i], true
+		}
+		return reflect.StructField{}, false
+	}
+
+	for _, field := range fields {
+		if name := fieldKey(field, tagName); name != "" && strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// tagIndex maps the key of each of the struct type's squashedFields to its
+// position, keeping the first field for a key
+func tagIndex(structType reflect.Type, tagName string) *fieldIndex {
+	cacheKey := tagIndexKey{structType: structType, tagName: tagName}
+	if index, ok := tagIndexCache.Load(cacheKey); ok {
+		return index.(*fieldIndex)
+	}
+
+	index := &fieldIndex{exact: map[string]int{}, folded: map[string]int{}, ascii: true}
+	for i, field := range squashedFields(structType) {
+		name := fieldKey(field, tagName)
+		if name == "" {
+			continue
+		}
+
+		if _, ok := index.exact[name]; !ok {
+			index.exact[name] = i
+		}
+		if _, ok := index.folded[strings.ToLower(name)]; !ok {
+			index.folded[strings.ToLower(name)] = i
+		}
+		index.ascii = index.ascii && isASCII(name)
+	}
+
+	cached, _ := tagIndexCache.LoadOrStore(cacheKey, index)
+	return cached.(*fieldIndex)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fieldByTag finds the field of a struct value that mapstructure would decode
+// key into (see structFieldByTag).
+func fieldByTag(structVal reflect.Value, tagName string, key string) (reflect.Value, bool) {
+	field, ok := structFieldByTag(structVal.Type(), tagName, key)
+	if !ok {
+		return reflect.Value{}, false
+	}
+
+	return structVal.FieldByIndex(field.Index), true
+}
+
+// structFieldByName finds a field by its Go name, searching squashed embedded
+// structs
+func structFieldByName(structType reflect.Type, name string) (reflect.StructField, bool) {
+	for _, field := range squashedFields(structType) {
+		if field.Name == name {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// targetStruct returns the struct a target points to, or false if it isn't a
+// (pointer to a) struct
+func targetStruct(to interface{}) (reflect.Value, bool) {
+	target := reflect.Indirect(reflect.ValueOf(to))
+	return target, target.Kind() == reflect.Struct
+}
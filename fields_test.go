@@ -0,0 +1,102 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fieldsInner struct {
+	Source string `json:"source"`
+	Level  int    `json:"level"`
+}
+
+type fieldsRecord struct {
+	fieldsInner
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	TITLE   string `json:"TITLE"`
+	Ignored string `json:"-"`
+	Plain   string
+	Straße  string `json:"straße"`
+}
+
+func TestSquashedFields(t *testing.T) {
+	fields := squashedFields(reflect.TypeOf(fieldsRecord{}))
+
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	want := []string{"Name", "Title", "TITLE", "Ignored", "Plain", "Straße", "Source", "Level"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("squashedFields() = %v, want %v, embedded fields last", names, want)
+	}
+
+	if source := fields[len(fields)-2]; !reflect.DeepEqual(source.Index, []int{0, 0}) {
+		t.Errorf("Source.Index = %v, want the full path [0 0]", source.Index)
+	}
+
+	again := squashedFields(reflect.TypeOf(fieldsRecord{}))
+	if &again[0] != &fields[0] {
+		t.Error("squashedFields() listed the fields again, want them cached")
+	}
+}
+
+func TestStructFieldByTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		tagName string
+		key     string
+		want    string
+		wantOK  bool
+	}{
+		{name: "exact", tagName: "json", key: "name", want: "Name", wantOK: true},
+		{name: "case-insensitive", tagName: "json", key: "NAME", want: "Name", wantOK: true},
+		{name: "exact preferred", tagName: "json", key: "TITLE", want: "TITLE", wantOK: true},
+		{name: "first fold kept", tagName: "json", key: "Title", want: "Title", wantOK: true},
+		{name: "embedded", tagName: "json", key: "level", want: "Level", wantOK: true},
+		{name: "untagged by Go name", tagName: "json", key: "plain", want: "Plain", wantOK: true},
+		{name: "non-ASCII", tagName: "json", key: "STRASSE", wantOK: false},
+		{name: "non-ASCII fold", tagName: "json", key: "STRAßE", want: "Straße", wantOK: true},
+		{name: "tagged -", tagName: "json", key: "Ignored", wantOK: false},
+		{name: "unknown", tagName: "json", key: "zip", wantOK: false},
+		{name: "other tag name", tagName: "db", key: "title", want: "Title", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field, ok := structFieldByTag(reflect.TypeOf(fieldsRecord{}), tt.tagName, tt.key)
+			if ok != tt.wantOK || field.Name != tt.want {
+				t.Errorf("structFieldByTag(%q) = %q, %v, want %q, %v", tt.key, field.Name, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFieldByTag(t *testing.T) {
+	record := fieldsRecord{fieldsInner: fieldsInner{Level: 3}, Name: "Miami"}
+	target := reflect.ValueOf(&record).Elem()
+
+	if value, ok := fieldByTag(target, "json", "level"); !ok || value.Int() != 3 {
+		t.Errorf("fieldByTag(level) = %v, %v, want 3", value, ok)
+	}
+	if _, ok := fieldByTag(target, "json", "zip"); ok {
+		t.Error("fieldByTag(zip) found a field")
+	}
+
+	if field, ok := structFieldByName(target.Type(), "Source"); !ok || !reflect.DeepEqual(field.Index, []int{0, 0}) {
+		t.Errorf("structFieldByName(Source) = %v, %v, want the embedded field", field.Index, ok)
+	}
+	if _, ok := structFieldByName(target.Type(), "source"); ok {
+		t.Error("structFieldByName(source) matched case-insensitively")
+	}
+}
+
+func TestDecodeHookCached(t *testing.T) {
+	cfg := configFor(reflect.TypeOf(fieldsRecord{}), nil)
+	first, second := cfg.decodeHook(), cfg.decodeHook()
+
+	if reflect.ValueOf(first).Pointer() != reflect.ValueOf(second).Pointer() {
+		t.Error("decodeHook() composed the hook chain again, want it cached")
+	}
+}
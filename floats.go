@@ -0,0 +1,298 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// NonFiniteFloatError is returned when a number in the changes (at any depth)
+// is NaN or ±Inf. Such values can't be compared for no-ops or encoded as JSON,
+// so they are rejected rather than stored, unless WithAllowNonFiniteFloats.
+type NonFiniteFloatError struct {
+	// Field is the path to the offending value, e.g. `readings[3]`
+	Field string
+	Value float64
+}
+
+func (e *NonFiniteFloatError) Error() string {
+	return fmt.Sprintf("'%s' must be a finite number, got %v", e.Field, e.Value)
+}
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// checkFloat fails on a NaN or infinite float, see walkChanges
+func checkFloat(path string, value reflect.Value) error {
+	if value.Kind() != reflect.Float32 && value.Kind() != reflect.Float64 {
+		return nil
+	}
+
+	if f := value.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+		return &NonFiniteFloatError{Field: path, Value: f}
+	}
+
+	return nil
+}
+
+// errNonFinite stops the walk of hasNonFinite at the first non-finite float
+var errNonFinite = errors.New("non-finite float")
+
+// hasNonFinite reports whether the value holds a NaN or infinite float at any
+// depth, see walkValue
+func hasNonFinite(value interface{}) bool {
+	_, err := walkValue("", reflect.ValueOf(value), func(path string, value reflect.Value) (reflect.Value, error) {
+		if checkFloat(path, value) != nil {
+			return reflect.Value{}, errNonFinite
+		}
+		return reflect.Value{}, nil
+	})
+
+	return err == errNonFinite
+}
+
+// finiteValue returns the value the way it's output (see
+// WithAllowNonFiniteFloats): as it is unless it holds a NaN or infinite float,
+// in which case it's rebuilt from plain slices and maps (structs by their json
+// keys) with those floats as the strings "NaN", "+Inf" and "-Inf", since JSON
+// has no other way to hold them
+func finiteValue(value interface{}) interface{} {
+	if !hasNonFinite(value) {
+		return value
+	}
+
+	return jsonSafe(reflect.ValueOf(value))
+}
+
+func jsonSafe(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch f := value.Float(); {
+		case math.IsNaN(f):
+			return "NaN"
+		case math.IsInf(f, 1):
+			return "+Inf"
+		case math.IsInf(f, -1):
+			return "-Inf"
+		}
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return jsonSafe(value.Elem())
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		elements := make([]interface{}, value.Len())
+		for i := range elements {
+			elements[i] = jsonSafe(value.Index(i))
+		}
+		return elements
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		entries := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = jsonSafe(iter.Value())
+		}
+		return entries
+	case reflect.Struct:
+		if value.Type().Implements(jsonMarshalerType) || !hasExportedFields(value.Type()) {
+			break
+		}
+		fields := map[string]interface{}{}
+		for _, field := range squashedFields(value.Type()) {
+			key := fieldKey(field, "json")
+			if key == "" || field.PkgPath != "" {
+				continue
+			}
+			fieldValue, err := value.FieldByIndexErr(field.Index)
+			if err != nil || (fieldValue.IsZero() && strings.Contains(field.Tag.Get("json"), ",omitempty")) {
+				continue
+			}
+			fields[key] = jsonSafe(fieldValue)
+		}
+		return fields
+	}
+
+	return value.Interface()
+}
+
+// sameValue reports whether an apply left a value as it was, comparing floats
+// bit for bit: NaN is the same as NaN, 0 isn't the same as -0, and 0.1+0.2
+// isn't the same as 0.3 (see WithFloatTolerance for fields where it should
+// be). It's otherwise reflect.DeepEqual.
+func sameValue(a, b interface{}) bool {
+	return sameBits(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func sameBits(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(a.Float()) == math.Float64bits(b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		x, y := a.Complex(), b.Complex()
+		return math.Float64bits(real(x)) == math.Float64bits(real(y)) && math.Float64bits(imag(x)) == math.Float64bits(imag(y))
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return sameBits(a.Elem(), b.Elem())
+	case reflect.Slice:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if !sameBits(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			other := b.MapIndex(iter.Key())
+			if !other.IsValid() || !sameBits(iter.Value(), other) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !sameBits(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.String:
+		return a.String() == b.String()
+	}
+
+	// funcs, channels and unsafe pointers, by identity
+	return a.Pointer() == b.Pointer()
+}
+
+// applyFloatTolerance makes the floats in the changes that are within the
+// WithFloatTolerance of the target's current value exactly that value, so
+// they're no-ops. Tolerances are given by field path (e.g. `readings` or
+// `station.temperature`), and cover the elements of slice fields by index.
+func (cfg *config) applyFloatTolerance(changes map[string]interface{}, target reflect.Value) error {
+	return walkChanges(changes, func(path string, value reflect.Value) (reflect.Value, error) {
+		eps, ok := cfg.floatTolerance(path)
+		if !ok {
+			return reflect.Value{}, nil
+		}
+
+		var changed float64
+		switch {
+		case value.Kind() == reflect.Float32 || value.Kind() == reflect.Float64:
+			changed = value.Float()
+		case value.Type() == jsonNumberType:
+			parsed, err := strconv.ParseFloat(value.String(), 64)
+			if err != nil {
+				return reflect.Value{}, nil
+			}
+			changed = parsed
+		default:
+			return reflect.Value{}, nil
+		}
+
+		current, ok := currentFloat(target, cfg.tagName, path)
+		if !ok || !(math.Abs(changed-current) <= eps) {
+			return reflect.Value{}, nil
+		}
+
+		if value.Type() == jsonNumberType {
+			return reflect.ValueOf(json.Number(strconv.FormatFloat(current, 'g', -1, 64))), nil
+		}
+		return reflect.ValueOf(current).Convert(value.Type()), nil
+	})
+}
+
+// floatTolerance returns the tolerance for the value at a path, which is that
+// of its field
+func (cfg *config) floatTolerance(path string) (float64, bool) {
+	if eps, ok := cfg.floatTolerances[path]; ok {
+		return eps, true
+	}
+
+	if i := strings.IndexByte(path, '['); i >= 0 {
+		eps, ok := cfg.floatTolerances[path[:i]]
+		return eps, ok
+	}
+
+	return 0, false
+}
+
+// currentFloat reads the float at a path of the changes (fields by tag name,
+// elements by index) from the target
+func currentFloat(target reflect.Value, tagName string, path string) (float64, bool) {
+	value := target
+	for _, segment := range strings.Split(path, ".") {
+		name, indexes := segment, ""
+		if i := strings.IndexByte(segment, '['); i >= 0 {
+			name, indexes = segment[:i], segment[i:]
+		}
+
+		value = reflect.Indirect(value)
+		if value.Kind() != reflect.Struct {
+			return 0, false
+		}
+		field, ok := structFieldByTag(value.Type(), tagName, name)
+		if !ok {
+			return 0, false
+		}
+		var err error
+		if value, err = value.FieldByIndexErr(field.Index); err != nil {
+			return 0, false
+		}
+
+		for indexes != "" {
+			end := strings.IndexByte(indexes, ']')
+			index, err := strconv.Atoi(indexes[1:end])
+			indexes = indexes[end+1:]
+
+			value = reflect.Indirect(value)
+			if err != nil || (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) || index >= value.Len() {
+				return 0, false
+			}
+			value = value.Index(index)
+		}
+	}
+
+	value = reflect.Indirect(value)
+	if value.Kind() != reflect.Float32 && value.Kind() != reflect.Float64 {
+		return 0, false
+	}
+
+	return value.Float(), true
+}
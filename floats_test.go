@@ -0,0 +1,246 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type sensorStation struct {
+	Name     string  `json:"name"`
+	Pressure float64 `json:"pressure"`
+}
+
+type sensorReading struct {
+	BaseStruct
+	Temperature float64       `json:"temperature"`
+	Humidity    float32       `json:"humidity"`
+	Samples     []float64     `json:"samples"`
+	Station     sensorStation `json:"station"`
+}
+
+// nearlyPointThree is 0.1+0.2 computed at run time, which isn't 0.3
+var nearlyPointThree = func() float64 {
+	a, b := 0.1, 0.2
+	return a + b
+}()
+
+func TestNonFiniteFloats(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		allow   bool
+		wantErr error
+		wantNew string
+	}{
+		{
+			name:    "NaN rejected",
+			changes: map[string]interface{}{"temperature": math.NaN()},
+			wantErr: &NonFiniteFloatError{Field: "temperature", Value: math.NaN()},
+		},
+		{
+			name:    "nested Inf rejected",
+			changes: map[string]interface{}{"samples": []interface{}{1.0, math.Inf(-1)}},
+			wantErr: &NonFiniteFloatError{Field: "samples[1]", Value: math.Inf(-1)},
+		},
+		{
+			name:    "NaN allowed",
+			changes: map[string]interface{}{"temperature": math.NaN()},
+			allow:   true,
+			wantNew: `"NaN"`,
+		},
+		{
+			name:    "Inf allowed",
+			changes: map[string]interface{}{"temperature": math.Inf(1)},
+			allow:   true,
+			wantNew: `"+Inf"`,
+		},
+		{
+			name:    "Inf in a slice allowed",
+			changes: map[string]interface{}{"samples": []interface{}{1.5, math.Inf(-1)}},
+			allow:   true,
+			wantNew: `[1.5,"-Inf"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := sensorReading{Temperature: 20}
+			opts := []Option{WithModifiedDts(false)}
+			if tt.allow {
+				opts = append(opts, WithAllowNonFiniteFloats())
+			}
+
+			result, err := ApplyChanges(tt.changes, &reading, opts...)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("ApplyChanges() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			encoded, err := json.Marshal(result.Changes[0])
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			var rendered struct{ New json.RawMessage }
+			if err := json.Unmarshal(encoded, &rendered); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if string(rendered.New) != tt.wantNew {
+				t.Errorf("New encoded as %s, want %s", rendered.New, tt.wantNew)
+			}
+
+			rows, err := ChangeRows(AuditEntry{Changes: result.Changes})
+			if err != nil {
+				t.Fatalf("ChangeRows() error = %v", err)
+			}
+			if len(rows) != 1 || rows[0].New != tt.wantNew {
+				t.Errorf("ChangeRows() = %+v, want one row with New %s", rows, tt.wantNew)
+			}
+
+			if _, err := ComputeETag(&reading); err != nil {
+				t.Errorf("ComputeETag() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestComputeETagNonFinite(t *testing.T) {
+	nan := sensorReading{Temperature: math.NaN()}
+	inf := sensorReading{Temperature: math.Inf(1)}
+
+	nanTag, err := ComputeETag(&nan)
+	if err != nil {
+		t.Fatalf("ComputeETag() error = %v", err)
+	}
+	infTag, _ := ComputeETag(&inf)
+	againTag, _ := ComputeETag(&sensorReading{Temperature: math.NaN()})
+	if nanTag == infTag || nanTag != againTag {
+		t.Errorf("ETags NaN %s, +Inf %s, NaN again %s, want NaN stable and distinct from +Inf", nanTag, infTag, againTag)
+	}
+
+	if finiteTag, _ := ComputeETag(&sensorReading{}); finiteTag == nanTag {
+		t.Errorf("ETag of a zero reading = %s, the same as NaN's", finiteTag)
+	}
+}
+
+func TestWithFloatTolerance(t *testing.T) {
+	tests := []struct {
+		name         string
+		changes      map[string]interface{}
+		opts         []Option
+		want         sensorReading
+		wantRowPaths []string
+	}{
+		{
+			name:         "exact comparison by default",
+			changes:      map[string]interface{}{"temperature": nearlyPointThree},
+			want:         sensorReading{Temperature: nearlyPointThree, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}},
+			wantRowPaths: []string{"temperature"},
+		},
+		{
+			name:    "same bits",
+			changes: map[string]interface{}{"temperature": 0.3},
+			want:    sensorReading{Temperature: 0.3, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}},
+		},
+		{
+			name:    "within the tolerance",
+			changes: map[string]interface{}{"temperature": nearlyPointThree},
+			opts:    []Option{WithFloatTolerance(1e-9, "temperature")},
+			want:    sensorReading{Temperature: 0.3, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}},
+		},
+		{
+			name:         "beyond the tolerance",
+			changes:      map[string]interface{}{"temperature": 0.4},
+			opts:         []Option{WithFloatTolerance(1e-9, "temperature")},
+			want:         sensorReading{Temperature: 0.4, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}},
+			wantRowPaths: []string{"temperature"},
+		},
+		{
+			name:         "another field",
+			changes:      map[string]interface{}{"temperature": nearlyPointThree, "humidity": 0.5000001},
+			opts:         []Option{WithFloatTolerance(1e-3, "humidity")},
+			want:         sensorReading{Temperature: nearlyPointThree, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}},
+			wantRowPaths: []string{"temperature"},
+		},
+		{
+			name:         "slice elements",
+			changes:      map[string]interface{}{"samples": []interface{}{1.0000001, 2.5}},
+			opts:         []Option{WithFloatTolerance(1e-3, "samples")},
+			want:         sensorReading{Temperature: 0.3, Humidity: 0.5, Samples: []float64{1, 2.5}, Station: sensorStation{Pressure: 1013.25}},
+			wantRowPaths: []string{"samples"},
+		},
+		{
+			name:    "nested field",
+			changes: map[string]interface{}{"station": map[string]interface{}{"pressure": 1013.2500001}},
+			opts:    []Option{WithFloatTolerance(1e-3, "station.pressure")},
+			want:    sensorReading{Temperature: 0.3, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}},
+		},
+		{
+			name:    "json.Number",
+			changes: map[string]interface{}{"temperature": json.Number("0.30000001")},
+			opts:    []Option{WithFloatTolerance(1e-6, "temperature")},
+			want:    sensorReading{Temperature: 0.3, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := sensorReading{Temperature: 0.3, Humidity: 0.5, Samples: []float64{1, 2}, Station: sensorStation{Pressure: 1013.25}}
+			result, err := ApplyChanges(tt.changes, &reading, tt.opts...)
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+			if !reflect.DeepEqual(reading, tt.want) {
+				t.Errorf("target = %+v, want %+v", reading, tt.want)
+			}
+
+			rows, err := ChangeRows(AuditEntry{Changes: result.Changes})
+			if err != nil {
+				t.Fatalf("ChangeRows() error = %v", err)
+			}
+			var paths []string
+			for _, row := range rows {
+				paths = append(paths, row.Field)
+			}
+			if !reflect.DeepEqual(paths, tt.wantRowPaths) {
+				t.Errorf("ChangeRows() changed %v, want %v", paths, tt.wantRowPaths)
+			}
+		})
+	}
+}
+
+func TestSameValue(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name string
+		a, b interface{}
+		want bool
+	}{
+		{name: "equal floats", a: 0.5, b: 0.5, want: true},
+		{name: "representation difference", a: nearlyPointThree, b: 0.3, want: false},
+		{name: "NaN", a: nan, b: nan, want: true},
+		{name: "signed zero", a: 0.0, b: math.Copysign(0, -1), want: false},
+		{name: "slices", a: []float64{1, nan}, b: []float64{1, nan}, want: true},
+		{name: "nil and empty slices", a: []float64(nil), b: []float64{}, want: false},
+		{name: "structs", a: sensorStation{Name: "a", Pressure: 1}, b: sensorStation{Name: "a", Pressure: 1}, want: true},
+		{name: "maps", a: map[string]interface{}{"a": 1.0}, b: map[string]interface{}{"a": 2.0}, want: false},
+		{name: "different types", a: 1.0, b: float32(1), want: false},
+		{name: "nils", a: nil, b: nil, want: true},
+		{name: "errors", a: errors.New("a"), b: errors.New("a"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameValue(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameValue(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
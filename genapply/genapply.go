@@ -0,0 +1,271 @@
+// Package genapply holds what the appliers cmd/applygen generates with -full
+// share: checking the changes are ones a generated applier handles, and
+// converting and reporting values the way the reflection path does, without
+// reflection. It isn't meant to be used directly.
+package genapply
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+// Plain reports whether a generated applier can handle the changes itself:
+// every key is ASCII and every value nil, a valid UTF-8 string, a bool, a
+// finite number of a predeclared type, a time.Time or a uuid.UUID. Anything
+// else (nested changes, json.Numbers, invalid UTF-8, NaN, ...) is left to the
+// reflection path, which reports it.
+func Plain(changes map[string]interface{}) bool {
+	if changes == nil {
+		return false
+	}
+
+	for key, value := range changes {
+		if !isASCII(key) || !plainValue(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func plainValue(value interface{}) bool {
+	switch typed := value.(type) {
+	case nil, bool, time.Time, uuid.UUID,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr:
+		return true
+	case string:
+		return utf8.ValidString(typed)
+	case float32:
+		return !math.IsNaN(float64(typed)) && !math.IsInf(float64(typed), 0)
+	case float64:
+		return !math.IsNaN(typed) && !math.IsInf(typed, 0)
+	}
+
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sanitize is applychanges.Sanitize for Plain changes: empty strings become
+// nil, in place
+func Sanitize(changes map[string]interface{}) {
+	for key, value := range changes {
+		if s, ok := value.(string); ok && s == "" {
+			changes[key] = nil
+		}
+	}
+}
+
+// String converts a Plain value for a string field at path
+func String(path string, value interface{}) (string, *applychanges.FieldError) {
+	switch typed := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return typed, nil
+	}
+
+	return "", unconvertible(path, "string", value)
+}
+
+// Bool converts a Plain value for a bool field at path
+func Bool(path string, value interface{}) (bool, *applychanges.FieldError) {
+	switch typed := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return typed, nil
+	}
+
+	return false, unconvertible(path, "bool", value)
+}
+
+// Int converts a Plain value for a signed integer field of the named type at
+// path, truncating floats. The caller converts the result to the field's type,
+// wrapping it like a reflect.Value.SetInt would.
+func Int(path string, fieldType string, value interface{}) (int64, *applychanges.FieldError) {
+	switch typed := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return int64(typed), nil
+	case int8:
+		return int64(typed), nil
+	case int16:
+		return int64(typed), nil
+	case int32:
+		return int64(typed), nil
+	case int64:
+		return typed, nil
+	case uint:
+		return int64(typed), nil
+	case uint8:
+		return int64(typed), nil
+	case uint16:
+		return int64(typed), nil
+	case uint32:
+		return int64(typed), nil
+	case uint64:
+		return int64(typed), nil
+	case uintptr:
+		return int64(typed), nil
+	case float32:
+		return int64(float64(typed)), nil
+	case float64:
+		return int64(typed), nil
+	}
+
+	return 0, unconvertible(path, fieldType, value)
+}
+
+// Float converts a Plain value for a float field of the named type at path
+func Float(path string, fieldType string, value interface{}) (float64, *applychanges.FieldError) {
+	switch typed := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return float64(typed), nil
+	case int8:
+		return float64(typed), nil
+	case int16:
+		return float64(typed), nil
+	case int32:
+		return float64(typed), nil
+	case int64:
+		return float64(typed), nil
+	case uint:
+		return float64(typed), nil
+	case uint8:
+		return float64(typed), nil
+	case uint16:
+		return float64(typed), nil
+	case uint32:
+		return float64(typed), nil
+	case uint64:
+		return float64(typed), nil
+	case uintptr:
+		return float64(typed), nil
+	case float32:
+		return float64(typed), nil
+	case float64:
+		return typed, nil
+	}
+
+	return 0, unconvertible(path, fieldType, value)
+}
+
+// Time converts a Plain value for a time.Time field at path, parsing strings
+// as RFC 3339 like the reflection path's decode hook
+func Time(path string, value interface{}) (time.Time, *applychanges.FieldError) {
+	switch typed := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return typed, nil
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, typed)
+		if err != nil {
+			return time.Time{}, &applychanges.FieldError{Path: path, Err: errors.New(err.Error())}
+		}
+		return t, nil
+	}
+
+	return time.Time{}, &applychanges.FieldError{Path: path, Err: fmt.Errorf("expected a map, got '%s'", kind(value))}
+}
+
+// UUID converts a Plain value for a uuid.UUID field at path, parsing strings
+// like the reflection path's decode hook
+func UUID(path string, value interface{}) (uuid.UUID, *applychanges.FieldError) {
+	switch typed := value.(type) {
+	case nil:
+		return uuid.UUID{}, nil
+	case uuid.UUID:
+		return typed, nil
+	case string:
+		id, err := uuid.Parse(typed)
+		if err != nil {
+			return uuid.UUID{}, &applychanges.FieldError{Path: path, Err: errors.New(err.Error())}
+		}
+		return id, nil
+	}
+
+	return uuid.UUID{}, &applychanges.FieldError{Path: path, Err: fmt.Errorf("source data must be an array or slice, got %s", kind(value))}
+}
+
+// unconvertible is mapstructure's error for a value of the wrong type
+func unconvertible(path string, fieldType string, value interface{}) *applychanges.FieldError {
+	return &applychanges.FieldError{
+		Path: path,
+		Err:  fmt.Errorf("expected type '%s', got unconvertible type '%T', value: '%v'", fieldType, value, value),
+	}
+}
+
+// kind names the reflect.Kind of a Plain value
+func kind(value interface{}) string {
+	switch value.(type) {
+	case time.Time:
+		return "struct"
+	case uuid.UUID:
+		return "array"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// NoSuchField is the error for a key matching no field
+func NoSuchField(key string) *applychanges.FieldError {
+	return &applychanges.FieldError{Path: key, Err: errors.New("no such field")}
+}
+
+// FieldErrors sorts the errors by path into applychanges.FieldErrors,
+// redacting the values of the sensitive fields like the reflection path
+func FieldErrors(errs []*applychanges.FieldError, sensitive ...string) error {
+	fieldErrors := applychanges.FieldErrors(errs)
+	sort.SliceStable(fieldErrors, func(i, j int) bool {
+		return fieldErrors[i].Path < fieldErrors[j].Path
+	})
+
+	for i, err := range fieldErrors {
+		for _, path := range sensitive {
+			if err.Path == path {
+				fieldErrors[i] = &applychanges.FieldError{Path: path, Err: errors.New("invalid value " + applychanges.Redacted)}
+			}
+		}
+	}
+
+	return fieldErrors
+}
+
+// Value is what a FieldChange reports for a pointer field: what it points to,
+// or nil
+func Value[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+
+	return *p
+}
+
+// Folded reports whether the key matches one of the keys case-insensitively,
+// which lowered are
+func Folded(key string, lowered map[string]bool) bool {
+	return lowered[strings.ToLower(key)]
+}
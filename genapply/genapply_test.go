@@ -0,0 +1,39 @@
+package genapply
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPlain(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    bool
+	}{
+		{name: "nil changes", want: false},
+		{name: "no changes", changes: map[string]interface{}{}, want: true},
+		{name: "scalars", changes: map[string]interface{}{"a": "x", "b": true, "c": 1, "d": uint8(2), "e": 1.5, "f": nil}, want: true},
+		{name: "time and uuid", changes: map[string]interface{}{"a": time.Now(), "b": uuid.New()}, want: true},
+		{name: "non-ASCII key", changes: map[string]interface{}{"é": "x"}, want: false},
+		{name: "invalid UTF-8", changes: map[string]interface{}{"a": "\xff"}, want: false},
+		{name: "NaN", changes: map[string]interface{}{"a": math.NaN()}, want: false},
+		{name: "infinite float32", changes: map[string]interface{}{"a": float32(math.Inf(-1))}, want: false},
+		{name: "json number", changes: map[string]interface{}{"a": json.Number("1")}, want: false},
+		{name: "nested changes", changes: map[string]interface{}{"a": map[string]interface{}{}}, want: false},
+		{name: "slice", changes: map[string]interface{}{"a": []interface{}{}}, want: false},
+		{name: "pointer", changes: map[string]interface{}{"a": new(string)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Plain(tt.changes); got != tt.want {
+				t.Errorf("Plain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
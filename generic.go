@@ -0,0 +1,49 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Apply is the type-safe form of ApplyChangesWrapper: to must point to a T,
+// and T must be a struct. Without options, the applier registered for T with
+// RegisterApplier is used, if any.
+func Apply[T any](changes map[string]any, modifier string, to *T, opts ...Option) (ApplyResult, error) {
+	if err := checkStructTarget(to); err != nil {
+		return ApplyResult{}, err
+	}
+
+	if len(opts) == 0 {
+		if apply, ok := generatedApplier[T](); ok {
+			return apply(changes, modifier, to)
+		}
+	}
+
+	return ApplyChangesWrapper(changes, modifier, to, opts...)
+}
+
+// Applied applies the changes like Apply to a deep copy of from, returning the
+// copy; from itself (including anything it points to) is left untouched.
+func Applied[T any](changes map[string]any, modifier string, from T, opts ...Option) (T, error) {
+	to := deepCopy(reflect.ValueOf(&from).Elem()).Interface().(T)
+	if _, err := Apply(changes, modifier, &to, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return to, nil
+}
+
+// checkStructTarget fails unless to is a non-nil pointer to a struct
+func checkStructTarget(to interface{}) error {
+	value := reflect.ValueOf(to)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("changes must be applied through a non-nil pointer, got %T", to)
+	}
+
+	if value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("changes can only be applied to structs, not %s", value.Elem().Type())
+	}
+
+	return nil
+}
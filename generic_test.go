@@ -0,0 +1,137 @@
+package applychanges
+
+import (
+	"strings"
+	"testing"
+)
+
+type genericReport struct {
+	BaseStruct
+	City    string `json:"city"`
+	Weather string `json:"weather"`
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]any
+		opts    []Option
+		want    genericReport
+		wantErr string
+	}{
+		{
+			name:    "applies",
+			changes: map[string]any{"weather": "Fog"},
+			want:    genericReport{City: "Tampa", Weather: "Fog"},
+		},
+		{
+			name:    "with options",
+			changes: map[string]any{"weather": "Fog", "zip": "33602"},
+			opts:    []Option{WithIgnoreUnknownFields()},
+			want:    genericReport{City: "Tampa", Weather: "Fog"},
+		},
+		{
+			name:    "fails",
+			changes: map[string]any{"weather": 7},
+			wantErr: "'weather'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := genericReport{City: "Tampa", Weather: "Sun"}
+			result, err := Apply(tt.changes, "EUA1", &report, tt.opts...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Apply() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			if report.City != tt.want.City || report.Weather != tt.want.Weather || report.ModifiedBy == nil || *report.ModifiedBy != "EUA1" {
+				t.Errorf("report = %+v, want %+v modified by EUA1", report, tt.want)
+			}
+			if len(result.AppliedFields) == 0 {
+				t.Errorf("AppliedFields = %v, want the applied fields", result.AppliedFields)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsTargets(t *testing.T) {
+	var nilReport *genericReport
+	if _, err := Apply(map[string]any{"city": "Tampa"}, "EUA1", nilReport); err == nil {
+		t.Error("Apply() error = nil, want a nil target rejected")
+	}
+
+	city := "Tampa"
+	if _, err := Apply(map[string]any{"city": "Miami"}, "EUA1", &city); err == nil {
+		t.Error("Apply() error = nil, want a non-struct target rejected")
+	}
+}
+
+func TestApplied(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]any
+		opts    []Option
+		want    nestedRecord
+		wantErr string
+	}{
+		{
+			name:    "applies to a copy",
+			changes: map[string]any{"name": "Tampa"},
+			want:    nestedRecord{Name: "Tampa", Details: &nestedDetails{Source: "radar", Level: 1}},
+		},
+		{
+			name:    "copies what it points to",
+			changes: map[string]any{"details": map[string]any{"level": 2}},
+			want:    nestedRecord{Name: "Miami", Details: &nestedDetails{Source: "radar", Level: 2}},
+		},
+		{
+			name:    "with options",
+			changes: map[string]any{"name": "Tampa", "zip": "33602"},
+			opts:    []Option{WithIgnoreUnknownFields()},
+			want:    nestedRecord{Name: "Tampa", Details: &nestedDetails{Source: "radar", Level: 1}},
+		},
+		{
+			name:    "fails with the zero value",
+			changes: map[string]any{"count": "many"},
+			wantErr: "'count'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := nestedRecord{Name: "Miami", Details: &nestedDetails{Source: "radar", Level: 1}}
+			got, err := Applied(tt.changes, "EUA1", from, tt.opts...)
+
+			if from.Name != "Miami" || from.ModifiedBy != nil || *from.Details != (nestedDetails{Source: "radar", Level: 1}) {
+				t.Errorf("from = %+v, want it untouched", from)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Applied() error = %v, want %s", err, tt.wantErr)
+				}
+				if got.Details != nil || got.Name != "" {
+					t.Errorf("Applied() = %+v, want the zero value", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Applied() error = %v", err)
+			}
+
+			if got.Name != tt.want.Name || *got.Details != *tt.want.Details || got.ModifiedBy == nil || *got.ModifiedBy != "EUA1" {
+				t.Errorf("Applied() = %+v, want %+v modified by EUA1", got, tt.want)
+			}
+			if got.Details == from.Details {
+				t.Error("Applied() shares Details with from, want a deep copy")
+			}
+		})
+	}
+}
@@ -6,6 +6,16 @@ require (
 	github.com/99designs/gqlgen v0.17.16
 	github.com/google/uuid v1.3.0
 	github.com/mitchellh/mapstructure v1.5.0
+	github.com/shopspring/decimal v1.4.0
+	github.com/vektah/gqlparser/v2 v2.5.0
 )
 
-require github.com/vektah/gqlparser/v2 v2.5.0 // indirect
+require (
+	github.com/agnivade/levenshtein v1.1.1 // indirect
+	golang.org/x/mod v0.6.0-dev.0.20220106191415-9b9b3d81d5e3 // indirect
+	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/tools v0.1.10 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,81 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// gormTagName is the struct tag GORM reads its column settings from
+const gormTagName = "gorm"
+
+// GormUpdates returns the fields an apply touched as the column map GORM's
+// db.Model(&x).Updates(...) expects, including the target's ModifiedBy and
+// ModifiedDts (see BuildNamedUpdateSQL). Columns are named by the field's
+// `gorm:"column:..."` tag, else its db tag, else GORM's default snake_case of
+// the Go name; values are the target's current ones.
+func GormUpdates(result ApplyResult, to interface{}, opts ...Option) (map[string]interface{}, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+
+	target, ok := targetStruct(to)
+	if !ok {
+		return nil, fmt.Errorf("changes can only be applied to structs, not %T", to)
+	}
+
+	fields, err := updatedFields(result, target, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		column := gormColumn(field.Tag.Get(gormTagName))
+		if column == "" {
+			column = strings.SplitN(field.Tag.Get(dbTagName), ",", 2)[0]
+		}
+		if column == "-" || field.Tag.Get(gormTagName) == "-" {
+			return nil, fmt.Errorf("'%s' has no db column", fieldKey(field, cfg.tagName))
+		}
+		if column == "" {
+			column = snakeCase(field.Name)
+		}
+
+		updates[column] = target.FieldByIndex(field.Index).Interface()
+	}
+
+	return updates, nil
+}
+
+// gormColumn returns the column setting of a gorm tag, e.g.
+// "column:city_name;type:text"
+func gormColumn(tag string) string {
+	for _, setting := range strings.Split(tag, ";") {
+		if name := strings.TrimSpace(setting); strings.HasPrefix(strings.ToLower(name), "column:") {
+			return strings.TrimSpace(name[len("column:"):])
+		}
+	}
+
+	return ""
+}
+
+// snakeCase converts a Go name to snake_case the way GORM names columns by
+// default, keeping initialisms together: "ModifiedDts" becomes "modified_dts"
+// and "OwnerID" becomes "owner_id"
+func snakeCase(name string) string {
+	runes := []rune(name)
+
+	var builder strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				builder.WriteByte('_')
+			}
+		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,104 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type gormReport struct {
+	City        string     `json:"city" gorm:"column:city_name;type:text"`
+	Weather     string     `json:"weather" db:"conditions"`
+	OwnerID     string     `json:"ownerId"`
+	Secret      string     `json:"secret" gorm:"-"`
+	ModifiedBy  string     `json:"modifiedBy"`
+	ModifiedDts *time.Time `json:"modifiedDts"`
+}
+
+func TestGormUpdates(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "gorm column",
+			changes: map[string]interface{}{"city": "Tampa"},
+			want:    map[string]interface{}{"city_name": "Tampa", "modified_by": "EUA1", "modified_dts": &now},
+		},
+		{
+			name:    "db column",
+			changes: map[string]interface{}{"weather": "Rain"},
+			want:    map[string]interface{}{"conditions": "Rain", "modified_by": "EUA1", "modified_dts": &now},
+		},
+		{
+			name:    "default column",
+			changes: map[string]interface{}{"ownerId": "EUA9"},
+			want:    map[string]interface{}{"owner_id": "EUA9", "modified_by": "EUA1", "modified_dts": &now},
+		},
+		{
+			name:    "ignored by gorm",
+			changes: map[string]interface{}{"secret": "x"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := gormReport{}
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &report, WithClock(fixedClock(now)))
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			updates, err := GormUpdates(result, &report)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GormUpdates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(updates, tt.want) {
+				t.Errorf("GormUpdates() = %#v, want %#v", updates, tt.want)
+			}
+		})
+	}
+}
+
+func TestGormColumn(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{tag: "column:city_name", want: "city_name"},
+		{tag: "type:text; COLUMN: city_name ;not null", want: "city_name"},
+		{tag: "type:text"},
+		{tag: ""},
+	}
+
+	for _, tt := range tests {
+		if got := gormColumn(tt.tag); got != tt.want {
+			t.Errorf("gormColumn(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "City", want: "city"},
+		{name: "ModifiedDts", want: "modified_dts"},
+		{name: "OwnerID", want: "owner_id"},
+		{name: "HTTPServer", want: "http_server"},
+		{name: "Address2Line", want: "address2_line"},
+		{name: "ID", want: "id"},
+	}
+
+	for _, tt := range tests {
+		if got := snakeCase(tt.name); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
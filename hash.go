@@ -0,0 +1,238 @@
+package applychanges
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// HashChanges returns a hex-encoded SHA-256 of the changes' content. Two
+// changesets hash the same when they carry the same keys and values, no matter
+// the map iteration order, how numbers were typed (1, int64(1), 1.0 and
+// json.Number("1") are all the same number), or whether a null was an untyped
+// nil or a nil pointer/slice/map.
+//
+// The hash is computed over a package-defined encoding rather than
+// encoding/json, so it stays the same across processes and Go versions.
+func HashChanges(changes map[string]interface{}) (string, error) {
+	canonical, err := canonicalizeChanges(changes)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// EqualChanges reports whether two changesets carry the same content, using the
+// same rules as HashChanges. Changesets that can't be canonicalized are never
+// equal.
+func EqualChanges(a, b map[string]interface{}) bool {
+	canonicalA, err := canonicalizeChanges(a)
+	if err != nil {
+		return false
+	}
+
+	canonicalB, err := canonicalizeChanges(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(canonicalA, canonicalB)
+}
+
+func canonicalizeChanges(changes map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, reflect.ValueOf(changes)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeCanonical encodes a value as a tag byte followed by its content:
+//
+//	null    n
+//	boolean t | f
+//	number  d<len>:<decimal>
+//	string  s<len>:<bytes>
+//	array   a<count>:<element>...
+//	object  o<count>:<string key><value>... (keys sorted)
+//
+// Integers are written in one exact decimal form however they were typed (an
+// int, uint, integral float or json.Number), so uint64(12345678901234567890)
+// and json.Number("12345678901234567890") encode alike. Anything else is
+// encoded through its JSON representation.
+func writeCanonical(buf *bytes.Buffer, value reflect.Value) error {
+	if !value.IsValid() {
+		buf.WriteByte('n')
+		return nil
+	}
+
+	if number, ok := value.Interface().(json.Number); ok {
+		normalized, err := canonicalJSONNumber(number)
+		if err != nil {
+			return err
+		}
+		writeCanonicalString(buf, 'd', normalized)
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			buf.WriteByte('n')
+			return nil
+		}
+		return writeCanonical(buf, value.Elem())
+	case reflect.Bool:
+		if value.Bool() {
+			buf.WriteByte('t')
+		} else {
+			buf.WriteByte('f')
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeCanonicalString(buf, 'd', strconv.FormatInt(value.Int(), 10))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeCanonicalString(buf, 'd', strconv.FormatUint(value.Uint(), 10))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		normalized, err := canonicalFloat(value.Float())
+		if err != nil {
+			return err
+		}
+		writeCanonicalString(buf, 'd', normalized)
+		return nil
+	case reflect.String:
+		writeCanonicalString(buf, 's', value.String())
+		return nil
+	case reflect.Slice:
+		if value.IsNil() {
+			buf.WriteByte('n')
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		if !isTextual(value) {
+			fmt.Fprintf(buf, "a%d:", value.Len())
+			for i := 0; i < value.Len(); i++ {
+				if err := writeCanonical(buf, value.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			buf.WriteByte('n')
+			return nil
+		}
+		if value.Type().Key().Kind() == reflect.String {
+			keys := make([]string, 0, value.Len())
+			for _, key := range value.MapKeys() {
+				keys = append(keys, key.String())
+			}
+			sort.Strings(keys)
+
+			fmt.Fprintf(buf, "o%d:", len(keys))
+			for _, key := range keys {
+				writeCanonicalString(buf, 's', key)
+				keyValue := reflect.ValueOf(key).Convert(value.Type().Key())
+				if err := writeCanonical(buf, value.MapIndex(keyValue)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return writeCanonicalJSON(buf, value.Interface())
+}
+
+func writeCanonicalString(buf *bytes.Buffer, tag byte, s string) {
+	buf.WriteByte(tag)
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.WriteString(s)
+}
+
+// writeCanonicalJSON handles values with their own JSON form (time.Time,
+// uuid.UUID, structs...) by canonicalizing what they marshal to
+func writeCanonicalJSON(buf *bytes.Buffer, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+
+	return writeCanonical(buf, reflect.ValueOf(generic))
+}
+
+// isTextual reports whether an array/slice marshals to a JSON string rather
+// than an array (uuid.UUID, []byte...), in which case it goes through its JSON
+// form
+func isTextual(value reflect.Value) bool {
+	if value.Type().Implements(textMarshalerType) || value.Type().Implements(jsonMarshalerType) {
+		return true
+	}
+
+	return value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*interface{ MarshalText() ([]byte, error) })(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// canonicalFloat formats integral floats exactly like integers, so 1.0 and 1
+// hash alike, and everything else in the shortest form that round-trips
+func canonicalFloat(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("cannot hash non-finite number %v", f)
+	}
+
+	if f == math.Trunc(f) {
+		if f >= math.MinInt64 && f < math.MaxInt64 {
+			return strconv.FormatInt(int64(f), 10), nil
+		}
+		integer, _ := new(big.Float).SetFloat64(f).Int(nil)
+		return integer.String(), nil
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
+
+// canonicalJSONNumber formats a json.Number holding an integer (in any
+// notation, e.g. "12345678901234567890" or "1e3") exactly, and anything else
+// like the float it parses as
+func canonicalJSONNumber(number json.Number) (string, error) {
+	if i, err := number.Int64(); err == nil {
+		return strconv.FormatInt(i, 10), nil
+	}
+
+	if exact, ok := new(big.Rat).SetString(string(number)); ok && exact.IsInt() {
+		return exact.Num().String(), nil
+	}
+
+	f, err := number.Float64()
+	if err != nil {
+		return "", fmt.Errorf("cannot hash number %q: %w", number, err)
+	}
+
+	return canonicalFloat(f)
+}
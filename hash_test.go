@@ -0,0 +1,146 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The hashes are pinned so that a change to the encoding, which would make
+// stored keys miss, fails here first. They can be checked by hand: each one is
+// the SHA-256 of the encoding documented on writeCanonical, e.g. "o0:" for {}.
+func TestHashChangesGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    string
+	}{
+		{name: "empty", changes: map[string]interface{}{}, want: "6ca6486412ffd97e14bd93d83faa44cc5b39ddf8a945875c3dc9192193db2bad"},
+		{name: "string", changes: map[string]interface{}{"weather": "sunny"}, want: "6c2bb76ec30353a2201e2bdb4d502004acf8174933a5dd6cd2e3a9f72d446627"},
+		{name: "integer", changes: map[string]interface{}{"count": 1}, want: "0767bb06d53277c43113e53b0a0f69cb8593a6f3ed113887e8776b549ef54f50"},
+		{name: "fraction", changes: map[string]interface{}{"count": 1.5}, want: "042a364b7b964bb749a44e8102e2e2fb0d4b2f8677a161d3b5ecff78d4f17a96"},
+		{name: "small fraction", changes: map[string]interface{}{"count": -0.000001}, want: "f8a41ba2ed36bb2d3b65a26efc8b794f9978b899a7e5470c1625761ea07495b9"},
+		{name: "beyond int64", changes: map[string]interface{}{"count": 1e21}, want: "2fd81aa9db45ddc1ecfc7293929a7888b667096c82252a8d8bfb91719227a711"},
+		{name: "large json.Number", changes: map[string]interface{}{"count": json.Number("12345678901234567890")}, want: "9e0bb8116091bab2eba19c0f78ee0e7c4227256e4d384d7980bb36f212363808"},
+		{name: "null and booleans", changes: map[string]interface{}{"c": false, "a": nil, "b": true}, want: "b47b84d1a792335e3b3ff47a60c120854c182e356460004d585bf8a8482bf379"},
+		{name: "array order kept", changes: map[string]interface{}{"tags": []interface{}{"b", "a"}}, want: "ce8ec1f1a25d5f2719a73b5a1659a07ff44b9d1cbe6e8c7ef42004f45641717d"},
+		{name: "nested keys sorted", changes: map[string]interface{}{"details": map[string]interface{}{"source": "buoy", "level": 2}}, want: "7cdd4c06cbbe8077bc7b409df509168d595e47a77b989f472b0052b13877250f"},
+		{name: "uuid", changes: map[string]interface{}{"reference": uuid.MustParse("7f1c1d3c-0b1a-4c8e-9a4e-1b2c3d4e5f60")}, want: "f14e580ebf0db0e37cddc6eabc170b0bad9d20c828f0a93f85375d3a4cddcc8e"},
+		{name: "time", changes: map[string]interface{}{"at": time.Date(2022, 9, 1, 12, 30, 0, 0, time.UTC)}, want: "0cae700db90f29f6904666ce5843d4cfac7a2309254852536809e2b8f58b8805"},
+		{name: "non-ASCII", changes: map[string]interface{}{"é": "ü"}, want: "119f1a98047e0db79c7354a5ced81c6f3507d035c45fc30e441adb8506f976e5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HashChanges(tt.changes)
+			if err != nil {
+				t.Fatalf("HashChanges() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HashChanges() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEqualChanges(t *testing.T) {
+	var nilPointer *string
+	var nilSlice []string
+
+	tests := []struct {
+		name string
+		a    map[string]interface{}
+		b    map[string]interface{}
+		want bool
+	}{
+		{
+			name: "key order",
+			a:    map[string]interface{}{"weather": "sunny", "city": "Tampa", "count": 1},
+			b:    map[string]interface{}{"count": 1, "city": "Tampa", "weather": "sunny"},
+			want: true,
+		},
+		{
+			name: "number types",
+			a:    map[string]interface{}{"a": 1, "b": int64(2), "c": uint8(3), "d": 4.0, "e": json.Number("5")},
+			b:    map[string]interface{}{"a": 1.0, "b": json.Number("2"), "c": 3, "d": int32(4), "e": float32(5)},
+			want: true,
+		},
+		{
+			name: "json.Number exponents",
+			a:    map[string]interface{}{"count": json.Number("1e3"), "ratio": json.Number("0.50")},
+			b:    map[string]interface{}{"count": 1000, "ratio": 0.5},
+			want: true,
+		},
+		{
+			name: "integers beyond int64",
+			a:    map[string]interface{}{"a": uint64(12345678901234567890), "b": 1e21, "c": json.Number("-9223372036854775809")},
+			b:    map[string]interface{}{"a": json.Number("12345678901234567890"), "b": json.Number("1000000000000000000000"), "c": json.Number("-9.223372036854775809e18")},
+			want: true,
+		},
+		{
+			name: "integers beyond int64 differing in the last digit",
+			a:    map[string]interface{}{"count": uint64(12345678901234567890)},
+			b:    map[string]interface{}{"count": json.Number("12345678901234567891")},
+		},
+		{
+			name: "nulls",
+			a:    map[string]interface{}{"a": nil, "b": nil, "c": nil},
+			b:    map[string]interface{}{"a": nilPointer, "b": nilSlice, "c": map[string]interface{}(nil)},
+			want: true,
+		},
+		{
+			name: "nested",
+			a:    map[string]interface{}{"details": map[string]interface{}{"source": "buoy", "tags": []interface{}{"a", 1}}},
+			b:    map[string]interface{}{"details": map[string]interface{}{"tags": []interface{}{"a", 1.0}, "source": "buoy"}},
+			want: true,
+		},
+		{
+			name: "typed nested",
+			a:    map[string]interface{}{"tags": []string{"a", "b"}, "scores": map[string]int{"x": 1}},
+			b:    map[string]interface{}{"tags": []interface{}{"a", "b"}, "scores": map[string]interface{}{"x": 1}},
+			want: true,
+		},
+		{
+			name: "different value",
+			a:    map[string]interface{}{"count": 1},
+			b:    map[string]interface{}{"count": 1.5},
+		},
+		{
+			name: "number and string",
+			a:    map[string]interface{}{"count": 1},
+			b:    map[string]interface{}{"count": "1"},
+		},
+		{
+			name: "null and missing",
+			a:    map[string]interface{}{"count": nil},
+			b:    map[string]interface{}{},
+		},
+		{
+			name: "array order",
+			a:    map[string]interface{}{"tags": []interface{}{"a", "b"}},
+			b:    map[string]interface{}{"tags": []interface{}{"b", "a"}},
+		},
+		{
+			name: "not hashable",
+			a:    map[string]interface{}{"count": math.NaN()},
+			b:    map[string]interface{}{"count": math.NaN()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EqualChanges(tt.a, tt.b); got != tt.want {
+				t.Errorf("EqualChanges() = %v, want %v", got, tt.want)
+			}
+
+			hashA, errA := HashChanges(tt.a)
+			hashB, errB := HashChanges(tt.b)
+			if tt.want && (errA != nil || errB != nil || hashA != hashB) {
+				t.Errorf("HashChanges() = %s (%v) and %s (%v), want the same hash", hashA, errA, hashB, errB)
+			}
+		})
+	}
+}
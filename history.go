@@ -0,0 +1,165 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// History keeps the most recent versions of every target applied to with
+// WithHistory, in memory, so a target can be restored to one of them. Targets
+// are told apart by type and ID field; those without an ID, or whose ID is
+// still the zero value (e.g. not yet saved), aren't tracked.
+type History struct {
+	limit int
+
+	mu       sync.Mutex
+	versions map[historyKey][]HistoryVersion
+	next     map[historyKey]int
+}
+
+// HistoryVersion is one version of a target kept by History
+type HistoryVersion struct {
+	// Version numbers the versions of a target from 0, the state it was in
+	// before the first apply History saw
+	Version int
+
+	// Modifier, At and Changes describe the apply producing the version (they
+	// are empty for version 0)
+	Modifier string
+	At       time.Time
+	Changes  []FieldChange
+
+	snapshot reflect.Value
+}
+
+type historyKey struct {
+	targetType reflect.Type
+	id         string
+}
+
+// NewHistory returns a History keeping the last limit versions of each target
+func NewHistory(limit int) *History {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &History{
+		limit:    limit,
+		versions: map[historyKey][]HistoryVersion{},
+		next:     map[historyKey]int{},
+	}
+}
+
+// Versions lists the versions kept of the target, oldest first
+func (h *History) Versions(target interface{}) []HistoryVersion {
+	key, ok := historyKeyOf(target)
+	if !ok {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]HistoryVersion(nil), h.versions[key]...)
+}
+
+// Restore overwrites the target with a copy of one of its versions. It
+// doesn't record a version of its own; apply the result to keep it.
+func (h *History) Restore(to interface{}, version int) error {
+	key, ok := historyKeyOf(to)
+	if !ok {
+		return fmt.Errorf("%T has no history", to)
+	}
+
+	target, _ := targetStruct(to)
+	if !target.CanSet() {
+		return fmt.Errorf("versions can only be restored through a pointer, not %T", to)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, kept := range h.versions[key] {
+		if kept.Version == version {
+			target.Set(deepCopy(kept.snapshot))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("version %d of %s %s is no longer kept", version, key.targetType.Name(), key.id)
+}
+
+// begin keeps the target's state as version 0 when it's new to the history
+func (h *History) begin(to interface{}) {
+	key, ok := historyKeyOf(to)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.next[key]; !ok {
+		target, _ := targetStruct(to)
+		h.add(key, HistoryVersion{snapshot: deepCopy(target)})
+	}
+}
+
+// record keeps the target's state after an apply
+func (h *History) record(to interface{}, result ApplyResult, cfg *config) {
+	key, ok := historyKeyOf(to)
+	if !ok {
+		return
+	}
+
+	version := HistoryVersion{At: cfg.now, Changes: result.Changes}
+	if cfg.modifier != nil {
+		version.Modifier = *cfg.modifier
+	}
+
+	target, _ := targetStruct(to)
+	version.snapshot = deepCopy(target)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.add(key, version)
+}
+
+// add numbers and keeps a version, dropping the oldest past the limit
+func (h *History) add(key historyKey, version HistoryVersion) {
+	version.Version = h.next[key]
+	h.next[key]++
+
+	versions := append(h.versions[key], version)
+	if len(versions) > h.limit {
+		versions = append([]HistoryVersion(nil), versions[len(versions)-h.limit:]...)
+	}
+	h.versions[key] = versions
+}
+
+// historyKeyOf identifies a target by type and ID field. Targets with a zero
+// ID can't be told apart, so they have no key.
+func historyKeyOf(to interface{}) (historyKey, bool) {
+	target, ok := targetStruct(to)
+	if !ok {
+		return historyKey{}, false
+	}
+
+	field, ok := structFieldByName(target.Type(), "ID")
+	if !ok {
+		return historyKey{}, false
+	}
+
+	id := target.FieldByIndex(field.Index)
+	if id.IsZero() {
+		return historyKey{}, false
+	}
+
+	return historyKey{
+		targetType: target.Type(),
+		id:         fmt.Sprint(id.Interface()),
+	}, true
+}
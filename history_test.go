@@ -0,0 +1,158 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        int
+		names        []string
+		wantVersions []int
+	}{
+		{name: "state before the first apply", limit: 5, names: []string{"Tampa"}, wantVersions: []int{0, 1}},
+		{name: "every apply", limit: 5, names: []string{"Tampa", "Orlando", "Naples"}, wantVersions: []int{0, 1, 2, 3}},
+		{name: "oldest dropped past the limit", limit: 2, names: []string{"Tampa", "Orlando", "Naples"}, wantVersions: []int{2, 3}},
+		{name: "limit of at least one", limit: 0, names: []string{"Tampa", "Orlando"}, wantVersions: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+			history := NewHistory(tt.limit)
+			record := nestedRecord{Name: "Miami"}
+			record.ID = uuid.New()
+
+			for _, name := range tt.names {
+				if _, err := ApplyChangesWrapper(map[string]interface{}{"name": name}, "EUA1", &record, WithHistory(history), WithClock(fixedClock(now))); err != nil {
+					t.Fatalf("ApplyChangesWrapper() error = %v", err)
+				}
+			}
+
+			versions := history.Versions(&record)
+			var got []int
+			for _, version := range versions {
+				got = append(got, version.Version)
+			}
+			if !reflect.DeepEqual(got, tt.wantVersions) {
+				t.Fatalf("Versions() = %v, want %v", got, tt.wantVersions)
+			}
+
+			last := versions[len(versions)-1]
+			if last.Modifier != "EUA1" || !last.At.Equal(now) || last.Changes[len(last.Changes)-1].New != tt.names[len(tt.names)-1] {
+				t.Errorf("last version = %+v, want the apply's modifier, time and changes", last)
+			}
+			if versions[0].Version == 0 && (versions[0].Modifier != "" || versions[0].Changes != nil) {
+				t.Errorf("version 0 = %+v, want it empty", versions[0])
+			}
+		})
+	}
+}
+
+func TestHistoryRestore(t *testing.T) {
+	history := NewHistory(5)
+	record := nestedRecord{Name: "Miami", Details: &nestedDetails{Source: "radar"}}
+	record.ID = uuid.New()
+
+	for _, changes := range []map[string]interface{}{
+		{"name": "Tampa", "details": map[string]interface{}{"level": 2}},
+		{"name": "Orlando", "details": nil},
+	} {
+		if _, err := ApplyChangesWrapper(changes, "EUA1", &record, WithHistory(history)); err != nil {
+			t.Fatalf("ApplyChangesWrapper() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		version     int
+		wantName    string
+		wantDetails *nestedDetails
+		wantErr     string
+	}{
+		{name: "before the first apply", version: 0, wantName: "Miami", wantDetails: &nestedDetails{Source: "radar"}},
+		{name: "after the first apply", version: 1, wantName: "Tampa", wantDetails: &nestedDetails{Source: "radar", Level: 2}},
+		{name: "latest", version: 2, wantName: "Orlando"},
+		{name: "not kept", version: 7, wantErr: "version 7 of nestedRecord " + record.ID.String() + " is no longer kept"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restored := record
+			err := history.Restore(&restored, tt.version)
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Restore() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			if restored.Name != tt.wantName || !reflect.DeepEqual(restored.Details, tt.wantDetails) {
+				t.Errorf("restored = %+v, want %q with %+v", restored, tt.wantName, tt.wantDetails)
+			}
+
+			// the version is restored as a copy, which can be changed freely
+			if restored.Details != nil {
+				restored.Details.Level = 99
+				again := record
+				if err := history.Restore(&again, tt.version); err != nil || !reflect.DeepEqual(again.Details, tt.wantDetails) {
+					t.Errorf("version %d changed through a restored copy: %+v", tt.version, again.Details)
+				}
+			}
+		})
+	}
+
+	if len(history.Versions(&record)) != 3 {
+		t.Errorf("Restore() recorded a version of its own")
+	}
+}
+
+func TestHistoryUntracked(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  interface{}
+		changes map[string]interface{}
+		wantErr string
+	}{
+		{name: "zero ID", target: &nestedRecord{}, changes: map[string]interface{}{"name": "Tampa"}, wantErr: "*applychanges.nestedRecord has no history"},
+		{name: "no ID", target: &taggedRecord{}, changes: map[string]interface{}{"tags": map[string]interface{}{"a": 1}}, wantErr: "*applychanges.taggedRecord has no history"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := NewHistory(5)
+			if _, err := ApplyChanges(tt.changes, tt.target, WithHistory(history)); err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if versions := history.Versions(tt.target); versions != nil {
+				t.Errorf("Versions() = %+v, want none", versions)
+			}
+			if err := history.Restore(tt.target, 0); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Restore() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHistoryDryRun(t *testing.T) {
+	history := NewHistory(5)
+	record := nestedRecord{Name: "Miami"}
+	record.ID = uuid.New()
+
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", &record, WithHistory(history), WithDryRun()); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	if versions := history.Versions(&record); len(versions) != 0 {
+		t.Errorf("Versions() = %+v, want dry runs left out", versions)
+	}
+}
@@ -0,0 +1,84 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoIdempotencyStore is returned by an apply given an idempotency key
+// without a store to look it up in, see WithIdempotencyStore
+var ErrNoIdempotencyStore = errors.New("an idempotency key needs WithIdempotencyStore")
+
+// IdempotencyStore remembers the results of the applies made with an
+// idempotency key (see WithIdempotencyKey and WithContentDerivedKey), so an
+// apply repeating a key returns the result stored for it instead of applying
+// the changes again. Expiring keys is up to the store.
+type IdempotencyStore interface {
+	// Load returns the result stored under the key, or false
+	Load(ctx context.Context, key string) (ApplyResult, bool, error)
+
+	// Save stores the result of a successful apply under the key
+	Save(ctx context.Context, key string, result ApplyResult) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore keeping every result in memory,
+// for tests and single-process use
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	results map[string]ApplyResult
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{results: map[string]ApplyResult{}}
+}
+
+func (s *MemoryIdempotencyStore) Load(_ context.Context, key string) (ApplyResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, ok := s.results[key]
+	return result, ok, nil
+}
+
+func (s *MemoryIdempotencyStore) Save(_ context.Context, key string, result ApplyResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.results[key] = result
+	return nil
+}
+
+// idempotencyKey returns the key the apply is stored under, or false when it
+// has none. Content-derived keys are the target's type and ID followed by the
+// HashChanges of the changes as given.
+func idempotencyKey(changes map[string]interface{}, to interface{}, cfg *config) (string, bool, error) {
+	if cfg.idempotencyKey == "" && !cfg.contentDerivedKey {
+		return "", false, nil
+	}
+	if cfg.idempotencyStore == nil {
+		return "", false, ErrNoIdempotencyStore
+	}
+	if cfg.idempotencyKey != "" {
+		return cfg.idempotencyKey, true, nil
+	}
+
+	hash, err := HashChanges(changes)
+	if err != nil {
+		return "", false, fmt.Errorf("deriving the idempotency key: %w", err)
+	}
+
+	target, ok := targetStruct(to)
+	if !ok {
+		return hash, true, nil
+	}
+
+	id := ""
+	if field, ok := structFieldByName(target.Type(), "ID"); ok {
+		id = fmt.Sprint(target.FieldByIndex(field.Index).Interface())
+	}
+
+	return fmt.Sprintf("%s/%s/%s", target.Type().Name(), id, hash), true, nil
+}
@@ -0,0 +1,117 @@
+package applychanges
+
+import (
+	"errors"
+	"testing"
+)
+
+type idempotentReport struct {
+	ID         string  `json:"id"`
+	City       string  `json:"city"`
+	Weather    string  `json:"weather"`
+	ModifiedBy *string `json:"modifiedBy"`
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		second      map[string]interface{}
+		secondID    string
+		wantRepeat  bool
+		wantErr     error
+		wantWeather string
+	}{
+		{
+			name:        "explicit key repeated",
+			opts:        []Option{WithIdempotencyKey("request-1")},
+			second:      map[string]interface{}{"weather": "rainy"},
+			wantRepeat:  true,
+			wantWeather: "sunny",
+		},
+		{
+			name:        "content derived key repeated",
+			opts:        []Option{WithContentDerivedKey()},
+			second:      map[string]interface{}{"weather": "sunny", "city": "Tampa"},
+			wantRepeat:  true,
+			wantWeather: "sunny",
+		},
+		{
+			name:        "content derived key with other changes",
+			opts:        []Option{WithContentDerivedKey()},
+			second:      map[string]interface{}{"weather": "rainy", "city": "Tampa"},
+			wantWeather: "rainy",
+		},
+		{
+			name:        "content derived key for another target",
+			opts:        []Option{WithContentDerivedKey()},
+			second:      map[string]interface{}{"weather": "sunny", "city": "Tampa"},
+			secondID:    "WR2",
+			wantWeather: "sunny",
+		},
+		{
+			name:    "key without a store",
+			opts:    []Option{WithIdempotencyKey("request-1"), WithIdempotencyStore(nil)},
+			wantErr: ErrNoIdempotencyStore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryIdempotencyStore()
+			opts := append([]Option{WithIdempotencyStore(store)}, tt.opts...)
+
+			report := idempotentReport{ID: "WR1"}
+			first, err := ApplyChangesWrapper(map[string]interface{}{"city": "Tampa", "weather": "sunny"}, "EUA1", &report, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if first.Duplicate {
+				t.Error("the first apply is reported as a Duplicate")
+			}
+
+			if tt.secondID != "" {
+				report.ID = tt.secondID
+			}
+			second, err := ApplyChangesWrapper(tt.second, "EUA2", &report, opts...)
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if second.Duplicate != tt.wantRepeat {
+				t.Errorf("Duplicate = %v, want %v", second.Duplicate, tt.wantRepeat)
+			}
+			if report.Weather != tt.wantWeather {
+				t.Errorf("weather = %q, want %q", report.Weather, tt.wantWeather)
+			}
+			if tt.wantRepeat && (*report.ModifiedBy != "EUA1" || len(second.Changes) != len(first.Changes)) {
+				t.Errorf("the repeated apply was applied: modifiedBy %s, changes %v", *report.ModifiedBy, second.Changes)
+			}
+		})
+	}
+}
+
+func TestIdempotencyNotSavedOnFailure(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	sink := &recordingSink{err: errors.New("sink down")}
+
+	report := idempotentReport{ID: "WR1"}
+	changes := map[string]interface{}{"weather": "sunny"}
+	if _, err := ApplyChangesWrapper(changes, "EUA1", &report, WithIdempotencyStore(store), WithIdempotencyKey("request-1"), WithAuditSink(sink)); err == nil {
+		t.Fatal("ApplyChangesWrapper() error = nil, want the audit failure")
+	}
+	if report.Weather != "" {
+		t.Fatalf("weather = %q after a failed apply", report.Weather)
+	}
+
+	result, err := ApplyChangesWrapper(changes, "EUA1", &report, WithIdempotencyStore(store), WithIdempotencyKey("request-1"))
+	if err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if result.Duplicate || report.Weather != "sunny" {
+		t.Errorf("retry after a failure: Duplicate = %v, weather = %q, want it applied", result.Duplicate, report.Weather)
+	}
+}
@@ -0,0 +1,109 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// applyTagName is the struct tag controlling how a field takes changes, e.g.
+// `apply:"immutable"` for a field that can't be changed once set
+const applyTagName = "apply"
+
+// ImmutableFieldError is returned when the changes address fields tagged
+// `apply:"immutable"` (or named by WithImmutableFields)
+type ImmutableFieldError struct {
+	// Fields are the paths of the offending keys (e.g. "createdBy" or
+	// "details.source"), sorted
+	Fields []string
+}
+
+func (e *ImmutableFieldError) Error() string {
+	return fmt.Sprintf("%s cannot be changed", quoteFields(e.Fields))
+}
+
+// rejectImmutableFields fails on (or, with WithSkipImmutableFields, drops and
+// returns) every key of the changes addressing an immutable field, including
+// the fields of nested structs. Keys are rejected whatever their value, so
+// changes sending back a whole object must leave its immutable fields out, as
+// ApplyJSONPatch does.
+func rejectImmutableFields(changes map[string]interface{}, target reflect.Value, cfg *config) ([]string, error) {
+	immutable := immutableFields(changes, target.Type(), cfg, "", "")
+	if len(immutable) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(immutable)
+	if !cfg.skipImmutable {
+		return nil, &ImmutableFieldError{Fields: immutable}
+	}
+
+	for _, path := range immutable {
+		dropPath(changes, strings.Split(path, "."))
+	}
+
+	return immutable, nil
+}
+
+// immutableFields lists the paths of the keys addressing immutable fields.
+// WithImmutableFields names fields by tag name, so the path of tag names leading
+// to the changes is tracked alongside the path of their keys.
+func immutableFields(changes map[string]interface{}, structType reflect.Type, cfg *config, prefix string, tagPrefix string) []string {
+	var paths []string
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, cfg.tagName, key)
+		if !ok {
+			continue
+		}
+
+		tagPath := tagPrefix + fieldKey(field, cfg.tagName)
+		if (isImmutable(field) && !cfg.ignoreImmutable) || cfg.immutableFields[tagPath] {
+			paths = append(paths, prefix+key)
+			continue
+		}
+
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			paths = append(paths, immutableFields(nested, fieldType, cfg, prefix+key+".", tagPath+".")...)
+		}
+	}
+
+	return paths
+}
+
+func isImmutable(field reflect.StructField) bool {
+	return hasApplyOption(field, "immutable")
+}
+
+// hasApplyOption reports whether the field's apply tag lists the option
+func hasApplyOption(field reflect.StructField, option string) bool {
+	for _, candidate := range strings.Split(field.Tag.Get(applyTagName), ",") {
+		if strings.TrimSpace(candidate) == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dropPath deletes the key at path from the (nested) changes
+func dropPath(changes map[string]interface{}, path []string) {
+	for len(path) > 1 {
+		nested, ok := changes[path[0]].(map[string]interface{})
+		if !ok {
+			return
+		}
+		changes, path = nested, path[1:]
+	}
+
+	delete(changes, path[0])
+}
@@ -0,0 +1,110 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestImmutableFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		opts        []Option
+		want        func(station *patchedStation)
+		wantDropped []string
+		wantErr     []string
+	}{
+		{
+			name:    "tagged field",
+			changes: map[string]interface{}{"code": "MIA", "name": "Miami"},
+			wantErr: []string{"code"},
+		},
+		{
+			name:    "even with its current value",
+			changes: map[string]interface{}{"code": "TPA"},
+			wantErr: []string{"code"},
+		},
+		{
+			name:    "embedded BaseStruct fields",
+			changes: map[string]interface{}{"id": uuid.New().String(), "createdBy": "EUA9"},
+			wantErr: []string{"createdBy", "id"},
+		},
+		{
+			name:    "nested struct fields",
+			changes: map[string]interface{}{"site": map[string]interface{}{"code": "S2"}, "alias": map[string]interface{}{"code": "A2"}},
+			wantErr: []string{"alias.code", "site.code"},
+		},
+		{
+			name:    "named by WithImmutableFields",
+			changes: map[string]interface{}{"name": "Miami", "site": map[string]interface{}{"name": "Dock"}},
+			opts:    []Option{WithImmutableFields("site.name")},
+			wantErr: []string{"site.name"},
+		},
+		{
+			name:        "skipped",
+			changes:     map[string]interface{}{"code": "MIA", "name": "Miami", "site": map[string]interface{}{"code": "S2", "name": "Dock"}},
+			opts:        []Option{WithSkipImmutableFields()},
+			want:        func(station *patchedStation) { station.Name, station.Site.Name = "Miami", "Dock" },
+			wantDropped: []string{"code", "site.code"},
+		},
+		{
+			name:    "tags ignored",
+			changes: map[string]interface{}{"code": "MIA", "site": map[string]interface{}{"code": "S2"}},
+			opts:    []Option{WithIgnoreImmutableTags()},
+			want:    func(station *patchedStation) { station.Code, station.Site.Code = "MIA", "S2" },
+		},
+		{
+			name:    "tags ignored, WithImmutableFields kept",
+			changes: map[string]interface{}{"code": "MIA", "name": "Miami"},
+			opts:    []Option{WithIgnoreImmutableTags(), WithImmutableFields("name")},
+			wantErr: []string{"name"},
+		},
+		{
+			name:    "mutable fields",
+			changes: map[string]interface{}{"name": "Miami", "site": map[string]interface{}{"name": "Dock"}},
+			want:    func(station *patchedStation) { station.Name, station.Site.Name = "Miami", "Dock" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newStation := func() patchedStation {
+				return patchedStation{
+					Code:  "TPA",
+					Name:  "Tampa",
+					Site:  patchedSite{Code: "S1", Name: "Port"},
+					Alias: &patchedSite{Code: "A1", Name: "Pier"},
+				}
+			}
+
+			station := newStation()
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &station, tt.opts...)
+			if tt.wantErr != nil {
+				var immutable *ImmutableFieldError
+				if !errors.As(err, &immutable) || !reflect.DeepEqual(immutable.Fields, tt.wantErr) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %v immutable", err, tt.wantErr)
+				}
+				if want := newStation(); !reflect.DeepEqual(station, want) {
+					t.Errorf("station = %+v, want nothing applied", station)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			want := newStation()
+			tt.want(&want)
+			want.BaseStruct = station.BaseStruct
+			if !reflect.DeepEqual(station, want) {
+				t.Errorf("station = %+v, want %+v", station, want)
+			}
+			if !reflect.DeepEqual(result.DroppedFields, tt.wantDropped) {
+				t.Errorf("DroppedFields = %v, want %v", result.DroppedFields, tt.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package applychanges
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrImpersonationReason is returned when WithImpersonation is given without
+	// a reason, or without the actors
+	ErrImpersonationReason = errors.New("impersonation needs an actual actor, who they act on behalf of and a reason")
+
+	// ErrImpersonationModifier is returned when a modifier (or principal) is
+	// given along with WithImpersonation, which names the modifier itself, and
+	// when it's given to an apply that doesn't stamp one, like ApplyChanges
+	ErrImpersonationModifier = errors.New("an impersonating apply takes its modifier from WithImpersonation, so the modifier must be empty")
+)
+
+// impersonation is who really applies changes on behalf of whom, see
+// WithImpersonation
+type impersonation struct {
+	actualActor string
+	onBehalfOf  string
+	reason      string
+}
+
+// impersonate makes the impersonated user the modifier, failing unless the
+// impersonation is complete and the apply wasn't given a modifier of its own
+func (cfg *config) impersonate() error {
+	if cfg.modifier == nil || *cfg.modifier != "" || cfg.principal != nil {
+		return ErrImpersonationModifier
+	}
+
+	if strings.TrimSpace(cfg.impersonation.actualActor) == "" ||
+		strings.TrimSpace(cfg.impersonation.onBehalfOf) == "" ||
+		strings.TrimSpace(cfg.impersonation.reason) == "" {
+		return ErrImpersonationReason
+	}
+
+	cfg.modifier = &cfg.impersonation.onBehalfOf
+	return nil
+}
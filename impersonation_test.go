@@ -0,0 +1,76 @@
+package applychanges
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWithImpersonation(t *testing.T) {
+	tests := []struct {
+		name     string
+		modifier string
+		opt      Option
+		wantErr  error
+	}{
+		{name: "stamps the impersonated user", opt: WithImpersonation("SUPPORT1", "EUA1", "ticket 4411: fix the misspelled city")},
+		{name: "empty reason", opt: WithImpersonation("SUPPORT1", "EUA1", " "), wantErr: ErrImpersonationReason},
+		{name: "no actual actor", opt: WithImpersonation("", "EUA1", "ticket 4411"), wantErr: ErrImpersonationReason},
+		{name: "modifier as well", modifier: "SUPPORT1", opt: WithImpersonation("SUPPORT1", "EUA1", "ticket 4411"), wantErr: ErrImpersonationModifier},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			record := nestedRecord{}
+			_, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, tt.modifier, &record, tt.opt, WithAuditSink(sink))
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.wantErr)
+				}
+				if record.Name != "" || len(sink.entries) != 0 {
+					t.Errorf("record = %+v with %d audit entries, want it untouched", record, len(sink.entries))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+			if record.ModifiedBy == nil || *record.ModifiedBy != "EUA1" {
+				t.Errorf("ModifiedBy = %v, want EUA1", record.ModifiedBy)
+			}
+			if record.ModifiedByPrincipal == nil || record.ModifiedByPrincipal.ID != "EUA1" {
+				t.Errorf("ModifiedByPrincipal = %+v, want EUA1", record.ModifiedByPrincipal)
+			}
+
+			if len(sink.entries) != 1 {
+				t.Fatalf("recorded %d audit entries, want 1", len(sink.entries))
+			}
+			entry := sink.entries[0]
+			if entry.Modifier != "EUA1" || entry.ActualActor != "SUPPORT1" || entry.Reason != "ticket 4411: fix the misspelled city" {
+				t.Errorf("AuditEntry = %+v, want EUA1 impersonated by SUPPORT1 with the reason", entry)
+			}
+		})
+	}
+}
+
+func TestImpersonationExclusive(t *testing.T) {
+	record := nestedRecord{}
+	impersonate := WithImpersonation("SUPPORT1", "EUA1", "ticket 4411")
+
+	if _, err := ApplyChangesAs(map[string]interface{}{"name": "x"}, Principal{ID: "EUA2"}, &record, impersonate); !errors.Is(err, ErrImpersonationModifier) {
+		t.Errorf("ApplyChangesAs() error = %v, want ErrImpersonationModifier", err)
+	}
+	if _, err := ApplyChanges(map[string]interface{}{"name": "x"}, &record, impersonate); !errors.Is(err, ErrImpersonationModifier) {
+		t.Errorf("ApplyChanges() error = %v, want ErrImpersonationModifier", err)
+	}
+
+	sink := &recordingSink{}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "x"}, "EUA2", &record, WithAuditSink(sink)); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if entry := sink.entries[0]; entry.ActualActor != "" || entry.Reason != "" {
+		t.Errorf("AuditEntry = %+v, want no impersonation recorded", entry)
+	}
+}
@@ -0,0 +1,328 @@
+package applygentest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+// stampedAt replaces the stamped modifiedDts on both sides of a comparison
+var stampedAt = time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+
+var (
+	networkID = uuid.MustParse("9b5f0a4e-3f1c-4d7a-8c57-1e7f1f2c3a4b")
+	backupID  = uuid.MustParse("0d6c1f0e-6a3b-4c4e-9d1e-2f3a4b5c6d7e")
+)
+
+func newStation() Station {
+	elevation := 12.5
+	code := "4321"
+	installed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	return Station{
+		BaseStruct:  applychanges.BaseStruct{CreatedBy: "EUA0"},
+		Name:        "Tampa",
+		Elevation:   &elevation,
+		Active:      true,
+		Channels:    4,
+		Readings:    120,
+		Code:        &code,
+		InstalledAt: &installed,
+		Network:     networkID,
+		Operator:    "ops",
+		Internal:    "kept",
+	}
+}
+
+func newReading() Reading {
+	verified := true
+	return Reading{Serial: "S1", Celsius: 21.5, Count: 3, Pin: "1234", Verified: &verified}
+}
+
+func TestGeneratedStationApplierMatchesReflection(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+	}{
+		{name: "no changes", changes: map[string]interface{}{}},
+		{name: "string", changes: map[string]interface{}{"name": "Miami"}},
+		{name: "empty string", changes: map[string]interface{}{"name": ""}},
+		{name: "null string", changes: map[string]interface{}{"name": nil}},
+		{name: "untagged field", changes: map[string]interface{}{"Operator": "field team"}},
+		{name: "number for a string", changes: map[string]interface{}{"name": 7.0}},
+		{name: "bool for a string", changes: map[string]interface{}{"name": true}},
+		{name: "time for a string", changes: map[string]interface{}{"name": stampedAt}},
+		{name: "float pointer", changes: map[string]interface{}{"elevation": 30.25}},
+		{name: "int for a float", changes: map[string]interface{}{"elevation": 30}},
+		{name: "null float pointer", changes: map[string]interface{}{"elevation": nil}},
+		{name: "string for a float", changes: map[string]interface{}{"elevation": "high"}},
+		{name: "bool", changes: map[string]interface{}{"active": false}},
+		{name: "string for a bool", changes: map[string]interface{}{"active": "yes"}},
+		{name: "float truncated into an int", changes: map[string]interface{}{"channels": 3.9}},
+		{name: "int wrapped into an int8", changes: map[string]interface{}{"channels": 300}},
+		{name: "unsigned into an int", changes: map[string]interface{}{"channels": uint16(7), "readings": uint64(9)}},
+		{name: "int64", changes: map[string]interface{}{"readings": int64(1) << 40}},
+		{name: "string for an int", changes: map[string]interface{}{"channels": "four"}},
+		{name: "json number", changes: map[string]interface{}{"readings": json.Number("12")}},
+		{name: "sensitive string", changes: map[string]interface{}{"code": "8765"}},
+		{name: "sensitive mistyped", changes: map[string]interface{}{"code": 8765, "name": false}},
+		{name: "time string", changes: map[string]interface{}{"installedAt": "2022-09-01T12:00:00.123Z"}},
+		{name: "time", changes: map[string]interface{}{"installedAt": stampedAt}},
+		{name: "unparsable time", changes: map[string]interface{}{"installedAt": "yesterday"}},
+		{name: "number for a time", changes: map[string]interface{}{"installedAt": 12}},
+		{name: "uuid for a time", changes: map[string]interface{}{"installedAt": networkID}},
+		{name: "uuid string", changes: map[string]interface{}{"network": backupID.String(), "backup": networkID.String()}},
+		{name: "uuid", changes: map[string]interface{}{"network": backupID, "backup": backupID}},
+		{name: "null uuid pointer", changes: map[string]interface{}{"backup": nil}},
+		{name: "unparsable uuid", changes: map[string]interface{}{"network": "not-a-uuid"}},
+		{name: "number for a uuid", changes: map[string]interface{}{"backup": 5}},
+		{name: "time for a uuid", changes: map[string]interface{}{"network": stampedAt}},
+		{name: "unknown key", changes: map[string]interface{}{"weather": "sunny", "name": "Miami"}},
+		{name: "tagged out", changes: map[string]interface{}{"Internal": "changed"}},
+		{name: "every error at once", changes: map[string]interface{}{"weather": "sunny", "name": 1, "active": 0, "backup": "zzz", "code": true}},
+		{name: "supplied modifiedBy", changes: map[string]interface{}{"modifiedBy": "someone else"}},
+		{name: "mistyped modifiedBy", changes: map[string]interface{}{"modifiedBy": 5}},
+		{name: "modifiedBy in another case", changes: map[string]interface{}{"ModifiedBy": "someone else"}},
+		{name: "supplied modifiedDts", changes: map[string]interface{}{"modifiedDts": "2022-09-01T12:00:00Z"}},
+		{name: "immutable field", changes: map[string]interface{}{"id": uuid.New().String()}},
+		{name: "immutable field and unknown key", changes: map[string]interface{}{"createdBy": "EUA9", "weather": "sunny"}},
+		{name: "key in another case", changes: map[string]interface{}{"NAME": "Miami"}},
+		{name: "conflicting keys", changes: map[string]interface{}{"name": "Miami", "Name": "Orlando"}},
+		{name: "non-ASCII key", changes: map[string]interface{}{"nämé": "Miami"}},
+		{name: "nested changes", changes: map[string]interface{}{"name": map[string]interface{}{"first": "Miami"}}},
+		{name: "slice", changes: map[string]interface{}{"name": []interface{}{"Miami"}}},
+		{name: "null slice", changes: map[string]interface{}{"name": []interface{}(nil)}},
+		{name: "invalid UTF-8", changes: map[string]interface{}{"name": "Mia\xffmi"}},
+		{name: "infinity", changes: map[string]interface{}{"elevation": math.Inf(1)}},
+		{name: "pointer value", changes: map[string]interface{}{"name": stringPtr("Miami")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compareAppliers(t, tt.changes, newStation, ApplyStationChanges, func(station *Station) {
+				if station.ModifiedDts != nil && station.ModifiedDts.After(stampedAt) {
+					station.ModifiedDts = &stampedAt
+				}
+			})
+		})
+	}
+}
+
+func TestGeneratedReadingApplierMatchesReflection(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+	}{
+		{name: "no changes", changes: map[string]interface{}{}},
+		{name: "float32", changes: map[string]interface{}{"celsius": 19.25, "count": 4}},
+		{name: "float64 rounded into a float32", changes: map[string]interface{}{"celsius": 0.1}},
+		{name: "float truncated into an int", changes: map[string]interface{}{"count": -2.7}},
+		{name: "string for a float", changes: map[string]interface{}{"celsius": "warm"}},
+		{name: "bool pointer", changes: map[string]interface{}{"verified": false}},
+		{name: "null bool pointer", changes: map[string]interface{}{"verified": nil}},
+		{name: "string for a bool", changes: map[string]interface{}{"verified": "no"}},
+		{name: "sensitive value", changes: map[string]interface{}{"pin": "9999"}},
+		{name: "emptied sensitive value", changes: map[string]interface{}{"pin": ""}},
+		{name: "sensitive mistyped", changes: map[string]interface{}{"pin": 9999}},
+		{name: "unknown key", changes: map[string]interface{}{"station": "Tampa"}},
+		{name: "immutable field", changes: map[string]interface{}{"serial": "S2"}},
+		{name: "supplied modifiedDts", changes: map[string]interface{}{"ModifiedDts": stampedAt}},
+		{name: "key in another case", changes: map[string]interface{}{"Count": 5}},
+		{name: "zero count", changes: map[string]interface{}{"count": 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compareAppliers(t, tt.changes, newReading, ApplyReadingChanges, func(reading *Reading) {
+				if reading.ModifiedDts.After(stampedAt) {
+					reading.ModifiedDts = stampedAt
+				}
+			})
+		})
+	}
+}
+
+// compareAppliers applies copies of the changes to new targets through the
+// generated applier and through ApplyChangesWrapper, failing unless the
+// targets, the changes left behind, the results and the errors match, once
+// the stamped modifiedDts is normalized
+func compareAppliers[T any](t *testing.T, changes map[string]interface{}, newTarget func() T, generated func(map[string]interface{}, string, *T) (applychanges.ApplyResult, error), normalize func(*T)) {
+	t.Helper()
+
+	generatedChanges, reflectedChanges := cloneChanges(changes), cloneChanges(changes)
+	generatedTarget, reflectedTarget := newTarget(), newTarget()
+
+	generatedResult, generatedErr := generated(generatedChanges, "EUA1", &generatedTarget)
+	reflectedResult, reflectedErr := applychanges.ApplyChangesWrapper(reflectedChanges, "EUA1", &reflectedTarget)
+
+	if got, want := describeError(generatedErr), describeError(reflectedErr); got != want {
+		t.Fatalf("generated error = %s, want %s", got, want)
+	}
+
+	normalize(&generatedTarget)
+	normalize(&reflectedTarget)
+	if !reflect.DeepEqual(generatedTarget, reflectedTarget) {
+		t.Errorf("generated target = %+v, want %+v", generatedTarget, reflectedTarget)
+	}
+
+	normalizeChanges(generatedChanges)
+	normalizeChanges(reflectedChanges)
+	if !reflect.DeepEqual(generatedChanges, reflectedChanges) {
+		t.Errorf("generated changes left = %#v, want %#v", generatedChanges, reflectedChanges)
+	}
+
+	normalizeResult(&generatedResult)
+	normalizeResult(&reflectedResult)
+	if !reflect.DeepEqual(generatedResult, reflectedResult) {
+		t.Errorf("generated result = %#v, want %#v", generatedResult, reflectedResult)
+	}
+}
+
+// describeError renders an error with its type, and those of the errors of
+// FieldErrors
+func describeError(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	description := fmt.Sprintf("%T %q", err, err.Error())
+	if fieldErrors, ok := err.(applychanges.FieldErrors); ok {
+		for _, fieldErr := range fieldErrors {
+			description += fmt.Sprintf(" [%s: %T]", fieldErr.Path, fieldErr.Err)
+		}
+	}
+
+	return description
+}
+
+func normalizeChanges(changes map[string]interface{}) {
+	if _, ok := changes["modifiedDts"].(time.Time); ok {
+		changes["modifiedDts"] = stampedAt
+	}
+}
+
+func normalizeResult(result *applychanges.ApplyResult) {
+	for i, change := range result.Changes {
+		if change.Path == "modifiedDts" && change.New != nil {
+			result.Changes[i].New = stampedAt
+		}
+	}
+}
+
+// cloneChanges copies the changes, along with their nested maps and slices
+func cloneChanges(changes map[string]interface{}) map[string]interface{} {
+	if changes == nil {
+		return nil
+	}
+
+	cloned := make(map[string]interface{}, len(changes))
+	for key, value := range changes {
+		cloned[key] = cloneValue(value)
+	}
+
+	return cloned
+}
+
+func cloneValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return cloneChanges(typed)
+	case []interface{}:
+		if typed == nil {
+			return typed
+		}
+		cloned := make([]interface{}, len(typed))
+		for i, element := range typed {
+			cloned[i] = cloneValue(element)
+		}
+		return cloned
+	}
+
+	return value
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestApplyDispatchesToGeneratedApplier(t *testing.T) {
+	calls := 0
+	applychanges.RegisterApplier(func(changes map[string]interface{}, modifier string, to *Reading) (applychanges.ApplyResult, error) {
+		calls++
+		return ApplyReadingChanges(changes, modifier, to)
+	})
+	t.Cleanup(func() {
+		applychanges.RegisterApplier(ApplyReadingChanges)
+	})
+
+	tests := []struct {
+		name      string
+		opts      []applychanges.Option
+		wantCalls int
+	}{
+		{name: "without options", wantCalls: 1},
+		{name: "with options", opts: []applychanges.Option{applychanges.WithModifiedDts(false)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			reading := newReading()
+
+			result, err := applychanges.Apply(map[string]interface{}{"count": 7}, "EUA1", &reading, tt.opts...)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("generated applier called %d times, want %d", calls, tt.wantCalls)
+			}
+			if reading.Count != 7 || reading.ModifiedBy != "EUA1" {
+				t.Errorf("target = %+v, want the count and modifier applied", reading)
+			}
+			if len(result.Changes) == 0 {
+				t.Errorf("Changes = %v, want the applied fields", result.Changes)
+			}
+		})
+	}
+
+	t.Run("applied", func(t *testing.T) {
+		calls = 0
+		from := newReading()
+
+		to, err := applychanges.Applied(map[string]interface{}{"count": 8}, "EUA1", from)
+		if err != nil {
+			t.Fatalf("Applied() error = %v", err)
+		}
+		if calls != 1 || to.Count != 8 || from.Count != 3 {
+			t.Errorf("Applied() = %+v from %+v with %d calls, want a changed copy through the generated applier", to, from, calls)
+		}
+	})
+}
+
+func BenchmarkApplyStation(b *testing.B) {
+	changes := map[string]interface{}{"name": "Miami", "elevation": 30.25, "active": false, "installedAt": "2022-09-01T12:00:00Z"}
+
+	b.Run("generated", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			station := newStation()
+			if _, err := ApplyStationChanges(cloneChanges(changes), "EUA1", &station); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("reflection", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			station := newStation()
+			if _, err := applychanges.ApplyChangesWrapper(cloneChanges(changes), "EUA1", &station); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
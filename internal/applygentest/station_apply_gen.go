@@ -0,0 +1,567 @@
+// Code generated by applygen; DO NOT EDIT.
+
+package applygentest
+
+import (
+	"sort"
+	"time"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+	"github.com/DylanSpOddball/apply-changes-wrapper/genapply"
+)
+
+func init() {
+	applychanges.RegisterFastDecoder("json", applyChangesStation)
+	applychanges.RegisterApplier(ApplyStationChanges)
+	applychanges.RegisterFastDecoder("json", applyChangesReading)
+	applychanges.RegisterApplier(ApplyReadingChanges)
+}
+
+// applyChangesStation assigns the changes to a Station without
+// reflection, or reports false (leaving it untouched) when it can't handle
+// them
+func applyChangesStation(changes map[string]interface{}, to *Station) bool {
+	for key, value := range changes {
+		switch key {
+		case "Operator":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "active":
+			if _, ok := value.(bool); !ok {
+				return false
+			}
+		case "channels":
+			if _, ok := value.(int8); !ok {
+				return false
+			}
+		case "code":
+			switch value.(type) {
+			case nil, string:
+			default:
+				return false
+			}
+		case "createdBy":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "createdDts":
+			if _, ok := value.(time.Time); !ok {
+				return false
+			}
+		case "deletedBy":
+			switch value.(type) {
+			case nil, string:
+			default:
+				return false
+			}
+		case "deletedDts":
+			switch value.(type) {
+			case nil, time.Time:
+			default:
+				return false
+			}
+		case "elevation":
+			switch value.(type) {
+			case nil, float64:
+			default:
+				return false
+			}
+		case "installedAt":
+			switch value.(type) {
+			case nil, time.Time:
+			default:
+				return false
+			}
+		case "modifiedBy":
+			switch value.(type) {
+			case nil, string:
+			default:
+				return false
+			}
+		case "modifiedDts":
+			switch value.(type) {
+			case nil, time.Time:
+			default:
+				return false
+			}
+		case "name":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "readings":
+			if _, ok := value.(int64); !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	for key, value := range changes {
+		switch key {
+		case "Operator":
+			to.Operator = value.(string)
+		case "active":
+			to.Active = value.(bool)
+		case "channels":
+			to.Channels = value.(int8)
+		case "code":
+			if value == nil {
+				to.Code = nil
+			} else {
+				v := value.(string)
+				to.Code = &v
+			}
+		case "createdBy":
+			to.BaseStruct.CreatedBy = value.(string)
+		case "createdDts":
+			to.BaseStruct.CreatedDts = value.(time.Time)
+		case "deletedBy":
+			if value == nil {
+				to.BaseStruct.DeletedBy = nil
+			} else {
+				v := value.(string)
+				to.BaseStruct.DeletedBy = &v
+			}
+		case "deletedDts":
+			if value == nil {
+				to.BaseStruct.DeletedDts = nil
+			} else {
+				v := value.(time.Time)
+				to.BaseStruct.DeletedDts = &v
+			}
+		case "elevation":
+			if value == nil {
+				to.Elevation = nil
+			} else {
+				v := value.(float64)
+				to.Elevation = &v
+			}
+		case "installedAt":
+			if value == nil {
+				to.InstalledAt = nil
+			} else {
+				v := value.(time.Time)
+				to.InstalledAt = &v
+			}
+		case "modifiedBy":
+			if value == nil {
+				to.BaseStruct.ModifiedBy = nil
+			} else {
+				v := value.(string)
+				to.BaseStruct.ModifiedBy = &v
+			}
+		case "modifiedDts":
+			if value == nil {
+				to.BaseStruct.ModifiedDts = nil
+			} else {
+				v := value.(time.Time)
+				to.BaseStruct.ModifiedDts = &v
+			}
+		case "name":
+			to.Name = value.(string)
+		case "readings":
+			to.Readings = value.(int64)
+		}
+	}
+
+	return true
+}
+
+// ApplyStationChanges applies the changes to a Station like
+// applychanges.ApplyChangesWrapper without options, but without reflection.
+// Changes it can't handle itself are handed to ApplyChangesWrapper.
+func ApplyStationChanges(changes map[string]interface{}, modifier string, to *Station) (applychanges.ApplyResult, error) {
+	if to == nil || !genapply.Plain(changes) {
+		return applychanges.ApplyChangesWrapper(changes, modifier, to)
+	}
+
+	for key := range changes {
+		switch key {
+		case "Operator", "active", "backup", "channels", "code", "elevation", "installedAt", "modifiedBy", "name", "network", "readings":
+		case "createdBy", "createdDts", "deletedBy", "deletedDts", "id", "modifiedDts":
+			return applychanges.ApplyChangesWrapper(changes, modifier, to)
+		default:
+			if genapply.Folded(key, stationKeys) {
+				return applychanges.ApplyChangesWrapper(changes, modifier, to)
+			}
+		}
+	}
+
+	genapply.Sanitize(changes)
+	changes["modifiedBy"] = modifier
+	now := time.Now().UTC()
+	changes["modifiedDts"] = now
+
+	next := *to
+	var errs []*applychanges.FieldError
+	for key, value := range changes {
+		switch key {
+		case "Operator":
+			v, err := genapply.String("Operator", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Operator = v
+		case "active":
+			v, err := genapply.Bool("active", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Active = v
+		case "backup":
+			if value == nil {
+				next.Backup = nil
+				continue
+			}
+			v, err := genapply.UUID("backup", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Backup = &v
+		case "channels":
+			v, err := genapply.Int("channels", "int8", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Channels = int8(v)
+		case "code":
+			if value == nil {
+				next.Code = nil
+				continue
+			}
+			v, err := genapply.String("code", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Code = &v
+		case "elevation":
+			if value == nil {
+				next.Elevation = nil
+				continue
+			}
+			v, err := genapply.Float("elevation", "float64", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Elevation = &v
+		case "installedAt":
+			if value == nil {
+				next.InstalledAt = nil
+				continue
+			}
+			v, err := genapply.Time("installedAt", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.InstalledAt = &v
+		case "modifiedBy":
+			if value == nil {
+				next.BaseStruct.ModifiedBy = nil
+				continue
+			}
+			v, err := genapply.String("modifiedBy", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.BaseStruct.ModifiedBy = &v
+		case "modifiedDts":
+			stamped := now
+			next.BaseStruct.ModifiedDts = &stamped
+		case "name":
+			v, err := genapply.String("name", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Name = v
+		case "network":
+			v, err := genapply.UUID("network", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Network = v
+		case "readings":
+			v, err := genapply.Int("readings", "int64", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Readings = v
+		default:
+			errs = append(errs, genapply.NoSuchField(key))
+		}
+	}
+	if len(errs) > 0 {
+		return applychanges.ApplyResult{}, genapply.FieldErrors(errs, "code")
+	}
+
+	result := applychanges.ApplyResult{AppliedFields: make([]string, 0, len(changes))}
+	for key := range changes {
+		result.AppliedFields = append(result.AppliedFields, key)
+	}
+	sort.Strings(result.AppliedFields)
+
+	result.Changes = make([]applychanges.FieldChange, 0, len(result.AppliedFields))
+	for _, key := range result.AppliedFields {
+		result.Changes = append(result.Changes, stationChange(key, to, &next))
+	}
+
+	*to = next
+	return result, nil
+}
+
+// stationKeys are the keys of a Station, lowercased
+var stationKeys = map[string]bool{
+	"active":      true,
+	"backup":      true,
+	"channels":    true,
+	"code":        true,
+	"createdby":   true,
+	"createddts":  true,
+	"deletedby":   true,
+	"deleteddts":  true,
+	"elevation":   true,
+	"id":          true,
+	"installedat": true,
+	"modifiedby":  true,
+	"modifieddts": true,
+	"name":        true,
+	"network":     true,
+	"operator":    true,
+	"readings":    true,
+}
+
+// stationChange reports the change to the field of a Station with the key
+func stationChange(key string, before, after *Station) applychanges.FieldChange {
+	switch key {
+	case "Operator":
+		return applychanges.FieldChange{Path: key, Old: before.Operator, New: after.Operator}
+	case "active":
+		return applychanges.FieldChange{Path: key, Old: before.Active, New: after.Active}
+	case "backup":
+		return applychanges.FieldChange{Path: key, Old: genapply.Value(before.Backup), New: genapply.Value(after.Backup)}
+	case "channels":
+		return applychanges.FieldChange{Path: key, Old: before.Channels, New: after.Channels}
+	case "code":
+		return applychanges.FieldChange{Path: key, Old: genapply.Value(before.Code), New: genapply.Value(after.Code), Sensitive: true}
+	case "elevation":
+		return applychanges.FieldChange{Path: key, Old: genapply.Value(before.Elevation), New: genapply.Value(after.Elevation)}
+	case "installedAt":
+		return applychanges.FieldChange{Path: key, Old: genapply.Value(before.InstalledAt), New: genapply.Value(after.InstalledAt)}
+	case "modifiedBy":
+		return applychanges.FieldChange{Path: key, Old: genapply.Value(before.BaseStruct.ModifiedBy), New: genapply.Value(after.BaseStruct.ModifiedBy)}
+	case "modifiedDts":
+		return applychanges.FieldChange{Path: key, Old: genapply.Value(before.BaseStruct.ModifiedDts), New: genapply.Value(after.BaseStruct.ModifiedDts)}
+	case "name":
+		return applychanges.FieldChange{Path: key, Old: before.Name, New: after.Name}
+	case "network":
+		return applychanges.FieldChange{Path: key, Old: before.Network, New: after.Network}
+	case "readings":
+		return applychanges.FieldChange{Path: key, Old: before.Readings, New: after.Readings}
+	}
+
+	return applychanges.FieldChange{Path: key}
+}
+
+// applyChangesReading assigns the changes to a Reading without
+// reflection, or reports false (leaving it untouched) when it can't handle
+// them
+func applyChangesReading(changes map[string]interface{}, to *Reading) bool {
+	for key, value := range changes {
+		switch key {
+		case "celsius":
+			if _, ok := value.(float32); !ok {
+				return false
+			}
+		case "count":
+			if _, ok := value.(int); !ok {
+				return false
+			}
+		case "modifiedBy":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "modifiedDts":
+			if _, ok := value.(time.Time); !ok {
+				return false
+			}
+		case "pin":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "serial":
+			if _, ok := value.(string); !ok {
+				return false
+			}
+		case "verified":
+			switch value.(type) {
+			case nil, bool:
+			default:
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	for key, value := range changes {
+		switch key {
+		case "celsius":
+			to.Celsius = value.(float32)
+		case "count":
+			to.Count = value.(int)
+		case "modifiedBy":
+			to.ModifiedBy = value.(string)
+		case "modifiedDts":
+			to.ModifiedDts = value.(time.Time)
+		case "pin":
+			to.Pin = value.(string)
+		case "serial":
+			to.Serial = value.(string)
+		case "verified":
+			if value == nil {
+				to.Verified = nil
+			} else {
+				v := value.(bool)
+				to.Verified = &v
+			}
+		}
+	}
+
+	return true
+}
+
+// ApplyReadingChanges applies the changes to a Reading like
+// applychanges.ApplyChangesWrapper without options, but without reflection.
+// Changes it can't handle itself are handed to ApplyChangesWrapper.
+func ApplyReadingChanges(changes map[string]interface{}, modifier string, to *Reading) (applychanges.ApplyResult, error) {
+	if to == nil || !genapply.Plain(changes) {
+		return applychanges.ApplyChangesWrapper(changes, modifier, to)
+	}
+
+	for key := range changes {
+		switch key {
+		case "celsius", "count", "modifiedBy", "pin", "verified":
+		case "modifiedDts", "serial":
+			return applychanges.ApplyChangesWrapper(changes, modifier, to)
+		default:
+			if genapply.Folded(key, readingKeys) {
+				return applychanges.ApplyChangesWrapper(changes, modifier, to)
+			}
+		}
+	}
+
+	genapply.Sanitize(changes)
+	changes["modifiedBy"] = modifier
+	now := time.Now().UTC()
+	changes["modifiedDts"] = now
+
+	next := *to
+	var errs []*applychanges.FieldError
+	for key, value := range changes {
+		switch key {
+		case "celsius":
+			v, err := genapply.Float("celsius", "float32", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Celsius = float32(v)
+		case "count":
+			v, err := genapply.Int("count", "int", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Count = int(v)
+		case "modifiedBy":
+			v, err := genapply.String("modifiedBy", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.ModifiedBy = v
+		case "modifiedDts":
+			next.ModifiedDts = now
+		case "pin":
+			v, err := genapply.String("pin", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Pin = v
+		case "verified":
+			if value == nil {
+				next.Verified = nil
+				continue
+			}
+			v, err := genapply.Bool("verified", value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			next.Verified = &v
+		default:
+			errs = append(errs, genapply.NoSuchField(key))
+		}
+	}
+	if len(errs) > 0 {
+		return applychanges.ApplyResult{}, genapply.FieldErrors(errs, "pin")
+	}
+
+	result := applychanges.ApplyResult{AppliedFields: make([]string, 0, len(changes))}
+	for key := range changes {
+		result.AppliedFields = append(result.AppliedFields, key)
+	}
+	sort.Strings(result.AppliedFields)
+
+	result.Changes = make([]applychanges.FieldChange, 0, len(result.AppliedFields))
+	for _, key := range result.AppliedFields {
+		result.Changes = append(result.Changes, readingChange(key, to, &next))
+	}
+
+	*to = next
+	return result, nil
+}
+
+// readingKeys are the keys of a Reading, lowercased
+var readingKeys = map[string]bool{
+	"celsius":     true,
+	"count":       true,
+	"modifiedby":  true,
+	"modifieddts": true,
+	"pin":         true,
+	"serial":      true,
+	"verified":    true,
+}
+
+// readingChange reports the change to the field of a Reading with the key
+func readingChange(key string, before, after *Reading) applychanges.FieldChange {
+	switch key {
+	case "celsius":
+		return applychanges.FieldChange{Path: key, Old: before.Celsius, New: after.Celsius}
+	case "count":
+		return applychanges.FieldChange{Path: key, Old: before.Count, New: after.Count}
+	case "modifiedBy":
+		return applychanges.FieldChange{Path: key, Old: before.ModifiedBy, New: after.ModifiedBy}
+	case "modifiedDts":
+		return applychanges.FieldChange{Path: key, Old: before.ModifiedDts, New: after.ModifiedDts}
+	case "pin":
+		return applychanges.FieldChange{Path: key, Old: before.Pin, New: after.Pin, Sensitive: true}
+	case "verified":
+		return applychanges.FieldChange{Path: key, Old: genapply.Value(before.Verified), New: genapply.Value(after.Verified)}
+	}
+
+	return applychanges.FieldChange{Path: key}
+}
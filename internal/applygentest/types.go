@@ -0,0 +1,41 @@
+// Package applygentest holds the types the appliers cmd/applygen generates
+// with -full are tested against, comparing them with the reflection path
+package applygentest
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+//go:generate go run ../../cmd/applygen -type Station,Reading -full
+
+// Station embeds the BaseStruct metadata, with its immutable and pointer
+// fields
+type Station struct {
+	applychanges.BaseStruct
+	Name        string     `json:"name"`
+	Elevation   *float64   `json:"elevation"`
+	Active      bool       `json:"active"`
+	Channels    int8       `json:"channels"`
+	Readings    int64      `json:"readings,omitempty"`
+	Code        *string    `json:"code" apply:"sensitive"`
+	InstalledAt *time.Time `json:"installedAt"`
+	Network     uuid.UUID  `json:"network"`
+	Backup      *uuid.UUID `json:"backup"`
+	Operator    string
+	Internal    string `json:"-"`
+}
+
+// Reading is flat, stamping its metadata into non-pointer fields
+type Reading struct {
+	ModifiedBy  string    `json:"modifiedBy"`
+	ModifiedDts time.Time `json:"modifiedDts"`
+	Serial      string    `json:"serial" apply:"immutable"`
+	Celsius     float32   `json:"celsius"`
+	Count       int       `json:"count"`
+	Pin         string    `json:"pin" apply:"sensitive"`
+	Verified    *bool     `json:"verified"`
+}
@@ -0,0 +1,403 @@
+package applychanges
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is a single RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch document (add, remove, replace,
+// move, copy and test operations) to the target with the same handling as
+// ApplyChangesWrapper.
+//
+// The operations are run in order against the target's JSON representation,
+// so paths use the target's json names and array indices; what they changed
+// then becomes the changes applied to the target, one key per top-level field
+// (nested structs carry only their changed fields, so the immutable fields
+// beside them aren't addressed). Nothing is applied if any operation
+// (including a test) fails.
+func ApplyJSONPatch(patch []byte, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	var operations []PatchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return ApplyResult{}, fmt.Errorf("invalid JSON patch: %w", err)
+	}
+
+	changes, err := jsonPatchChanges(operations, to, configFor(reflect.TypeOf(to), opts))
+	if err != nil {
+		return ApplyResult{}, err
+	}
+
+	return ApplyChangesWrapper(changes, modifier, to, opts...)
+}
+
+// jsonPatchChanges runs the operations against a JSON document of the target
+// and returns what they changed: the new value of every top-level field they
+// touched, or for a nested struct only its fields that changed (see
+// changedLeaves)
+func jsonPatchChanges(operations []PatchOperation, to interface{}, cfg *config) (map[string]interface{}, error) {
+	target, ok := targetStruct(to)
+	if !ok {
+		return nil, fmt.Errorf("changes can only be applied to structs, not %T", to)
+	}
+
+	document, err := jsonDocument(target)
+	if err != nil {
+		return nil, err
+	}
+	original, err := jsonDocument(target)
+	if err != nil {
+		return nil, err
+	}
+
+	touched := map[string]bool{}
+	for i, operation := range operations {
+		paths, err := applyPatchOperation(document, operation)
+		if err != nil {
+			return nil, fmt.Errorf("JSON patch operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+
+		for _, tokens := range paths {
+			touched[tokens[0]] = true
+		}
+	}
+
+	changes := make(map[string]interface{}, len(touched))
+	for jsonKey := range touched {
+		value, changed := changedLeaves(original[jsonKey], document[jsonKey], target.Type(), jsonKey)
+		if !changed {
+			continue
+		}
+
+		key := jsonKey
+		if field, ok := structFieldByTag(target.Type(), "json", jsonKey); ok {
+			if tagged := fieldKey(field, cfg.tagName); tagged != "" {
+				key = tagged
+			}
+		}
+
+		changes[key] = value
+	}
+
+	return changes, nil
+}
+
+// changedLeaves compares the value of a struct's field (by json key) before
+// and after the operations, returning what changed: the new value, or for a
+// nested struct the map of those of its fields that changed. Passing on only
+// those leaves the fields that weren't changed alone, so an operation inside
+// a nested struct doesn't address its immutable fields, and a field replaced
+// with its own value isn't applied at all.
+func changedLeaves(before, after interface{}, structType reflect.Type, jsonKey string) (interface{}, bool) {
+	if jsonEqual(before, after) {
+		return nil, false
+	}
+
+	beforeMap, wasMap := before.(map[string]interface{})
+	afterMap, isMap := after.(map[string]interface{})
+	field, ok := structFieldByTag(structType, "json", jsonKey)
+	if !wasMap || !isMap || !ok {
+		return after, true
+	}
+
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() != reflect.Struct || isScalar(fieldType) {
+		return after, true
+	}
+
+	changed := map[string]interface{}{}
+	for key, value := range afterMap {
+		if leaf, ok := changedLeaves(beforeMap[key], value, fieldType, key); ok {
+			changed[key] = leaf
+		}
+	}
+	for key, value := range beforeMap {
+		if _, ok := afterMap[key]; !ok && value != nil {
+			changed[key] = nil
+		}
+	}
+
+	return changed, true
+}
+
+// jsonDocument returns the target as a generic JSON object. Fields left out of
+// its JSON (omitempty) are present as null, so they can still be replaced or
+// removed.
+func jsonDocument(target reflect.Value) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(target.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	for _, field := range squashedFields(target.Type()) {
+		if key := fieldKey(field, "json"); key != "" && field.PkgPath == "" {
+			if _, ok := document[key]; !ok {
+				document[key] = nil
+			}
+		}
+	}
+
+	return document, nil
+}
+
+// applyPatchOperation runs one operation against the document, returning the
+// paths it modified
+func applyPatchOperation(document map[string]interface{}, operation PatchOperation) ([][]string, error) {
+	path, err := parseJSONPointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+
+		value, err := decodePatchValue(operation.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if operation.Op == "test" {
+			current, err := patchGet(document, path)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(current, value) {
+				return nil, fmt.Errorf("test failed: value differs")
+			}
+			return nil, nil
+		}
+
+		if operation.Op == "replace" {
+			if _, err := patchGet(document, path); err != nil {
+				return nil, err
+			}
+			if err := patchRemove(document, path); err != nil {
+				return nil, err
+			}
+		}
+
+		return [][]string{path}, patchAdd(document, path, value)
+	case "remove":
+		return [][]string{path}, patchRemove(document, path)
+	case "move", "copy":
+		from, err := parseJSONPointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := patchGet(document, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if operation.Op == "copy" {
+			if value != nil {
+				value = deepCopy(reflect.ValueOf(value)).Interface()
+			}
+			return [][]string{path}, patchAdd(document, path, value)
+		}
+
+		if len(path) > len(from) && strings.Join(path[:len(from)], "/") == strings.Join(from, "/") {
+			return nil, fmt.Errorf("cannot move a value into itself")
+		}
+		if err := patchRemove(document, from); err != nil {
+			return nil, err
+		}
+		return [][]string{from, path}, patchAdd(document, path, value)
+	}
+
+	return nil, fmt.Errorf("unsupported operation %q", operation.Op)
+}
+
+func decodePatchValue(raw json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+
+	return value, nil
+}
+
+// parseJSONPointer splits an RFC 6901 JSON pointer into its unescaped tokens.
+// The whole document ("") can't be addressed, since a struct can't be replaced
+// by an arbitrary value.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, fmt.Errorf("the whole document cannot be patched")
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func patchGet(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := node.(type) {
+		case map[string]interface{}:
+			child, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %q", token)
+			}
+			node = child
+		case []interface{}:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = container[index]
+		default:
+			return nil, fmt.Errorf("path not found: %q", token)
+		}
+	}
+
+	return node, nil
+}
+
+// patchAdd adds the value at path, inserting into arrays (or appending, for
+// "-") and setting object members. Arrays are rebuilt, so the parent of the
+// array is updated too.
+func patchAdd(document map[string]interface{}, path []string, value interface{}) error {
+	parent, err := patchGet(document, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[last] = value
+		return nil
+	case []interface{}:
+		index := len(container)
+		if last != "-" {
+			if index, err = arrayIndex(last, len(container)); err != nil {
+				return err
+			}
+		}
+
+		grown := make([]interface{}, 0, len(container)+1)
+		grown = append(grown, container[:index]...)
+		grown = append(grown, value)
+		grown = append(grown, container[index:]...)
+		return patchSet(document, path[:len(path)-1], grown)
+	}
+
+	return fmt.Errorf("path not found: %q", last)
+}
+
+func patchRemove(document map[string]interface{}, path []string) error {
+	parent, err := patchGet(document, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := container[last]; !ok {
+			return fmt.Errorf("path not found: %q", last)
+		}
+		if len(path) == 1 {
+			// top-level fields can't disappear from a struct; removing one
+			// clears it
+			container[last] = nil
+		} else {
+			delete(container, last)
+		}
+		return nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(container)-1)
+		if err != nil {
+			return err
+		}
+
+		shrunk := make([]interface{}, 0, len(container)-1)
+		shrunk = append(shrunk, container[:index]...)
+		shrunk = append(shrunk, container[index+1:]...)
+		return patchSet(document, path[:len(path)-1], shrunk)
+	}
+
+	return fmt.Errorf("path not found: %q", last)
+}
+
+// patchSet replaces the existing value at path
+func patchSet(document map[string]interface{}, path []string, value interface{}) error {
+	parent, err := patchGet(document, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[last] = value
+	case []interface{}:
+		index, err := arrayIndex(last, len(container)-1)
+		if err != nil {
+			return err
+		}
+		container[index] = value
+	}
+
+	return nil
+}
+
+// arrayIndex parses an array index token, which must be between 0 and max
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+
+	return index, nil
+}
+
+// jsonEqual compares two decoded JSON values the way a JSON patch test does:
+// numbers by value and objects regardless of member order
+func jsonEqual(a, b interface{}) bool {
+	var bufA, bufB bytes.Buffer
+	if writeCanonical(&bufA, reflect.ValueOf(a)) != nil || writeCanonical(&bufB, reflect.ValueOf(b)) != nil {
+		return false
+	}
+
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
@@ -0,0 +1,215 @@
+package applychanges
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type patchedSite struct {
+	Code string `json:"code" apply:"immutable"`
+	Name string `json:"name"`
+}
+
+type patchedStation struct {
+	BaseStruct
+	Code  string       `json:"code" apply:"immutable"`
+	Name  string       `json:"name"`
+	Tags  []string     `json:"tags"`
+	Site  patchedSite  `json:"site"`
+	Alias *patchedSite `json:"alias"`
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		patch       string
+		want        func(record *patchedRecord)
+		wantApplied []string
+		wantErr     string
+	}{
+		{
+			name:        "replace",
+			patch:       `[{"op": "replace", "path": "/city", "value": "Tampa"}]`,
+			want:        func(record *patchedRecord) { record.City = "Tampa" },
+			wantApplied: []string{"city", "modifiedBy", "modifiedDts"},
+		},
+		{
+			name:        "add to an array",
+			patch:       `[{"op": "add", "path": "/tags/1", "value": "gulf"}, {"op": "add", "path": "/tags/-", "value": "south"}]`,
+			want:        func(record *patchedRecord) { record.Tags = []string{"east", "gulf", "coast", "south"} },
+			wantApplied: []string{"modifiedBy", "modifiedDts", "tags"},
+		},
+		{
+			name:        "remove",
+			patch:       `[{"op": "remove", "path": "/tags/0"}, {"op": "remove", "path": "/weather"}]`,
+			want:        func(record *patchedRecord) { record.Tags, record.Weather = []string{"coast"}, nil },
+			wantApplied: []string{"modifiedBy", "modifiedDts", "tags", "weather"},
+		},
+		{
+			name:        "nested field",
+			patch:       `[{"op": "replace", "path": "/details/level", "value": 3}]`,
+			want:        func(record *patchedRecord) { record.Details.Level = 3 },
+			wantApplied: []string{"details", "modifiedBy", "modifiedDts"},
+		},
+		{
+			name:        "move",
+			patch:       `[{"op": "move", "from": "/labels/a", "path": "/labels/z"}]`,
+			want:        func(record *patchedRecord) { record.Labels = map[string]string{"b": "2", "z": "1"} },
+			wantApplied: []string{"labels", "modifiedBy", "modifiedDts"},
+		},
+		{
+			name:        "copy",
+			patch:       `[{"op": "copy", "from": "/city", "path": "/weather"}]`,
+			want:        func(record *patchedRecord) { record.Weather = stringPtr("Miami") },
+			wantApplied: []string{"modifiedBy", "modifiedDts", "weather"},
+		},
+		{
+			name:        "passing test",
+			patch:       `[{"op": "test", "path": "/count", "value": 1}, {"op": "replace", "path": "/count", "value": 2}]`,
+			want:        func(record *patchedRecord) { record.Count = 2 },
+			wantApplied: []string{"count", "modifiedBy", "modifiedDts"},
+		},
+		{
+			name:        "replaced with the same value",
+			patch:       `[{"op": "replace", "path": "/city", "value": "Miami"}]`,
+			want:        func(record *patchedRecord) {},
+			wantApplied: []string{"modifiedBy", "modifiedDts"},
+		},
+		{
+			name:    "failing test",
+			patch:   `[{"op": "replace", "path": "/city", "value": "Tampa"}, {"op": "test", "path": "/count", "value": 5}]`,
+			wantErr: "JSON patch operation 1 (test /count): test failed: value differs",
+		},
+		{
+			name:    "missing path",
+			patch:   `[{"op": "replace", "path": "/details/depth", "value": 1}]`,
+			wantErr: `path not found: "depth"`,
+		},
+		{
+			name:    "array index out of range",
+			patch:   `[{"op": "remove", "path": "/tags/5"}]`,
+			wantErr: `array index "5" out of range`,
+		},
+		{
+			name:    "whole document",
+			patch:   `[{"op": "replace", "path": "", "value": {}}]`,
+			wantErr: "the whole document cannot be patched",
+		},
+		{
+			name:    "unsupported operation",
+			patch:   `[{"op": "merge", "path": "/city", "value": "Tampa"}]`,
+			wantErr: `unsupported operation "merge"`,
+		},
+		{
+			name:    "move into itself",
+			patch:   `[{"op": "move", "from": "/details", "path": "/details/source"}]`,
+			wantErr: "cannot move a value into itself",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newPatchedRecord()
+			result, err := ApplyJSONPatch([]byte(tt.patch), "EUA1", &record)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyJSONPatch() error = %v, want %s", err, tt.wantErr)
+				}
+				if want := newPatchedRecord(); !reflect.DeepEqual(record, want) {
+					t.Errorf("record = %+v, want nothing applied", record)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyJSONPatch() error = %v", err)
+			}
+
+			want := newPatchedRecord()
+			tt.want(&want)
+			want.BaseStruct = record.BaseStruct
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("record = %+v, want %+v", record, want)
+			}
+			if !reflect.DeepEqual(result.AppliedFields, tt.wantApplied) {
+				t.Errorf("AppliedFields = %v, want %v", result.AppliedFields, tt.wantApplied)
+			}
+		})
+	}
+}
+
+// TestApplyJSONPatchImmutableFields checks that only the fields a patch
+// changes are applied, so the immutable fields beside them don't get in the
+// way, while changing one still fails
+func TestApplyJSONPatchImmutableFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    func(station *patchedStation)
+		wantErr string
+	}{
+		{
+			name:  "nested beside an immutable field",
+			patch: `[{"op": "replace", "path": "/site/name", "value": "Harbor"}]`,
+			want:  func(station *patchedStation) { station.Site.Name = "Harbor" },
+		},
+		{
+			name:  "pointer beside an immutable field",
+			patch: `[{"op": "replace", "path": "/alias/name", "value": "Dock"}]`,
+			want:  func(station *patchedStation) { station.Alias.Name = "Dock" },
+		},
+		{
+			name:  "immutable field replaced with its own value",
+			patch: `[{"op": "replace", "path": "/code", "value": "TPA"}, {"op": "replace", "path": "/name", "value": "Tampa Bay"}]`,
+			want:  func(station *patchedStation) { station.Name = "Tampa Bay" },
+		},
+		{
+			name:    "immutable field",
+			patch:   `[{"op": "replace", "path": "/code", "value": "MIA"}]`,
+			wantErr: "'code' cannot be changed",
+		},
+		{
+			name:    "nested immutable field",
+			patch:   `[{"op": "replace", "path": "/site/code", "value": "MIA"}]`,
+			wantErr: "'site.code' cannot be changed",
+		},
+		{
+			name:    "embedded immutable field",
+			patch:   `[{"op": "replace", "path": "/createdBy", "value": "EUA9"}]`,
+			wantErr: "'createdBy' cannot be changed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newStation := func() patchedStation {
+				return patchedStation{
+					BaseStruct: NewBaseStruct("EUA0"),
+					Code:       "TPA",
+					Name:       "Tampa",
+					Site:       patchedSite{Code: "S1", Name: "Port"},
+					Alias:      &patchedSite{Code: "A1", Name: "Pier"},
+				}
+			}
+
+			station := newStation()
+			_, err := ApplyJSONPatch([]byte(tt.patch), "EUA1", &station)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyJSONPatch() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyJSONPatch() error = %v", err)
+			}
+
+			want := newStation()
+			tt.want(&want)
+			want.BaseStruct = station.BaseStruct
+			if !reflect.DeepEqual(station, want) {
+				t.Errorf("station = %+v, want %+v", station, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// checkKeyCase enforces exact key matching, unless WithCaseInsensitiveKeys is
+// given: keys that only match a field case-insensitively (including those of
+// nested structs) fail the apply with FieldErrors, or are dropped and returned
+// (sorted) when unknown keys are ignored
+func checkKeyCase(changes map[string]interface{}, structType reflect.Type, cfg *config, prefix string) ([]string, error) {
+	var mismatched []string
+	var fieldErrors FieldErrors
+
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, cfg.tagName, key)
+		if !ok {
+			continue
+		}
+
+		name := fieldKey(field, cfg.tagName)
+		if name != key {
+			if cfg.errorUnused {
+				fieldErrors = append(fieldErrors, &FieldError{
+					Path: prefix + key,
+					Err:  fmt.Errorf("no such field (did you mean '%s'?)", name),
+				})
+			} else {
+				delete(changes, key)
+				mismatched = append(mismatched, prefix+key)
+			}
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if nested, ok := value.(map[string]interface{}); ok && fieldType.Kind() == reflect.Struct && !isScalar(fieldType) {
+			nestedMismatched, err := checkKeyCase(nested, fieldType, cfg, prefix+key+".")
+			if nestedErrors, ok := err.(FieldErrors); ok {
+				fieldErrors = append(fieldErrors, nestedErrors...)
+			}
+			mismatched = append(mismatched, nestedMismatched...)
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		sort.Slice(fieldErrors, func(i, j int) bool {
+			return fieldErrors[i].Path < fieldErrors[j].Path
+		})
+		return nil, fieldErrors
+	}
+
+	sort.Strings(mismatched)
+	return mismatched, nil
+}
+
+// exactMatch makes the decoder match keys against tag names exactly
+func exactMatch(mapKey string, fieldName string) bool {
+	return mapKey == fieldName
+}
+
+// nearMiss suggests the tag name of the struct's field a key was probably meant
+// for: one matching it case-insensitively, or otherwise within two edits of it
+func nearMiss(structType reflect.Type, tagName string, key string) (string, bool) {
+	if field, ok := structFieldByTag(structType, tagName, key); ok {
+		name := fieldKey(field, tagName)
+		return name, name != key
+	}
+
+	best, bestDistance := "", 3
+	for _, field := range squashedFields(structType) {
+		name := fieldKey(field, tagName)
+		if name == "" || field.PkgPath != "" {
+			continue
+		}
+
+		if distance := editDistance(strings.ToLower(key), strings.ToLower(name)); distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+
+	return best, best != ""
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	smallest := values[0]
+	for _, value := range values[1:] {
+		if value < smallest {
+			smallest = value
+		}
+	}
+
+	return smallest
+}
@@ -0,0 +1,152 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestKeyCase(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		opts        []Option
+		want        nestedRecord
+		wantUnknown []string
+		wantErr     []string
+	}{
+		{
+			name:    "exact keys",
+			changes: map[string]interface{}{"name": "Tampa", "inline": map[string]interface{}{"level": 2}},
+			want:    nestedRecord{Name: "Tampa", Inline: nestedDetails{Level: 2}},
+		},
+		{
+			name:    "mismatched keys",
+			changes: map[string]interface{}{"Name": "Tampa", "inline": map[string]interface{}{"LEVEL": 2}},
+			wantErr: []string{
+				"'Name': no such field (did you mean 'name'?)",
+				"'inline.LEVEL': no such field (did you mean 'level'?)",
+			},
+		},
+		{
+			name:        "mismatched keys ignored",
+			changes:     map[string]interface{}{"Name": "Tampa", "count": 2, "inline": map[string]interface{}{"LEVEL": 2}},
+			opts:        []Option{WithIgnoreUnknownFields()},
+			want:        nestedRecord{Count: 2},
+			wantUnknown: []string{"Name", "inline.LEVEL"},
+		},
+		{
+			name:    "case-insensitive",
+			changes: map[string]interface{}{"Name": "Tampa", "inline": map[string]interface{}{"LEVEL": 2}},
+			opts:    []Option{WithCaseInsensitiveKeys()},
+			want:    nestedRecord{Name: "Tampa", Inline: nestedDetails{Level: 2}},
+		},
+		{
+			name:    "case-sensitive again",
+			changes: map[string]interface{}{"Name": "Tampa"},
+			opts:    []Option{WithCaseInsensitiveKeys(), WithCaseSensitiveKeys()},
+			wantErr: []string{"'Name': no such field (did you mean 'name'?)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := nestedRecord{}
+			result, err := ApplyChanges(tt.changes, &record, tt.opts...)
+			if tt.wantErr != nil {
+				var fieldErrors FieldErrors
+				if !errors.As(err, &fieldErrors) {
+					t.Fatalf("ApplyChanges() error = %v, want FieldErrors", err)
+				}
+
+				var got []string
+				for _, fieldError := range fieldErrors {
+					got = append(got, fieldError.Error())
+				}
+				if !reflect.DeepEqual(got, tt.wantErr) {
+					t.Errorf("FieldErrors = %q, want %q", got, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(record, tt.want) {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+			if !reflect.DeepEqual(result.UnknownFields, tt.wantUnknown) {
+				t.Errorf("UnknownFields = %v, want %v", result.UnknownFields, tt.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestPrincipalStampedByTagName(t *testing.T) {
+	ada := Principal{ID: "EUA1", Name: "Ada", Role: "admin"}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "case-sensitive keys"},
+		{name: "case-insensitive keys", opts: []Option{WithCaseInsensitiveKeys()}},
+		{name: "untagged for the tag name", opts: []Option{WithTagName("db")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := principalRecord{}
+			if _, err := ApplyChangesAs(map[string]interface{}{}, ada, &record, tt.opts...); err != nil {
+				t.Fatalf("ApplyChangesAs() error = %v", err)
+			}
+
+			if record.ModifiedByPrincipal != ada {
+				t.Errorf("ModifiedByPrincipal = %+v, want %+v", record.ModifiedByPrincipal, ada)
+			}
+		})
+	}
+}
+
+func TestNearMiss(t *testing.T) {
+	structType := reflect.TypeOf(nestedRecord{})
+
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "NAME", want: "name", wantOK: true},
+		{key: "nmae", want: "name", wantOK: true},
+		{key: "detials", want: "details", wantOK: true},
+		{key: "name", want: "name"},
+		{key: "elevation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := nearMiss(structType, "json", tt.key)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("nearMiss(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "abc", want: 3},
+		{a: "name", b: "name", want: 0},
+		{a: "nmae", b: "name", want: 2},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConflictingKeysError is returned when more than one key of the changes
+// addresses the same field once keys are matched case-insensitively (see
+// WithCaseInsensitiveKeys), by snake case (see WithSnakeCaseKeys) or by a
+// fallback tag (see WithFallbackTagNames), rather than applying whichever
+// happens to win
+type ConflictingKeysError struct {
+	// Path is the field's path, e.g. "weather" or "details.source"
+	Path string
+
+	// Keys are the keys that address it, sorted
+	Keys []string
+}
+
+func (e *ConflictingKeysError) Error() string {
+	return fmt.Sprintf("%s all change '%s'", quoteFields(e.Keys), e.Path)
+}
+
+// checkConflictingKeys fails with a ConflictingKeysError for the first field
+// (by path) that more than one key of the changes addresses, including the
+// fields of nested structs. With WithAllowIdenticalKeys, keys carrying the
+// same value are collapsed into one instead: the field's own key if present,
+// else the first in order.
+func checkConflictingKeys(changes map[string]interface{}, structType reflect.Type, cfg *config, prefix string) error {
+	if exactKeys(changes, structType, cfg.tagName) {
+		return nil
+	}
+
+	addressed := map[string][]string{}
+	fields := map[string]reflect.StructField{}
+	for key := range changes {
+		field, ok := addressedField(structType, cfg, key)
+		if !ok {
+			continue
+		}
+
+		name := fieldKey(field, cfg.tagName)
+		addressed[name] = append(addressed[name], key)
+		fields[name] = field
+	}
+
+	names := make([]string, 0, len(addressed))
+	for name := range addressed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		keys := addressed[name]
+		if len(keys) > 1 {
+			sort.Strings(keys)
+			if !cfg.allowIdenticalKeys || !identicalValues(changes, keys) {
+				return &ConflictingKeysError{Path: prefix + name, Keys: keys}
+			}
+
+			kept := keys[0]
+			if _, ok := changes[name]; ok {
+				kept = name
+			}
+			for _, key := range keys {
+				if key != kept {
+					delete(changes, key)
+				}
+			}
+			keys = []string{kept}
+		}
+
+		fieldType := fields[name].Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if nested, ok := changes[keys[0]].(map[string]interface{}); ok && fieldType.Kind() == reflect.Struct && !isScalar(fieldType) {
+			if err := checkConflictingKeys(nested, fieldType, cfg, prefix+name+"."); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addressedField resolves a key to the field it will be applied to, the way
+// the key matching options will
+func addressedField(structType reflect.Type, cfg *config, key string) (reflect.StructField, bool) {
+	field, ok := cfg.keyField(structType, cfg.tagName, key)
+	for _, fallback := range cfg.fallbackTagNames {
+		if ok {
+			break
+		}
+		field, ok = cfg.keyField(structType, fallback, key)
+	}
+
+	if !ok && cfg.snakeCaseKeys {
+		for _, candidate := range squashedFields(structType) {
+			if name := fieldKey(candidate, cfg.tagName); name != "" && sameWords(key, name) {
+				field, ok = candidate, true
+				break
+			}
+		}
+	}
+
+	return field, ok && fieldKey(field, cfg.tagName) != ""
+}
+
+// keyField finds the field a key matches under the tag: exactly, unless
+// WithCaseInsensitiveKeys is given
+func (cfg *config) keyField(structType reflect.Type, tagName string, key string) (reflect.StructField, bool) {
+	field, ok := structFieldByTag(structType, tagName, key)
+	if ok && cfg.caseSensitiveKeys && fieldKey(field, tagName) != key {
+		return reflect.StructField{}, false
+	}
+
+	return field, ok
+}
+
+// exactKeys reports whether every key is exactly the tag name of a field and
+// no value is a nested map, so no two keys can address the same field
+func exactKeys(changes map[string]interface{}, structType reflect.Type, tagName string) bool {
+	index := tagIndex(structType, tagName)
+	for key, value := range changes {
+		if _, ok := index.exact[key]; !ok {
+			return false
+		}
+		if _, ok := value.(map[string]interface{}); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func identicalValues(changes map[string]interface{}, keys []string) bool {
+	for _, key := range keys[1:] {
+		if !reflect.DeepEqual(changes[key], changes[keys[0]]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeKeyCase renames keys written in the other case convention (e.g.
+// "city_name" for a "cityName" tag, or "cityName" for a "city_name" one) to
+// the tag name of the field they address, including the keys of nested
+// structs. Keys that already match a field are left alone.
+func normalizeKeyCase(changes map[string]interface{}, structType reflect.Type, cfg *config) error {
+	tagName := cfg.tagName
+	fields := squashedFields(structType)
+
+	for key, value := range changes {
+		canonical := key
+		if _, ok := cfg.keyField(structType, tagName, key); !ok {
+			for _, field := range fields {
+				if name := fieldKey(field, tagName); name != "" && sameWords(key, name) {
+					canonical = name
+					break
+				}
+			}
+		}
+
+		if canonical != key {
+			if _, exists := changes[canonical]; exists {
+				return fmt.Errorf("'%s' and '%s' both change '%s'", key, canonical, canonical)
+			}
+			delete(changes, key)
+			changes[canonical] = value
+		}
+
+		field, ok := structFieldByTag(structType, tagName, canonical)
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if nested, ok := value.(map[string]interface{}); ok && fieldType.Kind() == reflect.Struct && !isScalar(fieldType) {
+			if err := normalizeKeyCase(nested, fieldType, cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameWords reports whether two names spell the same words, ignoring case and
+// underscores: "city_name", "cityName" and "CityName" are all the same
+func sameWords(a, b string) bool {
+	return strings.EqualFold(strings.ReplaceAll(a, "_", ""), strings.ReplaceAll(b, "_", ""))
+}
+
+// applyFallbackTags renames keys that only match a field by one of the
+// fallback tags to the field's key under the primary tag, including the keys
+// of nested structs
+func applyFallbackTags(changes map[string]interface{}, structType reflect.Type, cfg *config) error {
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, cfg.tagName, key)
+		for _, fallback := range cfg.fallbackTagNames {
+			if ok {
+				break
+			}
+			field, ok = cfg.keyField(structType, fallback, key)
+		}
+		if !ok {
+			continue
+		}
+
+		canonical := fieldKey(field, cfg.tagName)
+		if canonical == "" {
+			continue
+		}
+
+		if _, matchesPrimary := structFieldByTag(structType, cfg.tagName, key); !matchesPrimary {
+			if _, exists := changes[canonical]; exists {
+				return fmt.Errorf("'%s' and '%s' both change '%s'", key, canonical, canonical)
+			}
+			delete(changes, key)
+			changes[canonical] = value
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if nested, ok := value.(map[string]interface{}); ok && fieldType.Kind() == reflect.Struct && !isScalar(fieldType) {
+			if err := applyFallbackTags(nested, fieldType, cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type keyedDetails struct {
+	Source string `json:"source" db:"source_name"`
+}
+
+type keyedReport struct {
+	Weather  string        `json:"weather" db:"conditions"`
+	CityName string        `json:"cityName" db:"city"`
+	Details  *keyedDetails `json:"details"`
+}
+
+func TestConflictingKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  map[string]interface{}
+		opts     []Option
+		wantPath string
+		wantKeys []string
+		want     keyedReport
+	}{
+		{
+			name:     "three-way conflict",
+			changes:  map[string]interface{}{"weather": "A", "Weather": "B", "conditions": "C"},
+			opts:     []Option{WithFallbackTagNames("db"), WithCaseInsensitiveKeys()},
+			wantPath: "weather",
+			wantKeys: []string{"Weather", "conditions", "weather"},
+		},
+		{
+			name:     "snake case conflict",
+			changes:  map[string]interface{}{"cityName": "Tampa", "city_name": "Miami"},
+			opts:     []Option{WithSnakeCaseKeys()},
+			wantPath: "cityName",
+			wantKeys: []string{"cityName", "city_name"},
+		},
+		{
+			name:     "nested conflict",
+			changes:  map[string]interface{}{"details": map[string]interface{}{"source": "a", "SOURCE": "b"}},
+			opts:     []Option{WithCaseInsensitiveKeys()},
+			wantPath: "details.source",
+			wantKeys: []string{"SOURCE", "source"},
+		},
+		{
+			name:     "conflicting nested maps",
+			changes:  map[string]interface{}{"details": map[string]interface{}{"source": "a"}, "Details": map[string]interface{}{"source": "a"}},
+			opts:     []Option{WithCaseInsensitiveKeys()},
+			wantPath: "details",
+			wantKeys: []string{"Details", "details"},
+		},
+		{
+			name:    "identical values allowed",
+			changes: map[string]interface{}{"weather": "A", "Weather": "A", "conditions": "A"},
+			opts:    []Option{WithFallbackTagNames("db"), WithAllowIdenticalKeys(), WithCaseInsensitiveKeys()},
+			want:    keyedReport{Weather: "A"},
+		},
+		{
+			name:     "different values still conflict",
+			changes:  map[string]interface{}{"weather": "A", "Weather": "A", "conditions": "B"},
+			opts:     []Option{WithFallbackTagNames("db"), WithAllowIdenticalKeys(), WithCaseInsensitiveKeys()},
+			wantPath: "weather",
+			wantKeys: []string{"Weather", "conditions", "weather"},
+		},
+		{
+			name:    "identical fallback keys without the field's own",
+			changes: map[string]interface{}{"city": "Tampa", "City": "Tampa"},
+			opts:    []Option{WithFallbackTagNames("db"), WithAllowIdenticalKeys(), WithCaseInsensitiveKeys()},
+			want:    keyedReport{CityName: "Tampa"},
+		},
+		{
+			name:    "distinct paths",
+			changes: map[string]interface{}{"weather": "A", "city": "Tampa", "details": map[string]interface{}{"source_name": "radar"}},
+			opts:    []Option{WithFallbackTagNames("db")},
+			want:    keyedReport{Weather: "A", CityName: "Tampa", Details: &keyedDetails{Source: "radar"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := keyedReport{}
+			_, err := ApplyChanges(tt.changes, &report, tt.opts...)
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("ApplyChanges() error = %v", err)
+				}
+				if !reflect.DeepEqual(report, tt.want) {
+					t.Errorf("report = %+v, want %+v", report, tt.want)
+				}
+				return
+			}
+
+			var conflict *ConflictingKeysError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("ApplyChanges() error = %v, want a ConflictingKeysError", err)
+			}
+			if conflict.Path != tt.wantPath || !reflect.DeepEqual(conflict.Keys, tt.wantKeys) {
+				t.Errorf("ConflictingKeysError = %+v, want %s by %v", conflict, tt.wantPath, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestWithAllowConflictingKeys(t *testing.T) {
+	changes := func() map[string]interface{} {
+		return map[string]interface{}{"weather": "A", "Weather": "B"}
+	}
+
+	report := keyedReport{}
+	_, err := ApplyChanges(changes(), &report, WithCaseInsensitiveKeys(), WithAllowConflictingKeys())
+	var conflict *ConflictingKeysError
+	if err == nil || errors.As(err, &conflict) {
+		t.Fatalf("ApplyChanges() error = %v, want the inexact key left to the decoder as unknown", err)
+	}
+
+	report = keyedReport{}
+	if _, err := ApplyChanges(changes(), &report, WithCaseInsensitiveKeys(), WithAllowConflictingKeys(), WithIgnoreUnknownFields()); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if report.Weather != "A" {
+		t.Errorf("Weather = %q, want the exact key's value", report.Weather)
+	}
+}
+
+func TestFallbackAndSnakeCaseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+		want    keyedReport
+	}{
+		{
+			name:    "fallback tag",
+			changes: map[string]interface{}{"conditions": "rain", "details": map[string]interface{}{"source_name": "radar"}},
+			opts:    []Option{WithFallbackTagNames("db")},
+			want:    keyedReport{Weather: "rain", Details: &keyedDetails{Source: "radar"}},
+		},
+		{
+			name:    "snake case",
+			changes: map[string]interface{}{"city_name": "Tampa"},
+			opts:    []Option{WithSnakeCaseKeys()},
+			want:    keyedReport{CityName: "Tampa"},
+		},
+		{
+			name:    "case-insensitive",
+			changes: map[string]interface{}{"WEATHER": "rain", "CITY": "Tampa"},
+			opts:    []Option{WithFallbackTagNames("db"), WithCaseInsensitiveKeys()},
+			want:    keyedReport{Weather: "rain", CityName: "Tampa"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := keyedReport{}
+			if _, err := ApplyChanges(tt.changes, &report, tt.opts...); err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+			if !reflect.DeepEqual(report, tt.want) {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSnakeCaseKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		opts        []Option
+		want        keyedReport
+		wantApplied []string
+		wantErr     bool
+	}{
+		{
+			name:        "snake case for a camel case tag",
+			changes:     map[string]interface{}{"city_name": "Tampa"},
+			want:        keyedReport{CityName: "Tampa"},
+			wantApplied: []string{"cityName"},
+		},
+		{
+			name:        "pascal case",
+			changes:     map[string]interface{}{"CityName": "Tampa"},
+			want:        keyedReport{CityName: "Tampa"},
+			wantApplied: []string{"cityName"},
+		},
+		{
+			name:        "camel case for a snake case tag",
+			changes:     map[string]interface{}{"Details": map[string]interface{}{"sourceName": "radar"}},
+			opts:        []Option{WithTagName("db")},
+			want:        keyedReport{Details: &keyedDetails{Source: "radar"}},
+			wantApplied: []string{"Details"},
+		},
+		{
+			name:        "nested keys",
+			changes:     map[string]interface{}{"details": map[string]interface{}{"SOURCE": "radar"}, "city_name": "Tampa"},
+			want:        keyedReport{CityName: "Tampa", Details: &keyedDetails{Source: "radar"}},
+			wantApplied: []string{"cityName", "details"},
+		},
+		{
+			name:    "both spellings",
+			changes: map[string]interface{}{"city_name": "Tampa", "cityName": "Miami"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown words",
+			changes: map[string]interface{}{"city_names": "Tampa"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := keyedReport{}
+			result, err := ApplyChanges(tt.changes, &report, append([]Option{WithSnakeCaseKeys()}, tt.opts...)...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(report, tt.want) {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+			if !reflect.DeepEqual(result.AppliedFields, tt.wantApplied) {
+				t.Errorf("AppliedFields = %v, want %v", result.AppliedFields, tt.wantApplied)
+			}
+		})
+	}
+
+	if _, err := ApplyChanges(map[string]interface{}{"city_name": "Tampa"}, &keyedReport{}); err == nil {
+		t.Error("ApplyChanges() error = nil, want snake case keys unknown without WithSnakeCaseKeys")
+	}
+}
+
+func TestSameWords(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "city_name", b: "cityName", want: true},
+		{a: "CityName", b: "cityName", want: true},
+		{a: "_city__name_", b: "CITYNAME", want: true},
+		{a: "city_names", b: "cityName"},
+		{a: "city", b: "cityName"},
+	}
+
+	for _, tt := range tests {
+		if got := sameWords(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameWords(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// fallbackReport is tagged for three key conventions
+type fallbackReport struct {
+	Weather string `json:"weather" db:"conditions" graphql:"sky"`
+	Station string `json:"station" db:"station_id"`
+	Notes   string `json:"notes" db:"-" graphql:"remarks"`
+}
+
+func TestWithFallbackTagNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		fallbacks   []string
+		want        fallbackReport
+		wantApplied []string
+		wantErr     bool
+	}{
+		{
+			name:        "primary tag",
+			changes:     map[string]interface{}{"weather": "rain"},
+			fallbacks:   []string{"db"},
+			want:        fallbackReport{Weather: "rain"},
+			wantApplied: []string{"weather"},
+		},
+		{
+			name:        "fallback tag reported by its primary key",
+			changes:     map[string]interface{}{"conditions": "rain", "station_id": "KTPA"},
+			fallbacks:   []string{"db"},
+			want:        fallbackReport{Weather: "rain", Station: "KTPA"},
+			wantApplied: []string{"station", "weather"},
+		},
+		{
+			name:        "several fallbacks",
+			changes:     map[string]interface{}{"sky": "clear", "station_id": "KTPA", "remarks": "calm"},
+			fallbacks:   []string{"db", "graphql"},
+			want:        fallbackReport{Weather: "clear", Station: "KTPA", Notes: "calm"},
+			wantApplied: []string{"notes", "station", "weather"},
+		},
+		{
+			name:      "unlisted tag",
+			changes:   map[string]interface{}{"sky": "clear"},
+			fallbacks: []string{"db"},
+			wantErr:   true,
+		},
+		{
+			name:      "primary and fallback for one field",
+			changes:   map[string]interface{}{"weather": "rain", "conditions": "fog"},
+			fallbacks: []string{"db"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := fallbackReport{}
+			result, err := ApplyChanges(tt.changes, &report, WithFallbackTagNames(tt.fallbacks...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if report != tt.want {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+			if !reflect.DeepEqual(result.AppliedFields, tt.wantApplied) {
+				t.Errorf("AppliedFields = %v, want %v", result.AppliedFields, tt.wantApplied)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package applychanges
+
+// LegacyOptions are the options that make ApplyChangesWrapper behave like the
+// original two-function surface (now the legacy package), switching off every
+// check and safeguard added since:
+//   - no modifiedDts is stamped, so only modifiedBy is written into the changes
+//   - keys are matched case-insensitively, as mapstructure does, and keys
+//     differing only in case are left for the decoder to reject
+//   - values that aren't valid UTF-8, NaN and ±Inf are stored as they are
+//   - fields tagged apply:"immutable", such as BaseStruct's ID and creation
+//     fields, can be changed
+//   - a failed apply leaves whatever was decoded before the failure on the
+//     target, rather than putting it back
+//
+// The one deliberate difference left is that strings parse into uuid.UUID,
+// decimal.Decimal and time.Duration fields, and registered scalars, where they
+// used to fail.
+func LegacyOptions() []Option {
+	return []Option{
+		WithModifiedDts(false),
+		WithCaseInsensitiveKeys(),
+		WithAllowConflictingKeys(),
+		WithAllowInvalidUTF8(),
+		WithAllowNonFiniteFloats(),
+		WithIgnoreImmutableTags(),
+		WithRollback(false),
+	}
+}
@@ -0,0 +1,41 @@
+// Package legacy keeps the original two-function surface, ApplyChanges and
+// ApplyChangesWrapper returning a single error, for callers that haven't moved
+// to the applychanges package yet. Both run the current pipeline with
+// applychanges.LegacyOptions, so they keep today's semantics: the changes map
+// is sanitized in place and gets the modifier written into it, no modifiedDts
+// is stamped, and errors are opaque.
+//
+// Moving off it is a matter of calling applychanges.ApplyChangesWrapper with
+// applychanges.LegacyOptions() and dropping options from the bundle as the
+// call site is ready for them.
+package legacy
+
+import (
+	"errors"
+
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+)
+
+// ApplyChanges applies the changes to the target, which must be a pointer to a
+// struct, sanitizing the changes map in place first
+func ApplyChanges(changes map[string]interface{}, to interface{}) error {
+	_, err := applychanges.ApplyChanges(changes, to, applychanges.LegacyOptions()...)
+	return opaque(err)
+}
+
+// ApplyChangesWrapper applies the changes like ApplyChanges, after writing the
+// modifier into the changes map as modifiedBy
+func ApplyChangesWrapper(changes map[string]interface{}, modifier string, to interface{}) error {
+	_, err := applychanges.ApplyChangesWrapper(changes, modifier, to, applychanges.LegacyOptions()...)
+	return opaque(err)
+}
+
+// opaque hides the typed errors of the current pipeline, which callers of the
+// legacy functions never had to handle, keeping only the message
+func opaque(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return errors.New(err.Error())
+}
@@ -0,0 +1,386 @@
+package legacy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	applychanges "github.com/DylanSpOddball/apply-changes-wrapper"
+	"github.com/google/uuid"
+	"github.com/mitchellh/mapstructure"
+)
+
+// oldSanitizeChanges and oldApplyChanges are copied verbatim from the original
+// main.go (only renamed), as the reference the legacy functions are compared
+// against
+func oldSanitizeChanges(changes map[string]interface{}) {
+	for key, value := range changes {
+		// Get the reflect value for type comparisons
+		reflectValue := reflect.ValueOf(value)
+
+		// String operations
+		if reflectValue.Kind() == reflect.String {
+			valAsString, ok := reflectValue.Interface().(string)
+
+			// Convert empty strings to `nil`
+			if ok && len(valAsString) == 0 {
+				changes[key] = nil
+				continue
+			}
+		}
+
+		// Empty slices don't play well with mapstructure, as they enter as []interface{}
+		// which promptly gets ignored by mapstructure.
+		// In order to get around this, we'll convert empty slices to a real "nil" value
+		if reflectValue.Kind() == reflect.Slice && reflectValue.IsNil() {
+			changes[key] = nil
+		}
+	}
+}
+
+func oldApplyChanges(changes map[string]interface{}, to interface{}) error {
+	oldSanitizeChanges(changes)
+
+	// Set up the decoder. This is almost exactly ripped from https://gqlgen.com/reference/changesets/
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused: true,
+		TagName:     "json",
+		Result:      to,
+		ZeroFields:  true,
+		Squash:      true,
+		// This is needed to get mapstructure to call the gqlgen unmarshaler func for custom scalars (eg Date)
+		DecodeHook: func(a reflect.Type, b reflect.Type, v interface{}) (interface{}, error) {
+			// If the destination is a time.Time and we need to parse it from a string
+			if b == reflect.TypeOf(time.Time{}) && a == reflect.TypeOf("") {
+				t, err := time.Parse(time.RFC3339Nano, v.(string))
+				return t, err
+			}
+
+			// If the desination implements graphql.Unmarshaler
+			if reflect.PtrTo(b).Implements(reflect.TypeOf((*graphql.Unmarshaler)(nil)).Elem()) {
+				resultType := reflect.New(b)
+				result := resultType.MethodByName("UnmarshalGQL").Call([]reflect.Value{reflect.ValueOf(v)})
+				err, _ := result[0].Interface().(error)
+				return resultType.Elem().Interface(), err
+			}
+
+			return v, nil
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return dec.Decode(changes)
+}
+
+func oldApplyChangesWrapper(changes map[string]interface{}, modifier string, to interface{}) error {
+	changesWithModifier := changes
+	changesWithModifier["modifiedBy"] = modifier
+	return oldApplyChanges(changesWithModifier, to)
+}
+
+type baseStruct struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	CreatedBy   string     `json:"createdBy" db:"created_by"`
+	CreatedDts  time.Time  `json:"createdDts" db:"created_dts"`
+	ModifiedBy  *string    `json:"modifiedBy" db:"modified_by"`
+	ModifiedDts *time.Time `json:"modifiedDts" db:"modified_dts"`
+}
+
+// celsius is a custom scalar, written as a string like "21.5C"
+type celsius float64
+
+func (c *celsius) UnmarshalGQL(v interface{}) error {
+	s, ok := v.(string)
+	if !ok || len(s) < 2 || s[len(s)-1] != 'C' {
+		return fmt.Errorf("not a temperature: %v", v)
+	}
+
+	degrees, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	*c = celsius(degrees)
+	return err
+}
+
+func (c celsius) MarshalGQL(w io.Writer) {
+	fmt.Fprintf(w, "%q", strconv.FormatFloat(float64(c), 'f', -1, 64)+"C")
+}
+
+type station struct {
+	Name      string `json:"name"`
+	Elevation int    `json:"elevation"`
+}
+
+type weatherReport struct {
+	baseStruct
+	City        string    `json:"city"`
+	Weather     string    `json:"weather"`
+	Forecaster  *string   `json:"forecaster"`
+	Temperature celsius   `json:"temperature"`
+	ObservedAt  time.Time `json:"observedAt"`
+	Humidity    float64   `json:"humidity"`
+	Alerts      []string  `json:"alerts"`
+	Station     station   `json:"station"`
+}
+
+// cityReport embeds the package's own BaseStruct
+type cityReport struct {
+	applychanges.BaseStruct
+	City string `json:"city"`
+}
+
+func newCityReport() cityReport {
+	return cityReport{
+		BaseStruct: applychanges.BaseStruct{
+			ID:         uuid.MustParse("4f1b6e2a-6f0e-4b8e-9a4c-3c5d2e1f0a9b"),
+			CreatedBy:  "Dylan",
+			CreatedDts: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+		City: "Clearwater",
+	}
+}
+
+// noteOnly has no modifiedBy field for the modifier to go into
+type noteOnly struct {
+	Note string `json:"note"`
+}
+
+func newWeatherReport() weatherReport {
+	forecaster := "Dylan"
+	return weatherReport{
+		baseStruct: baseStruct{
+			ID:         uuid.MustParse("4f1b6e2a-6f0e-4b8e-9a4c-3c5d2e1f0a9b"),
+			CreatedBy:  "Dylan",
+			CreatedDts: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+		City:        "Clearwater",
+		Weather:     "Hot and sunny",
+		Forecaster:  &forecaster,
+		Temperature: 31,
+		Humidity:    0.7,
+		Alerts:      []string{"heat"},
+		Station:     station{Name: "KPIE", Elevation: 3},
+	}
+}
+
+// corpus are changesets as the legacy callers send them
+var corpus = []struct {
+	name    string
+	changes map[string]interface{}
+}{
+	{name: "no changes", changes: map[string]interface{}{}},
+	{name: "one field", changes: map[string]interface{}{"weather": "Thunderstorms"}},
+	{name: "several fields", changes: map[string]interface{}{"city": "Tampa", "weather": "Rain", "humidity": 0.95}},
+	{name: "key in another case", changes: map[string]interface{}{"Weather": "Fog"}},
+	{name: "empty string", changes: map[string]interface{}{"weather": ""}},
+	{name: "empty string into a pointer", changes: map[string]interface{}{"forecaster": ""}},
+	{name: "null into a pointer", changes: map[string]interface{}{"forecaster": nil}},
+	{name: "string into a pointer", changes: map[string]interface{}{"forecaster": "Mr. Weatherdude"}},
+	{name: "null into a string", changes: map[string]interface{}{"city": nil}},
+	{name: "int into a float", changes: map[string]interface{}{"humidity": 1}},
+	{name: "float into an int", changes: map[string]interface{}{"station": map[string]interface{}{"elevation": 4.5}}},
+	{name: "nested struct", changes: map[string]interface{}{"station": map[string]interface{}{"name": "KTPA", "elevation": 7}}},
+	{name: "part of a nested struct", changes: map[string]interface{}{"station": map[string]interface{}{"name": "KTPA"}}},
+	{name: "slice", changes: map[string]interface{}{"alerts": []interface{}{"flood", "wind"}}},
+	{name: "typed slice", changes: map[string]interface{}{"alerts": []string{"flood"}}},
+	{name: "nil slice", changes: map[string]interface{}{"alerts": []string(nil)}},
+	{name: "empty slice", changes: map[string]interface{}{"alerts": []interface{}{}}},
+	{name: "time", changes: map[string]interface{}{"observedAt": "2022-06-01T13:30:00.5Z"}},
+	{name: "time with an offset", changes: map[string]interface{}{"observedAt": "2022-06-01T09:30:00-04:00"}},
+	{name: "invalid time", changes: map[string]interface{}{"observedAt": "yesterday"}},
+	{name: "time.Time value", changes: map[string]interface{}{"observedAt": time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC)}},
+	{name: "custom scalar", changes: map[string]interface{}{"temperature": "25.5C"}},
+	{name: "invalid custom scalar", changes: map[string]interface{}{"temperature": "hot"}},
+	{name: "metadata", changes: map[string]interface{}{"createdBy": "Someone else"}},
+	{name: "modifiedBy supplied", changes: map[string]interface{}{"modifiedBy": "Impostor", "weather": "Hail"}},
+	{name: "modifiedDts supplied", changes: map[string]interface{}{"modifiedDts": "2022-06-01T13:30:00Z"}},
+	{name: "unknown field", changes: map[string]interface{}{"weatherr": "Snow"}},
+	{name: "unknown nested field", changes: map[string]interface{}{"station": map[string]interface{}{"altitude": 4}}},
+	{name: "wrong type", changes: map[string]interface{}{"city": 7}},
+	{name: "wrong nested type", changes: map[string]interface{}{"station": map[string]interface{}{"elevation": "high"}}},
+	{name: "not a map for a struct", changes: map[string]interface{}{"station": "KTPA"}},
+	{name: "NaN", changes: map[string]interface{}{"humidity": math.NaN()}},
+	{name: "infinity", changes: map[string]interface{}{"humidity": math.Inf(1)}},
+	{name: "invalid UTF-8", changes: map[string]interface{}{"city": "Tam\xffpa"}},
+	{name: "keys differing only in case", changes: map[string]interface{}{"city": "Tampa", "City": "Miami"}},
+	{name: "failing after other fields", changes: map[string]interface{}{"city": "Tampa", "station": map[string]interface{}{"elevation": "high"}}},
+}
+
+// baseCorpus are changesets to the metadata of a type embedding
+// applychanges.BaseStruct, whose creation fields are tagged immutable
+var baseCorpus = []struct {
+	name    string
+	changes map[string]interface{}
+}{
+	{name: "content", changes: map[string]interface{}{"city": "Tampa"}},
+	{name: "createdBy", changes: map[string]interface{}{"createdBy": "Someone else"}},
+	{name: "id", changes: map[string]interface{}{"id": uuid.MustParse("0b7e4f3c-0d4a-4c1e-8f6b-2a9d5e7c1b3a")}},
+	{name: "createdDts", changes: map[string]interface{}{"createdDts": "2020-01-01T00:00:00Z"}},
+	{name: "createdBy alongside content", changes: map[string]interface{}{"createdBy": "", "city": "Tampa"}},
+}
+
+// copyChanges copies the top level of the changes, which is all either side
+// mutates
+func copyChanges(changes map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(changes))
+	for key, value := range changes {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+func TestApplyChangesWrapperMatchesOld(t *testing.T) {
+	for _, tt := range corpus {
+		t.Run(tt.name, func(t *testing.T) {
+			oldChanges, newChanges := copyChanges(tt.changes), copyChanges(tt.changes)
+			oldReport, newReport := newWeatherReport(), newWeatherReport()
+
+			oldErr := oldApplyChangesWrapper(oldChanges, "Mr. Weatherdude", &oldReport)
+			newErr := ApplyChangesWrapper(newChanges, "Mr. Weatherdude", &newReport)
+			if (oldErr == nil) != (newErr == nil) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, the original's = %v", newErr, oldErr)
+			}
+			if !sameChanges(newChanges, oldChanges) {
+				t.Errorf("changes = %v, the original's = %v", newChanges, oldChanges)
+			}
+			if !sameReport(newReport, oldReport) {
+				t.Errorf("target = %+v, the original's = %+v", newReport, oldReport)
+			}
+		})
+	}
+}
+
+func TestApplyChangesMatchesOld(t *testing.T) {
+	for _, tt := range corpus {
+		t.Run(tt.name, func(t *testing.T) {
+			oldChanges, newChanges := copyChanges(tt.changes), copyChanges(tt.changes)
+			oldReport, newReport := newWeatherReport(), newWeatherReport()
+
+			oldErr := oldApplyChanges(oldChanges, &oldReport)
+			newErr := ApplyChanges(newChanges, &newReport)
+			if (oldErr == nil) != (newErr == nil) {
+				t.Fatalf("ApplyChanges() error = %v, the original's = %v", newErr, oldErr)
+			}
+			if !sameChanges(newChanges, oldChanges) {
+				t.Errorf("changes = %v, the original's = %v", newChanges, oldChanges)
+			}
+			if !sameReport(newReport, oldReport) {
+				t.Errorf("target = %+v, the original's = %+v", newReport, oldReport)
+			}
+		})
+	}
+}
+
+// sameChanges is reflect.DeepEqual, except that a NaN value equals another
+func sameChanges(a, b map[string]interface{}) bool {
+	a, b = copyChanges(a), copyChanges(b)
+	for key, value := range a {
+		x, xok := value.(float64)
+		y, yok := b[key].(float64)
+		if xok && yok && math.IsNaN(x) && math.IsNaN(y) {
+			a[key], b[key] = nil, nil
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// sameReport is reflect.DeepEqual, except that a NaN humidity equals another
+func sameReport(a, b weatherReport) bool {
+	if math.IsNaN(a.Humidity) && math.IsNaN(b.Humidity) {
+		a.Humidity, b.Humidity = 0, 0
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func TestBaseStructMatchesOld(t *testing.T) {
+	for _, tt := range baseCorpus {
+		t.Run(tt.name, func(t *testing.T) {
+			oldChanges, newChanges := copyChanges(tt.changes), copyChanges(tt.changes)
+			oldReport, newReport := newCityReport(), newCityReport()
+
+			oldErr := oldApplyChangesWrapper(oldChanges, "Mr. Weatherdude", &oldReport)
+			newErr := ApplyChangesWrapper(newChanges, "Mr. Weatherdude", &newReport)
+			if (oldErr == nil) != (newErr == nil) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, the original's = %v", newErr, oldErr)
+			}
+			if !reflect.DeepEqual(newChanges, oldChanges) {
+				t.Errorf("changes = %v, the original's = %v", newChanges, oldChanges)
+			}
+			if !reflect.DeepEqual(newReport, oldReport) {
+				t.Errorf("target = %+v, the original's = %+v", newReport, oldReport)
+			}
+		})
+	}
+}
+
+func TestApplyChangesWrapperWithoutModifiedBy(t *testing.T) {
+	oldChanges, newChanges := map[string]interface{}{"note": "hi"}, map[string]interface{}{"note": "hi"}
+	var oldNote, newNote noteOnly
+
+	oldErr := oldApplyChangesWrapper(oldChanges, "Mr. Weatherdude", &oldNote)
+	newErr := ApplyChangesWrapper(newChanges, "Mr. Weatherdude", &newNote)
+	if (oldErr == nil) != (newErr == nil) {
+		t.Fatalf("ApplyChangesWrapper() error = %v, the original's = %v", newErr, oldErr)
+	}
+	if !reflect.DeepEqual(newChanges, oldChanges) {
+		t.Errorf("changes = %v, the original's = %v", newChanges, oldChanges)
+	}
+}
+
+func TestErrorsAreOpaque(t *testing.T) {
+	report := newWeatherReport()
+	err := ApplyChanges(map[string]interface{}{"weatherr": "Snow"}, &report)
+	if err == nil {
+		t.Fatal("ApplyChanges() error = nil, want the unknown field reported")
+	}
+	if reflect.TypeOf(err) != reflect.TypeOf(errors.New("")) {
+		t.Errorf("ApplyChanges() error is a %T, want an opaque error", err)
+	}
+}
+
+// TestDifferencesFromOld pins the deliberate difference listed on
+// applychanges.LegacyOptions
+func TestDifferencesFromOld(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    func(report *weatherReport)
+	}{
+		{
+			name:    "strings parse into uuid.UUID",
+			changes: map[string]interface{}{"id": "0b7e4f3c-0d4a-4c1e-8f6b-2a9d5e7c1b3a"},
+			want: func(report *weatherReport) {
+				report.ID = uuid.MustParse("0b7e4f3c-0d4a-4c1e-8f6b-2a9d5e7c1b3a")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := oldApplyChanges(copyChanges(tt.changes), &weatherReport{}); err == nil {
+				t.Fatal("the original's error = nil, want it to fail")
+			}
+
+			report := newWeatherReport()
+			if err := ApplyChanges(copyChanges(tt.changes), &report); err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			want := newWeatherReport()
+			tt.want(&want)
+			if !reflect.DeepEqual(report, want) {
+				t.Errorf("target = %+v, want %+v", report, want)
+			}
+		})
+	}
+}
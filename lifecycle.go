@@ -0,0 +1,57 @@
+package applychanges
+
+import "context"
+
+// BeforeApplier can be implemented by a target to normalize the changes before
+// they are decoded onto it. It is called after sanitization (including its
+// ChangeSanitizer) and the allowed, denied and immutable field checks, but
+// before metadata is stamped; returning an error aborts the apply.
+type BeforeApplier interface {
+	BeforeApply(changes map[string]interface{}) error
+}
+
+// AfterApplier can be implemented by a target to recompute derived fields once
+// the changes have been decoded onto it. It is called with the applied
+// changes before any Validator runs; returning an error fails the apply, which
+// puts the whole target back the way it was, including any field AfterApply
+// derived.
+type AfterApplier interface {
+	AfterApply(diff []FieldChange) error
+}
+
+// BeforeApplierContext is BeforeApplier for targets that need the apply's
+// context (see WithContext), e.g. to look something up; it is called instead of
+// BeforeApply when a target implements both
+type BeforeApplierContext interface {
+	BeforeApplyContext(ctx context.Context, changes map[string]interface{}) error
+}
+
+// AfterApplierContext is AfterApplier for targets that need the apply's
+// context; it is called instead of AfterApply when a target implements both
+type AfterApplierContext interface {
+	AfterApplyContext(ctx context.Context, diff []FieldChange) error
+}
+
+func beforeApply(ctx context.Context, changes map[string]interface{}, to interface{}) error {
+	if applier, ok := to.(BeforeApplierContext); ok {
+		return applier.BeforeApplyContext(ctx, changes)
+	}
+
+	if applier, ok := to.(BeforeApplier); ok {
+		return applier.BeforeApply(changes)
+	}
+
+	return nil
+}
+
+func afterApply(ctx context.Context, diff []FieldChange, to interface{}) error {
+	if applier, ok := to.(AfterApplierContext); ok {
+		return applier.AfterApplyContext(ctx, diff)
+	}
+
+	if applier, ok := to.(AfterApplier); ok {
+		return applier.AfterApply(diff)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// hookedRecord normalizes its name before an apply and derives its label after
+type hookedRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Label string `json:"-"`
+
+	diff []FieldChange
+}
+
+func (r *hookedRecord) BeforeApply(changes map[string]interface{}) error {
+	if name, ok := changes["name"].(string); ok {
+		if name == "reject" {
+			return errors.New("rejected name")
+		}
+		changes["name"] = strings.ToUpper(name)
+	}
+
+	return nil
+}
+
+func (r *hookedRecord) AfterApply(diff []FieldChange) error {
+	if r.Count < 0 {
+		return errors.New("negative count")
+	}
+
+	r.diff = diff
+	r.Label = fmt.Sprintf("%s x%d", r.Name, r.Count)
+	return nil
+}
+
+// contextHookedRecord implements both kinds of hooks, recording which ran
+type contextHookedRecord struct {
+	Name string `json:"name"`
+
+	calls []string
+}
+
+func (r *contextHookedRecord) BeforeApply(map[string]interface{}) error {
+	r.calls = append(r.calls, "BeforeApply")
+	return nil
+}
+
+func (r *contextHookedRecord) BeforeApplyContext(ctx context.Context, _ map[string]interface{}) error {
+	r.calls = append(r.calls, fmt.Sprint("BeforeApplyContext ", ctx.Value(validationKey{})))
+	return nil
+}
+
+func (r *contextHookedRecord) AfterApply([]FieldChange) error {
+	r.calls = append(r.calls, "AfterApply")
+	return nil
+}
+
+func (r *contextHookedRecord) AfterApplyContext(ctx context.Context, _ []FieldChange) error {
+	r.calls = append(r.calls, fmt.Sprint("AfterApplyContext ", ctx.Value(validationKey{})))
+	return nil
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    hookedRecord
+		wantErr bool
+	}{
+		{
+			name:    "normalized and derived",
+			changes: map[string]interface{}{"name": "tampa", "count": 2},
+			want:    hookedRecord{Name: "TAMPA", Count: 2, Label: "TAMPA x2"},
+		},
+		{
+			name:    "derived without the normalized field",
+			changes: map[string]interface{}{"count": 3},
+			want:    hookedRecord{Name: "MIAMI", Count: 3, Label: "MIAMI x3"},
+		},
+		{
+			name:    "BeforeApply fails",
+			changes: map[string]interface{}{"name": "reject", "count": 2},
+			want:    hookedRecord{Name: "MIAMI", Count: 1, Label: "MIAMI x1"},
+			wantErr: true,
+		},
+		{
+			name:    "AfterApply fails",
+			changes: map[string]interface{}{"name": "tampa", "count": -1},
+			want:    hookedRecord{Name: "MIAMI", Count: 1, Label: "MIAMI x1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := hookedRecord{Name: "MIAMI", Count: 1, Label: "MIAMI x1"}
+			if _, err := ApplyChanges(tt.changes, &record); (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if record.Name != tt.want.Name || record.Count != tt.want.Count || record.Label != tt.want.Label {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+			if !tt.wantErr && len(record.diff) != len(tt.changes) {
+				t.Errorf("AfterApply diff = %+v, want the %d applied changes", record.diff, len(tt.changes))
+			}
+		})
+	}
+}
+
+func TestLifecycleHooksRunBeforeValidator(t *testing.T) {
+	var label string
+	validator := ValidatorFunc(func(target interface{}) error {
+		label = target.(*hookedRecord).Label
+		return nil
+	})
+
+	record := hookedRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"name": "tampa", "count": 2}, &record, WithValidator(validator)); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if label != "TAMPA x2" {
+		t.Errorf("validator saw label %q, want the derived one", label)
+	}
+}
+
+func TestLifecycleHooksContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), validationKey{}, "request")
+
+	record := contextHookedRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"name": "Tampa"}, &record, WithContext(ctx)); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	want := []string{"BeforeApplyContext request", "AfterApplyContext request"}
+	if strings.Join(record.calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("calls = %v, want %v", record.calls, want)
+	}
+}
@@ -0,0 +1,157 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// LintSeverity says whether a LintIssue would make applying the changes fail
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// LintIssue is a single problem LintChanges found with one key of a changeset
+type LintIssue struct {
+	Field    string       `json:"field"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+}
+
+// deprecatedOption is the apply tag option marking a field clients should
+// stop sending, e.g. `apply:"deprecated"`
+const deprecatedOption = "deprecated"
+
+// LintChanges checks a changeset against a target type without needing an
+// instance of it: every key must match a field, and every value must survive
+// sanitization and the decode hooks for that field's type (along with the
+// immutable, enum and permission checks that don't depend on the target's
+// state). Near misses are flagged too: keys that only match a field
+// case-insensitively are errors (or warnings with WithCaseInsensitiveKeys),
+// keys for `apply:"deprecated"` fields are warnings, and unknown keys suggest
+// the field they were probably meant for.
+// Issues are returned sorted by field; a clean changeset returns none.
+//
+// Nothing but the checks runs: the changes are applied to throwaway instances
+// as a dry run, without lifecycle hooks, authorizers, validators, conditions,
+// audit sinks, event publishers, history or encryption. The caller's changes
+// are not modified.
+func LintChanges(targetType reflect.Type, changes map[string]interface{}, opts ...Option) []LintIssue {
+	cfg := lintConfig(configFor(targetType, opts))
+
+	for targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+
+	if targetType.Kind() != reflect.Struct {
+		return []LintIssue{{
+			Severity: LintError,
+			Message:  fmt.Sprintf("changes can only be applied to structs, not %s", targetType),
+		}}
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var issues []LintIssue
+	for _, key := range keys {
+		target := reflect.New(targetType)
+		field, ok := structFieldByTag(targetType, cfg.tagName, key)
+		if !ok {
+			message := fmt.Sprintf("%s has no field '%s'", targetType.Name(), key)
+			if suggestion, ok := nearMiss(targetType, cfg.tagName, key); ok {
+				message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+			}
+
+			issues = append(issues, LintIssue{
+				Field:    key,
+				Severity: LintError,
+				Message:  message,
+			})
+			continue
+		}
+
+		if name := fieldKey(field, cfg.tagName); name != key && !cfg.caseSensitiveKeys {
+			issues = append(issues, LintIssue{
+				Field:    key,
+				Severity: LintWarning,
+				Message:  fmt.Sprintf("'%s' only matches '%s' case-insensitively", key, name),
+			})
+		}
+
+		authorizePaths(map[string]interface{}{key: changes[key]}, targetType, cfg.tagName, "", func(path string, field *reflect.StructField, _ bool) {
+			if field != nil && hasApplyOption(*field, deprecatedOption) {
+				issues = append(issues, LintIssue{
+					Field:    path,
+					Severity: LintWarning,
+					Message:  fmt.Sprintf("'%s' is deprecated", path),
+				})
+			}
+		})
+
+		// Decode each key on its own into a throwaway instance, so one bad
+		// value doesn't hide the others
+		single := copyChanges(map[string]interface{}{key: changes[key]})
+		if _, err := applyChanges(single, target.Interface(), cfg); err != nil {
+			issues = append(issues, LintIssue{
+				Field:    key,
+				Severity: LintError,
+				Message:  lintMessage(err),
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Field < issues[j].Field
+	})
+
+	return issues
+}
+
+// lintConfig strips everything but the checks from the options: the changes
+// are applied as a dry run, to a throwaway instance, by no one in particular
+func lintConfig(cfg *config) *config {
+	cfg.dryRun = true
+	cfg.linting = true
+	cfg.modifier, cfg.principal, cfg.impersonation = nil, nil, nil
+
+	cfg.policy = nil
+	cfg.fieldLocks, cfg.fieldLocker = nil, nil
+	cfg.fieldAuthorizer = nil
+	cfg.valueAuthorizers = nil
+	cfg.deprecationHandler = nil
+	cfg.validator = nil
+	cfg.conditions = nil
+	cfg.expectedVersion, cfg.expectedETag = nil, nil
+	cfg.auditSink, cfg.eventPublisher, cfg.history = nil, nil, nil
+	cfg.idempotencyKey, cfg.contentDerivedKey = "", false
+	cfg.messageCatalog = nil
+
+	return cfg
+}
+
+// lintMessage flattens a multi-field error into one line
+func lintMessage(err error) string {
+	if fieldErrors, ok := err.(FieldErrors); ok {
+		messages := make([]string, len(fieldErrors))
+		for i, fieldErr := range fieldErrors {
+			messages[i] = fieldErr.Error()
+		}
+		return strings.Join(messages, "; ")
+	}
+
+	if decodeErr, ok := err.(*mapstructure.Error); ok {
+		return strings.Join(decodeErr.Errors, "; ")
+	}
+
+	return err.Error()
+}
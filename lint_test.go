@@ -0,0 +1,134 @@
+package applychanges
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// hookCalls counts the lifecycle hooks lintedRecord's throwaway instances see
+var hookCalls int
+
+type lintedRecord struct {
+	Name   string         `json:"name"`
+	Secret string         `json:"secret" apply:"encrypt"`
+	Legacy string         `json:"legacy" apply:"deprecated"`
+	Nested *nestedDetails `json:"nested"`
+}
+
+func (r *lintedRecord) BeforeApply(map[string]interface{}) error {
+	hookCalls++
+	return nil
+}
+
+func (r *lintedRecord) AfterApply([]FieldChange) error {
+	hookCalls++
+	return nil
+}
+
+// countingCipher counts the values it encrypts
+type countingCipher struct {
+	encrypted int
+}
+
+func (c *countingCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	c.encrypted++
+	return plaintext, nil
+}
+
+func (c *countingCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return ciphertext, nil
+}
+
+func TestLintChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+		want    []LintIssue
+	}{
+		{
+			name:    "clean",
+			changes: map[string]interface{}{"name": "report", "secret": "s3cr3t", "nested": map[string]interface{}{"level": 2}},
+		},
+		{
+			name:    "unknown key",
+			changes: map[string]interface{}{"nmae": "report"},
+			want:    []LintIssue{{Field: "nmae", Severity: LintError, Message: "lintedRecord has no field 'nmae' (did you mean 'name'?)"}},
+		},
+		{
+			name:    "case mismatch",
+			changes: map[string]interface{}{"Name": "report"},
+			want:    []LintIssue{{Field: "Name", Severity: LintError, Message: "'Name': no such field (did you mean 'name'?)"}},
+		},
+		{
+			name:    "case-insensitive match",
+			changes: map[string]interface{}{"Name": "report"},
+			opts:    []Option{WithCaseInsensitiveKeys()},
+			want:    []LintIssue{{Field: "Name", Severity: LintWarning, Message: "'Name' only matches 'name' case-insensitively"}},
+		},
+		{
+			name:    "deprecated",
+			changes: map[string]interface{}{"legacy": "x"},
+			want:    []LintIssue{{Field: "legacy", Severity: LintWarning, Message: "'legacy' is deprecated"}},
+		},
+		{
+			name:    "value of the wrong type",
+			changes: map[string]interface{}{"nested": map[string]interface{}{"level": "high"}},
+			want:    []LintIssue{{Field: "nested", Severity: LintError, Message: "'nested.level': expected type 'int', got unconvertible type 'string', value: 'high'"}},
+		},
+		{
+			name:    "encrypted value that isn't a string",
+			changes: map[string]interface{}{"secret": 42},
+			want:    []LintIssue{{Field: "secret", Severity: LintError, Message: "'secret' must be a string to be encrypted"}},
+		},
+		{
+			name:    "immutable",
+			changes: map[string]interface{}{"name": "report"},
+			opts:    []Option{WithImmutableFields("name")},
+			want:    []LintIssue{{Field: "name", Severity: LintError, Message: "'name' cannot be changed"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LintChanges(reflect.TypeOf(lintedRecord{}), tt.changes, tt.opts...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LintChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintChangesHasNoSideEffects(t *testing.T) {
+	hookCalls = 0
+	sink := &recordingSink{}
+	publisher := &recordingPublisher{}
+	cipher := &countingCipher{}
+	authorized, validated := 0, 0
+
+	changes := map[string]interface{}{"name": "report", "secret": "s3cr3t", "legacy": "x"}
+	LintChanges(reflect.TypeOf(&lintedRecord{}), changes,
+		WithAuditSink(sink),
+		WithEventPublisher(publisher),
+		WithFieldCipher(cipher),
+		WithHistory(NewHistory(10)),
+		WithValueAuthorizer(func(FieldChange, Principal) error {
+			authorized++
+			return nil
+		}),
+		WithValidator(ValidatorFunc(func(interface{}) error {
+			validated++
+			return nil
+		})),
+		WithContext(context.Background()),
+	)
+
+	if hookCalls != 0 || len(sink.entries) != 0 || len(publisher.events) != 0 || cipher.encrypted != 0 || authorized != 0 || validated != 0 {
+		t.Errorf("LintChanges() ran %d hooks, %d audit entries, %d events, %d encryptions, %d authorizations and %d validations, want none",
+			hookCalls, len(sink.entries), len(publisher.events), cipher.encrypted, authorized, validated)
+	}
+	if changes["secret"] != "s3cr3t" {
+		t.Errorf("changes = %v, want them untouched", changes)
+	}
+}
@@ -0,0 +1,125 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// lockVersionField is the Go name of the optional field ApplyChangesWrapper
+// uses for optimistic concurrency; targets opt in by declaring it as an integer
+const lockVersionField = "LockVersion"
+
+// ConflictError is returned when the version the changes were made against is
+// not the target's current LockVersion, i.e. someone else changed the target in
+// the meantime
+type ConflictError struct {
+	Expected int64
+	Actual   int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting change: expected version %d, but the current version is %d", e.Expected, e.Actual)
+}
+
+// checkLockVersion compares the target's LockVersion with the expected version,
+// given by WithExpectedVersion or as the LockVersion key of the changes (which
+// is removed, as the changes can't set the version themselves), and returns the
+// key and value of the next version. Nothing is checked when neither gives an
+// expected version, but the version is still incremented.
+//
+// This only detects conflicts with the version the target was loaded at; the
+// store still has to save conditionally on it, e.g. with
+// `UPDATE ... WHERE lock_version = <the old version>`.
+func checkLockVersion(changes map[string]interface{}, to interface{}, cfg *config) (string, int64, bool, error) {
+	target, ok := targetStruct(to)
+	if !ok {
+		return "", 0, false, nil
+	}
+
+	field, ok := structFieldByName(target.Type(), lockVersionField)
+	if !ok || !isIntegerKind(field.Type.Kind()) {
+		return "", 0, false, nil
+	}
+
+	key := fieldKey(field, cfg.tagName)
+	if key == "" {
+		return "", 0, false, nil
+	}
+
+	var current int64
+	value := target.FieldByIndex(field.Index)
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		current = int64(value.Uint())
+	default:
+		current = value.Int()
+	}
+
+	var expected []int64
+	if cfg.expectedVersion != nil {
+		expected = append(expected, *cfg.expectedVersion)
+	}
+
+	if changesKey, version, ok := changesLockVersion(changes, target.Type(), field, cfg.tagName); ok {
+		delete(changes, changesKey)
+
+		parsed, err := lockVersionValue(version)
+		if err != nil {
+			return "", 0, false, fmt.Errorf("'%s': %w", changesKey, err)
+		}
+		expected = append(expected, parsed)
+	}
+
+	for _, version := range expected {
+		if version != current {
+			return "", 0, false, &ConflictError{Expected: version, Actual: current}
+		}
+	}
+
+	return key, current + 1, true, nil
+}
+
+// changesLockVersion finds the changes key addressing the LockVersion field
+func changesLockVersion(changes map[string]interface{}, structType reflect.Type, field reflect.StructField, tagName string) (string, interface{}, bool) {
+	for key, value := range changes {
+		if candidate, ok := structFieldByTag(structType, tagName, key); ok && candidate.Name == field.Name {
+			return key, value, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// lockVersionValue converts an expected version from the changes to an int64
+func lockVersionValue(value interface{}) (int64, error) {
+	switch version := value.(type) {
+	case int:
+		return int64(version), nil
+	case int32:
+		return int64(version), nil
+	case int64:
+		return version, nil
+	case float64:
+		if version == math.Trunc(version) && math.Abs(version) < 1<<53 {
+			return int64(version), nil
+		}
+	case json.Number:
+		if parsed, err := version.Int64(); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return 0, fmt.Errorf("expected version must be an integer, got %v", value)
+}
+
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
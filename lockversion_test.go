@@ -0,0 +1,101 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// versionedRecord opts into optimistic concurrency with an unsigned LockVersion
+type versionedRecord struct {
+	Name        string `json:"name"`
+	ModifiedBy  string `json:"modifiedBy"`
+	LockVersion uint32 `json:"lockVersion"`
+}
+
+func TestLockVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  map[string]interface{}
+		opts     []Option
+		want     uint32
+		conflict *ConflictError
+		wantErr  bool
+	}{
+		{
+			name:    "incremented without an expected version",
+			changes: map[string]interface{}{"name": "Tampa"},
+			want:    4,
+		},
+		{
+			name:    "expected by option",
+			changes: map[string]interface{}{"name": "Tampa"},
+			opts:    []Option{WithExpectedVersion(3)},
+			want:    4,
+		},
+		{
+			name:    "expected by the changes",
+			changes: map[string]interface{}{"name": "Tampa", "lockVersion": float64(3)},
+			want:    4,
+		},
+		{
+			name:    "expected as a json.Number under another case",
+			changes: map[string]interface{}{"name": "Tampa", "LockVersion": json.Number("3")},
+			opts:    []Option{WithCaseInsensitiveKeys()},
+			want:    4,
+		},
+		{
+			name:     "stale by option",
+			changes:  map[string]interface{}{"name": "Tampa"},
+			opts:     []Option{WithExpectedVersion(2)},
+			conflict: &ConflictError{Expected: 2, Actual: 3},
+		},
+		{
+			name:     "stale by the changes",
+			changes:  map[string]interface{}{"name": "Tampa", "lockVersion": 5},
+			conflict: &ConflictError{Expected: 5, Actual: 3},
+		},
+		{
+			name:     "option and changes disagree",
+			changes:  map[string]interface{}{"name": "Tampa", "lockVersion": 3},
+			opts:     []Option{WithExpectedVersion(2)},
+			conflict: &ConflictError{Expected: 2, Actual: 3},
+		},
+		{
+			name:    "not an integer",
+			changes: map[string]interface{}{"name": "Tampa", "lockVersion": 3.5},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := versionedRecord{Name: "Miami", LockVersion: 3}
+			_, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, tt.opts...)
+
+			var conflict *ConflictError
+			switch {
+			case tt.conflict != nil:
+				if !errors.As(err, &conflict) || *conflict != *tt.conflict {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.conflict)
+				}
+			case tt.wantErr:
+				if err == nil || errors.As(err, &conflict) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want an invalid version", err)
+				}
+			case err != nil:
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if err != nil {
+				if record.Name != "Miami" || record.LockVersion != 3 {
+					t.Errorf("record = %+v, want it untouched", record)
+				}
+				return
+			}
+			if record.Name != "Tampa" || record.LockVersion != tt.want {
+				t.Errorf("record = %+v, want version %d", record, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package applychanges
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ApplyMergePatch applies an RFC 7386 JSON Merge Patch document to the target
+// with the same handling as ApplyChangesWrapper: keys are matched against the
+// target's tags, null clears a field, nested objects are merged into nested
+// structs and maps (see WithMergeMaps) and arrays replace the field wholesale.
+//
+// Numbers are decoded as json.Number so integers don't lose precision on their
+// way through float64.
+func ApplyMergePatch(patch []byte, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	changes, err := decodeMergePatch(patch)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+
+	return ApplyChangesWrapper(changes, modifier, to, append([]Option{WithMergeMaps()}, opts...)...)
+}
+
+// decodeMergePatch parses a merge patch into a changes map. Only object
+// patches are supported, since replacing the target with a scalar or array (as
+// the RFC allows) can't be expressed on a struct.
+func decodeMergePatch(patch []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(patch))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid merge patch: unexpected data after the document")
+	}
+
+	changes, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("merge patch must be a JSON object, got %s", jsonKind(document))
+	}
+
+	return changes, nil
+}
+
+// jsonKind names the JSON type of a decoded value for error messages
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case json.Number, float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
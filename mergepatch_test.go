@@ -0,0 +1,113 @@
+package applychanges
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type patchedRecord struct {
+	BaseStruct
+	City    string            `json:"city"`
+	Weather *string           `json:"weather"`
+	Count   int64             `json:"count"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Details *nestedDetails    `json:"details"`
+}
+
+func newPatchedRecord() patchedRecord {
+	return patchedRecord{
+		City:    "Miami",
+		Weather: stringPtr("Sun"),
+		Count:   1,
+		Tags:    []string{"east", "coast"},
+		Labels:  map[string]string{"a": "1", "b": "2"},
+		Details: &nestedDetails{Source: "survey", Level: 2},
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    func(record *patchedRecord)
+		wantErr string
+	}{
+		{
+			name:  "sets fields",
+			patch: `{"city": "Tampa", "count": 9007199254740993}`,
+			want: func(record *patchedRecord) {
+				record.City, record.Count = "Tampa", 9007199254740993
+			},
+		},
+		{
+			name:  "null clears",
+			patch: `{"weather": null}`,
+			want: func(record *patchedRecord) {
+				record.Weather = nil
+			},
+		},
+		{
+			name:  "objects merge",
+			patch: `{"details": {"level": 3}, "labels": {"b": null, "c": "3"}}`,
+			want: func(record *patchedRecord) {
+				record.Details = &nestedDetails{Source: "survey", Level: 3}
+				record.Labels = map[string]string{"a": "1", "c": "3"}
+			},
+		},
+		{
+			name:  "arrays replace",
+			patch: `{"tags": ["west"]}`,
+			want: func(record *patchedRecord) {
+				record.Tags = []string{"west"}
+			},
+		},
+		{
+			name:    "not an object",
+			patch:   `["city"]`,
+			wantErr: "merge patch must be a JSON object, got an array",
+		},
+		{
+			name:    "trailing data",
+			patch:   `{"city": "Tampa"} {}`,
+			wantErr: "unexpected data after the document",
+		},
+		{
+			name:    "invalid JSON",
+			patch:   `{"city": `,
+			wantErr: "invalid merge patch",
+		},
+		{
+			name:    "immutable fields",
+			patch:   `{"createdBy": "EUA9"}`,
+			wantErr: "'createdBy' cannot be changed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newPatchedRecord()
+			_, err := ApplyMergePatch([]byte(tt.patch), "EUA1", &record)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyMergePatch() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyMergePatch() error = %v", err)
+			}
+
+			want := newPatchedRecord()
+			tt.want(&want)
+			want.BaseStruct = record.BaseStruct
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("record = %+v, want %+v", record, want)
+			}
+			if record.ModifiedBy == nil || *record.ModifiedBy != "EUA1" {
+				t.Errorf("ModifiedBy = %v, want EUA1", record.ModifiedBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package applychanges
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// MessageCatalog maps the codes of the package's errors (see LocalizedError) to
+// the messages shown for them, with the error's parameters in braces, e.g.
+// "{fields} no se pueden cambiar" for "immutable". Codes a catalog lacks are
+// rendered from EnglishCatalog. The "and" entry joins the last two fields of
+// a list.
+type MessageCatalog map[string]string
+
+// EnglishCatalog is the default MessageCatalog. Its messages read like the
+// errors' Error strings, which stay in English, for logs, whatever the catalog.
+var EnglishCatalog = MessageCatalog{
+	"and": "and",
+
+	"field":               "'{path}': {error}",
+	"fieldErrors":         "{count} errors applying changes: {errors}",
+	"fieldErrors.one":     "1 error applying changes: {errors}",
+	"validation":          "'{field}' {message}",
+	"validationErrors":    "validation failed: {errors}",
+	"forbiddenFields":     "not authorized to change {fields}",
+	"policyDenied":        "not authorized to apply the changes: {error}",
+	"disallowedFields":    "changes to {fields} are not allowed",
+	"immutable":           "{fields} cannot be changed",
+	"fieldLocked":         "'{field}' is being edited by {holder}",
+	"fieldLockedErrors":   "fields are locked: {errors}",
+	"invalidEnum":         "'{field}': '{value}' is not a valid {type}",
+	"invalidEnum.allowed": "'{field}': '{value}' is not a valid {type} (expected {allowed})",
+	"depthExceeded":       "'{path}' is nested more than {max} levels deep",
+	"invalidEncoding":     "'{field}' is not valid UTF-8",
+	"nonFinite":           "'{field}' must be a finite number, got {value}",
+	"conflictingKeys":     "{keys} all change '{path}'",
+	"renamed":             "'{old}' has been renamed to '{new}'",
+	"modifiedDtsSupplied": "'{key}' is stamped by the apply and cannot be supplied",
+	"conflict":            "conflicting change: expected version {expected}, but the current version is {actual}",
+	"etagMismatch":        "conflicting change: expected ETag \"{expected}\", but the current ETag is \"{actual}\"",
+	"conditionNotMet":     "condition not met: {condition}",
+	"batch":               "{count} of the batch failed to apply: {errors}",
+	"replay":              "replaying audit entry {entry}: {reason}",
+	"rateLimited":         "{type} {id} has been changed too often, try again later",
+
+	"schemaMismatch":             "the changes were built against another schema of {type}: {differences}",
+	"schemaMismatch.added":       "added {fields}",
+	"schemaMismatch.removed":     "removed {fields}",
+	"schemaMismatch.retyped":     "retyped {fields}",
+	"schemaMismatch.fingerprint": "the fingerprint doesn't match the fields",
+}
+
+// LocalizedError is implemented by the package's typed errors, for messages
+// shown to people rather than logged
+type LocalizedError interface {
+	error
+
+	// ErrorCode is the key of the error's message in a MessageCatalog, e.g.
+	// "immutable" for an ImmutableFieldError
+	ErrorCode() string
+
+	// LocalizedMessage renders the error's message from the catalog
+	LocalizedMessage(catalog MessageCatalog) string
+}
+
+// LocalizeError renders the first LocalizedError in err's chain from the
+// catalog, or returns err's Error string when there is none
+func LocalizeError(err error, catalog MessageCatalog) string {
+	var localized LocalizedError
+	if errors.As(err, &localized) {
+		return localized.LocalizedMessage(catalog)
+	}
+
+	return err.Error()
+}
+
+// ErrorResponse is an apply's error as WriteErrorResponse and
+// GraphQLErrorPresenter present it: the code of its LocalizedError (empty for
+// other errors) and its message, localized with the catalog of
+// WithMessageCatalog (EnglishCatalog by default)
+type ErrorResponse struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewErrorResponse presents the error returned by an apply
+func NewErrorResponse(err error) ErrorResponse {
+	catalog := EnglishCatalog
+	var cataloged *catalogError
+	if errors.As(err, &cataloged) {
+		catalog = cataloged.catalog
+	}
+
+	var localized LocalizedError
+	if !errors.As(err, &localized) {
+		return ErrorResponse{Message: err.Error()}
+	}
+
+	return ErrorResponse{Code: localized.ErrorCode(), Message: localized.LocalizedMessage(catalog)}
+}
+
+// WriteErrorResponse writes the error returned by an apply as the JSON of its
+// ErrorResponse, with the status; a rate limited apply (see ErrRateLimited) is
+// always written with 429 Too Many Requests
+func WriteErrorResponse(w http.ResponseWriter, status int, err error) {
+	if errors.Is(err, ErrRateLimited) {
+		status = http.StatusTooManyRequests
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(NewErrorResponse(err))
+}
+
+// GraphQLErrorPresenter is a gqlgen error presenter presenting the errors
+// returned by applies as NewErrorResponse does, with their code in the "code"
+// extension; other errors are presented as by gqlgen's default presenter.
+//
+//	srv.SetErrorPresenter(applychanges.GraphQLErrorPresenter)
+func GraphQLErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	presented := graphql.DefaultErrorPresenter(ctx, err)
+
+	response := NewErrorResponse(err)
+	if response.Code == "" {
+		return presented
+	}
+
+	presented.Message = response.Message
+	if presented.Extensions == nil {
+		presented.Extensions = map[string]interface{}{}
+	}
+	presented.Extensions["code"] = response.Code
+
+	return presented
+}
+
+// catalogError carries the catalog of WithMessageCatalog along with an apply's
+// error, leaving its Error string as it is
+type catalogError struct {
+	err     error
+	catalog MessageCatalog
+}
+
+func (e *catalogError) Error() string {
+	return e.err.Error()
+}
+
+func (e *catalogError) Unwrap() error {
+	return e.err
+}
+
+// render fills in the message for the code with the params, given as name and
+// value pairs
+func (c MessageCatalog) render(code string, params ...string) string {
+	message, ok := c[code]
+	if !ok {
+		message = EnglishCatalog[code]
+	}
+
+	pairs := make([]string, len(params))
+	for i := 0; i < len(params); i += 2 {
+		pairs[i], pairs[i+1] = "{"+params[i]+"}", params[i+1]
+	}
+
+	return strings.NewReplacer(pairs...).Replace(message)
+}
+
+// fields quotes and joins the fields like quoteFields, with the catalog's "and"
+func (c MessageCatalog) fields(fields []string) string {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = "'" + field + "'"
+	}
+
+	if len(quoted) <= 1 {
+		return strings.Join(quoted, "")
+	}
+
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " " + c.render("and") + " " + quoted[len(quoted)-1]
+}
+
+func (e *FieldError) ErrorCode() string { return "field" }
+
+func (e *FieldError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "path", e.Path, "error", LocalizeError(e.Err, catalog))
+}
+
+func (e FieldErrors) ErrorCode() string { return "fieldErrors" }
+
+func (e FieldErrors) LocalizedMessage(catalog MessageCatalog) string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.LocalizedMessage(catalog)
+	}
+
+	code := e.ErrorCode()
+	if len(e) == 1 {
+		code += ".one"
+	}
+
+	return catalog.render(code, "count", strconv.Itoa(len(e)), "errors", strings.Join(messages, "; "))
+}
+
+// ErrorCode is the error's Code, or "validation" without one
+func (e ValidationError) ErrorCode() string {
+	if e.Code == "" {
+		return "validation"
+	}
+
+	return e.Code
+}
+
+// LocalizedMessage renders the message for the error's Code with its Params
+// and field, or "'{field}' {message}" without a Code
+func (e ValidationError) LocalizedMessage(catalog MessageCatalog) string {
+	if _, ok := catalog[e.ErrorCode()]; !ok && EnglishCatalog[e.ErrorCode()] == "" {
+		return catalog.render("validation", "field", e.Field, "message", e.Message)
+	}
+
+	params := []string{"field", e.Field, "message", e.Message}
+	names := make([]string, 0, len(e.Params))
+	for name := range e.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		params = append(params, name, e.Params[name])
+	}
+
+	return catalog.render(e.ErrorCode(), params...)
+}
+
+func (e ValidationErrors) ErrorCode() string { return "validationErrors" }
+
+func (e ValidationErrors) LocalizedMessage(catalog MessageCatalog) string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.LocalizedMessage(catalog)
+	}
+
+	return catalog.render(e.ErrorCode(), "errors", strings.Join(messages, "; "))
+}
+
+func (e *ForbiddenFieldsError) ErrorCode() string { return "forbiddenFields" }
+
+func (e *ForbiddenFieldsError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "fields", catalog.fields(e.Fields))
+}
+
+func (e *PolicyError) ErrorCode() string { return "policyDenied" }
+
+func (e *PolicyError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "error", LocalizeError(e.Err, catalog))
+}
+
+func (e *DisallowedFieldsError) ErrorCode() string { return "disallowedFields" }
+
+func (e *DisallowedFieldsError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "fields", catalog.fields(e.Fields))
+}
+
+func (e *ImmutableFieldError) ErrorCode() string { return "immutable" }
+
+func (e *ImmutableFieldError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "fields", catalog.fields(e.Fields))
+}
+
+func (e *InvalidEnumError) ErrorCode() string { return "invalidEnum" }
+
+func (e *InvalidEnumError) LocalizedMessage(catalog MessageCatalog) string {
+	code := e.ErrorCode()
+	if len(e.Allowed) > 0 {
+		code += ".allowed"
+	}
+
+	return catalog.render(code, "field", e.Field, "value", e.Value, "type", e.Type, "allowed", catalog.fields(e.Allowed))
+}
+
+func (e *DepthExceededError) ErrorCode() string { return "depthExceeded" }
+
+func (e *DepthExceededError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "path", e.Path, "max", strconv.Itoa(e.Max))
+}
+
+func (e *InvalidEncodingError) ErrorCode() string { return "invalidEncoding" }
+
+func (e *InvalidEncodingError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "field", e.Field)
+}
+
+func (e *NonFiniteFloatError) ErrorCode() string { return "nonFinite" }
+
+func (e *NonFiniteFloatError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "field", e.Field, "value", fmt.Sprint(e.Value))
+}
+
+func (e *ConflictingKeysError) ErrorCode() string { return "conflictingKeys" }
+
+func (e *ConflictingKeysError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "keys", catalog.fields(e.Keys), "path", e.Path)
+}
+
+func (e *RenamedFieldError) ErrorCode() string { return "renamed" }
+
+func (e *RenamedFieldError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "old", e.OldField, "new", e.NewField)
+}
+
+func (e *ModifiedDtsSuppliedError) ErrorCode() string { return "modifiedDtsSupplied" }
+
+func (e *ModifiedDtsSuppliedError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "key", e.Key)
+}
+
+func (e *ConflictError) ErrorCode() string { return "conflict" }
+
+func (e *ConflictError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "expected", strconv.FormatInt(e.Expected, 10), "actual", strconv.FormatInt(e.Actual, 10))
+}
+
+func (e *ErrETagMismatch) ErrorCode() string { return "etagMismatch" }
+
+func (e *ErrETagMismatch) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "expected", e.Expected, "actual", e.Actual)
+}
+
+func (e *ConditionError) ErrorCode() string { return "conditionNotMet" }
+
+func (e *ConditionError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "condition", e.Clause.String())
+}
+
+func (e *BatchError) ErrorCode() string { return "batch" }
+
+func (e *BatchError) LocalizedMessage(catalog MessageCatalog) string {
+	indexes := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	messages := make([]string, len(indexes))
+	for i, index := range indexes {
+		messages[i] = fmt.Sprintf("%d: %s", index, LocalizeError(e.Errors[index], catalog))
+	}
+
+	return catalog.render(e.ErrorCode(), "count", strconv.Itoa(len(indexes)), "errors", strings.Join(messages, "; "))
+}
+
+func (e *ReplayError) ErrorCode() string { return "replay" }
+
+func (e *ReplayError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "entry", strconv.Itoa(e.Entry), "reason", e.Reason)
+}
+
+func (e *RateLimitedError) ErrorCode() string { return "rateLimited" }
+
+func (e *RateLimitedError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "type", e.EntityType, "id", e.ID.String())
+}
+
+func (e *SchemaMismatchError) ErrorCode() string { return "schemaMismatch" }
+
+func (e *SchemaMismatchError) LocalizedMessage(catalog MessageCatalog) string {
+	var differences []string
+	if len(e.Added) > 0 {
+		differences = append(differences, catalog.render("schemaMismatch.added", "fields", catalog.fields(e.Added)))
+	}
+	if len(e.Removed) > 0 {
+		differences = append(differences, catalog.render("schemaMismatch.removed", "fields", catalog.fields(e.Removed)))
+	}
+	if len(e.Retyped) > 0 {
+		differences = append(differences, catalog.render("schemaMismatch.retyped", "fields", catalog.fields(e.Retyped)))
+	}
+	if len(differences) == 0 {
+		differences = append(differences, catalog.render("schemaMismatch.fingerprint"))
+	}
+
+	return catalog.render(e.ErrorCode(), "type", e.TargetType, "differences", strings.Join(differences, ", "))
+}
+
+func (e *FieldLockedError) ErrorCode() string { return "fieldLocked" }
+
+func (e *FieldLockedError) LocalizedMessage(catalog MessageCatalog) string {
+	return catalog.render(e.ErrorCode(), "field", e.Field, "holder", e.Holder)
+}
+
+func (e FieldLockedErrors) ErrorCode() string { return "fieldLockedErrors" }
+
+func (e FieldLockedErrors) LocalizedMessage(catalog MessageCatalog) string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.LocalizedMessage(catalog)
+	}
+
+	return catalog.render(e.ErrorCode(), "errors", strings.Join(messages, "; "))
+}
@@ -0,0 +1,244 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/google/uuid"
+)
+
+var spanishCatalog = MessageCatalog{
+	"and":              "y",
+	"validationErrors": "la validación falló: {errors}",
+	"required":         "'{field}' es obligatorio",
+	"range":            "'{field}' debe estar entre {min} y {max}",
+	"immutable":        "{fields} no se pueden cambiar",
+	"fieldErrors.one":  "1 error al aplicar los cambios: {errors}",
+}
+
+type localizedStation struct {
+	BaseStruct
+	City        string  `json:"city"`
+	Temperature float64 `json:"temperature"`
+	Code        string  `json:"code" apply:"immutable"`
+}
+
+// validateStation requires a city and a plausible temperature
+func validateStation(target interface{}) error {
+	station := target.(*localizedStation)
+
+	var errs ValidationErrors
+	if station.City == "" {
+		errs = append(errs, ValidationError{Field: "city", Message: "is required", Code: "required"})
+	}
+	if station.Temperature < -50 || station.Temperature > 60 {
+		errs = append(errs, ValidationError{
+			Field:   "temperature",
+			Message: "must be between -50 and 60",
+			Code:    "range",
+			Params:  map[string]string{"min": "-50", "max": "60"},
+		})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func TestWithMessageCatalog(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		catalog     MessageCatalog
+		wantCode    string
+		wantMessage string
+		wantLog     string
+	}{
+		{
+			name:        "required and range",
+			changes:     map[string]interface{}{"city": "", "temperature": 75},
+			catalog:     spanishCatalog,
+			wantCode:    "validationErrors",
+			wantMessage: "la validación falló: 'city' es obligatorio; 'temperature' debe estar entre -50 y 60",
+			wantLog:     "validation failed: 'city' is required; 'temperature' must be between -50 and 60",
+		},
+		{
+			name:        "list joined in the catalog's language",
+			changes:     map[string]interface{}{"code": "TPA", "createdBy": "EUA2"},
+			catalog:     spanishCatalog,
+			wantCode:    "immutable",
+			wantMessage: "'code' y 'createdBy' no se pueden cambiar",
+			wantLog:     "'code' and 'createdBy' cannot be changed",
+		},
+		{
+			name:        "missing code falls back to English",
+			changes:     map[string]interface{}{"elevation": 12},
+			catalog:     spanishCatalog,
+			wantCode:    "fieldErrors",
+			wantMessage: "1 error al aplicar los cambios: 'elevation': no such field",
+			wantLog:     "1 error applying changes: 'elevation': no such field",
+		},
+		{
+			name:        "English by default",
+			changes:     map[string]interface{}{"city": "", "temperature": 75},
+			wantCode:    "validationErrors",
+			wantMessage: "validation failed: 'city' is required; 'temperature' must be between -50 and 60",
+			wantLog:     "validation failed: 'city' is required; 'temperature' must be between -50 and 60",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			station := localizedStation{City: "Tampa", Temperature: 21}
+			opts := []Option{WithValidator(ValidatorFunc(validateStation))}
+			if tt.catalog != nil {
+				opts = append(opts, WithMessageCatalog(tt.catalog))
+			}
+
+			_, err := ApplyChangesWrapper(tt.changes, "EUA1", &station, opts...)
+			if err == nil {
+				t.Fatal("ApplyChangesWrapper() error = nil, want it to fail")
+			}
+			if err.Error() != tt.wantLog {
+				t.Errorf("Error() = %q, want it unchanged: %q", err.Error(), tt.wantLog)
+			}
+
+			response := NewErrorResponse(err)
+			if response.Code != tt.wantCode || response.Message != tt.wantMessage {
+				t.Errorf("NewErrorResponse() = %+v, want %q: %q", response, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestWithMessageCatalogUnwraps(t *testing.T) {
+	station := localizedStation{}
+	_, err := ApplyChangesWrapper(map[string]interface{}{"code": "TPA"}, "EUA1", &station, WithMessageCatalog(spanishCatalog))
+
+	var immutable *ImmutableFieldError
+	if !errors.As(err, &immutable) || !reflect.DeepEqual(immutable.Fields, []string{"code"}) {
+		t.Errorf("ApplyChangesWrapper() error = %v, want an ImmutableFieldError to be found in it", err)
+	}
+}
+
+func TestLocalizedMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  LocalizedError
+		want string
+	}{
+		{
+			name: "validation error without a code",
+			err:  ValidationError{Field: "city", Message: "is too long"},
+			want: "'city' is too long",
+		},
+		{
+			name: "validation error with a code no catalog has",
+			err:  ValidationError{Field: "city", Message: "is taken", Code: "unique"},
+			want: "'city' is taken",
+		},
+		{
+			name: "enum",
+			err:  &InvalidEnumError{Field: "status", Type: "Status", Value: "pending", Allowed: []string{"open", "closed"}},
+			want: "'status': 'pending' is not a valid Status (expected 'open' y 'closed')",
+		},
+		{
+			name: "field wrapping a localized error",
+			err:  &FieldError{Path: "details", Err: &ImmutableFieldError{Fields: []string{"source"}}},
+			want: "'details': 'source' no se pueden cambiar",
+		},
+		{
+			name: "batch",
+			err:  &BatchError{Errors: map[int]error{2: &ImmutableFieldError{Fields: []string{"code"}}, 0: errors.New("boom")}},
+			want: "2 of the batch failed to apply: 0: boom; 2: 'code' no se pueden cambiar",
+		},
+		{
+			name: "schema mismatch",
+			err:  &SchemaMismatchError{TargetType: "Station", Added: []string{"city"}},
+			want: "the changes were built against another schema of Station: added 'city'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.LocalizedMessage(spanishCatalog); got != tt.want {
+				t.Errorf("LocalizedMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEnglishCatalogMatchesErrors checks the English messages read like the
+// errors' Error strings
+func TestEnglishCatalogMatchesErrors(t *testing.T) {
+	errs := []LocalizedError{
+		&FieldError{Path: "city", Err: errors.New("expected type 'string'")},
+		FieldErrors{{Path: "a", Err: errors.New("no such field")}, {Path: "b", Err: errors.New("no such field")}},
+		ValidationErrors{{Field: "city", Message: "is required"}},
+		&ForbiddenFieldsError{Fields: []string{"a", "b", "c"}},
+		&PolicyError{Err: errors.New("closed")},
+		&DisallowedFieldsError{Fields: []string{"a"}},
+		&ImmutableFieldError{Fields: []string{"a", "b"}},
+		FieldLockedErrors{{Field: "a", Holder: "EUA2"}, {Field: "b.c", Holder: "EUA3"}},
+		&InvalidEnumError{Field: "status", Type: "Status", Value: "x"},
+		&InvalidEnumError{Field: "status", Type: "Status", Value: "x", Allowed: []string{"open"}},
+		&DepthExceededError{Path: "a.b.c", Max: 2},
+		&InvalidEncodingError{Field: "notes"},
+		&NonFiniteFloatError{Field: "reading", Value: 1.5},
+		&ConflictingKeysError{Path: "city", Keys: []string{"City", "city"}},
+		&RenamedFieldError{OldField: "weather", NewField: "conditions"},
+		&ModifiedDtsSuppliedError{Key: "modifiedDts"},
+		&ConflictError{Expected: 3, Actual: 4},
+		&ErrETagMismatch{Expected: "abc", Actual: "def"},
+		&ConditionError{Clause: FieldEquals("status", "open")},
+		&BatchError{Errors: map[int]error{1: errors.New("boom")}},
+		&ReplayError{Entry: 2, Reason: "it's for another target"},
+		&RateLimitedError{EntityType: "Station", ID: uuid.MustParse("8e5c5f4e-4b5a-4c1e-9d3a-2f6b7c8d9e0f")},
+		&SchemaMismatchError{TargetType: "Station", Removed: []string{"a"}, Retyped: []string{"b"}},
+		&SchemaMismatchError{TargetType: "Station"},
+	}
+
+	for _, err := range errs {
+		if got := err.LocalizedMessage(EnglishCatalog); got != err.Error() {
+			t.Errorf("%T.LocalizedMessage(EnglishCatalog) = %q, want %q", err, got, err.Error())
+		}
+	}
+}
+
+func TestWriteErrorResponse(t *testing.T) {
+	station := localizedStation{}
+	_, err := ApplyChangesWrapper(map[string]interface{}{"code": "TPA"}, "EUA1", &station, WithMessageCatalog(spanishCatalog))
+
+	recorder := httptest.NewRecorder()
+	WriteErrorResponse(recorder, http.StatusUnprocessableEntity, err)
+
+	if recorder.Code != http.StatusUnprocessableEntity || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("response = %d %q, want 422 application/json", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if want := `{"code":"immutable","message":"'code' no se pueden cambiar"}`; strings.TrimSpace(recorder.Body.String()) != want {
+		t.Errorf("body = %s, want %s", recorder.Body.String(), want)
+	}
+}
+
+func TestGraphQLErrorPresenter(t *testing.T) {
+	ctx := graphql.WithFieldContext(context.Background(), &graphql.FieldContext{})
+
+	station := localizedStation{}
+	_, err := ApplyChangesWrapper(map[string]interface{}{"code": "TPA"}, "EUA1", &station, WithMessageCatalog(spanishCatalog))
+
+	presented := GraphQLErrorPresenter(ctx, err)
+	if presented.Message != "'code' no se pueden cambiar" || presented.Extensions["code"] != "immutable" {
+		t.Errorf("GraphQLErrorPresenter() = %q %v, want the Spanish message and its code", presented.Message, presented.Extensions)
+	}
+
+	presented = GraphQLErrorPresenter(ctx, errors.New("boom"))
+	if presented.Message != "boom" || presented.Extensions != nil {
+		t.Errorf("GraphQLErrorPresenter() = %q %v, want other errors presented as they are", presented.Message, presented.Extensions)
+	}
+}
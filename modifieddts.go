@@ -0,0 +1,122 @@
+package applychanges
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ModifiedDtsPolicy is what ApplyChangesWrapper does with a modifiedDts the
+// changes supply themselves, e.g. an import preserving the original
+// modification times, see WithModifiedDtsPolicy
+type ModifiedDtsPolicy int
+
+const (
+	// RejectModifiedDts fails the apply with a ModifiedDtsSuppliedError (the
+	// default)
+	RejectModifiedDts ModifiedDtsPolicy = iota
+
+	// HonorModifiedDts stamps the supplied time instead of the current one. It
+	// must parse as a time.Time field would (see WithTimeLayouts and
+	// WithEpochTimes), and is converted into the configured time zone (see
+	// WithNormalizeTimeZone), or else UTC.
+	HonorModifiedDts
+
+	// ClampModifiedDts honors the supplied time unless it's later than the
+	// Clock's current time, which is stamped instead
+	ClampModifiedDts
+)
+
+func (p ModifiedDtsPolicy) String() string {
+	switch p {
+	case RejectModifiedDts:
+		return "reject"
+	case HonorModifiedDts:
+		return "honor"
+	case ClampModifiedDts:
+		return "clamp"
+	}
+
+	return fmt.Sprintf("ModifiedDtsPolicy(%d)", int(p))
+}
+
+// ModifiedDtsSuppliedError is returned when the changes supply a modifiedDts
+// under RejectModifiedDts
+type ModifiedDtsSuppliedError struct {
+	// Key is the key the changes supplied it as
+	Key string
+}
+
+func (e *ModifiedDtsSuppliedError) Error() string {
+	return fmt.Sprintf("'%s' is stamped by the apply and cannot be supplied", e.Key)
+}
+
+// stampedModifiedDts returns the time to stamp as modifiedDts under the
+// policy, removing any time the changes supplied under another spelling of
+// the key
+func stampedModifiedDts(changes map[string]interface{}, dtsKey string, cfg *config) (time.Time, error) {
+	var suppliedKey string
+	var supplied interface{}
+	for key, value := range changes {
+		if strings.EqualFold(key, dtsKey) {
+			suppliedKey, supplied = key, value
+			break
+		}
+	}
+
+	if suppliedKey == "" {
+		return cfg.now, nil
+	}
+
+	if cfg.modifiedDtsPolicy == RejectModifiedDts {
+		return time.Time{}, &ModifiedDtsSuppliedError{Key: suppliedKey}
+	}
+	delete(changes, suppliedKey)
+
+	t, err := cfg.suppliedTime(supplied)
+	if err != nil {
+		return time.Time{}, &FieldError{Path: dtsKey, Err: err}
+	}
+
+	if cfg.modifiedDtsPolicy == ClampModifiedDts && t.After(cfg.now) {
+		return cfg.now, nil
+	}
+
+	return t, nil
+}
+
+// suppliedTime reads a time from the changes the way a time.Time field would
+// be decoded, in the configured time zone or else UTC
+func (cfg *config) suppliedTime(value interface{}) (time.Time, error) {
+	var t time.Time
+	switch typed := value.(type) {
+	case time.Time:
+		t = typed
+	case *time.Time:
+		if typed == nil {
+			return time.Time{}, fmt.Errorf("expected a time, got null")
+		}
+		t = *typed
+	case string:
+		parsed, err := cfg.parseTime(typed)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t = parsed
+	default:
+		epoch, ok := time.Time{}, false
+		if cfg.epochUnit > 0 {
+			epoch, ok = epochTime(value, cfg.epochUnit)
+		}
+		if !ok {
+			return time.Time{}, fmt.Errorf("expected a time, got %T", value)
+		}
+		t = epoch
+	}
+
+	if cfg.timeZone != nil {
+		return t.In(cfg.timeZone), nil
+	}
+
+	return t.UTC(), nil
+}
@@ -0,0 +1,199 @@
+package applychanges
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock stuck at one time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestWithModifiedDtsPolicy(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	future := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		policy   ModifiedDtsPolicy
+		supplied interface{}
+		want     time.Time
+		wantErr  interface{}
+	}{
+		{name: "reject past", policy: RejectModifiedDts, supplied: past.Format(time.RFC3339), wantErr: &ModifiedDtsSuppliedError{}},
+		{name: "reject future", policy: RejectModifiedDts, supplied: future.Format(time.RFC3339), wantErr: &ModifiedDtsSuppliedError{}},
+		{name: "reject malformed", policy: RejectModifiedDts, supplied: "yesterday", wantErr: &ModifiedDtsSuppliedError{}},
+		{name: "honor past", policy: HonorModifiedDts, supplied: past.Format(time.RFC3339), want: past},
+		{name: "honor past with offset", policy: HonorModifiedDts, supplied: "2020-01-01T22:04:05-05:00", want: past},
+		{name: "honor time value", policy: HonorModifiedDts, supplied: past.In(time.FixedZone("EST", -5*3600)), want: past},
+		{name: "honor future", policy: HonorModifiedDts, supplied: future.Format(time.RFC3339), want: future},
+		{name: "honor malformed", policy: HonorModifiedDts, supplied: "yesterday", wantErr: &FieldError{}},
+		{name: "clamp past", policy: ClampModifiedDts, supplied: past.Format(time.RFC3339), want: past},
+		{name: "clamp future", policy: ClampModifiedDts, supplied: future.Format(time.RFC3339), want: now},
+		{name: "clamp malformed", policy: ClampModifiedDts, supplied: 42, wantErr: &FieldError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := nestedRecord{}
+			changes := map[string]interface{}{"name": "x", "modifiedDts": tt.supplied}
+			_, err := ApplyChangesWrapper(changes, "EUA1", &record, WithClock(fixedClock(now)), WithModifiedDtsPolicy(tt.policy))
+
+			switch want := tt.wantErr.(type) {
+			case *ModifiedDtsSuppliedError:
+				if !errors.As(err, &want) || want.Key != "modifiedDts" {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want a ModifiedDtsSuppliedError", err)
+				}
+			case *FieldError:
+				if !errors.As(err, &want) || want.Path != "modifiedDts" {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want a FieldError for modifiedDts", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("ApplyChangesWrapper() error = %v", err)
+				}
+				if !record.ModifiedDts.Equal(tt.want) || record.ModifiedDts.Location() != time.UTC {
+					t.Errorf("ModifiedDts = %v, want %v in UTC", record.ModifiedDts, tt.want)
+				}
+				return
+			}
+
+			if record.ModifiedDts != nil || record.Name != "" {
+				t.Errorf("record = %+v, want it untouched", record)
+			}
+		})
+	}
+}
+
+func TestModifiedDtsPolicyTimeOptions(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	berlin := time.FixedZone("CEST", 2*3600)
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		supplied interface{}
+		want     time.Time
+	}{
+		{
+			name:     "configured layout",
+			opts:     []Option{WithTimeLayouts("2006-01-02 15:04")},
+			supplied: "2024-05-01 08:30",
+			want:     time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "epoch seconds",
+			opts:     []Option{WithEpochTimes(time.Second)},
+			supplied: int64(1714552200),
+			want:     time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "normalized time zone",
+			opts:     []Option{WithNormalizeTimeZone(berlin)},
+			supplied: "2024-05-01T08:30:00Z",
+			want:     time.Date(2024, 5, 1, 10, 30, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := nestedRecord{}
+			opts := append([]Option{WithClock(fixedClock(now)), WithModifiedDtsPolicy(HonorModifiedDts)}, tt.opts...)
+			if _, err := ApplyChangesWrapper(map[string]interface{}{"modifiedDts": tt.supplied}, "EUA1", &record, opts...); err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if !record.ModifiedDts.Equal(tt.want) || record.ModifiedDts.Location().String() != tt.want.Location().String() {
+				t.Errorf("ModifiedDts = %v, want %v", record.ModifiedDts, tt.want)
+			}
+		})
+	}
+}
+
+func TestModifiedDtsNotStamped(t *testing.T) {
+	supplied := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	record := nestedRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"modifiedDts": supplied}, &record); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if !record.ModifiedDts.Equal(supplied) {
+		t.Errorf("ModifiedDts = %v, want the supplied %v", record.ModifiedDts, supplied)
+	}
+
+	record = nestedRecord{}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"modifiedDts": supplied}, "EUA1", &record, WithModifiedDts(false)); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if !record.ModifiedDts.Equal(supplied) {
+		t.Errorf("ModifiedDts = %v, want the supplied %v", record.ModifiedDts, supplied)
+	}
+}
+
+// stampedRecord has a non-pointer ModifiedDts, unlike BaseStruct
+type stampedRecord struct {
+	Name        string    `json:"name"`
+	ModifiedBy  string    `json:"modifiedBy"`
+	ModifiedDts time.Time `json:"modifiedAt"`
+}
+
+// unstampedRecord has ModifiedDts fields that can't be stamped
+type unstampedRecord struct {
+	Name        string `json:"name"`
+	ModifiedBy  string `json:"modifiedBy"`
+	ModifiedDts string `json:"modifiedDts"`
+}
+
+func TestWithModifiedDts(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		to   interface{}
+		opts []Option
+		want func(to interface{}) bool
+	}{
+		{
+			name: "BaseStruct",
+			to:   &nestedRecord{},
+			want: func(to interface{}) bool {
+				dts := to.(*nestedRecord).ModifiedDts
+				return dts != nil && dts.Equal(now)
+			},
+		},
+		{
+			name: "time.Time field under its own key",
+			to:   &stampedRecord{},
+			want: func(to interface{}) bool { return to.(*stampedRecord).ModifiedDts.Equal(now) },
+		},
+		{
+			name: "disabled",
+			to:   &nestedRecord{},
+			opts: []Option{WithModifiedDts(false)},
+			want: func(to interface{}) bool { return to.(*nestedRecord).ModifiedDts == nil },
+		},
+		{
+			name: "not a time",
+			to:   &unstampedRecord{},
+			want: func(to interface{}) bool { return to.(*unstampedRecord).ModifiedDts == "" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithClock(fixedClock(now))}, tt.opts...)
+			if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "Tampa"}, "EUA1", tt.to, opts...); err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if !tt.want(tt.to) {
+				t.Errorf("target = %+v, ModifiedDts stamped wrongly", tt.to)
+			}
+		})
+	}
+}
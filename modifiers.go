@@ -0,0 +1,101 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// recentModifiersField is the Go name of the optional field ApplyChangesWrapper
+// keeps the latest modifiers in; targets opt in by declaring it as a []string
+const recentModifiersField = "RecentModifiers"
+
+// defaultMaxRecentModifiers is how many modifiers are kept in a target's
+// RecentModifiers field unless WithMaxRecentModifiers says otherwise
+const defaultMaxRecentModifiers = 5
+
+// metadataKey returns the changes key for one of the target's metadata fields
+// (by Go name), falling back to the given default when the target doesn't have
+// the field
+func metadataKey(to interface{}, cfg *config, fieldName string, fallback string) string {
+	target, ok := targetStruct(to)
+	if !ok {
+		return fallback
+	}
+
+	field, ok := structFieldByName(target.Type(), fieldName)
+	if !ok {
+		return fallback
+	}
+
+	if key := fieldKey(field, cfg.tagName); key != "" {
+		return key
+	}
+
+	return fallback
+}
+
+// recentModifiersKey returns the changes key of the target's RecentModifiers
+// field, or false when the target doesn't have one
+func recentModifiersKey(to interface{}, cfg *config) (string, bool) {
+	target, ok := targetStruct(to)
+	if !ok {
+		return "", false
+	}
+
+	field, ok := structFieldByName(target.Type(), recentModifiersField)
+	if !ok || field.Type != reflect.TypeOf([]string{}) {
+		return "", false
+	}
+
+	key := fieldKey(field, cfg.tagName)
+	return key, key != ""
+}
+
+// rejectRecentModifiersWrite fails if the changes try to set RecentModifiers
+// themselves; the field is only ever maintained by ApplyChangesWrapper.
+func rejectRecentModifiersWrite(changes map[string]interface{}, to interface{}, cfg *config) error {
+	recentKey, ok := recentModifiersKey(to, cfg)
+	if !ok {
+		return nil
+	}
+
+	for key := range changes {
+		if strings.EqualFold(key, recentKey) {
+			return fmt.Errorf("'%s' cannot be changed directly", key)
+		}
+	}
+
+	return nil
+}
+
+// nextRecentModifiers returns the key of the target's RecentModifiers field and
+// its value with modifier pushed to the front, or false when the target doesn't
+// have the field. Consecutive applies by the same modifier are only listed
+// once.
+func nextRecentModifiers(to interface{}, modifier string, cfg *config) (string, []string, bool) {
+	key, ok := recentModifiersKey(to, cfg)
+	if !ok {
+		return "", nil, false
+	}
+
+	target, _ := targetStruct(to)
+	field, _ := structFieldByName(target.Type(), recentModifiersField)
+	current := target.FieldByIndex(field.Index).Interface().([]string)
+	if len(current) > 0 && current[0] == modifier {
+		return key, current, true
+	}
+
+	limit := cfg.maxRecentModifiers
+	if limit > len(current)+1 {
+		limit = len(current) + 1
+	}
+	if limit < 1 {
+		return key, []string{}, true
+	}
+
+	next := make([]string, 0, limit)
+	next = append(next, modifier)
+	next = append(next, current[:limit-1]...)
+	return key, next, true
+}
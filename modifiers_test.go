@@ -0,0 +1,60 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type modifiedRecord struct {
+	BaseStruct
+	Name            string   `json:"name"`
+	RecentModifiers []string `json:"recentModifiers"`
+}
+
+func TestRecentModifiers(t *testing.T) {
+	tests := []struct {
+		name      string
+		modifiers []string
+		opts      []Option
+		want      []string
+	}{
+		{name: "most recent first", modifiers: []string{"A", "B", "C"}, want: []string{"C", "B", "A"}},
+		{name: "consecutive repeats", modifiers: []string{"A", "B", "B", "A"}, want: []string{"A", "B", "A"}},
+		{name: "default cap", modifiers: []string{"A", "B", "C", "D", "E", "F"}, want: []string{"F", "E", "D", "C", "B"}},
+		{name: "configured cap", modifiers: []string{"A", "B", "C"}, opts: []Option{WithMaxRecentModifiers(2)}, want: []string{"C", "B"}},
+		{name: "zero cap", modifiers: []string{"A", "B"}, opts: []Option{WithMaxRecentModifiers(0)}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := modifiedRecord{}
+			for _, modifier := range tt.modifiers {
+				if _, err := ApplyChangesWrapper(map[string]interface{}{"name": modifier}, modifier, &record, tt.opts...); err != nil {
+					t.Fatalf("ApplyChangesWrapper() error = %v", err)
+				}
+			}
+
+			if !reflect.DeepEqual(record.RecentModifiers, tt.want) {
+				t.Errorf("RecentModifiers = %v, want %v", record.RecentModifiers, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecentModifiersWrite(t *testing.T) {
+	for _, key := range []string{"recentModifiers", "RecentModifiers"} {
+		record := modifiedRecord{RecentModifiers: []string{"A"}}
+		_, err := ApplyChangesWrapper(map[string]interface{}{key: []interface{}{"Z"}}, "B", &record)
+		if err == nil {
+			t.Errorf("ApplyChangesWrapper(%s) error = nil, want it rejected", key)
+		}
+		if !reflect.DeepEqual(record.RecentModifiers, []string{"A"}) || record.ModifiedBy != nil {
+			t.Errorf("record = %+v, want it untouched", record)
+		}
+	}
+
+	record := nestedRecord{}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"name": "x"}, "A", &record); err != nil {
+		t.Errorf("ApplyChangesWrapper() error = %v for a target without RecentModifiers", err)
+	}
+}
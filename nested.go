@@ -0,0 +1,99 @@
+package applychanges
+
+import (
+	"reflect"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+var unmarshalerType = reflect.TypeOf((*graphql.Unmarshaler)(nil)).Elem()
+
+// prepareNestedChanges walks the changes alongside the destination struct and
+// settles pointer-to-struct fields before mapstructure sees them.
+//
+// Left to mapstructure, an explicit null clears the pointer only when
+// ZeroFields is set and is ignored otherwise, and any nested map allocates a
+// struct for a nil pointer, even one sanitization left with nothing to set.
+// Here we:
+//   - set the pointer to nil on an explicit null (and drop the key), whatever
+//     ZeroFields says
+//   - drop nested maps that are empty after sanitization, without allocating
+//   - drop nested maps for a nil pointer that only clear fields (such as
+//     {"source": ""}, which Sanitize turns into a null), since there is
+//     nothing to clear
+//   - allocate a fresh struct for a nil pointer receiving any other map, so
+//     the decoder merges into it
+//
+// The keys of the pointers it cleared itself are returned.
+func prepareNestedChanges(changes map[string]interface{}, dest reflect.Value, tagName string) []string {
+	var cleared []string
+
+	for key, value := range changes {
+		field, ok := fieldByTag(dest, tagName, key)
+		if !ok || !field.CanSet() {
+			continue
+		}
+
+		fieldType := field.Type()
+		isStructPtr := fieldType.Kind() == reflect.Ptr && fieldType.Elem().Kind() == reflect.Struct
+		isStruct := fieldType.Kind() == reflect.Struct
+		if !isStructPtr && !isStruct {
+			continue
+		}
+
+		if value == nil {
+			if isStructPtr {
+				field.Set(reflect.Zero(fieldType))
+				delete(changes, key)
+				cleared = append(cleared, key)
+			}
+			continue
+		}
+
+		// Custom scalars decide for themselves what any other input means
+		if isScalar(fieldType) || (isStructPtr && isScalar(fieldType.Elem())) {
+			continue
+		}
+
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		Sanitize(nested)
+		if len(nested) == 0 {
+			delete(changes, key)
+			continue
+		}
+
+		if isStructPtr {
+			if field.IsNil() && onlyClears(nested) {
+				delete(changes, key)
+				continue
+			}
+			if field.IsNil() {
+				field.Set(reflect.New(fieldType.Elem()))
+			}
+			field = field.Elem()
+		}
+
+		prepareNestedChanges(nested, field, tagName)
+	}
+
+	return cleared
+}
+
+// onlyClears reports whether every value in the nested changes is a null, or
+// a nested map that itself only clears
+func onlyClears(nested map[string]interface{}) bool {
+	for _, value := range nested {
+		if value == nil {
+			continue
+		}
+		if inner, ok := value.(map[string]interface{}); ok && onlyClears(inner) {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type nestedDetails struct {
+	Source string `json:"source"`
+	Level  int    `json:"level"`
+}
+
+type nestedRecord struct {
+	BaseStruct
+	Name                string         `json:"name"`
+	Count               int            `json:"count"`
+	Details             *nestedDetails `json:"details"`
+	Inline              nestedDetails  `json:"inline"`
+	ModifiedByPrincipal *Principal     `json:"modifiedByPrincipal"`
+}
+
+func TestPrepareNestedChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  nestedRecord
+		changes map[string]interface{}
+		opts    []Option
+		want    *nestedDetails
+		inline  nestedDetails
+	}{
+		{
+			name:    "allocates a nil pointer for a non-empty map",
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": "radar"}},
+			want:    &nestedDetails{Source: "radar"},
+		},
+		{
+			name:    "merges into an existing pointer",
+			record:  nestedRecord{Details: &nestedDetails{Source: "radar", Level: 2}},
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": "satellite"}},
+			want:    &nestedDetails{Source: "satellite", Level: 2},
+		},
+		{
+			name:    "clears a pointer on an explicit null",
+			record:  nestedRecord{Details: &nestedDetails{Source: "radar"}},
+			changes: map[string]interface{}{"details": nil},
+		},
+		{
+			name:    "leaves a nil pointer nil for an empty map",
+			changes: map[string]interface{}{"details": map[string]interface{}{}},
+		},
+		{
+			name:    "clears a nested field on an empty string",
+			record:  nestedRecord{Details: &nestedDetails{Source: "radar", Level: 2}},
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": ""}},
+			want:    &nestedDetails{Level: 2},
+		},
+		{
+			name:    "leaves a nil pointer nil for a map that only clears",
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": ""}},
+		},
+		{
+			name:    "clears a pointer on an explicit null without ZeroFields",
+			record:  nestedRecord{Details: &nestedDetails{Source: "radar"}},
+			changes: map[string]interface{}{"details": nil},
+			opts:    []Option{WithZeroFields(false)},
+		},
+		{
+			name:    "merges into a struct value",
+			record:  nestedRecord{Inline: nestedDetails{Level: 3}},
+			changes: map[string]interface{}{"inline": map[string]interface{}{"source": "radar"}},
+			inline:  nestedDetails{Source: "radar", Level: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			if _, err := ApplyChanges(tt.changes, &record, tt.opts...); err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(record.Details, tt.want) {
+				t.Errorf("Details = %+v, want %+v", record.Details, tt.want)
+			}
+			if record.Inline != tt.inline {
+				t.Errorf("Inline = %+v, want %+v", record.Inline, tt.inline)
+			}
+		})
+	}
+}
+
+func TestFailedApplyLeavesTargetUnchanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  nestedRecord
+		changes map[string]interface{}
+	}{
+		{
+			name:    "allocated pointer",
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": "radar"}, "count": "many"},
+		},
+		{
+			name:    "merged pointer",
+			record:  nestedRecord{Details: &nestedDetails{Source: "radar", Level: 2}},
+			changes: map[string]interface{}{"details": map[string]interface{}{"level": 5}, "count": "many"},
+		},
+		{
+			name:    "cleared pointer",
+			record:  nestedRecord{Details: &nestedDetails{Source: "radar"}},
+			changes: map[string]interface{}{"details": nil, "count": "many"},
+		},
+		{
+			name:    "unknown key",
+			record:  nestedRecord{Name: "before"},
+			changes: map[string]interface{}{"name": "after", "inline": map[string]interface{}{"level": 1}, "bogus": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			details := record.Details
+			want := tt.record
+			if details != nil {
+				want.Details = &nestedDetails{}
+				*want.Details = *details
+			}
+
+			principal := Principal{ID: "EUA1", Name: "Ada", Role: "admin"}
+			if _, err := ApplyChangesAs(tt.changes, principal, &record); err == nil {
+				t.Fatal("ApplyChangesAs() error = nil, want an error")
+			}
+
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("record = %+v, want %+v", record, want)
+			}
+			if details != nil && record.Details != details {
+				t.Error("Details was replaced rather than restored in place")
+			}
+		})
+	}
+}
+
+// taggedRecord has a map field next to a struct nested two levels deep
+type taggedRecord struct {
+	Tags    map[string]interface{} `json:"tags"`
+	Station struct {
+		Name    string        `json:"name"`
+		Details nestedDetails `json:"details"`
+	} `json:"station"`
+}
+
+func TestNestedMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  map[string]interface{}
+		opts     []Option
+		wantTags map[string]interface{}
+		wantName string
+		want     nestedDetails
+	}{
+		{
+			name:     "map field replaced",
+			changes:  map[string]interface{}{"tags": map[string]interface{}{"region": "south"}},
+			wantTags: map[string]interface{}{"region": "south"},
+			wantName: "Tampa",
+			want:     nestedDetails{Source: "radar", Level: 2},
+		},
+		{
+			name:     "map field merged without ZeroFields",
+			changes:  map[string]interface{}{"tags": map[string]interface{}{"region": "south"}},
+			opts:     []Option{WithZeroFields(false)},
+			wantTags: map[string]interface{}{"region": "south", "owner": "noaa"},
+			wantName: "Tampa",
+			want:     nestedDetails{Source: "radar", Level: 2},
+		},
+		{
+			name:     "struct merged two levels deep",
+			changes:  map[string]interface{}{"station": map[string]interface{}{"details": map[string]interface{}{"level": 5}}},
+			wantTags: map[string]interface{}{"owner": "noaa"},
+			wantName: "Tampa",
+			want:     nestedDetails{Source: "radar", Level: 5},
+		},
+		{
+			name:     "struct merged without ZeroFields",
+			changes:  map[string]interface{}{"station": map[string]interface{}{"name": "Miami"}},
+			opts:     []Option{WithZeroFields(false)},
+			wantTags: map[string]interface{}{"owner": "noaa"},
+			wantName: "Miami",
+			want:     nestedDetails{Source: "radar", Level: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := taggedRecord{Tags: map[string]interface{}{"owner": "noaa"}}
+			record.Station.Name = "Tampa"
+			record.Station.Details = nestedDetails{Source: "radar", Level: 2}
+
+			if _, err := ApplyChanges(tt.changes, &record, tt.opts...); err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(record.Tags, tt.wantTags) {
+				t.Errorf("Tags = %v, want %v", record.Tags, tt.wantTags)
+			}
+			if record.Station.Name != tt.wantName || record.Station.Details != tt.want {
+				t.Errorf("Station = %+v, want %s with %+v", record.Station, tt.wantName, tt.want)
+			}
+		})
+	}
+}
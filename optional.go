@@ -0,0 +1,109 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Optional is a field type that records which of three states its last change
+// was in: absent (the zero Optional, the key wasn't in any changes), null, or
+// set to a value. Unlike other fields, an empty string is a value for an
+// Optional[string] rather than being sanitized into null, so "clear this" and
+// "set this to empty" stay apart.
+type Optional[T any] struct {
+	value T
+	set   bool
+	null  bool
+}
+
+// Some returns an Optional set to value
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{value: value, set: true}
+}
+
+// Null returns an Optional that was explicitly set to null
+func Null[T any]() Optional[T] {
+	return Optional[T]{set: true, null: true}
+}
+
+// IsSet reports whether the Optional was given in the changes at all, as null
+// or as a value
+func (o Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// IsNull reports whether the Optional was explicitly set to null
+func (o Optional[T]) IsNull() bool {
+	return o.null
+}
+
+// Get returns the value and whether the Optional holds one (it isn't absent or
+// null)
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.set && !o.null
+}
+
+// MarshalJSON encodes the value, or null when there isn't one
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if value, ok := o.Get(); ok {
+		return json.Marshal(value)
+	}
+
+	return []byte("null"), nil
+}
+
+// setChange records a change, decoding a non-null value into T
+func (o *Optional[T]) setChange(value interface{}, decode func(input interface{}, result interface{}) error) error {
+	var zero T
+	o.value, o.set, o.null = zero, true, value == nil
+	if value == nil {
+		return nil
+	}
+
+	return decode(value, &o.value)
+}
+
+// valueType returns T
+func (o *Optional[T]) valueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// optionalField is implemented by pointers to every Optional
+type optionalField interface {
+	setChange(value interface{}, decode func(input interface{}, result interface{}) error) error
+	valueType() reflect.Type
+}
+
+var optionalFieldType = reflect.TypeOf((*optionalField)(nil)).Elem()
+
+// prepareOptionals replaces the changes to Optional fields (including those of
+// nested structs) with the Optional itself, before the changes are sanitized
+func prepareOptionals(changes map[string]interface{}, structType reflect.Type, cfg *config) error {
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, cfg.tagName, key)
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		if reflect.PtrTo(fieldType).Implements(optionalFieldType) {
+			optional := reflect.New(fieldType)
+			if err := optional.Interface().(optionalField).setChange(value, cfg.decode); err != nil {
+				return err
+			}
+			changes[key] = optional.Elem().Interface()
+			continue
+		}
+
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if nested, ok := value.(map[string]interface{}); ok && fieldType.Kind() == reflect.Struct {
+			if err := prepareOptionals(nested, fieldType, cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type optionalDetails struct {
+	Note Optional[string] `json:"note"`
+}
+
+type optionalRecord struct {
+	Nickname Optional[string]   `json:"nickname"`
+	Limit    Optional[int]      `json:"limit"`
+	Details  optionalDetails    `json:"details"`
+	Aliases  Optional[[]string] `json:"aliases"`
+}
+
+func TestOptional(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    optionalRecord
+		wantErr bool
+	}{
+		{
+			name:    "absent",
+			changes: map[string]interface{}{},
+			want:    optionalRecord{Limit: Some(5)},
+		},
+		{
+			name:    "set",
+			changes: map[string]interface{}{"nickname": "Ada", "limit": 10},
+			want:    optionalRecord{Nickname: Some("Ada"), Limit: Some(10)},
+		},
+		{
+			name:    "null",
+			changes: map[string]interface{}{"limit": nil},
+			want:    optionalRecord{Limit: Null[int]()},
+		},
+		{
+			name:    "empty string isn't null",
+			changes: map[string]interface{}{"nickname": ""},
+			want:    optionalRecord{Nickname: Some(""), Limit: Some(5)},
+		},
+		{
+			name:    "nested",
+			changes: map[string]interface{}{"details": map[string]interface{}{"note": nil}},
+			want:    optionalRecord{Limit: Some(5), Details: optionalDetails{Note: Null[string]()}},
+		},
+		{
+			name:    "decoded value",
+			changes: map[string]interface{}{"aliases": []interface{}{"a", "b"}},
+			want:    optionalRecord{Limit: Some(5), Aliases: Some([]string{"a", "b"})},
+		},
+		{
+			name:    "undecodable value",
+			changes: map[string]interface{}{"limit": "many"},
+			want:    optionalRecord{Limit: Some(5)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := optionalRecord{Limit: Some(5)}
+			_, err := ApplyChanges(tt.changes, &record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(record, tt.want) {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionalStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		optional  Optional[string]
+		wantSet   bool
+		wantNull  bool
+		wantValue string
+		wantOK    bool
+		wantJSON  string
+	}{
+		{name: "absent", wantJSON: "null"},
+		{name: "null", optional: Null[string](), wantSet: true, wantNull: true, wantJSON: "null"},
+		{name: "empty", optional: Some(""), wantSet: true, wantOK: true, wantJSON: `""`},
+		{name: "value", optional: Some("Ada"), wantSet: true, wantValue: "Ada", wantOK: true, wantJSON: `"Ada"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok := tt.optional.Get()
+			if tt.optional.IsSet() != tt.wantSet || tt.optional.IsNull() != tt.wantNull || value != tt.wantValue || ok != tt.wantOK {
+				t.Errorf("%+v: IsSet() = %v, IsNull() = %v, Get() = %q, %v", tt.optional, tt.optional.IsSet(), tt.optional.IsNull(), value, ok)
+			}
+
+			encoded, err := json.Marshal(tt.optional)
+			if err != nil || string(encoded) != tt.wantJSON {
+				t.Errorf("json.Marshal() = %s, %v, want %s", encoded, err, tt.wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,864 @@
+package applychanges
+
+import (
+	"context"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Option configures how changes are applied
+type Option func(*config)
+
+// config is the resolved set of options for a single apply
+type config struct {
+	tagName     string
+	errorUnused bool
+	zeroFields  bool
+	decodeHooks []mapstructure.DecodeHookFunc
+	dryRun      bool
+	maxDepth    int
+	rollback    bool
+
+	requireChanges bool
+
+	maxDiffValueBytes int
+
+	timings     bool
+	metricsSink MetricsSink
+
+	rateLimiter RateLimiter
+	freeNoOps   bool
+
+	allowedFields      map[string]bool
+	deniedFields       map[string]bool
+	dropDisallowed     bool
+	skipImmutable      bool
+	ignoreImmutable    bool
+	modifiedDts        bool
+	modifiedDtsPolicy  ModifiedDtsPolicy
+	backfillModifiedBy bool
+	maxRecentModifiers int
+	clock              Clock
+
+	immutableFields  map[string]bool
+	adminOnlyFields  map[string]bool
+	policy           Policy
+	fieldLocks       map[string]string
+	fieldLocker      FieldLocker
+	fieldAuthorizer  FieldAuthorizer
+	valueAuthorizers []ValueAuthorizer
+	permissions      map[string]map[string]bool
+	cipher           FieldCipher
+
+	expectedVersion *int64
+	expectedETag    *string
+	rejectDeleted   bool
+	conditions      []Condition
+	auditSink       AuditSink
+	eventPublisher  EventPublisher
+	emitterRetry    *emitterRetry
+	history         *History
+	validator       Validator
+	sliceStrategies map[string]SliceStrategy
+	mergeMaps       bool
+	partialChildren bool
+
+	idempotencyStore  IdempotencyStore
+	idempotencyKey    string
+	contentDerivedKey bool
+
+	schemaMismatchOverride bool
+
+	messageCatalog MessageCatalog
+
+	timeLayouts       []string
+	epochUnit         time.Duration
+	durationUnit      time.Duration
+	timeZone          *time.Location
+	requireTimeOffset bool
+
+	repairUTF8       bool
+	allowInvalidUTF8 bool
+
+	allowNonFiniteFloats bool
+	floatTolerances      map[string]float64
+
+	graphQLCoercion bool
+
+	caseSensitiveKeys  bool
+	snakeCaseKeys      bool
+	fallbackTagNames   []string
+	allowIdenticalKeys bool
+	allowConflicts     bool
+	dualWrites         []dualWrite
+	deprecationHandler DeprecationHandler
+
+	stopOnError        bool
+	ctx                context.Context
+	principalExtractor PrincipalExtractor
+	impersonation      *impersonation
+
+	// deleting stamps the deletion metadata as well, see ApplyDelete
+	deleting bool
+
+	// restoring applies complete, already encrypted values, see Undo
+	restoring bool
+
+	// backfilling only stamps the metadata an entity is missing, see
+	// BackfillMetadata
+	backfilling bool
+
+	// linting applies to throwaway targets without side effects, see
+	// lintConfig
+	linting bool
+
+	// now is the time the apply stamps and audits, read once from the clock
+	now time.Time
+
+	// modifier is stamped as modifiedBy when set, see ApplyChangesWrapper;
+	// principal optionally describes it in full, see ApplyChangesAs
+	modifier  *string
+	principal *Principal
+}
+
+// newConfig resolves the options of an apply without a target type, see
+// configFor
+func newConfig(opts []Option) *config {
+	return configFor(nil, opts)
+}
+
+// WithTagName sets the struct tag change keys are matched against ("json" by
+// default)
+func WithTagName(tagName string) Option {
+	return func(cfg *config) {
+		cfg.tagName = tagName
+	}
+}
+
+// WithFallbackTagNames also matches keys against other struct tags, tried in
+// order when a key doesn't match any field by the tag name (see WithTagName).
+// With WithFallbackTagNames("db"), changes keyed by column name (e.g. from
+// database triggers) apply to the same json-tagged structs.
+func WithFallbackTagNames(tagNames ...string) Option {
+	return func(cfg *config) {
+		cfg.fallbackTagNames = append(cfg.fallbackTagNames, tagNames...)
+	}
+}
+
+// WithErrorUnused sets whether keys that don't match any field are an error
+// (true by default)
+func WithErrorUnused(errorUnused bool) Option {
+	return func(cfg *config) {
+		cfg.errorUnused = errorUnused
+	}
+}
+
+// WithIgnoreUnknownFields ignores keys that don't match any field instead of
+// failing, so clients can send fields a server doesn't know yet; the ignored
+// keys are listed in ApplyResult.UnknownFields. Same as WithErrorUnused(false).
+func WithIgnoreUnknownFields() Option {
+	return WithErrorUnused(false)
+}
+
+// WithUTF8Repair replaces invalid UTF-8 sequences in the strings of the changes
+// (at any depth) with U+FFFD, in place, instead of failing the apply with an
+// InvalidEncodingError, see RepairUTF8
+func WithUTF8Repair() Option {
+	return func(cfg *config) {
+		cfg.repairUTF8 = true
+	}
+}
+
+// WithAllowInvalidUTF8 stores strings that aren't valid UTF-8 as they are,
+// instead of failing the apply with an InvalidEncodingError. WithUTF8Repair
+// wins when both are given.
+func WithAllowInvalidUTF8() Option {
+	return func(cfg *config) {
+		cfg.allowInvalidUTF8 = true
+	}
+}
+
+// WithAllowNonFiniteFloats stores NaN and ±Inf numbers in the changes instead
+// of rejecting them with a NonFiniteFloatError. Wherever an apply is output as
+// JSON (FieldChange, audit entries, ChangeRows) they're the strings "NaN",
+// "+Inf" and "-Inf", and ComputeETag hashes them that way.
+func WithAllowNonFiniteFloats() Option {
+	return func(cfg *config) {
+		cfg.allowNonFiniteFloats = true
+	}
+}
+
+// WithGraphQLCoercion checks the changes against the input coercion rules of
+// the GraphQL spec for the type of each field before they're decoded, the way
+// gqlgen would coerce the variables of a request: Int fields accept integral
+// floats (but not fractional ones), booleans and strings are only accepted
+// for Boolean and String fields, enum fields only accept their exact values,
+// and null only goes into nullable (pointer, slice, map or Optional) fields.
+// The violations are FieldErrors phrased like GraphQL's, e.g. `'count': Int
+// cannot represent non-integer value: 1.5`. Decode hooks don't get to convert
+// the values of built-in types first.
+func WithGraphQLCoercion() Option {
+	return func(cfg *config) {
+		cfg.graphQLCoercion = true
+	}
+}
+
+// WithFloatTolerance treats a change to a float field (or to an element of a
+// slice of floats) within eps of the current value as a no-op, for the given
+// paths (e.g. `readings` or `station.temperature`): the current value is
+// kept, so the change shows no difference. Other floats compare bit for bit,
+// so a change from 0.3 to 0.1+0.2 is a change.
+func WithFloatTolerance(eps float64, paths ...string) Option {
+	return func(cfg *config) {
+		if cfg.floatTolerances == nil {
+			cfg.floatTolerances = map[string]float64{}
+		}
+		for _, path := range paths {
+			cfg.floatTolerances[path] = eps
+		}
+	}
+}
+
+// WithCaseInsensitiveKeys matches keys against the fields' tag names
+// case-insensitively when there's no exact match (as mapstructure does), so
+// "Weather" sets the weather field; LintChanges warns about such keys. By
+// default keys must be exactly a field's tag name, and those only differing in
+// case fail the apply like any unknown key, with the tag name suggested.
+func WithCaseInsensitiveKeys() Option {
+	return func(cfg *config) {
+		cfg.caseSensitiveKeys = false
+	}
+}
+
+// WithCaseSensitiveKeys only matches keys that are exactly a field's tag name,
+// which is the default; it undoes a WithCaseInsensitiveKeys given before it,
+// e.g. registered for the type with RegisterTypeConfig.
+func WithCaseSensitiveKeys() Option {
+	return func(cfg *config) {
+		cfg.caseSensitiveKeys = true
+	}
+}
+
+// WithSnakeCaseKeys accepts keys in either case convention: snake_case keys
+// match camelCase tag names and camelCase keys match snake_case ones, so
+// "city_name" sets a `json:"cityName"` field. Keys are renamed to the tag name
+// before anything else looks at them.
+func WithSnakeCaseKeys() Option {
+	return func(cfg *config) {
+		cfg.snakeCaseKeys = true
+	}
+}
+
+// WithAllowIdenticalKeys lets more than one key address the same field (e.g.
+// "weather" and "Weather") as long as they all carry the same value, instead
+// of failing with a ConflictingKeysError
+func WithAllowIdenticalKeys() Option {
+	return func(cfg *config) {
+		cfg.allowIdenticalKeys = true
+	}
+}
+
+// WithAllowConflictingKeys skips the ConflictingKeysError check altogether,
+// leaving keys that address the same field to the decoder: an exact match
+// wins, any other key for the field is unknown (see WithIgnoreUnknownFields),
+// and between several inexact matches mapstructure picks whichever it comes to
+// first
+func WithAllowConflictingKeys() Option {
+	return func(cfg *config) {
+		cfg.allowConflicts = true
+	}
+}
+
+// WithDualWrite renames the top-level field keyed oldField to newField for a
+// transition period: changes to either key are written to both fields, but
+// reported (and audited) as changes to newField alone. Both fields must exist
+// on the target. Changes keyed by oldField are reported to the
+// WithDeprecationHandler, and rejected once the cutover (which may be nil)
+// says so. Renames add up.
+func WithDualWrite(oldField, newField string, cutover *DualWriteCutover) Option {
+	return func(cfg *config) {
+		cfg.dualWrites = append(cfg.dualWrites, dualWrite{oldField: oldField, newField: newField, cutover: cutover})
+	}
+}
+
+// WithDeprecationHandler is told about the deprecated keys the changes use,
+// such as the old keys of WithDualWrite
+func WithDeprecationHandler(handler DeprecationHandler) Option {
+	return func(cfg *config) {
+		cfg.deprecationHandler = handler
+	}
+}
+
+// WithMaxApplyDepth rejects changes addressing fields more than n levels deep
+// with a DepthExceededError, before any of them are applied: top-level keys
+// are one level deep, and each merge into a nested struct one more (the keys
+// of a nested map for a struct field, or an element of a slice of structs).
+// The keys of a map-valued field don't count. E.g. WithMaxApplyDepth(2) allows
+// {"details": {"source": "radar"}} but not {"contacts": [{"name": "Ada"}]}.
+// There is no limit by default.
+func WithMaxApplyDepth(n int) Option {
+	return func(cfg *config) {
+		cfg.maxDepth = n
+	}
+}
+
+// WithRollback sets whether a failed apply puts the target back the way it was
+// (true by default). Without it, whatever was decoded before the failure stays
+// on the target, as mapstructure leaves it; the changes a ValueAuthorizer
+// rejects are still never assigned (see WithValueAuthorizer).
+func WithRollback(rollback bool) Option {
+	return func(cfg *config) {
+		cfg.rollback = rollback
+	}
+}
+
+// WithZeroFields sets whether null values and maps are decoded by zeroing the
+// destination first (true by default), see mapstructure.DecoderConfig
+func WithZeroFields(zeroFields bool) Option {
+	return func(cfg *config) {
+		cfg.zeroFields = zeroFields
+	}
+}
+
+// WithModifiedDts sets whether ApplyChangesWrapper stamps the current time into
+// the target's ModifiedDts field (true by default)
+func WithModifiedDts(modifiedDts bool) Option {
+	return func(cfg *config) {
+		cfg.modifiedDts = modifiedDts
+	}
+}
+
+// WithModifiedDtsPolicy sets what ApplyChangesWrapper does when the changes
+// supply their own modifiedDts (RejectModifiedDts by default)
+func WithModifiedDtsPolicy(policy ModifiedDtsPolicy) Option {
+	return func(cfg *config) {
+		cfg.modifiedDtsPolicy = policy
+	}
+}
+
+// WithBackfillModifiedBy has BackfillMetadata fill in a missing ModifiedBy
+// with the modifier of its fallback too; it's left alone otherwise
+func WithBackfillModifiedBy() Option {
+	return func(cfg *config) {
+		cfg.backfillModifiedBy = true
+	}
+}
+
+// WithMaxRecentModifiers sets how many modifiers are kept in a target's
+// RecentModifiers field (5 by default); the oldest are dropped first
+func WithMaxRecentModifiers(n int) Option {
+	return func(cfg *config) {
+		cfg.maxRecentModifiers = n
+	}
+}
+
+// WithClock sets the Clock modifiedDts is stamped from (the system time by
+// default)
+func WithClock(clock Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = clock
+	}
+}
+
+// WithExpectedVersion fails the apply with a ConflictError unless the target's
+// LockVersion field is at the given version (the changes can also carry the
+// expected version as their LockVersion key). The version is incremented by
+// every ApplyChangesWrapper call.
+func WithExpectedVersion(version int64) Option {
+	return func(cfg *config) {
+		cfg.expectedVersion = &version
+	}
+}
+
+// WithExpectedETag fails the apply with an ErrETagMismatch unless the target's
+// current ETag (see ComputeETag) is the given one, for HTTP APIs taking an
+// If-Match header. The ETag may be given quoted, as in the header.
+func WithExpectedETag(etag string) Option {
+	return func(cfg *config) {
+		cfg.expectedETag = &etag
+	}
+}
+
+// WithRejectDeleted fails the apply with ErrDeleted when the target has been
+// soft-deleted (its DeletedDts field is set)
+func WithRejectDeleted() Option {
+	return func(cfg *config) {
+		cfg.rejectDeleted = true
+	}
+}
+
+// WithCondition only applies the changes when the target meets the condition,
+// read from its current field values before anything is changed; otherwise the
+// apply fails with a ConditionError naming the clause it didn't meet. Given
+// more than once, every condition must be met.
+func WithCondition(condition Condition) Option {
+	return func(cfg *config) {
+		cfg.conditions = append(cfg.conditions, condition)
+	}
+}
+
+// WithValidator validates the target once the changes have been decoded onto
+// it. When the validator fails it, the whole target is put back the way it
+// was (including fields an AfterApplier derived), so a failed apply leaves the
+// target as it was.
+func WithValidator(validator Validator) Option {
+	return func(cfg *config) {
+		cfg.validator = validator
+	}
+}
+
+// WithAuditSink records an AuditEntry in the sink after every successful
+// ApplyChangesWrapper call, once any EventPublisher has published it (dry runs
+// aren't recorded)
+func WithAuditSink(sink AuditSink) Option {
+	return func(cfg *config) {
+		cfg.auditSink = sink
+	}
+}
+
+// WithEventPublisher publishes a ChangeApplied event to the publisher after
+// every successful apply, before any AuditSink records it; dry runs aren't
+// published
+func WithEventPublisher(publisher EventPublisher) Option {
+	return func(cfg *config) {
+		cfg.eventPublisher = publisher
+	}
+}
+
+// WithEmitterRetry tries the AuditSink and EventPublisher up to attempts times
+// before an apply gives up on them (and fails, leaving the target as it was),
+// waiting backoff(n) after the nth failed attempt; a nil backoff retries right
+// away. Retrying stops early when the apply's context is done, or its deadline
+// would pass before the next attempt.
+func WithEmitterRetry(attempts int, backoff func(attempt int) time.Duration) Option {
+	return func(cfg *config) {
+		cfg.emitterRetry = &emitterRetry{attempts: attempts, backoff: backoff}
+	}
+}
+
+// WithHistory keeps a version of the target in the history after every
+// successful apply (dry runs aren't kept), along with its state before the
+// first one
+func WithHistory(history *History) Option {
+	return func(cfg *config) {
+		cfg.history = history
+	}
+}
+
+// WithIdempotencyStore sets the store the idempotency keys of applies are
+// looked up in and saved to, see WithIdempotencyKey
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(cfg *config) {
+		cfg.idempotencyStore = store
+	}
+}
+
+// WithIdempotencyKey makes the apply idempotent under the key, e.g. one sent by
+// the client with the request: when the IdempotencyStore already holds a
+// result for it, that result is returned and nothing is applied
+func WithIdempotencyKey(key string) Option {
+	return func(cfg *config) {
+		cfg.idempotencyKey = key
+	}
+}
+
+// WithContentDerivedKey makes the apply idempotent under a key derived from the
+// target's type and ID and the HashChanges of the changes, for clients that
+// don't send a key of their own. Applying the same changes to the same target
+// again then counts as a repeat, for as long as the store keeps the key. An
+// explicit WithIdempotencyKey takes precedence.
+func WithContentDerivedKey() Option {
+	return func(cfg *config) {
+		cfg.contentDerivedKey = true
+	}
+}
+
+// WithSchemaMismatchOverride lets ApplyEnvelope apply an envelope built
+// against another schema of the target type, see SchemaMismatchError
+func WithSchemaMismatchOverride() Option {
+	return func(cfg *config) {
+		cfg.schemaMismatchOverride = true
+	}
+}
+
+// WithMessageCatalog localizes the messages of the errors the apply returns
+// with the catalog, for NewErrorResponse (and so WriteErrorResponse and
+// GraphQLErrorPresenter) to present them in; their Error strings, for logs,
+// stay the same. The errors are wrapped, so look for the package's errors in
+// them with errors.As and errors.Is.
+func WithMessageCatalog(catalog MessageCatalog) Option {
+	return func(cfg *config) {
+		cfg.messageCatalog = catalog
+	}
+}
+
+// WithContext sets the context the apply runs under (context.Background by
+// default), see ApplyChangesWrapperCtx
+func WithContext(ctx context.Context) Option {
+	return func(cfg *config) {
+		cfg.ctx = ctx
+	}
+}
+
+// WithImpersonation applies the changes as onBehalfOf, who is stamped as
+// modifiedBy, while the AuditEntry records the actualActor who applied them
+// and why. The modifier of the apply must be empty (e.g.
+// ApplyChangesWrapper(changes, "", to, WithImpersonation(...)) and all three
+// must be given, or the apply fails with ErrImpersonationModifier or
+// ErrImpersonationReason.
+func WithImpersonation(actualActor, onBehalfOf, reason string) Option {
+	return func(cfg *config) {
+		cfg.impersonation = &impersonation{actualActor: actualActor, onBehalfOf: onBehalfOf, reason: reason}
+	}
+}
+
+// WithPrincipalExtractor sets how ApplyChangesCtx finds the principal in its
+// context, instead of looking for one stored by ContextWithPrincipal
+func WithPrincipalExtractor(extract PrincipalExtractor) Option {
+	return func(cfg *config) {
+		cfg.principalExtractor = extract
+	}
+}
+
+// WithSliceStrategy sets how changes to the slice field at path (its tag name,
+// or e.g. "details.levels" for a nested struct's field) are combined with its
+// current elements, overriding its apply tag
+func WithSliceStrategy(path string, strategy SliceStrategy) Option {
+	return func(cfg *config) {
+		if cfg.sliceStrategies == nil {
+			cfg.sliceStrategies = map[string]SliceStrategy{}
+		}
+		cfg.sliceStrategies[path] = strategy
+	}
+}
+
+// WithMergeMaps merges changes to map fields into the current map, with null
+// removing an entry, instead of replacing the whole map (as JSON merge patches
+// do). Single map fields can opt in with `apply:"merge"`.
+func WithMergeMaps() Option {
+	return func(cfg *config) {
+		cfg.mergeMaps = true
+	}
+}
+
+// WithPartialChildren applies the rest of a child collection keyed by ID when
+// some of its children fail (e.g. for an unknown ID), leaving those as they
+// were and reporting them in the result's SkippedFields, rather than failing
+// the apply with all of them
+func WithPartialChildren() Option {
+	return func(cfg *config) {
+		cfg.partialChildren = true
+	}
+}
+
+// WithStopOnError makes ApplyBatch (and ApplyToAll) stop at the first item that
+// fails, instead of applying the rest regardless
+func WithStopOnError() Option {
+	return func(cfg *config) {
+		cfg.stopOnError = true
+	}
+}
+
+// WithDecodeHook adds a decode hook, called after any registered with
+// RegisterDecodeHook and before the built-in time.Time, time.Duration,
+// uuid.UUID, decimal.Decimal and graphql.Unmarshaler conversions. Hooks run in
+// the order they were added, each receiving the previous one's output.
+func WithDecodeHook(hook mapstructure.DecodeHookFunc) Option {
+	return func(cfg *config) {
+		cfg.decodeHooks = append(cfg.decodeHooks, hook)
+	}
+}
+
+// WithRequireChanges fails an apply with ErrNoChanges when none of its changes
+// are left to apply, e.g. because a decode hook skipped them all (see
+// SkipField) or WithDropDisallowedFields dropped them
+func WithRequireChanges() Option {
+	return func(cfg *config) {
+		cfg.requireChanges = true
+	}
+}
+
+// WithTimeLayouts also accepts strings in the given layouts (see time.Parse)
+// for time.Time fields, tried in order after RFC3339Nano, for clients that
+// don't send RFC3339. Layouts without a zone are read as UTC.
+func WithTimeLayouts(layouts ...string) Option {
+	return func(cfg *config) {
+		cfg.timeLayouts = append(cfg.timeLayouts, layouts...)
+	}
+}
+
+// WithEpochTimes accepts numbers for time.Time fields, read as a count of unit
+// since the Unix epoch: WithEpochTimes(time.Second) for Unix timestamps,
+// WithEpochTimes(time.Millisecond) for JavaScript's. Fractional numbers keep
+// their fraction of a unit; the times are in UTC.
+func WithEpochTimes(unit time.Duration) Option {
+	return func(cfg *config) {
+		cfg.epochUnit = unit
+	}
+}
+
+// WithNormalizeTimeZone converts the times read for time.Time fields (from
+// strings or, with WithEpochTimes, numbers) into loc before they're assigned,
+// e.g. WithNormalizeTimeZone(time.UTC), so times sent with different offsets
+// compare and sort as instants. Strings in a layout without an offset are read
+// in loc. time.Time values in the changes are assigned as they are.
+func WithNormalizeTimeZone(loc *time.Location) Option {
+	return func(cfg *config) {
+		cfg.timeZone = loc
+	}
+}
+
+// WithRequireTimeOffset rejects strings for time.Time fields that only parse
+// in a layout without a zone offset (see WithTimeLayouts), rather than guessing
+// their time zone
+func WithRequireTimeOffset() Option {
+	return func(cfg *config) {
+		cfg.requireTimeOffset = true
+	}
+}
+
+// WithDurationUnit reads numbers for time.Duration fields as a count of unit,
+// e.g. WithDurationUnit(time.Second) for clients sending seconds, rather than
+// as nanoseconds. Strings like "1h30m" are read with time.ParseDuration either
+// way.
+func WithDurationUnit(unit time.Duration) Option {
+	return func(cfg *config) {
+		cfg.durationUnit = unit
+	}
+}
+
+// WithMaxDiffValueBytes caps the strings and byte slices captured as the Old
+// and New values of a FieldChange at n bytes, for targets with large text or
+// blob fields: longer values are cut at n bytes and end with a marker giving
+// how many more there were, and the change is marked Truncated. Only the
+// changes in the result, audit entry and event are truncated: ValueAuthorizers
+// and AfterAppliers still see the whole values. A result with truncated
+// changes can't be undone or turned into SQL, and an audit entry holding them
+// can't be replayed.
+func WithMaxDiffValueBytes(n int) Option {
+	return func(cfg *config) {
+		cfg.maxDiffValueBytes = n
+	}
+}
+
+// WithTimings records how long each stage of the apply took (on the Clock) in
+// ApplyResult.Timings, keyed by the TimingStages, for finding what makes an
+// apply slow. Applies aren't timed otherwise, and their Timings are nil.
+func WithTimings() Option {
+	return func(cfg *config) {
+		cfg.timings = true
+	}
+}
+
+// WithMetricsSink hands the stage timings of every successful apply to the
+// sink, see WithTimings (which it implies)
+func WithMetricsSink(sink MetricsSink) Option {
+	return func(cfg *config) {
+		cfg.metricsSink = sink
+	}
+}
+
+// WithRateLimiter asks the limiter to allow every apply to an entity (a target
+// with a uuid.UUID ID) before doing anything else, failing with a
+// RateLimitedError when it doesn't. Dry runs aren't limited. Applies changing
+// nothing count against the limit too, unless WithFreeNoOps is given.
+func WithRateLimiter(rl RateLimiter) Option {
+	return func(cfg *config) {
+		cfg.rateLimiter = rl
+	}
+}
+
+// WithFreeNoOps leaves the applies changing no field (apart from the stamped
+// metadata) out of the limit of WithRateLimiter. The limiter is then asked
+// once the changes have been decoded rather than first; a denied apply is
+// undone like any other failing one.
+func WithFreeNoOps() Option {
+	return func(cfg *config) {
+		cfg.freeNoOps = true
+	}
+}
+
+// WithDryRun runs the whole apply against a copy of the target, leaving the
+// target itself untouched; the result describes what the apply would have done
+func WithDryRun() Option {
+	return func(cfg *config) {
+		cfg.dryRun = true
+	}
+}
+
+// WithAllowedFields limits the changes to the given fields (by tag name); any
+// other key is rejected with a DisallowedFieldsError, or dropped with
+// WithDropDisallowedFields. Stamped metadata is always allowed.
+func WithAllowedFields(fields ...string) Option {
+	return func(cfg *config) {
+		if cfg.allowedFields == nil {
+			cfg.allowedFields = map[string]bool{}
+		}
+		for _, field := range fields {
+			cfg.allowedFields[field] = true
+		}
+	}
+}
+
+// WithDeniedFields rejects changes to the given fields (by tag name) with a
+// DisallowedFieldsError, or drops them with WithDropDisallowedFields
+func WithDeniedFields(fields ...string) Option {
+	return func(cfg *config) {
+		if cfg.deniedFields == nil {
+			cfg.deniedFields = map[string]bool{}
+		}
+		for _, field := range fields {
+			cfg.deniedFields[field] = true
+		}
+	}
+}
+
+// WithDropDisallowedFields silently drops keys excluded by WithAllowedFields or
+// WithDeniedFields instead of failing; they are listed in
+// ApplyResult.DroppedFields
+func WithDropDisallowedFields() Option {
+	return func(cfg *config) {
+		cfg.dropDisallowed = true
+	}
+}
+
+// WithSkipImmutableFields silently drops keys addressing `apply:"immutable"`
+// fields instead of failing with an ImmutableFieldError; they are listed in
+// ApplyResult.DroppedFields
+func WithSkipImmutableFields() Option {
+	return func(cfg *config) {
+		cfg.skipImmutable = true
+	}
+}
+
+// WithIgnoreImmutableTags lets changes set fields tagged `apply:"immutable"`
+// (such as BaseStruct's ID and creation fields) like any other; fields named
+// by WithImmutableFields stay immutable
+func WithIgnoreImmutableTags() Option {
+	return func(cfg *config) {
+		cfg.ignoreImmutable = true
+	}
+}
+
+// WithImmutableFields treats the fields at the given paths (tag names, e.g.
+// "source" or "details.source" for a nested struct's field) as if they were
+// tagged `apply:"immutable"`, for rules declared outside the struct such as a
+// GraphQL schema's @immutable directive
+func WithImmutableFields(paths ...string) Option {
+	return func(cfg *config) {
+		if cfg.immutableFields == nil {
+			cfg.immutableFields = map[string]bool{}
+		}
+		for _, path := range paths {
+			cfg.immutableFields[path] = true
+		}
+	}
+}
+
+// WithApplyPolicy checks the changes as a whole against the policy before
+// anything is applied, and before the fields are authorized one by one (see
+// WithFieldAuthorizer); an apply the policy rejects fails with a PolicyError
+func WithApplyPolicy(policy Policy) Option {
+	return func(cfg *config) {
+		cfg.policy = policy
+	}
+}
+
+// WithFieldLocks rejects changes to the fields locked by other principals, by
+// field path (of tag names, e.g. "address.city") to the ID of the principal
+// holding the lock, compared with the apply's principal (or modifier). They
+// are checked after the immutable fields, before anything is decoded, failing
+// with FieldLockedErrors listing every locked field changed; changes to the
+// fields the principal locks itself, or nobody does, are applied as usual.
+func WithFieldLocks(locks map[string]string) Option {
+	return func(cfg *config) {
+		cfg.fieldLocks = locks
+	}
+}
+
+// WithFieldLocker checks the locks the locker returns for the entity, like
+// WithFieldLocks (and on top of its locks): it's given the apply's context and
+// the target before any change
+func WithFieldLocker(locker FieldLocker) Option {
+	return func(cfg *config) {
+		cfg.fieldLocker = locker
+	}
+}
+
+// WithFieldAuthorizer checks every key of the changes (after the allowed,
+// denied and immutable field checks, before anything is decoded) with the
+// authorizer, failing with a ForbiddenFieldsError listing all the fields it
+// rejects. The authorizer is given the apply's context and principal (see
+// ApplyChangesAs and ApplyChangesCtx).
+func WithFieldAuthorizer(authorizer FieldAuthorizer) Option {
+	return func(cfg *config) {
+		cfg.fieldAuthorizer = authorizer
+	}
+}
+
+// WithValueAuthorizer checks every applied field (apart from the stamped
+// metadata) with the authorizer, given the field's typed value before and
+// after the changes, failing with a ForbiddenFieldsError listing all the
+// fields it rejects. It runs once the changes have been decoded onto a copy of
+// the target, so errors converting them (e.g. a malformed time) are returned
+// before the authorizer sees anything, and the copy is only assigned to the
+// target once every field passes. Nested changes are checked as the whole
+// nested struct, by its top-level path.
+func WithValueAuthorizer(authorize func(change FieldChange, principal Principal) error) Option {
+	return WithValueAuthorizerCtx(func(_ context.Context, change FieldChange, principal Principal) error {
+		return authorize(change, principal)
+	})
+}
+
+// WithValueAuthorizerCtx is WithValueAuthorizer for authorizers that need the
+// apply's context, e.g. policy engines doing I/O
+func WithValueAuthorizerCtx(authorizer ValueAuthorizer) Option {
+	return func(cfg *config) {
+		cfg.valueAuthorizers = append(cfg.valueAuthorizers, authorizer)
+	}
+}
+
+// WithFieldPermissions only lets principals change the fields listed for the
+// role they act in (see ApplyChangesAs); changes to any other field fail with
+// a ForbiddenFieldsError, along with those rejected by WithFieldAuthorizer.
+// Given more than once, the permissions of each role are combined.
+func WithFieldPermissions(permissions FieldPermissions) Option {
+	return func(cfg *config) {
+		if cfg.permissions == nil {
+			cfg.permissions = map[string]map[string]bool{}
+		}
+		for role, fields := range permissions {
+			if cfg.permissions[role] == nil {
+				cfg.permissions[role] = map[string]bool{}
+			}
+			for _, field := range fields {
+				cfg.permissions[role][field] = true
+			}
+		}
+	}
+}
+
+// WithFieldCipher encrypts changes to `apply:"encrypt"` fields with the cipher
+// before they're decoded, storing the base64-encoded ciphertext; the plaintext
+// is read back with Decrypted. Hooks (BeforeApplier) still see the plaintext,
+// the diff and audit entries only the ciphertext.
+func WithFieldCipher(cipher FieldCipher) Option {
+	return func(cfg *config) {
+		cfg.cipher = cipher
+	}
+}
+
+// WithAdminOnlyFields only lets principals acting as AdminRole change the given
+// fields (by tag name), see ApplyChangesAs; anyone else is rejected with a
+// DisallowedFieldsError, or has the keys dropped with WithDropDisallowedFields
+func WithAdminOnlyFields(fields ...string) Option {
+	return func(cfg *config) {
+		if cfg.adminOnlyFields == nil {
+			cfg.adminOnlyFields = map[string]bool{}
+		}
+		for _, field := range fields {
+			cfg.adminOnlyFields[field] = true
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type optionsRecord struct {
+	City   string            `json:"city" db:"city_name"`
+	Labels map[string]string `json:"labels" db:"labels"`
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		opts        []Option
+		want        optionsRecord
+		wantUnknown []string
+		wantErr     bool
+	}{
+		{
+			name:    "defaults",
+			changes: map[string]interface{}{"city": "Tampa", "labels": map[string]interface{}{"b": "2"}},
+			want:    optionsRecord{City: "Tampa", Labels: map[string]string{"b": "2"}},
+		},
+		{
+			name:    "unknown keys fail by default",
+			changes: map[string]interface{}{"city": "Tampa", "zip": "33602"},
+			wantErr: true,
+		},
+		{
+			name:        "WithErrorUnused(false)",
+			changes:     map[string]interface{}{"city": "Tampa", "zip": "33602"},
+			opts:        []Option{WithErrorUnused(false)},
+			want:        optionsRecord{City: "Tampa", Labels: map[string]string{"a": "1"}},
+			wantUnknown: []string{"zip"},
+		},
+		{
+			name:    "WithZeroFields(false) merges maps",
+			changes: map[string]interface{}{"labels": map[string]interface{}{"b": "2"}},
+			opts:    []Option{WithZeroFields(false)},
+			want:    optionsRecord{City: "Miami", Labels: map[string]string{"a": "1", "b": "2"}},
+		},
+		{
+			name:    "WithTagName",
+			changes: map[string]interface{}{"city_name": "Tampa"},
+			opts:    []Option{WithTagName("db")},
+			want:    optionsRecord{City: "Tampa", Labels: map[string]string{"a": "1"}},
+		},
+		{
+			name:    "later options win",
+			changes: map[string]interface{}{"city": "Tampa", "zip": "33602"},
+			opts:    []Option{WithErrorUnused(false), WithErrorUnused(true)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := optionsRecord{City: "Miami", Labels: map[string]string{"a": "1"}}
+			result, err := ApplyChanges(tt.changes, &record, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(record, tt.want) {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+			if !reflect.DeepEqual(result.UnknownFields, tt.wantUnknown) {
+				t.Errorf("UnknownFields = %v, want %v", result.UnknownFields, tt.wantUnknown)
+			}
+		})
+	}
+}
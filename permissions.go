@@ -0,0 +1,91 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// AllFields grants a role every field in FieldPermissions
+const AllFields = "*"
+
+// FieldPermissions maps each role to the fields principals acting in it may
+// change (by tag name, or by path such as "details.source" for a nested
+// struct's field; listing "details" covers all of its fields), see
+// WithFieldPermissions. AllFields grants every field.
+//
+//	applychanges.FieldPermissions{
+//		"admin":    {applychanges.AllFields},
+//		"reviewer": {"status", "notes"},
+//	}
+type FieldPermissions map[string][]string
+
+// rolesOption is the apply tag option restricting a field to some roles, e.g.
+// `apply:"roles=admin|editor"`
+const rolesOption = "roles="
+
+// permitted checks whether the role may change the field at path: it must be
+// one of the field's tagged roles, if any, and be granted the field by
+// WithFieldPermissions, if given. A key whose nested keys are checked
+// separately (descending) is also permitted when the role is only granted some
+// of its fields.
+func permitted(path string, field *reflect.StructField, descending bool, role string, cfg *config) error {
+	if field != nil {
+		if roles, ok := fieldRoles(*field); ok && !containsString(roles, role) {
+			return fmt.Errorf("only %s can change it", strings.Join(roles, ", "))
+		}
+	}
+
+	if cfg.permissions == nil {
+		return nil
+	}
+
+	granted := cfg.permissions[role]
+	if granted[AllFields] {
+		return nil
+	}
+
+	for ancestor := path; ; {
+		if granted[ancestor] {
+			return nil
+		}
+
+		i := strings.LastIndex(ancestor, ".")
+		if i < 0 {
+			break
+		}
+		ancestor = ancestor[:i]
+	}
+
+	if descending {
+		for grantedPath := range granted {
+			if strings.HasPrefix(grantedPath, path+".") {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("the %q role cannot change it", role)
+}
+
+// fieldRoles returns the roles listed by the field's roles apply option
+func fieldRoles(field reflect.StructField) ([]string, bool) {
+	for _, option := range strings.Split(field.Tag.Get(applyTagName), ",") {
+		option = strings.TrimSpace(option)
+		if strings.HasPrefix(option, rolesOption) {
+			return strings.Split(strings.TrimPrefix(option, rolesOption), "|"), true
+		}
+	}
+
+	return nil, false
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
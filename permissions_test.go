@@ -0,0 +1,108 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWithFieldPermissions(t *testing.T) {
+	permissions := FieldPermissions{
+		"admin":    {AllFields},
+		"reviewer": {"status", "notes"},
+		"editor":   {"details"},
+		"operator": {"details.level"},
+	}
+
+	tests := []struct {
+		name      string
+		role      string
+		opts      []Option
+		changes   map[string]interface{}
+		forbidden map[string]string
+	}{
+		{
+			name:    "all fields",
+			role:    "admin",
+			opts:    []Option{WithFieldPermissions(permissions)},
+			changes: map[string]interface{}{"status": "open", "notes": "checked", "details": map[string]interface{}{"level": 2}},
+		},
+		{
+			name:    "listed field",
+			role:    "reviewer",
+			opts:    []Option{WithFieldPermissions(permissions)},
+			changes: map[string]interface{}{"status": "open"},
+		},
+		{
+			name:    "unlisted and role tagged fields",
+			role:    "reviewer",
+			opts:    []Option{WithFieldPermissions(permissions)},
+			changes: map[string]interface{}{"status": "open", "notes": "checked", "dueDts": "2024-06-01T00:00:00Z"},
+			forbidden: map[string]string{
+				"dueDts": `the "reviewer" role cannot change it`,
+				"notes":  "only admin can change it",
+			},
+		},
+		{
+			name:    "ancestor grants its nested fields",
+			role:    "editor",
+			opts:    []Option{WithFieldPermissions(permissions)},
+			changes: map[string]interface{}{"details": map[string]interface{}{"level": 2, "source": "radar"}},
+		},
+		{
+			name:      "nested field granted alone",
+			role:      "operator",
+			opts:      []Option{WithFieldPermissions(permissions)},
+			changes:   map[string]interface{}{"details": map[string]interface{}{"level": 2, "source": "radar"}},
+			forbidden: map[string]string{"details.source": `the "operator" role cannot change it`},
+		},
+		{
+			name:      "unknown role",
+			role:      "viewer",
+			opts:      []Option{WithFieldPermissions(permissions)},
+			changes:   map[string]interface{}{"status": "open"},
+			forbidden: map[string]string{"status": `the "viewer" role cannot change it`},
+		},
+		{
+			name:    "combined when given more than once",
+			role:    "reviewer",
+			opts:    []Option{WithFieldPermissions(permissions), WithFieldPermissions(FieldPermissions{"reviewer": {"dueDts"}})},
+			changes: map[string]interface{}{"status": "open", "dueDts": "2024-06-01T00:00:00Z"},
+		},
+		{
+			name:      "role tag without permissions",
+			role:      "viewer",
+			changes:   map[string]interface{}{"status": "open", "notes": "checked"},
+			forbidden: map[string]string{"notes": "only admin can change it"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := authorizedRecord{}
+			_, err := ApplyChangesAs(tt.changes, Principal{ID: "EUA1", Role: tt.role}, &record, tt.opts...)
+
+			if tt.forbidden == nil {
+				if err != nil {
+					t.Fatalf("ApplyChangesAs() error = %v", err)
+				}
+				return
+			}
+
+			var forbidden *ForbiddenFieldsError
+			if !errors.As(err, &forbidden) {
+				t.Fatalf("ApplyChangesAs() error = %v, want a ForbiddenFieldsError", err)
+			}
+			reasons := map[string]string{}
+			for path, reason := range forbidden.Reasons {
+				reasons[path] = reason.Error()
+			}
+			if !reflect.DeepEqual(reasons, tt.forbidden) {
+				t.Errorf("Reasons = %q, want %q", reasons, tt.forbidden)
+			}
+			if record.Status != "" {
+				t.Errorf("record = %+v, want it untouched", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Package changesetgen is a gqlgen plugin generating a typed applier for every
+// changeset input in the schema, so resolvers don't hand untyped change maps
+// to the wrapper themselves.
+//
+// An input named UpdateXInput or XChanges is a changeset for the type X when X
+// is an object in the schema and the input is modelled as a change map
+// (map[string]interface{} in gqlgen.yml, which is what keeps unset fields apart
+// from null ones). For each one the plugin generates
+//
+//	func ApplyXChanges(input map[string]interface{}, modifier string, to *X, opts ...applychanges.Option) (applychanges.ApplyResult, error)
+//
+// along with ApplyXChangesAs, taking an applychanges.Principal instead of the
+// modifier. Inputs modelled as structs are skipped, since they can't tell which
+// fields were sent.
+//
+// The plugin also declares the @immutable and @adminOnly directives (don't
+// declare them in the schema yourself). Marking a field of X or of its
+// changeset input with one of them gets the appliers to pass it to
+// applychanges.WithImmutableFields or applychanges.WithAdminOnlyFields, so the
+// schema is the one place those rules are kept; the directives do nothing at
+// runtime.
+package changesetgen
+
+import (
+	_ "embed"
+	"go/types"
+	"regexp"
+	"sort"
+
+	"github.com/99designs/gqlgen/codegen"
+	"github.com/99designs/gqlgen/codegen/config"
+	"github.com/99designs/gqlgen/codegen/templates"
+	"github.com/99designs/gqlgen/plugin"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+//go:embed changesets.gotpl
+var changesetsTemplate string
+
+// changesetInputName matches the names of changeset inputs, capturing the
+// name of the type they change
+var changesetInputName = regexp.MustCompile(`^(?:Update(\w+)Input|(\w+)Changes)$`)
+
+// New returns the plugin, writing the generated appliers to filename as part
+// of the package packageName
+func New(filename string, packageName string) plugin.Plugin {
+	return &Plugin{filename: filename, packageName: packageName}
+}
+
+// Plugin generates the changeset appliers, see New
+type Plugin struct {
+	filename    string
+	packageName string
+}
+
+// directives are the schema directives declaring field rules, by name
+const (
+	immutableDirective = "immutable"
+	adminOnlyDirective = "adminOnly"
+)
+
+var (
+	_ plugin.CodeGenerator       = &Plugin{}
+	_ plugin.ConfigMutator       = &Plugin{}
+	_ plugin.EarlySourceInjector = &Plugin{}
+)
+
+func (p *Plugin) Name() string {
+	return "changesetgen"
+}
+
+func (p *Plugin) InjectSourceEarly() *ast.Source {
+	return &ast.Source{
+		Name: "changesetgen/directives.graphql",
+		Input: `directive @` + immutableDirective + ` on FIELD_DEFINITION | INPUT_FIELD_DEFINITION
+directive @` + adminOnlyDirective + ` on FIELD_DEFINITION | INPUT_FIELD_DEFINITION
+`,
+	}
+}
+
+// MutateConfig keeps the directives out of the generated DirectiveRoot, since
+// they're enforced by the appliers rather than at runtime
+func (p *Plugin) MutateConfig(cfg *config.Config) error {
+	cfg.Directives[immutableDirective] = config.DirectiveConfig{SkipRuntime: true}
+	cfg.Directives[adminOnlyDirective] = config.DirectiveConfig{SkipRuntime: true}
+	return nil
+}
+
+func (p *Plugin) GenerateCode(data *codegen.Data) error {
+	return templates.Render(templates.Options{
+		PackageName:     p.packageName,
+		Filename:        p.filename,
+		Data:            &changesetBuild{Appliers: appliers(data)},
+		GeneratedHeader: true,
+		Packages:        data.Config.Packages,
+		Template:        changesetsTemplate,
+	})
+}
+
+type changesetBuild struct {
+	Appliers []*applier
+}
+
+// applier is a single generated pair of ApplyXChanges functions
+type applier struct {
+	Name      string
+	InputName string
+	Input     types.Type
+	Model     types.Type
+
+	// ImmutableFields and AdminOnlyFields are the names of the fields marked
+	// with the directives, on X or on its input, sorted
+	ImmutableFields []string
+	AdminOnlyFields []string
+}
+
+// appliers finds the changeset inputs and the models they change, sorted by
+// name
+func appliers(data *codegen.Data) []*applier {
+	var found []*applier
+	for _, input := range data.Inputs {
+		match := changesetInputName.FindStringSubmatch(input.Name)
+		if match == nil || !isChangeMap(input.Type) {
+			continue
+		}
+
+		modelName := match[1]
+		if modelName == "" {
+			modelName = match[2]
+		}
+
+		object := data.Objects.ByName(modelName)
+		if object == nil {
+			continue
+		}
+
+		named, ok := object.Type.(*types.Named)
+		if !ok {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Struct); !ok {
+			continue
+		}
+
+		definitions := append(ast.FieldList{}, object.Definition.Fields...)
+		definitions = append(definitions, input.Definition.Fields...)
+
+		found = append(found, &applier{
+			Name:            named.Obj().Name(),
+			InputName:       input.Name,
+			Input:           input.Type,
+			Model:           named,
+			ImmutableFields: directiveFields(definitions, immutableDirective),
+			AdminOnlyFields: directiveFields(definitions, adminOnlyDirective),
+		})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Name < found[j].Name
+	})
+
+	return found
+}
+
+// directiveFields lists the names of the fields marked with the directive,
+// sorted and without duplicates
+func directiveFields(definitions ast.FieldList, directive string) []string {
+	seen := map[string]bool{}
+	var fields []string
+	for _, definition := range definitions {
+		if definition.Directives.ForName(directive) != nil && !seen[definition.Name] {
+			seen[definition.Name] = true
+			fields = append(fields, definition.Name)
+		}
+	}
+
+	sort.Strings(fields)
+	return fields
+}
+
+// isChangeMap reports whether an input is modelled as map[string]interface{}
+func isChangeMap(t types.Type) bool {
+	m, ok := t.Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+
+	key, ok := m.Key().Underlying().(*types.Basic)
+	if !ok || key.Kind() != types.String {
+		return false
+	}
+
+	elem, ok := m.Elem().Underlying().(*types.Interface)
+	return ok && elem.Empty()
+}
@@ -0,0 +1,182 @@
+package changesetgen
+
+import (
+	"go/types"
+	"reflect"
+	"testing"
+
+	"github.com/99designs/gqlgen/codegen"
+	"github.com/99designs/gqlgen/codegen/config"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var (
+	modelPackage = types.NewPackage("example.com/model", "model")
+	changeMap    = types.NewMap(types.Typ[types.String], types.NewInterfaceType(nil, nil).Complete())
+)
+
+func namedStruct(name string) *types.Named {
+	return types.NewNamed(types.NewTypeName(0, modelPackage, name, nil), types.NewStruct(nil, nil), nil)
+}
+
+func object(name string, t types.Type, fields ...*ast.FieldDefinition) *codegen.Object {
+	return &codegen.Object{Definition: &ast.Definition{Name: name, Fields: fields}, Type: t}
+}
+
+func TestAppliers(t *testing.T) {
+	station := namedStruct("Station")
+	reading := namedStruct("Reading")
+
+	tests := []struct {
+		name   string
+		inputs codegen.Objects
+		want   []string
+	}{
+		{
+			name:   "UpdateXInput",
+			inputs: codegen.Objects{object("UpdateStationInput", changeMap)},
+			want:   []string{"Station UpdateStationInput"},
+		},
+		{
+			name:   "XChanges",
+			inputs: codegen.Objects{object("ReadingChanges", changeMap)},
+			want:   []string{"Reading ReadingChanges"},
+		},
+		{
+			name:   "sorted by model",
+			inputs: codegen.Objects{object("UpdateStationInput", changeMap), object("ReadingChanges", changeMap)},
+			want:   []string{"Reading ReadingChanges", "Station UpdateStationInput"},
+		},
+		{
+			name:   "struct input",
+			inputs: codegen.Objects{object("UpdateStationInput", namedStruct("UpdateStationInput"))},
+		},
+		{
+			name:   "unknown model",
+			inputs: codegen.Objects{object("UpdateSensorInput", changeMap)},
+		},
+		{
+			name:   "not a changeset name",
+			inputs: codegen.Objects{object("StationInput", changeMap), object("UpdateStation", changeMap)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &codegen.Data{
+				Objects: codegen.Objects{object("Station", station), object("Reading", reading)},
+				Inputs:  tt.inputs,
+			}
+
+			var got []string
+			for _, applier := range appliers(data) {
+				got = append(got, applier.Name+" "+applier.InputName)
+				if applier.Model == nil || applier.Input == nil {
+					t.Errorf("applier %s is missing its types", applier.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("appliers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsChangeMap(t *testing.T) {
+	tests := []struct {
+		name string
+		t    types.Type
+		want bool
+	}{
+		{name: "map[string]interface{}", t: changeMap, want: true},
+		{name: "named change map", t: types.NewNamed(types.NewTypeName(0, modelPackage, "Changes", nil), changeMap, nil), want: true},
+		{name: "map[string]string", t: types.NewMap(types.Typ[types.String], types.Typ[types.String])},
+		{name: "map[int]interface{}", t: types.NewMap(types.Typ[types.Int], types.NewInterfaceType(nil, nil).Complete())},
+		{name: "struct", t: namedStruct("UpdateStationInput")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isChangeMap(tt.t); got != tt.want {
+				t.Errorf("isChangeMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func field(name string, directives ...string) *ast.FieldDefinition {
+	definition := &ast.FieldDefinition{Name: name}
+	for _, directive := range directives {
+		definition.Directives = append(definition.Directives, &ast.Directive{Name: directive})
+	}
+
+	return definition
+}
+
+func TestDirectiveFields(t *testing.T) {
+	data := &codegen.Data{
+		Objects: codegen.Objects{object("Station", namedStruct("Station"),
+			field("id", immutableDirective),
+			field("name"),
+			field("owner", adminOnlyDirective),
+			field("code", immutableDirective, adminOnlyDirective),
+		)},
+		Inputs: codegen.Objects{object("UpdateStationInput", changeMap,
+			field("name"),
+			field("id", immutableDirective),
+			field("budget", adminOnlyDirective),
+		)},
+	}
+
+	found := appliers(data)
+	if len(found) != 1 {
+		t.Fatalf("appliers() = %d, want 1", len(found))
+	}
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{name: "immutable", got: found[0].ImmutableFields, want: []string{"code", "id"}},
+		{name: "adminOnly", got: found[0].AdminOnlyFields, want: []string{"budget", "code", "owner"}},
+		{name: "none", got: directiveFields(ast.FieldList{field("name")}, immutableDirective)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("fields = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectivesDeclared(t *testing.T) {
+	p := New("changesets_gen.go", "model").(*Plugin)
+
+	schema, err := gqlparser.LoadSchema(p.InjectSourceEarly(), &ast.Source{Name: "schema.graphql", Input: `
+type Query { station: Station }
+type Station { id: ID! @immutable, owner: String @adminOnly }
+input UpdateStationInput { owner: String @adminOnly }
+`})
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+	for _, name := range []string{immutableDirective, adminOnlyDirective} {
+		if schema.Directives[name] == nil {
+			t.Errorf("schema is missing @%s", name)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	if err := p.MutateConfig(cfg); err != nil {
+		t.Fatalf("MutateConfig() error = %v", err)
+	}
+	for _, name := range []string{immutableDirective, adminOnlyDirective} {
+		if !cfg.Directives[name].SkipRuntime {
+			t.Errorf("@%s is generated into the DirectiveRoot, want it skipped at runtime", name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+)
+
+// Policy decides whether the principal may apply the changes to the entity in
+// its current state, e.g. that nobody but an admin may change a closed case,
+// see WithApplyPolicy
+type Policy interface {
+	// Authorize is given the principal's ID (or the modifier when there's no
+	// principal), the entity before the apply and the sanitized changes, which
+	// it mustn't modify; returning an error aborts the apply
+	Authorize(ctx context.Context, principal string, entity interface{}, changes map[string]interface{}) error
+}
+
+// PolicyFunc adapts a function to a Policy
+type PolicyFunc func(ctx context.Context, principal string, entity interface{}, changes map[string]interface{}) error
+
+func (f PolicyFunc) Authorize(ctx context.Context, principal string, entity interface{}, changes map[string]interface{}) error {
+	return f(ctx, principal, entity, changes)
+}
+
+// AllowAll is the Policy of applies without WithApplyPolicy: it allows
+// everything
+var AllowAll Policy = PolicyFunc(func(context.Context, string, interface{}, map[string]interface{}) error {
+	return nil
+})
+
+// PolicyError is returned when an apply's Policy rejects it; like
+// ForbiddenFieldsError, it is the equivalent of an HTTP 403 Forbidden
+type PolicyError struct {
+	// Err is the error the Policy returned
+	Err error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("not authorized to apply the changes: %v", e.Err)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// authorizePolicy checks the changes against the Policy, once, before the
+// fields are authorized one by one
+func authorizePolicy(changes map[string]interface{}, entity interface{}, cfg *config) error {
+	if err := cfg.policy.Authorize(cfg.ctx, authorizedPrincipal(cfg).ID, entity, copyChanges(changes)); err != nil {
+		return &PolicyError{Err: err}
+	}
+
+	return nil
+}
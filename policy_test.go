@@ -0,0 +1,155 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type policyCase struct {
+	Status   string `json:"status"`
+	Summary  string `json:"summary"`
+	Assignee string `json:"assignee"`
+
+	ModifiedBy *string `json:"modifiedBy"`
+}
+
+var errCaseClosed = errors.New("the case is closed")
+
+// denyIfClosed lets nobody but EUA0 change a closed case, apart from reopening
+// it
+var denyIfClosed = PolicyFunc(func(_ context.Context, principal string, entity interface{}, changes map[string]interface{}) error {
+	if entity.(*policyCase).Status != "closed" || principal == "EUA0" {
+		return nil
+	}
+	if len(changes) == 1 && changes["status"] == "open" {
+		return nil
+	}
+	return errCaseClosed
+})
+
+func TestWithApplyPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		modifier string
+		changes  map[string]interface{}
+		policy   Policy
+		wantErr  error
+	}{
+		{
+			name:     "open case",
+			status:   "open",
+			modifier: "EUA1",
+			changes:  map[string]interface{}{"summary": "Flooding"},
+			policy:   denyIfClosed,
+		},
+		{
+			name:     "closed case",
+			status:   "closed",
+			modifier: "EUA1",
+			changes:  map[string]interface{}{"summary": "Flooding"},
+			policy:   denyIfClosed,
+			wantErr:  errCaseClosed,
+		},
+		{
+			name:     "reopening a closed case",
+			status:   "closed",
+			modifier: "EUA1",
+			changes:  map[string]interface{}{"status": "open"},
+			policy:   denyIfClosed,
+		},
+		{
+			name:     "closed case by the principal allowed to",
+			status:   "closed",
+			modifier: "EUA0",
+			changes:  map[string]interface{}{"summary": "Flooding"},
+			policy:   denyIfClosed,
+		},
+		{
+			name:     "AllowAll",
+			status:   "closed",
+			modifier: "EUA1",
+			changes:  map[string]interface{}{"summary": "Flooding"},
+			policy:   AllowAll,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := policyCase{Status: tt.status, Summary: "Storm"}
+			_, err := ApplyChangesWrapper(tt.changes, tt.modifier, &record, WithApplyPolicy(tt.policy))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				var policyErr *PolicyError
+				if !errors.As(err, &policyErr) {
+					t.Errorf("ApplyChangesWrapper() error = %T, want a *PolicyError", err)
+				}
+				if record != (policyCase{Status: tt.status, Summary: "Storm"}) {
+					t.Errorf("a rejected apply changed the target to %+v", record)
+				}
+			}
+		})
+	}
+}
+
+func TestWithApplyPolicyOrder(t *testing.T) {
+	var calls []string
+	policy := PolicyFunc(func(_ context.Context, principal string, entity interface{}, changes map[string]interface{}) error {
+		calls = append(calls, "policy")
+
+		record := entity.(*policyCase)
+		if record.Summary != "Storm" {
+			t.Errorf("the policy was given a changed entity: %+v", record)
+		}
+		if !reflect.DeepEqual(changes, map[string]interface{}{"summary": "Flooding", "assignee": "EUA2", "status": nil}) {
+			t.Errorf("the policy was given changes %v, want the sanitized changes", changes)
+		}
+
+		changes["summary"] = "changed by the policy"
+		return nil
+	})
+	authorizer := func(_ context.Context, fieldPath string, _ Principal) error {
+		calls = append(calls, "field "+fieldPath)
+		if fieldPath == "assignee" {
+			return errors.New("not yours to assign")
+		}
+		return nil
+	}
+
+	record := policyCase{Summary: "Storm"}
+	changes := map[string]interface{}{"summary": "Flooding", "assignee": "EUA2", "status": ""}
+	_, err := ApplyChangesWrapper(changes, "EUA1", &record, WithApplyPolicy(policy), WithFieldAuthorizer(authorizer))
+
+	var forbidden *ForbiddenFieldsError
+	if !errors.As(err, &forbidden) {
+		t.Fatalf("ApplyChangesWrapper() error = %v, want the field authorizer's ForbiddenFieldsError", err)
+	}
+	if len(calls) != 4 || calls[0] != "policy" {
+		t.Errorf("calls = %v, want the policy first, then each field", calls)
+	}
+	if changes["summary"] != "Flooding" {
+		t.Errorf("summary change = %q, the policy's edit to its copy leaked into the changes", changes["summary"])
+	}
+}
+
+func TestWithApplyPolicyBeforeFieldAuthorizer(t *testing.T) {
+	authorized := false
+	authorizer := func(context.Context, string, Principal) error {
+		authorized = true
+		return nil
+	}
+
+	record := policyCase{Status: "closed"}
+	_, err := ApplyChangesWrapper(map[string]interface{}{"summary": "Flooding"}, "EUA1", &record, WithApplyPolicy(denyIfClosed), WithFieldAuthorizer(authorizer))
+	if !errors.Is(err, errCaseClosed) {
+		t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, errCaseClosed)
+	}
+	if authorized {
+		t.Error("the field authorizer ran after the policy rejected the apply")
+	}
+}
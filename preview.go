@@ -0,0 +1,59 @@
+package applychanges
+
+import "reflect"
+
+// PreviewResult is what an apply would do to an entity, see Preview
+type PreviewResult struct {
+	// Entity points to a copy of the entity with the changes applied, derived
+	// fields (see AfterApplier) and stamped metadata included
+	Entity interface{}
+
+	// Result is the apply's result, whose Changes are the full diff
+	Result ApplyResult
+
+	// ValidationErr is what the WithValidator validator returned for Entity,
+	// nil when it passed or there is none
+	ValidationErr error
+}
+
+// Preview applies the changes like ApplyChangesWrapper to a deep copy of the
+// entity, which must be a pointer to a struct, for showing what an apply would
+// do without saving it. The whole pipeline runs on the copy, BeforeApplier
+// and AfterApplier included, but a failing validator doesn't fail the preview:
+// its error is returned in the result alongside the diff. Neither the entity
+// nor the changes are touched, and nothing is audited, published, recorded in
+// the History or stored under an idempotency key. Errors are those the apply
+// would fail with before validation.
+func Preview(changes map[string]interface{}, modifier string, entity interface{}, opts ...Option) (PreviewResult, error) {
+	if err := checkStructTarget(entity); err != nil {
+		return PreviewResult{}, err
+	}
+
+	cfg := previewConfig(configFor(reflect.TypeOf(entity), opts))
+	cfg.modifier = &modifier
+
+	validator := cfg.validator
+	cfg.validator = nil
+
+	preview := PreviewResult{Entity: copyTarget(entity)}
+	result, err := applyChanges(copyChanges(changes), preview.Entity, cfg)
+	if err != nil {
+		return PreviewResult{}, err
+	}
+	preview.Result = result
+
+	if validator != nil {
+		preview.ValidationErr = validate(cfg.ctx, validator, preview.Entity)
+	}
+
+	return preview, nil
+}
+
+// previewConfig turns off everything an apply does besides changing its
+// target, see Preview
+func previewConfig(cfg *config) *config {
+	cfg.auditSink, cfg.eventPublisher, cfg.history = nil, nil, nil
+	cfg.idempotencyKey, cfg.contentDerivedKey = "", false
+
+	return cfg
+}
@@ -0,0 +1,133 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// previewedReport derives Summary from City and Weather after every apply
+type previewedReport struct {
+	BaseStruct
+	City    string `json:"city"`
+	Weather string `json:"weather"`
+	Summary string `json:"summary"`
+}
+
+func (r *previewedReport) AfterApply([]FieldChange) error {
+	r.Summary = r.Weather + " in " + r.City
+	return nil
+}
+
+func TestPreview(t *testing.T) {
+	requireCity := ValidatorFunc(func(target interface{}) error {
+		if target.(*previewedReport).City == "" {
+			return ValidationErrors{{Field: "city", Message: "is required"}}
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name              string
+		changes           map[string]interface{}
+		want              previewedReport
+		wantChanged       []string
+		wantValidationErr error
+		wantErr           error
+	}{
+		{
+			name:        "passing validation",
+			changes:     map[string]interface{}{"weather": "Rain"},
+			want:        previewedReport{City: "Tampa", Weather: "Rain", Summary: "Rain in Tampa"},
+			wantChanged: []string{"modifiedBy", "weather"},
+		},
+		{
+			name:              "failing validation",
+			changes:           map[string]interface{}{"city": ""},
+			want:              previewedReport{Weather: "Sunny", Summary: "Sunny in "},
+			wantChanged:       []string{"city", "modifiedBy"},
+			wantValidationErr: ValidationErrors{{Field: "city", Message: "is required"}},
+		},
+		{
+			name:    "failing apply",
+			changes: map[string]interface{}{"weatherr": "Rain"},
+			wantErr: errors.New("1 error applying changes: 'weatherr': no such field"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := previewedReport{City: "Tampa", Weather: "Sunny", Summary: "Sunny in Tampa"}
+			original := report
+			changes := copyChanges(tt.changes)
+			sink, publisher, history := &recordingSink{}, &recordingPublisher{}, NewHistory(10)
+
+			preview, err := Preview(changes, "EUA1", &report,
+				WithValidator(requireCity),
+				WithModifiedDts(false),
+				WithAuditSink(sink),
+				WithEventPublisher(publisher),
+				WithHistory(history),
+			)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("Preview() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Preview() error = %v", err)
+			}
+
+			if report != original {
+				t.Errorf("entity = %+v, want it untouched", report)
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes = %v, want them untouched", changes)
+			}
+			if len(sink.entries) != 0 || len(publisher.events) != 0 || len(history.Versions(&report)) != 0 {
+				t.Errorf("audited %d entries, published %d events and recorded %d versions, want none",
+					len(sink.entries), len(publisher.events), len(history.Versions(&report)))
+			}
+			if err != nil {
+				return
+			}
+
+			previewed := *preview.Entity.(*previewedReport)
+			previewed.ModifiedBy = nil
+			if previewed != tt.want {
+				t.Errorf("Entity = %+v, want %+v", previewed, tt.want)
+			}
+
+			var changed []string
+			for _, change := range preview.Result.Changes {
+				changed = append(changed, change.Path)
+			}
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("changed %v, want %v", changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(preview.ValidationErr, tt.wantValidationErr) {
+				t.Errorf("ValidationErr = %v, want %v", preview.ValidationErr, tt.wantValidationErr)
+			}
+		})
+	}
+}
+
+func TestPreviewIdempotencyKey(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	report := previewedReport{City: "Tampa"}
+	if _, err := Preview(map[string]interface{}{"weather": "Rain"}, "EUA1", &report,
+		WithIdempotencyStore(store), WithIdempotencyKey("k1")); err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+
+	if _, ok, _ := store.Load(context.Background(), "k1"); ok {
+		t.Error("Preview() stored its result under the idempotency key")
+	}
+}
+
+func TestPreviewNotAStruct(t *testing.T) {
+	var notAStruct map[string]interface{}
+	if _, err := Preview(map[string]interface{}{}, "EUA1", &notAStruct); err == nil {
+		t.Error("Preview() error = nil, want only structs previewed")
+	}
+}
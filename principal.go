@@ -0,0 +1,94 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// modifiedByPrincipalField is the Go name of the optional field
+// ApplyChangesWrapper records the full modifier in; targets opt in by declaring
+// it as a Principal or *Principal
+const modifiedByPrincipalField = "ModifiedByPrincipal"
+
+// Principal identifies who is applying changes, carrying more than the bare ID
+// stamped as modifiedBy
+type Principal struct {
+	// ID is what gets stamped as modifiedBy (and RecentModifiers), e.g. an EUA
+	// ID
+	ID string `json:"id"`
+
+	// Name is the principal's display (common) name
+	Name string `json:"name"`
+
+	// Role is the role the principal acted in
+	Role string `json:"role"`
+}
+
+// ApplyChangesAs applies the changes like ApplyChangesWrapper with the
+// principal's ID as the modifier. Targets with a ModifiedByPrincipal field (a
+// Principal or *Principal) get the whole principal stamped there too; like
+// RecentModifiers, the changes can't set that field themselves.
+func ApplyChangesAs(changes map[string]interface{}, principal Principal, to interface{}, opts ...Option) (ApplyResult, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+	cfg.modifier = &principal.ID
+	cfg.principal = &principal
+
+	return applyChanges(changes, to, cfg)
+}
+
+// modifiedByPrincipalKey returns the changes key of the target's
+// ModifiedByPrincipal field, or false when the target doesn't have one
+func modifiedByPrincipalKey(to interface{}, cfg *config) (string, bool) {
+	target, ok := targetStruct(to)
+	if !ok {
+		return "", false
+	}
+
+	principalType := reflect.TypeOf(Principal{})
+	field, ok := structFieldByName(target.Type(), modifiedByPrincipalField)
+	if !ok || (field.Type != principalType && field.Type != reflect.PtrTo(principalType)) {
+		return "", false
+	}
+
+	key := fieldKey(field, cfg.tagName)
+	return key, key != ""
+}
+
+// rejectPrincipalWrite fails if the changes try to set ModifiedByPrincipal
+// themselves
+func rejectPrincipalWrite(changes map[string]interface{}, to interface{}, cfg *config) error {
+	principalKey, ok := modifiedByPrincipalKey(to, cfg)
+	if !ok {
+		return nil
+	}
+
+	for key := range changes {
+		if strings.EqualFold(key, principalKey) {
+			return fmt.Errorf("'%s' cannot be changed directly", key)
+		}
+	}
+
+	return nil
+}
+
+// principalChange is the value stamped into ModifiedByPrincipal: the principal
+// the changes are applied as, or just the modifier's ID when applied through
+// ApplyChangesWrapper, so it never describes an earlier modifier. It's keyed
+// like any other change, by the configured tag names of Principal's fields.
+func principalChange(cfg *config) map[string]interface{} {
+	principal := Principal{ID: *cfg.modifier}
+	if cfg.principal != nil {
+		principal = *cfg.principal
+	}
+
+	value := reflect.ValueOf(principal)
+	change := make(map[string]interface{}, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		if key := fieldKey(value.Type().Field(i), cfg.tagName); key != "" {
+			change[key] = value.Field(i).Interface()
+		}
+	}
+
+	return change
+}
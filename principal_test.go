@@ -0,0 +1,91 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+// principalRecord records its full modifier in a Principal value
+type principalRecord struct {
+	Name                string    `json:"name"`
+	ModifiedBy          string    `json:"modifiedBy"`
+	ModifiedByPrincipal Principal `json:"modifiedByPrincipal"`
+}
+
+func TestApplyChangesAs(t *testing.T) {
+	ada := Principal{ID: "EUA1", Name: "Ada", Role: "admin"}
+
+	tests := []struct {
+		name    string
+		apply   func(changes map[string]interface{}, to interface{}) (ApplyResult, error)
+		to      interface{}
+		changes map[string]interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "pointer field",
+			apply: func(changes map[string]interface{}, to interface{}) (ApplyResult, error) {
+				return ApplyChangesAs(changes, ada, to, WithModifiedDts(false))
+			},
+			to:      &nestedRecord{},
+			changes: map[string]interface{}{"name": "Tampa"},
+			want:    &nestedRecord{BaseStruct: BaseStruct{ModifiedBy: stringPtr("EUA1")}, Name: "Tampa", ModifiedByPrincipal: &ada},
+		},
+		{
+			name: "value field",
+			apply: func(changes map[string]interface{}, to interface{}) (ApplyResult, error) {
+				return ApplyChangesAs(changes, ada, to)
+			},
+			to:      &principalRecord{ModifiedBy: "EUA9", ModifiedByPrincipal: Principal{ID: "EUA9", Name: "Bob"}},
+			changes: map[string]interface{}{"name": "Tampa"},
+			want:    &principalRecord{Name: "Tampa", ModifiedBy: "EUA1", ModifiedByPrincipal: ada},
+		},
+		{
+			name: "wrapper only knows the ID",
+			apply: func(changes map[string]interface{}, to interface{}) (ApplyResult, error) {
+				return ApplyChangesWrapper(changes, "EUA2", to)
+			},
+			to:      &principalRecord{ModifiedBy: "EUA1", ModifiedByPrincipal: ada},
+			changes: map[string]interface{}{"name": "Tampa"},
+			want:    &principalRecord{Name: "Tampa", ModifiedBy: "EUA2", ModifiedByPrincipal: Principal{ID: "EUA2"}},
+		},
+		{
+			name: "set directly",
+			apply: func(changes map[string]interface{}, to interface{}) (ApplyResult, error) {
+				return ApplyChangesAs(changes, ada, to)
+			},
+			to:      &principalRecord{},
+			changes: map[string]interface{}{"name": "Tampa", "ModifiedByPrincipal": map[string]interface{}{"id": "EUA9"}},
+			want:    &principalRecord{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.apply(tt.changes, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(tt.to, tt.want) {
+				t.Errorf("target = %+v, want %+v", tt.to, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyChangesAsAudit(t *testing.T) {
+	ada := Principal{ID: "EUA1", Name: "Ada", Role: "admin"}
+
+	sink := &recordingSink{}
+	record := principalRecord{}
+	if _, err := ApplyChangesAs(map[string]interface{}{"name": "Tampa"}, ada, &record, WithAuditSink(sink)); err != nil {
+		t.Fatalf("ApplyChangesAs() error = %v", err)
+	}
+
+	if len(sink.entries) != 1 || sink.entries[0].Modifier != "EUA1" || !reflect.DeepEqual(sink.entries[0].Principal, &ada) {
+		t.Errorf("audit entries = %+v, want one by %+v", sink.entries, ada)
+	}
+}
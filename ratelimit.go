@@ -0,0 +1,177 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrRateLimited is wrapped by the RateLimitedError an apply WithRateLimiter
+// returns when its target has been changed too often
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimiter caps how often an entity can be changed, see WithRateLimiter.
+// Allow reports whether the entity of the type (its Go type name) and ID may
+// be changed once more, counting the change when it may.
+type RateLimiter interface {
+	Allow(ctx context.Context, entityType string, id uuid.UUID) (bool, error)
+}
+
+// RateLimitedError is returned by an apply its RateLimiter denied, before
+// anything was applied. It wraps ErrRateLimited, and WriteErrorResponse always
+// writes it as 429 Too Many Requests.
+type RateLimitedError struct {
+	EntityType string
+	ID         uuid.UUID
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s %s has been changed too often, try again later", e.EntityType, e.ID)
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// checkRateLimit asks the RateLimiter to allow changing the target. Targets
+// without a uuid.UUID ID field, or with a zero one (not yet created), aren't
+// limited.
+func checkRateLimit(to interface{}, cfg *config) error {
+	target, ok := targetStruct(to)
+	if !ok {
+		return nil
+	}
+
+	field, ok := structFieldByName(target.Type(), "ID")
+	if !ok || field.Type != reflect.TypeOf(uuid.UUID{}) {
+		return nil
+	}
+	id := target.FieldByIndex(field.Index).Interface().(uuid.UUID)
+	if id == uuid.Nil {
+		return nil
+	}
+
+	entityType := targetTypeName(to)
+	allowed, err := cfg.rateLimiter.Allow(cfg.ctx, entityType, id)
+	if err != nil {
+		return fmt.Errorf("checking the rate limit: %w", err)
+	}
+	if !allowed {
+		return &RateLimitedError{EntityType: entityType, ID: id}
+	}
+
+	return nil
+}
+
+// MemoryRateLimiter is a RateLimiter keeping a token bucket per entity in
+// memory, for deployments running a single instance. Each bucket holds up to
+// limit changes and refills at limit changes per window, so an entity can be
+// changed limit times at once and then limit times per window.
+type MemoryRateLimiter struct {
+	limit  float64
+	window time.Duration
+	clock  Clock
+
+	mu      sync.Mutex
+	buckets map[rateLimitKey]tokenBucket
+	swept   int
+}
+
+type rateLimitKey struct {
+	entityType string
+	id         uuid.UUID
+}
+
+type tokenBucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// NewMemoryRateLimiter returns a MemoryRateLimiter allowing limit changes to
+// an entity per window, timed on the clock (the system clock when nil)
+func NewMemoryRateLimiter(limit int, window time.Duration, clock Clock) *MemoryRateLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	return &MemoryRateLimiter{
+		limit:   float64(limit),
+		window:  window,
+		clock:   clock,
+		buckets: map[rateLimitKey]tokenBucket{},
+	}
+}
+
+// Allow takes a token from the entity's bucket, if it has one left
+func (l *MemoryRateLimiter) Allow(_ context.Context, entityType string, id uuid.UUID) (bool, error) {
+	now := l.clock.Now()
+	key := rateLimitKey{entityType: entityType, id: id}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = tokenBucket{tokens: l.limit, at: now}
+	}
+	bucket = l.refill(bucket, now)
+
+	if bucket.tokens < 1 {
+		l.buckets[key] = bucket
+		return false, nil
+	}
+
+	bucket.tokens--
+	l.buckets[key] = bucket
+	l.sweep(now)
+
+	return true, nil
+}
+
+// refill adds the tokens earned since the bucket was last taken from
+func (l *MemoryRateLimiter) refill(bucket tokenBucket, now time.Time) tokenBucket {
+	if elapsed := now.Sub(bucket.at); elapsed > 0 && l.window > 0 {
+		bucket.tokens += l.limit * float64(elapsed) / float64(l.window)
+		if bucket.tokens > l.limit {
+			bucket.tokens = l.limit
+		}
+	}
+	bucket.at = now
+
+	return bucket
+}
+
+// sweep forgets the buckets that have refilled, which a new bucket behaves like,
+// whenever there are twice as many as after the last sweep
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if len(l.buckets) < 2*l.swept+64 {
+		return
+	}
+
+	for key, bucket := range l.buckets {
+		if l.refill(bucket, now).tokens >= l.limit {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = len(l.buckets)
+}
+
+// changesFields reports whether any of the changes actually changed a field
+// rather than the stamped metadata
+func changesFields(changes []FieldChange) bool {
+	for _, change := range changes {
+		if !isBookkeeping(change.Path) && (change.Truncated || !sameValue(change.Old, change.New)) {
+			return true
+		}
+	}
+
+	return false
+}
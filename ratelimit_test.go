@@ -0,0 +1,226 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newRateLimitedReport() dualWriteReport {
+	report := dualWriteReport{BaseStruct: NewBaseStruct("EUA0"), City: "Tampa"}
+	report.ID = uuid.MustParse("7a6f0d0e-3c2b-4f4e-8f6d-1b2c3d4e5f60")
+	return report
+}
+
+func TestWithRateLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		applies   []map[string]interface{}
+		freeNoOps bool
+		wantErrs  []bool
+	}{
+		{
+			name:     "under the limit",
+			applies:  []map[string]interface{}{{"city": "Miami"}, {"city": "Orlando"}},
+			wantErrs: []bool{false, false},
+		},
+		{
+			name:     "over the limit",
+			applies:  []map[string]interface{}{{"city": "Miami"}, {"city": "Orlando"}, {"city": "Naples"}},
+			wantErrs: []bool{false, false, true},
+		},
+		{
+			name:     "no-ops are counted",
+			applies:  []map[string]interface{}{{"city": "Tampa"}, {"city": "Tampa"}, {"city": "Miami"}},
+			wantErrs: []bool{false, false, true},
+		},
+		{
+			name:      "free no-ops",
+			applies:   []map[string]interface{}{{"city": "Tampa"}, {"city": "Tampa"}, {"city": "Miami"}, {"city": "Tampa"}, {"city": "Naples"}},
+			freeNoOps: true,
+			wantErrs:  []bool{false, false, false, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &manualClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+			opts := []Option{WithRateLimiter(NewMemoryRateLimiter(2, time.Hour, clock)), WithClock(clock)}
+			if tt.freeNoOps {
+				opts = append(opts, WithFreeNoOps())
+			}
+
+			report := newRateLimitedReport()
+			for i, changes := range tt.applies {
+				before := report
+				city := changes["city"]
+				_, err := ApplyChangesWrapper(changes, "EUA1", &report, opts...)
+				if (err != nil) != tt.wantErrs[i] {
+					t.Fatalf("apply %d error = %v, want error %v", i, err, tt.wantErrs[i])
+				}
+				if err == nil {
+					if report.City != city {
+						t.Errorf("apply %d: city = %q, want %q", i, report.City, city)
+					}
+					continue
+				}
+
+				var limited *RateLimitedError
+				if !errors.Is(err, ErrRateLimited) || !errors.As(err, &limited) {
+					t.Fatalf("apply %d error = %v, want a RateLimitedError", i, err)
+				}
+				if limited.EntityType != "dualWriteReport" || limited.ID != report.ID {
+					t.Errorf("RateLimitedError = %+v, want the report", limited)
+				}
+				if report != before {
+					t.Errorf("apply %d changed the report to %+v, want nothing applied", i, report)
+				}
+			}
+		})
+	}
+}
+
+func TestWithRateLimiterUnlimitedTargets(t *testing.T) {
+	limiter := NewMemoryRateLimiter(1, time.Hour, nil)
+
+	for i := 0; i < 3; i++ {
+		report := dualWriteReport{}
+		if _, err := ApplyChangesWrapper(map[string]interface{}{"city": "Miami"}, "EUA1", &report, WithRateLimiter(limiter)); err != nil {
+			t.Fatalf("ApplyChangesWrapper() error = %v, want a target without an ID left unlimited", err)
+		}
+
+		report = newRateLimitedReport()
+		if _, err := ApplyChangesWrapper(map[string]interface{}{"city": "Miami"}, "EUA1", &report, WithRateLimiter(limiter), WithDryRun()); err != nil {
+			t.Fatalf("ApplyChangesWrapper() error = %v, want a dry run left unlimited", err)
+		}
+	}
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, uuid.UUID) (bool, error) {
+	return false, errors.New("limiter unavailable")
+}
+
+func TestWithRateLimiterError(t *testing.T) {
+	report := newRateLimitedReport()
+	_, err := ApplyChangesWrapper(map[string]interface{}{"city": "Miami"}, "EUA1", &report, WithRateLimiter(failingLimiter{}))
+	if err == nil || errors.Is(err, ErrRateLimited) || !strings.Contains(err.Error(), "limiter unavailable") {
+		t.Fatalf("ApplyChangesWrapper() error = %v, want the limiter's error", err)
+	}
+	if report.City != "Tampa" {
+		t.Errorf("city = %q, want nothing applied", report.City)
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	station := uuid.MustParse("11111111-1111-4111-8111-111111111111")
+	other := uuid.MustParse("22222222-2222-4222-8222-222222222222")
+
+	type allow struct {
+		after      time.Duration
+		entityType string
+		id         uuid.UUID
+		want       bool
+	}
+	tests := []struct {
+		name   string
+		allows []allow
+	}{
+		{
+			name: "burst up to the limit",
+			allows: []allow{
+				{0, "Station", station, true},
+				{0, "Station", station, true},
+				{0, "Station", station, true},
+				{0, "Station", station, false},
+			},
+		},
+		{
+			name: "buckets per entity",
+			allows: []allow{
+				{0, "Station", station, true},
+				{0, "Station", station, true},
+				{0, "Station", station, true},
+				{0, "Station", other, true},
+				{0, "Reading", station, true},
+			},
+		},
+		{
+			name: "refills over the window",
+			allows: []allow{
+				{0, "Station", station, true},
+				{0, "Station", station, true},
+				{0, "Station", station, true},
+				{10 * time.Minute, "Station", station, false},
+				{20 * time.Minute, "Station", station, true},
+				{0, "Station", station, false},
+				{time.Hour, "Station", station, true},
+				{0, "Station", station, true},
+				{0, "Station", station, true},
+				{0, "Station", station, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &manualClock{now: start}
+			limiter := NewMemoryRateLimiter(3, time.Hour, clock)
+
+			for i, a := range tt.allows {
+				clock.now = clock.now.Add(a.after)
+				got, err := limiter.Allow(context.Background(), a.entityType, a.id)
+				if err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+				if got != a.want {
+					t.Errorf("Allow() %d = %v, want %v", i, got, a.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimiterSweeps(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryRateLimiter(1, time.Minute, clock)
+
+	for i := 0; i < 1000; i++ {
+		clock.now = clock.now.Add(time.Minute)
+		if _, err := limiter.Allow(context.Background(), "Station", uuid.New()); err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+	}
+
+	if len(limiter.buckets) > 128 {
+		t.Errorf("kept %d buckets, want the refilled ones forgotten", len(limiter.buckets))
+	}
+}
+
+func TestRateLimitedErrorResponse(t *testing.T) {
+	report := newRateLimitedReport()
+	limiter := NewMemoryRateLimiter(1, time.Hour, nil)
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"city": "Miami"}, "EUA1", &report, WithRateLimiter(limiter)); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	_, err := ApplyChangesWrapper(map[string]interface{}{"city": "Naples"}, "EUA1", &report, WithRateLimiter(limiter))
+
+	recorder := httptest.NewRecorder()
+	WriteErrorResponse(recorder, http.StatusUnprocessableEntity, err)
+
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", recorder.Code)
+	}
+	want := `{"code":"rateLimited","message":"dualWriteReport 7a6f0d0e-3c2b-4f4e-8f6d-1b2c3d4e5f60 has been changed too often, try again later"}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Redacted stands in for the value of an `apply:"sensitive"` field wherever
+// the apply reports it: FieldChange's JSON and fmt output, audit entries and
+// decode errors. The value itself is still applied to the target.
+const Redacted = "[REDACTED]"
+
+func isSensitive(field reflect.StructField) bool {
+	return hasApplyOption(field, "sensitive")
+}
+
+// holdsSensitive reports whether the type is (or points to, or holds) a
+// struct with a sensitive field, whose changes are reported whole and so must
+// be redacted too
+func holdsSensitive(t reflect.Type) bool {
+	return holdsSensitiveField(t, map[reflect.Type]bool{})
+}
+
+func holdsSensitiveField(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if isSensitive(field) || holdsSensitiveField(field.Type, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MarshalJSON renders the change with Redacted in place of the values of a
+// sensitive field, and dates in place of those of a DateOnly one
+func (c FieldChange) MarshalJSON() ([]byte, error) {
+	type plain FieldChange
+	return json.Marshal(plain(c.rendered()))
+}
+
+// String renders the change for logs, with Redacted in place of the values of a
+// sensitive field and dates in place of those of a DateOnly one, followed by
+// its Elements
+func (c FieldChange) String() string {
+	c = c.rendered()
+	s := fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+	if len(c.Elements) == 0 {
+		return s
+	}
+
+	elements := make([]string, len(c.Elements))
+	for i, element := range c.Elements {
+		elements[i] = element.String()
+	}
+	return s + " (" + strings.Join(elements, "; ") + ")"
+}
+
+// redacted returns the change with its values replaced by Redacted (and no
+// Elements) when the field is sensitive
+func (c FieldChange) redacted() FieldChange {
+	if c.Sensitive {
+		c.Old, c.New = Redacted, Redacted
+		c.Elements = nil
+	}
+
+	return c
+}
+
+// rendered returns the change the way it's output: redacted, with the values
+// of a DateOnly field as dates, and NaN and ±Inf as strings (see
+// finiteValue)
+func (c FieldChange) rendered() FieldChange {
+	c = c.redacted()
+	if c.DateOnly && !c.Sensitive {
+		c.Old, c.New = dateOnlyValue(c.Old), dateOnlyValue(c.New)
+	}
+
+	c.Old, c.New = finiteValue(c.Old), finiteValue(c.New)
+	if len(c.Elements) > 0 {
+		elements := make([]ElementChange, len(c.Elements))
+		for i, element := range c.Elements {
+			element.Key, element.Old, element.New = finiteValue(element.Key), finiteValue(element.Old), finiteValue(element.New)
+			elements[i] = element
+		}
+		c.Elements = elements
+	}
+
+	return c
+}
+
+// redactChanges returns a copy of the changes with the values of sensitive
+// fields replaced by Redacted, for output that must never hold them
+func redactChanges(changes []FieldChange) []FieldChange {
+	if changes == nil {
+		return nil
+	}
+
+	redacted := make([]FieldChange, len(changes))
+	for i, change := range changes {
+		redacted[i] = change.redacted()
+	}
+
+	return redacted
+}
+
+// redactFieldErrors replaces the errors of sensitive fields, which can echo the
+// value that failed to decode
+func redactFieldErrors(err error, structType reflect.Type, tagName string) error {
+	var fieldErrors FieldErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	for i, fieldErr := range fieldErrors {
+		if sensitivePath(structType, tagName, fieldErr.Path) {
+			fieldErrors[i] = &FieldError{Path: fieldErr.Path, Err: errors.New("invalid value " + Redacted)}
+		}
+	}
+
+	return fieldErrors
+}
+
+// sensitivePath reports whether the field at a FieldError path (e.g.
+// "addresses[2].zip") is sensitive or is inside a sensitive field
+func sensitivePath(structType reflect.Type, tagName string, path string) bool {
+	t := structType
+	for _, segment := range strings.Split(path, ".") {
+		if i := strings.IndexByte(segment, '['); i >= 0 {
+			segment = segment[:i]
+		}
+
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+
+		field, ok := structFieldByTag(t, tagName, segment)
+		if !ok {
+			return false
+		}
+		if isSensitive(field) {
+			return true
+		}
+
+		t = field.Type
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type redactedContact struct {
+	Pin string `json:"pin" apply:"sensitive"`
+}
+
+type redactedRecord struct {
+	BaseStruct
+	Name     string            `json:"name"`
+	SSN      string            `json:"ssn" apply:"sensitive"`
+	Attempts int               `json:"attempts" apply:"sensitive"`
+	Contact  *redactedContact  `json:"contact"`
+	Vault    map[string]string `json:"vault" apply:"sensitive"`
+}
+
+// unstampedChanges leaves out the changes to the BaseStruct metadata
+func unstampedChanges(changes []FieldChange) []FieldChange {
+	var unstamped []FieldChange
+	for _, change := range changes {
+		if change.Path != "modifiedBy" && change.Path != "modifiedDts" {
+			unstamped = append(unstamped, change)
+		}
+	}
+	return unstamped
+}
+
+func TestSensitiveFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		changes    map[string]interface{}
+		wantString []string
+		wantJSON   string
+		wantErr    []string
+	}{
+		{
+			name:       "top level field",
+			changes:    map[string]interface{}{"ssn": "123-45-6789"},
+			wantString: []string{"ssn: [REDACTED] -> [REDACTED]"},
+			wantJSON:   `[{"path":"ssn","old":"[REDACTED]","new":"[REDACTED]","sensitive":true}]`,
+		},
+		{
+			name:       "struct holding a sensitive field",
+			changes:    map[string]interface{}{"contact": map[string]interface{}{"pin": "4321"}},
+			wantString: []string{"contact: [REDACTED] -> [REDACTED]"},
+			wantJSON:   `[{"path":"contact","old":"[REDACTED]","new":"[REDACTED]","sensitive":true}]`,
+		},
+		{
+			name:       "other fields shown",
+			changes:    map[string]interface{}{"name": "Kim"},
+			wantString: []string{"name:  -> Kim"},
+			wantJSON:   `[{"path":"name","old":"","new":"Kim"}]`,
+		},
+		{
+			name:    "decode error",
+			changes: map[string]interface{}{"attempts": "secret-value"},
+			wantErr: []string{"'attempts': invalid value [REDACTED]"},
+		},
+		{
+			name:    "decode error inside a sensitive field",
+			changes: map[string]interface{}{"vault": map[string]interface{}{"key": []int{4, 2}}},
+			wantErr: []string{"invalid value [REDACTED]"},
+		},
+		{
+			name:    "decode error of a nested sensitive field",
+			changes: map[string]interface{}{"contact": map[string]interface{}{"pin": []string{"leaked"}}},
+			wantErr: []string{"'contact.pin': invalid value [REDACTED]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			record := redactedRecord{}
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, WithAuditSink(sink))
+
+			if tt.wantErr != nil {
+				var fieldErrors FieldErrors
+				if !errors.As(err, &fieldErrors) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want FieldErrors", err)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("ApplyChangesWrapper() error = %q, want it to contain %q", err, want)
+					}
+				}
+				for _, leaked := range []string{"secret-value", "leaked"} {
+					if strings.Contains(err.Error(), leaked) {
+						t.Errorf("ApplyChangesWrapper() error = %q, leaks %q", err, leaked)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			changes := unstampedChanges(result.Changes)
+
+			var gotString []string
+			for _, change := range changes {
+				gotString = append(gotString, change.String())
+			}
+			if !reflect.DeepEqual(gotString, tt.wantString) {
+				t.Errorf("Changes = %q, want %q", gotString, tt.wantString)
+			}
+
+			gotJSON, err := json.Marshal(changes)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(gotJSON) != tt.wantJSON {
+				t.Errorf("json.Marshal(Changes) = %s, want %s", gotJSON, tt.wantJSON)
+			}
+
+			if len(sink.entries) != 1 {
+				t.Fatalf("recorded %d audit entries, want 1", len(sink.entries))
+			}
+			auditJSON, err := json.Marshal(unstampedChanges(sink.entries[0].Changes))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(auditJSON) != tt.wantJSON {
+				t.Errorf("audit Changes = %s, want %s", auditJSON, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestSensitiveFieldsStillApplied(t *testing.T) {
+	record := redactedRecord{SSN: "000-00-0000"}
+	result, err := ApplyChanges(map[string]interface{}{"ssn": "123-45-6789"}, &record)
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	if record.SSN != "123-45-6789" {
+		t.Errorf("SSN = %q, want it applied", record.SSN)
+	}
+	want := FieldChange{Path: "ssn", Old: "000-00-0000", New: "123-45-6789", Sensitive: true}
+	if len(result.Changes) != 1 || !reflect.DeepEqual(result.Changes[0], want) {
+		t.Errorf("Changes = %+v, want %+v with the values kept", result.Changes, want)
+	}
+}
@@ -0,0 +1,74 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrRegistryFrozen is what the Register* functions panic with, wrapped, once
+// Freeze has been called
+var ErrRegistryFrozen = errors.New("registration after Freeze")
+
+var (
+	// registryMu serializes registrations, across every registry
+	registryMu sync.Mutex
+
+	frozen int32
+)
+
+// Freeze ends registration for the process, typically once a service has
+// started: from then on RegisterDecodeHook, RegisterEnum, RegisterScalar,
+// RegisterFastDecoder, RegisterApplier and RegisterTypeConfig panic with
+// ErrRegistryFrozen, since registering while applies run changes their
+// behavior midway through traffic. Freezing again does nothing.
+func Freeze() {
+	atomic.StoreInt32(&frozen, 1)
+}
+
+// Frozen reports whether Freeze has been called, e.g. for health checks
+func Frozen() bool {
+	return atomic.LoadInt32(&frozen) == 1
+}
+
+// register runs a registration for the named Register* function, one at a
+// time, panicking once the registries are frozen
+func register(name string, update func()) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if Frozen() {
+		panic(fmt.Errorf("%s: %w", name, ErrRegistryFrozen))
+	}
+	update()
+}
+
+// registry is a copy-on-write map by type: a registration (under register)
+// stores a new copy with its entry, so applies read the map without locking
+// and never see a registration half done
+type registry[V any] struct {
+	entries atomic.Value // map[reflect.Type]V
+}
+
+func (r *registry[V]) load() map[reflect.Type]V {
+	entries, _ := r.entries.Load().(map[reflect.Type]V)
+	return entries
+}
+
+func (r *registry[V]) get(t reflect.Type) (V, bool) {
+	value, ok := r.load()[t]
+	return value, ok
+}
+
+// set replaces the entry for the type, under register
+func (r *registry[V]) set(t reflect.Type, value V) {
+	entries := make(map[reflect.Type]V, len(r.load())+1)
+	for k, v := range r.load() {
+		entries[k] = v
+	}
+	entries[t] = value
+
+	r.entries.Store(entries)
+}
@@ -0,0 +1,139 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// remove deletes the entry for the type, so tests can undo their registrations
+func (r *registry[V]) remove(t reflect.Type) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	entries := make(map[reflect.Type]V, len(r.load()))
+	for k, v := range r.load() {
+		if k != t {
+			entries[k] = v
+		}
+	}
+	r.entries.Store(entries)
+}
+
+// freezeForTest freezes the registries until the test is done
+func freezeForTest(t *testing.T) {
+	Freeze()
+	t.Cleanup(func() {
+		atomic.StoreInt32(&frozen, 0)
+	})
+}
+
+type frozenStatus string
+
+type frozenRecord struct {
+	Status frozenStatus `json:"status"`
+}
+
+func TestFreeze(t *testing.T) {
+	statusType := reflect.TypeOf(frozenStatus(""))
+	recordType := reflect.TypeOf(frozenRecord{})
+	defer enums.remove(statusType)
+	defer scalars.remove(statusType)
+	defer fastDecoders.remove(recordType)
+	defer generatedAppliers.remove(recordType)
+	defer typeConfigs.remove(recordType)
+	defer registeredDecodeHooks.Store(loadDecodeHooks())
+
+	tests := []struct {
+		name     string
+		register func()
+	}{
+		{name: "RegisterDecodeHook", register: func() { RegisterDecodeHook(noopHook) }},
+		{name: "RegisterEnum", register: func() { RegisterEnum[frozenStatus]("open", "closed") }},
+		{name: "RegisterScalar", register: func() {
+			RegisterScalar(func(value interface{}) (frozenStatus, error) { return frozenStatus("open"), nil })
+		}},
+		{name: "RegisterFastDecoder", register: func() {
+			RegisterFastDecoder("json", func(changes map[string]interface{}, to *frozenRecord) bool { return false })
+		}},
+		{name: "RegisterApplier", register: func() {
+			RegisterApplier(func(changes map[string]interface{}, modifier string, to *frozenRecord) (ApplyResult, error) {
+				return ApplyResult{}, nil
+			})
+		}},
+		{name: "RegisterTypeConfig", register: func() { RegisterTypeConfig[frozenRecord](WithTagName("json")) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.register()
+
+			freezeForTest(t)
+			if !Frozen() {
+				t.Fatal("Frozen() = false after Freeze()")
+			}
+
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, ErrRegistryFrozen) || err.Error() != tt.name+": registration after Freeze" {
+					t.Errorf("registering after Freeze() panicked with %v, want %s: %v", err, tt.name, ErrRegistryFrozen)
+				}
+			}()
+			tt.register()
+		})
+	}
+
+	if Frozen() {
+		t.Error("Frozen() = true, want the tests to have thawed the registries")
+	}
+}
+
+func TestFreezeKeepsRegistrations(t *testing.T) {
+	RegisterEnum[frozenStatus]("open", "closed")
+	defer enums.remove(reflect.TypeOf(frozenStatus("")))
+	freezeForTest(t)
+
+	record := frozenRecord{Status: "open"}
+	var invalid *InvalidEnumError
+	if _, err := ApplyChanges(map[string]interface{}{"status": "pending"}, &record); !errors.As(err, &invalid) {
+		t.Errorf("ApplyChanges() error = %v, want the enum registered before Freeze() checked", err)
+	}
+	if _, err := ApplyChanges(map[string]interface{}{"status": "closed"}, &record); err != nil || record.Status != "closed" {
+		t.Errorf("ApplyChanges() = %v, %v, want the status closed", record, err)
+	}
+}
+
+// TestRegistrationsDuringApplies is meant for -race: every apply sees the
+// registries either before or after each registration
+func TestRegistrationsDuringApplies(t *testing.T) {
+	defer enums.remove(reflect.TypeOf(frozenStatus("")))
+	defer typeConfigs.remove(reflect.TypeOf(frozenRecord{}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				record := frozenRecord{}
+				_, err := ApplyChanges(map[string]interface{}{"status": "open"}, &record)
+				var invalid *InvalidEnumError
+				if err != nil && !errors.As(err, &invalid) {
+					t.Errorf("ApplyChanges() error = %v", err)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		if i%2 == 0 {
+			RegisterEnum[frozenStatus]("open", "closed")
+		} else {
+			RegisterEnum[frozenStatus]("closed")
+		}
+		RegisterTypeConfig[frozenRecord](WithModifiedDts(i%2 == 0))
+	}
+	wg.Wait()
+}
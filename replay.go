@@ -0,0 +1,201 @@
+package applychanges
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ReplayError is returned by Replay for an audit entry that can't be replayed on
+// top of the ones before it
+type ReplayError struct {
+	// Entry is the index of the entry in the slice given to Replay
+	Entry int
+
+	// Reason says what's wrong with it, e.g. that it's for another target or
+	// that an entry before it is missing
+	Reason string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replaying audit entry %d: %s", e.Entry, e.Reason)
+}
+
+// replayClock is the Clock of a replayed apply, stuck at the entry's timestamp
+type replayClock time.Time
+
+func (c replayClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// Replay rebuilds the state of an entity at a point in time from the audit
+// entries recorded for it (see WithAuditSink), by applying the new values of
+// each entry's changes to a copy of base in timestamp order. Entries after
+// until are skipped.
+//
+// Each entry is applied through the usual path (options apply as usual, apart
+// from merging or encrypting the values, which are recorded as stored), as an
+// apply by the entry's modifier at the entry's timestamp, so the metadata is
+// stamped as it was rather than by the clock. Nothing is audited, published or
+// kept in a History.
+//
+// The entries must be for base's type and ID, and each one must change the
+// fields from the values the entries before it left them with; a missing entry
+// shows up as a ReplayError, as does an entry holding Redacted values or one
+// recorded under an incompatible BehaviorVersion (see CompatibleWith). Entries
+// that went through JSON replay the same as those handed to the sink, as long
+// as they're decoded with UseNumber so large integers keep their precision.
+func Replay[T any](base T, entries []AuditEntry, until time.Time, opts ...Option) (T, error) {
+	replayed := deepCopy(reflect.ValueOf(&base).Elem()).Addr().Interface().(*T)
+	target, ok := targetStruct(replayed)
+	if !ok {
+		return base, fmt.Errorf("changes can only be applied to structs, not %T", base)
+	}
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return entries[order[i]].Timestamp.Before(entries[order[j]].Timestamp)
+	})
+
+	targetID := ""
+	if field, ok := structFieldByName(target.Type(), "ID"); ok {
+		targetID = fmt.Sprint(target.FieldByIndex(field.Index).Interface())
+	}
+
+	for _, i := range order {
+		entry := entries[i]
+		if entry.Timestamp.After(until) {
+			break
+		}
+
+		switch {
+		case entry.TargetType != target.Type().Name():
+			return base, &ReplayError{Entry: i, Reason: fmt.Sprintf("it is for a %s, not a %s", entry.TargetType, target.Type().Name())}
+		case entry.TargetID != targetID:
+			return base, &ReplayError{Entry: i, Reason: fmt.Sprintf("it is for %s, not %s", entry.TargetID, targetID)}
+		case !CompatibleWith(entry.BehaviorVersion):
+			return base, &ReplayError{Entry: i, Reason: fmt.Sprintf("it was applied under BehaviorVersion %s, which %s can't reproduce", entry.BehaviorVersion, BehaviorVersion)}
+		}
+
+		cfg := configFor(reflect.TypeOf(replayed), opts)
+		cfg.modifier, cfg.principal, cfg.impersonation = &entry.Modifier, entry.Principal, nil
+		cfg.clock = replayClock(entry.Timestamp)
+		cfg.restoring = true
+		cfg.auditSink, cfg.eventPublisher, cfg.history = nil, nil, nil
+		cfg.idempotencyKey, cfg.contentDerivedKey = "", false
+
+		changes, deleting, err := replayChanges(entry, target, cfg)
+		if err != nil {
+			return base, &ReplayError{Entry: i, Reason: err.Error()}
+		}
+		cfg.deleting = deleting
+
+		if _, err := applyChanges(changes, replayed, cfg); err != nil {
+			return base, &ReplayError{Entry: i, Reason: err.Error()}
+		}
+	}
+
+	return *replayed, nil
+}
+
+// replayChanges returns the changes to apply for an entry: the new value of
+// every field but the stamped metadata, which is stamped anew. The old values
+// must match the target's current ones. An entry stamping the deletion fields
+// is a deletion, see ApplyDelete.
+func replayChanges(entry AuditEntry, target reflect.Value, cfg *config) (map[string]interface{}, bool, error) {
+	changes := map[string]interface{}{}
+	deleting := false
+
+	for _, change := range entry.Changes {
+		field, ok := structFieldByTag(target.Type(), cfg.tagName, change.Path)
+		if !ok {
+			return nil, false, fmt.Errorf("%s has no field '%s'", target.Type().Name(), change.Path)
+		}
+		if change.New == Redacted || change.Old == Redacted {
+			return nil, false, fmt.Errorf("'%s' was redacted from it", change.Path)
+		}
+		if change.Truncated {
+			return nil, false, fmt.Errorf("'%s' was truncated in it", change.Path)
+		}
+
+		current := fieldChangeValue(target.FieldByIndex(field.Index))
+		old := change.Old
+		if isDateOnly(field) {
+			current, old = dateOnlyValue(current), dateOnlyValue(old)
+		}
+		same, err := sameJSON(old, current)
+		if err != nil {
+			return nil, false, fmt.Errorf("'%s': %w", change.Path, err)
+		}
+		if !same {
+			return nil, false, fmt.Errorf("it changes '%s' from %v, but it was %v: an entry before it is missing", change.Path, change.Old, current)
+		}
+
+		switch {
+		case isDeletionField(field.Name):
+			deleting = true
+		case !isBookkeeping(field.Name):
+			if changes[change.Path], err = jsonValue(change.New); err != nil {
+				return nil, false, fmt.Errorf("'%s': %w", change.Path, err)
+			}
+		}
+	}
+
+	if deleting && len(changes) > 0 {
+		return nil, false, fmt.Errorf("it deletes the target along with other changes")
+	}
+
+	return changes, deleting, nil
+}
+
+// isDeletionField reports whether the field (by Go name) is stamped by
+// ApplyDelete
+func isDeletionField(name string) bool {
+	for _, deletion := range deletionFields {
+		if name == deletion {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonValue returns the value the way it reads back from JSON, so values
+// handed to an AuditSink replay the same as those stored and read back
+func jsonValue(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+// sameJSON reports whether two values read back from JSON the same
+func sameJSON(a, b interface{}) (bool, error) {
+	decodedA, err := jsonValue(a)
+	if err != nil {
+		return false, err
+	}
+
+	decodedB, err := jsonValue(b)
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(decodedA, decodedB), nil
+}
@@ -0,0 +1,174 @@
+package applychanges
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type replayedRecord struct {
+	BaseStruct
+	Name            string         `json:"name"`
+	Count           int64          `json:"count"`
+	Tags            []string       `json:"tags"`
+	Details         *nestedDetails `json:"details"`
+	RecentModifiers []string       `json:"recentModifiers"`
+	LockVersion     int64          `json:"lockVersion"`
+}
+
+// replayedHistory applies three changesets (and optionally a delete) to a new
+// record at one-hour intervals, returning the record before and after along
+// with the audit entries recorded
+func replayedHistory(t *testing.T, deleted bool) (replayedRecord, replayedRecord, []AuditEntry) {
+	t.Helper()
+
+	base := replayedRecord{BaseStruct: NewBaseStruct("EUA0")}
+	base.ID = uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	record := base
+
+	sink := &recordingSink{}
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, changes := range []map[string]interface{}{
+		{"name": "draft", "count": 1, "tags": []interface{}{"a"}},
+		{"count": 9007199254740993, "details": map[string]interface{}{"source": "radar", "level": 2}},
+		{"name": "final", "tags": []interface{}{"a", "b"}, "details": nil},
+	} {
+		clock := WithClock(fixedClock(start.Add(time.Duration(i) * time.Hour)))
+		if _, err := ApplyChangesWrapper(changes, []string{"EUA1", "EUA2", "EUA1"}[i], &record, clock, WithAuditSink(sink)); err != nil {
+			t.Fatalf("ApplyChangesWrapper() error = %v", err)
+		}
+	}
+
+	if deleted {
+		if _, err := ApplyDelete("EUA3", &record, WithClock(fixedClock(start.Add(3*time.Hour))), WithAuditSink(sink)); err != nil {
+			t.Fatalf("ApplyDelete() error = %v", err)
+		}
+	}
+
+	return base, record, sink.entries
+}
+
+func TestReplay(t *testing.T) {
+	base, want, entries := replayedHistory(t, true)
+	start := entries[0].Timestamp
+
+	stored := make([]AuditEntry, len(entries))
+	for i, entry := range entries {
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(encoded))
+		decoder.UseNumber()
+		if err := decoder.Decode(&stored[i]); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+	}
+
+	reversed := []AuditEntry{entries[3], entries[2], entries[1], entries[0]}
+
+	tests := []struct {
+		name    string
+		entries []AuditEntry
+		until   time.Time
+		want    func() replayedRecord
+	}{
+		{name: "every entry", entries: entries, until: start.Add(24 * time.Hour), want: func() replayedRecord { return want }},
+		{name: "read back from JSON", entries: stored, until: start.Add(24 * time.Hour), want: func() replayedRecord { return want }},
+		{name: "out of order", entries: reversed, until: start.Add(24 * time.Hour), want: func() replayedRecord { return want }},
+		{name: "none", until: start, want: func() replayedRecord { return base }},
+		{
+			name:    "until the second",
+			entries: entries,
+			until:   start.Add(90 * time.Minute),
+			want: func() replayedRecord {
+				record := base
+				_, _ = ApplyChangesWrapper(map[string]interface{}{"name": "draft", "count": 1, "tags": []interface{}{"a"}}, "EUA1", &record, WithClock(fixedClock(start)))
+				_, _ = ApplyChangesWrapper(map[string]interface{}{"count": 9007199254740993, "details": map[string]interface{}{"source": "radar", "level": 2}}, "EUA2", &record, WithClock(fixedClock(start.Add(time.Hour))))
+				return record
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Replay(base, tt.entries, tt.until)
+			if err != nil {
+				t.Fatalf("Replay() error = %v", err)
+			}
+			if want := tt.want(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Replay() = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	if base.ModifiedBy != nil || base.Name != "" {
+		t.Errorf("base = %+v, want it untouched", base)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	base, _, entries := replayedHistory(t, false)
+
+	otherID := append([]AuditEntry(nil), entries...)
+	otherID[1].TargetID = uuid.NewString()
+
+	otherType := append([]AuditEntry(nil), entries...)
+	otherType[0].TargetType = "nestedRecord"
+
+	incompatible := append([]AuditEntry(nil), entries...)
+	incompatible[2].BehaviorVersion = "1.0.0"
+
+	redacted := append([]AuditEntry(nil), entries...)
+	redacted[0].Changes = redacted[0].Changes[:len(redacted[0].Changes):len(redacted[0].Changes)]
+	redacted[0].Changes = append(redacted[0].Changes, FieldChange{Path: "tags", Old: nil, New: Redacted, Sensitive: true})
+
+	tests := []struct {
+		name    string
+		entries []AuditEntry
+		entry   int
+		reason  string
+	}{
+		{name: "another target", entries: otherID, entry: 1, reason: "it is for"},
+		{name: "another type", entries: otherType, entry: 0, reason: "it is for a nestedRecord"},
+		{name: "missing entry", entries: []AuditEntry{entries[0], entries[2]}, entry: 1, reason: "an entry before it is missing"},
+		{name: "incompatible behavior version", entries: incompatible, entry: 2, reason: "BehaviorVersion 1.0.0"},
+		{name: "redacted", entries: redacted, entry: 0, reason: "'tags' was redacted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Replay(base, tt.entries, entries[2].Timestamp)
+			var replayErr *ReplayError
+			if !errors.As(err, &replayErr) {
+				t.Fatalf("Replay() error = %v, want a ReplayError", err)
+			}
+			if replayErr.Entry != tt.entry || !strings.Contains(replayErr.Reason, tt.reason) {
+				t.Errorf("ReplayError = %v, want entry %d: %s", replayErr, tt.entry, tt.reason)
+			}
+			if !reflect.DeepEqual(got, base) {
+				t.Errorf("Replay() = %+v, want base back", got)
+			}
+		})
+	}
+}
+
+func TestReplayHasNoSideEffects(t *testing.T) {
+	base, _, entries := replayedHistory(t, false)
+
+	sink := &recordingSink{}
+	publisher := &recordingPublisher{}
+	if _, err := Replay(base, entries, entries[2].Timestamp, WithAuditSink(sink), WithEventPublisher(publisher), WithContext(context.Background())); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if len(sink.entries) != 0 || len(publisher.events) != 0 {
+		t.Errorf("Replay() recorded %d audit entries and %d events, want none", len(sink.entries), len(publisher.events))
+	}
+}
@@ -0,0 +1,81 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+)
+
+// Repository loads and stores the entities changes are applied to, see
+// ApplyAndSave
+type Repository[T any] interface {
+	Get(ctx context.Context, id uuid.UUID) (*T, error)
+	Save(ctx context.Context, entity *T) error
+}
+
+// ApplyAndSave fetches the entity, applies the changes to it with Apply and
+// saves it, returning the saved entity. Options apply as usual (WithValidator
+// runs before anything is saved, WithDryRun skips the save), and ctx is passed
+// on to the AuditSink and EventPublisher, which are only given the entry and
+// event once the save succeeded.
+func ApplyAndSave[T any](ctx context.Context, repo Repository[T], id uuid.UUID, changes map[string]any, modifier string, opts ...Option) (*T, ApplyResult, error) {
+	entity, err := repo.Get(ctx, id)
+	if err != nil {
+		return nil, ApplyResult{}, err
+	}
+
+	cfg := configFor(reflect.TypeOf((*T)(nil)).Elem(), opts)
+	audit := &deferredAudit{}
+	event := &deferredEvent{}
+	applyOpts := append(append([]Option(nil), opts...), WithContext(ctx), WithAuditSink(audit), WithEventPublisher(event))
+
+	result, err := Apply(changes, modifier, entity, applyOpts...)
+	if err != nil {
+		return nil, ApplyResult{}, err
+	}
+
+	if cfg.dryRun {
+		return entity, result, nil
+	}
+
+	if err := repo.Save(ctx, entity); err != nil {
+		return nil, ApplyResult{}, err
+	}
+
+	if cfg.auditSink != nil && audit.entry != nil {
+		if err := cfg.emit(ctx, func() error { return cfg.auditSink.Record(ctx, *audit.entry) }); err != nil {
+			return entity, result, fmt.Errorf("recording audit entry: %w", err)
+		}
+	}
+
+	if cfg.eventPublisher != nil && event.event != nil {
+		if err := cfg.emit(ctx, func() error { return cfg.eventPublisher.Publish(ctx, *event.event) }); err != nil {
+			return entity, result, fmt.Errorf("publishing change event: %w", err)
+		}
+	}
+
+	return entity, result, nil
+}
+
+// deferredAudit holds on to the audit entry of an apply until it has been
+// saved
+type deferredAudit struct {
+	entry *AuditEntry
+}
+
+func (d *deferredAudit) Record(_ context.Context, entry AuditEntry) error {
+	d.entry = &entry
+	return nil
+}
+
+// deferredEvent holds on to the event of an apply until it has been saved
+type deferredEvent struct {
+	event *ChangeApplied
+}
+
+func (d *deferredEvent) Publish(_ context.Context, event ChangeApplied) error {
+	d.event = &event
+	return nil
+}
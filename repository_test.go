@@ -0,0 +1,132 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// memoryRepository stores nestedRecords by ID
+type memoryRepository struct {
+	records map[uuid.UUID]nestedRecord
+	saveErr error
+	saves   int
+}
+
+func (r *memoryRepository) Get(_ context.Context, id uuid.UUID) (*nestedRecord, error) {
+	record, ok := r.records[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return &record, nil
+}
+
+func (r *memoryRepository) Save(_ context.Context, record *nestedRecord) error {
+	r.saves++
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+
+	r.records[record.ID] = *record
+	return nil
+}
+
+func TestApplyAndSave(t *testing.T) {
+	id := uuid.MustParse("8a7c3b4e-2f1d-4e5a-9b6c-7d8e9f0a1b2c")
+	saveErr := errors.New("database unavailable")
+
+	tests := []struct {
+		name      string
+		id        uuid.UUID
+		changes   map[string]interface{}
+		opts      []Option
+		saveErr   error
+		wantErr   bool
+		wantSaves int
+		wantName  string
+		wantAudit int
+	}{
+		{
+			name:      "saved",
+			id:        id,
+			changes:   map[string]interface{}{"name": "Tampa"},
+			wantSaves: 1,
+			wantName:  "Tampa",
+			wantAudit: 1,
+		},
+		{
+			name:     "not found",
+			id:       uuid.New(),
+			changes:  map[string]interface{}{"name": "Tampa"},
+			wantErr:  true,
+			wantName: "Miami",
+		},
+		{
+			name:     "apply fails",
+			id:       id,
+			changes:  map[string]interface{}{"count": "many"},
+			wantErr:  true,
+			wantName: "Miami",
+		},
+		{
+			name:      "save fails",
+			id:        id,
+			changes:   map[string]interface{}{"name": "Tampa"},
+			saveErr:   saveErr,
+			wantErr:   true,
+			wantSaves: 1,
+			wantName:  "Miami",
+		},
+		{
+			name:     "dry run",
+			id:       id,
+			changes:  map[string]interface{}{"name": "Tampa"},
+			opts:     []Option{WithDryRun()},
+			wantName: "Miami",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryRepository{records: map[uuid.UUID]nestedRecord{id: {BaseStruct: BaseStruct{ID: id}, Name: "Miami"}}, saveErr: tt.saveErr}
+			sink := &recordingSink{}
+			publisher := &recordingPublisher{}
+			opts := append([]Option{WithAuditSink(sink), WithEventPublisher(publisher)}, tt.opts...)
+
+			entity, _, err := ApplyAndSave[nestedRecord](context.Background(), repo, tt.id, tt.changes, "EUA1", opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyAndSave() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.saveErr != nil && !errors.Is(err, tt.saveErr) {
+				t.Errorf("ApplyAndSave() error = %v, want %v", err, tt.saveErr)
+			}
+			if !tt.wantErr && (entity == nil || entity.Name != tt.wantName) {
+				t.Errorf("ApplyAndSave() entity = %+v, want name %q", entity, tt.wantName)
+			}
+
+			if repo.saves != tt.wantSaves || repo.records[id].Name != tt.wantName {
+				t.Errorf("saved %d times with name %q, want %d with %q", repo.saves, repo.records[id].Name, tt.wantSaves, tt.wantName)
+			}
+			if len(sink.entries) != tt.wantAudit || len(publisher.events) != tt.wantAudit {
+				t.Errorf("audited %d and published %d, want %d of each", len(sink.entries), len(publisher.events), tt.wantAudit)
+			}
+		})
+	}
+}
+
+func TestApplyAndSaveAuditError(t *testing.T) {
+	id := uuid.New()
+	repo := &memoryRepository{records: map[uuid.UUID]nestedRecord{id: {BaseStruct: BaseStruct{ID: id}}}}
+	failure := errors.New("audit log unavailable")
+
+	entity, _, err := ApplyAndSave[nestedRecord](context.Background(), repo, id, map[string]interface{}{"name": "Tampa"}, "EUA1", WithAuditSink(&recordingSink{err: failure}))
+	if !errors.Is(err, failure) {
+		t.Fatalf("ApplyAndSave() error = %v, want %v", err, failure)
+	}
+	if entity == nil || repo.records[id].Name != "Tampa" {
+		t.Errorf("ApplyAndSave() entity = %+v, want it saved before the audit failed", entity)
+	}
+}
@@ -0,0 +1,137 @@
+package applychanges
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ApplyResult describes what an apply did to its target
+type ApplyResult struct {
+	// AppliedFields are the keys of the top-level fields the changes were
+	// applied to (including stamped metadata), sorted. Keys are reported as
+	// the field's tag name even when the changes matched it case-insensitively
+	// (see WithCaseInsensitiveKeys).
+	AppliedFields []string
+
+	// Changes holds the value of each applied field before and after the
+	// apply, in the same order as AppliedFields
+	Changes []FieldChange
+
+	// DroppedFields are the keys removed from the changes by
+	// WithDropDisallowedFields, followed by the paths removed by
+	// WithSkipImmutableFields, each sorted
+	DroppedFields []string
+
+	// UnknownFields are the keys that didn't match any field and were ignored
+	// (see WithIgnoreUnknownFields), sorted; nested keys are given by path
+	UnknownFields []string
+
+	// SkippedFields are the changes a decode hook skipped by returning
+	// SkipField, and the children left as they were WithPartialChildren,
+	// sorted by path
+	SkippedFields []SkippedField
+
+	// Duplicate is set on the result stored for an idempotency key the apply
+	// repeated (see IdempotencyStore), in which case nothing was applied
+	Duplicate bool
+
+	// Timings are how long each of the TimingStages took, WithTimings; nil
+	// otherwise
+	Timings map[string]time.Duration
+}
+
+// FieldChange is the before and after value of a single applied field. Pointer
+// fields are reported by what they point to, so a nil pointer is a nil value.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+
+	// Sensitive is set for `apply:"sensitive"` fields and the structs holding
+	// them, whose values are Redacted when the change is marshalled or printed
+	// (Old and New still hold them, e.g. for BuildUpdateSQL)
+	Sensitive bool `json:"sensitive,omitempty"`
+
+	// DateOnly is set for `applyas:"date"` fields, whose time.Time values are
+	// rendered as dates ("2006-01-02") when the change is marshalled, printed
+	// or written to the change log
+	DateOnly bool `json:"dateOnly,omitempty"`
+
+	// Elements breaks a change to a slice of scalars, or of structs merged by
+	// key (see MergeSliceByKey), down into the elements added, removed and
+	// modified. It is left out for sensitive fields.
+	Elements []ElementChange `json:"elements,omitempty"`
+
+	// Truncated is set when Old or New was cut short by WithMaxDiffValueBytes
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// appliedFields resolves the keys left in the changes to the fields they will
+// be decoded into, along with the keys already applied while preparing them
+func appliedFields(changes map[string]interface{}, structType reflect.Type, tagName string, alreadyApplied []string) []string {
+	seen := map[string]bool{}
+	fields := []string{}
+
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			fields = append(fields, key)
+		}
+	}
+
+	for _, key := range alreadyApplied {
+		add(key)
+	}
+
+	for key := range changes {
+		if field, ok := structFieldByTag(structType, tagName, key); ok {
+			add(fieldKey(field, tagName))
+		}
+	}
+
+	sort.Strings(fields)
+	return fields
+}
+
+// diffFields pairs the snapshot taken before the apply (see undoLog) with the
+// current values of the applied fields
+func diffFields(before map[string]interface{}, target reflect.Value, cfg *config, applied []string) []FieldChange {
+	changes := make([]FieldChange, 0, len(applied))
+	for _, key := range applied {
+		field, ok := structFieldByTag(target.Type(), cfg.tagName, key)
+		if !ok {
+			continue
+		}
+
+		change := FieldChange{
+			Path:      key,
+			Old:       before[key],
+			New:       fieldChangeValue(deepCopy(target.FieldByIndex(field.Index))),
+			Sensitive: isSensitive(field) || holdsSensitive(field.Type),
+			DateOnly:  isDateOnly(field),
+		}
+		if field.Type.Kind() == reflect.Slice {
+			change.Elements = elementChanges(change.Old, change.New, field, key, cfg)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// fieldChangeValue unwraps pointer fields into what they point to (or nil)
+func fieldChangeValue(value reflect.Value) interface{} {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if !value.CanInterface() {
+		return nil
+	}
+
+	return value.Interface()
+}
@@ -0,0 +1,164 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type resultRecord struct {
+	BaseStruct
+	City    string         `json:"city"`
+	Weather *string        `json:"weather"`
+	Details *nestedDetails `json:"details"`
+}
+
+func TestAppliedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+		want    []string
+	}{
+		{
+			name:    "with the stamped metadata",
+			changes: map[string]interface{}{"weather": "Fog", "city": "Tampa"},
+			want:    []string{"city", "modifiedBy", "modifiedDts", "weather"},
+		},
+		{
+			name:    "nested changes by their top-level field",
+			changes: map[string]interface{}{"details": map[string]interface{}{"level": 3}},
+			want:    []string{"details", "modifiedBy", "modifiedDts"},
+		},
+		{
+			name:    "case-insensitive keys by tag name",
+			changes: map[string]interface{}{"City": "Tampa"},
+			opts:    []Option{WithCaseInsensitiveKeys()},
+			want:    []string{"city", "modifiedBy", "modifiedDts"},
+		},
+		{
+			name:    "not the unknown keys",
+			changes: map[string]interface{}{"city": "Tampa", "zip": "33602"},
+			opts:    []Option{WithIgnoreUnknownFields()},
+			want:    []string{"city", "modifiedBy", "modifiedDts"},
+		},
+		{
+			name:    "not the dropped keys",
+			changes: map[string]interface{}{"city": "Tampa", "weather": "Fog"},
+			opts:    []Option{WithAllowedFields("city"), WithDropDisallowedFields()},
+			want:    []string{"city", "modifiedBy", "modifiedDts"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := resultRecord{City: "Miami"}
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, tt.opts...)
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(result.AppliedFields, tt.want) {
+				t.Errorf("AppliedFields = %v, want %v", result.AppliedFields, tt.want)
+			}
+		})
+	}
+}
+
+func TestChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    []FieldChange
+	}{
+		{
+			name:    "old and new values",
+			changes: map[string]interface{}{"city": "Tampa"},
+			want:    []FieldChange{{Path: "city", Old: "Miami", New: "Tampa"}},
+		},
+		{
+			name:    "pointers by what they point to",
+			changes: map[string]interface{}{"weather": "Fog"},
+			want:    []FieldChange{{Path: "weather", Old: nil, New: "Fog"}},
+		},
+		{
+			name:    "nested structs as a whole",
+			changes: map[string]interface{}{"details": map[string]interface{}{"level": 3}},
+			want:    []FieldChange{{Path: "details", Old: nestedDetails{Source: "survey", Level: 2}, New: nestedDetails{Source: "survey", Level: 3}}},
+		},
+		{
+			name:    "unchanged values",
+			changes: map[string]interface{}{"city": "Miami"},
+			want:    []FieldChange{{Path: "city", Old: "Miami", New: "Miami"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := resultRecord{City: "Miami", Details: &nestedDetails{Source: "survey", Level: 2}}
+			details := record.Details
+			result, err := ApplyChanges(tt.changes, &record)
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Changes, tt.want) {
+				t.Errorf("Changes = %+v, want %+v", result.Changes, tt.want)
+			}
+			if len(result.Changes) != len(result.AppliedFields) || result.Changes[0].Path != result.AppliedFields[0] {
+				t.Errorf("Changes = %+v, want them in the order of %v", result.Changes, result.AppliedFields)
+			}
+
+			details.Level = 9
+			if old, ok := result.Changes[0].Old.(nestedDetails); ok && old.Level == 9 {
+				t.Error("Changes share the target's pointers, want them copied")
+			}
+		})
+	}
+}
+
+func TestWithIgnoreUnknownFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    []string
+	}{
+		{
+			name:    "none",
+			changes: map[string]interface{}{"city": "Tampa"},
+		},
+		{
+			name:    "top-level keys, sorted",
+			changes: map[string]interface{}{"city": "Tampa", "zip": "33602", "state": "FL"},
+			want:    []string{"state", "zip"},
+		},
+		{
+			name:    "nested keys by path",
+			changes: map[string]interface{}{"details": map[string]interface{}{"level": 3, "extra": true}, "zip": "33602"},
+			want:    []string{"details.extra", "zip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := resultRecord{City: "Miami"}
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, WithIgnoreUnknownFields())
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(result.UnknownFields, tt.want) {
+				t.Errorf("UnknownFields = %v, want %v", result.UnknownFields, tt.want)
+			}
+			if city, ok := tt.changes["city"]; ok && record.City != city {
+				t.Errorf("City = %q, want the known keys applied", record.City)
+			}
+
+			if tt.want != nil {
+				record = resultRecord{City: "Miami"}
+				if _, err := ApplyChangesWrapper(tt.changes, "EUA1", &record); err == nil {
+					t.Error("ApplyChangesWrapper() error = nil without WithIgnoreUnknownFields")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// emitterRetry is how often, and how far apart, the AuditSink and
+// EventPublisher are tried, see WithEmitterRetry
+type emitterRetry struct {
+	attempts int
+	backoff  func(attempt int) time.Duration
+}
+
+// emit hands something to the AuditSink or EventPublisher with send, trying
+// again after a failure as configured with WithEmitterRetry. It gives up early
+// when ctx is done, or would be before the next attempt: the last error is
+// returned either way.
+func (cfg *config) emit(ctx context.Context, send func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || cfg.emitterRetry == nil || attempt >= cfg.emitterRetry.attempts {
+			return err
+		}
+
+		var wait time.Duration
+		if cfg.emitterRetry.backoff != nil {
+			wait = cfg.emitterRetry.backoff(attempt)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return fmt.Errorf("%w (not retried: the deadline is before the next attempt)", err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (retrying stopped: %v)", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyEmitter fails the first failures calls it gets, as an AuditSink and an
+// EventPublisher
+type flakyEmitter struct {
+	failures int
+	calls    int
+	onFail   func()
+}
+
+var errFlaky = errors.New("connection reset")
+
+func (e *flakyEmitter) send() error {
+	e.calls++
+	if e.calls > e.failures {
+		return nil
+	}
+	if e.onFail != nil {
+		e.onFail()
+	}
+	return errFlaky
+}
+
+func (e *flakyEmitter) Record(context.Context, AuditEntry) error {
+	return e.send()
+}
+
+func (e *flakyEmitter) Publish(context.Context, ChangeApplied) error {
+	return e.send()
+}
+
+func TestWithEmitterRetry(t *testing.T) {
+	noWait := func(int) time.Duration { return 0 }
+
+	tests := []struct {
+		name      string
+		failures  int
+		opts      func(emitter *flakyEmitter) []Option
+		wantCalls int
+		wantErr   string
+	}{
+		{
+			name:      "audit succeeds on the second attempt",
+			failures:  1,
+			opts:      func(e *flakyEmitter) []Option { return []Option{WithAuditSink(e), WithEmitterRetry(3, noWait)} },
+			wantCalls: 2,
+		},
+		{
+			name:      "event succeeds on the third attempt",
+			failures:  2,
+			opts:      func(e *flakyEmitter) []Option { return []Option{WithEventPublisher(e), WithEmitterRetry(3, nil)} },
+			wantCalls: 3,
+		},
+		{
+			name:      "retries exhausted",
+			failures:  5,
+			opts:      func(e *flakyEmitter) []Option { return []Option{WithAuditSink(e), WithEmitterRetry(3, noWait)} },
+			wantCalls: 3,
+			wantErr:   "connection reset",
+		},
+		{
+			name:      "no retry by default",
+			failures:  1,
+			opts:      func(e *flakyEmitter) []Option { return []Option{WithAuditSink(e)} },
+			wantCalls: 1,
+			wantErr:   "connection reset",
+		},
+		{
+			name:     "cancelled during the backoff",
+			failures: 5,
+			opts: func(e *flakyEmitter) []Option {
+				ctx, cancel := context.WithCancel(context.Background())
+				e.onFail = cancel
+				return []Option{WithContext(ctx), WithAuditSink(e), WithEmitterRetry(3, func(int) time.Duration { return time.Hour })}
+			},
+			wantCalls: 1,
+			wantErr:   "retrying stopped: context canceled",
+		},
+		{
+			name:     "deadline before the next attempt",
+			failures: 5,
+			opts: func(e *flakyEmitter) []Option {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				t.Cleanup(cancel)
+				return []Option{WithContext(ctx), WithAuditSink(e), WithEmitterRetry(3, func(int) time.Duration { return time.Hour })}
+			},
+			wantCalls: 1,
+			wantErr:   "not retried",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emitter := &flakyEmitter{failures: tt.failures}
+			record := nestedRecord{Name: "before"}
+
+			start := time.Now()
+			_, err := ApplyChangesWrapper(map[string]interface{}{"name": "after"}, "EUA1", &record, tt.opts(emitter)...)
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("ApplyChangesWrapper() took %v", elapsed)
+			}
+
+			if emitter.calls != tt.wantCalls {
+				t.Errorf("emitter called %d times, want %d", emitter.calls, tt.wantCalls)
+			}
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ApplyChangesWrapper() error = %v", err)
+				}
+				if record.Name != "after" || record.ModifiedBy == nil {
+					t.Errorf("record = %+v, want the changes kept", record)
+				}
+				return
+			}
+
+			if err == nil || !errors.Is(err, errFlaky) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want %q", err, tt.wantErr)
+			}
+			if record.Name != "before" || record.ModifiedBy != nil {
+				t.Errorf("record = %+v, want it rolled back", record)
+			}
+		})
+	}
+}
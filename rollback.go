@@ -0,0 +1,168 @@
+package applychanges
+
+import "reflect"
+
+// undoLog remembers the fields of a target an apply is about to touch, so a
+// failed apply can put them back the way they were. Only the addressed fields
+// are copied, rather than the whole target; applies whose hooks may touch any
+// other field are staged instead (see stagedApply).
+type undoLog struct {
+	target reflect.Value
+	fields []undoField
+}
+
+type undoField struct {
+	key   string
+	index []int
+
+	// original is the field's value itself, and saved a deep copy of it
+	original reflect.Value
+	saved    reflect.Value
+}
+
+// newUndoLog copies the current value of every field the changes address
+func newUndoLog(changes map[string]interface{}, target reflect.Value, tagName string) *undoLog {
+	undo := &undoLog{target: target, fields: make([]undoField, 0, len(changes))}
+	for key := range changes {
+		field, ok := structFieldByTag(target.Type(), tagName, key)
+		if !ok {
+			continue
+		}
+
+		value := target.FieldByIndex(field.Index)
+		if !value.CanSet() {
+			continue
+		}
+
+		undo.fields = append(undo.fields, undoField{
+			key:      fieldKey(field, tagName),
+			index:    field.Index,
+			original: shallowCopy(value),
+			saved:    deepCopy(value),
+		})
+	}
+
+	return undo
+}
+
+// snapshot returns the saved values keyed by the fields' tag names, for
+// diffFields. restore never hands out the saved values themselves, so they
+// can be shared with the result.
+func (undo *undoLog) snapshot() map[string]interface{} {
+	snapshot := make(map[string]interface{}, len(undo.fields))
+	for _, field := range undo.fields {
+		snapshot[field.key] = fieldChangeValue(field.saved)
+	}
+
+	return snapshot
+}
+
+// restore puts every field back. Pointers to structs keep their identity: the
+// original pointer is restored and what it points to is overwritten, since
+// nested changes are merged into the pointee in place.
+func (undo *undoLog) restore() {
+	for _, field := range undo.fields {
+		value := undo.target.FieldByIndex(field.index)
+		if !value.CanSet() {
+			continue
+		}
+
+		if field.original.Kind() == reflect.Ptr && !field.original.IsNil() {
+			value.Set(field.original)
+			value.Elem().Set(deepCopy(field.saved.Elem()))
+			continue
+		}
+
+		value.Set(deepCopy(field.saved))
+	}
+}
+
+func shallowCopy(value reflect.Value) reflect.Value {
+	copied := reflect.New(value.Type()).Elem()
+	copied.Set(value)
+	return copied
+}
+
+// stagedApply is an apply made to a copy of the target, which is only
+// committed to the target itself once the ValueAuthorizers have passed, see
+// stagesApply. Anything failing the apply after that restores the whole
+// target, not just the fields the changes addressed.
+type stagedApply struct {
+	original interface{}
+	target   reflect.Value
+	copied   interface{}
+
+	// once committed, before is the target as it was and saved a deep copy of
+	// it, for restore
+	before    reflect.Value
+	saved     reflect.Value
+	committed bool
+}
+
+// stagesApply reports whether the apply goes to a copy of the target first:
+// ValueAuthorizers see the decoded values before they are assigned to it, and
+// AfterAppliers and Validators may change or reject fields the changes never
+// addressed (e.g. a derived field), which the undoLog wouldn't put back
+func (cfg *config) stagesApply(to interface{}) bool {
+	if _, ok := targetStruct(to); !ok || reflect.ValueOf(to).Kind() != reflect.Ptr {
+		return false
+	}
+
+	if len(cfg.valueAuthorizers) > 0 {
+		return true
+	}
+	if !cfg.rollback {
+		return false
+	}
+
+	switch to.(type) {
+	case AfterApplier, AfterApplierContext:
+		return true
+	}
+
+	return cfg.validator != nil
+}
+
+func stageApply(to interface{}) *stagedApply {
+	target, _ := targetStruct(to)
+	return &stagedApply{original: to, target: target, copied: copyTarget(to)}
+}
+
+// commit assigns the copy to the target and returns the target. Pointers to
+// structs that were already set keep their identity, with what they point to
+// overwritten instead, as nested changes are merged into them in place.
+func (staged *stagedApply) commit() interface{} {
+	staged.before = shallowCopy(staged.target)
+	staged.saved = deepCopy(staged.target)
+
+	copied, _ := targetStruct(staged.copied)
+	staged.target.Set(copied)
+	for i := 0; i < staged.target.NumField(); i++ {
+		field, before := staged.target.Field(i), staged.before.Field(i)
+		if !field.CanSet() || field.Kind() != reflect.Ptr || field.Type().Elem().Kind() != reflect.Struct || field.IsNil() || before.IsNil() {
+			continue
+		}
+
+		before.Elem().Set(field.Elem())
+		field.Set(before)
+	}
+
+	staged.committed = true
+	return staged.original
+}
+
+// restore puts a committed target back the way it was, like undoLog.restore
+// for every field; an uncommitted one was never touched
+func (staged *stagedApply) restore() {
+	if !staged.committed {
+		return
+	}
+
+	staged.target.Set(staged.before)
+	for i := 0; i < staged.target.NumField(); i++ {
+		field := staged.target.Field(i)
+		if field.CanSet() && field.Kind() == reflect.Ptr && !field.IsNil() {
+			field.Elem().Set(staged.saved.Field(i).Elem())
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+	failValidation := ValidatorFunc(func(interface{}) error {
+		return ValidationErrors{{Field: "title", Message: "is taken"}}
+	})
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+	}{
+		{
+			name:    "validator fails",
+			changes: map[string]interface{}{"title": "Final", "body": "short", "notes": "new"},
+			opts:    []Option{WithValidator(failValidation)},
+		},
+		{
+			name:    "decode fails after another field",
+			changes: map[string]interface{}{"title": "Final", "body": "short", "blob": 7},
+		},
+		{
+			name:    "audit sink fails",
+			changes: map[string]interface{}{"body": "short", "notes": nil},
+			opts:    []Option{WithAuditSink(&recordingSink{err: errors.New("sink down")})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("long text ", 1000)
+			notes := "old"
+			record := documentRecord{Title: "Draft", Body: body, Blob: []byte{1, 2, 3}, Notes: &notes}
+			notesPointer := record.Notes
+			want := documentRecord{Title: "Draft", Body: body, Blob: []byte{1, 2, 3}, Notes: stringPtr("old")}
+
+			if _, err := ApplyChangesWrapper(tt.changes, "EUA1", &record, tt.opts...); err == nil {
+				t.Fatal("ApplyChangesWrapper() error = nil, want the apply to fail")
+			}
+
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("target = %+v, want it restored", record)
+			}
+			if record.Notes != notesPointer {
+				t.Error("Notes points somewhere else, want the original pointer restored")
+			}
+		})
+	}
+}
+
+func TestWithRollbackFalse(t *testing.T) {
+	failValidation := ValidatorFunc(func(interface{}) error {
+		return ValidationErrors{{Field: "title", Message: "is taken"}}
+	})
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		opts    []Option
+		want    documentRecord
+	}{
+		{
+			name:    "decode fails after another field",
+			changes: map[string]interface{}{"title": "Final", "blob": 7},
+			want:    documentRecord{Title: "Final", Body: "body", Blob: []byte{1, 2, 3}},
+		},
+		{
+			name:    "validator fails",
+			changes: map[string]interface{}{"title": "Final", "body": "short"},
+			opts:    []Option{WithValidator(failValidation)},
+			want:    documentRecord{Title: "Final", Body: "short", Blob: []byte{1, 2, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := documentRecord{Title: "Draft", Body: "body", Blob: []byte{1, 2, 3}}
+			if _, err := ApplyChanges(tt.changes, &record, append(tt.opts, WithRollback(false))...); err == nil {
+				t.Fatal("ApplyChanges() error = nil, want the apply to fail")
+			}
+			if !reflect.DeepEqual(record, tt.want) {
+				t.Errorf("target = %+v, want %+v left as decoded", record, tt.want)
+			}
+		})
+	}
+}
+
+// derivedReading derives Fahrenheit from Celsius after every apply
+type derivedReading struct {
+	Celsius    float64 `json:"celsius"`
+	Fahrenheit float64 `json:"-"`
+}
+
+func (r *derivedReading) AfterApply([]FieldChange) error {
+	r.Fahrenheit = r.Celsius*9/5 + 32
+	return nil
+}
+
+func TestRollbackDerivedField(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "validator fails",
+			opts: []Option{WithValidator(ValidatorFunc(func(interface{}) error {
+				return ValidationErrors{{Field: "celsius", Message: "is out of range"}}
+			}))},
+		},
+		{
+			name: "value authorizer fails",
+			opts: []Option{WithValueAuthorizer(func(FieldChange, Principal) error { return errors.New("not allowed") })},
+		},
+		{
+			name: "audit sink fails",
+			opts: []Option{WithAuditSink(&recordingSink{err: errors.New("sink down")})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := derivedReading{Celsius: 0, Fahrenheit: 32}
+			if _, err := ApplyChangesWrapper(map[string]interface{}{"celsius": 100}, "EUA1", &record, tt.opts...); err == nil {
+				t.Fatal("ApplyChangesWrapper() error = nil, want the apply to fail")
+			}
+
+			if record != (derivedReading{Celsius: 0, Fahrenheit: 32}) {
+				t.Errorf("target = %+v, want it restored along with the derived field", record)
+			}
+		})
+	}
+}
+
+func TestValidatorSeesAppliedTarget(t *testing.T) {
+	record := documentRecord{Title: "Draft"}
+	var seen string
+	validator := ValidatorFunc(func(target interface{}) error {
+		seen = target.(*documentRecord).Title
+		return nil
+	})
+
+	if _, err := ApplyChanges(map[string]interface{}{"title": "Final"}, &record, WithValidator(validator)); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if seen != "Final" || record.Title != "Final" {
+		t.Errorf("validator saw %q and the target has %q, want both Final", seen, record.Title)
+	}
+}
+
+// BenchmarkLargeField applies a small change to a record with a 5MB field,
+// which the undo log leaves alone, and a change to the 5MB field itself, whose
+// diff WithMaxDiffValueBytes keeps small
+func BenchmarkLargeField(b *testing.B) {
+	body := strings.Repeat("x", 5<<20)
+	newBody := body + "y"
+	validator := ValidatorFunc(func(interface{}) error { return nil })
+
+	benchmarks := []struct {
+		name    string
+		changes func() map[string]interface{}
+		opts    []Option
+	}{
+		{
+			name:    "other field",
+			changes: func() map[string]interface{} { return map[string]interface{}{"title": "Final"} },
+		},
+		{
+			name:    "other field validated",
+			changes: func() map[string]interface{} { return map[string]interface{}{"title": "Final"} },
+			opts:    []Option{WithValidator(validator)},
+		},
+		{
+			name:    "large field",
+			changes: func() map[string]interface{} { return map[string]interface{}{"body": newBody} },
+		},
+		{
+			name:    "large field capped",
+			changes: func() map[string]interface{} { return map[string]interface{}{"body": newBody} },
+			opts:    []Option{WithMaxDiffValueBytes(1024)},
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				record := documentRecord{Title: "Draft", Body: body}
+				if _, err := ApplyChangesWrapper(bm.changes(), "EUA1", &record, bm.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package applychanges
+
+import "reflect"
+
+// Sanitize normalizes changes in place before they are decoded: empty strings
+// and nil slices become real nil values.
+//
+// taken verbatim from https://github.com/CMSgov/easi-app/pull/1760
+func Sanitize(changes map[string]interface{}) {
+	for key, value := range changes {
+		// Get the reflect value for type comparisons
+		reflectValue := reflect.ValueOf(value)
+
+		// String operations
+		if reflectValue.Kind() == reflect.String {
+			valAsString, ok := reflectValue.Interface().(string)
+
+			// Convert empty strings to `nil`
+			if ok && len(valAsString) == 0 {
+				changes[key] = nil
+				continue
+			}
+		}
+
+		// Empty slices don't play well with mapstructure, as they enter as []interface{}
+		// which promptly gets ignored by mapstructure.
+		// In order to get around this, we'll convert empty slices to a real "nil" value
+		if reflectValue.Kind() == reflect.Slice && reflectValue.IsNil() {
+			changes[key] = nil
+		}
+	}
+}
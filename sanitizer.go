@@ -0,0 +1,56 @@
+package applychanges
+
+// ChangeSanitizer can be implemented by a target that needs its own cleanup of
+// incoming changes (normalizing a description, collapsing whitespace...). It is
+// called after the package's own sanitization and before decoding, with a copy
+// of the changes that it is free to modify; returning an error aborts the
+// apply.
+type ChangeSanitizer interface {
+	SanitizeChanges(changes map[string]interface{}) error
+}
+
+// sanitizeForTarget runs the target's own ChangeSanitizer, if it has one, on a
+// copy of the changes and returns the copy; otherwise the changes are returned
+// as they are.
+func sanitizeForTarget(changes map[string]interface{}, to interface{}) (map[string]interface{}, error) {
+	sanitizer, ok := to.(ChangeSanitizer)
+	if !ok {
+		return changes, nil
+	}
+
+	copied := copyChanges(changes)
+	if err := sanitizer.SanitizeChanges(copied); err != nil {
+		return nil, err
+	}
+
+	return copied, nil
+}
+
+// copyChanges copies the changes along with any nested maps and slices of the
+// shapes decoded JSON produces, so mutating the copy at any depth leaves the
+// original alone. Other values are shared.
+func copyChanges(changes map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(changes))
+	for key, value := range changes {
+		copied[key] = copyChangeValue(value)
+	}
+
+	return copied
+}
+
+func copyChangeValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return copyChanges(typed)
+	case []interface{}:
+		copied := make([]interface{}, len(typed))
+		for i, element := range typed {
+			copied[i] = copyChangeValue(element)
+		}
+		return copied
+	case []string:
+		return append([]string(nil), typed...)
+	}
+
+	return value
+}
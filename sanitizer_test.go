@@ -0,0 +1,102 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sanitizedReport struct {
+	BaseStruct
+	City    string  `json:"city"`
+	Weather string  `json:"weather"`
+	Address *string `json:"address"`
+}
+
+var errUnknownWeather = errors.New("unknown weather")
+
+// SanitizeChanges lowercases the weather, collapses the whitespace in the
+// address and rejects weather it doesn't know
+func (r *sanitizedReport) SanitizeChanges(changes map[string]interface{}) error {
+	if weather, ok := changes["weather"].(string); ok {
+		weather = strings.ToLower(strings.TrimSpace(weather))
+		if weather == "hail of frogs" {
+			return errUnknownWeather
+		}
+		changes["weather"] = weather
+	}
+
+	if address, ok := changes["address"].(string); ok {
+		changes["address"] = strings.Join(strings.Fields(address), " ")
+	}
+
+	return nil
+}
+
+func TestChangeSanitizer(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		wantWeather string
+		wantAddress string
+		wantErr     error
+	}{
+		{
+			name:        "normalized",
+			changes:     map[string]interface{}{"weather": "  SUNNY ", "address": " 1 Main   St\n Tampa "},
+			wantWeather: "sunny",
+			wantAddress: "1 Main St Tampa",
+		},
+		{
+			name:        "left alone",
+			changes:     map[string]interface{}{"city": "Tampa"},
+			wantWeather: "cloudy",
+		},
+		{
+			name:        "rejected",
+			changes:     map[string]interface{}{"weather": "Hail of frogs", "city": "Tampa"},
+			wantWeather: "cloudy",
+			wantErr:     errUnknownWeather,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := copyChanges(tt.changes)
+			report := sanitizedReport{Weather: "cloudy"}
+
+			_, err := ApplyChangesWrapper(tt.changes, "EUA1", &report)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if report.Weather != tt.wantWeather {
+				t.Errorf("weather = %q, want %q", report.Weather, tt.wantWeather)
+			}
+			address := ""
+			if report.Address != nil {
+				address = *report.Address
+			}
+			if address != tt.wantAddress {
+				t.Errorf("address = %q, want %q", address, tt.wantAddress)
+			}
+			if !reflect.DeepEqual(tt.changes, original) {
+				t.Errorf("the caller's changes became %v, want them untouched: %v", tt.changes, original)
+			}
+		})
+	}
+}
+
+func TestChangeSanitizerBeforeProtection(t *testing.T) {
+	report := sanitizedReport{}
+	_, err := ApplyChanges(map[string]interface{}{"weather": "SUNNY"}, &report, WithAllowedFields("city"))
+
+	var disallowed *DisallowedFieldsError
+	if !errors.As(err, &disallowed) {
+		t.Fatalf("ApplyChanges() error = %v, want a DisallowedFieldsError", err)
+	}
+	if report.Weather != "" {
+		t.Errorf("weather = %q, want nothing applied", report.Weather)
+	}
+}
@@ -0,0 +1,37 @@
+package applychanges
+
+import (
+	"reflect"
+)
+
+// scalars are the parse functions added by RegisterScalar, by the type they
+// produce
+var scalars registry[func(interface{}) (interface{}, error)]
+
+// RegisterScalar registers how to decode a change into a T (e.g. an
+// EmailAddress), for every apply in the process. Fields of type T or *T are
+// decoded by parse, ahead of the built-in conversions; values that already are
+// a T are left as they are. Registering a type again replaces its parser.
+func RegisterScalar[T any](parse func(value interface{}) (T, error)) {
+	register("RegisterScalar", func() {
+		scalars.set(reflect.TypeOf((*T)(nil)).Elem(), func(value interface{}) (interface{}, error) {
+			return parse(value)
+		})
+	})
+}
+
+// scalarParser returns the parse function registered for t
+func scalarParser(t reflect.Type) (func(interface{}) (interface{}, error), bool) {
+	return scalars.get(t)
+}
+
+// isScalar reports whether values of t are decoded as a whole rather than field
+// by field: custom scalars (graphql.Unmarshaler) and registered ones
+func isScalar(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(unmarshalerType) || t.Implements(unmarshalerType) {
+		return true
+	}
+
+	_, ok := scalarParser(t)
+	return ok
+}
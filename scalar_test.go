@@ -0,0 +1,132 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// emailAddress is a registered string scalar, lowered when parsed
+type emailAddress string
+
+// geoPoint is a registered struct scalar, parsed from "lat,lng"
+type geoPoint struct {
+	Lat float64
+	Lng float64
+}
+
+type scalarRecord struct {
+	Email    emailAddress  `json:"email"`
+	Backup   *emailAddress `json:"backup"`
+	Location geoPoint      `json:"location"`
+}
+
+func parseEmail(value interface{}) (emailAddress, error) {
+	s, ok := value.(string)
+	if !ok || !strings.Contains(s, "@") {
+		return "", fmt.Errorf("%v is not an email address", value)
+	}
+
+	return emailAddress(strings.ToLower(s)), nil
+}
+
+func parseGeoPoint(value interface{}) (geoPoint, error) {
+	var point geoPoint
+	if _, err := fmt.Sscanf(fmt.Sprint(value), "%g,%g", &point.Lat, &point.Lng); err != nil {
+		return geoPoint{}, errors.New("expected lat,lng")
+	}
+
+	return point, nil
+}
+
+func TestRegisterScalar(t *testing.T) {
+	backup := emailAddress("old@example.com")
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		want    scalarRecord
+		wantErr string
+	}{
+		{
+			name:    "string scalar",
+			changes: map[string]interface{}{"email": "Ada@Example.com"},
+			want:    scalarRecord{Email: "ada@example.com", Backup: &backup},
+		},
+		{
+			name:    "pointer field",
+			changes: map[string]interface{}{"backup": "New@Example.com"},
+			want:    scalarRecord{Email: "ada@example.com", Backup: func() *emailAddress { e := emailAddress("new@example.com"); return &e }()},
+		},
+		{
+			name:    "nil pointer",
+			changes: map[string]interface{}{"backup": nil},
+			want:    scalarRecord{Email: "ada@example.com"},
+		},
+		{
+			name:    "already a T",
+			changes: map[string]interface{}{"email": emailAddress("Kept@Example.com")},
+			want:    scalarRecord{Email: "Kept@Example.com", Backup: &backup},
+		},
+		{
+			name:    "struct scalar decoded whole",
+			changes: map[string]interface{}{"location": "27.9,-82.5"},
+			want:    scalarRecord{Email: "ada@example.com", Backup: &backup, Location: geoPoint{Lat: 27.9, Lng: -82.5}},
+		},
+		{
+			name:    "parse error",
+			changes: map[string]interface{}{"email": "nobody"},
+			wantErr: "nobody is not an email address",
+		},
+	}
+
+	t.Cleanup(func() {
+		scalars.remove(reflect.TypeOf(emailAddress("")))
+		scalars.remove(reflect.TypeOf(geoPoint{}))
+	})
+	RegisterScalar(parseEmail)
+	RegisterScalar(parseGeoPoint)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := scalarRecord{Email: "ada@example.com", Backup: &backup}
+			record := original
+			_, err := ApplyChanges(tt.changes, &record)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChanges() error = %v, want %q", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(record, original) {
+					t.Errorf("record = %+v, want it untouched", record)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(record, tt.want) {
+				t.Errorf("record = %+v, want %+v", record, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterScalarReplaces(t *testing.T) {
+	t.Cleanup(func() { scalars.remove(reflect.TypeOf(emailAddress(""))) })
+	RegisterScalar(parseEmail)
+	RegisterScalar(func(value interface{}) (emailAddress, error) { return "replaced@example.com", nil })
+
+	record := scalarRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"email": "nobody"}, &record); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if record.Email != "replaced@example.com" {
+		t.Errorf("Email = %q, want the replacing parser's", record.Email)
+	}
+	if !isScalar(reflect.TypeOf(emailAddress(""))) {
+		t.Error("isScalar() = false for a registered scalar")
+	}
+}
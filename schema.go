@@ -0,0 +1,214 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const jsonSchemaDraft07 = "http://json-schema.org/draft-07/schema#"
+
+// timeOfDayPattern matches what ParseTimeOfDay accepts; draft-07's "time"
+// format requires a zone offset, which a time of day doesn't have
+const timeOfDayPattern = `^([01][0-9]|2[0-3]):[0-5][0-9](:[0-5][0-9](\.[0-9]{1,9})?)?$`
+
+// ChangesetSchema returns a draft-07 JSON Schema describing the changesets that
+// can be applied to t: every settable field (other than `apply:"immutable"`
+// ones) is an optional property, and any other key is rejected. Pointer fields
+// also accept null; time.Time, Date and uuid.UUID fields are strings with the
+// matching format (as are `applyas:"date"` fields), and TimeOfDay and
+// `applyas:"time"` fields strings of the time; decimal.Decimal and
+// time.Duration fields are strings or numbers; RegisterEnum types list their
+// values; custom scalars (graphql.Unmarshaler or RegisterScalar) accept
+// anything, since only they know their input.
+//
+// Fields the options keep from being changed are left out too: those excluded
+// by WithAllowedFields, WithDeniedFields and WithAdminOnlyFields, named by
+// WithImmutableFields, or not permitted by WithFieldPermissions, `roles=` tags
+// or the FieldAuthorizer. Those checks are made for the principal carried by
+// the context given with WithContext (see ApplyChangesCtx), or else one with
+// no role.
+func ChangesetSchema(t reflect.Type, opts ...Option) ([]byte, error) {
+	cfg := configFor(t, opts)
+
+	extract := PrincipalFromContext
+	if cfg.principalExtractor != nil {
+		extract = cfg.principalExtractor
+	}
+	if principal, ok := extract(cfg.ctx); ok {
+		cfg.principal = &principal
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("changes can only be applied to structs, not %s", t)
+	}
+
+	schema := objectSchema(t, "", cfg, map[reflect.Type]bool{})
+	schema["$schema"] = jsonSchemaDraft07
+	schema["title"] = t.Name()
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// objectSchema describes a struct decoded from a nested map, at the path of
+// tag names prefix. seen guards against recursive types, which are left
+// unconstrained past the first level.
+func objectSchema(t reflect.Type, prefix string, cfg *config, seen map[reflect.Type]bool) map[string]interface{} {
+	seen[t] = true
+	defer delete(seen, t)
+
+	properties := map[string]interface{}{}
+	for _, field := range squashedFields(t) {
+		if field.PkgPath != "" {
+			// unexported, mapstructure can't set it
+			continue
+		}
+
+		name := fieldKey(field, cfg.tagName)
+		if name == "" || (isImmutable(field) && !cfg.ignoreImmutable) || isMaintainedField(field) || !schemaPermits(prefix+name, field, cfg) {
+			continue
+		}
+
+		property := valueSchema(field.Type, prefix+name+".", cfg, seen)
+		switch field.Tag.Get(applyAsTagName) {
+		case "date":
+			property["format"] = "date"
+		case "time":
+			delete(property, "format")
+			property["pattern"] = timeOfDayPattern
+		}
+		properties[name] = property
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// valueSchema describes a value of type t, which is at the path prefix when
+// it's a struct
+func valueSchema(t reflect.Type, prefix string, cfg *config, seen map[reflect.Type]bool) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		schema := valueSchema(t.Elem(), prefix, cfg, seen)
+		switch jsonType := schema["type"].(type) {
+		case string:
+			schema["type"] = []string{jsonType, "null"}
+		case []string:
+			schema["type"] = append(jsonType, "null")
+		}
+		if allowed, ok := schema["enum"].([]string); ok {
+			enum := make([]interface{}, 0, len(allowed)+1)
+			for _, value := range allowed {
+				enum = append(enum, value)
+			}
+			schema["enum"] = append(enum, nil)
+		}
+		return schema
+	}
+
+	// Optionals take their value's schema, or null
+	if reflect.PtrTo(t).Implements(optionalFieldType) {
+		return valueSchema(reflect.PtrTo(reflect.New(t).Interface().(optionalField).valueType()), prefix, cfg, seen)
+	}
+
+	switch t {
+	case reflect.TypeOf(Date{}):
+		return map[string]interface{}{"type": "string", "format": "date"}
+	case reflect.TypeOf(TimeOfDay{}):
+		return map[string]interface{}{"type": "string", "pattern": timeOfDayPattern}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(uuid.UUID{}):
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case decimalType:
+		return map[string]interface{}{"type": []string{"string", "number"}}
+	case durationType:
+		return map[string]interface{}{"type": []string{"string", "number"}}
+	}
+
+	if isScalar(t) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if allowed, ok := registeredEnum(t); ok {
+			return map[string]interface{}{"type": "string", "enum": allowed}
+		}
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": valueSchema(t.Elem(), prefix, cfg, seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": valueSchema(t.Elem(), prefix, cfg, seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		return objectSchema(t, prefix, cfg, seen)
+	}
+
+	return map[string]interface{}{}
+}
+
+// schemaPermits reports whether the options let the field at path (of tag
+// names) be changed: top-level fields are checked against the allowed, denied
+// and admin-only fields, and every field against WithImmutableFields, the role
+// permissions and the FieldAuthorizer, as the apply would
+func schemaPermits(path string, field reflect.StructField, cfg *config) bool {
+	if !strings.Contains(path, ".") && !nameAllowed(path, cfg) {
+		return false
+	}
+
+	if cfg.immutableFields[path] {
+		return false
+	}
+
+	principal := authorizedPrincipal(cfg)
+	if err := permitted(path, &field, isNestedStruct(field.Type), principal.Role, cfg); err != nil {
+		return false
+	}
+
+	return cfg.fieldAuthorizer == nil || cfg.fieldAuthorizer(cfg.ctx, path, principal) == nil
+}
+
+// isNestedStruct reports whether values of type t are decoded from nested
+// maps, whose keys are authorized separately
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+// isMaintainedField reports whether the field is one only ApplyChangesWrapper
+// may set (RecentModifiers or ModifiedByPrincipal)
+func isMaintainedField(field reflect.StructField) bool {
+	principalType := reflect.TypeOf(Principal{})
+
+	switch field.Name {
+	case recentModifiersField:
+		return field.Type == reflect.TypeOf([]string{})
+	case modifiedByPrincipalField:
+		return field.Type == principalType || field.Type == reflect.PtrTo(principalType)
+	}
+
+	return false
+}
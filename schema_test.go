@@ -0,0 +1,134 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+type schemaWeatherReport struct {
+	BaseStruct
+	City    string `json:"city"`
+	Weather string `json:"weather"`
+}
+
+type schemaStatus string
+
+type schemaStation struct {
+	Name     string  `json:"name"`
+	Latitude float64 `json:"latitude"`
+	Secret   string  `json:"secret" apply:"roles=admin"`
+}
+
+type schemaKitchenSink struct {
+	BaseStruct
+	Name            string            `json:"name"`
+	Nickname        *string           `json:"nickname"`
+	Count           int               `json:"count"`
+	Ratio           float64           `json:"ratio"`
+	Active          bool              `json:"active"`
+	ObservedAt      time.Time         `json:"observedAt"`
+	ReviewedAt      *time.Time        `json:"reviewedAt"`
+	Day             Date              `json:"day"`
+	Opens           TimeOfDay         `json:"opens"`
+	Birthday        string            `json:"birthday" applyas:"date"`
+	Reference       uuid.UUID         `json:"reference"`
+	Amount          decimal.Decimal   `json:"amount"`
+	Interval        time.Duration     `json:"interval"`
+	Status          schemaStatus      `json:"status"`
+	PreviousStatus  *schemaStatus     `json:"previousStatus"`
+	Tags            []string          `json:"tags"`
+	Scores          map[string]int    `json:"scores"`
+	Station         schemaStation     `json:"station"`
+	Backup          *schemaStation    `json:"backup"`
+	Maybe           Optional[int]     `json:"maybe"`
+	Code            string            `json:"code" apply:"immutable"`
+	Ignored         string            `json:"-"`
+	RecentModifiers []string          `json:"recentModifiers"`
+	Notes           map[string]string `json:"notes"`
+	internal        string
+}
+
+func TestChangesetSchema(t *testing.T) {
+	RegisterEnum(schemaStatus("DRAFT"), schemaStatus("FINAL"))
+
+	adminOnly := func(_ context.Context, fieldPath string, principal Principal) error {
+		if fieldPath == "notes" && principal.Role != AdminRole {
+			return errors.New("admins only")
+		}
+		return nil
+	}
+	reviewer := ContextWithPrincipal(context.Background(), Principal{ID: "EUA1", Role: "reviewer"})
+
+	tests := []struct {
+		name   string
+		target interface{}
+		opts   []Option
+	}{
+		{name: "weather_report", target: schemaWeatherReport{}},
+		{name: "kitchen_sink", target: &schemaKitchenSink{}},
+		{
+			name:   "kitchen_sink_filtered",
+			target: schemaKitchenSink{},
+			opts:   []Option{WithAllowedFields("name", "count", "station", "tags", "notes"), WithDeniedFields("tags"), WithImmutableFields("station.latitude")},
+		},
+		{
+			name:   "kitchen_sink_reviewer",
+			target: schemaKitchenSink{},
+			opts: []Option{
+				WithContext(reviewer),
+				WithFieldPermissions(FieldPermissions{"reviewer": {"status", "notes", "station.name", "station.secret"}}),
+				WithFieldAuthorizer(adminOnly),
+			},
+		},
+		{
+			name:   "kitchen_sink_admin",
+			target: schemaKitchenSink{},
+			opts: []Option{
+				WithContext(ContextWithPrincipal(context.Background(), Principal{ID: "EUA2", Role: AdminRole})),
+				WithAllowedFields("station", "notes"),
+				WithFieldAuthorizer(adminOnly),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := ChangesetSchema(reflect.TypeOf(tt.target), tt.opts...)
+			if err != nil {
+				t.Fatalf("ChangesetSchema() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", "schema", tt.name+".json")
+			if *update {
+				if err := os.WriteFile(golden, append(schema, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading the golden file: %v (run with -update to create it)", err)
+			}
+			if string(schema)+"\n" != string(want) {
+				t.Errorf("ChangesetSchema() =\n%s\nwant (%s)\n%s", schema, golden, want)
+			}
+		})
+	}
+}
+
+func TestChangesetSchemaNotStruct(t *testing.T) {
+	if _, err := ChangesetSchema(reflect.TypeOf("")); err == nil {
+		t.Error("ChangesetSchema() error = nil for a string")
+	}
+}
@@ -0,0 +1,92 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// SkipField can be returned (or wrapped) by a decode hook or a RegisterScalar
+// parser to drop the change it was given instead of failing the apply, e.g.
+// for the "N/A" a legacy client sends for numbers it doesn't have:
+//
+//	if v == "N/A" {
+//		return nil, fmt.Errorf("%w: not available", applychanges.SkipField)
+//	}
+//
+// The dropped change isn't applied nor reported as applied; it's listed in
+// ApplyResult.SkippedFields instead. The built-in conversions never skip.
+var SkipField = errors.New("skipped")
+
+// ErrNoChanges is returned by an apply WithRequireChanges when nothing is left
+// to apply once the changes have been skipped, dropped and filtered
+var ErrNoChanges = errors.New("no changes to apply")
+
+// SkippedField is a change dropped by a decode hook returning SkipField, or a
+// child WithPartialChildren
+type SkippedField struct {
+	// Path is the key of the change, with the keys of the structs it's nested
+	// in, e.g. `details.level`, or `notes[<id>]` for a child
+	Path string `json:"path"`
+
+	// Reason is the message of the error the hook returned
+	Reason string `json:"reason"`
+}
+
+// mayDecodeSkip reports whether any decode hook or scalar parser an apply
+// decodes with isn't built in, since only those can skip a change
+func (cfg *config) mayDecodeSkip() bool {
+	if len(cfg.decodeHooks) > 0 {
+		return true
+	}
+
+	return len(loadDecodeHooks().hooks) > 0 || len(scalars.load()) > 0
+}
+
+// skipFields runs the decode hooks over the changes ahead of decoding them,
+// removing the changes (top-level, or nested in the maps of struct fields) a
+// hook skips. Hooks failing otherwise are left for the decode to report.
+func skipFields(changes map[string]interface{}, structType reflect.Type, hook mapstructure.DecodeHookFunc, tagName string, prefix string) []SkippedField {
+	var skipped []SkippedField
+	for key, value := range changes {
+		field, ok := structFieldByTag(structType, tagName, key)
+		if !ok || value == nil {
+			continue
+		}
+
+		if err := hookSkips(hook, value, field.Type); err != nil {
+			delete(changes, key)
+			skipped = append(skipped, SkippedField{Path: prefix + key, Reason: err.Error()})
+			continue
+		}
+
+		nested, isMap := value.(map[string]interface{})
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if isMap && fieldType.Kind() == reflect.Struct && !isScalar(fieldType) {
+			skipped = append(skipped, skipFields(nested, fieldType, hook, tagName, prefix+key+".")...)
+		}
+	}
+
+	return skipped
+}
+
+// hookSkips runs the hook the way decoding a value into a field of fieldType
+// does (once more for what a pointer points to), returning the error when it
+// skips the value
+func hookSkips(hook mapstructure.DecodeHookFunc, value interface{}, fieldType reflect.Type) error {
+	for {
+		converted, err := mapstructure.DecodeHookExec(hook, reflect.ValueOf(value), reflect.New(fieldType).Elem())
+		if errors.Is(err, SkipField) {
+			return err
+		}
+		if err != nil || converted == nil || fieldType.Kind() != reflect.Ptr {
+			return nil
+		}
+
+		value, fieldType = converted, fieldType.Elem()
+	}
+}
@@ -0,0 +1,154 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type skipReading struct {
+	BaseStruct
+	Name    string         `json:"name"`
+	Count   int            `json:"count"`
+	Reading *int           `json:"reading"`
+	Details *nestedDetails `json:"details"`
+}
+
+// skipNotAvailable skips the "N/A" legacy clients send for numbers
+func skipNotAvailable(from reflect.Type, to reflect.Type, v interface{}) (interface{}, error) {
+	if to.Kind() == reflect.Int && v == "N/A" {
+		return nil, fmt.Errorf("%w: not available", SkipField)
+	}
+	return v, nil
+}
+
+func TestSkipField(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     map[string]interface{}
+		opts        []Option
+		want        skipReading
+		wantApplied []string
+		wantSkipped []SkippedField
+		wantErr     error
+	}{
+		{
+			name:        "skipped and applied",
+			changes:     map[string]interface{}{"name": "Tampa", "count": "N/A"},
+			want:        skipReading{Name: "Tampa", Count: 3},
+			wantApplied: []string{"modifiedBy", "name"},
+			wantSkipped: []SkippedField{{Path: "count", Reason: "skipped: not available"}},
+		},
+		{
+			name:        "pointer and nested fields",
+			changes:     map[string]interface{}{"reading": "N/A", "details": map[string]interface{}{"source": "buoy", "level": "N/A"}},
+			want:        skipReading{Count: 3, Details: &nestedDetails{Source: "buoy", Level: 2}},
+			wantApplied: []string{"details", "modifiedBy"},
+			wantSkipped: []SkippedField{{Path: "details.level", Reason: "skipped: not available"}, {Path: "reading", Reason: "skipped: not available"}},
+		},
+		{
+			name:        "nothing skipped",
+			changes:     map[string]interface{}{"count": 4},
+			want:        skipReading{Count: 4},
+			wantApplied: []string{"count", "modifiedBy"},
+		},
+		{
+			name:        "everything skipped",
+			changes:     map[string]interface{}{"count": "N/A"},
+			want:        skipReading{Count: 3},
+			wantApplied: []string{"modifiedBy"},
+			wantSkipped: []SkippedField{{Path: "count", Reason: "skipped: not available"}},
+		},
+		{
+			name:    "everything skipped with WithRequireChanges",
+			changes: map[string]interface{}{"count": "N/A"},
+			opts:    []Option{WithRequireChanges()},
+			want:    skipReading{Count: 3},
+			wantErr: ErrNoChanges,
+		},
+		{
+			name:        "something left with WithRequireChanges",
+			changes:     map[string]interface{}{"name": "Tampa", "count": "N/A"},
+			opts:        []Option{WithRequireChanges()},
+			want:        skipReading{Name: "Tampa", Count: 3},
+			wantApplied: []string{"modifiedBy", "name"},
+			wantSkipped: []SkippedField{{Path: "count", Reason: "skipped: not available"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := skipReading{Count: 3, Details: &nestedDetails{Level: 2}}
+			if tt.want.Details == nil {
+				tt.want.Details = reading.Details
+			}
+
+			opts := append([]Option{WithDecodeHook(skipNotAvailable), WithModifiedDts(false)}, tt.opts...)
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &reading, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyChangesWrapper() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !reflect.DeepEqual(reading, skipReading{Count: 3, Details: &nestedDetails{Level: 2}}) {
+					t.Errorf("a failed apply changed the target to %+v", reading)
+				}
+				return
+			}
+
+			reading.ModifiedBy, reading.ModifiedDts = nil, nil
+			if !reflect.DeepEqual(reading, tt.want) {
+				t.Errorf("target = %+v (details %+v), want %+v (details %+v)", reading, reading.Details, tt.want, tt.want.Details)
+			}
+			if !reflect.DeepEqual(result.AppliedFields, tt.wantApplied) {
+				t.Errorf("AppliedFields = %v, want %v", result.AppliedFields, tt.wantApplied)
+			}
+			if !reflect.DeepEqual(result.SkippedFields, tt.wantSkipped) {
+				t.Errorf("SkippedFields = %v, want %v", result.SkippedFields, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestSkipFieldScalar(t *testing.T) {
+	type celsius float64
+	RegisterScalar(func(value interface{}) (celsius, error) {
+		if value == "N/A" {
+			return 0, SkipField
+		}
+		f, ok := value.(float64)
+		if !ok {
+			return 0, fmt.Errorf("not a temperature: %v", value)
+		}
+		return celsius(f), nil
+	})
+	defer scalars.remove(reflect.TypeOf(celsius(0)))
+
+	var reading struct {
+		Temperature celsius `json:"temperature"`
+	}
+	reading.Temperature = 21
+
+	result, err := ApplyChanges(map[string]interface{}{"temperature": "N/A"}, &reading)
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if reading.Temperature != 21 {
+		t.Errorf("temperature = %v, want it left at 21", reading.Temperature)
+	}
+	want := []SkippedField{{Path: "temperature", Reason: "skipped"}}
+	if !reflect.DeepEqual(result.SkippedFields, want) {
+		t.Errorf("SkippedFields = %v, want %v", result.SkippedFields, want)
+	}
+}
+
+func TestSkipFieldBuiltinHooks(t *testing.T) {
+	reading := skipReading{Count: 3}
+	_, err := ApplyChanges(map[string]interface{}{"count": "N/A"}, &reading)
+	if err == nil {
+		t.Fatal("ApplyChanges() error = nil, want the built-in conversions to fail on N/A")
+	}
+	if errors.Is(err, SkipField) {
+		t.Errorf("ApplyChanges() error = %v, the built-in conversions never skip", err)
+	}
+}
@@ -0,0 +1,131 @@
+package applychanges
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// dbTagName is the struct tag naming a field's database column
+const dbTagName = "db"
+
+// ErrNothingToUpdate is returned by BuildUpdateSQL when no column changed, so
+// there is no statement to run
+var ErrNothingToUpdate = errors.New("no columns changed")
+
+// BuildUpdateSQL builds a parameterized UPDATE statement for the columns an
+// apply changed, from its result's Changes (to is the target it was applied to,
+// for looking up the fields' db tags):
+//
+//	UPDATE weather_report SET city = $1, modified_by = $2
+//
+// The args hold the new values in order, so the caller continues numbering
+// from len(args)+1 for its WHERE clause. Fields whose value didn't actually
+// change are left out, and a field without a db column is an error. Values are
+// passed on as they are, so slices need wrapping (e.g. pq.Array) by the caller.
+func BuildUpdateSQL(diff []FieldChange, tableName string, to interface{}, opts ...Option) (string, []interface{}, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+
+	target, ok := targetStruct(to)
+	if !ok {
+		return "", nil, fmt.Errorf("changes can only be applied to structs, not %T", to)
+	}
+	if err := checkNotTruncated(diff); err != nil {
+		return "", nil, err
+	}
+
+	var assignments []string
+	var args []interface{}
+	for _, change := range diff {
+		if sameValue(change.Old, change.New) {
+			continue
+		}
+
+		field, ok := structFieldByTag(target.Type(), cfg.tagName, change.Path)
+		if !ok {
+			return "", nil, fmt.Errorf("%s has no field '%s'", target.Type().Name(), change.Path)
+		}
+
+		column := strings.SplitN(field.Tag.Get(dbTagName), ",", 2)[0]
+		if column == "" || column == "-" {
+			return "", nil, fmt.Errorf("'%s' has no db column", change.Path)
+		}
+
+		args = append(args, change.New)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if len(assignments) == 0 {
+		return "", nil, ErrNothingToUpdate
+	}
+
+	return fmt.Sprintf("UPDATE %s SET %s", tableName, strings.Join(assignments, ", ")), args, nil
+}
+
+// BuildNamedUpdateSQL builds an UPDATE statement with :named parameters for the
+// fields an apply touched, for sqlx.NamedExec:
+//
+//	UPDATE weather_report SET city = :city, modified_by = :modified_by, modified_dts = :modified_dts
+//
+// The args map each column to the target's current value of the field. The
+// target's ModifiedBy and ModifiedDts columns are always included, so the
+// statement stamps them even for an ApplyChanges result. Callers add their
+// WHERE clause with their own named parameters (e.g. "WHERE id = :id", with
+// args["id"] set).
+func BuildNamedUpdateSQL(result ApplyResult, tableName string, to interface{}, opts ...Option) (string, map[string]interface{}, error) {
+	cfg := configFor(reflect.TypeOf(to), opts)
+
+	target, ok := targetStruct(to)
+	if !ok {
+		return "", nil, fmt.Errorf("changes can only be applied to structs, not %T", to)
+	}
+
+	fields, err := updatedFields(result, target, cfg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var assignments []string
+	args := map[string]interface{}{}
+	for _, field := range fields {
+		column := strings.SplitN(field.Tag.Get(dbTagName), ",", 2)[0]
+		if column == "" || column == "-" {
+			return "", nil, fmt.Errorf("'%s' has no db column", fieldKey(field, cfg.tagName))
+		}
+
+		if _, seen := args[column]; seen {
+			continue
+		}
+
+		args[column] = target.FieldByIndex(field.Index).Interface()
+		assignments = append(assignments, fmt.Sprintf("%s = :%s", column, column))
+	}
+
+	if len(assignments) == 0 {
+		return "", nil, ErrNothingToUpdate
+	}
+
+	return fmt.Sprintf("UPDATE %s SET %s", tableName, strings.Join(assignments, ", ")), args, nil
+}
+
+// updatedFields lists the fields an update has to write: those the apply
+// touched, followed by the target's ModifiedBy and ModifiedDts
+func updatedFields(result ApplyResult, target reflect.Value, cfg *config) ([]reflect.StructField, error) {
+	var fields []reflect.StructField
+	for _, key := range result.AppliedFields {
+		field, ok := structFieldByTag(target.Type(), cfg.tagName, key)
+		if !ok {
+			return nil, fmt.Errorf("%s has no field '%s'", target.Type().Name(), key)
+		}
+		fields = append(fields, field)
+	}
+
+	for _, name := range []string{"ModifiedBy", "ModifiedDts"} {
+		if field, ok := structFieldByName(target.Type(), name); ok {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
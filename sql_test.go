@@ -0,0 +1,185 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type sqlReport struct {
+	BaseStruct
+	City    string  `json:"city" db:"city"`
+	Weather *string `json:"weather" db:"weather"`
+	Notes   string  `json:"notes"`
+}
+
+func TestBuildUpdateSQL(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		changes  map[string]interface{}
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  error
+	}{
+		{
+			name:     "changed columns with the metadata",
+			changes:  map[string]interface{}{"city": "Tampa", "weather": "Rain"},
+			wantSQL:  "UPDATE weather_report SET city = $1, modified_by = $2, modified_dts = $3, weather = $4",
+			wantArgs: []interface{}{"Tampa", "EUA1", now, "Rain"},
+		},
+		{
+			name:     "unchanged values left out",
+			changes:  map[string]interface{}{"city": "Miami", "weather": "Rain"},
+			wantSQL:  "UPDATE weather_report SET modified_by = $1, modified_dts = $2, weather = $3",
+			wantArgs: []interface{}{"EUA1", now, "Rain"},
+		},
+		{
+			name:    "field without a column",
+			changes: map[string]interface{}{"notes": "calm"},
+			wantErr: errors.New("'notes' has no db column"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := sqlReport{City: "Miami"}
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &report, WithClock(fixedClock(now)))
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			query, args, err := BuildUpdateSQL(result.Changes, "weather_report", &report)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("BuildUpdateSQL() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildUpdateSQL() error = %v", err)
+			}
+
+			if query != tt.wantSQL {
+				t.Errorf("BuildUpdateSQL() query = %q, want %q", query, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("BuildUpdateSQL() args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuildUpdateSQLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    []FieldChange
+		to      interface{}
+		wantErr error
+	}{
+		{
+			name:    "nothing changed",
+			diff:    []FieldChange{{Path: "city", Old: "Tampa", New: "Tampa"}},
+			to:      &sqlReport{},
+			wantErr: ErrNothingToUpdate,
+		},
+		{
+			name: "truncated diff",
+			diff: []FieldChange{{Path: "city", Old: "a", New: "b", Truncated: true}},
+			to:   &sqlReport{},
+		},
+		{
+			name: "unknown field",
+			diff: []FieldChange{{Path: "zip", New: "33602"}},
+			to:   &sqlReport{},
+		},
+		{
+			name: "not a struct",
+			diff: []FieldChange{{Path: "city", New: "Tampa"}},
+			to:   map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := BuildUpdateSQL(tt.diff, "weather_report", tt.to)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("BuildUpdateSQL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildNamedUpdateSQL(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rain := "Rain"
+
+	tests := []struct {
+		name     string
+		apply    func(report *sqlReport) (ApplyResult, error)
+		wantSQL  string
+		wantArgs map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name: "wrapper",
+			apply: func(report *sqlReport) (ApplyResult, error) {
+				return ApplyChangesWrapper(map[string]interface{}{"city": "Tampa", "weather": "Rain"}, "EUA1", report, WithClock(fixedClock(now)))
+			},
+			wantSQL:  "UPDATE weather_report SET city = :city, modified_by = :modified_by, modified_dts = :modified_dts, weather = :weather",
+			wantArgs: map[string]interface{}{"city": "Tampa", "weather": &rain, "modified_by": stringPtr("EUA1"), "modified_dts": &now},
+		},
+		{
+			name: "metadata stamped for ApplyChanges",
+			apply: func(report *sqlReport) (ApplyResult, error) {
+				return ApplyChanges(map[string]interface{}{"city": "Tampa"}, report)
+			},
+			wantSQL:  "UPDATE weather_report SET city = :city, modified_by = :modified_by, modified_dts = :modified_dts",
+			wantArgs: map[string]interface{}{"city": "Tampa", "modified_by": (*string)(nil), "modified_dts": (*time.Time)(nil)},
+		},
+		{
+			name: "field without a column",
+			apply: func(report *sqlReport) (ApplyResult, error) {
+				return ApplyChanges(map[string]interface{}{"notes": "calm"}, report)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := sqlReport{City: "Miami"}
+			result, err := tt.apply(&report)
+			if err != nil {
+				t.Fatalf("apply error = %v", err)
+			}
+
+			query, args, err := BuildNamedUpdateSQL(result, "weather_report", &report)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildNamedUpdateSQL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if query != tt.wantSQL {
+				t.Errorf("BuildNamedUpdateSQL() query = %q, want %q", query, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("BuildNamedUpdateSQL() args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuildNamedUpdateSQLWithoutMetadata(t *testing.T) {
+	result := ApplyResult{AppliedFields: []string{"name"}}
+	if _, _, err := BuildNamedUpdateSQL(ApplyResult{}, "station", &fallbackReport{}); !errors.Is(err, ErrNothingToUpdate) {
+		t.Errorf("BuildNamedUpdateSQL() error = %v, want ErrNothingToUpdate", err)
+	}
+	if _, _, err := BuildNamedUpdateSQL(result, "station", &fallbackReport{}); err == nil {
+		t.Error("BuildNamedUpdateSQL() error = nil, want an unknown field")
+	}
+}
@@ -0,0 +1,114 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeOfDayLayout is the layout times of day are written in; seconds and a
+// fraction of a second are optional when reading them
+const TimeOfDayLayout = "15:04:05"
+
+// TimeOfDay is a wall-clock time (an opening hour, a daily cutoff) rather than
+// an instant, so it never shifts across time zones. It is encoded as
+// "15:04:05" in JSON and GraphQL, with fractional seconds when it has any,
+// and implements graphql.Unmarshaler so changes can set it from a time-only
+// string.
+type TimeOfDay struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// ParseTimeOfDay parses a "15:04", "15:04:05" or "15:04:05.999999999" string
+// into a TimeOfDay
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	layout := "15:04"
+	if strings.Count(s, ":") == 2 {
+		layout = TimeOfDayLayout
+	}
+
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return TimeOfDay{}, err
+	}
+
+	return TimeOfDayOf(t), nil
+}
+
+// TimeOfDayOf returns the time of day of t in its own location
+func TimeOfDayOf(t time.Time) TimeOfDay {
+	hour, minute, second := t.Clock()
+	return TimeOfDay{Hour: hour, Minute: minute, Second: second, Nanosecond: t.Nanosecond()}
+}
+
+// On returns the time of day on the date in loc
+func (t TimeOfDay) On(d Date, loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, t.Hour, t.Minute, t.Second, t.Nanosecond, loc)
+}
+
+func (t TimeOfDay) IsZero() bool {
+	return t == TimeOfDay{}
+}
+
+func (t TimeOfDay) String() string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if t.Nanosecond != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%09d", t.Nanosecond), "0")
+	}
+
+	return s
+}
+
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *TimeOfDay) UnmarshalText(text []byte) error {
+	parsed, err := ParseTimeOfDay(string(text))
+	if err != nil {
+		return err
+	}
+
+	*t = parsed
+	return nil
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return t.UnmarshalText([]byte(s))
+}
+
+// MarshalGQL implements graphql.Marshaler
+func (t TimeOfDay) MarshalGQL(w io.Writer) {
+	_, _ = io.WriteString(w, strconv.Quote(t.String()))
+}
+
+// UnmarshalGQL implements graphql.Unmarshaler
+func (t *TimeOfDay) UnmarshalGQL(v interface{}) error {
+	switch typed := v.(type) {
+	case string:
+		return t.UnmarshalText([]byte(typed))
+	case TimeOfDay:
+		*t = typed
+		return nil
+	case time.Time:
+		*t = TimeOfDayOf(typed)
+		return nil
+	}
+
+	return fmt.Errorf("expected a %s time string, got %T", TimeOfDayLayout, v)
+}
@@ -0,0 +1,196 @@
+package applychanges
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type scheduledRecord struct {
+	Opens  TimeOfDay  `json:"opens"`
+	Closes *TimeOfDay `json:"closes"`
+	Cutoff time.Time  `json:"cutoff" applyas:"time"`
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    TimeOfDay
+		wantErr bool
+	}{
+		{s: "09:30", want: TimeOfDay{Hour: 9, Minute: 30}},
+		{s: "17:45:10", want: TimeOfDay{Hour: 17, Minute: 45, Second: 10}},
+		{s: "23:59:59.5", want: TimeOfDay{Hour: 23, Minute: 59, Second: 59, Nanosecond: 500000000}},
+		{s: "00:00:00.000000001", want: TimeOfDay{Nanosecond: 1}},
+		{s: "24:00", wantErr: true},
+		{s: "9:65", wantErr: true},
+		{s: "2024-06-01", wantErr: true},
+		{s: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseTimeOfDay(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTimeOfDay() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseTimeOfDay() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeOfDayEncoding(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeOfDay TimeOfDay
+		want      string
+	}{
+		{name: "whole seconds", timeOfDay: TimeOfDay{Hour: 9, Minute: 30}, want: "09:30:00"},
+		{name: "fraction", timeOfDay: TimeOfDay{Hour: 23, Minute: 59, Second: 59, Nanosecond: 250000000}, want: "23:59:59.25"},
+		{name: "midnight", timeOfDay: TimeOfDay{}, want: "00:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.timeOfDay.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+
+			encoded, err := json.Marshal(tt.timeOfDay)
+			if err != nil || string(encoded) != `"`+tt.want+`"` {
+				t.Fatalf("json.Marshal() = %s, %v, want %q", encoded, err, tt.want)
+			}
+			var decoded TimeOfDay
+			if err := json.Unmarshal(encoded, &decoded); err != nil || decoded != tt.timeOfDay {
+				t.Errorf("json.Unmarshal() = %+v, %v, want %+v", decoded, err, tt.timeOfDay)
+			}
+
+			var gql bytes.Buffer
+			tt.timeOfDay.MarshalGQL(&gql)
+			if gql.String() != `"`+tt.want+`"` {
+				t.Errorf("MarshalGQL() = %s, want %q", gql.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeOfDayUnmarshalGQL(t *testing.T) {
+	tokyo := time.FixedZone("Tokyo", 9*60*60)
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    TimeOfDay
+		wantErr string
+	}{
+		{name: "string", value: "08:15", want: TimeOfDay{Hour: 8, Minute: 15}},
+		{name: "TimeOfDay", value: TimeOfDay{Hour: 8}, want: TimeOfDay{Hour: 8}},
+		{name: "time in its own zone", value: time.Date(2024, time.June, 1, 8, 15, 0, 0, tokyo), want: TimeOfDay{Hour: 8, Minute: 15}},
+		{name: "number", value: 815, wantErr: "expected a 15:04:05 time string, got int"},
+		{name: "bad string", value: "noon", wantErr: "cannot parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got TimeOfDay
+			err := got.UnmarshalGQL(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("UnmarshalGQL() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("UnmarshalGQL() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTimesOfDay(t *testing.T) {
+	tokyo := time.FixedZone("Tokyo", 9*60*60)
+	epochDay := func(hour, minute int) time.Time {
+		return time.Date(0, time.January, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		changes    map[string]interface{}
+		wantOpens  TimeOfDay
+		wantCloses *TimeOfDay
+		wantCutoff time.Time
+		wantErr    string
+	}{
+		{
+			name:       "TimeOfDay from strings",
+			changes:    map[string]interface{}{"opens": "08:00", "closes": "17:30:00"},
+			wantOpens:  TimeOfDay{Hour: 8},
+			wantCloses: &TimeOfDay{Hour: 17, Minute: 30},
+		},
+		{
+			name:    "TimeOfDay cleared",
+			changes: map[string]interface{}{"opens": nil, "closes": nil},
+		},
+		{
+			name:       "tagged time.Time from a string",
+			changes:    map[string]interface{}{"cutoff": "16:45"},
+			wantCutoff: epochDay(16, 45),
+		},
+		{
+			name:       "tagged time.Time from an instant, in its own zone",
+			changes:    map[string]interface{}{"cutoff": time.Date(2024, time.June, 1, 16, 45, 0, 0, tokyo)},
+			wantCutoff: epochDay(16, 45),
+		},
+		{
+			name:    "invalid tagged time",
+			changes: map[string]interface{}{"cutoff": "2024-06-01T16:45:00Z"},
+			wantErr: "error decoding 'cutoff': expected a 15:04:05 time",
+		},
+		{
+			name:    "invalid TimeOfDay",
+			changes: map[string]interface{}{"opens": "25:00"},
+			wantErr: "opens",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closes := TimeOfDay{Hour: 20}
+			record := scheduledRecord{Opens: TimeOfDay{Hour: 6}, Closes: &closes}
+			if _, ok := tt.changes["opens"]; !ok {
+				record.Opens = tt.wantOpens
+			}
+			if _, ok := tt.changes["closes"]; !ok {
+				record.Closes = tt.wantCloses
+			}
+
+			_, err := ApplyChanges(tt.changes, &record)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChanges() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			if record.Opens != tt.wantOpens {
+				t.Errorf("Opens = %v, want %v", record.Opens, tt.wantOpens)
+			}
+			if (record.Closes == nil) != (tt.wantCloses == nil) || (record.Closes != nil && *record.Closes != *tt.wantCloses) {
+				t.Errorf("Closes = %v, want %v", record.Closes, tt.wantCloses)
+			}
+			if !record.Cutoff.Equal(tt.wantCutoff) {
+				t.Errorf("Cutoff = %v, want %v", record.Cutoff, tt.wantCutoff)
+			}
+		})
+	}
+}
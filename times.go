@@ -0,0 +1,161 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parsesTimes reports whether the apply reads times and durations in more
+// ways than the built-in decode hook does
+func (cfg *config) parsesTimes() bool {
+	return len(cfg.timeLayouts) > 0 || cfg.epochUnit > 0 || cfg.durationUnit > 0 ||
+		cfg.timeZone != nil || cfg.requireTimeOffset
+}
+
+// timeHook converts strings in the configured layouts and epoch numbers for
+// time.Time destinations, and numbers in the configured unit for
+// time.Duration ones, leaving everything else to the built-in hook. The times
+// it reads are normalized into the configured time zone, while time.Time
+// values are left as they are (they weren't read from a client's input, and
+// are already truncated for `applyas` fields).
+func (cfg *config) timeHook(a reflect.Type, b reflect.Type, v interface{}) (interface{}, error) {
+	if b == durationType && cfg.durationUnit > 0 && a != durationType {
+		if d, ok := unitDuration(v, cfg.durationUnit); ok {
+			return d, nil
+		}
+		return v, nil
+	}
+
+	if b != timeType || a == timeType {
+		return v, nil
+	}
+
+	if s, ok := v.(string); ok {
+		return cfg.parseTime(s)
+	}
+
+	if cfg.epochUnit > 0 {
+		if t, ok := epochTime(v, cfg.epochUnit); ok {
+			return cfg.normalizeTime(t), nil
+		}
+	}
+
+	return v, nil
+}
+
+// parseTime parses s as RFC3339Nano or one of the configured layouts,
+// whichever parses first. Layouts without a zone offset read s in the
+// configured time zone (UTC by default), unless offsets are required.
+func (cfg *config) parseTime(s string) (time.Time, error) {
+	loc := time.UTC
+	if cfg.timeZone != nil {
+		loc = cfg.timeZone
+	}
+
+	layouts := append([]string{time.RFC3339Nano}, cfg.timeLayouts...)
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			continue
+		}
+
+		if cfg.requireTimeOffset && !hasZoneOffset(layout) {
+			return time.Time{}, fmt.Errorf("%q has no time zone offset", s)
+		}
+		return cfg.normalizeTime(t), nil
+	}
+
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time in any of the layouts %s", s, strings.Join(layouts, ", "))
+}
+
+// normalizeTime converts t into the configured time zone, if any
+func (cfg *config) normalizeTime(t time.Time) time.Time {
+	if cfg.timeZone == nil {
+		return t
+	}
+
+	return t.In(cfg.timeZone)
+}
+
+// hasZoneOffset reports whether a time layout reads a zone offset or name
+func hasZoneOffset(layout string) bool {
+	return strings.Contains(layout, "Z07") || strings.Contains(layout, "-07") || strings.Contains(layout, "MST")
+}
+
+// unitDuration reads a number as that many units
+func unitDuration(value interface{}, unit time.Duration) (time.Duration, bool) {
+	n, f, integral, ok := numberOf(value)
+	if !ok {
+		return 0, false
+	}
+
+	if !integral {
+		return time.Duration(math.Round(f * float64(unit))), true
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+// epochTime reads a number as the time that many units after the Unix epoch
+func epochTime(value interface{}, unit time.Duration) (time.Time, bool) {
+	n, f, integral, ok := numberOf(value)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if !integral {
+		seconds, fraction := math.Modf(f * unit.Seconds())
+		return time.Unix(int64(seconds), int64(fraction*float64(time.Second))).UTC(), true
+	}
+
+	switch {
+	case unit%time.Second == 0:
+		return time.Unix(n*int64(unit/time.Second), 0).UTC(), true
+	case time.Second%unit == 0:
+		perSecond := int64(time.Second / unit)
+		return time.Unix(n/perSecond, n%perSecond*int64(unit)).UTC(), true
+	}
+
+	return time.Unix(0, n*int64(unit)).UTC(), true
+}
+
+// numberOf reads a numeric change (including json.Numbers, see
+// ApplyMergePatch), as an int64 when it's integral and a float64 otherwise
+func numberOf(value interface{}) (n int64, f float64, integral bool, ok bool) {
+	if number, isNumber := value.(json.Number); isNumber {
+		if n, err := number.Int64(); err == nil {
+			return n, 0, true, true
+		}
+
+		f, err := number.Float64()
+		if err != nil {
+			return 0, 0, false, false
+		}
+		value = f
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), 0, true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, float64(v.Uint()), false, true
+		}
+		return int64(v.Uint()), 0, true, true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), 0, true, true
+		}
+		return 0, f, false, true
+	}
+
+	return 0, 0, false, false
+}
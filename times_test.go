@@ -0,0 +1,234 @@
+package applychanges
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type timedRecord struct {
+	At       time.Time      `json:"at"`
+	Optional *time.Time     `json:"optional"`
+	Window   time.Duration  `json:"window"`
+	Timeout  *time.Duration `json:"timeout"`
+}
+
+func TestWithTimeLayouts(t *testing.T) {
+	june1 := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		layouts []string
+		value   interface{}
+		want    time.Time
+		wantErr string
+	}{
+		{name: "RFC3339 without layouts", value: "2024-06-01T12:30:00Z", want: june1.Add(12*time.Hour + 30*time.Minute)},
+		{name: "RFC3339 with a fraction", value: "2024-06-01T00:00:00.25Z", want: june1.Add(250 * time.Millisecond)},
+		{name: "RFC3339 tried first", layouts: []string{"2006-01-02"}, value: "2024-06-01T00:00:00Z", want: june1},
+		{name: "date layout", layouts: []string{"2006-01-02"}, value: "2024-06-01", want: june1},
+		{name: "layouts in order", layouts: []string{"2006-01-02", time.RFC1123}, value: "Sat, 01 Jun 2024 00:00:00 UTC", want: june1},
+		{name: "US layout", layouts: []string{"01/02/2006"}, value: "06/01/2024", want: june1},
+		{
+			name:    "no layout matches",
+			layouts: []string{"2006-01-02"},
+			value:   "June 1st",
+			wantErr: `cannot parse "June 1st" as a time in any of the layouts 2006-01-02T15:04:05.999999999Z07:00, 2006-01-02`,
+		},
+		{name: "date without layouts", value: "2024-06-01", wantErr: "cannot parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record timedRecord
+			_, err := ApplyChanges(map[string]interface{}{"at": tt.value, "optional": tt.value}, &record, WithTimeLayouts(tt.layouts...))
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChanges() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+			if !record.At.Equal(tt.want) || record.Optional == nil || !record.Optional.Equal(tt.want) {
+				t.Errorf("times = %v, %v, want %v", record.At, record.Optional, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithEpochTimes(t *testing.T) {
+	tests := []struct {
+		name    string
+		unit    time.Duration
+		value   interface{}
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "seconds", unit: time.Second, value: 1717200000, want: time.Unix(1717200000, 0)},
+		{name: "fractional seconds", unit: time.Second, value: 1717200000.5, want: time.Unix(1717200000, int64(500*time.Millisecond))},
+		{name: "milliseconds", unit: time.Millisecond, value: int64(1717200000123), want: time.Unix(1717200000, int64(123*time.Millisecond))},
+		{name: "negative milliseconds", unit: time.Millisecond, value: -1500, want: time.Unix(-1, -int64(500*time.Millisecond))},
+		{name: "minutes", unit: time.Minute, value: 2, want: time.Unix(120, 0)},
+		{name: "integral float", unit: time.Second, value: 1717200000.0, want: time.Unix(1717200000, 0)},
+		{name: "JSON number", unit: time.Millisecond, value: json.Number("1717200000123"), want: time.Unix(1717200000, int64(123*time.Millisecond))},
+		{name: "unsigned", unit: time.Second, value: uint32(60), want: time.Unix(60, 0)},
+		{name: "strings still parsed", unit: time.Second, value: "2024-06-01T00:00:00Z", want: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "numbers without the option", value: 1717200000, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.unit > 0 {
+				opts = append(opts, WithEpochTimes(tt.unit))
+			}
+
+			var record timedRecord
+			_, err := ApplyChanges(map[string]interface{}{"at": tt.value, "optional": tt.value}, &record, opts...)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ApplyChanges() = %v, want an error", record.At)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+			if !record.At.Equal(tt.want) || record.Optional == nil || !record.Optional.Equal(tt.want) {
+				t.Errorf("times = %v, %v, want %v", record.At, record.Optional, tt.want)
+			}
+			if _, isString := tt.value.(string); !isString && record.At.Location() != time.UTC {
+				t.Errorf("location = %v, want UTC", record.At.Location())
+			}
+		})
+	}
+}
+
+func TestDurations(t *testing.T) {
+	tests := []struct {
+		name    string
+		unit    time.Duration
+		value   interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "string", value: "1h30m", want: 90 * time.Minute},
+		{name: "fractional string", value: "1.5s", want: 1500 * time.Millisecond},
+		{name: "string with a unit", unit: time.Second, value: "2m", want: 2 * time.Minute},
+		{name: "nanoseconds by default", value: 1500, want: 1500 * time.Nanosecond},
+		{name: "Duration", unit: time.Second, value: 3 * time.Second, want: 3 * time.Second},
+		{name: "seconds", unit: time.Second, value: 90, want: 90 * time.Second},
+		{name: "fractional seconds", unit: time.Second, value: 1.25, want: 1250 * time.Millisecond},
+		{name: "milliseconds", unit: time.Millisecond, value: int64(250), want: 250 * time.Millisecond},
+		{name: "JSON number", unit: time.Second, value: json.Number("45"), want: 45 * time.Second},
+		{name: "negative", unit: time.Minute, value: -5, want: -5 * time.Minute},
+		{name: "bad string", value: "soon", wantErr: true},
+		{name: "bool", unit: time.Second, value: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.unit > 0 {
+				opts = append(opts, WithDurationUnit(tt.unit))
+			}
+
+			var record timedRecord
+			_, err := ApplyChanges(map[string]interface{}{"window": tt.value, "timeout": tt.value}, &record, opts...)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ApplyChanges() = %v, want an error", record.Window)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+			if record.Window != tt.want || record.Timeout == nil || *record.Timeout != tt.want {
+				t.Errorf("durations = %v, %v, want %v", record.Window, record.Timeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeZones(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	tokyo := time.FixedZone("Tokyo", 9*60*60)
+	noon := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		value        interface{}
+		want         time.Time
+		wantLocation *time.Location
+		wantOffset   int
+		wantErr      string
+	}{
+		{name: "offset kept without normalizing", value: "2024-06-01T21:00:00+09:00", want: noon, wantOffset: 9 * 60 * 60},
+		{name: "normalized to UTC", opts: []Option{WithNormalizeTimeZone(time.UTC)}, value: "2024-06-01T21:00:00+09:00", want: noon, wantLocation: time.UTC},
+		{name: "normalized to another zone", opts: []Option{WithNormalizeTimeZone(newYork)}, value: "2024-06-01T12:00:00Z", want: noon, wantLocation: newYork},
+		{
+			name:         "layout without an offset read in the zone",
+			opts:         []Option{WithNormalizeTimeZone(newYork), WithTimeLayouts("2006-01-02 15:04")},
+			value:        "2024-06-01 08:00",
+			want:         noon,
+			wantLocation: newYork,
+		},
+		{name: "layout without an offset read as UTC", opts: []Option{WithTimeLayouts("2006-01-02 15:04")}, value: "2024-06-01 12:00", want: noon, wantLocation: time.UTC},
+		{name: "epochs normalized", opts: []Option{WithNormalizeTimeZone(tokyo), WithEpochTimes(time.Second)}, value: noon.Unix(), want: noon, wantLocation: tokyo},
+		{name: "time.Time values left alone", opts: []Option{WithNormalizeTimeZone(time.UTC)}, value: noon.In(tokyo), want: noon, wantLocation: tokyo},
+		{name: "offset required and given", opts: []Option{WithRequireTimeOffset()}, value: "2024-06-01T21:00:00+09:00", want: noon, wantOffset: 9 * 60 * 60},
+		{
+			name:       "offset required by a layout with one",
+			opts:       []Option{WithRequireTimeOffset(), WithTimeLayouts("2006-01-02 15:04 -0700")},
+			value:      "2024-06-01 08:00 -0400",
+			want:       noon,
+			wantOffset: -4 * 60 * 60,
+		},
+		{
+			name:    "offset required but missing",
+			opts:    []Option{WithRequireTimeOffset(), WithTimeLayouts("2006-01-02 15:04")},
+			value:   "2024-06-01 12:00",
+			wantErr: `"2024-06-01 12:00" has no time zone offset`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record timedRecord
+			_, err := ApplyChanges(map[string]interface{}{"at": tt.value, "optional": tt.value}, &record, tt.opts...)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChanges() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyChanges() error = %v", err)
+			}
+
+			for _, got := range []time.Time{record.At, *record.Optional} {
+				if !got.Equal(tt.want) {
+					t.Errorf("time = %v, want %v", got, tt.want)
+				}
+				if tt.wantLocation != nil && got.Location() != tt.wantLocation {
+					t.Errorf("location = %v, want %v", got.Location(), tt.wantLocation)
+				}
+				if _, offset := got.Zone(); tt.wantLocation == nil && offset != tt.wantOffset {
+					t.Errorf("offset = %d, want %d", offset, tt.wantOffset)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package applychanges
+
+import (
+	"context"
+	"time"
+)
+
+// The stages of an apply that WithTimings times, as the keys of
+// ApplyResult.Timings
+const (
+	// StageSanitize is checking and normalizing the changes: the value and key
+	// checks, Sanitize, the ETag and conditions, and ChangeSanitizers
+	StageSanitize = "sanitize"
+
+	// StageAuthorize is filtering and authorizing the changes before they're
+	// decoded (immutable fields, field locks, the Policy, roles and
+	// FieldAuthorizers), and the ValueAuthorizers after
+	StageAuthorize = "authorize"
+
+	// StageDecode is BeforeApply, stamping the metadata and decoding the
+	// changes onto the target, along with diffing it
+	StageDecode = "decode"
+
+	// StageValidate is AfterApply and the Validator
+	StageValidate = "validate"
+
+	// StageEmit is publishing the ChangeApplied event
+	StageEmit = "emit"
+
+	// StageAudit is recording the AuditEntry
+	StageAudit = "audit"
+
+	// StageFinalize is saving the result for its idempotency key and recording
+	// it in the History
+	StageFinalize = "finalize"
+)
+
+// TimingStages are the stages WithTimings times, in the order an apply runs
+// them
+var TimingStages = []string{StageSanitize, StageAuthorize, StageDecode, StageValidate, StageEmit, StageAudit, StageFinalize}
+
+// MetricsSink receives the stage timings of every successful apply, see
+// WithMetricsSink
+type MetricsSink interface {
+	RecordTimings(ctx context.Context, targetType string, timings map[string]time.Duration)
+}
+
+// stageTimer adds up the time each stage of an apply takes on the apply's
+// Clock. A nil stageTimer times nothing.
+type stageTimer struct {
+	clock   Clock
+	last    time.Time
+	timings map[string]time.Duration
+}
+
+// stageTimer starts timing the apply's stages from the time it started, or
+// returns nil when they aren't timed
+func (cfg *config) stageTimer() *stageTimer {
+	if !cfg.timings && cfg.metricsSink == nil {
+		return nil
+	}
+
+	return &stageTimer{clock: cfg.clock, last: cfg.now, timings: make(map[string]time.Duration, len(TimingStages))}
+}
+
+// lap adds the time since the previous lap (or the start) to the stage
+func (t *stageTimer) lap(stage string) {
+	if t == nil {
+		return
+	}
+
+	now := t.clock.Now()
+	t.timings[stage] += now.Sub(t.last)
+	t.last = now
+}
+
+// finish puts the timings in the result and hands them to the MetricsSink
+func (t *stageTimer) finish(result *ApplyResult, to interface{}, cfg *config) {
+	if t == nil {
+		return
+	}
+
+	result.Timings = t.timings
+	if cfg.metricsSink != nil {
+		cfg.metricsSink.RecordTimings(cfg.ctx, targetTypeName(to), t.timings)
+	}
+}
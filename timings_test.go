@@ -0,0 +1,165 @@
+package applychanges
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// doublingClock moves on by twice as long each time it's read, so the laps
+// of an apply's stages each take twice as long as the one before
+type doublingClock struct {
+	start time.Time
+	reads int
+}
+
+func (c *doublingClock) Now() time.Time {
+	c.reads++
+	return c.start.Add(time.Duration(1<<c.reads) * time.Millisecond)
+}
+
+// manualClock only moves when told to
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+type recordingMetrics struct {
+	targetType string
+	timings    map[string]time.Duration
+}
+
+func (m *recordingMetrics) RecordTimings(_ context.Context, targetType string, timings map[string]time.Duration) {
+	m.targetType = targetType
+	m.timings = timings
+}
+
+func TestWithTimings(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("disabled by default", func(t *testing.T) {
+		report := dualWriteReport{}
+		result, err := ApplyChangesWrapper(map[string]interface{}{"city": "Tampa"}, "EUA1", &report)
+		if err != nil {
+			t.Fatalf("ApplyChangesWrapper() error = %v", err)
+		}
+		if result.Timings != nil {
+			t.Errorf("Timings = %v, want nil", result.Timings)
+		}
+	})
+
+	t.Run("stages in order", func(t *testing.T) {
+		report := dualWriteReport{}
+		result, err := ApplyChangesWrapper(map[string]interface{}{"city": "Tampa"}, "EUA1", &report,
+			WithTimings(), WithClock(&doublingClock{start: start}))
+		if err != nil {
+			t.Fatalf("ApplyChangesWrapper() error = %v", err)
+		}
+
+		want := map[string]time.Duration{}
+		for i, stage := range TimingStages {
+			want[stage] = time.Duration(1<<(i+1)) * time.Millisecond
+		}
+		if !reflect.DeepEqual(result.Timings, want) {
+			t.Errorf("Timings = %v, want %v", result.Timings, want)
+		}
+	})
+
+	tests := []struct {
+		name string
+		opts func(clock *manualClock) []Option
+		want map[string]time.Duration
+	}{
+		{
+			name: "slow validator",
+			opts: func(clock *manualClock) []Option {
+				return []Option{WithValidator(ValidatorFunc(func(interface{}) error {
+					clock.now = clock.now.Add(250 * time.Millisecond)
+					return nil
+				}))}
+			},
+			want: map[string]time.Duration{StageValidate: 250 * time.Millisecond},
+		},
+		{
+			name: "slow audit sink",
+			opts: func(clock *manualClock) []Option {
+				return []Option{WithAuditSink(slowSink{clock: clock, delay: 80 * time.Millisecond})}
+			},
+			want: map[string]time.Duration{StageAudit: 80 * time.Millisecond},
+		},
+		{
+			name: "value authorizers count as authorizing",
+			opts: func(clock *manualClock) []Option {
+				return []Option{WithValueAuthorizer(func(FieldChange, Principal) error {
+					clock.now = clock.now.Add(time.Millisecond)
+					return nil
+				})}
+			},
+			want: map[string]time.Duration{StageAuthorize: time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &manualClock{now: start}
+			report := dualWriteReport{}
+			opts := append(tt.opts(clock), WithTimings(), WithClock(clock))
+
+			result, err := ApplyChangesWrapper(map[string]interface{}{"city": "Tampa"}, "EUA1", &report, opts...)
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			for _, stage := range TimingStages {
+				got, ok := result.Timings[stage]
+				if !ok {
+					t.Errorf("Timings has no %s stage", stage)
+				}
+				if got != tt.want[stage] {
+					t.Errorf("Timings[%s] = %v, want %v", stage, got, tt.want[stage])
+				}
+			}
+		})
+	}
+}
+
+type slowSink struct {
+	clock *manualClock
+	delay time.Duration
+}
+
+func (s slowSink) Record(context.Context, AuditEntry) error {
+	s.clock.now = s.clock.now.Add(s.delay)
+	return nil
+}
+
+func TestWithMetricsSink(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	metrics := &recordingMetrics{}
+	report := dualWriteReport{}
+
+	validator := ValidatorFunc(func(interface{}) error {
+		clock.now = clock.now.Add(time.Second)
+		return nil
+	})
+	result, err := ApplyChangesWrapper(map[string]interface{}{"city": "Tampa"}, "EUA1", &report,
+		WithMetricsSink(metrics), WithValidator(validator), WithClock(clock))
+	if err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	if metrics.targetType != "dualWriteReport" {
+		t.Errorf("recorded target type %q, want dualWriteReport", metrics.targetType)
+	}
+	if metrics.timings[StageValidate] != time.Second || !reflect.DeepEqual(metrics.timings, result.Timings) {
+		t.Errorf("recorded timings %v, want the result's %v", metrics.timings, result.Timings)
+	}
+
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"weather": 7}, "EUA1", &report, WithMetricsSink(&recordingMetrics{})); err == nil {
+		t.Fatal("ApplyChangesWrapper() error = nil, want the mistyped change rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package applychanges
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// truncateChanges returns copies of the changes with their string and byte
+// slice values cut down to n bytes, see WithMaxDiffValueBytes. The changes
+// themselves are left whole for the ValueAuthorizers and AfterAppliers, which
+// may hold on to them.
+func truncateChanges(changes []FieldChange, n int) []FieldChange {
+	truncated := make([]FieldChange, len(changes))
+	for i, change := range changes {
+		truncateChange(&change, n)
+		truncated[i] = change
+	}
+
+	return truncated
+}
+
+// truncateChange cuts the change's string and byte slice values down to n
+// bytes
+func truncateChange(change *FieldChange, n int) {
+	var oldTruncated, newTruncated bool
+	change.Old, oldTruncated = truncateValue(change.Old, n)
+	change.New, newTruncated = truncateValue(change.New, n)
+	change.Truncated = oldTruncated || newTruncated
+}
+
+// truncateValue cuts a string or byte slice longer than n bytes at n (backing
+// up to the start of a rune for strings) and marks how many bytes were left
+// out, returning other values as they are
+func truncateValue(value interface{}, n int) (interface{}, bool) {
+	switch typed := value.(type) {
+	case string:
+		if len(typed) <= n {
+			return value, false
+		}
+		cut := n
+		for cut > 0 && !utf8.RuneStart(typed[cut]) {
+			cut--
+		}
+		return typed[:cut] + truncationMarker(len(typed)-cut), true
+	case []byte:
+		if len(typed) <= n {
+			return value, false
+		}
+		truncated := make([]byte, n, n+32)
+		copy(truncated, typed)
+		return append(truncated, truncationMarker(len(typed)-n)...), true
+	}
+
+	return value, false
+}
+
+func truncationMarker(omitted int) string {
+	return fmt.Sprintf("…[%d more bytes]", omitted)
+}
+
+// checkNotTruncated fails for the first truncated change, whose values can't
+// be written back
+func checkNotTruncated(changes []FieldChange) error {
+	for _, change := range changes {
+		if change.Truncated {
+			return fmt.Errorf("'%s' was truncated, see WithMaxDiffValueBytes", change.Path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package applychanges
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type documentRecord struct {
+	BaseStruct
+	Title string  `json:"title"`
+	Body  string  `json:"body"`
+	Blob  []byte  `json:"blob"`
+	Notes *string `json:"notes"`
+}
+
+func TestTruncateValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         interface{}
+		want          interface{}
+		wantTruncated bool
+	}{
+		{name: "short string", value: "abc", want: "abc"},
+		{name: "exactly n bytes", value: "abcdef", want: "abcdef"},
+		{name: "long string", value: "abcdefgh", want: "abcdef…[2 more bytes]", wantTruncated: true},
+		{name: "cut inside a rune", value: "abcd€", want: "abcd…[3 more bytes]", wantTruncated: true},
+		{name: "long bytes", value: []byte("abcdefgh"), want: []byte("abcdef…[2 more bytes]"), wantTruncated: true},
+		{name: "short bytes", value: []byte("abc"), want: []byte("abc")},
+		{name: "other values", value: 1234567890, want: 1234567890},
+		{name: "nil", value: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateValue(tt.value, 6)
+			if !reflect.DeepEqual(got, tt.want) || truncated != tt.wantTruncated {
+				t.Errorf("truncateValue() = %q, %v, want %q, %v", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestWithMaxDiffValueBytes(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	record := documentRecord{Title: "Draft", Body: body}
+
+	result, err := ApplyChangesWrapper(map[string]interface{}{"title": "Final", "body": body + "y"}, "EUA1", &record,
+		WithMaxDiffValueBytes(10), WithModifiedDts(false))
+	if err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if record.Body != body+"y" {
+		t.Errorf("Body has %d bytes, want the whole %d applied", len(record.Body), len(body)+1)
+	}
+
+	want := []FieldChange{
+		{Path: "body", Old: "xxxxxxxxxx…[90 more bytes]", New: "xxxxxxxxxx…[91 more bytes]", Truncated: true},
+		{Path: "modifiedBy", New: "EUA1"},
+		{Path: "title", Old: "Draft", New: "Final"},
+	}
+	if !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("Changes = %+v, want %+v", result.Changes, want)
+	}
+
+	if _, err := Undo(result, "EUA1", &record); err == nil || err.Error() != "'body' was truncated, see WithMaxDiffValueBytes" {
+		t.Errorf("Undo() error = %v, want the truncated field reported", err)
+	}
+	if record.Body != body+"y" || record.Title != "Final" {
+		t.Errorf("target = %+v, want the refused undo to leave it alone", record)
+	}
+
+	if _, _, err := BuildUpdateSQL(result.Changes, "document", &record); err == nil {
+		t.Error("BuildUpdateSQL() error = nil, want the truncated field reported")
+	}
+}
+
+func TestWithMaxDiffValueBytesAuthorizesWholeValues(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	record := documentRecord{Title: "Draft", Body: body}
+
+	var authorized interface{}
+	authorizer := func(change FieldChange, _ Principal) error {
+		if change.Path == "body" {
+			authorized = change.New
+		}
+		if change.Truncated {
+			return errors.New("truncated before authorization")
+		}
+		return nil
+	}
+	sink := &recordingSink{}
+
+	result, err := ApplyChangesWrapper(map[string]interface{}{"body": body + "y"}, "EUA1", &record,
+		WithMaxDiffValueBytes(10), WithValueAuthorizer(authorizer), WithAuditSink(sink))
+	if err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	if authorized != body+"y" {
+		t.Errorf("authorizer saw %v, want the whole new body", authorized)
+	}
+	if len(result.Changes) == 0 || !result.Changes[0].Truncated {
+		t.Errorf("Changes = %+v, want the body truncated in the result", result.Changes)
+	}
+	if len(sink.entries) != 1 || !sink.entries[0].Changes[0].Truncated {
+		t.Errorf("audit entries = %+v, want the body truncated", sink.entries)
+	}
+}
@@ -0,0 +1,310 @@
+package applychanges
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// typeConfigs are the options registered with RegisterTypeConfig, by struct
+// type
+var typeConfigs registry[[]Option]
+
+// RegisterTypeConfig registers options for every apply to a T (or a pointer to
+// one) in the process, e.g. the tag a type's changes are keyed by, or the
+// fields no caller may change. Registering a type again replaces its options.
+// See configFor for how they're layered with the call's own options.
+func RegisterTypeConfig[T any](opts ...Option) {
+	register("RegisterTypeConfig", func() {
+		typeConfigs.set(reflect.TypeOf((*T)(nil)).Elem(), append([]Option(nil), opts...))
+	})
+}
+
+// configFor resolves the options of an apply to a targetType, in the same
+// order for every entry point: the package defaults first, then the options
+// registered for the type with RegisterTypeConfig, then the call's own options.
+// A later layer overrides the settings of an earlier one, except for the
+// options that add to a setting (WithDecodeHook, WithCondition,
+// WithImmutableFields and the like), which add to it. The targetType may be a
+// pointer, or nil when there's no target.
+func configFor(targetType reflect.Type, opts []Option) *config {
+	cfg := &config{
+		tagName:            "json",
+		errorUnused:        true,
+		caseSensitiveKeys:  true,
+		zeroFields:         true,
+		rollback:           true,
+		modifiedDts:        true,
+		maxRecentModifiers: defaultMaxRecentModifiers,
+		clock:              systemClock{},
+		ctx:                context.Background(),
+	}
+
+	for targetType != nil && targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+	if targetType != nil {
+		registered, _ := typeConfigs.get(targetType)
+		for _, opt := range registered {
+			opt(cfg)
+		}
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// OptionsSnapshot is every setting an apply resolves its options to, see
+// EffectiveOptions. It marshals to JSON for logging; settings that are code
+// (validators, sinks, hooks and the like) are only reported as set or counted.
+type OptionsSnapshot struct {
+	TagName              string   `json:"tagName"`
+	FallbackTagNames     []string `json:"fallbackTagNames,omitempty"`
+	CaseSensitiveKeys    bool     `json:"caseSensitiveKeys"`
+	SnakeCaseKeys        bool     `json:"snakeCaseKeys"`
+	AllowIdenticalKeys   bool     `json:"allowIdenticalKeys"`
+	AllowConflictingKeys bool     `json:"allowConflictingKeys"`
+
+	// MetadataKeys are the keys of the metadata fields the target type has
+	// (modifiedBy, lockVersion, deletedDts, ...), by field name
+	MetadataKeys map[string]string `json:"metadataKeys,omitempty"`
+
+	// ReadonlyFields are the paths of the fields tagged `apply:"immutable"` and
+	// those named by WithImmutableFields, sorted
+	ReadonlyFields       []string            `json:"readonlyFields,omitempty"`
+	AdminOnlyFields      []string            `json:"adminOnlyFields,omitempty"`
+	AllowedFields        []string            `json:"allowedFields,omitempty"`
+	DeniedFields         []string            `json:"deniedFields,omitempty"`
+	FieldPermissions     map[string][]string `json:"fieldPermissions,omitempty"`
+	DropDisallowedFields bool                `json:"dropDisallowedFields"`
+	SkipImmutableFields  bool                `json:"skipImmutableFields"`
+	IgnoreImmutableTags  bool                `json:"ignoreImmutableTags"`
+
+	ErrorUnused        bool `json:"errorUnused"`
+	ZeroFields         bool `json:"zeroFields"`
+	Rollback           bool `json:"rollback"`
+	DryRun             bool `json:"dryRun"`
+	RequireChanges     bool `json:"requireChanges"`
+	StopOnError        bool `json:"stopOnError"`
+	MaxApplyDepth      int  `json:"maxApplyDepth"`
+	MaxRecentModifiers int  `json:"maxRecentModifiers"`
+	MaxDiffValueBytes  int  `json:"maxDiffValueBytes"`
+	Timings            bool `json:"timings"`
+	FreeNoOps          bool `json:"freeNoOps"`
+
+	ModifiedDts        bool              `json:"modifiedDts"`
+	ModifiedDtsPolicy  string            `json:"modifiedDtsPolicy"`
+	BackfillModifiedBy bool              `json:"backfillModifiedBy"`
+	SliceStrategies    map[string]string `json:"sliceStrategies,omitempty"`
+	MergeMaps          bool              `json:"mergeMaps"`
+	PartialChildren    bool              `json:"partialChildren"`
+
+	TimeLayouts       []string `json:"timeLayouts,omitempty"`
+	EpochUnit         string   `json:"epochUnit,omitempty"`
+	DurationUnit      string   `json:"durationUnit,omitempty"`
+	TimeZone          string   `json:"timeZone,omitempty"`
+	RequireTimeOffset bool     `json:"requireTimeOffset"`
+
+	AllowInvalidUTF8     bool               `json:"allowInvalidUTF8"`
+	AllowNonFiniteFloats bool               `json:"allowNonFiniteFloats"`
+	FloatTolerances      map[string]float64 `json:"floatTolerances,omitempty"`
+
+	GraphQLCoercion bool `json:"graphQLCoercion"`
+
+	ExpectedVersion *int64      `json:"expectedVersion,omitempty"`
+	ExpectedETag    *string     `json:"expectedETag,omitempty"`
+	RejectDeleted   bool        `json:"rejectDeleted"`
+	Conditions      []Condition `json:"conditions,omitempty"`
+
+	DecodeHooks      int  `json:"decodeHooks"`
+	ValueAuthorizers int  `json:"valueAuthorizers"`
+	ApplyPolicy      bool `json:"applyPolicy"`
+	FieldLocks       bool `json:"fieldLocks"`
+	FieldAuthorizer  bool `json:"fieldAuthorizer"`
+	FieldCipher      bool `json:"fieldCipher"`
+	Validator        bool `json:"validator"`
+	AuditSink        bool `json:"auditSink"`
+	EventPublisher   bool `json:"eventPublisher"`
+	History          bool `json:"history"`
+	MetricsSink      bool `json:"metricsSink"`
+	RateLimiter      bool `json:"rateLimiter"`
+
+	IdempotencyStore  bool `json:"idempotencyStore"`
+	ContentDerivedKey bool `json:"contentDerivedKey"`
+
+	MessageCatalog bool `json:"messageCatalog"`
+}
+
+// EffectiveOptions returns the settings an apply to a targetType with the
+// callOpts resolves to, after layering them over the package defaults and the
+// options registered with RegisterTypeConfig the way the apply itself does.
+func EffectiveOptions(targetType reflect.Type, callOpts ...Option) OptionsSnapshot {
+	return configFor(targetType, callOpts).snapshot(targetType)
+}
+
+func (cfg *config) snapshot(targetType reflect.Type) OptionsSnapshot {
+	decodeHooks := len(loadDecodeHooks().hooks) + len(cfg.decodeHooks)
+
+	snapshot := OptionsSnapshot{
+		TagName:              cfg.tagName,
+		FallbackTagNames:     cfg.fallbackTagNames,
+		CaseSensitiveKeys:    cfg.caseSensitiveKeys,
+		SnakeCaseKeys:        cfg.snakeCaseKeys,
+		AllowIdenticalKeys:   cfg.allowIdenticalKeys,
+		AllowConflictingKeys: cfg.allowConflicts,
+		AdminOnlyFields:      sortedSet(cfg.adminOnlyFields),
+		AllowedFields:        sortedSet(cfg.allowedFields),
+		DeniedFields:         sortedSet(cfg.deniedFields),
+		DropDisallowedFields: cfg.dropDisallowed,
+		SkipImmutableFields:  cfg.skipImmutable,
+		IgnoreImmutableTags:  cfg.ignoreImmutable,
+		ErrorUnused:          cfg.errorUnused,
+		ZeroFields:           cfg.zeroFields,
+		Rollback:             cfg.rollback,
+		DryRun:               cfg.dryRun,
+		RequireChanges:       cfg.requireChanges,
+		StopOnError:          cfg.stopOnError,
+		MaxApplyDepth:        cfg.maxDepth,
+		MaxRecentModifiers:   cfg.maxRecentModifiers,
+		MaxDiffValueBytes:    cfg.maxDiffValueBytes,
+		Timings:              cfg.timings || cfg.metricsSink != nil,
+		FreeNoOps:            cfg.freeNoOps,
+		ModifiedDts:          cfg.modifiedDts,
+		ModifiedDtsPolicy:    cfg.modifiedDtsPolicy.String(),
+		BackfillModifiedBy:   cfg.backfillModifiedBy,
+		MergeMaps:            cfg.mergeMaps,
+		PartialChildren:      cfg.partialChildren,
+		TimeLayouts:          cfg.timeLayouts,
+		RequireTimeOffset:    cfg.requireTimeOffset,
+		AllowInvalidUTF8:     cfg.allowInvalidUTF8,
+		AllowNonFiniteFloats: cfg.allowNonFiniteFloats,
+		GraphQLCoercion:      cfg.graphQLCoercion,
+		FloatTolerances:      cfg.floatTolerances,
+		ExpectedVersion:      cfg.expectedVersion,
+		ExpectedETag:         cfg.expectedETag,
+		RejectDeleted:        cfg.rejectDeleted,
+		Conditions:           cfg.conditions,
+		DecodeHooks:          decodeHooks,
+		ValueAuthorizers:     len(cfg.valueAuthorizers),
+		ApplyPolicy:          cfg.policy != nil,
+		FieldLocks:           cfg.fieldLocks != nil || cfg.fieldLocker != nil,
+		FieldAuthorizer:      cfg.fieldAuthorizer != nil,
+		FieldCipher:          cfg.cipher != nil,
+		Validator:            cfg.validator != nil,
+		AuditSink:            cfg.auditSink != nil,
+		EventPublisher:       cfg.eventPublisher != nil,
+		History:              cfg.history != nil,
+		MetricsSink:          cfg.metricsSink != nil,
+		RateLimiter:          cfg.rateLimiter != nil,
+		IdempotencyStore:     cfg.idempotencyStore != nil,
+		ContentDerivedKey:    cfg.contentDerivedKey,
+		MessageCatalog:       cfg.messageCatalog != nil,
+	}
+
+	if cfg.epochUnit != 0 {
+		snapshot.EpochUnit = cfg.epochUnit.String()
+	}
+	if cfg.durationUnit != 0 {
+		snapshot.DurationUnit = cfg.durationUnit.String()
+	}
+	if cfg.timeZone != nil {
+		snapshot.TimeZone = cfg.timeZone.String()
+	}
+
+	if len(cfg.permissions) > 0 {
+		snapshot.FieldPermissions = map[string][]string{}
+		for role, fields := range cfg.permissions {
+			snapshot.FieldPermissions[role] = sortedSet(fields)
+		}
+	}
+
+	if len(cfg.sliceStrategies) > 0 {
+		snapshot.SliceStrategies = map[string]string{}
+		for path, strategy := range cfg.sliceStrategies {
+			snapshot.SliceStrategies[path] = strategy.String()
+		}
+	}
+
+	readonly := sortedSet(cfg.immutableFields)
+	for targetType != nil && targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+	if targetType != nil && targetType.Kind() == reflect.Struct {
+		for _, name := range append(append([]string(nil), bookkeepingFields...), deletionFields...) {
+			if field, ok := structFieldByName(targetType, name); ok {
+				if snapshot.MetadataKeys == nil {
+					snapshot.MetadataKeys = map[string]string{}
+				}
+				snapshot.MetadataKeys[name] = fieldKey(field, cfg.tagName)
+			}
+		}
+
+		if !cfg.ignoreImmutable {
+			readonly = append(readonly, taggedImmutableFields(targetType, cfg.tagName, "", map[reflect.Type]bool{})...)
+		}
+		readonly = sortedSet(setOf(readonly))
+	}
+	snapshot.ReadonlyFields = readonly
+
+	return snapshot
+}
+
+// taggedImmutableFields lists the paths of the fields tagged
+// `apply:"immutable"`, including the fields of nested structs
+func taggedImmutableFields(structType reflect.Type, tagName string, prefix string, seen map[reflect.Type]bool) []string {
+	if seen[structType] {
+		return nil
+	}
+	seen[structType] = true
+	defer delete(seen, structType)
+
+	var paths []string
+	for _, field := range squashedFields(structType) {
+		key := fieldKey(field, tagName)
+		if key == "" || field.PkgPath != "" {
+			continue
+		}
+
+		if isImmutable(field) {
+			paths = append(paths, prefix+key)
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && !isScalar(fieldType) {
+			paths = append(paths, taggedImmutableFields(fieldType, tagName, prefix+key+".", seen)...)
+		}
+	}
+
+	return paths
+}
+
+// sortedSet returns the members of a set, sorted
+func sortedSet(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+
+	return members
+}
+
+func setOf(members []string) map[string]bool {
+	set := make(map[string]bool, len(members))
+	for _, member := range members {
+		set[member] = true
+	}
+
+	return set
+}
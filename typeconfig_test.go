@@ -0,0 +1,120 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type configuredRecord struct {
+	BaseStruct
+	Name            string   `json:"name" db:"display_name"`
+	Code            string   `json:"code" db:"code" apply:"immutable"`
+	RecentModifiers []string `json:"recentModifiers" db:"recent_modifiers"`
+}
+
+// capturedConfig returns an option recording the config it's applied to, so
+// tests can see what an apply resolved its options to
+func capturedConfig(cfg **config) Option {
+	return func(resolved *config) {
+		*cfg = resolved
+	}
+}
+
+func registerTestTypeConfig[T any](t *testing.T, opts ...Option) {
+	RegisterTypeConfig[T](opts...)
+	t.Cleanup(func() {
+		typeConfigs.remove(reflect.TypeOf((*T)(nil)).Elem())
+	})
+}
+
+func TestEffectiveOptionsLayers(t *testing.T) {
+	registerTestTypeConfig[configuredRecord](t, WithTagName("db"), WithMaxRecentModifiers(3), WithImmutableFields("display_name"))
+	recordType := reflect.TypeOf(&configuredRecord{})
+
+	tests := []struct {
+		name  string
+		typ   reflect.Type
+		opts  []Option
+		check func(t *testing.T, snapshot OptionsSnapshot)
+	}{
+		{
+			name: "package defaults",
+			typ:  reflect.TypeOf(nestedRecord{}),
+			check: func(t *testing.T, snapshot OptionsSnapshot) {
+				if snapshot.TagName != "json" || snapshot.MaxRecentModifiers != defaultMaxRecentModifiers || snapshot.ModifiedDtsPolicy != "reject" {
+					t.Errorf("snapshot = %+v, want the defaults", snapshot)
+				}
+				if snapshot.MetadataKeys["ModifiedBy"] != "modifiedBy" || snapshot.MetadataKeys["LockVersion"] != "" {
+					t.Errorf("MetadataKeys = %v, want the BaseStruct fields by json key", snapshot.MetadataKeys)
+				}
+			},
+		},
+		{
+			name: "registered for the type",
+			typ:  recordType,
+			check: func(t *testing.T, snapshot OptionsSnapshot) {
+				if snapshot.TagName != "db" || snapshot.MaxRecentModifiers != 3 {
+					t.Errorf("snapshot = %+v, want the registered options", snapshot)
+				}
+				if snapshot.MetadataKeys["RecentModifiers"] != "recent_modifiers" {
+					t.Errorf("MetadataKeys = %v, want them by db key", snapshot.MetadataKeys)
+				}
+				if want := []string{"code", "created_by", "created_dts", "deleted_by", "deleted_dts", "display_name", "id"}; !reflect.DeepEqual(snapshot.ReadonlyFields, want) {
+					t.Errorf("ReadonlyFields = %v, want %v", snapshot.ReadonlyFields, want)
+				}
+			},
+		},
+		{
+			name: "legacy options",
+			typ:  recordType,
+			opts: LegacyOptions(),
+			check: func(t *testing.T, snapshot OptionsSnapshot) {
+				if snapshot.CaseSensitiveKeys || !snapshot.AllowConflictingKeys || !snapshot.AllowInvalidUTF8 || !snapshot.IgnoreImmutableTags || snapshot.Rollback {
+					t.Errorf("snapshot = %+v, want every legacy opt-out", snapshot)
+				}
+				if want := []string{"display_name"}; !reflect.DeepEqual(snapshot.ReadonlyFields, want) {
+					t.Errorf("ReadonlyFields = %v, want %v", snapshot.ReadonlyFields, want)
+				}
+			},
+		},
+		{
+			name: "call-site options override",
+			typ:  recordType,
+			opts: []Option{WithMaxRecentModifiers(1), WithModifiedDtsPolicy(ClampModifiedDts), WithSliceStrategy("tags", AppendSlice)},
+			check: func(t *testing.T, snapshot OptionsSnapshot) {
+				if snapshot.TagName != "db" || snapshot.MaxRecentModifiers != 1 || snapshot.ModifiedDtsPolicy != "clamp" {
+					t.Errorf("snapshot = %+v, want the call's options over the registered ones", snapshot)
+				}
+				if snapshot.SliceStrategies["tags"] != "append" {
+					t.Errorf("SliceStrategies = %v, want tags appended", snapshot.SliceStrategies)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, EffectiveOptions(tt.typ, tt.opts...))
+		})
+	}
+}
+
+func TestEffectiveOptionsMatchApply(t *testing.T) {
+	registerTestTypeConfig[configuredRecord](t, WithTagName("db"), WithMaxRecentModifiers(2))
+
+	var applied *config
+	opts := []Option{WithDeniedFields("code"), WithAllowIdenticalKeys()}
+	record := configuredRecord{}
+	if _, err := ApplyChangesWrapper(map[string]interface{}{"display_name": "x"}, "EUA1", &record, append(opts, capturedConfig(&applied))...); err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+	if record.Name != "x" {
+		t.Errorf("Name = %q, want the change applied by db key", record.Name)
+	}
+
+	got := applied.snapshot(reflect.TypeOf(record))
+	want := EffectiveOptions(reflect.TypeOf(&record), opts...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("apply resolved %+v, EffectiveOptions() = %+v", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package applychanges
+
+import "reflect"
+
+// Inverse returns the changes that restore what the apply changed: the old
+// value of every applied field (with null for fields that were nil, so fields
+// the apply set are cleared again), leaving out the metadata stamped by every
+// apply. Pass it to Undo rather than applying it directly, since slice and map
+// fields may merge it into their current value, and encrypted fields would be
+// encrypted again.
+func (r ApplyResult) Inverse() map[string]interface{} {
+	inverse := make(map[string]interface{}, len(r.Changes))
+	for _, change := range r.Changes {
+		if !isBookkeeping(change.Path) {
+			inverse[change.Path] = change.Old
+		}
+	}
+
+	return inverse
+}
+
+// Undo restores the fields changed by an apply to their values before it, as
+// an apply by the modifier (so modifiedBy and modifiedDts are stamped anew).
+// The target should be the one the result came from; options apply as usual,
+// apart from merging or encrypting the restored values.
+func Undo(result ApplyResult, modifier string, to interface{}, opts ...Option) (ApplyResult, error) {
+	if err := checkNotTruncated(result.Changes); err != nil {
+		return ApplyResult{}, err
+	}
+
+	cfg := configFor(reflect.TypeOf(to), opts)
+	cfg.modifier = &modifier
+	cfg.restoring = true
+
+	return applyChanges(result.Inverse(), to, cfg)
+}
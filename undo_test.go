@@ -0,0 +1,162 @@
+package applychanges
+
+import (
+	"reflect"
+	"testing"
+)
+
+type undoneRecord struct {
+	BaseStruct
+	Tags map[string]interface{} `json:"tags"`
+	SSN  string                 `json:"ssn" apply:"encrypt"`
+}
+
+func TestInverse(t *testing.T) {
+	tests := []struct {
+		name   string
+		result ApplyResult
+		want   map[string]interface{}
+	}{
+		{
+			name: "old values",
+			result: ApplyResult{Changes: []FieldChange{
+				{Path: "name", Old: "Miami", New: "Tampa"},
+				{Path: "details", Old: nil, New: nestedDetails{Source: "radar"}},
+			}},
+			want: map[string]interface{}{"name": "Miami", "details": nil},
+		},
+		{
+			name: "bookkeeping left out",
+			result: ApplyResult{Changes: []FieldChange{
+				{Path: "modifiedBy", Old: "EUA0", New: "EUA1"},
+				{Path: "modifiedDts", Old: nil, New: "2024-06-01T00:00:00Z"},
+				{Path: "count", Old: 1, New: 2},
+			}},
+			want: map[string]interface{}{"count": 1},
+		},
+		{
+			name:   "nothing changed",
+			result: ApplyResult{},
+			want:   map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Inverse(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inverse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  nestedRecord
+		changes map[string]interface{}
+	}{
+		{
+			name:    "set fields",
+			record:  nestedRecord{Name: "Miami", Count: 1},
+			changes: map[string]interface{}{"name": "Tampa", "count": 2},
+		},
+		{
+			name:    "fields the apply set are cleared",
+			record:  nestedRecord{Name: "Miami"},
+			changes: map[string]interface{}{"details": map[string]interface{}{"source": "radar", "level": 2}},
+		},
+		{
+			name:    "fields the apply nulled are restored",
+			record:  nestedRecord{Name: "Miami", Details: &nestedDetails{Source: "radar", Level: 2}},
+			changes: map[string]interface{}{"details": nil},
+		},
+		{
+			name:    "nested fields",
+			record:  nestedRecord{Details: &nestedDetails{Source: "radar", Level: 2}},
+			changes: map[string]interface{}{"details": map[string]interface{}{"level": 3}, "inline": map[string]interface{}{"source": "buoy"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := tt.record
+			want := tt.record
+			if tt.record.Details != nil {
+				details := *tt.record.Details
+				record.Details = &details
+			}
+
+			result, err := ApplyChangesWrapper(tt.changes, "EUA1", &record)
+			if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			undone, err := Undo(result, "EUA2", &record)
+			if err != nil {
+				t.Fatalf("Undo() error = %v", err)
+			}
+
+			if record.ModifiedBy == nil || *record.ModifiedBy != "EUA2" {
+				t.Errorf("ModifiedBy = %v, want the undo stamped by EUA2", record.ModifiedBy)
+			}
+			record.BaseStruct, record.ModifiedByPrincipal = want.BaseStruct, want.ModifiedByPrincipal
+			if !reflect.DeepEqual(record, want) {
+				t.Errorf("record = %+v, want %+v", record, want)
+			}
+			if len(unstampedChanges(undone.Changes)) == 0 {
+				t.Errorf("Undo() changes = %v, want the restored fields", undone.Changes)
+			}
+		})
+	}
+}
+
+func TestUndoReplacesMergedFields(t *testing.T) {
+	record := undoneRecord{Tags: map[string]interface{}{"region": "east"}}
+	result, err := ApplyChanges(map[string]interface{}{"tags": map[string]interface{}{"priority": "high"}}, &record)
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	if _, err := Undo(result, "EUA2", &record); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if want := map[string]interface{}{"region": "east"}; !reflect.DeepEqual(record.Tags, want) {
+		t.Errorf("Tags = %v, want %v without the merged key", record.Tags, want)
+	}
+}
+
+func TestUndoEncryptedFields(t *testing.T) {
+	opts := []Option{WithFieldCipher(testCipher())}
+	record := undoneRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"ssn": "111-11-1111"}, &record, opts...); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	result, err := ApplyChanges(map[string]interface{}{"ssn": "222-22-2222"}, &record, opts...)
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if _, err := Undo(result, "EUA2", &record, opts...); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+
+	if got, err := Decrypted(&record, "ssn", opts...); err != nil || got != "111-11-1111" {
+		t.Errorf("Decrypted() = %q, %v, want the first value back", got, err)
+	}
+}
+
+func TestUndoTruncated(t *testing.T) {
+	record := nestedRecord{}
+	result, err := ApplyChanges(map[string]interface{}{"name": "a name longer than the cap"}, &record, WithMaxDiffValueBytes(4))
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	if _, err := Undo(result, "EUA2", &record); err == nil {
+		t.Errorf("Undo() of a truncated result succeeded, want an error")
+	}
+	if record.Name != "a name longer than the cap" {
+		t.Errorf("Name = %q, want it untouched", record.Name)
+	}
+}
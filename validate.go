@@ -0,0 +1,68 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Validator checks a target once the changes have been decoded onto it, see
+// WithValidator. It can return any error, e.g. go-playground/validator's
+// ValidationErrors for `validate:"..."` tags, or ValidationErrors for
+// field-level failures.
+type Validator interface {
+	Validate(target interface{}) error
+}
+
+// ContextValidator is a Validator that needs the apply's context (see
+// WithContext), e.g. to check uniqueness against a database; ValidateContext
+// is called instead of Validate
+type ContextValidator interface {
+	Validator
+	ValidateContext(ctx context.Context, target interface{}) error
+}
+
+// ValidatorFunc adapts a function to the Validator interface
+type ValidatorFunc func(target interface{}) error
+
+// Validate calls f(target)
+func (f ValidatorFunc) Validate(target interface{}) error {
+	return f(target)
+}
+
+// ValidationError is a single field failing validation
+type ValidationError struct {
+	Field   string
+	Message string
+
+	// Code optionally keys the error's message in a MessageCatalog, e.g.
+	// "required", with the Params it's rendered with (along with field and
+	// message), e.g. "min" and "max" for "range"
+	Code   string
+	Params map[string]string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("'%s' %s", e.Field, e.Message)
+}
+
+// ValidationErrors are all the fields of a target that failed validation
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// validate runs the validator, with the context when it takes one
+func validate(ctx context.Context, validator Validator, target interface{}) error {
+	if validator, ok := validator.(ContextValidator); ok {
+		return validator.ValidateContext(ctx, target)
+	}
+
+	return validator.Validate(target)
+}
@@ -0,0 +1,95 @@
+package applychanges
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// contextValidator records the context it was called with
+type contextValidator struct {
+	ctx         context.Context
+	contextless bool
+}
+
+func (v *contextValidator) Validate(interface{}) error {
+	v.contextless = true
+	return nil
+}
+
+func (v *contextValidator) ValidateContext(ctx context.Context, _ interface{}) error {
+	v.ctx = ctx
+	return nil
+}
+
+type validationKey struct{}
+
+func TestValidationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "one field",
+			err:  ValidationError{Field: "title", Message: "is required"},
+			want: "'title' is required",
+		},
+		{
+			name: "several fields",
+			err:  ValidationErrors{{Field: "title", Message: "is required"}, {Field: "body", Message: "is too short"}},
+			want: "validation failed: 'title' is required; 'body' is too short",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithValidator(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		validation bool
+		wantTitle  string
+	}{
+		{name: "passes", wantTitle: "Final"},
+		{name: "validation errors", err: ValidationErrors{{Field: "title", Message: "is taken"}}, validation: true, wantTitle: "Draft"},
+		{name: "any error", err: context.DeadlineExceeded, wantTitle: "Draft"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := ValidatorFunc(func(interface{}) error { return tt.err })
+			record := documentRecord{Title: "Draft"}
+			_, err := ApplyChanges(map[string]interface{}{"title": "Final"}, &record, WithValidator(validator))
+
+			var validation ValidationErrors
+			if errors.As(err, &validation) != tt.validation || (!tt.validation && !errors.Is(err, tt.err)) {
+				t.Fatalf("ApplyChanges() error = %v, want %v", err, tt.err)
+			}
+			if record.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", record.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestContextValidator(t *testing.T) {
+	ctx := context.WithValue(context.Background(), validationKey{}, "request")
+	validator := &contextValidator{}
+
+	record := documentRecord{}
+	if _, err := ApplyChanges(map[string]interface{}{"title": "Final"}, &record, WithContext(ctx), WithValidator(validator)); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	if validator.contextless || validator.ctx == nil || validator.ctx.Value(validationKey{}) != "request" {
+		t.Errorf("validator = %+v, want ValidateContext called with the apply's context", validator)
+	}
+}
@@ -0,0 +1,60 @@
+package applychanges
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BehaviorVersion identifies the apply semantics implemented by this package,
+// as MAJOR.MINOR.PATCH. It is bumped whenever the outcome of applying the same
+// changes to the same target could differ (sanitization rules, decode hooks,
+// metadata stamping, etc.):
+//   - MAJOR when previously produced results can no longer be reproduced
+//   - MINOR when new behavior is added without changing existing outcomes
+//   - PATCH for fixes that consumers shouldn't need to gate on
+//
+// Anything recording the result of an apply should record this alongside it, so
+// replaying it later can tell which semantics produced it.
+const BehaviorVersion = "7.1.0"
+
+// CompatibleWith reports whether something produced under the given behavior
+// version can be interpreted with the current semantics: the major versions
+// must match and the version must not be newer than BehaviorVersion.
+func CompatibleWith(version string) bool {
+	theirs, ok := parseBehaviorVersion(version)
+	if !ok {
+		return false
+	}
+
+	ours, _ := parseBehaviorVersion(BehaviorVersion)
+	if theirs[0] != ours[0] {
+		return false
+	}
+
+	for i := 1; i < len(ours); i++ {
+		if theirs[i] != ours[i] {
+			return theirs[i] < ours[i]
+		}
+	}
+
+	return true
+}
+
+func parseBehaviorVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) != len(parsed) {
+		return parsed, false
+	}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+
+	return parsed, true
+}
@@ -0,0 +1,80 @@
+package applychanges
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// recordingSink collects the audit entries it is handed
+type recordingSink struct {
+	entries []AuditEntry
+	err     error
+}
+
+func (s *recordingSink) Record(_ context.Context, entry AuditEntry) error {
+	s.entries = append(s.entries, entry)
+	return s.err
+}
+
+// recordingPublisher collects the events it is handed
+type recordingPublisher struct {
+	events []ChangeApplied
+	err    error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event ChangeApplied) error {
+	p.events = append(p.events, event)
+	return p.err
+}
+
+func TestCompatibleWith(t *testing.T) {
+	ours, _ := parseBehaviorVersion(BehaviorVersion)
+	format := func(major, minor, patch int) string {
+		return fmt.Sprintf("%d.%d.%d", major, minor, patch)
+	}
+
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{BehaviorVersion, true},
+		{"v" + BehaviorVersion, true},
+		{format(ours[0], 0, 0), true},
+		{format(ours[0], ours[1], ours[2]+1), false},
+		{format(ours[0], ours[1]+1, 0), false},
+		{format(ours[0]+1, 0, 0), false},
+		{format(ours[0]-1, ours[1], ours[2]), false},
+		{"", false},
+		{"1.2", false},
+		{"1.2.x", false},
+		{"1.-2.3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if got := CompatibleWith(tt.version); got != tt.want {
+				t.Errorf("CompatibleWith(%q) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBehaviorVersionRecorded(t *testing.T) {
+	sink := &recordingSink{}
+	publisher := &recordingPublisher{}
+
+	record := nestedRecord{}
+	_, err := ApplyChangesWrapper(map[string]interface{}{"name": "x"}, "EUA1", &record,
+		WithAuditSink(sink), WithEventPublisher(publisher))
+	if err != nil {
+		t.Fatalf("ApplyChangesWrapper() error = %v", err)
+	}
+
+	if len(sink.entries) != 1 || sink.entries[0].BehaviorVersion != BehaviorVersion {
+		t.Errorf("audit entries = %+v, want one with BehaviorVersion %s", sink.entries, BehaviorVersion)
+	}
+	if len(publisher.events) != 1 || publisher.events[0].BehaviorVersion != BehaviorVersion {
+		t.Errorf("events = %+v, want one with BehaviorVersion %s", publisher.events, BehaviorVersion)
+	}
+}
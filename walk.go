@@ -0,0 +1,179 @@
+package applychanges
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// valueVisitor checks a string or float found in the changes, see walkChanges.
+// It returns a replacement of the same type to store in the value's place, or
+// the zero Value to leave it alone.
+type valueVisitor func(path string, value reflect.Value) (reflect.Value, error)
+
+// walkChanges visits every string and float in the changes, at any depth:
+// behind pointers and interfaces, and in slices, arrays, maps and exported
+// struct fields. Values are visited with their path, e.g. `details.notes[2]`,
+// and replacements are stored in place, in copies of the arrays and structs
+// that can't be changed where they are. Keys are walked in no particular
+// order, stopping at the first error.
+func walkChanges(changes map[string]interface{}, visit valueVisitor) error {
+	for key, value := range changes {
+		replacement, err := walkValue(key, reflect.ValueOf(value), visit)
+		if err != nil {
+			return err
+		}
+		if replacement.IsValid() {
+			changes[key] = replacement.Interface()
+		}
+	}
+
+	return nil
+}
+
+// walkValue visits the strings and floats in the value, returning what to
+// replace the value itself with, if anything
+func walkValue(path string, value reflect.Value, visit valueVisitor) (reflect.Value, error) {
+	switch value.Kind() {
+	case reflect.String, reflect.Float32, reflect.Float64:
+		return visit(path, value)
+	case reflect.Interface:
+		if value.IsNil() {
+			return reflect.Value{}, nil
+		}
+		return walkValue(path, value.Elem(), visit)
+	case reflect.Ptr:
+		if value.IsNil() {
+			return reflect.Value{}, nil
+		}
+		replacement, err := walkValue(path, value.Elem(), visit)
+		if replacement.IsValid() {
+			value.Elem().Set(replacement)
+		}
+		return reflect.Value{}, err
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.Value{}, nil
+		}
+		_, err := walkElements(path, value, visit)
+		return reflect.Value{}, err
+	case reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.Value{}, nil
+		}
+		return walkCopy(value, func(settable reflect.Value) (bool, error) {
+			return walkElements(path, settable, visit)
+		})
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			nestedPath := path + "." + fmt.Sprint(iter.Key().Interface())
+			replacement, err := walkValue(nestedPath, iter.Value(), visit)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if replacement.IsValid() {
+				value.SetMapIndex(iter.Key(), replacement)
+			}
+		}
+	case reflect.Struct:
+		if !hasExportedFields(value.Type()) {
+			return reflect.Value{}, nil
+		}
+		return walkCopy(value, func(settable reflect.Value) (bool, error) {
+			return walkFields(path, settable, visit)
+		})
+	}
+
+	return reflect.Value{}, nil
+}
+
+// walkElements walks the elements of a slice, or of a settable array,
+// replacing them in place
+func walkElements(path string, value reflect.Value, visit valueVisitor) (bool, error) {
+	replaced := false
+	for i := 0; i < value.Len(); i++ {
+		replacement, err := walkValue(fmt.Sprintf("%s[%d]", path, i), value.Index(i), visit)
+		if err != nil {
+			return false, err
+		}
+		if replacement.IsValid() {
+			value.Index(i).Set(replacement)
+			replaced = true
+		}
+	}
+
+	return replaced, nil
+}
+
+// walkFields walks the exported fields of a settable struct, replacing them in
+// place. Fields are named by their Go name, since the struct isn't necessarily
+// the target of the changes.
+func walkFields(path string, value reflect.Value, visit valueVisitor) (bool, error) {
+	replaced := false
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).PkgPath != "" {
+			continue
+		}
+
+		replacement, err := walkValue(path+"."+value.Type().Field(i).Name, value.Field(i), visit)
+		if err != nil {
+			return false, err
+		}
+		if replacement.IsValid() {
+			value.Field(i).Set(replacement)
+			replaced = true
+		}
+	}
+
+	return replaced, nil
+}
+
+// hasExportedFields reports whether a struct type (time.Time, say) has any
+// fields to walk
+func hasExportedFields(structType reflect.Type) bool {
+	for i := 0; i < structType.NumField(); i++ {
+		if structType.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// walkCopy walks an array or struct in place when it's settable, or else in a
+// copy, which is returned as its replacement when anything was replaced
+func walkCopy(value reflect.Value, walk func(settable reflect.Value) (bool, error)) (reflect.Value, error) {
+	if value.CanSet() {
+		_, err := walk(value)
+		return reflect.Value{}, err
+	}
+
+	settable := reflect.New(value.Type()).Elem()
+	settable.Set(value)
+
+	replaced, err := walk(settable)
+	if err != nil || !replaced {
+		return reflect.Value{}, err
+	}
+
+	return settable, nil
+}
+
+// checkValues checks every string in the changes is valid UTF-8 (repairing it
+// with WithUTF8Repair), and every float is finite (unless
+// WithAllowNonFiniteFloats), in one walk
+func (cfg *config) checkValues(changes map[string]interface{}) error {
+	return walkChanges(changes, func(path string, value reflect.Value) (reflect.Value, error) {
+		if value.Kind() == reflect.String {
+			if cfg.allowInvalidUTF8 && !cfg.repairUTF8 {
+				return reflect.Value{}, nil
+			}
+			return checkUTF8(path, value, cfg.repairUTF8)
+		}
+		if cfg.allowNonFiniteFloats {
+			return reflect.Value{}, nil
+		}
+
+		return reflect.Value{}, checkFloat(path, value)
+	})
+}
@@ -0,0 +1,251 @@
+package applychanges
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of a webhook's body,
+// keyed by its secret, as "sha256=<hex>"
+const WebhookSignatureHeader = "X-Apply-Signature-256"
+
+// Webhook is an endpoint notified of the changes applied to one target type,
+// see WebhookDispatcher.Register
+type Webhook struct {
+	URL string
+
+	// Secret signs every request (see WebhookSignatureHeader); requests are
+	// unsigned without one
+	Secret []byte
+}
+
+// WebhookConfig configures NewWebhookDispatcher
+type WebhookConfig struct {
+	// Client sends the requests (http.DefaultClient by default)
+	Client *http.Client
+
+	// Timeout bounds every attempt at a request (10s by default); an attempt
+	// timing out is retried like a network error
+	Timeout time.Duration
+
+	// MaxAttempts is how many times a request is tried before giving up (3 by
+	// default). Requests are retried after a network error, a 429 or a 5xx.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, doubling for every retry
+	// after it (500ms by default)
+	Backoff time.Duration
+}
+
+// WebhookDispatcher is an EventPublisher POSTing every ChangeApplied event, as
+// JSON, to the webhooks registered for its target type. Publish delivers to
+// them in turn, retrying as configured, so it's usually wrapped in an
+// AsyncPublisher:
+//
+//	webhooks := applychanges.NewWebhookDispatcher(applychanges.WebhookConfig{})
+//	webhooks.Register("SystemIntake", applychanges.Webhook{URL: url, Secret: secret})
+//	publisher := applychanges.NewAsyncPublisher(webhooks, applychanges.AsyncPublisherConfig{})
+type WebhookDispatcher struct {
+	config WebhookConfig
+
+	mu       sync.RWMutex
+	webhooks map[string][]Webhook
+}
+
+// NewWebhookDispatcher returns a WebhookDispatcher without any webhooks
+func NewWebhookDispatcher(config WebhookConfig) *WebhookDispatcher {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultWebhookTimeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = 500 * time.Millisecond
+	}
+
+	return &WebhookDispatcher{config: config, webhooks: map[string][]Webhook{}}
+}
+
+// Register adds a webhook for the changes applied to targets of the given type
+// (ChangeApplied.TargetType, e.g. "SystemIntake")
+func (d *WebhookDispatcher) Register(targetType string, webhook Webhook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.webhooks[targetType] = append(d.webhooks[targetType], webhook)
+}
+
+// Publish delivers the event to every webhook registered for its target type,
+// failing with the URLs that couldn't be delivered to
+func (d *WebhookDispatcher) Publish(ctx context.Context, event ChangeApplied) error {
+	d.mu.RLock()
+	webhooks := d.webhooks[event.TargetType]
+	d.mu.RUnlock()
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, webhook := range webhooks {
+		if err := d.deliver(ctx, webhook, body); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", webhook.URL, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("delivering webhooks: %s", strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+// deliver POSTs the body to the webhook until it succeeds, fails permanently
+// or runs out of attempts
+func (d *WebhookDispatcher) deliver(ctx context.Context, webhook Webhook, body []byte) error {
+	backoff := d.config.Backoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		if retry, err = d.post(ctx, webhook, body); err == nil || !retry || attempt == d.config.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// post sends a single request within the Timeout, reporting whether a failure
+// is worth retrying
+func (d *WebhookDispatcher) post(ctx context.Context, webhook Webhook, body []byte) (bool, error) {
+	var signature string
+	if len(webhook.Secret) > 0 {
+		signature = SignWebhook(webhook.Secret, body)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+
+	retry, err := postWebhook(attemptCtx, d.config.Client, webhook.URL, body, WebhookSignatureHeader, signature)
+	if err != nil && attemptCtx.Err() != nil && ctx.Err() == nil {
+		retry = true
+	}
+
+	return retry, err
+}
+
+// WebhookEmitter is an EventPublisher POSTing every ChangeApplied event, as
+// JSON, to a single URL, signed in its X-Signature header like SignWebhook.
+// Each event is tried once, within the Timeout and the apply's context: retry
+// it WithEmitterRetry, or publish through an AsyncPublisher to keep it off the
+// apply's path.
+type WebhookEmitter struct {
+	URL    string
+	Secret string
+
+	// Timeout bounds every request (10s by default)
+	Timeout time.Duration
+
+	// Client sends the requests (http.DefaultClient by default)
+	Client *http.Client
+}
+
+// NewWebhookEmitter returns a WebhookEmitter for the URL
+func NewWebhookEmitter(url, secret string, timeout time.Duration) *WebhookEmitter {
+	return &WebhookEmitter{URL: url, Secret: secret, Timeout: timeout}
+}
+
+// Publish POSTs the event, failing for a network error, a timeout or a status
+// other than 2xx
+func (e *WebhookEmitter) Publish(ctx context.Context, event ChangeApplied) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	_, err = postWebhook(ctx, client, e.URL, body, "X-Signature", SignWebhook([]byte(e.Secret), body))
+	return err
+}
+
+// defaultWebhookTimeout bounds a webhook request when no timeout is configured
+const defaultWebhookTimeout = 10 * time.Second
+
+// postWebhook sends a single request, signed in the header unless signature is
+// empty, reporting whether a failure is worth retrying
+func postWebhook(ctx context.Context, client *http.Client, url string, body []byte, header, signature string) (bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		request.Header.Set(header, signature)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", response.Status)
+}
+
+// SignWebhook returns the WebhookSignatureHeader value for a body, see
+// VerifyWebhookSignature
+func SignWebhook(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether the signature header of a webhook
+// request (WebhookSignatureHeader or a WebhookEmitter's X-Signature) matches
+// its body, in constant time
+func VerifyWebhookSignature(body []byte, header, secret string) bool {
+	return hmac.Equal([]byte(header), []byte(SignWebhook([]byte(secret), body)))
+}
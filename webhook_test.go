@@ -0,0 +1,261 @@
+package applychanges
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the requests made to it, answering with the next
+// of its statuses (200 once they run out) after the delay
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	delay    time.Duration
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+
+	r.mu.Lock()
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, request.Header.Clone())
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-time.After(r.delay):
+	case <-request.Context().Done():
+	}
+	w.WriteHeader(status)
+}
+
+func TestWebhookEmitter(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		delay        time.Duration
+		opts         []Option
+		wantErr      string
+		wantRequests int
+	}{
+		{
+			name:         "delivered",
+			wantRequests: 1,
+		},
+		{
+			name:         "failed",
+			statuses:     []int{http.StatusInternalServerError},
+			wantErr:      "publishing change event: unexpected status 500 Internal Server Error",
+			wantRequests: 1,
+		},
+		{
+			name:         "retried WithEmitterRetry",
+			statuses:     []int{http.StatusInternalServerError, http.StatusBadGateway},
+			opts:         []Option{WithEmitterRetry(3, nil)},
+			wantRequests: 3,
+		},
+		{
+			name:         "timed out",
+			delay:        time.Second,
+			wantErr:      "context deadline exceeded",
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{statuses: tt.statuses, delay: tt.delay}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			emitter := NewWebhookEmitter(server.URL, "s3cret", 50*time.Millisecond)
+			report := dualWriteReport{City: "Tampa"}
+			opts := append([]Option{WithEventPublisher(emitter)}, tt.opts...)
+			_, err := ApplyChangesWrapper(map[string]interface{}{"city": "Miami"}, "EUA1", &report, opts...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			// waits for the handlers, which outlive a request timing out
+			server.Close()
+			if len(receiver.bodies) != tt.wantRequests {
+				t.Fatalf("received %d requests, want %d", len(receiver.bodies), tt.wantRequests)
+			}
+
+			body, header := receiver.bodies[0], receiver.headers[0]
+			if !VerifyWebhookSignature(body, header.Get("X-Signature"), "s3cret") {
+				t.Errorf("X-Signature %q doesn't match the body", header.Get("X-Signature"))
+			}
+			if header.Get("Content-Type") != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", header.Get("Content-Type"))
+			}
+
+			var event ChangeApplied
+			if err := json.Unmarshal(body, &event); err != nil {
+				t.Fatalf("decoding the body: %v", err)
+			}
+			if event.TargetType != "dualWriteReport" || event.Modifier != "EUA1" || len(event.Diff) == 0 || event.Diff[0].New != "Miami" {
+				t.Errorf("event = %+v, want the city change by EUA1", event)
+			}
+		})
+	}
+}
+
+func TestWebhookEmitterContext(t *testing.T) {
+	receiver := &webhookReceiver{delay: time.Second}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	emitter := NewWebhookEmitter(server.URL, "s3cret", time.Minute)
+	err := emitter.Publish(ctx, ChangeApplied{TargetType: "dualWriteReport"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish() error = %v, want the context's deadline honored", err)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"targetType":"SystemIntake"}`)
+	signature := SignWebhook([]byte("s3cret"), body)
+
+	tests := []struct {
+		name   string
+		body   []byte
+		header string
+		secret string
+		want   bool
+	}{
+		{name: "valid", body: body, header: signature, secret: "s3cret", want: true},
+		{name: "wrong secret", body: body, header: signature, secret: "other"},
+		{name: "tampered body", body: []byte(`{"targetType":"Other"}`), header: signature, secret: "s3cret"},
+		{name: "missing header", body: body, secret: "s3cret"},
+		{name: "bare hex", body: body, header: strings.TrimPrefix(signature, "sha256="), secret: "s3cret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyWebhookSignature(tt.body, tt.header, tt.secret); got != tt.want {
+				t.Errorf("VerifyWebhookSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		delay        time.Duration
+		secret       []byte
+		targetType   string
+		wantErr      string
+		wantRequests int
+	}{
+		{
+			name:         "signed",
+			secret:       []byte("s3cret"),
+			targetType:   "dualWriteReport",
+			wantRequests: 1,
+		},
+		{
+			name:         "unsigned",
+			targetType:   "dualWriteReport",
+			wantRequests: 1,
+		},
+		{
+			name:       "other target types",
+			targetType: "SystemIntake",
+		},
+		{
+			name:         "retried after a 429 and a 5xx",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+			targetType:   "dualWriteReport",
+			wantRequests: 3,
+		},
+		{
+			name:         "not retried after a 4xx",
+			statuses:     []int{http.StatusBadRequest},
+			targetType:   "dualWriteReport",
+			wantErr:      "unexpected status 400 Bad Request",
+			wantRequests: 1,
+		},
+		{
+			name:         "out of attempts",
+			statuses:     []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			targetType:   "dualWriteReport",
+			wantErr:      "unexpected status 500 Internal Server Error",
+			wantRequests: 3,
+		},
+		{
+			name:         "every attempt timed out",
+			delay:        time.Second,
+			targetType:   "dualWriteReport",
+			wantErr:      "context deadline exceeded",
+			wantRequests: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{statuses: tt.statuses, delay: tt.delay}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			dispatcher := NewWebhookDispatcher(WebhookConfig{Timeout: 20 * time.Millisecond, Backoff: time.Millisecond})
+			dispatcher.Register(tt.targetType, Webhook{URL: server.URL, Secret: tt.secret})
+
+			report := dualWriteReport{City: "Tampa"}
+			_, err := ApplyChangesWrapper(map[string]interface{}{"city": "Miami"}, "EUA1", &report, WithEventPublisher(dispatcher))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), server.URL+": ") || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyChangesWrapper() error = %v, want %q for %s", err, tt.wantErr, server.URL)
+				}
+			} else if err != nil {
+				t.Fatalf("ApplyChangesWrapper() error = %v", err)
+			}
+
+			server.Close()
+			if len(receiver.bodies) != tt.wantRequests {
+				t.Fatalf("received %d requests, want %d", len(receiver.bodies), tt.wantRequests)
+			}
+			if tt.wantRequests == 0 {
+				return
+			}
+
+			signature := receiver.headers[0].Get(WebhookSignatureHeader)
+			if tt.secret == nil && signature != "" {
+				t.Errorf("%s = %q, want unsigned", WebhookSignatureHeader, signature)
+			}
+			if tt.secret != nil && !VerifyWebhookSignature(receiver.bodies[0], signature, string(tt.secret)) {
+				t.Errorf("%s %q doesn't match the body", WebhookSignatureHeader, signature)
+			}
+		})
+	}
+}
+
+func TestNewWebhookDispatcherDefaults(t *testing.T) {
+	dispatcher := NewWebhookDispatcher(WebhookConfig{})
+
+	if dispatcher.config.Timeout != defaultWebhookTimeout || dispatcher.config.MaxAttempts != 3 || dispatcher.config.Backoff != 500*time.Millisecond {
+		t.Errorf("config = %+v, want the documented defaults", dispatcher.config)
+	}
+}